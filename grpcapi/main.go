@@ -0,0 +1,17 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/danielpaulus/go-ios/grpcapi/server"
+	log "github.com/sirupsen/logrus"
+)
+
+func main() {
+	address := flag.String("address", ":50051", "address the gRPC API listens on, f.ex. 127.0.0.1:50051")
+	flag.Parse()
+	log.WithField("address", *address).Info("starting go-iOS gRPC API")
+	if err := server.Serve(*address); err != nil {
+		log.Fatal(err)
+	}
+}
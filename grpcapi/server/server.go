@@ -0,0 +1,332 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/danielpaulus/go-ios/grpcapi/goiospb"
+	"github.com/danielpaulus/go-ios/ios"
+	"github.com/danielpaulus/go-ios/ios/syslog"
+	"github.com/danielpaulus/go-ios/ios/testmanagerd"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// connectionPoolIdleTimeout is how long the lockdown session of a device is kept open without requests
+const connectionPoolIdleTimeout = 5 * time.Minute
+
+// logMessageSource delivers the messages of the device log, implemented by syslog.Connection
+type logMessageSource interface {
+	ReadLogMessage() (string, error)
+	Close() error
+}
+
+// Server implements the GoIOS service of goios.proto with go-ios
+type Server struct {
+	goiospb.UnimplementedGoIOSServer
+	listDevices func() (ios.DeviceList, error)
+	listen      func() (func() (ios.AttachedMessage, error), func() error, error)
+	getDevice   func(udid string) (ios.DeviceEntry, error)
+	syslog      func(device ios.DeviceEntry) (logMessageSource, error)
+	runTests    func(ctx context.Context, config testmanagerd.TestConfig) ([]testmanagerd.TestSuite, error)
+}
+
+// New creates a Server for the devices connected to this host. Services of the devices are started on the lockdown
+// sessions of pool, if it is not nil.
+func New(pool *ios.ConnectionPool) *Server {
+	return &Server{
+		listDevices: ios.ListDevices,
+		listen:      ios.Listen,
+		getDevice: func(udid string) (ios.DeviceEntry, error) {
+			device, err := ios.GetDevice(udid)
+			device.ConnectionPool = pool
+			return device, err
+		},
+		syslog: func(device ios.DeviceEntry) (logMessageSource, error) {
+			return syslog.New(device)
+		},
+		runTests: testmanagerd.RunTestWithConfig,
+	}
+}
+
+// Serve starts the gRPC server on address, f.ex. "127.0.0.1:50051", and returns when the server fails. Services
+// are started on pooled lockdown sessions while it runs, see ios.ConnectionPool
+func Serve(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("Serve: failed to listen on %s: %w", address, err)
+	}
+	pool := ios.NewConnectionPool(connectionPoolIdleTimeout)
+	defer pool.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		if err := pool.EvictDetachedDevices(ctx); err != nil {
+			log.WithError(err).Warn("pooled connections of detached devices will not be closed")
+		}
+	}()
+
+	grpcServer := grpc.NewServer()
+	goiospb.RegisterGoIOSServer(grpcServer, New(pool))
+	log.WithField("address", listener.Addr().String()).Info("gRPC server started")
+	return grpcServer.Serve(listener)
+}
+
+func (s *Server) ListDevices(ctx context.Context, req *goiospb.ListDevicesRequest) (*goiospb.ListDevicesResponse, error) {
+	deviceList, err := s.listDevices()
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "cannot list devices: %v", err)
+	}
+	response := &goiospb.ListDevicesResponse{}
+	for _, device := range deviceList.DeviceList {
+		response.Devices = append(response.Devices, toDevice(device.DeviceID, device.Properties))
+	}
+	return response, nil
+}
+
+func (s *Server) WatchDevices(req *goiospb.WatchDevicesRequest, stream goiospb.GoIOS_WatchDevicesServer) error {
+	receive, closeListener, err := s.listen()
+	if err != nil {
+		return status.Errorf(codes.Unavailable, "cannot listen for devices: %v", err)
+	}
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		closeListener()
+	}()
+
+	// detach messages only contain the device id, so the attached devices are remembered
+	attached := map[int]*goiospb.Device{}
+	for {
+		message, err := receive()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return status.Errorf(codes.Unavailable, "stopped listening for devices: %v", err)
+		}
+		event := &goiospb.DeviceEvent{}
+		switch message.MessageType {
+		case "Attached":
+			event.Type = goiospb.DeviceEvent_TYPE_ATTACHED
+			event.Device = toDevice(message.DeviceID, message.Properties)
+			attached[message.DeviceID] = event.Device
+		case "Detached":
+			event.Type = goiospb.DeviceEvent_TYPE_DETACHED
+			event.Device = attached[message.DeviceID]
+			if event.Device == nil {
+				event.Device = &goiospb.Device{DeviceId: int32(message.DeviceID)}
+			}
+			delete(attached, message.DeviceID)
+		default:
+			continue
+		}
+		if err := stream.Send(event); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) StreamSyslog(req *goiospb.StreamSyslogRequest, stream goiospb.GoIOS_StreamSyslogServer) error {
+	device, err := s.getDevice(req.Udid)
+	if err != nil {
+		return status.Errorf(codes.NotFound, "device not found: %v", err)
+	}
+	source, err := s.syslog(device)
+	if err != nil {
+		return status.Errorf(codes.Unavailable, "cannot connect to the syslog: %v", err)
+	}
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		source.Close()
+	}()
+
+	processes := map[string]bool{}
+	for _, process := range req.Processes {
+		processes[process] = true
+	}
+	parse := syslog.Parser()
+	for {
+		message, err := source.ReadLogMessage()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return status.Errorf(codes.Unavailable, "stopped reading the syslog: %v", err)
+		}
+		entry := toLogEntry(parse, strings.TrimRight(message, "\x00\n"))
+		// the process is logged with its subsystem, f.ex. MyApp(UIKitCore)
+		process, _, _ := strings.Cut(entry.Process, "(")
+		if len(processes) > 0 && !processes[process] {
+			continue
+		}
+		if err := stream.Send(entry); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) RunTests(stream goiospb.GoIOS_RunTestsServer) error {
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	start := first.GetStart()
+	if start == nil {
+		return status.Error(codes.InvalidArgument, "the first request must start the test run")
+	}
+	if start.TestRunnerBundleId == "" {
+		return status.Error(codes.InvalidArgument, "test_runner_bundle_id is missing")
+	}
+	device, err := s.getDevice(start.Udid)
+	if err != nil {
+		return status.Errorf(codes.NotFound, "device not found: %v", err)
+	}
+	// every run gets its own attachments directory, so that concurrent runs do not share it
+	attachmentsDir, err := os.MkdirTemp("", "go-ios-grpc-run-")
+	if err != nil {
+		return status.Errorf(codes.Internal, "cannot create the attachments directory: %v", err)
+	}
+	defer os.RemoveAll(attachmentsDir)
+
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	go func() {
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			if req.GetCancel() != nil {
+				log.WithField("udid", device.Properties.SerialNumber).Info("test run cancelled by the client")
+				cancel()
+				return
+			}
+		}
+	}()
+
+	events, eventWriter := io.Pipe()
+	env := map[string]any{}
+	for k, v := range start.Env {
+		env[k] = v
+	}
+	config := testmanagerd.TestConfig{
+		BundleId:           start.BundleId,
+		TestRunnerBundleId: start.TestRunnerBundleId,
+		XctestConfigName:   start.XctestConfig,
+		Env:                env,
+		Args:               start.Args,
+		TestsToRun:         start.TestsToRun,
+		TestsToSkip:        start.TestsToSkip,
+		XcTest:             start.Xctest,
+		Device:             device,
+		Listener:           testmanagerd.NewTestListener(io.Discard, io.Discard, attachmentsDir),
+		EventStream:        eventWriter,
+	}
+	runErr := make(chan error, 1)
+	go func() {
+		_, err := s.runTests(ctx, config)
+		eventWriter.Close()
+		runErr <- err
+	}()
+
+	decoder := json.NewDecoder(events)
+	var sendErr error
+	for sendErr == nil {
+		var event testmanagerd.TestEvent
+		if err := decoder.Decode(&event); err != nil {
+			break
+		}
+		sendErr = stream.Send(toTestEvent(event))
+	}
+	if sendErr != nil {
+		// the client is gone, stop the run and drain the events so it can finish
+		cancel()
+		io.Copy(io.Discard, events)
+		<-runErr
+		return sendErr
+	}
+
+	finished := &goiospb.TestEvent{Type: goiospb.TestEvent_TYPE_RUN_FINISHED, Time: timestamppb.Now()}
+	if err := <-runErr; err != nil {
+		finished.RunError = err.Error()
+	}
+	return stream.Send(finished)
+}
+
+func toDevice(deviceID int, properties ios.DeviceProperties) *goiospb.Device {
+	return &goiospb.Device{
+		Udid:           properties.SerialNumber,
+		DeviceId:       int32(deviceID),
+		ConnectionType: properties.ConnectionType,
+		ProductId:      int32(properties.ProductID),
+		LocationId:     int32(properties.LocationID),
+	}
+}
+
+func toLogEntry(parse func(string) (*syslog.LogEntry, error), message string) *goiospb.LogEntry {
+	entry, err := parse(message)
+	if err != nil {
+		return &goiospb.LogEntry{Raw: message}
+	}
+	return &goiospb.LogEntry{
+		Timestamp: entry.Timestamp,
+		Device:    entry.Device,
+		Process:   entry.Process,
+		Pid:       entry.PID,
+		Level:     entry.Level,
+		Message:   entry.Message,
+		Raw:       message,
+	}
+}
+
+var testEventTypes = map[testmanagerd.TestEventType]goiospb.TestEvent_Type{
+	testmanagerd.EventTestSuiteStarted:  goiospb.TestEvent_TYPE_TEST_SUITE_STARTED,
+	testmanagerd.EventTestSuiteFinished: goiospb.TestEvent_TYPE_TEST_SUITE_FINISHED,
+	testmanagerd.EventTestStarted:       goiospb.TestEvent_TYPE_TEST_STARTED,
+	testmanagerd.EventTestFailed:        goiospb.TestEvent_TYPE_TEST_FAILED,
+	testmanagerd.EventTestFinished:      goiospb.TestEvent_TYPE_TEST_FINISHED,
+	testmanagerd.EventAttachment:        goiospb.TestEvent_TYPE_ATTACHMENT,
+	testmanagerd.EventLog:               goiospb.TestEvent_TYPE_LOG,
+	testmanagerd.EventTestPlanFinished:  goiospb.TestEvent_TYPE_TEST_PLAN_FINISHED,
+	testmanagerd.EventSessionCrashed:    goiospb.TestEvent_TYPE_SESSION_CRASHED,
+	testmanagerd.EventOutput:            goiospb.TestEvent_TYPE_OUTPUT,
+	testmanagerd.EventRunnerStarted:     goiospb.TestEvent_TYPE_RUNNER_STARTED,
+}
+
+func toTestEvent(event testmanagerd.TestEvent) *goiospb.TestEvent {
+	result := &goiospb.TestEvent{
+		Type:       testEventTypes[event.Type],
+		Time:       timestamppb.New(event.Time),
+		Suite:      event.Suite,
+		ClassName:  event.ClassName,
+		MethodName: event.MethodName,
+		Status:     string(event.Status),
+		Duration:   event.Duration,
+		Message:    event.Message,
+		Pid:        event.PID,
+	}
+	if event.Error != nil {
+		result.Error = &goiospb.TestError{Message: event.Error.Message, File: event.Error.File, Line: event.Error.Line}
+	}
+	if event.Attachment != nil {
+		result.Attachment = &goiospb.TestAttachment{
+			Name:                  event.Attachment.Name,
+			Path:                  event.Attachment.Path,
+			UniformTypeIdentifier: event.Attachment.UniformTypeIdentifier,
+		}
+	}
+	return result
+}
@@ -0,0 +1,206 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/danielpaulus/go-ios/grpcapi/goiospb"
+	"github.com/danielpaulus/go-ios/ios"
+	"github.com/danielpaulus/go-ios/ios/testmanagerd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// startServer serves s on an in-memory connection and returns a client for it
+func startServer(t *testing.T, s *Server) goiospb.GoIOSClient {
+	listener := bufconn.Listen(1024 * 1024)
+	grpcServer := grpc.NewServer()
+	goiospb.RegisterGoIOSServer(grpcServer, s)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return goiospb.NewGoIOSClient(conn)
+}
+
+func fakeDevice(udid string) (ios.DeviceEntry, error) {
+	return ios.DeviceEntry{Properties: ios.DeviceProperties{SerialNumber: udid}}, nil
+}
+
+func TestWatchDevices(t *testing.T) {
+	messages := make(chan ios.AttachedMessage, 3)
+	messages <- ios.AttachedMessage{MessageType: "Attached", DeviceID: 3, Properties: ios.DeviceProperties{SerialNumber: "00008110-0001", ConnectionType: "USB"}}
+	messages <- ios.AttachedMessage{MessageType: "Paired", DeviceID: 3}
+	messages <- ios.AttachedMessage{MessageType: "Detached", DeviceID: 3}
+	closed := make(chan struct{})
+	client := startServer(t, &Server{
+		listen: func() (func() (ios.AttachedMessage, error), func() error, error) {
+			receive := func() (ios.AttachedMessage, error) {
+				select {
+				case message := <-messages:
+					return message, nil
+				case <-closed:
+					return ios.AttachedMessage{}, errors.New("closed")
+				}
+			}
+			return receive, func() error {
+				close(closed)
+				return nil
+			}, nil
+		},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := client.WatchDevices(ctx, &goiospb.WatchDevicesRequest{})
+	require.NoError(t, err)
+
+	attached, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, goiospb.DeviceEvent_TYPE_ATTACHED, attached.Type)
+	assert.Equal(t, "00008110-0001", attached.Device.Udid)
+	assert.Equal(t, "USB", attached.Device.ConnectionType)
+	detached, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, goiospb.DeviceEvent_TYPE_DETACHED, detached.Type)
+	assert.Equal(t, "00008110-0001", detached.Device.Udid, "detached events contain the device that was attached")
+
+	cancel()
+	<-closed
+}
+
+// fakeSyslog returns the messages and then blocks until it is closed
+type fakeSyslog struct {
+	messages chan string
+	closed   chan struct{}
+}
+
+func (f fakeSyslog) ReadLogMessage() (string, error) {
+	select {
+	case message := <-f.messages:
+		return message, nil
+	case <-f.closed:
+		return "", io.EOF
+	}
+}
+
+func (f fakeSyslog) Close() error {
+	close(f.closed)
+	return nil
+}
+
+func TestStreamSyslog(t *testing.T) {
+	source := fakeSyslog{messages: make(chan string, 3), closed: make(chan struct{})}
+	source.messages <- "Jan 16 15:36:43 iPhone SpringBoard(FrontBoard)[58] <Notice>: not streamed\x00"
+	source.messages <- "Jan 16 15:36:44 iPhone MyApp(UIKitCore)[912] <Error>: streamed\x00"
+	source.messages <- "unparseable"
+	client := startServer(t, &Server{
+		getDevice: fakeDevice,
+		syslog: func(device ios.DeviceEntry) (logMessageSource, error) {
+			return source, nil
+		},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := client.StreamSyslog(ctx, &goiospb.StreamSyslogRequest{Udid: "00008110-0001", Processes: []string{"MyApp"}})
+	require.NoError(t, err)
+
+	entry, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, "MyApp(UIKitCore)", entry.Process)
+	assert.Equal(t, "912", entry.Pid)
+	assert.Equal(t, "Error", entry.Level)
+	assert.Equal(t, "streamed", entry.Message)
+
+	cancel()
+	<-source.closed
+}
+
+func TestRunTests(t *testing.T) {
+	var config testmanagerd.TestConfig
+	client := startServer(t, &Server{
+		getDevice: fakeDevice,
+		runTests: func(ctx context.Context, testConfig testmanagerd.TestConfig) ([]testmanagerd.TestSuite, error) {
+			config = testConfig
+			io.WriteString(config.EventStream, `{"type":"testStarted","className":"LoginTests","methodName":"testLogin"}`+"\n")
+			io.WriteString(config.EventStream, `{"type":"testFailed","className":"LoginTests","methodName":"testLogin","error":{"Message":"failed","File":"LoginTests.swift","Line":12}}`+"\n")
+			return nil, errors.New("test runner crashed")
+		},
+	})
+
+	stream, err := client.RunTests(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&goiospb.RunTestsRequest{Request: &goiospb.RunTestsRequest_Start{Start: &goiospb.TestRunConfig{
+		Udid:               "00008110-0001",
+		TestRunnerBundleId: "com.example.UITests.xctrunner",
+		TestsToRun:         []string{"LoginTests"},
+		Env:                map[string]string{"API_URL": "http://localhost"},
+	}}}))
+
+	var events []*goiospb.TestEvent
+	for {
+		event, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		events = append(events, event)
+	}
+
+	require.Len(t, events, 3)
+	assert.Equal(t, goiospb.TestEvent_TYPE_TEST_STARTED, events[0].Type)
+	assert.Equal(t, "testLogin", events[0].MethodName)
+	assert.Equal(t, goiospb.TestEvent_TYPE_TEST_FAILED, events[1].Type)
+	assert.Equal(t, uint64(12), events[1].Error.Line)
+	assert.Equal(t, goiospb.TestEvent_TYPE_RUN_FINISHED, events[2].Type)
+	assert.Equal(t, "test runner crashed", events[2].RunError)
+	assert.Equal(t, "com.example.UITests.xctrunner", config.TestRunnerBundleId)
+	assert.Equal(t, []string{"LoginTests"}, config.TestsToRun)
+	assert.Equal(t, map[string]any{"API_URL": "http://localhost"}, config.Env)
+
+	t.Run("cancel", func(t *testing.T) {
+		started := make(chan struct{})
+		client := startServer(t, &Server{
+			getDevice: fakeDevice,
+			runTests: func(ctx context.Context, testConfig testmanagerd.TestConfig) ([]testmanagerd.TestSuite, error) {
+				close(started)
+				<-ctx.Done()
+				return nil, ctx.Err()
+			},
+		})
+		stream, err := client.RunTests(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, stream.Send(&goiospb.RunTestsRequest{Request: &goiospb.RunTestsRequest_Start{Start: &goiospb.TestRunConfig{TestRunnerBundleId: "com.example.UITests.xctrunner"}}}))
+		<-started
+		require.NoError(t, stream.Send(&goiospb.RunTestsRequest{Request: &goiospb.RunTestsRequest_Cancel{Cancel: &goiospb.CancelTestRun{}}}))
+
+		finished, err := stream.Recv()
+		require.NoError(t, err)
+		assert.Equal(t, goiospb.TestEvent_TYPE_RUN_FINISHED, finished.Type)
+		assert.Equal(t, context.Canceled.Error(), finished.RunError)
+	})
+
+	t.Run("requires start", func(t *testing.T) {
+		stream, err := client.RunTests(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, stream.Send(&goiospb.RunTestsRequest{Request: &goiospb.RunTestsRequest_Cancel{Cancel: &goiospb.CancelTestRun{}}}))
+
+		_, err = stream.Recv()
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+}
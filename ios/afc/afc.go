@@ -22,6 +22,8 @@ const (
 	Afc_operation_file_write               uint64 = 0x00000010
 	Afc_operation_file_open_result         uint64 = 0x0000000E
 	Afc_operation_file_read                uint64 = 0x0000000F
+	Afc_operation_file_seek                uint64 = 0x00000011
+	Afc_operation_get_file_hash            uint64 = 0x0000001D
 	Afc_operation_remove_path_and_contents uint64 = 0x00000022
 )
 
@@ -34,6 +36,12 @@ const (
 	Afc_Mode_RDAPPEND uint64 = 0x00000006
 )
 
+const (
+	Afc_Seek_Set uint64 = 0
+	Afc_Seek_Cur uint64 = 1
+	Afc_Seek_End uint64 = 2
+)
+
 const (
 	Afc_Err_Success                = 0
 	Afc_Err_UnknownError           = 1
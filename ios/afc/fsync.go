@@ -8,8 +8,10 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/danielpaulus/go-ios/ios"
 	log "github.com/sirupsen/logrus"
@@ -40,6 +42,11 @@ func (s *statInfo) IsLink() bool {
 	return s.stIfmt == "S_IFLNK"
 }
 
+// ModTime returns the time the file was last modified
+func (s *statInfo) ModTime() time.Time {
+	return time.Unix(0, s.stMtime)
+}
+
 func New(device ios.DeviceEntry) (*Connection, error) {
 	deviceConn, err := ios.ConnectToService(device, serviceName)
 	if err != nil {
@@ -186,6 +193,30 @@ func (conn *Connection) Stat(path string) (*statInfo, error) {
 	return &si, nil
 }
 
+// maxLinkDepth is the number of symbolic links statLinkTarget follows before it gives up, like ELOOP on darwin
+const maxLinkDepth = 32
+
+// statLinkTarget returns the path and the statInfo of the file filePath refers to. Symbolic links are followed,
+// relative link targets are resolved against the directory of the link. For other files filePath and its own
+// statInfo are returned.
+func (conn *Connection) statLinkTarget(filePath string) (string, *statInfo, error) {
+	for i := 0; i <= maxLinkDepth; i++ {
+		fileInfo, err := conn.Stat(filePath)
+		if err != nil {
+			return "", nil, err
+		}
+		if !fileInfo.IsLink() {
+			return filePath, fileInfo, nil
+		}
+		target := fileInfo.stLinktarget
+		if !path.IsAbs(target) {
+			target = path.Join(path.Dir(filePath), target)
+		}
+		filePath = target
+	}
+	return "", nil, fmt.Errorf("stat: too many levels of symbolic links at %s", filePath)
+}
+
 func (conn *Connection) listDir(path string) ([]string, error) {
 	headerPayload := []byte(path)
 	headerLength := uint64(len(headerPayload))
@@ -414,6 +445,123 @@ func (conn *Connection) PullSingleFile(srcPath, dstPath string) error {
 	return nil
 }
 
+// ReadFile returns the complete contents of the file at path
+func (conn *Connection) ReadFile(path string) ([]byte, error) {
+	path, fileInfo, err := conn.statLinkTarget(path)
+	if err != nil {
+		return nil, err
+	}
+	fd, err := conn.OpenFile(path, Afc_Mode_RDONLY)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.CloseFile(fd)
+	return conn.readFull(fd, fileInfo.stSize)
+}
+
+// SeekFile moves the position of the open file fd to offset, relative to whence (Afc_Seek_Set, Afc_Seek_Cur or Afc_Seek_End)
+func (conn *Connection) SeekFile(fd uint64, offset int64, whence uint64) error {
+	headerPayload := make([]byte, 24)
+	binary.LittleEndian.PutUint64(headerPayload, fd)
+	binary.LittleEndian.PutUint64(headerPayload[8:], whence)
+	binary.LittleEndian.PutUint64(headerPayload[16:], uint64(offset))
+	thisLength := Afc_header_size + 24
+	header := AfcPacketHeader{Magic: Afc_magic, Packet_num: conn.packageNumber, Operation: Afc_operation_file_seek, This_length: thisLength, Entire_length: thisLength}
+	conn.packageNumber++
+	packet := AfcPacket{Header: header, HeaderPayload: headerPayload, Payload: make([]byte, 0)}
+	response, err := conn.sendAfcPacketAndAwaitResponse(packet)
+	if err != nil {
+		return err
+	}
+	if err = conn.checkOperationStatus(response); err != nil {
+		return fmt.Errorf("seek file: unexpected afc status: %v", err)
+	}
+	return nil
+}
+
+// readFull reads exactly size bytes from the current position of the open file fd
+func (conn *Connection) readFull(fd uint64, size int64) ([]byte, error) {
+	data := make([]byte, 0, size)
+	for int64(len(data)) < size {
+		headerPayload := make([]byte, 16)
+		binary.LittleEndian.PutUint64(headerPayload, fd)
+		binary.LittleEndian.PutUint64(headerPayload[8:], uint64(size-int64(len(data))))
+		thisLength := Afc_header_size + 16
+		header := AfcPacketHeader{Magic: Afc_magic, Packet_num: conn.packageNumber, Operation: Afc_operation_file_read, This_length: thisLength, Entire_length: thisLength}
+		conn.packageNumber++
+		packet := AfcPacket{Header: header, HeaderPayload: headerPayload, Payload: make([]byte, 0)}
+		response, err := conn.sendAfcPacketAndAwaitResponse(packet)
+		if err != nil {
+			return nil, err
+		}
+		if err = conn.checkOperationStatus(response); err != nil {
+			return nil, fmt.Errorf("read file: unexpected afc status: %v", err)
+		}
+		if len(response.Payload) == 0 {
+			return nil, fmt.Errorf("read file: unexpected end of file after %d of %d bytes", len(data), size)
+		}
+		data = append(data, response.Payload...)
+	}
+	return data, nil
+}
+
+// Tail returns the last n lines of the file at path. The file is read backwards in chunks starting from its end,
+// so only the required part of the file is transferred. If the file has less than n lines, all lines are returned.
+func (conn *Connection) Tail(path string, n int) ([]string, error) {
+	if n <= 0 {
+		return []string{}, nil
+	}
+	path, fileInfo, err := conn.statLinkTarget(path)
+	if err != nil {
+		return nil, err
+	}
+	if fileInfo.IsDir() {
+		return nil, fmt.Errorf("tail: %s is a directory", path)
+	}
+	fd, err := conn.OpenFile(path, Afc_Mode_RDONLY)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.CloseFile(fd)
+
+	const chunkSize = 64 * 1024
+	offset := fileInfo.stSize
+	// chunks are collected from the end of the file backwards and joined once, so that
+	// reading many short lines does not copy the whole buffer for every chunk
+	var chunks [][]byte
+	newlines := 0
+	for offset > 0 && newlines < n {
+		readSize := min(int64(chunkSize), offset)
+		offset -= readSize
+		err = conn.SeekFile(fd, offset, Afc_Seek_Set)
+		if err != nil {
+			return nil, err
+		}
+		chunk, err := conn.readFull(fd, readSize)
+		if err != nil {
+			return nil, err
+		}
+		if len(chunks) == 0 && chunk[len(chunk)-1] == '\n' {
+			// the newline terminating the last line does not start another line
+			newlines--
+		}
+		newlines += bytes.Count(chunk, []byte("\n"))
+		chunks = append(chunks, chunk)
+	}
+	slices.Reverse(chunks)
+	content := bytes.Join(chunks, nil)
+
+	content = bytes.TrimSuffix(content, []byte("\n"))
+	if len(content) == 0 {
+		return []string{}, nil
+	}
+	lines := strings.Split(string(content), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines, nil
+}
+
 func (conn *Connection) Pull(srcPath, dstPath string) error {
 	fileInfo, err := conn.Stat(srcPath)
 	if err != nil {
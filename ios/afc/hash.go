@@ -0,0 +1,90 @@
+package afc
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// HashAlgorithm is the algorithm of the hashes returned by Hash. It is the algorithm the device uses for the
+// AFC file hash operation.
+const HashAlgorithm = "sha1"
+
+// hashReadSize is the size of the chunks a file is read in, if the device does not support the hash operation
+const hashReadSize = 64 * 1024
+
+// Hash returns the HashAlgorithm hash of the file at path. The hash is calculated by the device if its AFC service
+// supports the file hash operation. Otherwise the file is streamed from the device and hashed locally.
+func (conn *Connection) Hash(path string) ([]byte, error) {
+	pathBytes := append([]byte(path), 0)
+	thisLength := Afc_header_size + uint64(len(pathBytes))
+	header := AfcPacketHeader{Magic: Afc_magic, Packet_num: conn.packageNumber, Operation: Afc_operation_get_file_hash, This_length: thisLength, Entire_length: thisLength}
+	conn.packageNumber++
+	packet := AfcPacket{Header: header, HeaderPayload: pathBytes, Payload: make([]byte, 0)}
+	response, err := conn.sendAfcPacketAndAwaitResponse(packet)
+	if err != nil {
+		return nil, err
+	}
+	if response.Header.Operation == Afc_operation_status {
+		errorCode := binary.LittleEndian.Uint64(response.HeaderPayload)
+		if errorCode == Afc_Err_OperationNotSupported || errorCode == Afc_Err_UnknownPacketType {
+			return conn.hashByStreaming(path)
+		}
+		if err = conn.checkOperationStatus(response); err != nil {
+			return nil, fmt.Errorf("hash file: unexpected afc status: %v", err)
+		}
+	}
+	if len(response.Payload) != sha1.Size {
+		return nil, fmt.Errorf("hash file: unexpected hash length %d", len(response.Payload))
+	}
+	return response.Payload, nil
+}
+
+// hashByStreaming reads the file at path in chunks and hashes it locally
+func (conn *Connection) hashByStreaming(path string) ([]byte, error) {
+	path, fileInfo, err := conn.statLinkTarget(path)
+	if err != nil {
+		return nil, err
+	}
+	fd, err := conn.OpenFile(path, Afc_Mode_RDONLY)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.CloseFile(fd)
+
+	hash := sha1.New()
+	for left := fileInfo.stSize; left > 0; {
+		chunk, err := conn.readFull(fd, min(left, hashReadSize))
+		if err != nil {
+			return nil, err
+		}
+		hash.Write(chunk)
+		left -= int64(len(chunk))
+	}
+	return hash.Sum(nil), nil
+}
+
+// Verify returns an error if the local file at localPath differs from the file at devicePath, f.ex. to check
+// the integrity of Push or Pull
+func (conn *Connection) Verify(localPath string, devicePath string) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	localHash := sha1.New()
+	if _, err := io.Copy(localHash, f); err != nil {
+		return err
+	}
+	deviceHash, err := conn.Hash(devicePath)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(localHash.Sum(nil), deviceHash) {
+		return fmt.Errorf("verify: %s differs from %s on the device", localPath, devicePath)
+	}
+	return nil
+}
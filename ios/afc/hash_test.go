@@ -0,0 +1,60 @@
+package afc
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHash(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 10000)
+	expected := sha1.Sum(content)
+
+	t.Run("streams the file if the device does not support hashing", func(t *testing.T) {
+		server := &mockAfcServer{path: "/Downloads/test.bin", content: content, maxReadSize: 4096}
+		conn := newMockConnection(t, server)
+
+		hash, err := conn.Hash("/Downloads/test.bin")
+
+		assert.NoError(t, err)
+		assert.Equal(t, expected[:], hash)
+		assert.Greater(t, server.reads, 1, "the file should be read in chunks")
+	})
+
+	t.Run("streams the target of a symbolic link", func(t *testing.T) {
+		server := &mockAfcServer{path: "/Downloads/test.bin", content: content, maxReadSize: 4096, links: map[string]string{"/Downloads/latest.bin": "test.bin"}}
+		conn := newMockConnection(t, server)
+
+		hash, err := conn.Hash("/Downloads/latest.bin")
+
+		assert.NoError(t, err)
+		assert.Equal(t, expected[:], hash)
+	})
+
+	t.Run("uses the hash of the device if supported", func(t *testing.T) {
+		server := &mockAfcServer{path: "/Downloads/test.bin", content: content, maxReadSize: 4096, fileHash: expected[:]}
+		conn := newMockConnection(t, server)
+
+		hash, err := conn.Hash("/Downloads/test.bin")
+
+		assert.NoError(t, err)
+		assert.Equal(t, expected[:], hash)
+		assert.Equal(t, 0, server.reads, "the file should not be transferred")
+	})
+}
+
+func TestVerify(t *testing.T) {
+	content := []byte("pushed file content")
+	localPath := filepath.Join(t.TempDir(), "local.txt")
+	assert.NoError(t, os.WriteFile(localPath, content, 0o644))
+
+	server := &mockAfcServer{path: "/Downloads/remote.txt", content: content, maxReadSize: 4096}
+	assert.NoError(t, newMockConnection(t, server).Verify(localPath, "/Downloads/remote.txt"))
+
+	server = &mockAfcServer{path: "/Downloads/remote.txt", content: []byte("truncated"), maxReadSize: 4096}
+	assert.Error(t, newMockConnection(t, server).Verify(localPath, "/Downloads/remote.txt"))
+}
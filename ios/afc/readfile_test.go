@@ -0,0 +1,52 @@
+package afc
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadFile(t *testing.T) {
+	content := bytes.Repeat([]byte("summary "), 2000)
+
+	t.Run("reads the whole file", func(t *testing.T) {
+		server := &mockAfcServer{path: "/Documents/Results.xcresult/Info.plist", content: content, maxReadSize: 4096}
+		conn := newMockConnection(t, server)
+
+		result, err := conn.ReadFile("/Documents/Results.xcresult/Info.plist")
+
+		assert.NoError(t, err)
+		assert.Equal(t, content, result)
+	})
+
+	t.Run("reads the target of a symbolic link", func(t *testing.T) {
+		links := map[string]string{"/Documents/Results.xcresult/Info.plist": "/Documents/Results.xcresult/Info-1.plist"}
+		server := &mockAfcServer{path: "/Documents/Results.xcresult/Info-1.plist", content: content, maxReadSize: 4096, links: links}
+		conn := newMockConnection(t, server)
+
+		result, err := conn.ReadFile("/Documents/Results.xcresult/Info.plist")
+
+		assert.NoError(t, err)
+		assert.Equal(t, content, result)
+	})
+
+	t.Run("resolves relative link targets against the directory of the link", func(t *testing.T) {
+		server := &mockAfcServer{path: "/Documents/Results/Info.plist", content: content, maxReadSize: 4096, links: map[string]string{"/Documents/Results/Current.plist": "Info.plist"}}
+		conn := newMockConnection(t, server)
+
+		result, err := conn.ReadFile("/Documents/Results/Current.plist")
+
+		assert.NoError(t, err)
+		assert.Equal(t, content, result)
+	})
+
+	t.Run("fails for links to themselves", func(t *testing.T) {
+		server := &mockAfcServer{path: "/Documents/other", maxReadSize: 4096, links: map[string]string{"/Documents/loop": "loop"}}
+		conn := newMockConnection(t, server)
+
+		_, err := conn.ReadFile("/Documents/loop")
+
+		assert.Error(t, err)
+	})
+}
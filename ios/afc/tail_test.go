@@ -0,0 +1,182 @@
+package afc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/danielpaulus/go-ios/ios"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockAfcServer serves a single in-memory file over AFC. It supports the operations needed for reading a file.
+// Reads return at most maxReadSize bytes to exercise short reads. The file hash operation is answered with
+// fileHash, or reported as not supported if fileHash is nil. links maps the paths of symbolic links to their targets.
+type mockAfcServer struct {
+	path        string
+	content     []byte
+	links       map[string]string
+	maxReadSize int
+	fileHash    []byte
+	position    int64
+	seeks       int
+	reads       int
+}
+
+func (s *mockAfcServer) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		packet, err := Decode(conn)
+		if err != nil {
+			return
+		}
+		var response AfcPacket
+		switch packet.Header.Operation {
+		case Afc_operation_file_info:
+			if target, ok := s.links[string(packet.HeaderPayload)]; ok {
+				info := fmt.Sprintf("st_size\x00%d\x00st_ifmt\x00S_IFLNK\x00st_linktarget\x00%s\x00", len(target), target)
+				response = dataPacket(Afc_operation_data, nil, []byte(info))
+				break
+			}
+			if string(packet.HeaderPayload) != s.path {
+				response = statusPacket(Afc_Err_ObjectNotFound)
+				break
+			}
+			info := fmt.Sprintf("st_size\x00%d\x00st_ifmt\x00S_IFREG\x00", len(s.content))
+			response = dataPacket(Afc_operation_data, nil, []byte(info))
+		case Afc_operation_file_open:
+			if strings.TrimSuffix(string(packet.HeaderPayload[8:]), "\x00") != s.path {
+				response = statusPacket(Afc_Err_ObjectNotFound)
+				break
+			}
+			fd := make([]byte, 8)
+			binary.LittleEndian.PutUint64(fd, 1)
+			response = dataPacket(Afc_operation_file_open_result, fd, nil)
+		case Afc_operation_file_seek:
+			s.seeks++
+			s.position = int64(binary.LittleEndian.Uint64(packet.HeaderPayload[16:]))
+			response = statusPacket(Afc_Err_Success)
+		case Afc_operation_file_read:
+			s.reads++
+			size := int64(binary.LittleEndian.Uint64(packet.HeaderPayload[8:]))
+			size = min(size, int64(s.maxReadSize), int64(len(s.content))-s.position)
+			data := s.content[s.position : s.position+size]
+			s.position += size
+			response = dataPacket(Afc_operation_data, nil, data)
+		case Afc_operation_get_file_hash:
+			if s.fileHash == nil {
+				response = statusPacket(Afc_Err_OperationNotSupported)
+				break
+			}
+			response = dataPacket(Afc_operation_data, nil, s.fileHash)
+		default:
+			response = statusPacket(Afc_Err_Success)
+		}
+		if err := Encode(response, conn); err != nil {
+			return
+		}
+	}
+}
+
+func statusPacket(status uint64) AfcPacket {
+	headerPayload := make([]byte, 8)
+	binary.LittleEndian.PutUint64(headerPayload, status)
+	return dataPacket(Afc_operation_status, headerPayload, nil)
+}
+
+func dataPacket(operation uint64, headerPayload []byte, payload []byte) AfcPacket {
+	thisLength := Afc_header_size + uint64(len(headerPayload))
+	header := AfcPacketHeader{Magic: Afc_magic, Operation: operation, This_length: thisLength, Entire_length: thisLength + uint64(len(payload))}
+	return AfcPacket{Header: header, HeaderPayload: headerPayload, Payload: payload}
+}
+
+func newMockConnection(t *testing.T, server *mockAfcServer) *Connection {
+	// net.Pipe can not be used here, it blocks on the empty writes Encode does for packets without payload
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	client, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	device, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.serve(device)
+	t.Cleanup(func() { client.Close() })
+	return NewFromConn(ios.NewDeviceConnectionWithRWC(client))
+}
+
+func TestTail(t *testing.T) {
+	var lines []string
+	for i := 0; i < 20000; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	content := strings.Join(lines, "\n") + "\n"
+
+	t.Run("returns the last lines of a large file", func(t *testing.T) {
+		server := &mockAfcServer{path: "/var/log/test.log", content: []byte(content), maxReadSize: 4096}
+		conn := newMockConnection(t, server)
+
+		result, err := conn.Tail("/var/log/test.log", 3)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"line 19997", "line 19998", "line 19999"}, result)
+		assert.Equal(t, 1, server.seeks, "only the last chunk of the file should be read")
+	})
+
+	t.Run("reads multiple chunks if necessary", func(t *testing.T) {
+		server := &mockAfcServer{path: "/var/log/test.log", content: []byte(content), maxReadSize: 4096}
+		conn := newMockConnection(t, server)
+
+		result, err := conn.Tail("/var/log/test.log", 15000)
+
+		assert.NoError(t, err)
+		assert.Equal(t, lines[5000:], result)
+		assert.Greater(t, server.seeks, 1)
+	})
+
+	t.Run("returns all lines of a file smaller than the requested range", func(t *testing.T) {
+		server := &mockAfcServer{path: "/small.log", content: []byte("first\nsecond"), maxReadSize: 4096}
+		conn := newMockConnection(t, server)
+
+		result, err := conn.Tail("/small.log", 10)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"first", "second"}, result)
+	})
+
+	t.Run("returns no lines for an empty file", func(t *testing.T) {
+		server := &mockAfcServer{path: "/empty.log", maxReadSize: 4096}
+		conn := newMockConnection(t, server)
+
+		result, err := conn.Tail("/empty.log", 10)
+
+		assert.NoError(t, err)
+		assert.Empty(t, result)
+	})
+
+	t.Run("follows relative symbolic links", func(t *testing.T) {
+		server := &mockAfcServer{path: "/var/log/test.log", content: []byte(content), maxReadSize: 4096, links: map[string]string{"/var/log/current.log": "test.log"}}
+		conn := newMockConnection(t, server)
+
+		result, err := conn.Tail("/var/log/current.log", 2)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"line 19998", "line 19999"}, result)
+	})
+
+	t.Run("fails for a missing file", func(t *testing.T) {
+		server := &mockAfcServer{path: "/small.log", maxReadSize: 4096}
+		conn := newMockConnection(t, server)
+
+		_, err := conn.Tail("/missing.log", 10)
+
+		assert.Error(t, err)
+	})
+}
@@ -48,6 +48,9 @@ func New(deviceEntry ios.DeviceEntry) (*Connection, error) {
 type LaunchedAppWithStdIo struct {
 	stdIoConnection openstdio.Connection
 	Pid             int
+	// Environment contains the environment variables the app was launched with as echoed by the device in the
+	// launch response. It is nil if the response does not contain them.
+	Environment map[string]interface{}
 }
 
 // Read reads from the stdio socket of the launched app
@@ -75,11 +78,11 @@ type Process struct {
 // LaunchApp launches an app on the device with the given bundleId and arguments for iOS17+.
 // On a successful launch it returns the PID of the launched process.
 func (c *Connection) LaunchApp(bundleId string, args []interface{}, env map[string]interface{}, options map[string]interface{}, terminateExisting bool) (int, error) {
-	pid, err := c.launchApp(bundleId, args, env, options, terminateExisting, map[string]any{})
+	launched, err := c.launchApp(bundleId, args, env, options, terminateExisting, map[string]any{})
 	if err != nil {
 		return 0, fmt.Errorf("LaunchApp: failed to launch app: %w", err)
 	}
-	return pid, nil
+	return launched.pid, nil
 }
 
 // LaunchAppWithStdIo launches an app and connects to the stdio-socket
@@ -97,31 +100,42 @@ func (c *Connection) LaunchAppWithStdIo(bundleId string, args []interface{}, env
 		"standardError":  stdio.ID,
 	}
 
-	pid, err := c.launchApp(bundleId, args, env, options, terminateExisting, stdIoConfig)
+	launched, err := c.launchApp(bundleId, args, env, options, terminateExisting, stdIoConfig)
 	if err != nil {
 		return LaunchedAppWithStdIo{}, fmt.Errorf("LaunchAppWithStdIo: failed to launch app: %w", err)
 	}
 	return LaunchedAppWithStdIo{
 		stdIoConnection: stdio,
-		Pid:             pid,
+		Pid:             launched.pid,
+		Environment:     launched.environment,
 	}, nil
 }
 
-func (c *Connection) launchApp(bundleId string, args []interface{}, env map[string]interface{}, options map[string]interface{}, terminateExisting bool, stdio map[string]any) (int, error) {
+// launchedApp is the process started by a launch request
+type launchedApp struct {
+	pid         int
+	environment map[string]interface{}
+}
+
+func (c *Connection) launchApp(bundleId string, args []interface{}, env map[string]interface{}, options map[string]interface{}, terminateExisting bool, stdio map[string]any) (launchedApp, error) {
 	msg := buildAppLaunchPayload(c.deviceId, bundleId, args, env, options, terminateExisting, stdio)
 	err := c.conn.Send(msg, xpc.HeartbeatRequestFlag)
 	if err != nil {
-		return 0, fmt.Errorf("launchApp: failed to send launch-app request: %w", err)
+		return launchedApp{}, fmt.Errorf("launchApp: failed to send launch-app request: %w", err)
 	}
 	m, err := c.conn.ReceiveOnServerClientStream()
 	if err != nil {
-		return 0, fmt.Errorf("launchApp: failed to read response: %w", err)
+		return launchedApp{}, fmt.Errorf("launchApp: failed to read response: %w", err)
 	}
-	pid, err := pidFromResponse(m)
+	return launchedAppFromResponse(m)
+}
+
+func launchedAppFromResponse(response map[string]interface{}) (launchedApp, error) {
+	pid, err := pidFromResponse(response)
 	if err != nil {
-		return 0, fmt.Errorf("launchApp: failed to get PID: %w", err)
+		return launchedApp{}, fmt.Errorf("launchApp: failed to get PID: %w", err)
 	}
-	return int(pid), nil
+	return launchedApp{pid: int(pid), environment: environmentFromResponse(response)}, nil
 }
 
 // Close closes the connection to the appservice
@@ -299,6 +313,23 @@ func pidFromResponse(response map[string]interface{}) (int64, error) {
 	return pid, nil
 }
 
+// environmentFromResponse returns the environment variables the launch response echoes in its launch options
+func environmentFromResponse(response map[string]interface{}) map[string]interface{} {
+	output, ok := response["CoreDevice.output"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	options, ok := output["options"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	env, ok := options["environmentVariables"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	return env
+}
+
 func getError(response map[string]interface{}) error {
 	if e, ok := response["CoreDevice.error"].(map[string]interface{}); ok {
 		return fmt.Errorf("device returned error: %+v", e)
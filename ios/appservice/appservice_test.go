@@ -0,0 +1,58 @@
+package appservice
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLaunchedAppFromResponse(t *testing.T) {
+	response := map[string]interface{}{
+		"CoreDevice.CoreDeviceDDIProtocolVersion": int64(0),
+		"CoreDevice.action":                       map[string]interface{}{},
+		"CoreDevice.output": map[string]interface{}{
+			"processToken": map[string]interface{}{
+				"processIdentifier": int64(1234),
+			},
+			"options": map[string]interface{}{
+				"arguments": []interface{}{"-NSTreatUnknownArgumentsAsOpen", "NO"},
+				"environmentVariables": map[string]interface{}{
+					"XCTestSessionIdentifier": "5E6D3C48-0000-0000-0000-000000000000",
+					"TERM":                    "xterm-256color",
+				},
+				"terminateExisting": true,
+			},
+		},
+	}
+
+	launched, err := launchedAppFromResponse(response)
+
+	require.NoError(t, err)
+	assert.Equal(t, 1234, launched.pid)
+	assert.Equal(t, map[string]interface{}{
+		"XCTestSessionIdentifier": "5E6D3C48-0000-0000-0000-000000000000",
+		"TERM":                    "xterm-256color",
+	}, launched.environment)
+}
+
+func TestLaunchedAppFromResponseWithoutEnvironment(t *testing.T) {
+	response := map[string]interface{}{
+		"CoreDevice.output": map[string]interface{}{
+			"processToken": map[string]interface{}{
+				"processIdentifier": int64(1234),
+			},
+		},
+	}
+
+	launched, err := launchedAppFromResponse(response)
+
+	require.NoError(t, err)
+	assert.Equal(t, 1234, launched.pid)
+	assert.Nil(t, launched.environment)
+}
+
+func TestLaunchedAppFromResponseWithoutPid(t *testing.T) {
+	_, err := launchedAppFromResponse(map[string]interface{}{"CoreDevice.output": map[string]interface{}{}})
+	assert.Error(t, err)
+}
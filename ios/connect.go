@@ -43,7 +43,11 @@ func newConnectMessage(deviceID int, portNumber uint16) connectMessage {
 func (muxConn *UsbMuxConnection) Connect(deviceID int, port uint16) error {
 	msg := newConnectMessage(deviceID, Ntohs(port))
 	muxConn.Send(msg)
-	resp, err := muxConn.ReadMessage()
+	var resp UsbMuxMessage
+	err := withConnectTimeout(muxConn.deviceConn, func() (err error) {
+		resp, err = muxConn.ReadMessage()
+		return err
+	})
 	if err != nil {
 		return err
 	}
@@ -75,7 +79,11 @@ func (muxConn *UsbMuxConnection) ConnectLockdown(deviceID int) (*LockDownConnect
 	if err != nil {
 		return &LockDownConnection{}, err
 	}
-	resp, err := muxConn.ReadMessage()
+	var resp UsbMuxMessage
+	err = withConnectTimeout(muxConn.deviceConn, func() (err error) {
+		resp, err = muxConn.ReadMessage()
+		return err
+	})
 	if err != nil {
 		return &LockDownConnection{}, err
 	}
@@ -88,6 +96,9 @@ func (muxConn *UsbMuxConnection) ConnectLockdown(deviceID int) (*LockDownConnect
 }
 
 func ConnectToService(device DeviceEntry, serviceName string) (DeviceConnectionInterface, error) {
+	if device.ConnectionPool != nil {
+		return device.ConnectionPool.ConnectToService(device, serviceName)
+	}
 	startServiceResponse, err := StartService(device, serviceName)
 	if err != nil {
 		return nil, err
@@ -187,19 +198,15 @@ func (muxConn *UsbMuxConnection) connectWithStartServiceResponse(deviceID int, s
 		return err
 	}
 
-	var sslerr error
-	if startServiceResponse.EnableServiceSSL {
+	if !startServiceResponse.EnableServiceSSL {
+		return nil
+	}
+	return withConnectTimeout(muxConn.deviceConn, func() error {
 		if _, ok := serviceConfigurations[startServiceResponse.Service]; ok {
-			sslerr = muxConn.deviceConn.EnableSessionSslHandshakeOnly(pairRecord)
-		} else {
-			sslerr = muxConn.deviceConn.EnableSessionSsl(pairRecord)
-		}
-		if sslerr != nil {
-			return sslerr
+			return muxConn.deviceConn.EnableSessionSslHandshakeOnly(pairRecord)
 		}
-	}
-
-	return nil
+		return muxConn.deviceConn.EnableSessionSsl(pairRecord)
+	})
 }
 
 func ConnectLockdownWithSession(device DeviceEntry) (*LockDownConnection, error) {
@@ -214,13 +221,23 @@ func ConnectLockdownWithSession(device DeviceEntry) (*LockDownConnection, error)
 		return nil, fmt.Errorf("could not retrieve PairRecord with error: %v", err)
 	}
 
-	lockdownConnection, err := muxConnection.ConnectLockdown(device.DeviceID)
+	return muxConnection.connectLockdownSession(device.DeviceID, pairRecord)
+}
+
+// connectLockdownSession connects to lockdown and starts a session with pairRecord. Like ConnectLockdown, it uses
+// the network connection of muxConn.
+func (muxConn *UsbMuxConnection) connectLockdownSession(deviceID int, pairRecord PairRecord) (*LockDownConnection, error) {
+	lockdownConnection, err := muxConn.ConnectLockdown(deviceID)
 	if err != nil {
 		return nil, fmt.Errorf("Lockdown connection failed with: %v", err)
 	}
-	resp, err := lockdownConnection.StartSession(pairRecord)
+	var resp StartSessionResponse
+	err = withConnectTimeout(muxConn.deviceConn, func() (err error) {
+		resp, err = lockdownConnection.StartSession(pairRecord)
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("StartSession failed: %+v error: %v", resp, err)
+		return nil, fmt.Errorf("StartSession failed: %+v error: %w", resp, err)
 	}
 	return lockdownConnection, nil
 }
@@ -0,0 +1,324 @@
+package ios
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// lockdownSession is the part of a LockDownConnection with an active session that the ConnectionPool uses
+type lockdownSession interface {
+	StartService(serviceName string) (StartServiceResponse, error)
+	Close()
+}
+
+// ConnectionPool keeps the pair record, a lockdown session and the RSD services of each device warm, so that a long
+// running process like an API server does not need a usbmuxd connection, a pair record lookup and a TLS handshake
+// every time it starts a service on a device. Services of a device are started after another on its lockdown
+// session, the service connections themselves are not pooled. Lockdown sessions that were not used for the idle
+// timeout are closed.
+// iOS 17+ devices are reached through the tunnel of `ios tunnel start` or the go-ios agent, which keeps a tunnel
+// running for each connected device. For those devices the pool caches the RSD handshake through the tunnel, see Rsd.
+// Set it as DeviceEntry.ConnectionPool to make ConnectToService and StartService use the pool for that device. It is
+// safe for concurrent use.
+type ConnectionPool struct {
+	idleTimeout time.Duration
+	now         func() time.Time
+
+	readPairRecord  func(udid string) (PairRecord, error)
+	connectLockdown func(device DeviceEntry, pairRecord PairRecord) (lockdownSession, error)
+	rsdHandshake    func(device DeviceEntry, address string, port int) (RsdPortProvider, error)
+
+	mu      sync.Mutex
+	devices map[string]*pooledDevice
+	stop    chan struct{}
+	closed  bool
+}
+
+// pooledDevice holds the warm connections of a single device, mu serializes the requests on the lockdown session
+type pooledDevice struct {
+	mu         sync.Mutex
+	deviceID   int
+	pairRecord *PairRecord
+	lockdown   lockdownSession
+	lastUsed   time.Time
+
+	rsdAddress string
+	rsdPort    int
+	rsd        RsdPortProvider
+}
+
+// NewConnectionPool creates a ConnectionPool that closes lockdown sessions which were not used for idleTimeout
+func NewConnectionPool(idleTimeout time.Duration) *ConnectionPool {
+	pool := newConnectionPool(idleTimeout)
+	go pool.closeIdleSessions()
+	return pool
+}
+
+func newConnectionPool(idleTimeout time.Duration) *ConnectionPool {
+	return &ConnectionPool{
+		idleTimeout:    idleTimeout,
+		now:            time.Now,
+		readPairRecord: ReadPairRecord,
+		connectLockdown: func(device DeviceEntry, pairRecord PairRecord) (lockdownSession, error) {
+			muxConnection, err := NewUsbMuxConnectionSimple()
+			if err != nil {
+				return nil, fmt.Errorf("USBMuxConnection failed with: %v", err)
+			}
+			defer muxConnection.ReleaseDeviceConnection()
+			return muxConnection.connectLockdownSession(device.DeviceID, pairRecord)
+		},
+		rsdHandshake: func(device DeviceEntry, address string, port int) (RsdPortProvider, error) {
+			rsdService, err := NewWithAddrPortDevice(address, port, device)
+			if err != nil {
+				return nil, err
+			}
+			defer rsdService.Close()
+			return rsdService.Handshake()
+		},
+		devices: map[string]*pooledDevice{},
+		stop:    make(chan struct{}),
+	}
+}
+
+// device returns the pooled connections of device, creating them if needed
+func (p *ConnectionPool) device(device DeviceEntry) (*pooledDevice, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, errors.New("ConnectionPool: pool is closed")
+	}
+	udid := device.Properties.SerialNumber
+	pooled, ok := p.devices[udid]
+	if !ok || pooled.deviceID != device.DeviceID {
+		if ok {
+			// the device was reconnected and got a new id, its session belongs to the old connection
+			go pooled.close()
+		}
+		pooled = &pooledDevice{deviceID: device.DeviceID}
+		p.devices[udid] = pooled
+	}
+	return pooled, nil
+}
+
+// PairRecord returns the pair record of device, it is read from usbmuxd only once
+func (p *ConnectionPool) PairRecord(device DeviceEntry) (PairRecord, error) {
+	pooled, err := p.device(device)
+	if err != nil {
+		return PairRecord{}, err
+	}
+	pooled.mu.Lock()
+	defer pooled.mu.Unlock()
+	return p.pairRecord(device, pooled)
+}
+
+// pairRecord returns the cached pair record of pooled, the caller must hold pooled.mu
+func (p *ConnectionPool) pairRecord(device DeviceEntry, pooled *pooledDevice) (PairRecord, error) {
+	if pooled.pairRecord == nil {
+		pairRecord, err := p.readPairRecord(device.Properties.SerialNumber)
+		if err != nil {
+			return PairRecord{}, fmt.Errorf("could not retrieve PairRecord with error: %v", err)
+		}
+		pooled.pairRecord = &pairRecord
+	}
+	return *pooled.pairRecord, nil
+}
+
+// StartService starts serviceName on the lockdown session of device. A broken session, f.ex. because the device
+// closed it, is replaced by a new one once.
+func (p *ConnectionPool) StartService(device DeviceEntry, serviceName string) (StartServiceResponse, error) {
+	if err := serviceAvailable(device, serviceName); err != nil {
+		return StartServiceResponse{}, err
+	}
+	pooled, err := p.device(device)
+	if err != nil {
+		return StartServiceResponse{}, err
+	}
+	pooled.mu.Lock()
+	defer pooled.mu.Unlock()
+	pooled.lastUsed = p.now()
+
+	reused := pooled.lockdown != nil
+	response, err := p.startService(device, pooled, serviceName)
+	if err != nil && reused {
+		log.WithFields(log.Fields{"error": err, "udid": device.Properties.SerialNumber}).Debug("pooled lockdown session failed, starting a new one")
+		response, err = p.startService(device, pooled, serviceName)
+	}
+	return response, err
+}
+
+// startService starts serviceName on the session of pooled, the session is closed if that fails. The caller must
+// hold pooled.mu.
+func (p *ConnectionPool) startService(device DeviceEntry, pooled *pooledDevice, serviceName string) (StartServiceResponse, error) {
+	if pooled.lockdown == nil {
+		pairRecord, err := p.pairRecord(device, pooled)
+		if err != nil {
+			return StartServiceResponse{}, err
+		}
+		lockdown, err := p.connectLockdown(device, pairRecord)
+		if err != nil {
+			return StartServiceResponse{}, err
+		}
+		pooled.lockdown = lockdown
+	}
+	response, err := startServiceOn(device, pooled.lockdown, serviceName)
+	if err != nil {
+		pooled.lockdown.Close()
+		pooled.lockdown = nil
+		return StartServiceResponse{}, err
+	}
+	return response, nil
+}
+
+// ConnectToService works like ConnectToService, but starts the service on the pooled lockdown session of device
+func (p *ConnectionPool) ConnectToService(device DeviceEntry, serviceName string) (DeviceConnectionInterface, error) {
+	startServiceResponse, err := p.StartService(device, serviceName)
+	if err != nil {
+		return nil, err
+	}
+	pairRecord, err := p.PairRecord(device)
+	if err != nil {
+		return nil, err
+	}
+	muxConn, err := NewUsbMuxConnectionSimple()
+	if err != nil {
+		return nil, fmt.Errorf("Could not connect to usbmuxd socket, is it running? %w", err)
+	}
+	err = muxConn.connectWithStartServiceResponse(device.DeviceID, startServiceResponse, pairRecord)
+	if err != nil {
+		muxConn.Close()
+		return nil, err
+	}
+	return muxConn.ReleaseDeviceConnection(), nil
+}
+
+// Rsd returns the RSD services of an iOS 17+ device reachable through the tunnel at address and port. The RSD
+// handshake is only done again if the tunnel changed.
+func (p *ConnectionPool) Rsd(device DeviceEntry, address string, port int) (RsdPortProvider, error) {
+	pooled, err := p.device(device)
+	if err != nil {
+		return nil, err
+	}
+	pooled.mu.Lock()
+	defer pooled.mu.Unlock()
+	if pooled.rsd == nil || pooled.rsdAddress != address || pooled.rsdPort != port {
+		rsd, err := p.rsdHandshake(device, address, port)
+		if err != nil {
+			return nil, err
+		}
+		pooled.rsd, pooled.rsdAddress, pooled.rsdPort = rsd, address, port
+	}
+	return pooled.rsd, nil
+}
+
+// Evict closes the pooled connections of the device with udid and forgets its pair record
+func (p *ConnectionPool) Evict(udid string) {
+	p.mu.Lock()
+	pooled, ok := p.devices[udid]
+	delete(p.devices, udid)
+	p.mu.Unlock()
+	if ok {
+		pooled.close()
+	}
+}
+
+// EvictDetachedDevices listens for devices being detached from the host and evicts them until ctx is done
+func (p *ConnectionPool) EvictDetachedDevices(ctx context.Context) error {
+	receive, closeListener, err := Listen()
+	if err != nil {
+		return fmt.Errorf("EvictDetachedDevices: %w", err)
+	}
+	go func() {
+		<-ctx.Done()
+		closeListener()
+	}()
+	for {
+		message, err := receive()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("EvictDetachedDevices: %w", err)
+		}
+		if message.MessageType == "Detached" {
+			p.evictDeviceID(message.DeviceID)
+		}
+	}
+}
+
+// evictDeviceID evicts the device with the usbmuxd id deviceID, detach messages do not contain the udid
+func (p *ConnectionPool) evictDeviceID(deviceID int) {
+	p.mu.Lock()
+	var evicted []*pooledDevice
+	for udid, pooled := range p.devices {
+		if pooled.deviceID == deviceID {
+			evicted = append(evicted, pooled)
+			delete(p.devices, udid)
+		}
+	}
+	p.mu.Unlock()
+	for _, pooled := range evicted {
+		pooled.close()
+	}
+}
+
+// Close closes all pooled connections, the pool cannot be used anymore afterwards
+func (p *ConnectionPool) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.stop)
+	devices := p.devices
+	p.devices = map[string]*pooledDevice{}
+	p.mu.Unlock()
+	for _, pooled := range devices {
+		pooled.close()
+	}
+}
+
+func (p *ConnectionPool) closeIdleSessions() {
+	ticker := time.NewTicker(p.idleTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.closeSessionsIdleSince(p.now().Add(-p.idleTimeout))
+		}
+	}
+}
+
+// closeSessionsIdleSince closes the lockdown sessions that were last used before t, the pair records are kept
+func (p *ConnectionPool) closeSessionsIdleSince(t time.Time) {
+	p.mu.Lock()
+	devices := make([]*pooledDevice, 0, len(p.devices))
+	for _, pooled := range p.devices {
+		devices = append(devices, pooled)
+	}
+	p.mu.Unlock()
+	for _, pooled := range devices {
+		pooled.mu.Lock()
+		if pooled.lockdown != nil && pooled.lastUsed.Before(t) {
+			pooled.lockdown.Close()
+			pooled.lockdown = nil
+		}
+		pooled.mu.Unlock()
+	}
+}
+
+func (d *pooledDevice) close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.lockdown != nil {
+		d.lockdown.Close()
+		d.lockdown = nil
+	}
+}
@@ -0,0 +1,151 @@
+package ios
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeLockdownSession counts the started services and fails once broken is set
+type fakeLockdownSession struct {
+	started int
+	broken  bool
+	closed  bool
+}
+
+func (f *fakeLockdownSession) StartService(serviceName string) (StartServiceResponse, error) {
+	if f.broken {
+		return StartServiceResponse{}, errors.New("connection reset by peer")
+	}
+	f.started++
+	return StartServiceResponse{Service: serviceName, Port: 49152}, nil
+}
+
+func (f *fakeLockdownSession) Close() {
+	f.closed = true
+}
+
+// fakePool returns a pool whose lockdown sessions and pair record reads are recorded in sessions and pairRecordReads
+func fakePool(sessions *[]*fakeLockdownSession, pairRecordReads *int) *ConnectionPool {
+	pool := newConnectionPool(time.Minute)
+	pool.readPairRecord = func(udid string) (PairRecord, error) {
+		*pairRecordReads++
+		return PairRecord{HostID: "host"}, nil
+	}
+	pool.connectLockdown = func(device DeviceEntry, pairRecord PairRecord) (lockdownSession, error) {
+		session := &fakeLockdownSession{}
+		*sessions = append(*sessions, session)
+		return session, nil
+	}
+	return pool
+}
+
+var pooledTestDevice = DeviceEntry{DeviceID: 3, Properties: DeviceProperties{SerialNumber: "00008110-0001"}}
+
+func TestConnectionPoolReusesLockdownSession(t *testing.T) {
+	var sessions []*fakeLockdownSession
+	pairRecordReads := 0
+	pool := fakePool(&sessions, &pairRecordReads)
+
+	for _, service := range []string{"com.apple.afc", "com.apple.syslog_relay", "com.apple.afc"} {
+		response, err := pool.StartService(pooledTestDevice, service)
+		require.NoError(t, err)
+		assert.Equal(t, service, response.Service)
+	}
+	_, err := pool.PairRecord(pooledTestDevice)
+	require.NoError(t, err)
+
+	require.Len(t, sessions, 1)
+	assert.Equal(t, 3, sessions[0].started)
+	assert.Equal(t, 1, pairRecordReads)
+
+	t.Run("replaces a broken session", func(t *testing.T) {
+		sessions[0].broken = true
+
+		_, err := pool.StartService(pooledTestDevice, "com.apple.afc")
+
+		require.NoError(t, err)
+		require.Len(t, sessions, 2)
+		assert.True(t, sessions[0].closed)
+		assert.Equal(t, 1, sessions[1].started)
+	})
+
+	t.Run("a reconnected device gets a new session", func(t *testing.T) {
+		reconnected := pooledTestDevice
+		reconnected.DeviceID = 4
+
+		_, err := pool.StartService(reconnected, "com.apple.afc")
+
+		require.NoError(t, err)
+		assert.Len(t, sessions, 3)
+	})
+}
+
+func TestConnectionPoolEviction(t *testing.T) {
+	var sessions []*fakeLockdownSession
+	pairRecordReads := 0
+	pool := fakePool(&sessions, &pairRecordReads)
+	now := time.Date(2024, 1, 16, 15, 36, 43, 0, time.UTC)
+	pool.now = func() time.Time { return now }
+
+	_, err := pool.StartService(pooledTestDevice, "com.apple.afc")
+	require.NoError(t, err)
+	pool.closeSessionsIdleSince(now.Add(-time.Minute))
+	assert.False(t, sessions[0].closed, "a session that was used recently stays open")
+	pool.closeSessionsIdleSince(now.Add(time.Second))
+	assert.True(t, sessions[0].closed)
+
+	_, err = pool.StartService(pooledTestDevice, "com.apple.afc")
+	require.NoError(t, err)
+	assert.Len(t, sessions, 2)
+	assert.Equal(t, 1, pairRecordReads, "idle sessions keep the pair record")
+
+	pool.evictDeviceID(pooledTestDevice.DeviceID)
+	assert.True(t, sessions[1].closed)
+	_, err = pool.StartService(pooledTestDevice, "com.apple.afc")
+	require.NoError(t, err)
+	assert.Equal(t, 2, pairRecordReads, "detached devices lose their pair record")
+
+	pool.Close()
+	assert.True(t, sessions[2].closed)
+	_, err = pool.StartService(pooledTestDevice, "com.apple.afc")
+	assert.Error(t, err)
+}
+
+func TestConnectionPoolRsd(t *testing.T) {
+	pool := newConnectionPool(time.Minute)
+	handshakes := 0
+	pool.rsdHandshake = func(device DeviceEntry, address string, port int) (RsdPortProvider, error) {
+		handshakes++
+		return RsdHandshakeResponse{Udid: device.Properties.SerialNumber}, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err := pool.Rsd(pooledTestDevice, "fd00::1", 58783)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 1, handshakes)
+
+	_, err := pool.Rsd(pooledTestDevice, "fd00::2", 58783)
+	require.NoError(t, err)
+	assert.Equal(t, 2, handshakes, "a new tunnel needs a new handshake")
+}
+
+func TestStartServiceUsesConnectionPoolOfDevice(t *testing.T) {
+	var sessions []*fakeLockdownSession
+	pairRecordReads := 0
+	pool := fakePool(&sessions, &pairRecordReads)
+	device := pooledTestDevice
+	device.ConnectionPool = pool
+
+	for i := 0; i < 2; i++ {
+		_, err := StartService(device, "com.apple.afc")
+		require.NoError(t, err)
+	}
+
+	require.Len(t, sessions, 1)
+	assert.Equal(t, 2, sessions[0].started)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"time"
 
 	"github.com/danielpaulus/go-ios/ios"
 	"github.com/danielpaulus/go-ios/ios/afc"
@@ -126,6 +127,60 @@ func ListReports(device ios.DeviceEntry, pattern string) ([]string, error) {
 	return afc.ListFiles(".", pattern)
 }
 
+// Report is a crash report in the crash report directory of the device
+type Report struct {
+	// Name is the file name of the report, f.ex. MyApp-2024-01-16-153643.ips
+	Name string
+	// ModTime is the time the report was written
+	ModTime time.Time
+}
+
+// ListReportsSince returns the crash reports matching pattern that were written at since or later, f.ex. to find
+// the reports of processes that crashed while tests ran
+func ListReportsSince(device ios.DeviceEntry, pattern string, since time.Time) ([]Report, error) {
+	err := moveReports(device)
+	if err != nil {
+		return nil, err
+	}
+	deviceConn, err := ios.ConnectToService(device, crashReportCopyMobileService)
+	if err != nil {
+		return nil, err
+	}
+	afc := afc.NewFromConn(deviceConn)
+	defer afc.Close()
+	files, err := afc.ListFiles(".", pattern)
+	if err != nil {
+		return nil, err
+	}
+	var reports []Report
+	for _, f := range files {
+		if f == "." || f == ".." {
+			continue
+		}
+		info, err := afc.Stat(f)
+		if err != nil {
+			log.Warnf("failed getting info for file: %s, skipping", f)
+			continue
+		}
+		if info.IsDir() || info.ModTime().Before(since) {
+			continue
+		}
+		reports = append(reports, Report{Name: f, ModTime: info.ModTime()})
+	}
+	return reports, nil
+}
+
+// DownloadReport copies the crash report with the given name, see Report, to targetPath
+func DownloadReport(device ios.DeviceEntry, name string, targetPath string) error {
+	deviceConn, err := ios.ConnectToService(device, crashReportCopyMobileService)
+	if err != nil {
+		return err
+	}
+	afc := afc.NewFromConn(deviceConn)
+	defer afc.Close()
+	return afc.PullSingleFile(name, targetPath)
+}
+
 func moveReports(device ios.DeviceEntry) error {
 	log.Debug("moving crashreports")
 	conn, err := newMover(device)
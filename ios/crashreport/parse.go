@@ -0,0 +1,369 @@
+package crashreport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// CrashReport is the structured content of a crash report
+type CrashReport struct {
+	Process   string
+	PID       int
+	BundleID  string
+	OSVersion string
+	// ExceptionType is f.ex. "EXC_CRASH (SIGABRT)"
+	ExceptionType string
+	// TerminationReason is f.ex. "SIGNAL 6 Abort trap: 6"
+	TerminationReason string
+	// FaultingThread is the index of the thread that triggered the crash
+	FaultingThread int
+	Threads        []Thread
+	BinaryImages   []BinaryImage
+}
+
+// Thread is the stack trace of a single thread at the time of the crash
+type Thread struct {
+	Index     int
+	Name      string
+	Triggered bool
+	Frames    []Frame
+}
+
+// Frame is a single stack frame. Symbol is empty if the crash report is not symbolicated for the frame.
+type Frame struct {
+	Index int
+	// Image is the name of the binary image containing the frame
+	Image   string
+	Address uint64
+	// ImageOffset is the offset of Address from the load address of Image
+	ImageOffset  uint64
+	Symbol       string
+	SymbolOffset uint64
+}
+
+// BinaryImage is a binary that was loaded into the crashed process
+type BinaryImage struct {
+	Name        string
+	UUID        string
+	Path        string
+	LoadAddress uint64
+	Size        uint64
+}
+
+// FaultingThreadFrames returns the frames of the thread that triggered the crash
+func (r CrashReport) FaultingThreadFrames() []Frame {
+	for _, thread := range r.Threads {
+		if thread.Index == r.FaultingThread {
+			return thread.Frames
+		}
+	}
+	return nil
+}
+
+// ParseCrashReport parses a crash report in the JSON .ips format of iOS 15 and later or in the legacy text
+// format of .crash files and older .ips files
+func ParseCrashReport(content []byte) (CrashReport, error) {
+	content = bytes.TrimSpace(content)
+	var header ipsHeader
+	body := content
+	// .ips files start with a single line JSON header, followed by the report
+	if firstLine, rest, found := bytes.Cut(content, []byte("\n")); found && json.Valid(firstLine) {
+		if err := json.Unmarshal(firstLine, &header); err != nil {
+			return CrashReport{}, fmt.Errorf("ParseCrashReport: invalid ips header: %w", err)
+		}
+		body = bytes.TrimSpace(rest)
+	}
+
+	var report CrashReport
+	var err error
+	if bytes.HasPrefix(body, []byte("{")) {
+		report, err = parseIpsBody(body)
+	} else {
+		report, err = parseLegacyReport(body)
+	}
+	if err != nil {
+		return CrashReport{}, fmt.Errorf("ParseCrashReport: %w", err)
+	}
+	if report.Process == "" {
+		report.Process = header.AppName
+	}
+	if report.BundleID == "" {
+		report.BundleID = header.BundleID
+	}
+	if report.OSVersion == "" {
+		report.OSVersion = header.OSVersion
+	}
+	return report, nil
+}
+
+type ipsHeader struct {
+	AppName   string `json:"app_name"`
+	BundleID  string `json:"bundleID"`
+	OSVersion string `json:"os_version"`
+}
+
+type ipsBody struct {
+	ProcName   string `json:"procName"`
+	PID        int    `json:"pid"`
+	BundleInfo struct {
+		BundleIdentifier string `json:"CFBundleIdentifier"`
+	} `json:"bundleInfo"`
+	OSVersion struct {
+		Train string `json:"train"`
+		Build string `json:"build"`
+	} `json:"osVersion"`
+	Exception struct {
+		Type    string `json:"type"`
+		Signal  string `json:"signal"`
+		Subtype string `json:"subtype"`
+	} `json:"exception"`
+	Termination struct {
+		Namespace string   `json:"namespace"`
+		Code      int      `json:"code"`
+		Indicator string   `json:"indicator"`
+		Reasons   []string `json:"reasons"`
+	} `json:"termination"`
+	FaultingThread int `json:"faultingThread"`
+	Threads        []struct {
+		Name      string `json:"name"`
+		Queue     string `json:"queue"`
+		Triggered bool   `json:"triggered"`
+		Frames    []struct {
+			ImageOffset    uint64 `json:"imageOffset"`
+			ImageIndex     int    `json:"imageIndex"`
+			Symbol         string `json:"symbol"`
+			SymbolLocation uint64 `json:"symbolLocation"`
+		} `json:"frames"`
+	} `json:"threads"`
+	UsedImages []struct {
+		Name string `json:"name"`
+		UUID string `json:"uuid"`
+		Path string `json:"path"`
+		Base uint64 `json:"base"`
+		Size uint64 `json:"size"`
+	} `json:"usedImages"`
+}
+
+func parseIpsBody(body []byte) (CrashReport, error) {
+	var ips ipsBody
+	if err := json.Unmarshal(body, &ips); err != nil {
+		return CrashReport{}, fmt.Errorf("invalid ips report: %w", err)
+	}
+	report := CrashReport{
+		Process:        ips.ProcName,
+		PID:            ips.PID,
+		BundleID:       ips.BundleInfo.BundleIdentifier,
+		OSVersion:      strings.TrimSpace(fmt.Sprintf("%s (%s)", ips.OSVersion.Train, ips.OSVersion.Build)),
+		ExceptionType:  ips.Exception.Type,
+		FaultingThread: ips.FaultingThread,
+	}
+	if report.OSVersion == "()" {
+		report.OSVersion = ""
+	}
+	if ips.Exception.Signal != "" {
+		report.ExceptionType = fmt.Sprintf("%s (%s)", ips.Exception.Type, ips.Exception.Signal)
+	}
+	if ips.Termination.Namespace != "" {
+		reason := []string{ips.Termination.Namespace, strconv.Itoa(ips.Termination.Code)}
+		if ips.Termination.Indicator != "" {
+			reason = append(reason, ips.Termination.Indicator)
+		}
+		reason = append(reason, ips.Termination.Reasons...)
+		report.TerminationReason = strings.Join(reason, " ")
+	}
+
+	for _, image := range ips.UsedImages {
+		report.BinaryImages = append(report.BinaryImages, BinaryImage{
+			Name:        image.Name,
+			UUID:        image.UUID,
+			Path:        image.Path,
+			LoadAddress: image.Base,
+			Size:        image.Size,
+		})
+	}
+	for i, ipsThread := range ips.Threads {
+		thread := Thread{Index: i, Name: ipsThread.Name, Triggered: ipsThread.Triggered}
+		if thread.Name == "" && ipsThread.Queue != "" {
+			thread.Name = "Dispatch queue: " + ipsThread.Queue
+		}
+		for j, ipsFrame := range ipsThread.Frames {
+			frame := Frame{Index: j, ImageOffset: ipsFrame.ImageOffset, Symbol: ipsFrame.Symbol, SymbolOffset: ipsFrame.SymbolLocation}
+			if ipsFrame.ImageIndex >= 0 && ipsFrame.ImageIndex < len(report.BinaryImages) {
+				image := report.BinaryImages[ipsFrame.ImageIndex]
+				frame.Image = image.Name
+				frame.Address = image.LoadAddress + ipsFrame.ImageOffset
+			}
+			thread.Frames = append(thread.Frames, frame)
+		}
+		report.Threads = append(report.Threads, thread)
+	}
+	return report, nil
+}
+
+var (
+	legacyProcessRegex     = regexp.MustCompile(`^(.*?)\s*\[(\d+)\]$`)
+	legacyThreadRegex      = regexp.MustCompile(`^Thread (\d+)( Crashed)?:`)
+	legacyThreadNameRegex  = regexp.MustCompile(`^Thread (\d+) name:\s*(.*)$`)
+	legacyFrameRegex       = regexp.MustCompile(`^(\d+)\s+(.+?)\s+0x([0-9a-fA-F]+)\s+(.*)$`)
+	legacyBinaryImageRegex = regexp.MustCompile(`^\s*0x([0-9a-fA-F]+)\s*-\s*0x([0-9a-fA-F]+)\s+\+?(\S+)\s+\S+\s+<([0-9a-fA-F-]+)>\s*(.*)$`)
+)
+
+func parseLegacyReport(body []byte) (CrashReport, error) {
+	report := CrashReport{}
+	threadNames := map[int]string{}
+	var thread *Thread
+	inBinaryImages := false
+	foundHeader := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if inBinaryImages {
+			if image, ok := parseLegacyBinaryImage(line); ok {
+				report.BinaryImages = append(report.BinaryImages, image)
+			}
+			continue
+		}
+		if line == "" {
+			if thread != nil {
+				report.Threads = append(report.Threads, *thread)
+				thread = nil
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "Binary Images:") {
+			inBinaryImages = true
+			continue
+		}
+		if thread != nil {
+			if frame, ok := parseLegacyFrame(line); ok {
+				thread.Frames = append(thread.Frames, frame)
+				continue
+			}
+		}
+		if match := legacyThreadNameRegex.FindStringSubmatch(line); match != nil {
+			index, _ := strconv.Atoi(match[1])
+			threadNames[index] = match[2]
+			continue
+		}
+		if match := legacyThreadRegex.FindStringSubmatch(line); match != nil {
+			index, _ := strconv.Atoi(match[1])
+			thread = &Thread{Index: index, Triggered: match[2] != ""}
+			if thread.Triggered {
+				report.FaultingThread = index
+			}
+			continue
+		}
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch key {
+		case "Process":
+			foundHeader = true
+			if match := legacyProcessRegex.FindStringSubmatch(value); match != nil {
+				report.Process = match[1]
+				report.PID, _ = strconv.Atoi(match[2])
+			} else {
+				report.Process = value
+			}
+		case "Identifier":
+			report.BundleID = value
+		case "OS Version":
+			report.OSVersion = value
+		case "Exception Type":
+			foundHeader = true
+			report.ExceptionType = value
+		case "Termination Reason":
+			report.TerminationReason = value
+		case "Triggered by Thread":
+			report.FaultingThread, _ = strconv.Atoi(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return CrashReport{}, fmt.Errorf("failed reading crash report: %w", err)
+	}
+	if thread != nil {
+		report.Threads = append(report.Threads, *thread)
+	}
+	if !foundHeader {
+		return CrashReport{}, fmt.Errorf("not a crash report, missing Process and Exception Type")
+	}
+
+	for i := range report.Threads {
+		report.Threads[i].Name = threadNames[report.Threads[i].Index]
+		if report.Threads[i].Index == report.FaultingThread {
+			report.Threads[i].Triggered = true
+		}
+		for j := range report.Threads[i].Frames {
+			frame := &report.Threads[i].Frames[j]
+			if frame.ImageOffset == 0 {
+				if image, ok := report.imageContaining(frame.Address); ok {
+					frame.ImageOffset = frame.Address - image.LoadAddress
+				}
+			}
+		}
+	}
+	return report, nil
+}
+
+// parseLegacyFrame parses a frame line like
+// '0   libsystem_kernel.dylib   0x00000001bbd4e84c __pthread_kill + 8' or an unsymbolicated frame like
+// '2   MyApp   0x0000000100f2c0a4 0x100f24000 + 32932'
+func parseLegacyFrame(line string) (Frame, bool) {
+	match := legacyFrameRegex.FindStringSubmatch(line)
+	if match == nil {
+		return Frame{}, false
+	}
+	index, _ := strconv.Atoi(match[1])
+	address, err := strconv.ParseUint(match[3], 16, 64)
+	if err != nil {
+		return Frame{}, false
+	}
+	frame := Frame{Index: index, Image: match[2], Address: address}
+	location, offset, found := strings.Cut(match[4], " + ")
+	if !found {
+		frame.Symbol = match[4]
+		return frame, true
+	}
+	offsetValue, _ := strconv.ParseUint(strings.TrimSpace(offset), 10, 64)
+	if strings.HasPrefix(location, "0x") {
+		frame.ImageOffset = offsetValue
+		return frame, true
+	}
+	frame.Symbol = location
+	frame.SymbolOffset = offsetValue
+	return frame, true
+}
+
+func parseLegacyBinaryImage(line string) (BinaryImage, bool) {
+	match := legacyBinaryImageRegex.FindStringSubmatch(line)
+	if match == nil {
+		return BinaryImage{}, false
+	}
+	start, err := strconv.ParseUint(match[1], 16, 64)
+	if err != nil {
+		return BinaryImage{}, false
+	}
+	end, err := strconv.ParseUint(match[2], 16, 64)
+	if err != nil {
+		return BinaryImage{}, false
+	}
+	return BinaryImage{Name: match[3], UUID: match[4], Path: match[5], LoadAddress: start, Size: end - start + 1}, true
+}
+
+func (r CrashReport) imageContaining(address uint64) (BinaryImage, bool) {
+	for _, image := range r.BinaryImages {
+		if address >= image.LoadAddress && address < image.LoadAddress+image.Size {
+			return image, true
+		}
+	}
+	return BinaryImage{}, false
+}
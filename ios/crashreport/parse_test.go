@@ -0,0 +1,114 @@
+package crashreport_test
+
+import (
+	"testing"
+
+	"github.com/danielpaulus/go-ios/ios/crashreport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const ipsReport = `{"app_name":"MyApp","timestamp":"2024-01-16 15:36:43.00 +0100","app_version":"1.0","slice_uuid":"3f1c0f3e-7a4b-3c5d-9e8f-0a1b2c3d4e5f","build_version":"1","platform":2,"bundleID":"com.example.MyApp","share_with_app_devs":0,"is_first_party":0,"bug_type":"309","os_version":"iPhone OS 17.2 (21C62)","incident_id":"A1B2C3D4-0000-0000-0000-000000000000","name":"MyApp"}
+{
+  "uptime" : 1000,
+  "procName" : "MyApp",
+  "pid" : 1234,
+  "bundleInfo" : {"CFBundleShortVersionString":"1.0","CFBundleVersion":"1","CFBundleIdentifier":"com.example.MyApp"},
+  "osVersion" : {"train":"iPhone OS 17.2","build":"21C62","releaseType":"User"},
+  "exception" : {"codes":"0x0000000000000000, 0x0000000000000000","rawCodes":[0,0],"type":"EXC_CRASH","signal":"SIGABRT"},
+  "termination" : {"flags":0,"code":6,"namespace":"SIGNAL","indicator":"Abort trap: 6","byProc":"MyApp","byPid":1234},
+  "faultingThread" : 0,
+  "threads" : [
+    {"triggered":true,"id":100,"queue":"com.apple.main-thread","frames":[
+      {"imageOffset":34892,"symbol":"__pthread_kill","symbolLocation":8,"imageIndex":0},
+      {"imageOffset":32932,"imageIndex":1}
+    ]},
+    {"id":101,"name":"worker","frames":[
+      {"imageOffset":4096,"symbol":"mach_msg2_trap","symbolLocation":8,"imageIndex":0}
+    ]}
+  ],
+  "usedImages" : [
+    {"source":"P","arch":"arm64e","base":7445233664,"size":241664,"uuid":"c8b0e4d3-1a2b-3c4d-5e6f-7a8b9c0d1e2f","path":"/usr/lib/system/libsystem_kernel.dylib","name":"libsystem_kernel.dylib"},
+    {"source":"P","arch":"arm64","base":4310777856,"size":49152,"uuid":"3f1c0f3e-7a4b-3c5d-9e8f-0a1b2c3d4e5f","path":"/private/var/containers/Bundle/Application/UUID/MyApp.app/MyApp","name":"MyApp"}
+  ]
+}`
+
+const legacyReport = `Incident Identifier: A1B2C3D4-0000-0000-0000-000000000000
+Hardware Model:      iPhone12,1
+Process:             MyApp [1234]
+Path:                /private/var/containers/Bundle/Application/UUID/MyApp.app/MyApp
+Identifier:          com.example.MyApp
+Version:             1 (1.0)
+OS Version:          iPhone OS 14.4 (18D52)
+
+Exception Type:  EXC_CRASH (SIGABRT)
+Exception Codes: 0x0000000000000000, 0x0000000000000000
+Termination Reason: SIGNAL 6 Abort trap: 6
+Triggered by Thread:  0
+
+Thread 0 name:  Dispatch queue: com.apple.main-thread
+Thread 0 Crashed:
+0   libsystem_kernel.dylib        	0x00000001bbc0884c __pthread_kill + 8
+1   MyApp                         	0x0000000100f2c0a4 0x100f24000 + 32932
+
+Thread 1:
+0   libsystem_kernel.dylib        	0x00000001bbc01000 mach_msg_trap + 8
+
+Binary Images:
+0x100f24000 - 0x100f2ffff MyApp arm64  <3f1c0f3e7a4b3c5d9e8f0a1b2c3d4e5f> /private/var/containers/Bundle/Application/UUID/MyApp.app/MyApp
+0x1bbc00000 - 0x1bbc3afff libsystem_kernel.dylib arm64e  <c8b0e4d31a2b3c4d5e6f7a8b9c0d1e2f> /usr/lib/system/libsystem_kernel.dylib
+`
+
+func TestParseIpsCrashReport(t *testing.T) {
+	report, err := crashreport.ParseCrashReport([]byte(ipsReport))
+	require.NoError(t, err)
+
+	assert.Equal(t, "MyApp", report.Process)
+	assert.Equal(t, 1234, report.PID)
+	assert.Equal(t, "com.example.MyApp", report.BundleID)
+	assert.Equal(t, "iPhone OS 17.2 (21C62)", report.OSVersion)
+	assert.Equal(t, "EXC_CRASH (SIGABRT)", report.ExceptionType)
+	assert.Equal(t, "SIGNAL 6 Abort trap: 6", report.TerminationReason)
+	assert.Equal(t, 0, report.FaultingThread)
+	require.Len(t, report.Threads, 2)
+	assert.Equal(t, "Dispatch queue: com.apple.main-thread", report.Threads[0].Name)
+	assert.Equal(t, "worker", report.Threads[1].Name)
+	assert.Len(t, report.BinaryImages, 2)
+
+	assert.Equal(t, []crashreport.Frame{
+		{Index: 0, Image: "libsystem_kernel.dylib", Address: 7445233664 + 34892, ImageOffset: 34892, Symbol: "__pthread_kill", SymbolOffset: 8},
+		{Index: 1, Image: "MyApp", Address: 4310777856 + 32932, ImageOffset: 32932},
+	}, report.FaultingThreadFrames())
+}
+
+func TestParseLegacyCrashReport(t *testing.T) {
+	report, err := crashreport.ParseCrashReport([]byte(legacyReport))
+	require.NoError(t, err)
+
+	assert.Equal(t, "MyApp", report.Process)
+	assert.Equal(t, 1234, report.PID)
+	assert.Equal(t, "com.example.MyApp", report.BundleID)
+	assert.Equal(t, "iPhone OS 14.4 (18D52)", report.OSVersion)
+	assert.Equal(t, "EXC_CRASH (SIGABRT)", report.ExceptionType)
+	assert.Equal(t, "SIGNAL 6 Abort trap: 6", report.TerminationReason)
+	assert.Equal(t, 0, report.FaultingThread)
+	require.Len(t, report.Threads, 2)
+	assert.True(t, report.Threads[0].Triggered)
+	assert.Equal(t, "Dispatch queue: com.apple.main-thread", report.Threads[0].Name)
+	assert.False(t, report.Threads[1].Triggered)
+
+	assert.Equal(t, []crashreport.BinaryImage{
+		{Name: "MyApp", UUID: "3f1c0f3e7a4b3c5d9e8f0a1b2c3d4e5f", Path: "/private/var/containers/Bundle/Application/UUID/MyApp.app/MyApp", LoadAddress: 0x100f24000, Size: 0xc000},
+		{Name: "libsystem_kernel.dylib", UUID: "c8b0e4d31a2b3c4d5e6f7a8b9c0d1e2f", Path: "/usr/lib/system/libsystem_kernel.dylib", LoadAddress: 0x1bbc00000, Size: 0x3b000},
+	}, report.BinaryImages)
+
+	assert.Equal(t, []crashreport.Frame{
+		{Index: 0, Image: "libsystem_kernel.dylib", Address: 0x1bbc0884c, ImageOffset: 0x884c, Symbol: "__pthread_kill", SymbolOffset: 8},
+		{Index: 1, Image: "MyApp", Address: 0x100f2c0a4, ImageOffset: 32932},
+	}, report.FaultingThreadFrames())
+}
+
+func TestParseCrashReportInvalid(t *testing.T) {
+	_, err := crashreport.ParseCrashReport([]byte("just some text\n"))
+	assert.Error(t, err)
+}
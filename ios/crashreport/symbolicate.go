@@ -0,0 +1,197 @@
+package crashreport
+
+import (
+	"debug/macho"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// lcUUID is the load command containing the UUID of a Mach-O binary
+const lcUUID = 0x1b
+
+// Symbols maps offsets into a binary image to the names of the symbols at these offsets
+type Symbols struct {
+	// Image is the name of the binary image, f.ex. "MyApp"
+	Image string
+	// UUID of the binary image. If it is set, the symbols are only used for images with the same UUID.
+	UUID    string
+	symbols []symbol
+}
+
+type symbol struct {
+	offset uint64
+	name   string
+}
+
+// NewSymbols creates the symbols of image from a map of image offsets to symbol names
+func NewSymbols(image string, uuid string, offsets map[uint64]string) Symbols {
+	s := Symbols{Image: image, UUID: uuid}
+	for offset, name := range offsets {
+		s.symbols = append(s.symbols, symbol{offset: offset, name: name})
+	}
+	sort.Slice(s.symbols, func(i, j int) bool {
+		return s.symbols[i].offset < s.symbols[j].offset
+	})
+	return s
+}
+
+// Lookup returns the symbol containing imageOffset and the offset of imageOffset from the start of the symbol
+func (s Symbols) Lookup(imageOffset uint64) (string, uint64, bool) {
+	i := sort.Search(len(s.symbols), func(i int) bool {
+		return s.symbols[i].offset > imageOffset
+	})
+	if i == 0 {
+		return "", 0, false
+	}
+	sym := s.symbols[i-1]
+	return sym.name, imageOffset - sym.offset, true
+}
+
+// LoadDSYM reads the symbol tables of a .dSYM bundle or of the DWARF file inside of it. Universal binaries
+// return the symbols of each contained architecture.
+func LoadDSYM(path string) ([]Symbols, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("LoadDSYM: %w", err)
+	}
+	files := []string{path}
+	if info.IsDir() {
+		files, err = filepath.Glob(filepath.Join(path, "Contents", "Resources", "DWARF", "*"))
+		if err != nil {
+			return nil, fmt.Errorf("LoadDSYM: %w", err)
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("LoadDSYM: no DWARF files found in %s", path)
+		}
+	}
+	var result []Symbols
+	for _, file := range files {
+		symbols, err := loadMachOSymbols(file)
+		if err != nil {
+			return nil, fmt.Errorf("LoadDSYM: %w", err)
+		}
+		result = append(result, symbols...)
+	}
+	return result, nil
+}
+
+func loadMachOSymbols(path string) ([]Symbols, error) {
+	image := filepath.Base(path)
+	fat, err := macho.OpenFat(path)
+	if err == nil {
+		defer fat.Close()
+		var result []Symbols
+		for _, arch := range fat.Arches {
+			result = append(result, machOSymbols(image, arch.File))
+		}
+		return result, nil
+	}
+	if !errors.Is(err, macho.ErrNotFat) {
+		return nil, fmt.Errorf("failed reading %s: %w", path, err)
+	}
+	f, err := macho.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading %s: %w", path, err)
+	}
+	defer f.Close()
+	return []Symbols{machOSymbols(image, f)}, nil
+}
+
+// machOSymbols converts the symbol table of f to offsets relative to the start of its __TEXT segment, which is
+// what the image offsets of crash reports are relative to
+func machOSymbols(image string, f *macho.File) Symbols {
+	s := Symbols{Image: image}
+	for _, load := range f.Loads {
+		raw := load.Raw()
+		if len(raw) >= 24 && f.ByteOrder.Uint32(raw) == lcUUID {
+			s.UUID = hex.EncodeToString(raw[8:24])
+		}
+	}
+	var textAddress uint64
+	if text := f.Segment("__TEXT"); text != nil {
+		textAddress = text.Addr
+	}
+	if f.Symtab == nil {
+		return s
+	}
+	for _, sym := range f.Symtab.Syms {
+		// skip debugger entries and symbols that are not defined in a section
+		if sym.Type&0xe0 != 0 || sym.Type&0x0e != 0x0e || sym.Value < textAddress {
+			continue
+		}
+		s.symbols = append(s.symbols, symbol{offset: sym.Value - textAddress, name: strings.TrimPrefix(sym.Name, "_")})
+	}
+	sort.Slice(s.symbols, func(i, j int) bool {
+		return s.symbols[i].offset < s.symbols[j].offset
+	})
+	return s
+}
+
+// Symbolicate resolves the frames of the faulting thread of report that are not symbolicated yet with symbols.
+// Frames of images without matching symbols keep their raw addresses.
+func Symbolicate(report CrashReport, symbols []Symbols) CrashReport {
+	threads := make([]Thread, len(report.Threads))
+	copy(threads, report.Threads)
+	report.Threads = threads
+	for i := range report.Threads {
+		if report.Threads[i].Index != report.FaultingThread {
+			continue
+		}
+		frames := make([]Frame, len(report.Threads[i].Frames))
+		copy(frames, report.Threads[i].Frames)
+		for j := range frames {
+			if frames[j].Symbol != "" {
+				continue
+			}
+			imageSymbols, ok := report.symbolsForImage(frames[j].Image, symbols)
+			if !ok {
+				continue
+			}
+			if name, offset, ok := imageSymbols.Lookup(frames[j].ImageOffset); ok {
+				frames[j].Symbol = name
+				frames[j].SymbolOffset = offset
+			}
+		}
+		report.Threads[i].Frames = frames
+	}
+	return report
+}
+
+// symbolsForImage returns the symbols for the binary image named image. Symbols with a UUID are only used if it
+// matches the UUID of the image in the crash report.
+func (r CrashReport) symbolsForImage(image string, symbols []Symbols) (Symbols, bool) {
+	var uuid string
+	for _, binaryImage := range r.BinaryImages {
+		if binaryImage.Name == image {
+			uuid = normalizeUUID(binaryImage.UUID)
+			break
+		}
+	}
+	for _, s := range symbols {
+		if s.Image != image {
+			continue
+		}
+		if s.UUID != "" && uuid != "" && normalizeUUID(s.UUID) != uuid {
+			continue
+		}
+		return s, true
+	}
+	return Symbols{}, false
+}
+
+func normalizeUUID(uuid string) string {
+	return strings.ToLower(strings.ReplaceAll(uuid, "-", ""))
+}
+
+// String formats the frame like in a crash report, using the raw address if the frame is not symbolicated
+func (f Frame) String() string {
+	if f.Symbol != "" {
+		return fmt.Sprintf("%d %s 0x%x %s + %d", f.Index, f.Image, f.Address, f.Symbol, f.SymbolOffset)
+	}
+	return fmt.Sprintf("%d %s 0x%x 0x%x + %d", f.Index, f.Image, f.Address, f.Address-f.ImageOffset, f.ImageOffset)
+}
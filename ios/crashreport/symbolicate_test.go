@@ -0,0 +1,45 @@
+package crashreport_test
+
+import (
+	"testing"
+
+	"github.com/danielpaulus/go-ios/ios/crashreport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSymbolicate(t *testing.T) {
+	report, err := crashreport.ParseCrashReport([]byte(legacyReport))
+	require.NoError(t, err)
+
+	symbols := []crashreport.Symbols{
+		crashreport.NewSymbols("MyApp", "00000000-0000-0000-0000-000000000000", map[uint64]string{0x8000: "wrongBuild"}),
+		crashreport.NewSymbols("MyApp", "3F1C0F3E-7A4B-3C5D-9E8F-0A1B2C3D4E5F", map[uint64]string{
+			0x1000: "main",
+			0x8000: "-[LoginViewController login]",
+			0x9000: "-[LoginViewController logout]",
+		}),
+	}
+	symbolicated := crashreport.Symbolicate(report, symbols)
+
+	frames := symbolicated.FaultingThreadFrames()
+	assert.Equal(t, "-[LoginViewController login]", frames[1].Symbol)
+	assert.Equal(t, uint64(32932-0x8000), frames[1].SymbolOffset)
+	assert.Equal(t, "__pthread_kill", frames[0].Symbol, "already symbolicated frames are kept")
+	assert.Equal(t, "1 MyApp 0x100f2c0a4 -[LoginViewController login] + 164", frames[1].String())
+	assert.Empty(t, report.FaultingThreadFrames()[1].Symbol, "the original report must not be modified")
+}
+
+func TestSymbolicateMissingSymbols(t *testing.T) {
+	report, err := crashreport.ParseCrashReport([]byte(legacyReport))
+	require.NoError(t, err)
+
+	symbolicated := crashreport.Symbolicate(report, []crashreport.Symbols{
+		crashreport.NewSymbols("OtherFramework", "", map[uint64]string{0: "other"}),
+		crashreport.NewSymbols("MyApp", "", map[uint64]string{0x9000: "afterTheFrame"}),
+	})
+
+	frame := symbolicated.FaultingThreadFrames()[1]
+	assert.Empty(t, frame.Symbol)
+	assert.Equal(t, "1 MyApp 0x100f2c0a4 0x100f24000 + 32932", frame.String())
+}
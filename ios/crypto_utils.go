@@ -209,6 +209,49 @@ func Sign(challengeBytes []byte, cert *x509.Certificate, supervisedPrivateKey in
 	return sd.Finish()
 }
 
+// ParseSupervisionIdentity parses the certificate and private key of a supervision identity, f.ex. the files created
+// by 'ios prepare create-cert' or exported from Apple Configurator. Both can be PEM or DER encoded, keys can be PKCS1,
+// PKCS8 or EC keys.
+func ParseSupervisionIdentity(certBytes []byte, keyBytes []byte) (*x509.Certificate, crypto.Signer, error) {
+	if block, _ := pem.Decode(certBytes); block != nil {
+		certBytes = block.Bytes
+	}
+	cert, err := x509.ParseCertificate(certBytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ParseSupervisionIdentity: invalid certificate: %w", err)
+	}
+	if block, _ := pem.Decode(keyBytes); block != nil {
+		keyBytes = block.Bytes
+	}
+	key, err := parsePrivateKey(keyBytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ParseSupervisionIdentity: invalid private key: %w", err)
+	}
+	publicKey, ok := key.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !publicKey.Equal(cert.PublicKey) {
+		return nil, nil, errors.New("ParseSupervisionIdentity: the private key does not belong to the certificate")
+	}
+	return cert, key, nil
+}
+
+func parsePrivateKey(der []byte) (crypto.Signer, error) {
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+	return signer, nil
+}
+
 // CaCertificate is a simple struct to hold a x509 cert and privateKey in DER and PEM formats
 // as well as the CER should you need it.
 type CaCertificate struct {
@@ -0,0 +1,160 @@
+package debugserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/danielpaulus/go-ios/ios"
+	log "github.com/sirupsen/logrus"
+)
+
+// rsdServiceName is the debugserver of devices with iOS 17 and later, reachable through the tunnel
+const rsdServiceName = "com.apple.internal.dt.remote.debugproxy"
+
+// interruptPacket stops the running process, it is sent without packet framing
+const interruptPacket = "\x03"
+
+// ProcessExit is how a process ended, either by exiting with Status or by the signal Signal
+type ProcessExit struct {
+	Status int
+	Signal int
+}
+
+// ExitCode returns the exit status of the process, or 128 plus the signal like shells report processes killed by a
+// signal
+func (e ProcessExit) ExitCode() int {
+	if e.Signal != 0 {
+		return 128 + e.Signal
+	}
+	return e.Status
+}
+
+// WaitForExit attaches debugserver to the running process with pid and blocks until the process exited. The process
+// continues right after attaching, signals it receives are passed on to it. If ctx is done before, debugserver detaches
+// from the process, which keeps running, and ctx.Err() is returned.
+func WaitForExit(ctx context.Context, device ios.DeviceEntry, pid uint64) (ProcessExit, error) {
+	var conn ios.DeviceConnectionInterface
+	var err error
+	if device.SupportsRsd() {
+		conn, err = ios.ConnectToServiceTunnelIface(device, rsdServiceName)
+	} else {
+		conn, err = connectToDevice(device)
+	}
+	if err != nil {
+		return ProcessExit{}, fmt.Errorf("WaitForExit: cannot connect to debugserver: %w", err)
+	}
+	defer conn.Close()
+	exit, err := waitForExit(ctx, conn, pid)
+	if err != nil {
+		return ProcessExit{}, fmt.Errorf("WaitForExit: %w", err)
+	}
+	return exit, nil
+}
+
+func waitForExit(ctx context.Context, conn io.ReadWriter, pid uint64) (ProcessExit, error) {
+	gdb := NewGDBServer(conn)
+	if _, err := gdb.Request("QStartNoAckMode"); err != nil {
+		return ProcessExit{}, err
+	}
+	reply, err := gdb.Request("vAttach;" + strconv.FormatUint(pid, 16))
+	if err != nil {
+		return ProcessExit{}, err
+	}
+	if strings.HasPrefix(reply, "E") {
+		return ProcessExit{}, fmt.Errorf("cannot attach to process %d: %s", pid, reply)
+	}
+
+	// writes of the interrupt on cancellation must not interleave with the packets of the loop
+	var writeMu sync.Mutex
+	send := func(packet string) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		return gdb.Send(packet)
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			writeMu.Lock()
+			defer writeMu.Unlock()
+			if _, err := conn.Write([]byte(interruptPacket)); err != nil {
+				log.WithError(err).Debug("debugserver: cannot interrupt process")
+			}
+		case <-done:
+		}
+	}()
+
+	// the process is stopped after attaching
+	continuePacket := "c"
+	for {
+		if err := send(continuePacket); err != nil {
+			return ProcessExit{}, err
+		}
+		reply, err := receiveStopReply(gdb)
+		if err != nil {
+			return ProcessExit{}, err
+		}
+		exit, exited, err := parseStopReply(reply)
+		if err != nil {
+			return ProcessExit{}, err
+		}
+		if exited {
+			return exit, nil
+		}
+		if ctx.Err() != nil {
+			if _, err := gdb.Request("D"); err != nil {
+				log.WithError(err).Debug("debugserver: cannot detach from process")
+			}
+			return ProcessExit{}, ctx.Err()
+		}
+		// pass the signal the process stopped with on to it
+		continuePacket = "C" + reply[1:3]
+	}
+}
+
+// receiveStopReply returns the next reply of debugserver that is not console output of the process
+func receiveStopReply(gdb *GDBServer) (string, error) {
+	for {
+		reply, err := gdb.Recv()
+		if err != nil {
+			return "", err
+		}
+		if reply == "" {
+			return "", errors.New("debugserver closed the connection")
+		}
+		if reply[0] != 'O' {
+			return reply, nil
+		}
+	}
+}
+
+// parseStopReply returns the exit of the process if reply reports that the process exited (W) or was terminated by a
+// signal (X). Other valid replies are stops (T, S).
+func parseStopReply(reply string) (ProcessExit, bool, error) {
+	switch reply[0] {
+	case 'W', 'X':
+		if len(reply) < 3 {
+			return ProcessExit{}, false, fmt.Errorf("invalid stop reply %s", reply)
+		}
+		value, err := strconv.ParseUint(reply[1:3], 16, 8)
+		if err != nil {
+			return ProcessExit{}, false, fmt.Errorf("invalid stop reply %s", reply)
+		}
+		if reply[0] == 'W' {
+			return ProcessExit{Status: int(value)}, true, nil
+		}
+		return ProcessExit{Signal: int(value)}, true, nil
+	case 'T', 'S':
+		if len(reply) < 3 {
+			return ProcessExit{}, false, fmt.Errorf("invalid stop reply %s", reply)
+		}
+		return ProcessExit{}, false, nil
+	}
+	return ProcessExit{}, false, fmt.Errorf("unexpected reply %s", reply)
+}
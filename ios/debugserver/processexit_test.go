@@ -0,0 +1,139 @@
+package debugserver
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDebugserver answers the packets of waitForExit on conn with the replies of respond and records the received
+// packets, an interrupt is recorded as interruptPacket
+func fakeDebugserver(t *testing.T, conn net.Conn, respond func(packet string) []string) <-chan []string {
+	received := make(chan []string, 1)
+	go func() {
+		var packets []string
+		defer func() { received <- packets }()
+		reader := bufio.NewReader(conn)
+		gdb := NewGDBServer(conn)
+		for {
+			b, err := reader.ReadByte()
+			if err != nil {
+				return
+			}
+			var packet string
+			switch b {
+			case '+':
+				continue
+			case interruptPacket[0]:
+				packet = interruptPacket
+			case '$':
+				payload, err := reader.ReadString('#')
+				if err != nil {
+					return
+				}
+				if _, err := reader.Discard(2); err != nil {
+					return
+				}
+				packet = payload[:len(payload)-1]
+			default:
+				t.Errorf("unexpected byte %q", b)
+				return
+			}
+			packets = append(packets, packet)
+			for _, reply := range respond(packet) {
+				if err := gdb.Send(reply); err != nil {
+					return
+				}
+			}
+		}
+	}()
+	return received
+}
+
+func TestWaitForExit(t *testing.T) {
+	client, device := net.Pipe()
+	received := fakeDebugserver(t, device, func(packet string) []string {
+		switch packet {
+		case "QStartNoAckMode", "D":
+			return []string{"OK"}
+		case "vAttach;4d2":
+			return []string{"T11thread:1;"}
+		case "c":
+			return []string{"O68656c6c6f", "T1ethread:1;"}
+		case "C1e":
+			return []string{"W03"}
+		}
+		return []string{"E01"}
+	})
+
+	exit, err := waitForExit(context.Background(), client, 1234)
+	require.NoError(t, err)
+	assert.Equal(t, ProcessExit{Status: 3}, exit)
+	assert.Equal(t, 3, exit.ExitCode())
+
+	client.Close()
+	assert.Equal(t, []string{"QStartNoAckMode", "vAttach;4d2", "c", "C1e"}, <-received)
+}
+
+func TestWaitForExitAttachFails(t *testing.T) {
+	client, device := net.Pipe()
+	fakeDebugserver(t, device, func(packet string) []string {
+		if packet == "QStartNoAckMode" {
+			return []string{"OK"}
+		}
+		return []string{"E01"}
+	})
+
+	_, err := waitForExit(context.Background(), client, 1234)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cannot attach to process 1234")
+	}
+	client.Close()
+}
+
+func TestWaitForExitDetachesOnCancel(t *testing.T) {
+	client, device := net.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	received := fakeDebugserver(t, device, func(packet string) []string {
+		switch packet {
+		case "QStartNoAckMode", "D":
+			return []string{"OK"}
+		case "vAttach;4d2":
+			return []string{"T11thread:1;"}
+		case "c":
+			// the process keeps running until it is interrupted
+			cancel()
+			return nil
+		case interruptPacket:
+			return []string{"T02thread:1;"}
+		}
+		return []string{"E01"}
+	})
+
+	_, err := waitForExit(ctx, client, 1234)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	client.Close()
+	assert.Equal(t, []string{"QStartNoAckMode", "vAttach;4d2", "c", interruptPacket, "D"}, <-received)
+}
+
+func TestProcessExit(t *testing.T) {
+	exit, exited, err := parseStopReply("X09")
+	require.NoError(t, err)
+	assert.True(t, exited)
+	assert.Equal(t, ProcessExit{Signal: 9}, exit)
+	assert.Equal(t, 137, exit.ExitCode())
+
+	_, exited, err = parseStopReply("T05thread:1;")
+	require.NoError(t, err)
+	assert.False(t, exited)
+
+	for _, reply := range []string{"W", "Wzz", "T", "E01"} {
+		_, _, err = parseStopReply(reply)
+		assert.Error(t, err, reply)
+	}
+}
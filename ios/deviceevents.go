@@ -0,0 +1,126 @@
+package ios
+
+import (
+	"context"
+	"time"
+)
+
+// DeviceEventType is the kind of a DeviceEvent
+type DeviceEventType string
+
+const (
+	// EventDeviceAttached is sent when a device is connected to the host over USB or becomes reachable over the network
+	EventDeviceAttached = DeviceEventType("attached")
+	// EventDeviceDetached is sent when a device is disconnected from the host
+	EventDeviceDetached = DeviceEventType("detached")
+	// EventDevicePaired is sent when a device was paired with the host
+	EventDevicePaired = DeviceEventType("paired")
+)
+
+// ConnectionType values of DeviceEvent and DeviceProperties
+const (
+	ConnectionTypeUSB     = "USB"
+	ConnectionTypeNetwork = "Network"
+)
+
+// DeviceEvent is a notification of usbmuxd about a device. usbmuxd only sends the device id with detached and paired
+// events, the other fields are filled in from the attached event of the device.
+type DeviceEvent struct {
+	Type     DeviceEventType `json:"type"`
+	Time     time.Time       `json:"time"`
+	DeviceID int             `json:"deviceId"`
+	Udid     string          `json:"udid,omitempty"`
+	// ConnectionType is ConnectionTypeUSB or ConnectionTypeNetwork for devices connected over Wi-Fi
+	ConnectionType string `json:"connectionType,omitempty"`
+	// ConnectionSpeed is the speed of the USB interface in bit/s, it is 0 for network connections
+	ConnectionSpeed int `json:"connectionSpeed,omitempty"`
+	ProductID       int `json:"productId,omitempty"`
+	LocationID      int `json:"locationId,omitempty"`
+}
+
+// DeviceEvents is a subscription to the device events of usbmuxd, see ListenDeviceEvents
+type DeviceEvents struct {
+	// C receives the events, it is closed when the subscription ends
+	C   <-chan DeviceEvent
+	err error
+}
+
+// Err returns the error that ended the subscription, it is nil if the context was done. It may only be called after
+// C was closed.
+func (d *DeviceEvents) Err() error {
+	return d.err
+}
+
+// ListenDeviceEvents subscribes to the device events of usbmuxd until ctx is done or the connection to usbmuxd
+// fails. usbmuxd reports the devices that are attached when the subscription starts as attached events first.
+func ListenDeviceEvents(ctx context.Context) (*DeviceEvents, error) {
+	receive, closeListener, err := Listen()
+	if err != nil {
+		if closeListener != nil {
+			closeListener()
+		}
+		return nil, err
+	}
+	return deviceEventsFrom(ctx, receive, closeListener, time.Now), nil
+}
+
+func deviceEventsFrom(ctx context.Context, receive func() (AttachedMessage, error), closeListener func() error, now func() time.Time) *DeviceEvents {
+	events := make(chan DeviceEvent)
+	subscription := &DeviceEvents{C: events}
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		<-ctx.Done()
+		closeListener()
+	}()
+	go func() {
+		defer close(events)
+		defer cancel()
+		attached := map[int]DeviceProperties{}
+		for {
+			message, err := receive()
+			if err != nil {
+				if ctx.Err() == nil {
+					subscription.err = err
+				}
+				return
+			}
+			event, ok := newDeviceEvent(message, attached)
+			if !ok {
+				continue
+			}
+			event.Time = now()
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return subscription
+}
+
+// newDeviceEvent converts message to a DeviceEvent and keeps track of the attached devices, it returns false for
+// unknown message types
+func newDeviceEvent(message AttachedMessage, attached map[int]DeviceProperties) (DeviceEvent, bool) {
+	event := DeviceEvent{DeviceID: message.DeviceID}
+	properties := attached[message.DeviceID]
+	switch message.MessageType {
+	case "Attached":
+		event.Type = EventDeviceAttached
+		properties = message.Properties
+		attached[message.DeviceID] = properties
+	case "Detached":
+		event.Type = EventDeviceDetached
+		delete(attached, message.DeviceID)
+	case "Paired":
+		event.Type = EventDevicePaired
+	default:
+		return DeviceEvent{}, false
+	}
+	event.Udid = properties.SerialNumber
+	event.ConnectionType = properties.ConnectionType
+	event.ConnectionSpeed = properties.ConnectionSpeed
+	event.ProductID = properties.ProductID
+	event.LocationID = properties.LocationID
+	return event, true
+}
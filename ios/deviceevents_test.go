@@ -0,0 +1,81 @@
+package ios
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeListener returns messages and then err, or blocks until it is closed if err is nil
+func fakeListener(err error, messages ...AttachedMessage) (func() (AttachedMessage, error), func() error, chan struct{}) {
+	closed := make(chan struct{})
+	receive := func() (AttachedMessage, error) {
+		if len(messages) > 0 {
+			message := messages[0]
+			messages = messages[1:]
+			return message, nil
+		}
+		if err != nil {
+			return AttachedMessage{}, err
+		}
+		<-closed
+		return AttachedMessage{}, errors.New("use of closed network connection")
+	}
+	return receive, func() error {
+		close(closed)
+		return nil
+	}, closed
+}
+
+func TestDeviceEvents(t *testing.T) {
+	now := time.Date(2024, 1, 16, 15, 36, 43, 0, time.UTC)
+	properties := DeviceProperties{SerialNumber: "00008110-0001", ConnectionType: ConnectionTypeUSB, ConnectionSpeed: 480000000, ProductID: 4776, LocationID: 1}
+	usbmuxdError := errors.New("connection reset by peer")
+	receive, closeListener, closed := fakeListener(usbmuxdError,
+		AttachedMessage{MessageType: "Attached", DeviceID: 3, Properties: properties},
+		AttachedMessage{MessageType: "Paired", DeviceID: 3},
+		AttachedMessage{MessageType: "Unknown", DeviceID: 3},
+		AttachedMessage{MessageType: "Detached", DeviceID: 3},
+		AttachedMessage{MessageType: "Detached", DeviceID: 5},
+	)
+
+	subscription := deviceEventsFrom(context.Background(), receive, closeListener, func() time.Time { return now })
+
+	var events []DeviceEvent
+	for event := range subscription.C {
+		events = append(events, event)
+	}
+	attached := DeviceEvent{Type: EventDeviceAttached, Time: now, DeviceID: 3, Udid: "00008110-0001", ConnectionType: ConnectionTypeUSB, ConnectionSpeed: 480000000, ProductID: 4776, LocationID: 1}
+	paired, detached := attached, attached
+	paired.Type = EventDevicePaired
+	detached.Type = EventDeviceDetached
+	assert.Equal(t, []DeviceEvent{
+		attached,
+		paired,
+		detached,
+		{Type: EventDeviceDetached, Time: now, DeviceID: 5},
+	}, events)
+	assert.Equal(t, usbmuxdError, subscription.Err())
+	<-closed
+}
+
+func TestDeviceEventsCancel(t *testing.T) {
+	receive, closeListener, closed := fakeListener(nil,
+		AttachedMessage{MessageType: "Attached", DeviceID: 3, Properties: DeviceProperties{SerialNumber: "00008110-0001"}},
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	subscription := deviceEventsFrom(ctx, receive, closeListener, time.Now)
+	event := <-subscription.C
+	require.Equal(t, EventDeviceAttached, event.Type)
+	cancel()
+
+	_, ok := <-subscription.C
+	assert.False(t, ok, "the channel is closed when the context is done")
+	assert.NoError(t, subscription.Err())
+	<-closed
+}
@@ -0,0 +1,20 @@
+package ios_test
+
+import (
+	"testing"
+
+	ios "github.com/danielpaulus/go-ios/ios"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarketingName(t *testing.T) {
+	assert.Equal(t, "iPhone 13", ios.MarketingName("iPhone14,5"))
+	assert.Equal(t, "iPhone SE (2nd generation)", ios.MarketingName("iPhone12,8"))
+	assert.Equal(t, "iPad (9th generation)", ios.MarketingName("iPad12,1"))
+	assert.Equal(t, "iPad Pro (12.9-inch) (5th generation)", ios.MarketingName("iPad13,10"))
+	assert.Equal(t, "iPad Pro (11-inch) (M4)", ios.MarketingName("iPad16,3"))
+}
+
+func TestMarketingNameFallsBackToProductType(t *testing.T) {
+	assert.Equal(t, "iPhone99,1", ios.MarketingName("iPhone99,1"))
+}
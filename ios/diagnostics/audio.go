@@ -0,0 +1,78 @@
+package diagnostics
+
+import (
+	"errors"
+	"fmt"
+)
+
+// MobileGestalt keys for the audio state. They are not documented and newer iOS versions answer
+// them with MobileGestaltDeprecated, in that case the values are reported as unavailable.
+const (
+	gestaltRingerSwitchState = "RingerSwitchState"
+	gestaltRingerVolume      = "RingerVolume"
+)
+
+// RingerMode is the position of the ring/silent switch
+type RingerMode int
+
+const (
+	// RingerModeUnknown is returned if the device does not report the switch position
+	RingerModeUnknown RingerMode = iota
+	// RingerModeRing means sounds are played
+	RingerModeRing
+	// RingerModeSilent means the device is muted with the switch
+	RingerModeSilent
+)
+
+func (m RingerMode) String() string {
+	switch m {
+	case RingerModeRing:
+		return "ring"
+	case RingerModeSilent:
+		return "silent"
+	default:
+		return "unknown"
+	}
+}
+
+// AudioState contains the ringer volume and the position of the ring/silent switch
+type AudioState struct {
+	RingerMode RingerMode
+	// RingerVolume is the volume of the ringer between 0 and 1, only valid if RingerVolumeAvailable is true
+	RingerVolume          float64
+	RingerVolumeAvailable bool
+}
+
+// AudioState reads the ringer volume and the silent switch state from MobileGestalt. Devices without a ring/silent
+// switch or iOS versions that do not expose these values return RingerModeUnknown and an unavailable volume.
+func (diagnosticsConn *Connection) AudioState() (AudioState, error) {
+	return audioStateFromGestalt(diagnosticsConn.mobileGestalt([]string{gestaltRingerSwitchState, gestaltRingerVolume}))
+}
+
+// audioStateFromGestalt maps the result of the MobileGestalt query to AudioState. iOS 17.4 and later deprecate the
+// whole query, the state is unknown then.
+func audioStateFromGestalt(values map[string]interface{}, err error) (AudioState, error) {
+	if errors.Is(err, ErrMobileGestaltDeprecated) {
+		return AudioState{RingerMode: RingerModeUnknown}, nil
+	}
+	if err != nil {
+		return AudioState{}, fmt.Errorf("AudioState: %w", err)
+	}
+	state := AudioState{}
+	// the switch state is true if the ringer is on
+	switch ringerOn := values[gestaltRingerSwitchState].(type) {
+	case bool:
+		state.RingerMode = ringerMode(ringerOn)
+	case uint64:
+		state.RingerMode = ringerMode(ringerOn != 0)
+	}
+	state.RingerVolume, state.RingerVolumeAvailable = gestaltFloat(values, gestaltRingerVolume)
+	return state, nil
+}
+
+func ringerMode(ringerOn bool) RingerMode {
+	if ringerOn {
+		return RingerModeRing
+	}
+	return RingerModeSilent
+}
@@ -0,0 +1,91 @@
+package diagnostics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const audioStateResponseFixture = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Diagnostics</key>
+	<dict>
+		<key>MobileGestalt</key>
+		<dict>
+			<key>RingerSwitchState</key>
+			<false/>
+			<key>RingerVolume</key>
+			<real>0.625</real>
+			<key>Status</key>
+			<string>Success</string>
+		</dict>
+	</dict>
+	<key>Status</key>
+	<string>Success</string>
+</dict>
+</plist>`
+
+const audioStateDeprecatedResponseFixture = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Diagnostics</key>
+	<dict>
+		<key>MobileGestalt</key>
+		<dict>
+			<key>RingerSwitchState</key>
+			<string>MobileGestaltDeprecated</string>
+			<key>RingerVolume</key>
+			<string>MobileGestaltDeprecated</string>
+			<key>Status</key>
+			<string>Success</string>
+		</dict>
+	</dict>
+	<key>Status</key>
+	<string>Success</string>
+</dict>
+</plist>`
+
+// audioStateQueryDeprecatedResponseFixture is what iOS 17.4 and later answer for the whole query
+const audioStateQueryDeprecatedResponseFixture = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Diagnostics</key>
+	<dict>
+		<key>MobileGestalt</key>
+		<dict>
+			<key>Status</key>
+			<string>MobileGestaltDeprecated</string>
+		</dict>
+	</dict>
+	<key>Status</key>
+	<string>Success</string>
+</dict>
+</plist>`
+
+func TestAudioStateFromGestalt(t *testing.T) {
+	state, err := audioStateFromGestalt(parseGestaltResponse(t, audioStateResponseFixture))
+
+	assert.NoError(t, err)
+	assert.Equal(t, AudioState{RingerMode: RingerModeSilent, RingerVolume: 0.625, RingerVolumeAvailable: true}, state)
+	assert.Equal(t, "silent", state.RingerMode.String())
+}
+
+func TestAudioStateFromGestaltUnavailable(t *testing.T) {
+	state, err := audioStateFromGestalt(parseGestaltResponse(t, audioStateDeprecatedResponseFixture))
+
+	assert.NoError(t, err)
+	assert.Equal(t, AudioState{}, state)
+	assert.Equal(t, "unknown", state.RingerMode.String())
+}
+
+func TestAudioStateFromGestaltQueryDeprecated(t *testing.T) {
+	state, err := audioStateFromGestalt(parseGestaltResponse(t, audioStateQueryDeprecatedResponseFixture))
+
+	assert.NoError(t, err)
+	assert.Equal(t, RingerModeUnknown, state.RingerMode)
+	assert.False(t, state.RingerVolumeAvailable)
+}
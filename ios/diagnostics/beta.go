@@ -0,0 +1,79 @@
+package diagnostics
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	ios "github.com/danielpaulus/go-ios/ios"
+)
+
+// buildVersionRegex splits build versions like 21A329 or 21A5248v into the number after the train letter and the
+// optional lowercase suffix
+var buildVersionRegex = regexp.MustCompile(`^\d+[A-Z](\d+)([a-z]?)$`)
+
+// rapidSecurityResponseDigits is the minimum length of the build number of Rapid Security Responses, f.ex. 20E772520a,
+// which end with a lowercase letter like betas
+const rapidSecurityResponseDigits = 6
+
+// BuildInfo contains the build of iOS installed on the device and whether it is a beta
+type BuildInfo struct {
+	BuildVersion   string
+	ProductVersion string
+	// ReleaseType is reported by beta and internal builds, f.ex. "Beta", and empty for customer releases
+	ReleaseType string
+	Beta        bool
+}
+
+// GetBuildInfo reads the build and version of the installed iOS from lockdown and the release type from MobileGestalt
+// and reports whether it is a beta or developer seed
+func GetBuildInfo(device ios.DeviceEntry) (BuildInfo, error) {
+	values, err := ios.GetValues(device)
+	if err != nil {
+		return BuildInfo{}, fmt.Errorf("GetBuildInfo: failed reading values from lockdown: %w", err)
+	}
+	service, err := New(device)
+	if err != nil {
+		return BuildInfo{}, fmt.Errorf("GetBuildInfo: %w", err)
+	}
+	defer service.Close()
+	releaseType, err := releaseTypeFromGestalt(service.mobileGestalt([]string{"ReleaseType"}))
+	if err != nil {
+		return BuildInfo{}, fmt.Errorf("GetBuildInfo: %w", err)
+	}
+	return newBuildInfo(values.Value.BuildVersion, values.Value.ProductVersion, releaseType), nil
+}
+
+// releaseTypeFromGestalt returns the ReleaseType of the MobileGestalt query. Customer builds do not have a release
+// type, MobileGestalt answers with an empty value then. iOS 17.4 and later deprecate the query, the release type is
+// unknown and returned empty.
+func releaseTypeFromGestalt(values map[string]interface{}, err error) (string, error) {
+	if errors.Is(err, ErrMobileGestaltDeprecated) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	releaseType, _ := values["ReleaseType"].(string)
+	return releaseType, nil
+}
+
+func newBuildInfo(buildVersion string, productVersion string, releaseType string) BuildInfo {
+	return BuildInfo{
+		BuildVersion:   buildVersion,
+		ProductVersion: productVersion,
+		ReleaseType:    releaseType,
+		Beta:           releaseType == "Beta" || IsBetaBuild(buildVersion),
+	}
+}
+
+// IsBetaBuild returns true for build versions of betas and developer seeds, which end with a lowercase letter like
+// 21A5248v. Releases like 21A329 and Rapid Security Responses like 20E772520a are not betas.
+func IsBetaBuild(buildVersion string) bool {
+	match := buildVersionRegex.FindStringSubmatch(buildVersion)
+	if match == nil {
+		return false
+	}
+	number, suffix := match[1], match[2]
+	return suffix != "" && len(number) < rapidSecurityResponseDigits
+}
@@ -0,0 +1,78 @@
+package diagnostics
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const releaseTypeResponseFixture = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Diagnostics</key>
+	<dict>
+		<key>MobileGestalt</key>
+		<dict>
+			<key>ReleaseType</key>
+			%s
+			<key>Status</key>
+			<string>Success</string>
+		</dict>
+	</dict>
+	<key>Status</key>
+	<string>Success</string>
+</dict>
+</plist>`
+
+func TestIsBetaBuild(t *testing.T) {
+	tests := map[string]bool{
+		"21A329":      false,
+		"20G75":       false,
+		"18A8395":     false,
+		"21A5248v":    true,
+		"22A5282m":    true,
+		"21E5184i":    true,
+		"20E772520a":  false,
+		"":            false,
+		"not-a-build": false,
+	}
+	for build, beta := range tests {
+		assert.Equal(t, beta, IsBetaBuild(build), build)
+	}
+}
+
+func TestReleaseTypeFromGestalt(t *testing.T) {
+	tests := map[string]struct {
+		response    string
+		releaseType string
+	}{
+		"beta":                  {fmt.Sprintf(releaseTypeResponseFixture, "<string>Beta</string>"), "Beta"},
+		"customer release":      {fmt.Sprintf(releaseTypeResponseFixture, "<string></string>"), ""},
+		"deprecated key":        {fmt.Sprintf(releaseTypeResponseFixture, "<string>MobileGestaltDeprecated</string>"), ""},
+		"deprecated since 17.4": {audioStateQueryDeprecatedResponseFixture, ""},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			releaseType, err := releaseTypeFromGestalt(parseGestaltResponse(t, tc.response))
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.releaseType, releaseType)
+		})
+	}
+}
+
+func TestNewBuildInfo(t *testing.T) {
+	t.Run("beta", func(t *testing.T) {
+		info := newBuildInfo("21A5248v", "17.0", "Beta")
+		assert.Equal(t, BuildInfo{BuildVersion: "21A5248v", ProductVersion: "17.0", ReleaseType: "Beta", Beta: true}, info)
+	})
+	t.Run("release", func(t *testing.T) {
+		info := newBuildInfo("21A329", "17.0", "")
+		assert.Equal(t, BuildInfo{BuildVersion: "21A329", ProductVersion: "17.0"}, info)
+	})
+	t.Run("beta build number without release type", func(t *testing.T) {
+		assert.True(t, newBuildInfo("21A5248v", "17.0", "").Beta)
+	})
+}
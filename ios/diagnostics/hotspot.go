@@ -0,0 +1,52 @@
+package diagnostics
+
+import "fmt"
+
+// gestaltPersonalHotspot is the MobileGestalt capability that is true if the device and its carrier allow
+// sharing the cellular connection
+const gestaltPersonalHotspot = "personal-hotspot"
+
+// HotspotState is the personal hotspot state of the device
+type HotspotState int
+
+const (
+	// HotspotStateUnknown is returned if the device does not report the capability
+	HotspotStateUnknown HotspotState = iota
+	// HotspotStateUnsupported means the device or its carrier do not allow a personal hotspot
+	HotspotStateUnsupported
+	// HotspotStateAvailable means the personal hotspot can be turned on in the settings of the device
+	HotspotStateAvailable
+)
+
+func (s HotspotState) String() string {
+	switch s {
+	case HotspotStateUnsupported:
+		return "unsupported"
+	case HotspotStateAvailable:
+		return "available"
+	default:
+		return "unknown"
+	}
+}
+
+// HotspotState reports whether the device allows a personal hotspot. iOS does not expose whether the hotspot is
+// turned on to a connected host and it cannot be turned on or off remotely, so this is the only state go-ios reads.
+func (diagnosticsConn *Connection) HotspotState() (HotspotState, error) {
+	values, err := diagnosticsConn.mobileGestalt([]string{gestaltPersonalHotspot})
+	if err != nil {
+		return HotspotStateUnknown, fmt.Errorf("HotspotState: %w", err)
+	}
+	return hotspotStateFromGestalt(values), nil
+}
+
+func hotspotStateFromGestalt(values map[string]interface{}) HotspotState {
+	supported, ok := values[gestaltPersonalHotspot].(bool)
+	switch {
+	case !ok:
+		return HotspotStateUnknown
+	case supported:
+		return HotspotStateAvailable
+	default:
+		return HotspotStateUnsupported
+	}
+}
@@ -0,0 +1,45 @@
+package diagnostics
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const hotspotResponseFixture = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Diagnostics</key>
+	<dict>
+		<key>MobileGestalt</key>
+		<dict>
+			<key>Status</key>
+			<string>Success</string>
+			<key>personal-hotspot</key>
+			%s
+		</dict>
+	</dict>
+	<key>Status</key>
+	<string>Success</string>
+</dict>
+</plist>`
+
+func TestHotspotStateFromGestalt(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected HotspotState
+	}{
+		{"<true/>", HotspotStateAvailable},
+		{"<false/>", HotspotStateUnsupported},
+		{"<string>MobileGestaltDeprecated</string>", HotspotStateUnknown},
+	}
+	for _, tc := range tests {
+		t.Run(tc.expected.String(), func(t *testing.T) {
+			state := hotspotStateFromGestalt(gestaltFixture(t, fmt.Sprintf(hotspotResponseFixture, tc.value)))
+
+			assert.Equal(t, tc.expected, state)
+		})
+	}
+}
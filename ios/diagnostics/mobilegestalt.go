@@ -1,6 +1,19 @@
 package diagnostics
 
-import ios "github.com/danielpaulus/go-ios/ios"
+import (
+	"errors"
+	"fmt"
+
+	ios "github.com/danielpaulus/go-ios/ios"
+)
+
+// gestaltDeprecated is the status and value the device answers MobileGestalt queries with that it does not support
+// anymore. iOS 17.4 and later deprecate the whole query.
+const gestaltDeprecated = "MobileGestaltDeprecated"
+
+// ErrMobileGestaltDeprecated is returned if the device does not answer MobileGestalt queries anymore, which is the
+// case from iOS 17.4 on
+var ErrMobileGestaltDeprecated = errors.New("MobileGestalt is deprecated on this device")
 
 func gestaltRequest(keys []string) []byte {
 	goodbyeMap := map[string]interface{}{
@@ -26,3 +39,70 @@ func (diagnosticsConn *Connection) MobileGestaltQuery(keys []string) (interface{
 	plist, err := ios.ParsePlist(respBytes)
 	return plist, err
 }
+
+// mobileGestalt queries keys with MobileGestaltQuery and returns the values of the MobileGestalt dict of the
+// response. Keys the device answers with MobileGestaltDeprecated are left out, if it deprecated the whole query
+// ErrMobileGestaltDeprecated is returned.
+func (diagnosticsConn *Connection) mobileGestalt(keys []string) (map[string]interface{}, error) {
+	response, err := diagnosticsConn.MobileGestaltQuery(keys)
+	if err != nil {
+		return nil, err
+	}
+	return gestaltValues(response)
+}
+
+// gestaltValues extracts the values of a MobileGestaltQuery response, see mobileGestalt
+func gestaltValues(response interface{}) (map[string]interface{}, error) {
+	responseMap, ok := response.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("MobileGestalt: unexpected response %+v", response)
+	}
+	if status := responseMap["Status"]; status != "Success" {
+		return nil, fmt.Errorf("MobileGestalt: request failed with status '%v'", status)
+	}
+	diagnostics, _ := responseMap["Diagnostics"].(map[string]interface{})
+	gestalt, _ := diagnostics["MobileGestalt"].(map[string]interface{})
+	switch status := gestalt["Status"]; status {
+	case "Success":
+	case gestaltDeprecated:
+		return nil, ErrMobileGestaltDeprecated
+	default:
+		return nil, fmt.Errorf("MobileGestalt: query failed with status '%v'", status)
+	}
+	values := make(map[string]interface{}, len(gestalt))
+	for key, value := range gestalt {
+		if key == "Status" || value == gestaltDeprecated {
+			continue
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// gestaltUint returns the integer value of key, it is false if the value is missing or not a number
+func gestaltUint(values map[string]interface{}, key string) (uint64, bool) {
+	switch value := values[key].(type) {
+	case uint64:
+		return value, true
+	case int64:
+		return uint64(value), value >= 0
+	case float64:
+		return uint64(value), value >= 0
+	default:
+		return 0, false
+	}
+}
+
+// gestaltFloat returns the number value of key, it is false if the value is missing or not a number
+func gestaltFloat(values map[string]interface{}, key string) (float64, bool) {
+	switch value := values[key].(type) {
+	case float64:
+		return value, true
+	case uint64:
+		return float64(value), true
+	case int64:
+		return float64(value), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,53 @@
+package diagnostics
+
+import (
+	"testing"
+
+	ios "github.com/danielpaulus/go-ios/ios"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// parseGestaltResponse parses a MobileGestalt response fixture like MobileGestaltQuery does and returns its values
+func parseGestaltResponse(t *testing.T, response string) (map[string]interface{}, error) {
+	parsed, err := ios.ParsePlist([]byte(response))
+	require.NoError(t, err)
+	return gestaltValues(parsed)
+}
+
+// gestaltFixture returns the values of a successful MobileGestalt response fixture
+func gestaltFixture(t *testing.T, response string) map[string]interface{} {
+	values, err := parseGestaltResponse(t, response)
+	require.NoError(t, err)
+	return values
+}
+
+func TestGestaltValues(t *testing.T) {
+	values := gestaltFixture(t, audioStateDeprecatedResponseFixture)
+	assert.Empty(t, values, "values answered with MobileGestaltDeprecated and the status must be left out")
+
+	values = gestaltFixture(t, screenResponseFixture)
+	assert.Equal(t, map[string]interface{}{
+		"main-screen-height": uint64(2532),
+		"main-screen-scale":  float64(3),
+		"main-screen-width":  uint64(1170),
+	}, values)
+}
+
+func TestGestaltValuesFailedRequest(t *testing.T) {
+	_, err := parseGestaltResponse(t, `<?xml version="1.0" encoding="UTF-8"?>
+<plist version="1.0">
+<dict>
+	<key>Status</key>
+	<string>UnknownRequest</string>
+</dict>
+</plist>`)
+
+	assert.EqualError(t, err, "MobileGestalt: request failed with status 'UnknownRequest'")
+}
+
+func TestGestaltValuesDeprecated(t *testing.T) {
+	_, err := parseGestaltResponse(t, audioStateQueryDeprecatedResponseFixture)
+
+	assert.ErrorIs(t, err, ErrMobileGestaltDeprecated)
+}
@@ -0,0 +1,40 @@
+package diagnostics
+
+import "fmt"
+
+// ScreenInfo contains the resolution of the main screen of the device
+type ScreenInfo struct {
+	// WidthPixels and HeightPixels are the native resolution of the screen in portrait orientation
+	WidthPixels  uint64
+	HeightPixels uint64
+	// WidthPoints and HeightPoints are the size of the screen in points as used by UIKit and XCUITest coordinates
+	WidthPoints  float64
+	HeightPoints float64
+	// Scale is the number of pixels per point, f.ex. 2 or 3
+	Scale float64
+}
+
+// ScreenInfo reads the resolution and scale factor of the main screen from MobileGestalt
+func (diagnosticsConn *Connection) ScreenInfo() (ScreenInfo, error) {
+	values, err := diagnosticsConn.mobileGestalt([]string{"main-screen-width", "main-screen-height", "main-screen-scale"})
+	if err != nil {
+		return ScreenInfo{}, fmt.Errorf("ScreenInfo: %w", err)
+	}
+	return screenInfoFromGestalt(values)
+}
+
+func screenInfoFromGestalt(values map[string]interface{}) (ScreenInfo, error) {
+	width, _ := gestaltUint(values, "main-screen-width")
+	height, _ := gestaltUint(values, "main-screen-height")
+	scale, _ := gestaltFloat(values, "main-screen-scale")
+	if scale <= 0 {
+		return ScreenInfo{}, fmt.Errorf("ScreenInfo: invalid screen scale %v", scale)
+	}
+	return ScreenInfo{
+		WidthPixels:  width,
+		HeightPixels: height,
+		WidthPoints:  float64(width) / scale,
+		HeightPoints: float64(height) / scale,
+		Scale:        scale,
+	}, nil
+}
@@ -0,0 +1,43 @@
+package diagnostics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const screenResponseFixture = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Diagnostics</key>
+	<dict>
+		<key>MobileGestalt</key>
+		<dict>
+			<key>Status</key>
+			<string>Success</string>
+			<key>main-screen-height</key>
+			<integer>2532</integer>
+			<key>main-screen-scale</key>
+			<real>3</real>
+			<key>main-screen-width</key>
+			<integer>1170</integer>
+		</dict>
+	</dict>
+	<key>Status</key>
+	<string>Success</string>
+</dict>
+</plist>`
+
+func TestScreenInfoFromGestalt(t *testing.T) {
+	info, err := screenInfoFromGestalt(gestaltFixture(t, screenResponseFixture))
+
+	assert.NoError(t, err)
+	assert.Equal(t, ScreenInfo{
+		WidthPixels:  1170,
+		HeightPixels: 2532,
+		WidthPoints:  390,
+		HeightPoints: 844,
+		Scale:        3,
+	}, info)
+}
@@ -0,0 +1,73 @@
+package diagnostics
+
+import (
+	"fmt"
+
+	ios "github.com/danielpaulus/go-ios/ios"
+	log "github.com/sirupsen/logrus"
+)
+
+// StorageInfo contains the capacity and usage of the device's volumes in bytes
+type StorageInfo struct {
+	// TotalDiskCapacity is the size of the whole disk
+	TotalDiskCapacity uint64
+	// SystemCapacity and SystemAvailable describe the read only system volume
+	SystemCapacity  uint64
+	SystemAvailable uint64
+	// DataCapacity is the size of the data volume that holds apps and user data
+	DataCapacity uint64
+	// DataAvailable is the space on the data volume that is free right now
+	DataAvailable uint64
+	// DataPurgeable is the space occupied by data like caches that iOS deletes when space is needed.
+	// DataAvailable+DataPurgeable is the space that can be used for installing apps.
+	DataPurgeable uint64
+	// DataReserved is the space iOS keeps free for itself and that is not available to apps
+	DataReserved uint64
+}
+
+// GetStorageInfo reads the disk usage of the device from the lockdown domain ios.DiskUsageDomain. Other than the free
+// bytes reported by AFC it includes the capacity of the volumes and the space that is occupied by purgeable data.
+// If lockdown does not report the disk usage, it is read from MobileGestalt.
+func GetStorageInfo(device ios.DeviceEntry) (StorageInfo, error) {
+	usage, err := ios.GetDeviceCapabilities(device, ios.DiskUsageDomain)
+	if err == nil && usage.Has("TotalDiskCapacity") {
+		return storageInfoFromDiskUsage(usage), nil
+	}
+	log.WithError(err).Debug("GetStorageInfo: lockdown did not report the disk usage, falling back to MobileGestalt")
+	service, err := New(device)
+	if err != nil {
+		return StorageInfo{}, fmt.Errorf("GetStorageInfo: %w", err)
+	}
+	defer service.Close()
+	return service.StorageInfo()
+}
+
+// StorageInfo reads the disk usage of the device from MobileGestalt. iOS 17.4 and later deprecated the query and
+// return ErrMobileGestaltDeprecated, use GetStorageInfo instead.
+func (diagnosticsConn *Connection) StorageInfo() (StorageInfo, error) {
+	values, err := diagnosticsConn.mobileGestalt([]string{"DiskUsage"})
+	if err != nil {
+		return StorageInfo{}, fmt.Errorf("StorageInfo: %w", err)
+	}
+	usage, ok := values["DiskUsage"].(map[string]interface{})
+	if !ok {
+		return StorageInfo{}, fmt.Errorf("StorageInfo: the device did not report DiskUsage")
+	}
+	return storageInfoFromDiskUsage(usage), nil
+}
+
+// storageInfoFromDiskUsage converts the disk usage values of lockdown or MobileGestalt into StorageInfo
+func storageInfoFromDiskUsage(usage map[string]interface{}) StorageInfo {
+	info := StorageInfo{}
+	info.TotalDiskCapacity, _ = gestaltUint(usage, "TotalDiskCapacity")
+	info.SystemCapacity, _ = gestaltUint(usage, "TotalSystemCapacity")
+	info.SystemAvailable, _ = gestaltUint(usage, "TotalSystemAvailable")
+	info.DataCapacity, _ = gestaltUint(usage, "TotalDataCapacity")
+	info.DataAvailable, _ = gestaltUint(usage, "AmountDataAvailable")
+	info.DataReserved, _ = gestaltUint(usage, "AmountDataReserved")
+	// TotalDataAvailable includes the purgeable space, AmountDataAvailable does not
+	if totalDataAvailable, _ := gestaltUint(usage, "TotalDataAvailable"); totalDataAvailable > info.DataAvailable {
+		info.DataPurgeable = totalDataAvailable - info.DataAvailable
+	}
+	return info
+}
@@ -0,0 +1,81 @@
+package diagnostics
+
+import (
+	"testing"
+
+	ios "github.com/danielpaulus/go-ios/ios"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const diskUsageResponseFixture = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Diagnostics</key>
+	<dict>
+		<key>MobileGestalt</key>
+		<dict>
+			<key>DiskUsage</key>
+			<dict>
+				<key>AmountDataAvailable</key>
+				<integer>41502347264</integer>
+				<key>AmountDataReserved</key>
+				<integer>209715200</integer>
+				<key>CalculateDiskUsage</key>
+				<string>DEPRECATED</string>
+				<key>TotalDataAvailable</key>
+				<integer>45797314560</integer>
+				<key>TotalDataCapacity</key>
+				<integer>113067094016</integer>
+				<key>TotalDiskCapacity</key>
+				<integer>128000000000</integer>
+				<key>TotalSystemAvailable</key>
+				<integer>0</integer>
+				<key>TotalSystemCapacity</key>
+				<integer>11306709401</integer>
+			</dict>
+			<key>Status</key>
+			<string>Success</string>
+		</dict>
+	</dict>
+	<key>Status</key>
+	<string>Success</string>
+</dict>
+</plist>`
+
+func TestStorageInfoFromDiskUsage(t *testing.T) {
+	usage, ok := gestaltFixture(t, diskUsageResponseFixture)["DiskUsage"].(map[string]interface{})
+	require.True(t, ok)
+
+	info := storageInfoFromDiskUsage(usage)
+
+	assert.Equal(t, StorageInfo{
+		TotalDiskCapacity: 128000000000,
+		SystemCapacity:    11306709401,
+		SystemAvailable:   0,
+		DataCapacity:      113067094016,
+		DataAvailable:     41502347264,
+		DataPurgeable:     4294967296,
+		DataReserved:      209715200,
+	}, info)
+}
+
+func TestStorageInfoFromLockdownDiskUsage(t *testing.T) {
+	usage, err := ios.DeviceCapabilitiesFromValue(ios.DiskUsageDomain, map[string]interface{}{
+		"AmountDataAvailable":  uint64(41502347264),
+		"AmountDataReserved":   uint64(209715200),
+		"TotalDataAvailable":   uint64(45797314560),
+		"TotalDataCapacity":    uint64(113067094016),
+		"TotalDiskCapacity":    uint64(128000000000),
+		"TotalSystemAvailable": uint64(0),
+		"TotalSystemCapacity":  uint64(11306709401),
+	})
+	require.NoError(t, err)
+
+	info := storageInfoFromDiskUsage(usage)
+
+	assert.Equal(t, uint64(128000000000), info.TotalDiskCapacity)
+	assert.Equal(t, uint64(41502347264), info.DataAvailable)
+	assert.Equal(t, uint64(4294967296), info.DataPurgeable)
+}
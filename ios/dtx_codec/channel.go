@@ -105,6 +105,13 @@ func (d *Channel) Send(expectsReply bool, messageType MessageType, payloadBytes
 	return d.connection.Send(bytes)
 }
 
+// state returns the code, the name and the number of pending replies of the channel
+func (d *Channel) state() ChannelState {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return ChannelState{Code: d.channelCode, Name: d.channelName, PendingReplies: len(d.responseWaiters)}
+}
+
 func (d *Channel) AddResponseWaiter(identifier int, channel chan Message) {
 	d.mutex.Lock()
 	defer d.mutex.Unlock()
@@ -5,8 +5,10 @@ import (
 	"errors"
 	"io"
 	"math"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/danielpaulus/go-ios/ios"
@@ -41,6 +43,8 @@ type Connection struct {
 	closed    chan struct{}
 	err       error
 	closeOnce sync.Once
+	// lastReceived is the time in unix nanoseconds the last message was received, see LastMessageReceived
+	lastReceived atomic.Int64
 }
 
 // Dispatcher is a simple interface containing a Dispatch func to receive dtx.Messages
@@ -67,6 +71,33 @@ func (dtxConn *Connection) Err() error {
 	return dtxConn.err
 }
 
+// LastMessageReceived returns the time the last message was received on the connection, or the time the connection
+// was created if no message was received yet
+func (dtxConn *Connection) LastMessageReceived() time.Time {
+	return time.Unix(0, dtxConn.lastReceived.Load())
+}
+
+// ChannelState describes an open channel of a Connection, f.ex. to log it when the device stops responding
+type ChannelState struct {
+	Code int
+	Name string
+	// PendingReplies is the number of messages sent on the channel that are still waiting for a reply
+	PendingReplies int
+}
+
+// Channels returns the state of the global channel and all channels opened on the connection ordered by their code
+func (dtxConn *Connection) Channels() []ChannelState {
+	channels := []ChannelState{dtxConn.globalChannel.state()}
+	dtxConn.activeChannels.Range(func(_, value any) bool {
+		channels = append(channels, value.(*Channel).state())
+		return true
+	})
+	sort.SliceStable(channels, func(i, j int) bool {
+		return channels[i].Code < channels[j].Code
+	})
+	return channels
+}
+
 // Close closes the underlying deviceConnection
 func (dtxConn *Connection) Close() error {
 	if dtxConn.deviceConnection != nil {
@@ -124,6 +155,10 @@ func (g GlobalDispatcher) Dispatch(msg Message) {
 					"time": msg.Auxiliary.GetArguments()[2],
 				}).Debug("outputReceived:fromProcess:atTime:")
 			}
+			// services attaching to a process receive its output with a custom MessageDispatcher
+			if g.dtxConnection.MessageDispatcher != nil {
+				g.dtxConnection.Dispatch(msg)
+			}
 			return
 		}
 	}
@@ -160,12 +195,18 @@ func NewTunnelConnection(device ios.DeviceEntry, serviceName string) (*Connectio
 	return newDtxConnection(conn)
 }
 
+// NewConnection starts reading from a Dtx based service on an already established connection to the device
+func NewConnection(conn ios.DeviceConnectionInterface) (*Connection, error) {
+	return newDtxConnection(conn)
+}
+
 func newDtxConnection(conn ios.DeviceConnectionInterface) (*Connection, error) {
 	requestChannelMessages := make(chan Message, 5)
 
 	// The global channel has channelCode 0, so we need to start with channelCodeCounter==1
 	dtxConnection := &Connection{deviceConnection: conn, channelCodeCounter: 1, requestChannelMessages: requestChannelMessages}
 	dtxConnection.closed = make(chan struct{})
+	dtxConnection.lastReceived.Store(time.Now().UnixNano())
 
 	// The global channel is automatically present and used for requesting other channels and some other methods like notifyPublishedCapabilities
 	globalChannel := Channel{
@@ -203,6 +244,7 @@ func reader(dtxConn *Connection) {
 			log.Errorf("error reading dtx connection %+v", err)
 			return
 		}
+		dtxConn.lastReceived.Store(time.Now().UnixNano())
 		if _channel, ok := dtxConn.activeChannels.Load(msg.ChannelCode); ok {
 			channel := _channel.(*Channel)
 			channel.Dispatch(msg)
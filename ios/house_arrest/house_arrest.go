@@ -117,6 +117,26 @@ func (conn *Connection) ListFiles(filePath string) ([]string, error) {
 	return strings.Split(fileList, string([]byte{0})), nil
 }
 
+// ReadFile returns the contents of the file at filePath, relative to the vended app container
+func (conn *Connection) ReadFile(filePath string) ([]byte, error) {
+	return afc.NewFromConn(conn.deviceConn).ReadFile(filePath)
+}
+
+// Remove deletes the file or empty directory at filePath, relative to the vended app container
+func (conn *Connection) Remove(filePath string) error {
+	return afc.NewFromConn(conn.deviceConn).Remove(filePath)
+}
+
+// MkDir creates the directory at dirPath, relative to the vended app container
+func (conn *Connection) MkDir(dirPath string) error {
+	return afc.NewFromConn(conn.deviceConn).MkDir(dirPath)
+}
+
+// RemoveAll deletes the file or directory at filePath with all its contents, relative to the vended app container
+func (conn *Connection) RemoveAll(filePath string) error {
+	return afc.NewFromConn(conn.deviceConn).RemoveAll(filePath)
+}
+
 func (conn *Connection) openFileForWriting(filePath string) (byte, error) {
 	pathBytes := []byte(filePath)
 	headerLength := 8 + uint64(len(pathBytes))
@@ -0,0 +1,48 @@
+package installationproxy
+
+import "sort"
+
+// appGroupsEntitlement lists the app groups an app shares containers with
+const appGroupsEntitlement = "com.apple.security.application-groups"
+
+// AppGroups returns the app group identifiers from the entitlements of the app, f.ex. "group.com.example.shared"
+func (a AppInfo) AppGroups() []string {
+	groups, ok := a.Entitlements[appGroupsEntitlement].([]interface{})
+	if !ok {
+		return []string{}
+	}
+	result := make([]string, 0, len(groups))
+	for _, group := range groups {
+		if id, ok := group.(string); ok {
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+// AppGroupContainer is the shared container of an app group
+type AppGroupContainer struct {
+	Identifier string
+	// Path of the shared container on the device, empty if installd did not report it
+	Path string
+}
+
+// AppGroupContainers returns the shared containers of the app groups of the app sorted by identifier. The paths are
+// only informational, house_arrest vends app containers only, so the shared containers cannot be accessed through it.
+func (a AppInfo) AppGroupContainers() []AppGroupContainer {
+	containers := []AppGroupContainer{}
+	seen := map[string]struct{}{}
+	for _, group := range a.AppGroups() {
+		seen[group] = struct{}{}
+		containers = append(containers, AppGroupContainer{Identifier: group, Path: a.GroupContainers[group]})
+	}
+	for group, path := range a.GroupContainers {
+		if _, ok := seen[group]; !ok {
+			containers = append(containers, AppGroupContainer{Identifier: group, Path: path})
+		}
+	}
+	sort.Slice(containers, func(i, j int) bool {
+		return containers[i].Identifier < containers[j].Identifier
+	})
+	return containers
+}
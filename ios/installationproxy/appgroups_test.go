@@ -0,0 +1,34 @@
+package installationproxy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppGroups(t *testing.T) {
+	app := AppInfo{
+		CFBundleIdentifier: "com.example.app",
+		Entitlements: map[string]interface{}{
+			"application-identifier":                "TEAMID.com.example.app",
+			"com.apple.security.application-groups": []interface{}{"group.com.example.shared", "group.com.example.widgets"},
+			"get-task-allow":                        true,
+		},
+		GroupContainers: map[string]string{
+			"group.com.example.shared": "/private/var/mobile/Containers/Shared/AppGroup/1A2B3C4D-0000-0000-0000-000000000000",
+		},
+	}
+
+	assert.Equal(t, []string{"group.com.example.shared", "group.com.example.widgets"}, app.AppGroups())
+	assert.Equal(t, []AppGroupContainer{
+		{Identifier: "group.com.example.shared", Path: "/private/var/mobile/Containers/Shared/AppGroup/1A2B3C4D-0000-0000-0000-000000000000"},
+		{Identifier: "group.com.example.widgets"},
+	}, app.AppGroupContainers())
+}
+
+func TestAppGroupsWithoutEntitlement(t *testing.T) {
+	app := AppInfo{Entitlements: map[string]interface{}{"get-task-allow": true}}
+
+	assert.Equal(t, []string{}, app.AppGroups())
+	assert.Equal(t, []AppGroupContainer{}, app.AppGroupContainers())
+}
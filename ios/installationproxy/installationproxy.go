@@ -2,7 +2,9 @@ package installationproxy
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
@@ -73,6 +75,32 @@ func (conn *Connection) browseApps(request interface{}) ([]AppInfo, error) {
 	return appinfos, nil
 }
 
+// appInstalledPollInterval is the time WaitForAppInstalled waits between two lookups
+var appInstalledPollInterval = 500 * time.Millisecond
+
+// WaitForAppInstalled polls installation_proxy until the app with the given bundleID is listed and returns its AppInfo.
+// Right after an install finished, the app might not be listed yet. Waiting for it prevents launches from racing the install.
+// Use a context with a timeout or deadline to limit the time to wait.
+func (conn *Connection) WaitForAppInstalled(ctx context.Context, bundleID string) (AppInfo, error) {
+	for {
+		apps, err := conn.browseApps(browseBundleIDs([]string{bundleID}))
+		if err != nil {
+			return AppInfo{}, fmt.Errorf("WaitForAppInstalled: failed browsing apps: %w", err)
+		}
+		for _, app := range apps {
+			if app.CFBundleIdentifier == bundleID {
+				return app, nil
+			}
+		}
+		log.WithField("bundleID", bundleID).Debug("app is not installed yet")
+		select {
+		case <-ctx.Done():
+			return AppInfo{}, fmt.Errorf("WaitForAppInstalled: app %s was not installed: %w", bundleID, ctx.Err())
+		case <-time.After(appInstalledPollInterval):
+		}
+	}
+}
+
 func (c *Connection) Uninstall(bundleId string) error {
 	options := map[string]interface{}{}
 	uninstallCommand := map[string]interface{}{
@@ -146,6 +174,7 @@ func browseApps(applicationType string, showLaunchProhibitedApps bool) map[strin
 		"Container",
 		"Entitlements",
 		"EnvironmentVariables",
+		"GroupContainers",
 		"MinimumOSVersion",
 		"Path",
 		"ProfileValidated",
@@ -167,6 +196,13 @@ func browseApps(applicationType string, showLaunchProhibitedApps bool) map[strin
 	return map[string]interface{}{"ClientOptions": clientOptions, "Command": "Browse"}
 }
 
+// browseBundleIDs creates a browse request for all types of apps that only returns the apps with the given bundleIDs
+func browseBundleIDs(bundleIDs []string) map[string]interface{} {
+	request := browseApps("", true)
+	request["ClientOptions"].(map[string]interface{})["BundleIDs"] = bundleIDs
+	return request
+}
+
 type BrowseResponse struct {
 	CurrentIndex  uint64
 	CurrentAmount uint64
@@ -185,6 +221,7 @@ type AppInfo struct {
 	Container                    string
 	Entitlements                 map[string]interface{}
 	EnvironmentVariables         map[string]interface{}
+	GroupContainers              map[string]string
 	MinimumOSVersion             string
 	Path                         string
 	ProfileValidated             bool
@@ -0,0 +1,85 @@
+package installationproxy
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	ios "github.com/danielpaulus/go-ios/ios"
+	"github.com/stretchr/testify/assert"
+)
+
+// serveBrowse answers browse requests with an empty app list until absentResponses requests were answered,
+// afterwards the app with bundleID is listed.
+func serveBrowse(conn net.Conn, bundleID string, absentResponses int, requests chan<- map[string]interface{}) {
+	defer conn.Close()
+	codec := ios.NewPlistCodec()
+	for i := 0; ; i++ {
+		request, err := codec.Decode(conn)
+		if err != nil {
+			return
+		}
+		parsed, _ := ios.ParsePlist(request)
+		requests <- parsed
+		response := BrowseResponse{Status: "Complete", CurrentList: []AppInfo{}}
+		if i >= absentResponses {
+			response.CurrentAmount = 1
+			response.CurrentList = []AppInfo{{CFBundleIdentifier: bundleID, Path: "/private/var/containers/Bundle/Application/test.app"}}
+		}
+		encoded, err := codec.Encode(response)
+		if err != nil {
+			return
+		}
+		if _, err := conn.Write(encoded); err != nil {
+			return
+		}
+	}
+}
+
+func newMockConnection(t *testing.T, bundleID string, absentResponses int) (*Connection, chan map[string]interface{}) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	client, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	device, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests := make(chan map[string]interface{}, 100)
+	go serveBrowse(device, bundleID, absentResponses, requests)
+	t.Cleanup(func() { client.Close() })
+	return &Connection{deviceConn: ios.NewDeviceConnectionWithRWC(client), plistCodec: ios.NewPlistCodec()}, requests
+}
+
+func TestWaitForAppInstalled(t *testing.T) {
+	appInstalledPollInterval = time.Millisecond
+
+	t.Run("returns once the app is listed", func(t *testing.T) {
+		conn, requests := newMockConnection(t, "com.example.app", 2)
+
+		app, err := conn.WaitForAppInstalled(context.Background(), "com.example.app")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "com.example.app", app.CFBundleIdentifier)
+		assert.Len(t, requests, 3, "the app should be looked up until it is listed")
+		request := <-requests
+		clientOptions := request["ClientOptions"].(map[string]interface{})
+		assert.Equal(t, []interface{}{"com.example.app"}, clientOptions["BundleIDs"])
+	})
+
+	t.Run("fails when the context expires", func(t *testing.T) {
+		conn, _ := newMockConnection(t, "com.example.app", 1000)
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		_, err := conn.WaitForAppInstalled(ctx, "com.example.app")
+
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
@@ -0,0 +1,113 @@
+package instruments
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/danielpaulus/go-ios/ios"
+	"github.com/danielpaulus/go-ios/ios/nskeyedarchiver"
+	log "github.com/sirupsen/logrus"
+)
+
+// AppState is the simplified state of an app derived from the state_description of application state notifications
+type AppState string
+
+const (
+	AppStateForeground AppState = "foreground"
+	AppStateBackground AppState = "background"
+	AppStateSuspended  AppState = "suspended"
+	AppStateTerminated AppState = "terminated"
+	AppStateUnknown    AppState = "unknown"
+)
+
+// AppStateTransition is emitted when an app changes its state
+type AppStateTransition struct {
+	BundleID string
+	Pid      uint64
+	State    AppState
+	// Description is the state as reported by the device, f.ex. "Foreground Running"
+	Description string
+	Timestamp   time.Time
+}
+
+// StreamAppStateTransitions listens to the application state notifications of instruments and emits the state
+// changes of the app with bundleID. Notifications that do not change the state are dropped. The returned channel
+// is closed when ctx is cancelled or the connection fails.
+func StreamAppStateTransitions(ctx context.Context, device ios.DeviceEntry, bundleID string) (<-chan AppStateTransition, error) {
+	receive, closeFunc, err := ListenAppStateNotifications(device)
+	if err != nil {
+		return nil, err
+	}
+	transitions := make(chan AppStateTransition, 10)
+	stop := context.AfterFunc(ctx, func() {
+		if err := closeFunc(); err != nil {
+			log.WithError(err).Debug("failed closing app state notifications")
+		}
+	})
+	go func() {
+		defer stop()
+		streamAppStateTransitions(ctx, receive, bundleID, transitions)
+	}()
+	return transitions, nil
+}
+
+// streamAppStateTransitions reads notifications with receive until it fails and sends the state changes of bundleID
+// to transitions, which is closed afterwards
+func streamAppStateTransitions(ctx context.Context, receive func() (map[string]interface{}, error), bundleID string, transitions chan<- AppStateTransition) {
+	defer close(transitions)
+	lastState := AppState("")
+	for {
+		notification, err := receive()
+		if err != nil {
+			log.WithError(err).Debug("app state notifications stopped")
+			return
+		}
+		transition, ok := appStateTransitionFromNotification(notification)
+		if !ok || transition.BundleID != bundleID || transition.State == lastState {
+			continue
+		}
+		lastState = transition.State
+		select {
+		case transitions <- transition:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func appStateTransitionFromNotification(notification map[string]interface{}) (AppStateTransition, bool) {
+	bundleID, ok := notification["displayID"].(string)
+	if !ok {
+		return AppStateTransition{}, false
+	}
+	description, _ := notification["state_description"].(string)
+	transition := AppStateTransition{
+		BundleID:    bundleID,
+		State:       appStateFromDescription(description),
+		Description: description,
+	}
+	transition.Pid, _ = notification["pid"].(uint64)
+	if date, ok := notification["timestamp"].(nskeyedarchiver.NSDate); ok {
+		transition.Timestamp = date.Timestamp
+	}
+	return transition, true
+}
+
+// appStateFromDescription maps descriptions like "Foreground Running", "Background Running",
+// "Background Task Suspended" or "Terminated" to an AppState
+func appStateFromDescription(description string) AppState {
+	d := strings.ToLower(description)
+	switch {
+	case strings.Contains(d, "terminated"):
+		return AppStateTerminated
+	case strings.Contains(d, "suspended"):
+		return AppStateSuspended
+	case strings.Contains(d, "foreground"):
+		return AppStateForeground
+	case strings.Contains(d, "background"):
+		return AppStateBackground
+	default:
+		return AppStateUnknown
+	}
+}
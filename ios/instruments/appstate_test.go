@@ -0,0 +1,79 @@
+package instruments
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/danielpaulus/go-ios/ios/nskeyedarchiver"
+	"github.com/stretchr/testify/assert"
+)
+
+func appStateNotification(bundleID string, pid uint64, description string) map[string]interface{} {
+	return map[string]interface{}{
+		"appName":            bundleID,
+		"displayID":          bundleID,
+		"executable":         "/private/var/containers/Bundle/Application/UUID/App.app/App",
+		"mach_absolute_time": uint64(123456789),
+		"pid":                pid,
+		"state_description":  description,
+		"timestamp":          nskeyedarchiver.NSDate{Timestamp: time.Date(2024, 1, 16, 15, 36, 43, 0, time.UTC)},
+	}
+}
+
+func TestStreamAppStateTransitions(t *testing.T) {
+	notifications := []map[string]interface{}{
+		appStateNotification("com.example.app", 1234, "Foreground Running"),
+		appStateNotification("com.apple.mobilesafari", 99, "Background Running"),
+		appStateNotification("com.example.app", 1234, "Foreground Running"),
+		{"memoryPressure": uint64(2)},
+		appStateNotification("com.example.app", 1234, "Background Running"),
+		appStateNotification("com.example.app", 1234, "Background Task Suspended"),
+		appStateNotification("com.example.app", 1234, "Terminated"),
+	}
+	receive := func() (map[string]interface{}, error) {
+		if len(notifications) == 0 {
+			return nil, io.EOF
+		}
+		n := notifications[0]
+		notifications = notifications[1:]
+		return n, nil
+	}
+	transitions := make(chan AppStateTransition, 10)
+
+	streamAppStateTransitions(context.Background(), receive, "com.example.app", transitions)
+
+	var states []AppState
+	var descriptions []string
+	for transition := range transitions {
+		assert.Equal(t, uint64(1234), transition.Pid)
+		assert.Equal(t, time.Date(2024, 1, 16, 15, 36, 43, 0, time.UTC), transition.Timestamp)
+		states = append(states, transition.State)
+		descriptions = append(descriptions, transition.Description)
+	}
+	assert.Equal(t, []AppState{AppStateForeground, AppStateBackground, AppStateSuspended, AppStateTerminated}, states)
+	assert.Equal(t, []string{"Foreground Running", "Background Running", "Background Task Suspended", "Terminated"}, descriptions)
+}
+
+func TestStreamAppStateTransitionsCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	receive := func() (map[string]interface{}, error) {
+		return appStateNotification("com.example.app", 1, "Foreground Running"), nil
+	}
+	// an unbuffered channel nobody reads from blocks the stream until ctx is cancelled
+	transitions := make(chan AppStateTransition)
+	done := make(chan struct{})
+	go func() {
+		streamAppStateTransitions(ctx, receive, "com.example.app", transitions)
+		close(done)
+	}()
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("stream did not stop after cancel")
+	}
+}
@@ -2,22 +2,46 @@ package instruments
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/danielpaulus/go-ios/ios"
 	dtx "github.com/danielpaulus/go-ios/ios/dtx_codec"
 	log "github.com/sirupsen/logrus"
 )
 
+// sysmontapMsgDispatcher passes the messages of sysmontap to the receiver of the service. The messages channel is
+// never closed because the DTX reader may still dispatch a message while the service is closed, closed is closed instead.
 type sysmontapMsgDispatcher struct {
-	messages chan dtx.Message
+	messages  chan dtx.Message
+	closed    chan struct{}
+	closeOnce sync.Once
 }
 
 func newSysmontapMsgDispatcher() *sysmontapMsgDispatcher {
-	return &sysmontapMsgDispatcher{make(chan dtx.Message)}
+	return &sysmontapMsgDispatcher{messages: make(chan dtx.Message), closed: make(chan struct{})}
 }
 
 func (p *sysmontapMsgDispatcher) Dispatch(m dtx.Message) {
-	p.messages <- m
+	select {
+	case p.messages <- m:
+	case <-p.closed:
+	}
+}
+
+// next returns the next message of sysmontap, it is false once the dispatcher was closed
+func (p *sysmontapMsgDispatcher) next() (dtx.Message, bool) {
+	select {
+	case m := <-p.messages:
+		return m, true
+	case <-p.closed:
+		return dtx.Message{}, false
+	}
+}
+
+func (p *sysmontapMsgDispatcher) close() {
+	p.closeOnce.Do(func() {
+		close(p.closed)
+	})
 }
 
 const sysmontapName = "com.apple.instruments.server.services.sysmontap"
@@ -28,6 +52,8 @@ type sysmontapService struct {
 
 	deviceInfoService *DeviceInfoService
 	msgDispatcher     *sysmontapMsgDispatcher
+	systemAttributes  []interface{}
+	processAttributes []interface{}
 }
 
 // NewSysmontapService creates a new sysmontapService
@@ -78,26 +104,29 @@ func NewSysmontapService(device ios.DeviceEntry, samplingInterval int) (*sysmont
 		return nil, err
 	}
 
-	return &sysmontapService{processControlChannel, dtxConn, deviceInfoService, msgDispatcher}, nil
+	return &sysmontapService{processControlChannel, dtxConn, deviceInfoService, msgDispatcher, sysAttrs, procAttrs}, nil
 }
 
-// Close closes up the DTX connection, message dispatcher and dtx.Message channel
+// Close closes up the DTX connection and the message dispatcher, which closes the channels of the Receive methods
 func (s *sysmontapService) Close() error {
-	close(s.msgDispatcher.messages)
+	s.msgDispatcher.close()
 
 	s.deviceInfoService.Close()
 	return s.conn.Close()
 }
 
 // ReceiveCPUUsage returns a chan of SysmontapMessage with CPU Usage info
-// The method will close the result channel automatically as soon as sysmontapMsgDispatcher's
-// dtx.Message channel is closed.
+// The method will close the result channel automatically as soon as the service is closed.
 func (s *sysmontapService) ReceiveCPUUsage() chan SysmontapMessage {
 	messages := make(chan SysmontapMessage)
 	go func() {
 		defer close(messages)
 
-		for msg := range s.msgDispatcher.messages {
+		for {
+			msg, ok := s.msgDispatcher.next()
+			if !ok {
+				break
+			}
 			sysmontapMessage, err := mapToCPUUsage(msg)
 			if err != nil {
 				log.Debugf("expected `sysmontapMessage` from global channel, but received %v", msg)
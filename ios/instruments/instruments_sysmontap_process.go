@@ -0,0 +1,105 @@
+package instruments
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	dtx "github.com/danielpaulus/go-ios/ios/dtx_codec"
+	log "github.com/sirupsen/logrus"
+)
+
+// ProcessStats is the resource usage of a single process in a sysmontap sample
+type ProcessStats struct {
+	Pid  uint64
+	Name string
+	// PhysFootprint is the memory footprint of the process in bytes, the value Xcode shows as memory usage
+	PhysFootprint uint64
+	// Attributes contains all process attributes of the sample by their name, f.ex. cpuUsage or memResidentSize
+	Attributes map[string]interface{}
+}
+
+// ReceiveProcessStats returns a chan with the usage of all processes of each sysmontap sample.
+// The result channel is closed as soon as the service is closed. ReceiveProcessStats, ReceiveSystemStats and
+// ReceiveCPUUsage must not be used at the same time as they consume the same messages.
+func (s *sysmontapService) ReceiveProcessStats() chan []ProcessStats {
+	stats := make(chan []ProcessStats)
+	go func() {
+		defer close(stats)
+
+		for {
+			msg, ok := s.msgDispatcher.next()
+			if !ok {
+				break
+			}
+			processStats, err := mapToProcessStats(msg, s.processAttributes)
+			if err != nil {
+				log.Debugf("expected process sample from global channel, but received %v", msg)
+				continue
+			}
+			stats <- processStats
+		}
+
+		log.Infof("sysmontap message dispatcher channel closed")
+	}()
+
+	return stats
+}
+
+// mapToProcessStats extracts the process samples of a sysmontap message. The "Processes" dictionary maps pids to
+// arrays of values that are named with attributeNames, the process attributes the service was configured with.
+func mapToProcessStats(msg dtx.Message, attributeNames []interface{}) ([]ProcessStats, error) {
+	if len(msg.Payload) != 1 {
+		return nil, fmt.Errorf("payload of message should have only one element: %+v", msg)
+	}
+	resultArray, ok := msg.Payload[0].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected resultArray of type []interface{}: %+v", msg.Payload[0])
+	}
+	for _, result := range resultArray {
+		resultMap, ok := result.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		processes, ok := resultMap["Processes"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		stats := make([]ProcessStats, 0, len(processes))
+		for key, values := range processes {
+			processValues, ok := values.([]interface{})
+			if !ok {
+				continue
+			}
+			process := ProcessStats{Attributes: map[string]interface{}{}}
+			for i, value := range processValues {
+				if i >= len(attributeNames) {
+					break
+				}
+				if name, ok := attributeNames[i].(string); ok {
+					process.Attributes[name] = value
+				}
+			}
+			if pid, ok := process.Attributes["pid"].(uint64); ok {
+				process.Pid = pid
+			} else {
+				process.Pid = pidFromProcessKey(key)
+			}
+			process.Name, _ = process.Attributes["name"].(string)
+			process.PhysFootprint, _ = process.Attributes["physFootprint"].(uint64)
+			stats = append(stats, process)
+		}
+		return stats, nil
+	}
+	return nil, fmt.Errorf("message does not contain a process sample: %+v", msg)
+}
+
+// pidFromProcessKey parses the pid from the key of the "Processes" dictionary, the unarchiver converts its numeric
+// keys to strings like "uint64{123}"
+func pidFromProcessKey(key string) uint64 {
+	pid, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(key, "uint64{"), "}"), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return pid
+}
@@ -0,0 +1,62 @@
+package instruments
+
+import (
+	"sort"
+	"testing"
+
+	dtx "github.com/danielpaulus/go-ios/ios/dtx_codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessStatsFromRecordedFrame(t *testing.T) {
+	processAttributes := []interface{}{"pid", "name", "cpuUsage", "physFootprint", "memResidentSize"}
+	frame := dtx.Message{Payload: []interface{}{[]interface{}{
+		map[string]interface{}{
+			"CPUCount":       uint64(6),
+			"EndMachAbsTime": uint64(1000),
+			"System":         []interface{}{},
+			"Type":           uint64(41),
+		},
+		map[string]interface{}{
+			"Processes": map[string]interface{}{
+				"uint64{321}": []interface{}{uint64(321), "MyAppUITests-Runner", 12.5, uint64(52428800), uint64(41943040)},
+				"uint64{654}": []interface{}{uint64(654), "MyApp", 30.0, uint64(157286400), uint64(104857600)},
+			},
+			"Type": uint64(5),
+		},
+	}}}
+
+	stats, err := mapToProcessStats(frame, processAttributes)
+
+	require.NoError(t, err)
+	require.Len(t, stats, 2)
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Pid < stats[j].Pid })
+	assert.Equal(t, uint64(321), stats[0].Pid)
+	assert.Equal(t, "MyAppUITests-Runner", stats[0].Name)
+	assert.Equal(t, uint64(52428800), stats[0].PhysFootprint)
+	assert.Equal(t, 12.5, stats[0].Attributes["cpuUsage"])
+	assert.Equal(t, "MyApp", stats[1].Name)
+	assert.Equal(t, uint64(157286400), stats[1].PhysFootprint)
+}
+
+func TestProcessStatsPidFromKey(t *testing.T) {
+	frame := dtx.Message{Payload: []interface{}{[]interface{}{
+		map[string]interface{}{
+			"Processes": map[string]interface{}{"uint64{42}": []interface{}{"MyApp", uint64(1024)}},
+		},
+	}}}
+
+	stats, err := mapToProcessStats(frame, []interface{}{"name", "physFootprint"})
+
+	require.NoError(t, err)
+	assert.Equal(t, []ProcessStats{{Pid: 42, Name: "MyApp", PhysFootprint: 1024, Attributes: map[string]interface{}{"name": "MyApp", "physFootprint": uint64(1024)}}}, stats)
+}
+
+func TestProcessStatsWithoutProcesses(t *testing.T) {
+	_, err := mapToProcessStats(systemFrame(1000, 1.0, []interface{}{}), nil)
+	assert.NoError(t, err, "an empty process sample is valid")
+
+	_, err = mapToProcessStats(dtx.Message{Payload: []interface{}{[]interface{}{map[string]interface{}{"Type": uint64(41)}}}}, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,132 @@
+package instruments
+
+import (
+	"fmt"
+
+	dtx "github.com/danielpaulus/go-ios/ios/dtx_codec"
+	log "github.com/sirupsen/logrus"
+)
+
+// meanLoadSamples is the number of samples SystemStats.MeanCPUTotalLoad is calculated from
+const meanLoadSamples = 10
+
+// SystemStats is a system wide sample of the device's resource usage
+type SystemStats struct {
+	EndMachAbsTime uint64
+	CPUCount       uint64
+	EnabledCPUs    uint64
+	// CPUTotalLoad is the load of all CPUs combined in percent, 100 is one fully loaded CPU
+	CPUTotalLoad float64
+	// MeanCPUTotalLoad is the mean CPUTotalLoad of the last 10 samples in percent. It is not a unix load average,
+	// the window grows with each sample until 10 samples were received.
+	MeanCPUTotalLoad float64
+	// MemoryPressure is the ratio of used memory pages (active, wired and compressed) to all memory pages between 0 and 1
+	MemoryPressure float64
+	// Attributes contains all system attributes of the sample by their name, f.ex. vmFreeCount or diskBytesRead
+	Attributes map[string]interface{}
+}
+
+// ReceiveSystemStats returns a chan of SystemStats with the system wide CPU and memory usage.
+// The result channel is closed as soon as the service is closed. ReceiveSystemStats and ReceiveCPUUsage
+// must not be used at the same time as they consume the same messages.
+func (s *sysmontapService) ReceiveSystemStats() chan SystemStats {
+	stats := make(chan SystemStats)
+	go func() {
+		defer close(stats)
+
+		sampler := systemStatsSampler{attributeNames: s.systemAttributes}
+		for {
+			msg, ok := s.msgDispatcher.next()
+			if !ok {
+				break
+			}
+			systemStats, err := sampler.next(msg)
+			if err != nil {
+				log.Debugf("expected system sample from global channel, but received %v", msg)
+				continue
+			}
+			stats <- systemStats
+		}
+
+		log.Infof("sysmontap message dispatcher channel closed")
+	}()
+
+	return stats
+}
+
+// systemStatsSampler converts sysmontap messages to SystemStats and keeps the history needed for the mean CPU load
+type systemStatsSampler struct {
+	attributeNames []interface{}
+	recentLoads    []float64
+}
+
+func (s *systemStatsSampler) next(msg dtx.Message) (SystemStats, error) {
+	stats, err := mapToSystemStats(msg, s.attributeNames)
+	if err != nil {
+		return SystemStats{}, err
+	}
+	s.recentLoads = append(s.recentLoads, stats.CPUTotalLoad)
+	if len(s.recentLoads) > meanLoadSamples {
+		s.recentLoads = s.recentLoads[1:]
+	}
+	sum := 0.0
+	for _, load := range s.recentLoads {
+		sum += load
+	}
+	stats.MeanCPUTotalLoad = sum / float64(len(s.recentLoads))
+	return stats, nil
+}
+
+// mapToSystemStats extracts the system sample of a sysmontap message. The values of the "System" array are
+// named with attributeNames, the system attributes the service was configured with.
+func mapToSystemStats(msg dtx.Message, attributeNames []interface{}) (SystemStats, error) {
+	if len(msg.Payload) != 1 {
+		return SystemStats{}, fmt.Errorf("payload of message should have only one element: %+v", msg)
+	}
+	resultArray, ok := msg.Payload[0].([]interface{})
+	if !ok {
+		return SystemStats{}, fmt.Errorf("expected resultArray of type []interface{}: %+v", msg.Payload[0])
+	}
+	for _, result := range resultArray {
+		resultMap, ok := result.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		systemValues, ok := resultMap["System"].([]interface{})
+		if !ok {
+			continue
+		}
+
+		stats := SystemStats{Attributes: map[string]interface{}{}}
+		for i, value := range systemValues {
+			if i >= len(attributeNames) {
+				break
+			}
+			if name, ok := attributeNames[i].(string); ok {
+				stats.Attributes[name] = value
+			}
+		}
+		stats.CPUCount, _ = resultMap["CPUCount"].(uint64)
+		stats.EnabledCPUs, _ = resultMap["EnabledCPUs"].(uint64)
+		stats.EndMachAbsTime, _ = resultMap["EndMachAbsTime"].(uint64)
+		if cpuUsage, ok := resultMap["SystemCPUUsage"].(map[string]interface{}); ok {
+			stats.CPUTotalLoad, _ = cpuUsage["CPU_TotalLoad"].(float64)
+		}
+		stats.MemoryPressure = memoryPressure(stats.Attributes)
+		return stats, nil
+	}
+	return SystemStats{}, fmt.Errorf("message does not contain a system sample: %+v", msg)
+}
+
+func memoryPressure(attributes map[string]interface{}) float64 {
+	count := func(name string) uint64 {
+		value, _ := attributes[name].(uint64)
+		return value
+	}
+	used := count("vmActiveCount") + count("vmWireCount") + count("vmCompressorPageCount")
+	total := used + count("vmInactiveCount") + count("vmFreeCount")
+	if total == 0 {
+		return 0
+	}
+	return float64(used) / float64(total)
+}
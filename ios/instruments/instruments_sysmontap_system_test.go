@@ -0,0 +1,70 @@
+package instruments
+
+import (
+	"testing"
+
+	dtx "github.com/danielpaulus/go-ios/ios/dtx_codec"
+	"github.com/stretchr/testify/assert"
+)
+
+var systemAttributes = []interface{}{"vmFreeCount", "vmActiveCount", "vmInactiveCount", "vmWireCount", "vmCompressorPageCount", "diskBytesRead"}
+
+func systemFrame(endTime uint64, totalLoad float64, system []interface{}) dtx.Message {
+	return dtx.Message{Payload: []interface{}{[]interface{}{
+		map[string]interface{}{
+			"CPUCount":       uint64(6),
+			"EnabledCPUs":    uint64(6),
+			"EndMachAbsTime": endTime,
+			"Type":           uint64(41),
+			"System":         system,
+			"SystemCPUUsage": map[string]interface{}{"CPU_TotalLoad": totalLoad, "CPU_UserLoad": -1.0, "CPU_SystemLoad": -1.0},
+		},
+		map[string]interface{}{
+			"Processes": map[string]interface{}{},
+			"Type":      uint64(5),
+		},
+	}}}
+}
+
+func TestSystemStatsFromRecordedFrames(t *testing.T) {
+	frames := []dtx.Message{
+		systemFrame(1000, 120.0, []interface{}{uint64(1000), uint64(2000), uint64(500), uint64(1000), uint64(500), uint64(4096)}),
+		systemFrame(2000, 60.0, []interface{}{uint64(500), uint64(2500), uint64(500), uint64(1000), uint64(500), uint64(8192)}),
+	}
+	sampler := systemStatsSampler{attributeNames: systemAttributes}
+
+	first, err := sampler.next(frames[0])
+	assert.NoError(t, err)
+	second, err := sampler.next(frames[1])
+	assert.NoError(t, err)
+
+	assert.Equal(t, uint64(1000), first.EndMachAbsTime)
+	assert.Equal(t, uint64(6), first.CPUCount)
+	assert.Equal(t, 120.0, first.CPUTotalLoad)
+	assert.Equal(t, 120.0, first.MeanCPUTotalLoad)
+	assert.InDelta(t, 0.7, first.MemoryPressure, 0.0001)
+	assert.Equal(t, uint64(4096), first.Attributes["diskBytesRead"])
+
+	assert.Equal(t, uint64(2000), second.EndMachAbsTime)
+	assert.Equal(t, 60.0, second.CPUTotalLoad)
+	assert.Equal(t, 90.0, second.MeanCPUTotalLoad)
+	assert.InDelta(t, 0.8, second.MemoryPressure, 0.0001)
+}
+
+func TestSystemStatsMeanCPUTotalLoadWindow(t *testing.T) {
+	sampler := systemStatsSampler{attributeNames: systemAttributes}
+	for i := 0; i < meanLoadSamples; i++ {
+		_, err := sampler.next(systemFrame(uint64(i), 0, []interface{}{}))
+		assert.NoError(t, err)
+	}
+	stats, err := sampler.next(systemFrame(100, 100.0, []interface{}{}))
+
+	assert.NoError(t, err)
+	assert.Equal(t, 100.0/meanLoadSamples, stats.MeanCPUTotalLoad, "only the last samples must be used")
+}
+
+func TestSystemStatsIgnoresFramesWithoutSystemSample(t *testing.T) {
+	_, err := mapToSystemStats(dtx.Message{Payload: []interface{}{[]interface{}{map[string]interface{}{"Processes": map[string]interface{}{}}}}}, systemAttributes)
+
+	assert.Error(t, err)
+}
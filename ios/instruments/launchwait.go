@@ -0,0 +1,69 @@
+package instruments
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/danielpaulus/go-ios/ios"
+	"github.com/danielpaulus/go-ios/ios/syslog"
+	log "github.com/sirupsen/logrus"
+)
+
+// LaunchAppAndWaitForLog launches the app with bundleID and blocks until the launched process writes a syslog line
+// matching pattern, f.ex. a readiness marker the app logs with os_log. It returns the pid of the app and the matching
+// line. Use a ctx with a deadline to limit the time to wait, the app keeps running if the wait times out.
+func LaunchAppAndWaitForLog(ctx context.Context, device ios.DeviceEntry, bundleID string, args []interface{}, env map[string]any, opts map[string]any, pattern *regexp.Regexp) (uint64, string, error) {
+	// syslog is connected before the launch so that early log lines are not missed
+	syslogConn, err := syslog.New(device)
+	if err != nil {
+		return 0, "", fmt.Errorf("LaunchAppAndWaitForLog: failed connecting to syslog: %w", err)
+	}
+	defer syslogConn.Close()
+
+	processControl, err := NewProcessControl(device)
+	if err != nil {
+		return 0, "", fmt.Errorf("LaunchAppAndWaitForLog: failed connecting to process control: %w", err)
+	}
+	defer processControl.Close()
+	pid, err := processControl.LaunchAppWithArgs(bundleID, args, env, opts)
+	if err != nil {
+		return 0, "", fmt.Errorf("LaunchAppAndWaitForLog: failed launching %s: %w", bundleID, err)
+	}
+	log.WithFields(log.Fields{"pid": pid, "bundleID": bundleID}).Debug("app launched, waiting for log line")
+
+	stop := context.AfterFunc(ctx, func() {
+		syslogConn.Close()
+	})
+	defer stop()
+	line, err := waitForLogLine(ctx, syslogConn.ReadLogMessage, pid, pattern)
+	if err != nil {
+		return pid, "", fmt.Errorf("LaunchAppAndWaitForLog: %w", err)
+	}
+	return pid, line, nil
+}
+
+// waitForLogLine calls readLine until a line of the process with pid matches pattern
+func waitForLogLine(ctx context.Context, readLine func() (string, error), pid uint64, pattern *regexp.Regexp) (string, error) {
+	parse := syslog.Parser()
+	expectedPid := strconv.FormatUint(pid, 10)
+	for {
+		line, err := readLine()
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("log line matching '%s' not found: %w", pattern, ctx.Err())
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed reading syslog: %w", err)
+		}
+		line = strings.TrimRight(line, "\x00\n")
+		entry, err := parse(line)
+		if err != nil || entry.PID != expectedPid {
+			continue
+		}
+		if pattern.MatchString(entry.Message) {
+			return line, nil
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package instruments
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func syntheticLogLines(lines ...string) func() (string, error) {
+	return func() (string, error) {
+		if len(lines) == 0 {
+			return "", io.EOF
+		}
+		line := lines[0]
+		lines = lines[1:]
+		return line + "\n\x00", nil
+	}
+}
+
+func TestWaitForLogLine(t *testing.T) {
+	readLine := syntheticLogLines(
+		"Jan 16 15:36:43 iPhone SpringBoard(FrontBoard)[58] <Notice>: Bootstrapping com.example.app",
+		"Jan 16 15:36:43 iPhone MyApp[1234] <Notice>: loading configuration",
+		"Jan 16 15:36:44 iPhone OtherApp[999] <Notice>: app ready port=9999",
+		"not a syslog line",
+		"Jan 16 15:36:44 iPhone MyApp[1234] <Notice>: app ready port=8100",
+		"Jan 16 15:36:45 iPhone MyApp[1234] <Notice>: app ready port=8200",
+	)
+
+	line, err := waitForLogLine(context.Background(), readLine, 1234, regexp.MustCompile(`app ready port=\d+`))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Jan 16 15:36:44 iPhone MyApp[1234] <Notice>: app ready port=8100", line)
+}
+
+func TestWaitForLogLineTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	unblock := make(chan struct{})
+	context.AfterFunc(ctx, func() { close(unblock) })
+	readLine := func() (string, error) {
+		select {
+		case <-unblock:
+			return "", fmt.Errorf("connection closed")
+		case <-time.After(5 * time.Millisecond):
+			return "Jan 16 15:36:43 iPhone MyApp[1234] <Notice>: still loading", nil
+		}
+	}
+
+	_, err := waitForLogLine(ctx, readLine, 1234, regexp.MustCompile("app ready"))
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestWaitForLogLineConnectionLost(t *testing.T) {
+	_, err := waitForLogLine(context.Background(), syntheticLogLines(), 1234, regexp.MustCompile("app ready"))
+
+	assert.ErrorIs(t, err, io.EOF)
+}
@@ -0,0 +1,84 @@
+package instruments
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/danielpaulus/go-ios/ios"
+)
+
+// networkUsageTimeout is the time ReadNetworkUsage waits for a sample of sysmontap
+const networkUsageTimeout = 10 * time.Second
+
+// NetworkUsage contains the cumulative network traffic of all interfaces of the device since it booted.
+// The device does not report wifi and cellular traffic separately.
+type NetworkUsage struct {
+	BytesIn    uint64
+	BytesOut   uint64
+	PacketsIn  uint64
+	PacketsOut uint64
+}
+
+// ReadNetworkUsage reads the network traffic counters of the device from a system sample of sysmontap
+func ReadNetworkUsage(device ios.DeviceEntry) (NetworkUsage, error) {
+	sysmon, err := NewSysmontapService(device, 10)
+	if err != nil {
+		return NetworkUsage{}, fmt.Errorf("ReadNetworkUsage: cannot start sysmontap: %w", err)
+	}
+	stats := sysmon.ReceiveSystemStats()
+	defer func() {
+		sysmon.Close()
+		for range stats {
+		}
+	}()
+
+	select {
+	case sample, ok := <-stats:
+		if !ok {
+			return NetworkUsage{}, fmt.Errorf("ReadNetworkUsage: sysmontap closed before sending a sample")
+		}
+		return NetworkUsageFromSystemStats(sample)
+	case <-time.After(networkUsageTimeout):
+		return NetworkUsage{}, fmt.Errorf("ReadNetworkUsage: no sample received within %s", networkUsageTimeout)
+	}
+}
+
+// NetworkUsageFromSystemStats extracts the network traffic counters of a sysmontap system sample
+func NetworkUsageFromSystemStats(stats SystemStats) (NetworkUsage, error) {
+	counter := func(name string) (uint64, error) {
+		value, ok := stats.Attributes[name].(uint64)
+		if !ok {
+			return 0, fmt.Errorf("NetworkUsageFromSystemStats: system sample does not contain %s", name)
+		}
+		return value, nil
+	}
+	var usage NetworkUsage
+	var err error
+	if usage.BytesIn, err = counter("netBytesIn"); err != nil {
+		return NetworkUsage{}, err
+	}
+	if usage.BytesOut, err = counter("netBytesOut"); err != nil {
+		return NetworkUsage{}, err
+	}
+	if usage.PacketsIn, err = counter("netPacketsIn"); err != nil {
+		return NetworkUsage{}, err
+	}
+	if usage.PacketsOut, err = counter("netPacketsOut"); err != nil {
+		return NetworkUsage{}, err
+	}
+	return usage, nil
+}
+
+// Since returns the traffic between baseline and u. The counters of the device can not be cleared, so a reading
+// taken before a test can be used as baseline instead. If the device rebooted in between, u is returned unchanged.
+func (u NetworkUsage) Since(baseline NetworkUsage) NetworkUsage {
+	if u.BytesIn < baseline.BytesIn || u.BytesOut < baseline.BytesOut || u.PacketsIn < baseline.PacketsIn || u.PacketsOut < baseline.PacketsOut {
+		return u
+	}
+	return NetworkUsage{
+		BytesIn:    u.BytesIn - baseline.BytesIn,
+		BytesOut:   u.BytesOut - baseline.BytesOut,
+		PacketsIn:  u.PacketsIn - baseline.PacketsIn,
+		PacketsOut: u.PacketsOut - baseline.PacketsOut,
+	}
+}
@@ -0,0 +1,59 @@
+package instruments
+
+import (
+	"testing"
+	"time"
+
+	dtx "github.com/danielpaulus/go-ios/ios/dtx_codec"
+	"github.com/stretchr/testify/assert"
+)
+
+var networkAttributes = []interface{}{"vmFreeCount", "netBytesIn", "netBytesOut", "netPacketsIn", "netPacketsOut"}
+
+func TestNetworkUsageFromSystemStats(t *testing.T) {
+	stats, err := mapToSystemStats(systemFrame(1000, 10.0, []interface{}{uint64(1000), uint64(52428800), uint64(1048576), uint64(40000), uint64(9000)}), networkAttributes)
+	assert.NoError(t, err)
+
+	usage, err := NetworkUsageFromSystemStats(stats)
+
+	assert.NoError(t, err)
+	assert.Equal(t, NetworkUsage{BytesIn: 52428800, BytesOut: 1048576, PacketsIn: 40000, PacketsOut: 9000}, usage)
+}
+
+func TestNetworkUsageFromSystemStatsWithoutNetworkAttributes(t *testing.T) {
+	stats, err := mapToSystemStats(systemFrame(1000, 10.0, []interface{}{uint64(1000)}), systemAttributes)
+	assert.NoError(t, err)
+
+	_, err = NetworkUsageFromSystemStats(stats)
+
+	assert.Error(t, err)
+}
+
+func TestNetworkUsageSince(t *testing.T) {
+	baseline := NetworkUsage{BytesIn: 100, BytesOut: 50, PacketsIn: 10, PacketsOut: 5}
+	current := NetworkUsage{BytesIn: 300, BytesOut: 80, PacketsIn: 30, PacketsOut: 8}
+
+	assert.Equal(t, NetworkUsage{BytesIn: 200, BytesOut: 30, PacketsIn: 20, PacketsOut: 3}, current.Since(baseline))
+	assert.Equal(t, baseline, baseline.Since(current), "counters reset by a reboot should not underflow")
+}
+
+func TestSysmontapDispatchAfterClose(t *testing.T) {
+	dispatcher := newSysmontapMsgDispatcher()
+	dispatched := make(chan struct{})
+	go func() {
+		// the DTX reader can still dispatch a message that nobody receives anymore while the service is closed
+		dispatcher.Dispatch(dtx.Message{})
+		close(dispatched)
+	}()
+
+	dispatcher.close()
+
+	select {
+	case <-dispatched:
+	case <-time.After(time.Second):
+		t.Fatal("Dispatch blocked after the dispatcher was closed")
+	}
+	assert.NotPanics(t, func() { dispatcher.Dispatch(dtx.Message{}) })
+	_, ok := dispatcher.next()
+	assert.False(t, ok)
+}
@@ -62,6 +62,19 @@ func NewProcessControl(device ios.DeviceEntry) (*ProcessControl, error) {
 	return &ProcessControl{processControlChannel: processControlChannel, conn: dtxConn}, nil
 }
 
+// NewProcessControlWithOutput connects to process control like NewProcessControl and passes everything the processes
+// launched with it write to stdout and stderr to handle. Processes only send their output if they are launched with
+// NSUnbufferedIO=YES in their environment, which LaunchApp and LaunchAppWithArgs do. handle is called on the reader
+// goroutine of the connection and must not block.
+func NewProcessControlWithOutput(device ios.DeviceEntry, handle func(ProcessOutput)) (*ProcessControl, error) {
+	dtxConn, err := connectInstrumentsWithMsgDispatcher(device, processOutputHandler{handle: handle})
+	if err != nil {
+		return nil, err
+	}
+	processControlChannel := dtxConn.RequestChannelIdentifier(procControlChannel, loggingDispatcher{dtxConn})
+	return &ProcessControl{processControlChannel: processControlChannel, conn: dtxConn}, nil
+}
+
 // DisableMemoryLimit disables the memory limit of a process.
 func (p ProcessControl) DisableMemoryLimit(pid uint64) (bool, error) {
 	aux := dtx.NewPrimitiveDictionary()
@@ -0,0 +1,181 @@
+package instruments
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/danielpaulus/go-ios/ios"
+	dtx "github.com/danielpaulus/go-ios/ios/dtx_codec"
+	"github.com/danielpaulus/go-ios/ios/nskeyedarchiver"
+	"github.com/danielpaulus/go-ios/ios/syslog"
+	log "github.com/sirupsen/logrus"
+)
+
+const outputReceivedSelector = "outputReceived:fromProcess:atTime:"
+
+// ProcessOutput is a chunk of console output (stdout/stderr and os_log messages) of a process
+type ProcessOutput struct {
+	Pid     uint64
+	Message string
+	// Timestamp is the mach absolute time at which the output was written. It is only set for output of processes
+	// launched with ProcessControl, not for output received with AttachToProcess.
+	Timestamp uint64
+}
+
+// ProcessOutputListener streams the syslog messages of an already running process
+type ProcessOutputListener struct {
+	pid       uint64
+	conn      *syslog.Connection
+	output    chan ProcessOutput
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// AttachToProcess reads the syslog of the device and returns a ProcessOutputListener that receives the messages the
+// process with the given pid logs with os_log or NSLog. Its stdout and stderr are not part of the syslog, they can only
+// be received for processes launched with NewProcessControlWithOutput. The process keeps running when the listener is
+// closed.
+func AttachToProcess(device ios.DeviceEntry, pid uint64) (*ProcessOutputListener, error) {
+	conn, err := syslog.New(device)
+	if err != nil {
+		return nil, fmt.Errorf("AttachToProcess: failed to connect to syslog: %w", err)
+	}
+	listener := &ProcessOutputListener{pid: pid, conn: conn, output: make(chan ProcessOutput, 100), closed: make(chan struct{})}
+	go listener.readSyslog()
+	log.WithFields(log.Fields{"pid": pid}).Debug("Attached to process")
+	return listener, nil
+}
+
+// Output returns the channel on which the output of the process is received. It is closed after Close was called or
+// if the connection to the syslog of the device was lost.
+func (l *ProcessOutputListener) Output() <-chan ProcessOutput {
+	return l.output
+}
+
+// Close stops reading the syslog of the device
+func (l *ProcessOutputListener) Close() error {
+	var err error
+	l.closeOnce.Do(func() {
+		close(l.closed)
+		err = l.conn.Close()
+	})
+	return err
+}
+
+func (l *ProcessOutputListener) readSyslog() {
+	defer close(l.output)
+	parse := syslog.Parser()
+	for {
+		message, err := l.conn.ReadLogMessage()
+		if err != nil {
+			select {
+			case <-l.closed:
+			default:
+				log.WithFields(log.Fields{"pid": l.pid, "error": err}).Error("failed reading syslog")
+			}
+			return
+		}
+		output, ok := processOutputFromSyslog(parse, message, l.pid)
+		if !ok {
+			continue
+		}
+		select {
+		case l.output <- output:
+		case <-l.closed:
+			return
+		}
+	}
+}
+
+// processOutputFromSyslog returns the message of a syslog line if it was logged by the process with the given pid
+func processOutputFromSyslog(parse func(string) (*syslog.LogEntry, error), message string, pid uint64) (ProcessOutput, bool) {
+	entry, err := parse(strings.TrimRight(message, "\x00\n"))
+	if err != nil {
+		return ProcessOutput{}, false
+	}
+	entryPid, err := strconv.ParseUint(entry.PID, 10, 64)
+	if err != nil || entryPid != pid {
+		return ProcessOutput{}, false
+	}
+	return ProcessOutput{Pid: pid, Message: entry.Message + "\n"}, true
+}
+
+// processOutputHandler passes the output of all processes of a connection to handle, see NewProcessControlWithOutput
+type processOutputHandler struct {
+	handle func(ProcessOutput)
+}
+
+func (d processOutputHandler) Dispatch(msg dtx.Message) {
+	if len(msg.Payload) == 0 || msg.Payload[0] != outputReceivedSelector {
+		return
+	}
+	output, err := decodeProcessOutput(msg)
+	if err != nil {
+		log.Debugf("error decoding process output %+v, %v", msg, err)
+		return
+	}
+	d.handle(output)
+}
+
+// decodeProcessOutput decodes the arguments of a outputReceived:fromProcess:atTime: message
+func decodeProcessOutput(msg dtx.Message) (ProcessOutput, error) {
+	args := msg.Auxiliary.GetArguments()
+	if len(args) != 3 {
+		return ProcessOutput{}, fmt.Errorf("decodeProcessOutput: expected 3 arguments but got %d", len(args))
+	}
+	message, err := unarchiveArgument(args[0])
+	if err != nil {
+		return ProcessOutput{}, fmt.Errorf("decodeProcessOutput: failed to decode message: %w", err)
+	}
+	text, ok := message.(string)
+	if !ok {
+		return ProcessOutput{}, fmt.Errorf("decodeProcessOutput: message %v is not a string", message)
+	}
+	pid, err := uint64Argument(args[1])
+	if err != nil {
+		return ProcessOutput{}, fmt.Errorf("decodeProcessOutput: failed to decode pid: %w", err)
+	}
+	timestamp, err := uint64Argument(args[2])
+	if err != nil {
+		return ProcessOutput{}, fmt.Errorf("decodeProcessOutput: failed to decode timestamp: %w", err)
+	}
+	return ProcessOutput{Pid: pid, Message: text, Timestamp: timestamp}, nil
+}
+
+func unarchiveArgument(arg interface{}) (interface{}, error) {
+	data, ok := arg.([]byte)
+	if !ok {
+		return arg, nil
+	}
+	unarchived, err := nskeyedarchiver.Unarchive(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(unarchived) == 0 {
+		return nil, fmt.Errorf("empty archive")
+	}
+	return unarchived[0], nil
+}
+
+// uint64Argument converts a DTX auxiliary argument into an uint64. Depending on the iOS version, numbers are either
+// sent as primitive values or as NSKeyedArchiver encoded NSNumbers
+func uint64Argument(arg interface{}) (uint64, error) {
+	value, err := unarchiveArgument(arg)
+	if err != nil {
+		return 0, err
+	}
+	switch v := value.(type) {
+	case uint64:
+		return v, nil
+	case uint32:
+		return uint64(v), nil
+	case int64:
+		return uint64(v), nil
+	case int:
+		return uint64(v), nil
+	default:
+		return 0, fmt.Errorf("unexpected type %T", value)
+	}
+}
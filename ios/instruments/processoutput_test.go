@@ -0,0 +1,63 @@
+package instruments
+
+import (
+	"testing"
+
+	dtx "github.com/danielpaulus/go-ios/ios/dtx_codec"
+	"github.com/danielpaulus/go-ios/ios/nskeyedarchiver"
+	"github.com/danielpaulus/go-ios/ios/syslog"
+	"github.com/stretchr/testify/assert"
+)
+
+func outputReceivedMessage(t *testing.T, text string, pid int) dtx.Message {
+	payload, err := nskeyedarchiver.ArchiveBin(outputReceivedSelector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aux := dtx.NewPrimitiveDictionary()
+	aux.AddNsKeyedArchivedObject(text)
+	aux.AddInt32(pid)
+	aux.AddNsKeyedArchivedObject(uint64(123456789))
+	bytes, err := dtx.Encode(2, 0, -1, false, dtx.Methodinvocation, payload, aux)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, _, err := dtx.DecodeNonBlocking(bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestDecodeProcessOutput(t *testing.T) {
+	msg := outputReceivedMessage(t, "app did finish launching\n", 4711)
+
+	output, err := decodeProcessOutput(msg)
+
+	assert.NoError(t, err)
+	assert.Equal(t, ProcessOutput{Pid: 4711, Message: "app did finish launching\n", Timestamp: 123456789}, output)
+}
+
+func TestProcessOutputFromSyslog(t *testing.T) {
+	parse := syslog.Parser()
+
+	_, ok := processOutputFromSyslog(parse, "Jan 16 15:36:43 iPhone SpringBoard[58] <Notice>: other process\n\x00", 4711)
+	assert.False(t, ok)
+	_, ok = processOutputFromSyslog(parse, "not a syslog line\x00", 4711)
+	assert.False(t, ok)
+	output, ok := processOutputFromSyslog(parse, "Jan 16 15:36:43 iPhone MyApp(UIKitCore)[4711] <Notice>: attached process\n\x00", 4711)
+	assert.True(t, ok)
+	assert.Equal(t, ProcessOutput{Pid: 4711, Message: "attached process\n"}, output)
+}
+
+func TestProcessOutputHandler(t *testing.T) {
+	var received []ProcessOutput
+	handler := processOutputHandler{handle: func(output ProcessOutput) {
+		received = append(received, output)
+	}}
+
+	handler.Dispatch(outputReceivedMessage(t, "runner started\n", 4711))
+	handler.Dispatch(dtx.Message{Payload: []interface{}{"_notifyOfPublishedCapabilities:"}})
+
+	assert.Equal(t, []ProcessOutput{{Pid: 4711, Message: "runner started\n", Timestamp: 123456789}}, received)
+}
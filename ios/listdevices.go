@@ -61,6 +61,9 @@ type DeviceEntry struct {
 	UserspaceTUN     bool
 	UserspaceTUNHost string
 	UserspaceTUNPort int
+	// ConnectionPool, if set, makes ConnectToService and StartService start the services of the device on its pooled
+	// lockdown session
+	ConnectionPool *ConnectionPool `json:"-" plist:"-"`
 }
 
 // DeviceProperties contains important device related info like the udid which is named SerialNumber
@@ -0,0 +1,58 @@
+package ios
+
+import "fmt"
+
+// DiskUsageDomain is the lockdown domain containing the capacity and free space of the device's volumes
+const DiskUsageDomain = "com.apple.disk_usage"
+
+// DeviceCapabilities contains all values of a lockdown domain. It allows tools to probe for device features
+// generically without knowing the keys of a domain in advance.
+type DeviceCapabilities map[string]interface{}
+
+// GetDeviceCapabilities creates a new lockdown session for the device and reads all values of the given domain,
+// f.ex. DiskUsageDomain
+func GetDeviceCapabilities(device DeviceEntry, domain string) (DeviceCapabilities, error) {
+	lockDownConn, err := ConnectLockdownWithSession(device)
+	if err != nil {
+		return DeviceCapabilities{}, err
+	}
+	defer lockDownConn.Close()
+	resp, err := lockDownConn.GetValueForDomain("", domain)
+	if err != nil {
+		return DeviceCapabilities{}, err
+	}
+	return DeviceCapabilitiesFromValue(domain, resp)
+}
+
+// DeviceCapabilitiesFromValue converts the value lockdown returns for a domain into DeviceCapabilities
+func DeviceCapabilitiesFromValue(domain string, value interface{}) (DeviceCapabilities, error) {
+	values, ok := value.(map[string]interface{})
+	if !ok {
+		return DeviceCapabilities{}, fmt.Errorf("DeviceCapabilitiesFromValue: unexpected response for domain %s: %+v", domain, value)
+	}
+	return DeviceCapabilities(values), nil
+}
+
+// Has returns true if the capability is reported by the device
+func (c DeviceCapabilities) Has(key string) bool {
+	_, ok := c[key]
+	return ok
+}
+
+// Bool returns the value of a boolean capability. ok is false if the capability is missing or not a boolean.
+func (c DeviceCapabilities) Bool(key string) (value bool, ok bool) {
+	value, ok = c[key].(bool)
+	return
+}
+
+// Uint64 returns the value of a numeric capability. ok is false if the capability is missing or not a number.
+func (c DeviceCapabilities) Uint64(key string) (value uint64, ok bool) {
+	value, ok = c[key].(uint64)
+	return
+}
+
+// String returns the value of a string capability. ok is false if the capability is missing or not a string.
+func (c DeviceCapabilities) String(key string) (value string, ok bool) {
+	value, ok = c[key].(string)
+	return
+}
@@ -0,0 +1,72 @@
+package ios_test
+
+import (
+	"testing"
+
+	ios "github.com/danielpaulus/go-ios/ios"
+	"github.com/stretchr/testify/assert"
+)
+
+const diskUsageDomainResponse = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Domain</key>
+	<string>com.apple.disk_usage</string>
+	<key>Request</key>
+	<string>GetValue</string>
+	<key>Value</key>
+	<dict>
+		<key>AmountDataAvailable</key>
+		<integer>41502347264</integer>
+		<key>AmountDataReserved</key>
+		<integer>209715200</integer>
+		<key>CalculateDiskUsage</key>
+		<string>OkilyDokily</string>
+		<key>NANDInfo</key>
+		<data>AAAA</data>
+		<key>TotalDataAvailable</key>
+		<integer>45797314560</integer>
+		<key>TotalDataCapacity</key>
+		<integer>113067094016</integer>
+		<key>TotalDiskCapacity</key>
+		<integer>128000000000</integer>
+		<key>TotalSystemAvailable</key>
+		<integer>0</integer>
+		<key>TotalSystemCapacity</key>
+		<integer>11306709401</integer>
+		<key>SupportsAutomaticCleanup</key>
+		<true/>
+	</dict>
+</dict>
+</plist>`
+
+func TestDeviceCapabilitiesFromValue(t *testing.T) {
+	response, err := ios.ParsePlist([]byte(diskUsageDomainResponse))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	capabilities, err := ios.DeviceCapabilitiesFromValue(ios.DiskUsageDomain, response["Value"])
+
+	assert.NoError(t, err)
+	totalCapacity, ok := capabilities.Uint64("TotalDiskCapacity")
+	assert.True(t, ok)
+	assert.Equal(t, uint64(128000000000), totalCapacity)
+	cleanup, ok := capabilities.Bool("SupportsAutomaticCleanup")
+	assert.True(t, ok)
+	assert.True(t, cleanup)
+	calculate, ok := capabilities.String("CalculateDiskUsage")
+	assert.True(t, ok)
+	assert.Equal(t, "OkilyDokily", calculate)
+	assert.True(t, capabilities.Has("NANDInfo"))
+	_, ok = capabilities.Bool("TotalDiskCapacity")
+	assert.False(t, ok, "values of a different type must not be returned")
+	assert.False(t, capabilities.Has("Unknown"))
+}
+
+func TestDeviceCapabilitiesFromValueInvalidResponse(t *testing.T) {
+	_, err := ios.DeviceCapabilitiesFromValue(ios.DiskUsageDomain, "not a dict")
+
+	assert.Error(t, err)
+}
@@ -1,6 +1,11 @@
 package ios
 
-import log "github.com/sirupsen/logrus"
+import (
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
 
 // LanguageConfiguration is a simple struct encapsulating a language and locale string
 type LanguageConfiguration struct {
@@ -72,3 +77,44 @@ func GetLanguage(device DeviceEntry) (LanguageConfiguration, error) {
 	supportedLanguages := InterfaceToStringSlice(supportedLanguagesResp)
 	return LanguageConfiguration{Language: languageResp.(string), Locale: localeResp.(string), SupportedLocales: supportedLocales, SupportedLanguages: supportedLanguages}, nil
 }
+
+// DeviceLocale contains the language and region settings a device was using at a given point in time.
+// It is meant to be recorded alongside test results so failures can be correlated with the device locale.
+type DeviceLocale struct {
+	Language string
+	Locale   string
+	Region   string
+}
+
+// GetDeviceLocale creates a new lockdown session for the device and reads the current language and locale
+// from the com.apple.international domain with a single request.
+func GetDeviceLocale(device DeviceEntry) (DeviceLocale, error) {
+	lockDownConn, err := ConnectLockdownWithSession(device)
+	if err != nil {
+		return DeviceLocale{}, err
+	}
+	defer lockDownConn.Close()
+	resp, err := lockDownConn.GetValueForDomain("", languageDomain)
+	if err != nil {
+		return DeviceLocale{}, err
+	}
+	values, ok := resp.(map[string]interface{})
+	if !ok {
+		return DeviceLocale{}, fmt.Errorf("GetDeviceLocale: unexpected response for domain %s: %+v", languageDomain, resp)
+	}
+	return DeviceLocaleFromValues(values), nil
+}
+
+// DeviceLocaleFromValues extracts a DeviceLocale from the values lockdown returns for the com.apple.international domain.
+// The region is derived from the locale identifier, f.ex. "US" for "en_US".
+func DeviceLocaleFromValues(values map[string]interface{}) DeviceLocale {
+	var locale DeviceLocale
+	locale.Language, _ = values["Language"].(string)
+	locale.Locale, _ = values["Locale"].(string)
+	if _, region, found := strings.Cut(locale.Locale, "_"); found {
+		// locales can carry additional modifiers like "de_DE@calendar=gregorian"
+		region, _, _ = strings.Cut(region, "@")
+		locale.Region = region
+	}
+	return locale
+}
@@ -0,0 +1,54 @@
+package ios_test
+
+import (
+	"testing"
+
+	ios "github.com/danielpaulus/go-ios/ios"
+	"github.com/stretchr/testify/assert"
+)
+
+const internationalDomainResponse = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Domain</key>
+	<string>com.apple.international</string>
+	<key>Request</key>
+	<string>GetValue</string>
+	<key>Value</key>
+	<dict>
+		<key>Keyboard</key>
+		<string>de_DE</string>
+		<key>Language</key>
+		<string>de</string>
+		<key>Locale</key>
+		<string>de_AT@calendar=gregorian</string>
+		<key>SupportedLanguages</key>
+		<array>
+			<string>de</string>
+			<string>en</string>
+		</array>
+	</dict>
+</dict>
+</plist>`
+
+func TestDeviceLocaleFromValues(t *testing.T) {
+	response, err := ios.ParsePlist([]byte(internationalDomainResponse))
+	if !assert.NoError(t, err) {
+		return
+	}
+	values, ok := response["Value"].(map[string]interface{})
+	if !assert.True(t, ok) {
+		return
+	}
+
+	locale := ios.DeviceLocaleFromValues(values)
+
+	assert.Equal(t, ios.DeviceLocale{Language: "de", Locale: "de_AT@calendar=gregorian", Region: "AT"}, locale)
+}
+
+func TestDeviceLocaleFromValuesWithoutRegion(t *testing.T) {
+	locale := ios.DeviceLocaleFromValues(map[string]interface{}{"Language": "en", "Locale": "en"})
+
+	assert.Equal(t, ios.DeviceLocale{Language: "en", Locale: "en"}, locale)
+}
@@ -0,0 +1,131 @@
+package ios
+
+import (
+	"fmt"
+
+	"github.com/Masterminds/semver"
+	log "github.com/sirupsen/logrus"
+)
+
+// WirelessLockdownDomain is the lockdown domain containing the network connection settings of the device
+const WirelessLockdownDomain = "com.apple.mobile.wireless_lockdown"
+
+const enableWifiConnectionsKey = "EnableWifiConnections"
+
+// SupportsWirelessDebugging returns true if the device can be used for debugging over the network. Network
+// tunnels to the RemoteXPC services are only available on iOS 17 and later, so older devices are reported as
+// not supported. The device must be paired before the network connection can be enabled with SetWirelessDebugging.
+func SupportsWirelessDebugging(device DeviceEntry) (bool, error) {
+	version, err := GetProductVersion(device)
+	if err != nil {
+		return false, fmt.Errorf("SupportsWirelessDebugging: cannot determine iOS version: %w", err)
+	}
+	return supportsWirelessDebugging(version), nil
+}
+
+func supportsWirelessDebugging(version *semver.Version) bool {
+	return !version.LessThan(IOS17())
+}
+
+// GetWirelessDebugging returns true if the "connect via network" setting of the device is enabled
+func GetWirelessDebugging(device DeviceEntry) (bool, error) {
+	lockDownConn, err := ConnectLockdownWithSession(device)
+	if err != nil {
+		return false, err
+	}
+	defer lockDownConn.Close()
+	value, err := lockDownConn.GetValueForDomain(enableWifiConnectionsKey, WirelessLockdownDomain)
+	if err != nil {
+		return false, err
+	}
+	enabled, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("GetWirelessDebugging: expected bool for %s.%s but received %T:%+v", WirelessLockdownDomain, enableWifiConnectionsKey, value, value)
+	}
+	return enabled, nil
+}
+
+// SetWirelessDebugging enables or disables the "connect via network" setting of the device. If enabled, the
+// device can be discovered and tunneled to over the network while it is not connected by USB.
+// This requires iOS 17 or later, see SupportsWirelessDebugging.
+func SetWirelessDebugging(device DeviceEntry, enabled bool) error {
+	supported, err := SupportsWirelessDebugging(device)
+	if err != nil {
+		return err
+	}
+	if !supported {
+		return fmt.Errorf("SetWirelessDebugging: wireless debugging requires iOS 17 or later")
+	}
+	lockDownConn, err := ConnectLockdownWithSession(device)
+	if err != nil {
+		return err
+	}
+	defer lockDownConn.Close()
+	log.Debugf("Setting %s: %t", enableWifiConnectionsKey, enabled)
+	return lockDownConn.SetValueForDomain(enableWifiConnectionsKey, WirelessLockdownDomain, enabled)
+}
+
+const enableWifiDebuggingKey = "EnableWifiDebugging"
+
+// WirelessSettings are the settings of the device that allow using it over the network instead of USB
+type WirelessSettings struct {
+	// WifiConnections is the "sync with this device over Wi-Fi" setting. If enabled, usbmuxd lists the device as a
+	// network device while it is in the same network as the host.
+	WifiConnections bool
+	// WifiDebugging allows starting developer services over the network connection
+	WifiDebugging bool
+}
+
+// GetWirelessSettings reads the network connection settings of the device
+func GetWirelessSettings(device DeviceEntry) (WirelessSettings, error) {
+	lockDownConn, err := ConnectLockdownWithSession(device)
+	if err != nil {
+		return WirelessSettings{}, fmt.Errorf("GetWirelessSettings: %w", err)
+	}
+	defer lockDownConn.Close()
+	var settings WirelessSettings
+	for key, setting := range map[string]*bool{enableWifiConnectionsKey: &settings.WifiConnections, enableWifiDebuggingKey: &settings.WifiDebugging} {
+		value, err := lockDownConn.GetValueForDomain(key, WirelessLockdownDomain)
+		if err != nil {
+			return WirelessSettings{}, fmt.Errorf("GetWirelessSettings: failed reading %s: %w", key, err)
+		}
+		// the keys are missing until they were set for the first time
+		if value == nil {
+			continue
+		}
+		enabled, ok := value.(bool)
+		if !ok {
+			return WirelessSettings{}, fmt.Errorf("GetWirelessSettings: expected bool for %s.%s but received %T:%+v", WirelessLockdownDomain, key, value, value)
+		}
+		*setting = enabled
+	}
+	return settings, nil
+}
+
+// SetWirelessSettings changes the network connection settings of the device. The settings can only be changed while
+// the device is paired and connected by USB. Afterwards the device can be used over the network without the cable.
+func SetWirelessSettings(device DeviceEntry, settings WirelessSettings) error {
+	if err := device.RequireUSB("SetWirelessSettings"); err != nil {
+		return err
+	}
+	lockDownConn, err := ConnectLockdownWithSession(device)
+	if err != nil {
+		return fmt.Errorf("SetWirelessSettings: %w", err)
+	}
+	defer lockDownConn.Close()
+	log.Debugf("Setting %s: %t, %s: %t", enableWifiConnectionsKey, settings.WifiConnections, enableWifiDebuggingKey, settings.WifiDebugging)
+	err = lockDownConn.SetValueForDomain(enableWifiConnectionsKey, WirelessLockdownDomain, settings.WifiConnections)
+	if err != nil {
+		return fmt.Errorf("SetWirelessSettings: failed setting %s: %w", enableWifiConnectionsKey, err)
+	}
+	err = lockDownConn.SetValueForDomain(enableWifiDebuggingKey, WirelessLockdownDomain, settings.WifiDebugging)
+	if err != nil {
+		return fmt.Errorf("SetWirelessSettings: failed setting %s: %w", enableWifiDebuggingKey, err)
+	}
+	return nil
+}
+
+// EnableWifiSync enables Wi-Fi connections and debugging, so that the device can be used over the network
+func EnableWifiSync(device DeviceEntry) error {
+	return SetWirelessSettings(device, WirelessSettings{WifiConnections: true, WifiDebugging: true})
+}
@@ -0,0 +1,48 @@
+package ios
+
+import (
+	"testing"
+
+	"github.com/Masterminds/semver"
+	"github.com/stretchr/testify/assert"
+	"howett.net/plist"
+)
+
+func TestEnableWirelessDebuggingRequest(t *testing.T) {
+	encoded, err := plist.Marshal(newSetValue(enableWifiConnectionsKey, WirelessLockdownDomain, true), plist.XMLFormat)
+	assert.NoError(t, err)
+
+	request, err := ParsePlist(encoded)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"Label":   "go.ios.control",
+		"Request": "SetValue",
+		"Domain":  "com.apple.mobile.wireless_lockdown",
+		"Key":     "EnableWifiConnections",
+		"Value":   true,
+	}, request)
+}
+
+func TestEnableWifiDebuggingRequest(t *testing.T) {
+	encoded, err := plist.Marshal(newSetValue(enableWifiDebuggingKey, WirelessLockdownDomain, false), plist.XMLFormat)
+	assert.NoError(t, err)
+
+	request, err := ParsePlist(encoded)
+	assert.NoError(t, err)
+	assert.Equal(t, "EnableWifiDebugging", request["Key"])
+	assert.Equal(t, "com.apple.mobile.wireless_lockdown", request["Domain"])
+	assert.Equal(t, false, request["Value"])
+}
+
+func TestSupportsWirelessDebugging(t *testing.T) {
+	assert.False(t, supportsWirelessDebugging(semver.MustParse("16.7.2")))
+	assert.True(t, supportsWirelessDebugging(semver.MustParse("17.0")))
+	assert.True(t, supportsWirelessDebugging(semver.MustParse("18.1")))
+}
+
+func TestSetWirelessSettingsRequiresUSB(t *testing.T) {
+	network := DeviceEntry{Properties: DeviceProperties{SerialNumber: "udid0", ConnectionType: ConnectionTypeNetwork}}
+
+	err := SetWirelessSettings(network, WirelessSettings{WifiConnections: true})
+	assert.ErrorIs(t, err, ErrUSBRequired)
+}
@@ -0,0 +1,71 @@
+package mcinstall
+
+import (
+	"fmt"
+
+	"github.com/danielpaulus/go-ios/ios"
+)
+
+// ManagementStatus describes whether a device is supervised and assigned to an MDM server.
+// All values come from the cloud configuration of the device which can be read without escalating the
+// connection and on devices that are not enrolled in any MDM.
+type ManagementStatus struct {
+	// Supervised is true if the device was prepared as a supervised device
+	Supervised bool
+	// OrganizationName is the organization that supervises the device
+	OrganizationName string
+	// CloudConfigured is true if the device has a cloud configuration, f.ex. because it was prepared
+	// with Prepare or with automated device enrollment
+	CloudConfigured bool
+	// MDMConfigurationURL is the enrollment URL of the MDM server the device was assigned to with automated device
+	// enrollment. MDM profiles that were installed manually are not reported here, they are part of the profile
+	// list returned by HandleList.
+	MDMConfigurationURL string
+	// MDMUnremovable is true if the user cannot remove the MDM enrollment of the device
+	MDMUnremovable bool
+}
+
+// MDMEnrolled returns true if the device was assigned to an MDM server with automated device enrollment
+func (s ManagementStatus) MDMEnrolled() bool {
+	return s.MDMConfigurationURL != ""
+}
+
+// GetManagementStatus reads the supervision and MDM enrollment status of the device
+func GetManagementStatus(device ios.DeviceEntry) (ManagementStatus, error) {
+	conn, err := New(device)
+	if err != nil {
+		return ManagementStatus{}, fmt.Errorf("GetManagementStatus: failed connecting to mcinstall: %w", err)
+	}
+	defer conn.Close()
+	response, err := check(conn.sendAndReceive(request("GetCloudConfiguration")))
+	if err != nil {
+		return ManagementStatus{}, fmt.Errorf("GetManagementStatus: failed getting cloud configuration: %w", err)
+	}
+	return managementStatusFromCloudConfiguration(response), nil
+}
+
+func managementStatusFromCloudConfiguration(response map[string]interface{}) ManagementStatus {
+	config, ok := response["CloudConfiguration"].(map[string]interface{})
+	if !ok || len(config) == 0 {
+		return ManagementStatus{}
+	}
+	status := ManagementStatus{CloudConfigured: true}
+	status.Supervised = plistBool(config["IsSupervised"])
+	status.MDMUnremovable = plistBool(config["IsMDMUnremovable"])
+	status.OrganizationName, _ = config["OrganizationName"].(string)
+	status.MDMConfigurationURL, _ = config["ConfigurationURL"].(string)
+	return status
+}
+
+// plistBool accepts booleans and the integers some iOS versions use instead
+func plistBool(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case uint64:
+		return v != 0
+	case int64:
+		return v != 0
+	}
+	return false
+}
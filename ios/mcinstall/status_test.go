@@ -0,0 +1,71 @@
+package mcinstall
+
+import (
+	"testing"
+
+	"github.com/danielpaulus/go-ios/ios"
+	"github.com/stretchr/testify/assert"
+)
+
+const supervisedCloudConfiguration = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>CloudConfiguration</key>
+	<dict>
+		<key>AllowPairing</key>
+		<true/>
+		<key>ConfigurationURL</key>
+		<string>https://mdm.example.com/enroll</string>
+		<key>IsMDMUnremovable</key>
+		<integer>1</integer>
+		<key>IsSupervised</key>
+		<true/>
+		<key>OrganizationName</key>
+		<string>Example Org</string>
+		<key>SkipSetup</key>
+		<array>
+			<string>Location</string>
+		</array>
+	</dict>
+	<key>Status</key>
+	<string>Acknowledged</string>
+</dict>
+</plist>`
+
+const unmanagedCloudConfiguration = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>CloudConfiguration</key>
+	<dict/>
+	<key>Status</key>
+	<string>Acknowledged</string>
+</dict>
+</plist>`
+
+func TestManagementStatusSupervised(t *testing.T) {
+	response, err := ios.ParsePlist([]byte(supervisedCloudConfiguration))
+	assert.NoError(t, err)
+
+	status := managementStatusFromCloudConfiguration(response)
+
+	assert.Equal(t, ManagementStatus{
+		Supervised:          true,
+		OrganizationName:    "Example Org",
+		CloudConfigured:     true,
+		MDMConfigurationURL: "https://mdm.example.com/enroll",
+		MDMUnremovable:      true,
+	}, status)
+	assert.True(t, status.MDMEnrolled())
+}
+
+func TestManagementStatusUnmanaged(t *testing.T) {
+	response, err := ios.ParsePlist([]byte(unmanagedCloudConfiguration))
+	assert.NoError(t, err)
+
+	status := managementStatusFromCloudConfiguration(response)
+
+	assert.Equal(t, ManagementStatus{}, status)
+	assert.False(t, status.MDMEnrolled())
+}
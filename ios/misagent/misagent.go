@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/danielpaulus/go-ios/ios"
+	"howett.net/plist"
 )
 
 const serviceName string = "com.apple.misagent"
@@ -26,7 +27,36 @@ func New(device ios.DeviceEntry) (*Connection, error) {
 	return &c, nil
 }
 
+// CopyAll checks that misagent can list the installed provisioning profiles
 func (c *Connection) CopyAll() error {
+	_, err := c.copyAll()
+	return err
+}
+
+// CopyAllProfiles returns all provisioning profiles installed on the device
+func (c *Connection) CopyAllProfiles() ([]Profile, error) {
+	payload, err := c.copyAll()
+	if err != nil {
+		return nil, fmt.Errorf("CopyAllProfiles: %w", err)
+	}
+	profiles := make([]Profile, 0, len(payload))
+	for _, data := range payload {
+		profile, err := ParseProfile(data)
+		if err != nil {
+			return nil, fmt.Errorf("CopyAllProfiles: %w", err)
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles, nil
+}
+
+type copyAllResponse struct {
+	Status  uint64
+	Payload [][]byte
+}
+
+// copyAll returns the signed provisioning profiles installed on the device
+func (c *Connection) copyAll() ([][]byte, error) {
 	msg := map[string]interface{}{
 		"MessageType": "CopyAll",
 		"ProfileType": "Provisioning",
@@ -34,31 +64,29 @@ func (c *Connection) CopyAll() error {
 	reader := c.deviceConn.Reader()
 	requestBytes, err := c.plistCodec.Encode(msg)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	err = c.deviceConn.Send(requestBytes)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	responseBytes, err := c.plistCodec.Decode(reader)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	resp, err := ios.ParsePlist(responseBytes)
+	var resp copyAllResponse
+	_, err = plist.Unmarshal(responseBytes, &resp)
 	if err != nil {
-		return err
-	}
-	t, ok := resp["Status"]
-	if !ok {
-		return fmt.Errorf("misagent invalid response %v", resp)
+		return nil, fmt.Errorf("misagent invalid response: %w", err)
 	}
-	i, ok := t.(int)
-	if !ok {
-		return fmt.Errorf("misagent invalid status in response %v", resp)
+	if resp.Status != 0 {
+		return nil, fmt.Errorf("misagent returned error code %d", resp.Status)
 	}
-	if i == 0 {
-		return nil
-	}
-	return fmt.Errorf("misagent returned error code %d in response %v", i, resp)
+	return resp.Payload, nil
+}
+
+// Close closes the connection to misagent
+func (c *Connection) Close() error {
+	return c.deviceConn.Close()
 }
@@ -0,0 +1,129 @@
+package misagent
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/danielpaulus/go-ios/ios"
+	"github.com/danielpaulus/go-ios/ios/installationproxy"
+	"go.mozilla.org/pkcs7"
+	"howett.net/plist"
+)
+
+// ErrProfileExpired is returned if the provisioning profile of an app is expired
+var ErrProfileExpired = errors.New("provisioning profile expired")
+
+// Profile contains the fields of a provisioning profile needed to check whether an app can still be launched
+type Profile struct {
+	UUID           string
+	Name           string
+	AppIDName      string
+	TeamIdentifier []string
+	CreationDate   time.Time
+	ExpirationDate time.Time
+	Entitlements   map[string]interface{}
+}
+
+// ApplicationIdentifier returns the application-identifier entitlement, f.ex. "TEAMID.com.example.app" or "TEAMID.*"
+// for wildcard profiles
+func (p Profile) ApplicationIdentifier() string {
+	id, _ := p.Entitlements["application-identifier"].(string)
+	return id
+}
+
+// Matches returns whether the profile can be used for an app with the application-identifier appIdentifier
+func (p Profile) Matches(appIdentifier string) bool {
+	profileIdentifier := p.ApplicationIdentifier()
+	if prefix, ok := strings.CutSuffix(profileIdentifier, "*"); ok {
+		return strings.HasPrefix(appIdentifier, prefix)
+	}
+	return profileIdentifier == appIdentifier
+}
+
+// ParseProfile decodes a provisioning profile, which is a plist signed as PKCS#7 message, like embedded.mobileprovision
+// files or the profiles returned by misagent
+func ParseProfile(data []byte) (Profile, error) {
+	p7, err := pkcs7.Parse(data)
+	if err != nil {
+		return Profile{}, fmt.Errorf("ParseProfile: failed parsing signed profile: %w", err)
+	}
+	var profile Profile
+	_, err = plist.Unmarshal(p7.Content, &profile)
+	if err != nil {
+		return Profile{}, fmt.Errorf("ParseProfile: failed decoding profile plist: %w", err)
+	}
+	return profile, nil
+}
+
+// ProvisioningValidity contains the profile an installed app is provisioned with
+type ProvisioningValidity struct {
+	Profile Profile
+	// DaysUntilExpiry is the number of full days until the profile expires
+	DaysUntilExpiry int
+}
+
+// CheckAppProvisioning looks up the provisioning profile of the installed app with bundleID on the device and returns
+// how long it is still valid. It returns ErrProfileExpired if the profile is expired already.
+func CheckAppProvisioning(device ios.DeviceEntry, bundleID string) (ProvisioningValidity, error) {
+	installationProxy, err := installationproxy.New(device)
+	if err != nil {
+		return ProvisioningValidity{}, fmt.Errorf("CheckAppProvisioning: failed connecting to installation proxy: %w", err)
+	}
+	defer installationProxy.Close()
+	apps, err := installationProxy.BrowseUserApps()
+	if err != nil {
+		return ProvisioningValidity{}, fmt.Errorf("CheckAppProvisioning: failed browsing apps: %w", err)
+	}
+	var appIdentifier string
+	for _, app := range apps {
+		if app.CFBundleIdentifier == bundleID {
+			appIdentifier, _ = app.Entitlements["application-identifier"].(string)
+			break
+		}
+	}
+	if appIdentifier == "" {
+		return ProvisioningValidity{}, fmt.Errorf("CheckAppProvisioning: no provisioned app with bundle id %s installed", bundleID)
+	}
+
+	conn, err := New(device)
+	if err != nil {
+		return ProvisioningValidity{}, fmt.Errorf("CheckAppProvisioning: failed connecting to misagent: %w", err)
+	}
+	defer conn.Close()
+	profiles, err := conn.CopyAllProfiles()
+	if err != nil {
+		return ProvisioningValidity{}, fmt.Errorf("CheckAppProvisioning: %w", err)
+	}
+	validity, err := provisioningValidity(appIdentifier, profiles, time.Now())
+	if err != nil {
+		return validity, fmt.Errorf("CheckAppProvisioning: %w", err)
+	}
+	return validity, nil
+}
+
+// provisioningValidity picks the profile of appIdentifier that expires last, which is the one the device uses when
+// several profiles match
+func provisioningValidity(appIdentifier string, profiles []Profile, now time.Time) (ProvisioningValidity, error) {
+	var found bool
+	var profile Profile
+	for _, p := range profiles {
+		if p.Matches(appIdentifier) && (!found || p.ExpirationDate.After(profile.ExpirationDate)) {
+			profile = p
+			found = true
+		}
+	}
+	if !found {
+		return ProvisioningValidity{}, fmt.Errorf("no provisioning profile found for %s", appIdentifier)
+	}
+	validity := ProvisioningValidity{
+		Profile:         profile,
+		DaysUntilExpiry: int(math.Floor(profile.ExpirationDate.Sub(now).Hours() / 24)),
+	}
+	if !profile.ExpirationDate.After(now) {
+		return validity, fmt.Errorf("profile %s of %s expired on %s: %w", profile.Name, appIdentifier, profile.ExpirationDate.Format(time.RFC3339), ErrProfileExpired)
+	}
+	return validity, nil
+}
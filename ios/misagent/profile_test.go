@@ -0,0 +1,107 @@
+package misagent
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mozilla.org/pkcs7"
+	"howett.net/plist"
+)
+
+// signedProfile creates a provisioning profile signed like the ones Apple issues
+func signedProfile(t *testing.T, name string, appIdentifier string, expiration time.Time) []byte {
+	content, err := plist.Marshal(map[string]interface{}{
+		"UUID":           "6c2b1a9e-0000-0000-0000-" + name,
+		"Name":           name,
+		"AppIDName":      "Example",
+		"TeamIdentifier": []string{"TEAMID"},
+		"CreationDate":   expiration.AddDate(-1, 0, 0),
+		"ExpirationDate": expiration,
+		"Entitlements": map[string]interface{}{
+			"application-identifier": appIdentifier,
+			"get-task-allow":         true,
+		},
+	}, plist.XMLFormat)
+	require.NoError(t, err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Apple iPhone OS Provisioning Profile Signing"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	signed, err := pkcs7.NewSignedData(content)
+	require.NoError(t, err)
+	require.NoError(t, signed.AddSigner(cert, key, pkcs7.SignerInfoConfig{}))
+	data, err := signed.Finish()
+	require.NoError(t, err)
+	return data
+}
+
+func TestParseProfile(t *testing.T) {
+	expiration := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	profile, err := ParseProfile(signedProfile(t, "valid", "TEAMID.com.example.app", expiration))
+
+	require.NoError(t, err)
+	assert.Equal(t, "valid", profile.Name)
+	assert.Equal(t, []string{"TEAMID"}, profile.TeamIdentifier)
+	assert.Equal(t, "TEAMID.com.example.app", profile.ApplicationIdentifier())
+	assert.True(t, expiration.Equal(profile.ExpirationDate))
+}
+
+func TestParseProfileInvalid(t *testing.T) {
+	_, err := ParseProfile([]byte("not a profile"))
+	assert.Error(t, err)
+}
+
+func TestProvisioningValidity(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	parse := func(name string, appIdentifier string, expiration time.Time) Profile {
+		profile, err := ParseProfile(signedProfile(t, name, appIdentifier, expiration))
+		require.NoError(t, err)
+		return profile
+	}
+	expired := parse("expired", "TEAMID.com.example.app", now.AddDate(0, 0, -3))
+	valid := parse("valid", "TEAMID.com.example.app", now.AddDate(0, 0, 10).Add(time.Hour))
+	wildcard := parse("wildcard", "TEAMID.*", now.AddDate(0, 0, 5))
+	other := parse("other", "TEAMID.com.example.other", now.AddDate(1, 0, 0))
+
+	t.Run("valid profile", func(t *testing.T) {
+		validity, err := provisioningValidity("TEAMID.com.example.app", []Profile{expired, valid, other}, now)
+		require.NoError(t, err)
+		assert.Equal(t, "valid", validity.Profile.Name)
+		assert.Equal(t, 10, validity.DaysUntilExpiry)
+	})
+	t.Run("expired profile", func(t *testing.T) {
+		validity, err := provisioningValidity("TEAMID.com.example.app", []Profile{expired, other}, now)
+		assert.ErrorIs(t, err, ErrProfileExpired)
+		assert.Equal(t, "expired", validity.Profile.Name)
+		assert.Equal(t, -3, validity.DaysUntilExpiry)
+	})
+	t.Run("wildcard profile", func(t *testing.T) {
+		validity, err := provisioningValidity("TEAMID.com.example.app", []Profile{wildcard}, now)
+		require.NoError(t, err)
+		assert.Equal(t, 5, validity.DaysUntilExpiry)
+	})
+	t.Run("no profile", func(t *testing.T) {
+		_, err := provisioningValidity("TEAMID.com.example.app", []Profile{other}, now)
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, ErrProfileExpired)
+	})
+}
@@ -0,0 +1,249 @@
+// Package mobilebackup2 creates iTunes compatible backups of devices with the mobilebackup2 service and restores
+// devices from them. A backup is a directory named after the udid of the device that contains Info.plist,
+// Status.plist, Manifest.plist, Manifest.db and the backed up files, stored in subdirectories named after the first
+// two characters of their hash.
+package mobilebackup2
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/danielpaulus/go-ios/ios"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	usbmuxdServiceName = "com.apple.mobilebackup2"
+	shimServiceName    = "com.apple.mobilebackup2.shim.remote"
+)
+
+// backupDomain is the lockdown domain containing the backup settings of the device
+const backupDomain = "com.apple.mobile.backup"
+
+// supportedProtocolVersions are the versions of the mobilebackup2 protocol that are implemented here
+var supportedProtocolVersions = []float64{2.0, 2.1}
+
+// BackupOptions configure Backup
+type BackupOptions struct {
+	// Full forces a full backup. Otherwise the device only sends the files that changed since the last backup, if
+	// there is one in the backup directory.
+	Full bool
+	// Password enables backup encryption with this password, if the device does not encrypt its backups yet. The
+	// device asks for the passcode before it enables encryption. Backups of devices that encrypt their backups
+	// already are encrypted with the password that was set before.
+	Password string
+	// Progress is called with the overall progress of the backup in percent
+	Progress func(percent float64)
+}
+
+// Connection is a session with the mobilebackup2 service. The device sends and receives files of the backup
+// directory during the session.
+type Connection struct {
+	deviceConn ios.DeviceConnectionInterface
+	dl         *deviceLink
+	udid       string
+}
+
+// New connects to the mobilebackup2 service of the device. backupPath is the directory that contains the backups
+// of devices, each in a directory named after the udid.
+func New(device ios.DeviceEntry, backupPath string) (*Connection, error) {
+	deviceConn, err := connect(device)
+	if err != nil {
+		return nil, fmt.Errorf("New: cannot connect to mobilebackup2: %w", err)
+	}
+	c := &Connection{deviceConn: deviceConn, dl: newDeviceLink(deviceConn, backupPath), udid: device.Properties.SerialNumber}
+	err = c.hello()
+	if err != nil {
+		deviceConn.Close()
+		return nil, fmt.Errorf("New: %w", err)
+	}
+	return c, nil
+}
+
+func connect(device ios.DeviceEntry) (ios.DeviceConnectionInterface, error) {
+	if device.SupportsRsd() {
+		return ios.ConnectToShimService(device, shimServiceName)
+	}
+	return ios.ConnectToService(device, usbmuxdServiceName)
+}
+
+func (c *Connection) hello() error {
+	err := c.dl.versionExchange()
+	if err != nil {
+		return err
+	}
+	err = c.dl.sendProcessMessage(map[string]interface{}{"MessageName": "Hello", "SupportedProtocolVersions": supportedProtocolVersions})
+	if err != nil {
+		return fmt.Errorf("hello: %w", err)
+	}
+	response, err := c.dl.run()
+	if err != nil {
+		return fmt.Errorf("hello: %w", err)
+	}
+	log.WithField("version", response["ProtocolVersion"]).Debug("mobilebackup2: connected")
+	return nil
+}
+
+// Close ends the session and closes the connection
+func (c *Connection) Close() error {
+	err := c.dl.disconnect()
+	return errors.Join(err, c.deviceConn.Close())
+}
+
+// Backup lets the device send a backup to the backup directory. The Info.plist and Status.plist of the backup must
+// have been written before, see Backup.
+func (c *Connection) Backup(full bool, progress func(float64)) error {
+	if progress != nil {
+		c.dl.progress = progress
+	}
+	request := map[string]interface{}{"MessageName": "Backup", "TargetIdentifier": c.udid}
+	if full {
+		request["Options"] = map[string]interface{}{"ForceFullBackup": true}
+	}
+	err := c.dl.sendProcessMessage(request)
+	if err != nil {
+		return fmt.Errorf("Backup: %w", err)
+	}
+	_, err = c.dl.run()
+	if err != nil {
+		return fmt.Errorf("Backup: %w", err)
+	}
+	return nil
+}
+
+// ChangePassword enables backup encryption if oldPassword is empty, disables it if newPassword is empty and
+// changes the backup password otherwise. The device asks the user for the passcode to confirm it.
+func (c *Connection) ChangePassword(oldPassword string, newPassword string) error {
+	request := map[string]interface{}{"MessageName": "ChangePassword", "TargetIdentifier": c.udid}
+	if oldPassword != "" {
+		request["OldPassword"] = oldPassword
+	}
+	if newPassword != "" {
+		request["NewPassword"] = newPassword
+	}
+	err := c.dl.sendProcessMessage(request)
+	if err != nil {
+		return fmt.Errorf("ChangePassword: %w", err)
+	}
+	_, err = c.dl.run()
+	if err != nil {
+		return fmt.Errorf("ChangePassword: %w", err)
+	}
+	return nil
+}
+
+// IsEncryptionEnabled returns true if the device encrypts its backups
+func IsEncryptionEnabled(device ios.DeviceEntry) (bool, error) {
+	lockdown, err := ios.ConnectLockdownWithSession(device)
+	if err != nil {
+		return false, fmt.Errorf("IsEncryptionEnabled: %w", err)
+	}
+	defer lockdown.Close()
+	value, err := lockdown.GetValueForDomain("WillEncrypt", backupDomain)
+	if err != nil {
+		return false, fmt.Errorf("IsEncryptionEnabled: %w", err)
+	}
+	enabled, _ := value.(bool)
+	return enabled, nil
+}
+
+// Backup creates an iTunes compatible backup of the device in the directory named after the udid in backupPath.
+// An existing backup in this directory is updated incrementally, unless options.Full is set.
+func Backup(device ios.DeviceEntry, backupPath string, options BackupOptions) error {
+	udid := device.Properties.SerialNumber
+	deviceDir := filepath.Join(backupPath, udid)
+	err := os.MkdirAll(deviceDir, 0o755)
+	if err != nil {
+		return fmt.Errorf("Backup: %w", err)
+	}
+	values, err := ios.GetValues(device)
+	if err != nil {
+		return fmt.Errorf("Backup: failed reading device info: %w", err)
+	}
+	encrypted, err := IsEncryptionEnabled(device)
+	if err != nil {
+		return fmt.Errorf("Backup: %w", err)
+	}
+
+	conn, err := New(device, backupPath)
+	if err != nil {
+		return fmt.Errorf("Backup: %w", err)
+	}
+	defer conn.Close()
+
+	if options.Password != "" && !encrypted {
+		log.Info("Enabling backup encryption, enter the passcode on the device")
+		err = conn.ChangePassword("", options.Password)
+		if err != nil {
+			return fmt.Errorf("Backup: failed enabling encryption: %w", err)
+		}
+	}
+	err = prepareBackupDir(deviceDir, values.Value, options.Full, time.Now())
+	if err != nil {
+		return fmt.Errorf("Backup: %w", err)
+	}
+	return conn.Backup(options.Full, options.Progress)
+}
+
+// prepareBackupDir writes the Info.plist that the device expects and a new Status.plist, if a full backup is
+// created or there is no backup yet
+func prepareBackupDir(deviceDir string, values ios.AllValuesType, full bool, now time.Time) error {
+	err := os.WriteFile(filepath.Join(deviceDir, "Info.plist"), ios.ToPlistBytes(newInfoPlist(values, now)), 0o644)
+	if err != nil {
+		return err
+	}
+	statusPath := filepath.Join(deviceDir, "Status.plist")
+	if _, err := os.Stat(statusPath); full || errors.Is(err, os.ErrNotExist) {
+		err = os.WriteFile(statusPath, ios.ToBinPlistBytes(newStatusPlist(full, now)), 0o644)
+		if err != nil {
+			return err
+		}
+	}
+	if full {
+		err = os.Remove(filepath.Join(deviceDir, "Manifest.plist"))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func newInfoPlist(values ios.AllValuesType, now time.Time) map[string]interface{} {
+	info := map[string]interface{}{
+		"Build Version":     values.BuildVersion,
+		"Device Name":       values.DeviceName,
+		"Display Name":      values.DeviceName,
+		"GUID":              strings.ToUpper(strings.ReplaceAll(uuid.New().String(), "-", "")),
+		"Last Backup Date":  now,
+		"Product Type":      values.ProductType,
+		"Product Version":   values.ProductVersion,
+		"Serial Number":     values.SerialNumber,
+		"Target Identifier": values.UniqueDeviceID,
+		"Target Type":       "Device",
+		"Unique Identifier": strings.ToUpper(values.UniqueDeviceID),
+		"iTunes Version":    "10.0.1",
+	}
+	if values.InternationalMobileEquipmentIdentity != "" {
+		info["IMEI"] = values.InternationalMobileEquipmentIdentity
+	}
+	if values.MobileEquipmentIdentifier != "" {
+		info["MEID"] = values.MobileEquipmentIdentifier
+	}
+	return info
+}
+
+func newStatusPlist(full bool, now time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"BackupState":   "new",
+		"Date":          now,
+		"IsFullBackup":  full,
+		"Version":       "3.3",
+		"SnapshotState": "finished",
+		"UUID":          strings.ToUpper(uuid.New().String()),
+	}
+}
@@ -0,0 +1,236 @@
+package mobilebackup2
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/danielpaulus/go-ios/ios"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"howett.net/plist"
+)
+
+const testUdid = "00008110-0001"
+
+// fakeDevice is the device side of a mobilebackup2 session
+type fakeDevice struct {
+	t     *testing.T
+	conn  net.Conn
+	codec ios.PlistCodecReadWriter
+}
+
+func newTestConnection(t *testing.T, root string) (*Connection, *fakeDevice) {
+	client, device := net.Pipe()
+	t.Cleanup(func() {
+		client.Close()
+		device.Close()
+	})
+	conn := &Connection{deviceConn: ios.NewDeviceConnectionWithRWC(client), dl: newDeviceLink(client, root), udid: testUdid}
+	return conn, &fakeDevice{t: t, conn: device, codec: ios.NewPlistCodecReadWriter(device, device)}
+}
+
+func (d *fakeDevice) send(message ...interface{}) {
+	assert.NoError(d.t, d.codec.Write(message))
+}
+
+func (d *fakeDevice) receive() []interface{} {
+	var message []interface{}
+	assert.NoError(d.t, d.codec.Read(&message))
+	return message
+}
+
+// status reads a DLMessageStatusResponse and returns its code and status
+func (d *fakeDevice) status() (int64, interface{}) {
+	message := d.receive()
+	if !assert.Len(d.t, message, 4) {
+		return 0, nil
+	}
+	assert.Equal(d.t, dlStatusResponse, message[0])
+	return int64(message[1].(uint64)), message[3]
+}
+
+func (d *fakeDevice) writePrefixed(s string) {
+	binary.Write(d.conn, binary.BigEndian, uint32(len(s)))
+	// writes to a net.Pipe block until they are read, even empty ones
+	if s != "" {
+		io.WriteString(d.conn, s)
+	}
+}
+
+func (d *fakeDevice) writeChunk(code byte, data string) {
+	binary.Write(d.conn, binary.BigEndian, uint32(len(data)+1))
+	d.conn.Write(append([]byte{code}, data...))
+}
+
+func (d *fakeDevice) readPrefixed() string {
+	var length uint32
+	assert.NoError(d.t, binary.Read(d.conn, binary.BigEndian, &length))
+	b := make([]byte, length)
+	io.ReadFull(d.conn, b)
+	return string(b)
+}
+
+// readFile reads a file sent with DLMessageDownloadFiles, it returns the content and the last code
+func (d *fakeDevice) readFile() (string, byte) {
+	var content []byte
+	for {
+		var length uint32
+		assert.NoError(d.t, binary.Read(d.conn, binary.BigEndian, &length))
+		chunk := make([]byte, length)
+		io.ReadFull(d.conn, chunk)
+		if chunk[0] != codeFileData {
+			return string(content), chunk[0]
+		}
+		content = append(content, chunk[1:]...)
+	}
+}
+
+func (d *fakeDevice) hello() {
+	d.send(dlVersionExchange, uint64(300), uint64(0))
+	assert.Equal(d.t, []interface{}{dlVersionExchange, dlVersionsOk, uint64(300)}, d.receive())
+	d.send(dlDeviceReady)
+	hello := d.receive()
+	assert.Equal(d.t, "Hello", hello[1].(map[string]interface{})["MessageName"])
+	d.send(dlProcessMessage, map[string]interface{}{"ErrorCode": uint64(0), "MessageName": "Response", "ProtocolVersion": 2.1})
+}
+
+func TestBackup(t *testing.T) {
+	root := t.TempDir()
+	deviceDir := filepath.Join(root, testUdid)
+	require.NoError(t, os.MkdirAll(deviceDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(deviceDir, "Status.plist"), []byte("status"), 0o644))
+	conn, device := newTestConnection(t, root)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		device.hello()
+		request := device.receive()
+		assert.Equal(t, map[string]interface{}{
+			"MessageName":      "Backup",
+			"TargetIdentifier": testUdid,
+			"Options":          map[string]interface{}{"ForceFullBackup": true},
+		}, request[1])
+
+		device.send(dlDownloadFiles, []interface{}{testUdid + "/Status.plist", testUdid + "/Manifest.plist"}, map[string]interface{}{}, 5.0)
+		assert.Equal(t, testUdid+"/Status.plist", device.readPrefixed())
+		content, code := device.readFile()
+		assert.Equal(t, "status", content)
+		assert.Equal(t, byte(codeSuccess), code)
+		assert.Equal(t, testUdid+"/Manifest.plist", device.readPrefixed())
+		_, code = device.readFile()
+		assert.Equal(t, byte(codeErrorLocal), code, "Manifest.plist does not exist")
+		assert.Equal(t, "", device.readPrefixed())
+		statusCode, errs := device.status()
+		assert.Equal(t, int64(statusMultiStatus), statusCode)
+		assert.Contains(t, errs, testUdid+"/Manifest.plist")
+
+		device.send(dlCreateDirectory, testUdid+"/0a")
+		statusCode, _ = device.status()
+		assert.Equal(t, int64(statusOK), statusCode)
+
+		device.send(dlUploadFiles, map[string]interface{}{}, 50.0)
+		device.writePrefixed("/private/var/file")
+		device.writePrefixed(testUdid + "/0a/file")
+		device.writeChunk(codeFileData, "backed up ")
+		device.writeChunk(codeFileData, "content")
+		device.writeChunk(codeSuccess, "")
+		device.writePrefixed("")
+		statusCode, _ = device.status()
+		assert.Equal(t, int64(statusOK), statusCode)
+
+		device.send(dlGetFreeDiskSpace, dlEmptyParameter)
+		statusCode, free := device.status()
+		assert.Equal(t, int64(statusOK), statusCode)
+		assert.NotZero(t, free)
+
+		device.send(dlMoveItems, map[string]interface{}{testUdid + "/0a/file": testUdid + "/0b/file"}, map[string]interface{}{}, 80.0)
+		statusCode, _ = device.status()
+		assert.Equal(t, int64(statusOK), statusCode)
+		device.send(dlCopyItem, testUdid+"/0b", testUdid+"/0c")
+		statusCode, _ = device.status()
+		assert.Equal(t, int64(statusOK), statusCode)
+		device.send(dlRemoveItems, []interface{}{testUdid + "/0b"}, map[string]interface{}{}, 90.0)
+		statusCode, _ = device.status()
+		assert.Equal(t, int64(statusOK), statusCode)
+
+		device.send(dlContentsOfDirectory, testUdid)
+		statusCode, contents := device.status()
+		assert.Equal(t, int64(statusOK), statusCode)
+		assert.Contains(t, contents, "0c")
+		assert.NotContains(t, contents, "0b")
+
+		device.send(dlProcessMessage, map[string]interface{}{"ErrorCode": uint64(0)})
+		assert.Equal(t, dlDisconnect, device.receive()[0])
+	}()
+
+	require.NoError(t, conn.hello())
+	var progress []float64
+	require.NoError(t, conn.Backup(true, func(percent float64) { progress = append(progress, percent) }))
+	require.NoError(t, conn.Close())
+	<-done
+
+	content, err := os.ReadFile(filepath.Join(deviceDir, "0c", "file"))
+	require.NoError(t, err)
+	assert.Equal(t, "backed up content", string(content))
+	assert.Equal(t, []float64{5, 50, 80, 90}, progress)
+}
+
+func TestBackupFails(t *testing.T) {
+	t.Run("device error", func(t *testing.T) {
+		conn, device := newTestConnection(t, t.TempDir())
+		go func() {
+			device.receive()
+			device.send(dlProcessMessage, map[string]interface{}{"ErrorCode": uint64(105), "ErrorDescription": "Insufficient free disk space"})
+		}()
+
+		err := conn.Backup(false, nil)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "Insufficient free disk space")
+		}
+	})
+
+	t.Run("paths outside of the backup directory", func(t *testing.T) {
+		root := t.TempDir()
+		conn, device := newTestConnection(t, filepath.Join(root, "backups"))
+		go func() {
+			device.receive()
+			device.send(dlCreateDirectory, "../escaped")
+		}()
+
+		err := conn.Backup(false, nil)
+		assert.Error(t, err)
+		assert.NoDirExists(t, filepath.Join(root, "escaped"))
+	})
+}
+
+func TestPrepareBackupDir(t *testing.T) {
+	deviceDir := t.TempDir()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	values := ios.AllValuesType{DeviceName: "iPhone", ProductVersion: "17.4", UniqueDeviceID: "00008110-000a"}
+	require.NoError(t, os.WriteFile(filepath.Join(deviceDir, "Manifest.plist"), []byte("old"), 0o644))
+
+	require.NoError(t, prepareBackupDir(deviceDir, values, true, now))
+
+	var info map[string]interface{}
+	b, err := os.ReadFile(filepath.Join(deviceDir, "Info.plist"))
+	require.NoError(t, err)
+	_, err = plist.Unmarshal(b, &info)
+	require.NoError(t, err)
+	assert.Equal(t, "iPhone", info["Display Name"])
+	assert.Equal(t, "00008110-000A", info["Unique Identifier"])
+	assert.Equal(t, now, info["Last Backup Date"].(time.Time).UTC())
+
+	var status map[string]interface{}
+	b, err = os.ReadFile(filepath.Join(deviceDir, "Status.plist"))
+	require.NoError(t, err)
+	_, err = plist.Unmarshal(b, &status)
+	require.NoError(t, err)
+	assert.Equal(t, true, status["IsFullBackup"])
+	assert.NoFileExists(t, filepath.Join(deviceDir, "Manifest.plist"), "a full backup starts without manifest")
+}
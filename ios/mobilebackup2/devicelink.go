@@ -0,0 +1,575 @@
+package mobilebackup2
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/danielpaulus/go-ios/ios"
+	log "github.com/sirupsen/logrus"
+)
+
+// mobilebackup2 uses the DeviceLink protocol. All messages are plist arrays with the message name as the first
+// element. The device tells the host which files to store, send, move or delete during a backup with them.
+const (
+	dlVersionExchange       = "DLMessageVersionExchange"
+	dlDeviceReady           = "DLMessageDeviceReady"
+	dlProcessMessage        = "DLMessageProcessMessage"
+	dlDisconnect            = "DLMessageDisconnect"
+	dlStatusResponse        = "DLMessageStatusResponse"
+	dlDownloadFiles         = "DLMessageDownloadFiles"
+	dlUploadFiles           = "DLMessageUploadFiles"
+	dlGetFreeDiskSpace      = "DLMessageGetFreeDiskSpace"
+	dlContentsOfDirectory   = "DLContentsOfDirectory"
+	dlCreateDirectory       = "DLMessageCreateDirectory"
+	dlMoveFiles             = "DLMessageMoveFiles"
+	dlMoveItems             = "DLMessageMoveItems"
+	dlRemoveFiles           = "DLMessageRemoveFiles"
+	dlRemoveItems           = "DLMessageRemoveItems"
+	dlCopyItem              = "DLMessageCopyItem"
+	dlPurgeDiskSpace        = "DLMessagePurgeDiskSpace"
+	dlVersionsOk            = "DLVersionsOk"
+	dlEmptyParameter        = "___EmptyParameterString___"
+	dlSupportedMajorVersion = 300
+)
+
+// the codes that precede the chunks of files that are sent with DLMessageDownloadFiles and DLMessageUploadFiles
+const (
+	codeSuccess     = 0x00
+	codeErrorLocal  = 0x06
+	codeErrorRemote = 0x0b
+	codeFileData    = 0x0c
+)
+
+const (
+	statusOK          = 0
+	statusMultiStatus = -13
+	// fileChunkSize is the size of the chunks files are sent to the device in
+	fileChunkSize = 32 * 1024
+)
+
+// deviceLink executes the DeviceLink messages of the device on the files of the backup directory root
+type deviceLink struct {
+	conn  io.ReadWriter
+	codec ios.PlistCodecReadWriter
+	root  string
+	// progress is called with the overall progress in percent, if the device sends it
+	progress func(float64)
+}
+
+func newDeviceLink(conn io.ReadWriter, root string) *deviceLink {
+	return &deviceLink{conn: conn, codec: ios.NewPlistCodecReadWriter(conn, conn), root: root, progress: func(float64) {}}
+}
+
+func (dl *deviceLink) readMessage() ([]interface{}, error) {
+	var message []interface{}
+	err := dl.codec.Read(&message)
+	if err != nil {
+		return nil, err
+	}
+	if len(message) == 0 {
+		return nil, errors.New("received empty DeviceLink message")
+	}
+	if _, ok := message[0].(string); !ok {
+		return nil, fmt.Errorf("received DeviceLink message without name: %+v", message)
+	}
+	return message, nil
+}
+
+// versionExchange has to be done before sending any other message
+func (dl *deviceLink) versionExchange() error {
+	message, err := dl.readMessage()
+	if err != nil {
+		return fmt.Errorf("versionExchange: %w", err)
+	}
+	if message[0] != dlVersionExchange || len(message) < 2 {
+		return fmt.Errorf("versionExchange: unexpected message %+v", message)
+	}
+	major, ok := message[1].(uint64)
+	if !ok || major > dlSupportedMajorVersion {
+		return fmt.Errorf("versionExchange: unsupported DeviceLink version %v", message[1])
+	}
+	err = dl.codec.Write([]interface{}{dlVersionExchange, dlVersionsOk, major})
+	if err != nil {
+		return fmt.Errorf("versionExchange: %w", err)
+	}
+	message, err = dl.readMessage()
+	if err != nil {
+		return fmt.Errorf("versionExchange: %w", err)
+	}
+	if message[0] != dlDeviceReady {
+		return fmt.Errorf("versionExchange: device is not ready: %+v", message)
+	}
+	return nil
+}
+
+func (dl *deviceLink) sendProcessMessage(message map[string]interface{}) error {
+	return dl.codec.Write([]interface{}{dlProcessMessage, message})
+}
+
+func (dl *deviceLink) disconnect() error {
+	return dl.codec.Write([]interface{}{dlDisconnect, dlEmptyParameter})
+}
+
+// run handles the requests of the device until it sends the result of the process message that was sent before
+func (dl *deviceLink) run() (map[string]interface{}, error) {
+	for {
+		message, err := dl.readMessage()
+		if err != nil {
+			return nil, err
+		}
+		name := message[0].(string)
+		dl.reportProgress(name, message)
+		switch name {
+		case dlProcessMessage:
+			return processMessageResult(message)
+		case dlDisconnect:
+			return nil, errors.New("device disconnected")
+		case dlDownloadFiles:
+			err = dl.downloadFiles(message)
+		case dlUploadFiles:
+			err = dl.uploadFiles()
+		case dlGetFreeDiskSpace:
+			err = dl.getFreeDiskSpace()
+		case dlContentsOfDirectory:
+			err = dl.contentsOfDirectory(message)
+		case dlCreateDirectory:
+			err = dl.createDirectory(message)
+		case dlMoveFiles, dlMoveItems:
+			err = dl.moveItems(message)
+		case dlRemoveFiles, dlRemoveItems:
+			err = dl.removeItems(message)
+		case dlCopyItem:
+			err = dl.copyItem(message)
+		case dlPurgeDiskSpace:
+			err = dl.statusResponse(-1, "Operation not supported", map[string]interface{}{})
+		default:
+			log.WithField("message", message).Warn("mobilebackup2: unknown DeviceLink message")
+			err = dl.statusResponse(-1, "Operation not supported", map[string]interface{}{})
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed handling %s: %w", name, err)
+		}
+	}
+}
+
+func processMessageResult(message []interface{}) (map[string]interface{}, error) {
+	if len(message) < 2 {
+		return nil, fmt.Errorf("invalid %s: %+v", dlProcessMessage, message)
+	}
+	result, ok := message[1].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid %s: %+v", dlProcessMessage, message)
+	}
+	if code, ok := result["ErrorCode"].(uint64); ok && code != 0 {
+		return result, fmt.Errorf("device failed with error code %d: %v", int64(code), result["ErrorDescription"])
+	}
+	return result, nil
+}
+
+// reportProgress passes the overall progress contained in some of the messages to dl.progress
+func (dl *deviceLink) reportProgress(name string, message []interface{}) {
+	index := 3
+	switch name {
+	case dlUploadFiles:
+		index = 2
+	case dlDownloadFiles, dlMoveFiles, dlMoveItems, dlRemoveFiles, dlRemoveItems:
+	default:
+		return
+	}
+	if len(message) <= index {
+		return
+	}
+	if progress, ok := message[index].(float64); ok && progress > 0 {
+		dl.progress(progress)
+	}
+}
+
+// statusResponse answers a request of the device. The device expects the code as an unsigned integer, negative
+// error codes are sent in two's complement.
+func (dl *deviceLink) statusResponse(code int64, description string, status interface{}) error {
+	if description == "" {
+		description = dlEmptyParameter
+	}
+	return dl.codec.Write([]interface{}{dlStatusResponse, uint64(code), description, status})
+}
+
+// path returns the path of the file name relative to the backup directory. Paths leaving the backup directory are
+// rejected.
+func (dl *deviceLink) path(name string) (string, error) {
+	if !filepath.IsLocal(filepath.FromSlash(name)) {
+		return "", fmt.Errorf("invalid backup path '%s'", name)
+	}
+	return filepath.Join(dl.root, filepath.FromSlash(name)), nil
+}
+
+func stringArgument(message []interface{}, index int) (string, error) {
+	if len(message) <= index {
+		return "", fmt.Errorf("%v has no argument %d", message[0], index)
+	}
+	s, ok := message[index].(string)
+	if !ok {
+		return "", fmt.Errorf("argument %d of %v is not a string: %+v", index, message[0], message[index])
+	}
+	return s, nil
+}
+
+// downloadFiles sends the requested files of the backup directory to the device. Files that do not exist are
+// reported in a multi status response, this is expected for the first backup of a device.
+func (dl *deviceLink) downloadFiles(message []interface{}) error {
+	if len(message) < 2 {
+		return fmt.Errorf("invalid message: %+v", message)
+	}
+	files, ok := message[1].([]interface{})
+	if !ok {
+		return fmt.Errorf("invalid file list: %+v", message[1])
+	}
+	errs := map[string]interface{}{}
+	for _, f := range files {
+		name, ok := f.(string)
+		if !ok {
+			continue
+		}
+		err := dl.sendFile(name)
+		if err == nil {
+			continue
+		}
+		var pathErr *fs.PathError
+		if !errors.As(err, &pathErr) {
+			return err
+		}
+		errs[name] = map[string]interface{}{"DLFileErrorString": pathErr.Err.Error(), "DLFileErrorCode": uint64(deviceErrorCode(err))}
+		err = dl.writeChunk(codeErrorLocal, []byte(pathErr.Err.Error()))
+		if err != nil {
+			return err
+		}
+	}
+	err := binary.Write(dl.conn, binary.BigEndian, uint32(0))
+	if err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return dl.statusResponse(statusMultiStatus, "Multi status", errs)
+	}
+	return dl.statusResponse(statusOK, "", map[string]interface{}{})
+}
+
+// sendFile sends the name and the contents of the file. Errors reading the file are returned as *fs.PathError
+// before any content was sent, all other errors are errors of the connection.
+func (dl *deviceLink) sendFile(name string) error {
+	err := dl.writePrefixed([]byte(name))
+	if err != nil {
+		return err
+	}
+	path, err := dl.path(name)
+	if err != nil {
+		return &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	chunk := make([]byte, fileChunkSize)
+	for {
+		n, err := f.Read(chunk)
+		if n > 0 {
+			if err := dl.writeChunk(codeFileData, chunk[:n]); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return dl.writeChunk(codeSuccess, nil)
+}
+
+func (dl *deviceLink) writePrefixed(b []byte) error {
+	err := binary.Write(dl.conn, binary.BigEndian, uint32(len(b)))
+	if err != nil {
+		return err
+	}
+	_, err = dl.conn.Write(b)
+	return err
+}
+
+func (dl *deviceLink) writeChunk(code byte, data []byte) error {
+	header := make([]byte, 5)
+	binary.BigEndian.PutUint32(header, uint32(len(data)+1))
+	header[4] = code
+	_, err := dl.conn.Write(append(header, data...))
+	return err
+}
+
+func (dl *deviceLink) readPrefixed() ([]byte, error) {
+	var length uint32
+	err := binary.Read(dl.conn, binary.BigEndian, &length)
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, length)
+	_, err = io.ReadFull(dl.conn, b)
+	return b, err
+}
+
+// uploadFiles receives files from the device and stores them in the backup directory
+func (dl *deviceLink) uploadFiles() error {
+	for {
+		deviceName, err := dl.readPrefixed()
+		if err != nil {
+			return err
+		}
+		// an empty name ends the upload
+		if len(deviceName) == 0 {
+			break
+		}
+		name, err := dl.readPrefixed()
+		if err != nil {
+			return err
+		}
+		err = dl.receiveFile(string(name))
+		if err != nil {
+			return err
+		}
+	}
+	return dl.statusResponse(statusOK, "", map[string]interface{}{})
+}
+
+func (dl *deviceLink) receiveFile(name string) error {
+	path, err := dl.path(name)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	for {
+		var header struct {
+			Length uint32
+			Code   byte
+		}
+		err := binary.Read(dl.conn, binary.BigEndian, &header)
+		if err != nil {
+			return err
+		}
+		if header.Length == 0 {
+			return fmt.Errorf("invalid chunk of %s", name)
+		}
+		data := io.LimitReader(dl.conn, int64(header.Length-1))
+		switch header.Code {
+		case codeFileData:
+			_, err = io.Copy(f, data)
+			if err != nil {
+				return err
+			}
+		case codeSuccess:
+			_, err = io.Copy(io.Discard, data)
+			return err
+		case codeErrorRemote:
+			message, err := io.ReadAll(data)
+			if err != nil {
+				return err
+			}
+			log.WithFields(log.Fields{"file": name, "error": string(message)}).Warn("mobilebackup2: device failed sending file")
+			return nil
+		default:
+			return fmt.Errorf("unknown code %x receiving %s", header.Code, name)
+		}
+	}
+}
+
+func (dl *deviceLink) getFreeDiskSpace() error {
+	free, err := freeDiskSpace(dl.root)
+	if err != nil {
+		return dl.statusResponse(-1, err.Error(), uint64(0))
+	}
+	return dl.statusResponse(statusOK, "", free)
+}
+
+func (dl *deviceLink) contentsOfDirectory(message []interface{}) error {
+	name, err := stringArgument(message, 1)
+	if err != nil {
+		return err
+	}
+	path, err := dl.path(name)
+	if err != nil {
+		return err
+	}
+	contents := map[string]interface{}{}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		log.WithFields(log.Fields{"dir": name, "error": err}).Debug("mobilebackup2: cannot list directory")
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		fileType := "DLFileTypeUnknown"
+		if info.IsDir() {
+			fileType = "DLFileTypeDirectory"
+		} else if info.Mode().IsRegular() {
+			fileType = "DLFileTypeRegular"
+		}
+		contents[entry.Name()] = map[string]interface{}{
+			"DLFileType":             fileType,
+			"DLFileSize":             uint64(info.Size()),
+			"DLFileModificationDate": info.ModTime(),
+		}
+	}
+	return dl.statusResponse(statusOK, "", contents)
+}
+
+func (dl *deviceLink) createDirectory(message []interface{}) error {
+	name, err := stringArgument(message, 1)
+	if err != nil {
+		return err
+	}
+	path, err := dl.path(name)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(path, 0o755)
+	if err != nil {
+		return dl.statusResponse(deviceErrorCode(err), err.Error(), map[string]interface{}{})
+	}
+	return dl.statusResponse(statusOK, "", map[string]interface{}{})
+}
+
+func (dl *deviceLink) moveItems(message []interface{}) error {
+	if len(message) < 2 {
+		return fmt.Errorf("invalid message: %+v", message)
+	}
+	items, ok := message[1].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("invalid items: %+v", message[1])
+	}
+	for from, to := range items {
+		toName, ok := to.(string)
+		if !ok {
+			return fmt.Errorf("invalid destination of %s: %+v", from, to)
+		}
+		src, err := dl.path(from)
+		if err != nil {
+			return err
+		}
+		dst, err := dl.path(toName)
+		if err != nil {
+			return err
+		}
+		err = os.MkdirAll(filepath.Dir(dst), 0o755)
+		if err == nil {
+			err = os.RemoveAll(dst)
+		}
+		if err == nil {
+			err = os.Rename(src, dst)
+		}
+		if err != nil {
+			return dl.statusResponse(deviceErrorCode(err), err.Error(), map[string]interface{}{})
+		}
+	}
+	return dl.statusResponse(statusOK, "", map[string]interface{}{})
+}
+
+func (dl *deviceLink) removeItems(message []interface{}) error {
+	if len(message) < 2 {
+		return fmt.Errorf("invalid message: %+v", message)
+	}
+	items, ok := message[1].([]interface{})
+	if !ok {
+		return fmt.Errorf("invalid items: %+v", message[1])
+	}
+	for _, item := range items {
+		name, ok := item.(string)
+		if !ok {
+			continue
+		}
+		path, err := dl.path(name)
+		if err != nil {
+			return err
+		}
+		err = os.RemoveAll(path)
+		if err != nil {
+			return dl.statusResponse(deviceErrorCode(err), err.Error(), map[string]interface{}{})
+		}
+	}
+	return dl.statusResponse(statusOK, "", map[string]interface{}{})
+}
+
+func (dl *deviceLink) copyItem(message []interface{}) error {
+	from, err := stringArgument(message, 1)
+	if err != nil {
+		return err
+	}
+	to, err := stringArgument(message, 2)
+	if err != nil {
+		return err
+	}
+	src, err := dl.path(from)
+	if err != nil {
+		return err
+	}
+	dst, err := dl.path(to)
+	if err != nil {
+		return err
+	}
+	err = copyPath(src, dst)
+	if err != nil {
+		return dl.statusResponse(deviceErrorCode(err), err.Error(), map[string]interface{}{})
+	}
+	return dl.statusResponse(statusOK, "", map[string]interface{}{})
+}
+
+// copyPath copies the file or directory src to dst
+func copyPath(src string, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, strings.TrimPrefix(path, src))
+		if d.IsDir() {
+			return os.MkdirAll(target, 0o755)
+		}
+		err = os.MkdirAll(filepath.Dir(target), 0o755)
+		if err != nil {
+			return err
+		}
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		out, err := os.Create(target)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(out, in)
+		return errors.Join(err, out.Close())
+	})
+}
+
+// deviceErrorCode converts file system errors to the error codes of the device
+func deviceErrorCode(err error) int64 {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return -6
+	case errors.Is(err, fs.ErrExist):
+		return -7
+	}
+	return -1
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package mobilebackup2
+
+import "golang.org/x/sys/unix"
+
+// freeDiskSpace returns the bytes available to the user on the file system of path
+func freeDiskSpace(path string) (uint64, error) {
+	var stat unix.Statfs_t
+	err := unix.Statfs(path, &stat)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
@@ -0,0 +1,187 @@
+package mobilebackup2
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"howett.net/plist"
+)
+
+// the flags of the Files table of Manifest.db
+const (
+	fileFlagFile      = 1
+	fileFlagDirectory = 2
+)
+
+// BackupFile is an entry of the Manifest.db of a backup. The content of files is stored in the backup directory
+// as <first two characters of FileID>/<FileID>.
+type BackupFile struct {
+	FileID string
+	// Domain is the domain the file belongs to, f.ex. HomeDomain or AppDomain-<bundleID> for the files of apps
+	Domain string
+	// RelativePath is the path of the file relative to the directory of its domain
+	RelativePath string
+	Flags        int64
+}
+
+// IsDir returns true if the entry is a directory
+func (f BackupFile) IsDir() bool {
+	return f.Flags == fileFlagDirectory
+}
+
+// IsFile returns true if the entry is a regular file
+func (f BackupFile) IsFile() bool {
+	return f.Flags == fileFlagFile
+}
+
+// ExtractOptions select the files of a backup. Zero values select all files.
+type ExtractOptions struct {
+	// Domain selects the files of the domain, f.ex. AppDomain-com.example.app
+	Domain string
+	// Path selects the file or directory with the path relative to the domain and everything inside of it
+	Path string
+}
+
+func (o ExtractOptions) matches(f BackupFile) bool {
+	if o.Domain != "" && f.Domain != o.Domain {
+		return false
+	}
+	if o.Path == "" {
+		return true
+	}
+	selected := path.Clean(o.Path)
+	return f.RelativePath == selected || strings.HasPrefix(f.RelativePath, selected+"/")
+}
+
+// ListFiles returns the files of the backup in deviceDir, the directory of the backup named after the udid, that
+// match options. Encrypted backups are not supported, as their Manifest.db is encrypted too.
+func ListFiles(deviceDir string, options ExtractOptions) ([]BackupFile, error) {
+	err := checkUnencrypted(deviceDir)
+	if err != nil {
+		return nil, fmt.Errorf("ListFiles: %w", err)
+	}
+	db, err := openSqlite(filepath.Join(deviceDir, "Manifest.db"))
+	if err != nil {
+		return nil, fmt.Errorf("ListFiles: failed opening Manifest.db: %w", err)
+	}
+	columns, rows, err := db.table("Files")
+	if err != nil {
+		return nil, fmt.Errorf("ListFiles: %w", err)
+	}
+	index := map[string]int{}
+	for i, name := range columns {
+		index[name] = i
+	}
+	for _, column := range []string{"fileID", "domain", "relativePath", "flags"} {
+		if _, ok := index[column]; !ok {
+			return nil, fmt.Errorf("ListFiles: Manifest.db has no column %s", column)
+		}
+	}
+	value := func(row []interface{}, column string) interface{} {
+		if i := index[column]; i < len(row) {
+			return row[i]
+		}
+		return nil
+	}
+	var files []BackupFile
+	for _, row := range rows {
+		f := BackupFile{}
+		f.FileID, _ = value(row, "fileID").(string)
+		f.Domain, _ = value(row, "domain").(string)
+		f.RelativePath, _ = value(row, "relativePath").(string)
+		f.Flags, _ = value(row, "flags").(int64)
+		if options.matches(f) {
+			files = append(files, f)
+		}
+	}
+	return files, nil
+}
+
+// checkUnencrypted returns an error if the backup in deviceDir is encrypted
+func checkUnencrypted(deviceDir string) error {
+	b, err := os.ReadFile(filepath.Join(deviceDir, "Manifest.plist"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%s contains no backup", deviceDir)
+		}
+		return err
+	}
+	var m manifest
+	_, err = plist.Unmarshal(b, &m)
+	if err != nil {
+		return fmt.Errorf("invalid Manifest.plist: %w", err)
+	}
+	if m.IsEncrypted {
+		return errors.New("extracting files of encrypted backups is not supported")
+	}
+	return nil
+}
+
+// Extract copies the files of the backup in deviceDir that match options to outDir, each file is written to
+// outDir/<domain>/<relative path>. It returns the number of extracted files. Symlinks are skipped.
+func Extract(deviceDir string, outDir string, options ExtractOptions) (int, error) {
+	files, err := ListFiles(deviceDir, options)
+	if err != nil {
+		return 0, fmt.Errorf("Extract: %w", err)
+	}
+	extracted := 0
+	for _, f := range files {
+		target := filepath.Join(f.Domain, filepath.FromSlash(f.RelativePath))
+		if !filepath.IsLocal(target) {
+			log.WithFields(log.Fields{"domain": f.Domain, "path": f.RelativePath}).Warn("mobilebackup2: skipping file with invalid path")
+			continue
+		}
+		target = filepath.Join(outDir, target)
+		switch {
+		case f.IsDir():
+			err = os.MkdirAll(target, 0o755)
+		case f.IsFile():
+			var src string
+			src, err = filePath(f.FileID)
+			if err == nil {
+				err = extractFile(filepath.Join(deviceDir, src), target)
+			}
+			if err == nil {
+				extracted++
+			}
+		default:
+			log.WithFields(log.Fields{"domain": f.Domain, "path": f.RelativePath}).Debug("mobilebackup2: skipping symlink")
+		}
+		if err != nil {
+			return extracted, fmt.Errorf("Extract: failed extracting %s/%s: %w", f.Domain, f.RelativePath, err)
+		}
+	}
+	return extracted, nil
+}
+
+// filePath returns the path of the content of the file with fileID relative to the backup directory
+func filePath(fileID string) (string, error) {
+	if len(fileID) < 2 || !filepath.IsLocal(fileID) || strings.ContainsAny(fileID, `/\`) {
+		return "", fmt.Errorf("invalid file id '%s'", fileID)
+	}
+	return filepath.Join(fileID[:2], fileID), nil
+}
+
+func extractFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	err = os.MkdirAll(filepath.Dir(dst), 0o755)
+	if err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	return errors.Join(err, out.Close())
+}
@@ -0,0 +1,154 @@
+package mobilebackup2
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/danielpaulus/go-ios/ios"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	notesFileID = "ab0000000000000000000000000000000000000002"
+	smsFileID   = "ae0000000000000000000000000000000000000005"
+)
+
+// newTestBackup creates a backup with the Manifest.db of test-fixture, which has a page size of 512 bytes so that
+// the Files table spans several levels of pages and a row with overflow pages
+func newTestBackup(t *testing.T, encrypted bool) string {
+	deviceDir := t.TempDir()
+	manifestDB, err := os.ReadFile(filepath.Join("test-fixture", "Manifest.db"))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(deviceDir, "Manifest.db"), manifestDB, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(deviceDir, "Manifest.plist"), ios.ToPlistBytes(map[string]interface{}{"IsEncrypted": encrypted}), 0o644))
+	for fileID, content := range map[string]string{notesFileID: "notes", smsFileID: "sms"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(deviceDir, fileID[:2]), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(deviceDir, fileID[:2], fileID), []byte(content), 0o644))
+	}
+	require.NoError(t, os.MkdirAll(filepath.Join(deviceDir, "ac"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(deviceDir, "ac", "ac0000000000000000000000000000000000000003"), []byte("prefs"), 0o644))
+	return deviceDir
+}
+
+func TestListFiles(t *testing.T) {
+	deviceDir := newTestBackup(t, false)
+
+	files, err := ListFiles(deviceDir, ExtractOptions{})
+	require.NoError(t, err)
+	assert.Len(t, files, 105)
+
+	files, err = ListFiles(deviceDir, ExtractOptions{Domain: "AppDomain-com.example.app", Path: "Documents/"})
+	require.NoError(t, err)
+	assert.Equal(t, []BackupFile{
+		{FileID: "aa0000000000000000000000000000000000000001", Domain: "AppDomain-com.example.app", RelativePath: "Documents", Flags: fileFlagDirectory},
+		{FileID: notesFileID, Domain: "AppDomain-com.example.app", RelativePath: "Documents/notes.txt", Flags: fileFlagFile},
+		{FileID: "ad0000000000000000000000000000000000000004", Domain: "AppDomain-com.example.app", RelativePath: "Documents/link", Flags: 4},
+	}, files)
+}
+
+func TestExtract(t *testing.T) {
+	deviceDir := newTestBackup(t, false)
+	outDir := t.TempDir()
+
+	extracted, err := Extract(deviceDir, outDir, ExtractOptions{Domain: "AppDomain-com.example.app"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, extracted)
+
+	content, err := os.ReadFile(filepath.Join(outDir, "AppDomain-com.example.app", "Documents", "notes.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "notes", string(content))
+	content, err = os.ReadFile(filepath.Join(outDir, "AppDomain-com.example.app", "Library", "Preferences", "com.example.app.plist"))
+	require.NoError(t, err)
+	assert.Equal(t, "prefs", string(content))
+	assert.NoFileExists(t, filepath.Join(outDir, "AppDomain-com.example.app", "Documents", "link"))
+	assert.NoDirExists(t, filepath.Join(outDir, "HomeDomain"))
+}
+
+func TestExtractSingleFile(t *testing.T) {
+	deviceDir := newTestBackup(t, false)
+	outDir := t.TempDir()
+
+	extracted, err := Extract(deviceDir, outDir, ExtractOptions{Domain: "HomeDomain", Path: "Library/SMS/sms.db"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, extracted)
+	content, err := os.ReadFile(filepath.Join(outDir, "HomeDomain", "Library", "SMS", "sms.db"))
+	require.NoError(t, err)
+	assert.Equal(t, "sms", string(content))
+}
+
+func TestExtractEncryptedBackup(t *testing.T) {
+	deviceDir := newTestBackup(t, true)
+
+	_, err := Extract(deviceDir, t.TempDir(), ExtractOptions{})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "encrypted")
+	}
+}
+
+func TestFilePath(t *testing.T) {
+	p, err := filePath(notesFileID)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("ab", notesFileID), p)
+
+	for _, fileID := range []string{"", "a", "..", "../etc", "ab/cd"} {
+		_, err = filePath(fileID)
+		assert.Error(t, err, fileID)
+	}
+}
+
+func TestSqliteOverflowPayload(t *testing.T) {
+	db, err := openSqlite(filepath.Join("test-fixture", "Manifest.db"))
+	require.NoError(t, err)
+
+	columns, rows, err := db.table("Files")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"fileID", "domain", "relativePath", "flags", "file"}, columns)
+	for _, row := range rows {
+		if row[0] == notesFileID {
+			assert.Equal(t, []byte(strings.Repeat("x", 2000)), row[4])
+			return
+		}
+	}
+	t.Fatal("row not found")
+}
+
+func TestParseSqliteInvalid(t *testing.T) {
+	_, err := parseSqlite([]byte("not a database"))
+	assert.Error(t, err)
+}
+
+func TestParseRecordInvalidHeaderSize(t *testing.T) {
+	for _, record := range [][]byte{{0x00}, {0x00, 0x01, 0x41}, {0x81, 0x01, 0x01}, {0x05, 0x01}} {
+		_, err := parseRecord(record)
+		assert.Error(t, err, "record %x", record)
+	}
+
+	values, err := parseRecord([]byte{0x02, 0x01, 0x41})
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{int64(0x41)}, values)
+}
+
+func TestReadVarint(t *testing.T) {
+	v, n := readVarint([]byte{0x7f})
+	assert.Equal(t, uint64(0x7f), v)
+	assert.Equal(t, 1, n)
+
+	v, n = readVarint([]byte{0x81, 0x00})
+	assert.Equal(t, uint64(0x80), v)
+	assert.Equal(t, 2, n)
+
+	v, n = readVarint([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	assert.Equal(t, ^uint64(0), v)
+	assert.Equal(t, 9, n)
+
+	_, n = readVarint([]byte{0x81})
+	assert.Equal(t, 0, n)
+}
+
+func TestColumnNames(t *testing.T) {
+	names := columnNames(`CREATE TABLE "Files" (fileID TEXT PRIMARY KEY, "domain" TEXT, price NUMERIC(10, 2), PRIMARY KEY (fileID, domain))`)
+	assert.Equal(t, []string{"fileID", "domain", "price"}, names)
+}
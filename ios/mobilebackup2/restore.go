@@ -0,0 +1,112 @@
+package mobilebackup2
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/danielpaulus/go-ios/ios"
+	"howett.net/plist"
+)
+
+// RestoreOptions configure Restore
+type RestoreOptions struct {
+	// Source is the udid of the device the backup was created of, it defaults to the udid of the restored device
+	Source string
+	// SystemFiles restores the system files contained in the backup too
+	SystemFiles bool
+	// WithSettings restores the settings of the device from the backup along with the files, otherwise the current
+	// settings are kept. The device does not support restoring only the settings.
+	WithSettings bool
+	// Reboot reboots the device after the restore finished
+	Reboot bool
+	// RemoveItemsNotRestored removes the files from the device that are not contained in the backup
+	RemoveItemsNotRestored bool
+	// Password is required to restore encrypted backups
+	Password string
+	// Progress is called with the overall progress of the restore in percent
+	Progress func(percent float64)
+}
+
+// Restore sends the backup in the directory named after options.Source in the backup directory to the device.
+func (c *Connection) Restore(options RestoreOptions) error {
+	if options.Progress != nil {
+		c.dl.progress = options.Progress
+	}
+	err := c.dl.sendProcessMessage(newRestoreRequest(c.udid, options))
+	if err != nil {
+		return fmt.Errorf("Restore: %w", err)
+	}
+	_, err = c.dl.run()
+	if err != nil {
+		return fmt.Errorf("Restore: %w", err)
+	}
+	return nil
+}
+
+func newRestoreRequest(udid string, options RestoreOptions) map[string]interface{} {
+	source := options.Source
+	if source == "" {
+		source = udid
+	}
+	restoreOptions := map[string]interface{}{
+		"RestoreShouldReboot":     options.Reboot,
+		"RestoreDontCopyBackup":   true,
+		"RestorePreserveSettings": !options.WithSettings,
+		"RestoreSystemFiles":      options.SystemFiles,
+		"RemoveItemsNotRestored":  options.RemoveItemsNotRestored,
+	}
+	if options.Password != "" {
+		restoreOptions["Password"] = options.Password
+	}
+	return map[string]interface{}{
+		"MessageName":      "Restore",
+		"TargetIdentifier": udid,
+		"SourceIdentifier": source,
+		"Options":          restoreOptions,
+	}
+}
+
+// Restore restores the device from the backup in backupPath that was created with Backup. Disable "Find My" on
+// the device before restoring, the device refuses the restore otherwise.
+func Restore(device ios.DeviceEntry, backupPath string, options RestoreOptions) error {
+	if options.Source == "" {
+		options.Source = device.Properties.SerialNumber
+	}
+	err := checkBackup(filepath.Join(backupPath, options.Source), options.Password)
+	if err != nil {
+		return fmt.Errorf("Restore: %w", err)
+	}
+	conn, err := New(device, backupPath)
+	if err != nil {
+		return fmt.Errorf("Restore: %w", err)
+	}
+	defer conn.Close()
+	return conn.Restore(options)
+}
+
+type manifest struct {
+	IsEncrypted bool
+}
+
+// checkBackup returns an error if deviceDir contains no complete backup or if the password of an encrypted backup
+// is missing
+func checkBackup(deviceDir string, password string) error {
+	b, err := os.ReadFile(filepath.Join(deviceDir, "Manifest.plist"))
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%s contains no backup", deviceDir)
+	}
+	if err != nil {
+		return err
+	}
+	var m manifest
+	_, err = plist.Unmarshal(b, &m)
+	if err != nil {
+		return fmt.Errorf("invalid Manifest.plist: %w", err)
+	}
+	if m.IsEncrypted && password == "" {
+		return errors.New("the backup is encrypted, the password is required to restore it")
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package mobilebackup2
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/danielpaulus/go-ios/ios"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestore(t *testing.T) {
+	root := t.TempDir()
+	source := "00008110-0002"
+	require.NoError(t, os.MkdirAll(filepath.Join(root, source), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, source, "Manifest.db"), []byte("manifest"), 0o644))
+	conn, device := newTestConnection(t, root)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		request := device.receive()
+		assert.Equal(t, map[string]interface{}{
+			"MessageName":      "Restore",
+			"TargetIdentifier": testUdid,
+			"SourceIdentifier": source,
+			"Options": map[string]interface{}{
+				"RestoreShouldReboot":     true,
+				"RestoreDontCopyBackup":   true,
+				"RestorePreserveSettings": true,
+				"RestoreSystemFiles":      true,
+				"RemoveItemsNotRestored":  false,
+				"Password":                "secret",
+			},
+		}, request[1])
+
+		device.send(dlDownloadFiles, []interface{}{source + "/Manifest.db"}, map[string]interface{}{}, 30.0)
+		assert.Equal(t, source+"/Manifest.db", device.readPrefixed())
+		content, code := device.readFile()
+		assert.Equal(t, "manifest", content)
+		assert.Equal(t, byte(codeSuccess), code)
+		assert.Equal(t, "", device.readPrefixed())
+		statusCode, _ := device.status()
+		assert.Equal(t, int64(statusOK), statusCode)
+
+		device.send(dlProcessMessage, map[string]interface{}{"ErrorCode": uint64(0)})
+	}()
+
+	var progress []float64
+	err := conn.Restore(RestoreOptions{
+		Source:      source,
+		SystemFiles: true,
+		Reboot:      true,
+		Password:    "secret",
+		Progress:    func(percent float64) { progress = append(progress, percent) },
+	})
+	require.NoError(t, err)
+	<-done
+	assert.Equal(t, []float64{30}, progress)
+}
+
+func TestRestoreRequestDefaults(t *testing.T) {
+	request := newRestoreRequest(testUdid, RestoreOptions{WithSettings: true})
+
+	assert.Equal(t, testUdid, request["SourceIdentifier"])
+	options := request["Options"].(map[string]interface{})
+	assert.Equal(t, false, options["RestoreShouldReboot"])
+	assert.Equal(t, false, options["RestorePreserveSettings"], "settings are restored from the backup")
+	assert.NotContains(t, options, "Password")
+}
+
+func TestCheckBackup(t *testing.T) {
+	deviceDir := t.TempDir()
+	if err := checkBackup(deviceDir, ""); assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "contains no backup")
+	}
+
+	require.NoError(t, os.WriteFile(filepath.Join(deviceDir, "Manifest.plist"), ios.ToPlistBytes(map[string]interface{}{"IsEncrypted": true}), 0o644))
+	if err := checkBackup(deviceDir, ""); assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "password")
+	}
+	assert.NoError(t, checkBackup(deviceDir, "secret"))
+}
@@ -0,0 +1,344 @@
+package mobilebackup2
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+// sqliteDB reads the tables of SQLite databases like the Manifest.db of backups. It only supports what is needed to
+// read the rows of tables: UTF-8 databases without WAL and full table scans.
+type sqliteDB struct {
+	data     []byte
+	pageSize int
+	// usableSize is the page size without the reserved bytes at the end of each page
+	usableSize int
+}
+
+const (
+	sqliteHeader         = "SQLite format 3\x00"
+	sqliteHeaderSize     = 100
+	pageInteriorTable    = 0x05
+	pageLeafTable        = 0x0d
+	sqliteEncodingUTF8   = 1
+	maxSqliteTreeDepth   = 64
+	sqliteMasterRootPage = 1
+)
+
+func openSqlite(path string) (*sqliteDB, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseSqlite(data)
+}
+
+func parseSqlite(data []byte) (*sqliteDB, error) {
+	if len(data) < sqliteHeaderSize || string(data[:len(sqliteHeader)]) != sqliteHeader {
+		return nil, errors.New("not a SQLite database")
+	}
+	pageSize := int(binary.BigEndian.Uint16(data[16:18]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	if pageSize < 512 || pageSize&(pageSize-1) != 0 {
+		return nil, fmt.Errorf("invalid SQLite page size %d", pageSize)
+	}
+	if encoding := binary.BigEndian.Uint32(data[56:60]); encoding != sqliteEncodingUTF8 && encoding != 0 {
+		return nil, fmt.Errorf("unsupported SQLite text encoding %d", encoding)
+	}
+	return &sqliteDB{data: data, pageSize: pageSize, usableSize: pageSize - int(data[20])}, nil
+}
+
+func (db *sqliteDB) page(number uint32) ([]byte, error) {
+	start := int64(number-1) * int64(db.pageSize)
+	if number == 0 || start+int64(db.pageSize) > int64(len(db.data)) {
+		return nil, fmt.Errorf("invalid SQLite page %d", number)
+	}
+	return db.data[start : start+int64(db.pageSize)], nil
+}
+
+// table returns the rows of the table with name, each row contains the values of the columns in the order of the
+// CREATE TABLE statement
+func (db *sqliteDB) table(name string) ([]string, [][]interface{}, error) {
+	master, err := db.rows(sqliteMasterRootPage)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed reading sqlite_master: %w", err)
+	}
+	for _, row := range master {
+		if len(row) < 5 || row[0] != "table" || row[1] != name {
+			continue
+		}
+		rootPage, ok := row[3].(int64)
+		if !ok || rootPage <= 0 || rootPage > math.MaxUint32 {
+			return nil, nil, fmt.Errorf("invalid root page of table %s: %v", name, row[3])
+		}
+		sql, _ := row[4].(string)
+		rows, err := db.rows(uint32(rootPage))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed reading table %s: %w", name, err)
+		}
+		return columnNames(sql), rows, nil
+	}
+	return nil, nil, fmt.Errorf("table %s not found", name)
+}
+
+// rows returns the records of the table b-tree with the root page
+func (db *sqliteDB) rows(rootPage uint32) ([][]interface{}, error) {
+	var rows [][]interface{}
+	err := db.walk(rootPage, 0, func(record []byte) error {
+		row, err := parseRecord(record)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, row)
+		return nil
+	})
+	return rows, err
+}
+
+func (db *sqliteDB) walk(pageNumber uint32, depth int, f func(record []byte) error) error {
+	if depth > maxSqliteTreeDepth {
+		return errors.New("SQLite b-tree is too deep, the database is corrupt")
+	}
+	page, err := db.page(pageNumber)
+	if err != nil {
+		return err
+	}
+	header := 0
+	if pageNumber == 1 {
+		header = sqliteHeaderSize
+	}
+	if len(page) < header+8 {
+		return fmt.Errorf("page %d is too small", pageNumber)
+	}
+	pageType := page[header]
+	cellCount := int(binary.BigEndian.Uint16(page[header+3:]))
+	cellPointers := header + 8
+	if pageType == pageInteriorTable {
+		cellPointers = header + 12
+	}
+	if cellPointers+2*cellCount > len(page) {
+		return fmt.Errorf("invalid cell count of page %d", pageNumber)
+	}
+	for i := 0; i < cellCount; i++ {
+		offset := int(binary.BigEndian.Uint16(page[cellPointers+2*i:]))
+		if offset >= len(page) {
+			return fmt.Errorf("invalid cell offset on page %d", pageNumber)
+		}
+		cell := page[offset:]
+		switch pageType {
+		case pageInteriorTable:
+			if len(cell) < 4 {
+				return fmt.Errorf("invalid cell on page %d", pageNumber)
+			}
+			err = db.walk(binary.BigEndian.Uint32(cell), depth+1, f)
+		case pageLeafTable:
+			var record []byte
+			record, err = db.leafPayload(cell)
+			if err == nil {
+				err = f(record)
+			}
+		default:
+			return fmt.Errorf("page %d has type %d, it is not a table page", pageNumber, pageType)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if pageType == pageInteriorTable {
+		return db.walk(binary.BigEndian.Uint32(page[header+8:]), depth+1, f)
+	}
+	return nil
+}
+
+// leafPayload returns the payload of a table leaf cell and reads the parts stored on overflow pages
+func (db *sqliteDB) leafPayload(cell []byte) ([]byte, error) {
+	payloadSize, n := readVarint(cell)
+	if n == 0 {
+		return nil, errors.New("invalid cell")
+	}
+	cell = cell[n:]
+	// the rowid is not needed
+	_, n = readVarint(cell)
+	if n == 0 {
+		return nil, errors.New("invalid cell")
+	}
+	cell = cell[n:]
+	if payloadSize > uint64(len(db.data)) {
+		return nil, errors.New("invalid payload size")
+	}
+	size := int(payloadSize)
+	local := db.localPayloadSize(size)
+	if local > len(cell) {
+		return nil, errors.New("invalid cell")
+	}
+	payload := make([]byte, 0, size)
+	payload = append(payload, cell[:local]...)
+	if local == size {
+		return payload, nil
+	}
+	if len(cell) < local+4 {
+		return nil, errors.New("invalid cell")
+	}
+	next := binary.BigEndian.Uint32(cell[local:])
+	for len(payload) < size {
+		if next == 0 {
+			return nil, errors.New("overflow chain ends early")
+		}
+		page, err := db.page(next)
+		if err != nil {
+			return nil, err
+		}
+		next = binary.BigEndian.Uint32(page)
+		chunk := page[4:db.usableSize]
+		if remaining := size - len(payload); len(chunk) > remaining {
+			chunk = chunk[:remaining]
+		}
+		payload = append(payload, chunk...)
+	}
+	return payload, nil
+}
+
+// localPayloadSize returns how many bytes of a payload of a table leaf cell are stored on the page itself
+func (db *sqliteDB) localPayloadSize(size int) int {
+	maxLocal := db.usableSize - 35
+	if size <= maxLocal {
+		return size
+	}
+	minLocal := (db.usableSize-12)*32/255 - 23
+	local := minLocal + (size-minLocal)%(db.usableSize-4)
+	if local > maxLocal {
+		return minLocal
+	}
+	return local
+}
+
+// parseRecord decodes the values of a record. Integers are returned as int64, floats as float64, text as string
+// and blobs as []byte.
+func parseRecord(record []byte) ([]interface{}, error) {
+	headerSize, n := readVarint(record)
+	if n == 0 || headerSize < uint64(n) || headerSize > uint64(len(record)) {
+		return nil, errors.New("invalid record header")
+	}
+	header := record[n:headerSize]
+	body := record[headerSize:]
+	var values []interface{}
+	for len(header) > 0 {
+		serialType, n := readVarint(header)
+		if n == 0 {
+			return nil, errors.New("invalid record header")
+		}
+		header = header[n:]
+		size := serialTypeSize(serialType)
+		if size > uint64(len(body)) {
+			return nil, errors.New("record is too short")
+		}
+		values = append(values, recordValue(serialType, body[:size]))
+		body = body[size:]
+	}
+	return values, nil
+}
+
+func serialTypeSize(serialType uint64) uint64 {
+	switch {
+	case serialType <= 4:
+		return serialType
+	case serialType == 5:
+		return 6
+	case serialType == 6, serialType == 7:
+		return 8
+	case serialType < 12:
+		return 0
+	}
+	return (serialType - 12) / 2
+}
+
+func recordValue(serialType uint64, b []byte) interface{} {
+	switch {
+	case serialType == 0:
+		return nil
+	case serialType <= 6:
+		// big endian two's complement integers of 1 to 8 bytes
+		var v int64
+		if b[0]&0x80 != 0 {
+			v = -1
+		}
+		for _, c := range b {
+			v = v<<8 | int64(c)
+		}
+		return v
+	case serialType == 7:
+		return math.Float64frombits(binary.BigEndian.Uint64(b))
+	case serialType == 8:
+		return int64(0)
+	case serialType == 9:
+		return int64(1)
+	case serialType < 12:
+		return nil
+	case serialType%2 == 0:
+		return bytes.Clone(b)
+	}
+	return string(b)
+}
+
+// readVarint decodes a SQLite varint, it returns the value and the number of bytes read or 0 if b is too short
+func readVarint(b []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < 9; i++ {
+		if i >= len(b) {
+			return 0, 0
+		}
+		if i == 8 {
+			return v<<8 | uint64(b[i]), 9
+		}
+		v = v<<7 | uint64(b[i]&0x7f)
+		if b[i]&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	return v, 9
+}
+
+// columnNames extracts the column names of a CREATE TABLE statement
+func columnNames(sql string) []string {
+	start := strings.Index(sql, "(")
+	end := strings.LastIndex(sql, ")")
+	if start == -1 || end <= start {
+		return nil
+	}
+	var names []string
+	depth := 0
+	definition := strings.Builder{}
+	addColumn := func() {
+		fields := strings.Fields(definition.String())
+		definition.Reset()
+		if len(fields) == 0 {
+			return
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "PRIMARY", "UNIQUE", "CHECK", "FOREIGN", "CONSTRAINT":
+			// table constraints are not columns
+			return
+		}
+		names = append(names, strings.Trim(fields[0], "\"`[]"))
+	}
+	for _, c := range sql[start+1 : end] {
+		switch {
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			addColumn()
+			continue
+		}
+		definition.WriteRune(c)
+	}
+	addColumn()
+	return names
+}
@@ -0,0 +1,117 @@
+package ios
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ErrUSBRequired is returned for services and operations that are not available for devices connected over Wi-Fi
+var ErrUSBRequired = errors.New("a USB connection to the device is required")
+
+// usbOnlyServices are lockdown services that the device does not provide over the network. Starting them on a
+// network connection does not fail, but the device never answers. Devices on the network are tunneled to with
+// the remote pairing of the tunnel package instead of CoreDeviceProxy. Other services the device does not answer
+// for over the network fail after networkStartServiceTimeout.
+var usbOnlyServices = map[string]bool{
+	"com.apple.internal.devicecompute.CoreDeviceProxy": true,
+}
+
+// deviceConnectTimeout is how long usbmuxd and the device may take to answer while a connection is set up. A
+// device connected over Wi-Fi that went to sleep or left the network is only noticed this way.
+const deviceConnectTimeout = 30 * time.Second
+
+// networkStartServiceTimeout is how long a device connected over Wi-Fi may take to start a service
+const networkStartServiceTimeout = 10 * time.Second
+
+// IsNetwork returns true if usbmuxd reaches the device over Wi-Fi instead of USB
+func (device DeviceEntry) IsNetwork() bool {
+	return device.Properties.ConnectionType == ConnectionTypeNetwork
+}
+
+// RequireUSB returns an error wrapping ErrUSBRequired if device is connected over the network, operation is used
+// in the error message
+func (device DeviceEntry) RequireUSB(operation string) error {
+	if device.IsNetwork() {
+		return fmt.Errorf("%s: %w, device %s is connected over Wi-Fi", operation, ErrUSBRequired, device.Properties.SerialNumber)
+	}
+	return nil
+}
+
+// serviceAvailable returns an error wrapping ErrUSBRequired if serviceName cannot be started on device because it
+// is connected over the network
+func serviceAvailable(device DeviceEntry, serviceName string) error {
+	if !usbOnlyServices[serviceName] {
+		return nil
+	}
+	return device.RequireUSB("StartService " + serviceName)
+}
+
+// startServiceOn starts serviceName on the lockdown session of device. Devices connected over the network do not
+// answer for services they only provide over USB, so an error wrapping ErrUSBRequired is returned for them if the
+// service was not started within networkStartServiceTimeout.
+func startServiceOn(device DeviceEntry, lockdown lockdownSession, serviceName string) (StartServiceResponse, error) {
+	lockdownConn, ok := lockdown.(*LockDownConnection)
+	if !ok || !device.IsNetwork() {
+		return lockdown.StartService(serviceName)
+	}
+	return startServiceOverNetwork(lockdownConn, serviceName, networkStartServiceTimeout)
+}
+
+func startServiceOverNetwork(lockdown *LockDownConnection, serviceName string, timeout time.Duration) (StartServiceResponse, error) {
+	var response StartServiceResponse
+	err := withConnectTimeoutAfter(lockdown.deviceConnection, timeout, func() (err error) {
+		response, err = lockdown.StartService(serviceName)
+		return err
+	})
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return StartServiceResponse{}, fmt.Errorf("StartService %s: the device did not start the service over Wi-Fi within %s, it might only be available over USB: %w", serviceName, timeout, ErrUSBRequired)
+	}
+	return response, err
+}
+
+// findDevice returns the entry of the device with udid. usbmuxd lists a device that is connected over USB and
+// Wi-Fi at the same time twice, the USB entry is preferred then because it is faster and supports all services.
+func findDevice(deviceList DeviceList, udid string) (DeviceEntry, bool) {
+	var network *DeviceEntry
+	for i, device := range deviceList.DeviceList {
+		if device.Properties.SerialNumber != udid {
+			continue
+		}
+		if !device.IsNetwork() {
+			return device, true
+		}
+		if network == nil {
+			network = &deviceList.DeviceList[i]
+		}
+	}
+	if network == nil {
+		return DeviceEntry{}, false
+	}
+	return *network, true
+}
+
+// withConnectTimeout runs f with a deadline of deviceConnectTimeout on conn, so that setting up a connection to an
+// unreachable device fails instead of blocking forever
+func withConnectTimeout(conn DeviceConnectionInterface, f func() error) error {
+	return withConnectTimeoutAfter(conn, deviceConnectTimeout, f)
+}
+
+func withConnectTimeoutAfter(conn DeviceConnectionInterface, timeout time.Duration, f func() error) error {
+	deviceConn, ok := conn.(*DeviceConnection)
+	if !ok || deviceConn.Conn() == nil {
+		return f()
+	}
+	netConn := deviceConn.Conn()
+	err := netConn.SetDeadline(time.Now().Add(timeout))
+	if err != nil {
+		return f()
+	}
+	defer netConn.SetDeadline(time.Time{})
+	err = f()
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return fmt.Errorf("the device did not answer within %s, is it still connected? %w", timeout, err)
+	}
+	return err
+}
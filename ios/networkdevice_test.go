@@ -0,0 +1,79 @@
+package ios
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindDevice(t *testing.T) {
+	network := DeviceEntry{DeviceID: 7, Properties: DeviceProperties{SerialNumber: "udid0", ConnectionType: ConnectionTypeNetwork}}
+	usb := DeviceEntry{DeviceID: 3, Properties: DeviceProperties{SerialNumber: "udid0", ConnectionType: ConnectionTypeUSB}}
+	other := DeviceEntry{DeviceID: 5, Properties: DeviceProperties{SerialNumber: "udid1", ConnectionType: ConnectionTypeNetwork}}
+
+	testCases := map[string]struct {
+		devices  []DeviceEntry
+		udid     string
+		expected DeviceEntry
+		found    bool
+	}{
+		"usb is preferred":        {[]DeviceEntry{network, usb, other}, "udid0", usb, true},
+		"network only device":     {[]DeviceEntry{network, usb, other}, "udid1", other, true},
+		"network without usb":     {[]DeviceEntry{network, other}, "udid0", network, true},
+		"device is not connected": {[]DeviceEntry{network, usb}, "udid1", DeviceEntry{}, false},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			device, ok := findDevice(DeviceList{DeviceList: tc.devices}, tc.udid)
+			assert.Equal(t, tc.found, ok)
+			assert.Equal(t, tc.expected, device)
+		})
+	}
+}
+
+func TestUSBOnlyServices(t *testing.T) {
+	network := DeviceEntry{DeviceID: 7, Properties: DeviceProperties{SerialNumber: "udid0", ConnectionType: ConnectionTypeNetwork}}
+
+	_, err := StartService(network, "com.apple.internal.devicecompute.CoreDeviceProxy")
+	assert.ErrorIs(t, err, ErrUSBRequired)
+	assert.ErrorIs(t, Pair(network), ErrUSBRequired)
+
+	usb := network
+	usb.Properties.ConnectionType = ConnectionTypeUSB
+	assert.NoError(t, usb.RequireUSB("Pair"))
+	assert.NoError(t, serviceAvailable(network, "com.apple.afc"))
+}
+
+func TestStartServiceOverNetworkTimeout(t *testing.T) {
+	client, device := net.Pipe()
+	defer device.Close()
+	lockdown := NewLockDownConnection(NewDeviceConnectionWithConn(client))
+	start := time.Now()
+
+	// the device never answers for a service it does not provide over the network
+	_, err := startServiceOverNetwork(lockdown, "com.apple.mobile.screenshotr", 10*time.Millisecond)
+
+	assert.ErrorIs(t, err, ErrUSBRequired)
+	assert.Contains(t, err.Error(), "com.apple.mobile.screenshotr")
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestConnectTimeout(t *testing.T) {
+	client, device := net.Pipe()
+	defer device.Close()
+	muxConn := NewUsbMuxConnection(NewDeviceConnectionWithConn(client))
+	start := time.Now()
+	// the device never answers
+	err := withConnectTimeoutAfter(muxConn.deviceConn, 10*time.Millisecond, func() error {
+		_, err := muxConn.ReadMessage()
+		return err
+	})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "did not answer")
+	assert.Less(t, time.Since(start), time.Second)
+}
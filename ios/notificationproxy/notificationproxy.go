@@ -2,7 +2,9 @@ package notificationproxy
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"sync"
 	"time"
 
@@ -13,6 +15,12 @@ import (
 
 const serviceName = "com.apple.mobile.notification_proxy"
 
+// ErrNotificationTimeout is returned by WaitForNotification if the notification was not posted in time
+var ErrNotificationTimeout = errors.New("timed out waiting for notification")
+
+// ErrProxyDeath is returned by WaitForNotification if notification proxy shuts down while waiting
+var ErrProxyDeath = errors.New("notification proxy died")
+
 type Connection struct {
 	deviceConn          ios.DeviceConnectionInterface
 	plistCodec          ios.PlistCodec
@@ -119,6 +127,34 @@ func (c *Connection) Observe(notification string, timeout time.Duration) error {
 	}
 }
 
+// WaitForNotification observes the Darwin notification name and returns once it is posted on the device. It returns
+// an error wrapping ErrNotificationTimeout if the notification does not arrive within timeout, or the error of ctx if
+// it is done first. Like Observe, only one caller per Connection may wait at a time.
+func (c *Connection) WaitForNotification(ctx context.Context, name string, timeout time.Duration) error {
+	if c.newNotification(name) {
+		err := c.startObserving(name)
+		if err != nil {
+			return fmt.Errorf("WaitForNotification: failed observing %s: %w", name, err)
+		}
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case remoteNotification := <-c.notificationChannel:
+			if remoteNotification == name {
+				return nil
+			}
+		case <-c.proxyDeathChannel:
+			return fmt.Errorf("WaitForNotification: %w", ErrProxyDeath)
+		case <-timer.C:
+			return fmt.Errorf("WaitForNotification: %s not posted within %s: %w", name, timeout, ErrNotificationTimeout)
+		case <-ctx.Done():
+			return fmt.Errorf("WaitForNotification: %w", ctx.Err())
+		}
+	}
+}
+
 func (c *Connection) startObserving(notification string) error {
 	request := notificationProxyRequest{Command: "ObserveNotification", Name: notification}
 	bytes, err := c.plistCodec.Encode(request)
@@ -0,0 +1,79 @@
+package notificationproxy
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	ios "github.com/danielpaulus/go-ios/ios"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newMockConnection returns a Connection to a fake notification proxy that posts the notifications in posts once
+// the first one is observed
+func newMockConnection(t *testing.T, posts ...string) (*Connection, chan map[string]interface{}) {
+	client, device := net.Pipe()
+	requests := make(chan map[string]interface{}, 10)
+	go func() {
+		codec := ios.NewPlistCodec()
+		for {
+			request, err := codec.Decode(device)
+			if err != nil {
+				return
+			}
+			parsed, _ := ios.ParsePlist(request)
+			requests <- parsed
+			for _, name := range posts {
+				msg, _ := codec.Encode(map[string]interface{}{"Command": "RelayNotification", "Name": name})
+				if _, err := device.Write(msg); err != nil {
+					return
+				}
+			}
+			posts = nil
+		}
+	}()
+	t.Cleanup(func() {
+		client.Close()
+		device.Close()
+	})
+	c := &Connection{
+		deviceConn: ios.NewDeviceConnectionWithRWC(client), plistCodec: ios.NewPlistCodec(), alreadyObserving: make(map[string]interface{}),
+		notificationChannel: make(chan string), proxyDeathChannel: make(chan interface{}),
+	}
+	go read(c)
+	return c, requests
+}
+
+func TestWaitForNotification(t *testing.T) {
+	conn, requests := newMockConnection(t, "com.apple.mobile.keybagd.lock_status", "com.example.ready")
+
+	err := conn.WaitForNotification(context.Background(), "com.example.ready", time.Second)
+
+	require.NoError(t, err)
+	request := <-requests
+	assert.Equal(t, "ObserveNotification", request["Command"])
+	assert.Equal(t, "com.example.ready", request["Name"])
+}
+
+func TestWaitForNotificationTimeout(t *testing.T) {
+	conn, _ := newMockConnection(t, "com.apple.mobile.keybagd.lock_status")
+
+	start := time.Now()
+	err := conn.WaitForNotification(context.Background(), "com.example.ready", 50*time.Millisecond)
+
+	assert.ErrorIs(t, err, ErrNotificationTimeout)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestWaitForNotificationCancelled(t *testing.T) {
+	conn, _ := newMockConnection(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := conn.WaitForNotification(ctx, "com.example.ready", time.Minute)
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.NotErrorIs(t, err, ErrNotificationTimeout)
+}
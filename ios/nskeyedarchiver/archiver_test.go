@@ -60,6 +60,17 @@ func TestXCTestconfig(t *testing.T) {
 	log.Info(unarchivedObject)
 }
 
+func TestXCTestConfigurationWithTargetApplicationLaunchConfiguration(t *testing.T) {
+	config := nskeyedarchiver.NewXCTestConfiguration("productmodulename", uuid.New(), "targetAppBundle", "targetAppPath", "testBundleUrl", nil, nil, false, semver.MustParse("17.0.0"),
+		nskeyedarchiver.WithTargetApplicationArguments([]string{"-UITesting", "YES"}),
+		nskeyedarchiver.WithTargetApplicationEnvironment(map[string]interface{}{"API_URL": "https://staging.example.com"}))
+	result, err := nskeyedarchiver.ArchiveXML(config)
+	assert.NoError(t, err)
+	assert.Contains(t, result, "<key>targetApplicationArguments</key>")
+	assert.Contains(t, result, "<string>-UITesting</string>")
+	assert.Contains(t, result, "<string>https://staging.example.com</string>")
+}
+
 func TestXCTCaps(t *testing.T) {
 	nskeyedBytes, err := os.ReadFile("fixtures/XCTCapabilities.bin")
 	if err != nil {
@@ -76,6 +76,77 @@ type XCTestConfiguration struct {
 	contents map[string]interface{}
 }
 
+// XCTestConfigurationOption for overriding the default settings of a XCTestConfiguration
+type XCTestConfigurationOption func(contents map[string]interface{})
+
+// WithDefaultTestExecutionTimeAllowance enables test timeouts and sets the time in seconds each test is allowed to run
+func WithDefaultTestExecutionTimeAllowance(seconds uint64) XCTestConfigurationOption {
+	return func(contents map[string]interface{}) {
+		contents["testTimeoutsEnabled"] = true
+		contents["defaultTestExecutionTimeAllowance"] = seconds
+	}
+}
+
+const (
+	// ScreenCaptureFormatScreenRecording lets XCTest capture screen recordings during UI tests. This is the default
+	ScreenCaptureFormatScreenRecording = 2
+	// ScreenCaptureFormatScreenshots lets XCTest capture screenshots only during UI tests
+	ScreenCaptureFormatScreenshots = 1
+)
+
+// WithPreferredScreenCaptureFormat sets the format XCTest uses for capturing the screen during UI tests, see ScreenCaptureFormatScreenRecording
+// and ScreenCaptureFormatScreenshots
+func WithPreferredScreenCaptureFormat(format int) XCTestConfigurationOption {
+	return func(contents map[string]interface{}) {
+		contents["preferredScreenCaptureFormat"] = format
+	}
+}
+
+// testExecutionOrderingRandom lets XCTest run the tests in the random order derived from randomExecutionOrderingSeed
+const testExecutionOrderingRandom = 1
+
+// WithRandomExecutionOrdering lets XCTest run the tests in random order. The same seed results in the same order.
+func WithRandomExecutionOrdering(seed uint64) XCTestConfigurationOption {
+	return func(contents map[string]interface{}) {
+		contents["testExecutionOrdering"] = testExecutionOrderingRandom
+		contents["randomExecutionOrderingSeed"] = seed
+	}
+}
+
+// WithTargetApplicationArguments sets the launch arguments XCUIApplication uses for the UI target app
+func WithTargetApplicationArguments(args []string) XCTestConfigurationOption {
+	return func(contents map[string]interface{}) {
+		arguments := make([]interface{}, len(args))
+		for i, arg := range args {
+			arguments[i] = arg
+		}
+		contents["targetApplicationArguments"] = arguments
+	}
+}
+
+// WithTargetApplicationEnvironment sets the environment XCUIApplication uses for the UI target app
+func WithTargetApplicationEnvironment(env map[string]interface{}) XCTestConfigurationOption {
+	return func(contents map[string]interface{}) {
+		contents["targetApplicationEnvironment"] = env
+	}
+}
+
+// WithKeepSystemAttachments lets XCTest keep the attachments it creates by itself, like the screenshots of failed
+// assertions and crash logs of the app under test, and report them to the IDE. By default they are deleted
+func WithKeepSystemAttachments() XCTestConfigurationOption {
+	return func(contents map[string]interface{}) {
+		contents["systemAttachmentLifetime"] = attachmentLifetimeKeepAlways
+	}
+}
+
+// WithTestBundleURL loads the test bundle at testBundleURL instead of the one passed to NewXCTestConfiguration, f.ex.
+// a logic test bundle that was copied to the container of the test runner
+func WithTestBundleURL(testBundleURL string) XCTestConfigurationOption {
+	return func(contents map[string]interface{}) {
+		contents["testBundleURL"] = NewNSURL(testBundleURL)
+	}
+}
+
 func NewXCTestConfiguration(
 	productModuleName string,
 	sessionIdentifier uuid.UUID,
@@ -86,6 +157,7 @@ func NewXCTestConfiguration(
 	testsToSkip []string,
 	isXCTest bool,
 	version *semver.Version,
+	opts ...XCTestConfigurationOption,
 ) XCTestConfiguration {
 	contents := map[string]interface{}{}
 
@@ -150,7 +222,7 @@ func NewXCTestConfiguration(
 	contents["testTimeoutsEnabled"] = false
 	contents["treatMissingBaselinesAsFailures"] = false
 	contents["userAttachmentLifetime"] = attachmentLifetimeKeepAlways
-	contents["preferredScreenCaptureFormat"] = 2
+	contents["preferredScreenCaptureFormat"] = ScreenCaptureFormatScreenRecording
 	contents["IDECapabilities"] = XCTCapabilities{CapabilitiesDictionary: map[string]interface{}{
 		"expected failure test capability":         true,
 		"test case run configurations":             true,
@@ -174,6 +246,10 @@ func NewXCTestConfiguration(
 		contents["testIdentifiersToSkip"] = testIdentifiersToSkipEntry
 	}
 
+	for _, opt := range opts {
+		opt(contents)
+	}
+
 	return XCTestConfiguration{contents}
 }
 
@@ -234,7 +310,8 @@ func archiveXcTestConfiguration(xctestconfigInterface interface{}, objects []int
 	for _, key := range []string{
 		"aggregateStatisticsBeforeCrash", "automationFrameworkPath", "productModuleName", "sessionIdentifier",
 		"targetApplicationBundleID", "targetApplicationPath", "testBundleURL", "testsToRun", "testsToSkip",
-		"testIdentifiersToRun", "testIdentifiersToSkip", "IDECapabilities",
+		"testIdentifiersToRun", "testIdentifiersToSkip", "IDECapabilities", "targetApplicationArguments",
+		"targetApplicationEnvironment",
 	} {
 		_, ok := xctestconfig.contents[key]
 		if ok {
@@ -0,0 +1,191 @@
+package ostrace
+
+import (
+	"archive/tar"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/danielpaulus/go-ios/ios"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	usbmuxdServiceName = "com.apple.os_trace_relay"
+	shimServiceName    = "com.apple.os_trace_relay.shim.remote"
+)
+
+const (
+	// the device sends this byte before the response to the CreateArchive request
+	responseMarker = 1
+	// the device sends this byte before each chunk of the archive
+	chunkMarker = 3
+)
+
+// ArchiveOptions limit the log entries that are exported with CreateArchive. Zero values do not limit the export.
+type ArchiveOptions struct {
+	// SizeLimit is the maximum size of the archive in bytes
+	SizeLimit uint64
+	// AgeLimit is the maximum age of the exported log entries in seconds
+	AgeLimit uint64
+	// StartTime is the time of the oldest exported log entry
+	StartTime time.Time
+}
+
+// CreateArchive exports the os_log archive of the device and writes it to w. The archive is a tar file containing
+// the contents of a .logarchive directory, use PullLogArchive to extract it directly.
+// Exporting the archive can take minutes on devices with a lot of logs, use ArchiveOptions to limit it.
+func CreateArchive(device ios.DeviceEntry, w io.Writer, options ArchiveOptions) error {
+	conn, err := connect(device)
+	if err != nil {
+		return fmt.Errorf("CreateArchive: cannot connect to os_trace_relay: %w", err)
+	}
+	defer conn.Close()
+
+	plistCodec := ios.NewPlistCodec()
+	request, err := plistCodec.Encode(newCreateArchiveRequest(options))
+	if err != nil {
+		return fmt.Errorf("CreateArchive: %w", err)
+	}
+	if err := conn.Send(request); err != nil {
+		return fmt.Errorf("CreateArchive: failed sending request: %w", err)
+	}
+	if err := readArchive(conn.Reader(), w); err != nil {
+		return fmt.Errorf("CreateArchive: %w", err)
+	}
+	return nil
+}
+
+// PullLogArchive exports the os_log archive of the device with CreateArchive and extracts it to dstPath, which
+// should end with .logarchive so it can be opened with the Console app or 'log show'
+func PullLogArchive(device ios.DeviceEntry, dstPath string, options ArchiveOptions) error {
+	tarFile, err := os.CreateTemp("", "go-ios-logarchive-*.tar")
+	if err != nil {
+		return fmt.Errorf("PullLogArchive: %w", err)
+	}
+	defer os.Remove(tarFile.Name())
+	defer tarFile.Close()
+
+	if err := CreateArchive(device, tarFile, options); err != nil {
+		return err
+	}
+	if _, err := tarFile.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("PullLogArchive: %w", err)
+	}
+	if err := extractTar(tarFile, dstPath); err != nil {
+		return fmt.Errorf("PullLogArchive: %w", err)
+	}
+	return nil
+}
+
+func connect(device ios.DeviceEntry) (ios.DeviceConnectionInterface, error) {
+	if device.SupportsRsd() {
+		return ios.ConnectToShimService(device, shimServiceName)
+	}
+	return ios.ConnectToService(device, usbmuxdServiceName)
+}
+
+func newCreateArchiveRequest(options ArchiveOptions) map[string]interface{} {
+	request := map[string]interface{}{"Request": "CreateArchive"}
+	if options.SizeLimit > 0 {
+		request["SizeLimit"] = options.SizeLimit
+	}
+	if options.AgeLimit > 0 {
+		request["AgeLimit"] = options.AgeLimit
+	}
+	if !options.StartTime.IsZero() {
+		request["StartTime"] = uint64(options.StartTime.Unix())
+	}
+	return request
+}
+
+// readArchive reads the response to a CreateArchive request and writes the chunks of the archive to w until the
+// device closes the connection
+func readArchive(r io.Reader, w io.Writer) error {
+	marker := make([]byte, 1)
+	if _, err := io.ReadFull(r, marker); err != nil {
+		return fmt.Errorf("failed reading response: %w", err)
+	}
+	if marker[0] != responseMarker {
+		return fmt.Errorf("unexpected response marker %d", marker[0])
+	}
+	response, err := ios.NewPlistCodec().Decode(r)
+	if err != nil {
+		return fmt.Errorf("failed reading response: %w", err)
+	}
+	parsed, err := ios.ParsePlist(response)
+	if err != nil {
+		return fmt.Errorf("failed parsing response: %w", err)
+	}
+	if status, _ := parsed["Status"].(string); status != "RequestSuccessful" {
+		return fmt.Errorf("device rejected archive request: %+v", parsed)
+	}
+
+	var written int64
+	for {
+		if _, err := io.ReadFull(r, marker); err != nil {
+			if errors.Is(err, io.EOF) {
+				log.Debugf("received log archive with %d bytes", written)
+				return nil
+			}
+			return fmt.Errorf("failed reading archive: %w", err)
+		}
+		if marker[0] != chunkMarker {
+			return fmt.Errorf("unexpected chunk marker %d", marker[0])
+		}
+		var length uint32
+		if err := binary.Read(r, binary.LittleEndian, &length); err != nil {
+			return fmt.Errorf("failed reading chunk length: %w", err)
+		}
+		n, err := io.CopyN(w, r, int64(length))
+		written += n
+		if err != nil {
+			return fmt.Errorf("failed reading archive chunk: %w", err)
+		}
+	}
+}
+
+// extractTar extracts the tar archive r into the directory dstPath
+func extractTar(r io.Reader, dstPath string) error {
+	if err := os.MkdirAll(dstPath, 0o755); err != nil {
+		return err
+	}
+	tarReader := tar.NewReader(r)
+	for {
+		header, err := tarReader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed reading tar archive: %w", err)
+		}
+		target := filepath.Join(dstPath, header.Name)
+		if !strings.HasPrefix(target, filepath.Clean(dstPath)+string(os.PathSeparator)) {
+			return fmt.Errorf("invalid path in archive: %s", header.Name)
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return err
+			}
+			f, err := os.Create(target)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tarReader)
+			f.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package ostrace
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/danielpaulus/go-ios/ios"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateArchiveRequest(t *testing.T) {
+	encoded, err := ios.NewPlistCodec().Encode(newCreateArchiveRequest(ArchiveOptions{
+		SizeLimit: 100 * 1024 * 1024,
+		StartTime: time.Unix(1700000000, 0),
+	}))
+	assert.NoError(t, err)
+
+	request, err := ios.ParsePlist(encoded[4:])
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"Request":   "CreateArchive",
+		"SizeLimit": uint64(104857600),
+		"StartTime": uint64(1700000000),
+	}, request)
+}
+
+func TestCreateArchiveRequestWithoutLimits(t *testing.T) {
+	assert.Equal(t, map[string]interface{}{"Request": "CreateArchive"}, newCreateArchiveRequest(ArchiveOptions{}))
+}
+
+func TestReadArchive(t *testing.T) {
+	response, err := ios.NewPlistCodec().Encode(map[string]interface{}{"Status": "RequestSuccessful"})
+	assert.NoError(t, err)
+	stream := &bytes.Buffer{}
+	stream.WriteByte(responseMarker)
+	stream.Write(response)
+	// the chunk lengths are little endian
+	stream.Write([]byte{chunkMarker, 12, 0, 0, 0})
+	stream.WriteString("first chunk,")
+	stream.Write([]byte{chunkMarker, 12, 0, 0, 0})
+	stream.WriteString("second chunk")
+
+	archive := &bytes.Buffer{}
+	err = readArchive(stream, archive)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "first chunk,second chunk", archive.String())
+}
@@ -2,6 +2,8 @@ package ios
 
 import (
 	"bytes"
+	"crypto"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"strings"
@@ -28,6 +30,20 @@ func PairSupervised(device DeviceEntry, p12bytes []byte, p12Password string) err
 	if err != nil {
 		return err
 	}
+	signer, ok := supervisedPrivateKey.(crypto.Signer)
+	if !ok {
+		return fmt.Errorf("PairSupervised: unsupported private key type %T", supervisedPrivateKey)
+	}
+	return PairSupervisedWithIdentity(device, cert, signer)
+}
+
+// PairSupervisedWithIdentity pairs a supervised device with the supervision identity of the organization, the
+// certificate and private key that were used to supervise the device. The device does not show a trust dialog.
+// Use ParseSupervisionIdentity to load the identity from the certificate and key files that cfgutil accepts.
+func PairSupervisedWithIdentity(device DeviceEntry, cert *x509.Certificate, supervisedPrivateKey crypto.Signer) error {
+	if err := device.RequireUSB("PairSupervised"); err != nil {
+		return err
+	}
 	usbmuxConn, err := NewUsbMuxConnectionSimple()
 	if err != nil {
 		return err
@@ -86,17 +102,16 @@ func PairSupervised(device DeviceEntry, p12bytes []byte, p12Password string) err
 	if err != nil {
 		return err
 	}
-	respMap, err := ParsePlist(resp)
+	escrow, err := extractEscrowBag(resp)
 	if err != nil {
 		return err
 	}
-	escrow := respMap["EscrowBag"].([]byte)
 
 	usbmuxConn, err = NewUsbMuxConnectionSimple()
-	defer usbmuxConn.Close()
 	if err != nil {
 		return err
 	}
+	defer usbmuxConn.Close()
 
 	success, err := usbmuxConn.savePair(device.Properties.SerialNumber, deviceCert, hostPrivateKey, hostCert, rootPrivateKey, rootCert, escrow, wifiMac.(string), pairRecordData.HostID, buid)
 	if err != nil {
@@ -108,6 +123,23 @@ func PairSupervised(device DeviceEntry, p12bytes []byte, p12Password string) err
 	return nil
 }
 
+// extractEscrowBag returns the escrow bag of the response to the signed pairing challenge. The device answers with
+// an error instead, if the challenge was not signed with the identity that supervises it.
+func extractEscrowBag(resp []byte) ([]byte, error) {
+	respPlist, err := ParsePlist(resp)
+	if err != nil {
+		return nil, err
+	}
+	if errormsg, ok := respPlist["Error"]; ok {
+		return nil, fmt.Errorf("supervised pairing failed with '%v', is the device supervised by this identity?", errormsg)
+	}
+	escrow, ok := respPlist["EscrowBag"].([]byte)
+	if !ok {
+		return nil, fmt.Errorf("EscrowBag key is missing: %+v", respPlist)
+	}
+	return escrow, nil
+}
+
 func extractPairingChallenge(resp []byte) ([]byte, error) {
 	respPlist, err := ParsePlist(resp)
 	if err != nil {
@@ -151,7 +183,11 @@ func extractPairingChallenge(resp []byte) ([]byte, error) {
 // 1. run the Pair() function
 // 2. accept the trust pop up on the device
 // 3. run the Pair() function a second time
+// Pairing is only possible while the device is connected over USB.
 func Pair(device DeviceEntry) error {
+	if err := device.RequireUSB("Pair"); err != nil {
+		return err
+	}
 	usbmuxConn, err := NewUsbMuxConnectionSimple()
 	if err != nil {
 		return err
@@ -0,0 +1,79 @@
+package ios
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"time"
+)
+
+// PairRecordInfo contains the parts of a PairRecord that can be shown to users, f.ex. to find out which host a
+// device is paired with. It never contains the private keys or the escrow bag of the pair record.
+type PairRecordInfo struct {
+	HostID            string
+	SystemBUID        string
+	WiFiMACAddress    string
+	HostCertificate   CertificateInfo
+	DeviceCertificate CertificateInfo
+	RootCertificate   CertificateInfo
+	// HasEscrowBag is true if the pair record contains an escrow bag, which allows connecting to a locked device
+	HasEscrowBag bool
+}
+
+// CertificateInfo identifies a certificate of a pair record
+type CertificateInfo struct {
+	// SHA256Fingerprint and SHA1Fingerprint are hex encoded hashes of the DER encoded certificate
+	SHA256Fingerprint string
+	SHA1Fingerprint   string
+	Subject           string
+	NotBefore         time.Time
+	NotAfter          time.Time
+}
+
+// GetPairRecordInfo reads the pair record of the device from usbmuxd and returns its public parts
+func GetPairRecordInfo(device DeviceEntry) (PairRecordInfo, error) {
+	pairRecord, err := ReadPairRecord(device.Properties.SerialNumber)
+	if err != nil {
+		return PairRecordInfo{}, fmt.Errorf("GetPairRecordInfo: %w", err)
+	}
+	return pairRecord.PublicInfo(), nil
+}
+
+// PublicInfo returns the fields of the pair record that are safe to display. Private keys are left out.
+func (p PairRecord) PublicInfo() PairRecordInfo {
+	return PairRecordInfo{
+		HostID:            p.HostID,
+		SystemBUID:        p.SystemBUID,
+		WiFiMACAddress:    p.WiFiMACAddress,
+		HostCertificate:   certificateInfo(p.HostCertificate),
+		DeviceCertificate: certificateInfo(p.DeviceCertificate),
+		RootCertificate:   certificateInfo(p.RootCertificate),
+		HasEscrowBag:      len(p.EscrowBag) > 0,
+	}
+}
+
+// certificateInfo accepts PEM as stored in pair records as well as DER encoded certificates
+func certificateInfo(cert []byte) CertificateInfo {
+	if len(cert) == 0 {
+		return CertificateInfo{}
+	}
+	der := cert
+	if block, _ := pem.Decode(cert); block != nil {
+		der = block.Bytes
+	}
+	sha256Sum := sha256.Sum256(der)
+	sha1Sum := sha1.Sum(der)
+	info := CertificateInfo{
+		SHA256Fingerprint: hex.EncodeToString(sha256Sum[:]),
+		SHA1Fingerprint:   hex.EncodeToString(sha1Sum[:]),
+	}
+	if parsed, err := x509.ParseCertificate(der); err == nil {
+		info.Subject = parsed.Subject.String()
+		info.NotBefore = parsed.NotBefore
+		info.NotAfter = parsed.NotAfter
+	}
+	return info
+}
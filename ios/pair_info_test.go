@@ -0,0 +1,64 @@
+package ios
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPairRecordPublicInfo(t *testing.T) {
+	deviceKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	devicePublicKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&deviceKey.PublicKey)})
+	rootCert, hostCert, deviceCert, rootKey, hostKey, err := createRootCertificate(devicePublicKey)
+	require.NoError(t, err)
+	pairRecord := PairRecord{
+		HostID:            "5A1F0C8E-6E3B-4C52-9D7A-0123456789AB",
+		SystemBUID:        "2F6A8E1C-1B3D-4E5F-8A9B-ABCDEF012345",
+		HostCertificate:   hostCert,
+		HostPrivateKey:    hostKey,
+		DeviceCertificate: deviceCert,
+		EscrowBag:         []byte("escrow bag secret"),
+		WiFiMACAddress:    "aa:bb:cc:dd:ee:ff",
+		RootCertificate:   rootCert,
+		RootPrivateKey:    rootKey,
+	}
+
+	info := pairRecord.PublicInfo()
+
+	assert.Equal(t, pairRecord.HostID, info.HostID)
+	assert.Equal(t, pairRecord.SystemBUID, info.SystemBUID)
+	assert.Equal(t, pairRecord.WiFiMACAddress, info.WiFiMACAddress)
+	assert.True(t, info.HasEscrowBag)
+	block, _ := pem.Decode(hostCert)
+	hostCertHash := sha256.Sum256(block.Bytes)
+	assert.Equal(t, hex.EncodeToString(hostCertHash[:]), info.HostCertificate.SHA256Fingerprint)
+	assert.Len(t, info.RootCertificate.SHA1Fingerprint, 40)
+	assert.False(t, info.DeviceCertificate.NotAfter.IsZero())
+
+	serialized, err := json.Marshal(info)
+	require.NoError(t, err)
+	for name, secret := range map[string][]byte{"host private key": hostKey, "root private key": rootKey, "escrow bag": pairRecord.EscrowBag} {
+		assert.False(t, bytes.Contains(serialized, secret), "%s must not be returned", name)
+		block, _ := pem.Decode(secret)
+		if block != nil {
+			assert.False(t, bytes.Contains(serialized, []byte(hex.EncodeToString(block.Bytes[:32]))), "%s must not be returned", name)
+		}
+	}
+	assert.NotContains(t, string(serialized), "PRIVATE KEY")
+}
+
+func TestPairRecordPublicInfoEmpty(t *testing.T) {
+	info := PairRecord{HostID: "host"}.PublicInfo()
+
+	assert.Equal(t, PairRecordInfo{HostID: "host"}, info)
+}
@@ -0,0 +1,83 @@
+package ios
+
+import (
+	"errors"
+	"fmt"
+)
+
+// HostTrustStatus describes if the device trusts the host go-ios is running on
+type HostTrustStatus struct {
+	// Paired is true if usbmuxd has a pair record for the device
+	Paired bool
+	// HostID and SystemBUID identify this host in the pair record, they are empty if the host is not paired
+	HostID     string
+	SystemBUID string
+	// Trusted is true if the device accepted the pair record of this host and reports a trusted host as attached.
+	// A paired host is not trusted anymore if the user reset the trust settings or the device was erased.
+	Trusted bool
+	// HostAttached is true if the device reports a host as attached
+	HostAttached bool
+	// SessionError contains the reason why the device rejected the pair record, f.ex. InvalidHostID
+	SessionError string
+}
+
+// GetHostTrustStatus reports if the device is paired with and trusts this host. Lockdown does not reveal which
+// other hosts a device is paired with, so only the status of this host can be determined.
+func GetHostTrustStatus(device DeviceEntry) (HostTrustStatus, error) {
+	muxConnection, err := NewUsbMuxConnectionSimple()
+	if err != nil {
+		return HostTrustStatus{}, fmt.Errorf("GetHostTrustStatus: USBMuxConnection failed with: %w", err)
+	}
+	defer muxConnection.ReleaseDeviceConnection()
+
+	err = muxConnection.Send(newReadPair(device.Properties.SerialNumber))
+	if err != nil {
+		return HostTrustStatus{}, fmt.Errorf("GetHostTrustStatus: failed requesting pair record: %w", err)
+	}
+	resp, err := muxConnection.ReadMessage()
+	if err != nil {
+		return HostTrustStatus{}, fmt.Errorf("GetHostTrustStatus: failed reading pair record: %w", err)
+	}
+	pairRecordData, err := pairRecordDatafromBytes(resp.Payload)
+	if err != nil {
+		return HostTrustStatus{Paired: false}, nil
+	}
+	pairRecord := PairRecordfromBytes(pairRecordData.PairRecordData)
+	status := HostTrustStatus{Paired: true, HostID: pairRecord.HostID, SystemBUID: pairRecord.SystemBUID}
+
+	lockdownConnection, err := muxConnection.connectLockdownSession(device.DeviceID, pairRecord)
+	if err != nil {
+		var sessionErr StartSessionError
+		if errors.As(err, &sessionErr) {
+			status.SessionError = sessionErr.Reason
+			return status, nil
+		}
+		return HostTrustStatus{}, fmt.Errorf("GetHostTrustStatus: %w", err)
+	}
+	defer lockdownConnection.Close()
+
+	err = lockdownConnection.Send(newGetValue(""))
+	if err != nil {
+		return HostTrustStatus{}, fmt.Errorf("GetHostTrustStatus: failed requesting lockdown values: %w", err)
+	}
+	valuesResp, err := lockdownConnection.ReadMessage()
+	if err != nil {
+		return HostTrustStatus{}, fmt.Errorf("GetHostTrustStatus: failed reading lockdown values: %w", err)
+	}
+	return hostTrustStatusFromValues(status, valuesResp)
+}
+
+// hostTrustStatusFromValues completes status with the attached host flags of a lockdown GetValue response
+func hostTrustStatusFromValues(status HostTrustStatus, getValueResponse []byte) (HostTrustStatus, error) {
+	response, err := ParsePlist(getValueResponse)
+	if err != nil {
+		return HostTrustStatus{}, fmt.Errorf("hostTrustStatusFromValues: %w", err)
+	}
+	values, ok := response["Value"].(map[string]interface{})
+	if !ok {
+		return HostTrustStatus{}, fmt.Errorf("hostTrustStatusFromValues: unexpected lockdown response: %+v", response)
+	}
+	status.Trusted, _ = values["TrustedHostAttached"].(bool)
+	status.HostAttached, _ = values["HostAttached"].(bool)
+	return status, nil
+}
@@ -0,0 +1,71 @@
+package ios
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const trustedHostValuesResponse = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Request</key>
+	<string>GetValue</string>
+	<key>Value</key>
+	<dict>
+		<key>ActivationState</key>
+		<string>Activated</string>
+		<key>DeviceClass</key>
+		<string>iPhone</string>
+		<key>HostAttached</key>
+		<true/>
+		<key>ProductVersion</key>
+		<string>17.5.1</string>
+		<key>TrustedHostAttached</key>
+		<true/>
+	</dict>
+</dict>
+</plist>`
+
+func TestHostTrustStatusFromValues(t *testing.T) {
+	paired := HostTrustStatus{Paired: true, HostID: "5F6C5C4B-0C1A-4B8E-9C1F-2B0E1D7A3C11", SystemBUID: "3C1B2A0F-7E5D-4C3B-A291-8F7E6D5C4B3A"}
+
+	status, err := hostTrustStatusFromValues(paired, []byte(trustedHostValuesResponse))
+
+	assert.NoError(t, err)
+	assert.Equal(t, HostTrustStatus{
+		Paired:       true,
+		HostID:       "5F6C5C4B-0C1A-4B8E-9C1F-2B0E1D7A3C11",
+		SystemBUID:   "3C1B2A0F-7E5D-4C3B-A291-8F7E6D5C4B3A",
+		Trusted:      true,
+		HostAttached: true,
+	}, status)
+}
+
+func TestHostTrustStatusFromValuesWithoutValue(t *testing.T) {
+	response := `<?xml version="1.0" encoding="UTF-8"?>
+<plist version="1.0">
+<dict>
+	<key>Error</key>
+	<string>InvalidHostID</string>
+	<key>Request</key>
+	<string>GetValue</string>
+</dict>
+</plist>`
+
+	_, err := hostTrustStatusFromValues(HostTrustStatus{Paired: true}, []byte(response))
+
+	assert.Error(t, err)
+}
+
+func TestStartSessionErrorSurvivesWrapping(t *testing.T) {
+	err := fmt.Errorf("StartSession failed: %+v error: %w", StartSessionResponse{}, StartSessionError{Reason: "InvalidHostID"})
+
+	var sessionErr StartSessionError
+	assert.True(t, errors.As(err, &sessionErr))
+	assert.Equal(t, "InvalidHostID", sessionErr.Reason)
+	assert.Equal(t, "failed to start new lockdown session: InvalidHostID", sessionErr.Error())
+}
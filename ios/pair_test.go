@@ -0,0 +1,58 @@
+package ios
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSupervisionIdentity(t *testing.T) {
+	identity, err := CreateDERFormattedSupervisionCert()
+	require.NoError(t, err)
+	pkcs1, err := x509.ParsePKCS1PrivateKey(identity.PrivateKeyDER)
+	require.NoError(t, err)
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(pkcs1)
+	require.NoError(t, err)
+
+	for name, files := range map[string][2][]byte{
+		"der":   {identity.CertDER, identity.PrivateKeyDER},
+		"pem":   {identity.CertPEM, identity.PrivateKeyPEM},
+		"pkcs8": {identity.CertPEM, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8})},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cert, key, err := ParseSupervisionIdentity(files[0], files[1])
+			require.NoError(t, err)
+			assert.Equal(t, identity.CertDER, cert.Raw)
+			_, err = Sign([]byte("challenge"), cert, key)
+			assert.NoError(t, err)
+		})
+	}
+
+	t.Run("rejects a key of another identity", func(t *testing.T) {
+		other, err := CreateDERFormattedSupervisionCert()
+		require.NoError(t, err)
+		_, _, err = ParseSupervisionIdentity(identity.CertDER, other.PrivateKeyDER)
+		assert.Error(t, err)
+	})
+
+	t.Run("rejects invalid files", func(t *testing.T) {
+		_, _, err := ParseSupervisionIdentity([]byte("no cert"), identity.PrivateKeyDER)
+		assert.Error(t, err)
+		_, _, err = ParseSupervisionIdentity(identity.CertDER, []byte("no key"))
+		assert.Error(t, err)
+	})
+}
+
+func TestExtractEscrowBag(t *testing.T) {
+	escrow, err := extractEscrowBag(ToPlistBytes(map[string]interface{}{"Request": "Pair", "EscrowBag": []byte("escrow")}))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("escrow"), escrow)
+
+	_, err = extractEscrowBag(ToPlistBytes(map[string]interface{}{"Request": "Pair", "Error": "MCChallengeRequired"}))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "MCChallengeRequired")
+	}
+}
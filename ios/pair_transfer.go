@@ -0,0 +1,139 @@
+package ios
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// PairRecordExportVersion is the version of the PairRecordExport format written by ExportPairRecord
+const PairRecordExportVersion = 1
+
+// PairRecordExport is a portable copy of the pair record of a device. It can be imported on another host, so that
+// the device trusts that host without pairing again. It contains the private keys and the escrow bag of the pair
+// record and must be stored as securely as the pair record itself.
+type PairRecordExport struct {
+	Version    int
+	UDID       string
+	PairRecord PairRecord
+}
+
+// ExportPairRecord reads the pair record of the device with udid from usbmuxd
+func ExportPairRecord(udid string) (PairRecordExport, error) {
+	pairRecord, err := ReadPairRecord(udid)
+	if err != nil {
+		return PairRecordExport{}, fmt.Errorf("ExportPairRecord: %w", err)
+	}
+	return PairRecordExport{Version: PairRecordExportVersion, UDID: udid, PairRecord: pairRecord}, nil
+}
+
+// ImportPairRecord saves the exported pair record with usbmuxd. An existing pair record of the device is replaced.
+func ImportPairRecord(export PairRecordExport) error {
+	err := export.validate()
+	if err != nil {
+		return fmt.Errorf("ImportPairRecord: %w", err)
+	}
+	muxConnection, err := NewUsbMuxConnectionSimple()
+	if err != nil {
+		return fmt.Errorf("ImportPairRecord: could not create usbmuxConnection: %w", err)
+	}
+	defer muxConnection.Close()
+	err = muxConnection.SavePairRecord(export.UDID, export.PairRecord)
+	if err != nil {
+		return fmt.Errorf("ImportPairRecord: %w", err)
+	}
+	return nil
+}
+
+// DeletePairRecord deletes the pair record of the device with udid from usbmuxd. The device has to be paired again
+// to be used with this host afterwards.
+func DeletePairRecord(udid string) error {
+	muxConnection, err := NewUsbMuxConnectionSimple()
+	if err != nil {
+		return fmt.Errorf("DeletePairRecord: could not create usbmuxConnection: %w", err)
+	}
+	defer muxConnection.Close()
+	err = muxConnection.DeletePairRecord(udid)
+	if err != nil {
+		return fmt.Errorf("DeletePairRecord: %w", err)
+	}
+	return nil
+}
+
+// ParsePairRecordExport decodes a PairRecordExport that was serialized as JSON
+func ParsePairRecordExport(b []byte) (PairRecordExport, error) {
+	var export PairRecordExport
+	err := json.Unmarshal(b, &export)
+	if err != nil {
+		return PairRecordExport{}, fmt.Errorf("ParsePairRecordExport: %w", err)
+	}
+	err = export.validate()
+	if err != nil {
+		return PairRecordExport{}, fmt.Errorf("ParsePairRecordExport: %w", err)
+	}
+	return export, nil
+}
+
+func (e PairRecordExport) validate() error {
+	if e.Version != PairRecordExportVersion {
+		return fmt.Errorf("unsupported pair record export version %d", e.Version)
+	}
+	if e.UDID == "" {
+		return fmt.Errorf("pair record export has no udid")
+	}
+	record := e.PairRecord
+	if record.HostID == "" || len(record.HostCertificate) == 0 || len(record.HostPrivateKey) == 0 {
+		return fmt.Errorf("pair record of %s has no host identity", e.UDID)
+	}
+	return nil
+}
+
+// DeletePair contains the usbmuxd request to delete a pair record
+type DeletePair struct {
+	BundleID            string
+	ClientVersionString string
+	MessageType         string
+	ProgName            string
+	LibUSBMuxVersion    uint32 `plist:"kLibUSBMuxVersion"`
+	PairRecordID        string
+}
+
+func newDeletePair(udid string) DeletePair {
+	return DeletePair{
+		BundleID:            "go.ios.control",
+		ClientVersionString: "go-ios-1.0.0",
+		MessageType:         "DeletePairRecord",
+		ProgName:            "go-ios",
+		LibUSBMuxVersion:    3,
+		PairRecordID:        udid,
+	}
+}
+
+// SavePairRecord saves pairRecord for the device with udid
+func (muxConn *UsbMuxConnection) SavePairRecord(udid string, pairRecord PairRecord) error {
+	ok, err := muxConn.savePair(udid, pairRecord.DeviceCertificate, pairRecord.HostPrivateKey, pairRecord.HostCertificate,
+		pairRecord.RootPrivateKey, pairRecord.RootCertificate, pairRecord.EscrowBag, pairRecord.WiFiMACAddress,
+		pairRecord.HostID, pairRecord.SystemBUID)
+	if err != nil {
+		return fmt.Errorf("failed saving pair record: %w", err)
+	}
+	if !ok {
+		return fmt.Errorf("usbmuxd refused saving the pair record of %s", udid)
+	}
+	return nil
+}
+
+// DeletePairRecord deletes the pair record of the device with udid
+func (muxConn *UsbMuxConnection) DeletePairRecord(udid string) error {
+	err := muxConn.Send(newDeletePair(udid))
+	if err != nil {
+		return fmt.Errorf("failed sending DeletePairRecord: %w", err)
+	}
+	resp, err := muxConn.ReadMessage()
+	if err != nil {
+		return fmt.Errorf("failed reading DeletePairRecord response: %w", err)
+	}
+	if !MuxResponsefromBytes(resp.Payload).IsSuccessFull() {
+		return fmt.Errorf("usbmuxd failed deleting the pair record of %s, is the device paired?", udid)
+	}
+	return nil
+}
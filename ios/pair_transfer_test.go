@@ -0,0 +1,47 @@
+package ios
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePairRecordExport(t *testing.T) {
+	export := PairRecordExport{
+		Version: PairRecordExportVersion,
+		UDID:    "00008110-0001",
+		PairRecord: PairRecord{
+			HostID:          "5A1F0C8E-6E3B-4C52-9D7A-0123456789AB",
+			SystemBUID:      "2F6A8E1C-1B3D-4E5F-8A9B-ABCDEF012345",
+			HostCertificate: []byte("host certificate"),
+			HostPrivateKey:  []byte("host private key"),
+			EscrowBag:       []byte("escrow bag"),
+			WiFiMACAddress:  "aa:bb:cc:dd:ee:ff",
+		},
+	}
+	b, err := json.Marshal(export)
+	require.NoError(t, err)
+
+	parsed, err := ParsePairRecordExport(b)
+	require.NoError(t, err)
+	assert.Equal(t, export, parsed)
+
+	t.Run("rejects invalid exports", func(t *testing.T) {
+		for name, modify := range map[string]func(*PairRecordExport){
+			"unknown version": func(e *PairRecordExport) { e.Version = 2 },
+			"missing udid":    func(e *PairRecordExport) { e.UDID = "" },
+			"missing key":     func(e *PairRecordExport) { e.PairRecord.HostPrivateKey = nil },
+		} {
+			invalid := export
+			modify(&invalid)
+			b, err := json.Marshal(invalid)
+			require.NoError(t, err)
+			_, err = ParsePairRecordExport(b)
+			assert.Error(t, err, name)
+		}
+		_, err := ParsePairRecordExport([]byte("not json"))
+		assert.Error(t, err)
+	})
+}
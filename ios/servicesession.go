@@ -0,0 +1,97 @@
+package ios
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+)
+
+// ServiceSession keeps track of the service connections opened for a device so that all of them can be released
+// with a single call to CloseAll, even if the caller forgets to close individual services.
+// It is safe for concurrent use.
+type ServiceSession struct {
+	device  DeviceEntry
+	mu      sync.Mutex
+	closers []io.Closer
+	closed  bool
+}
+
+// NewServiceSession creates a ServiceSession for device
+func NewServiceSession(device DeviceEntry) *ServiceSession {
+	return &ServiceSession{device: device}
+}
+
+// Device returns the device of the session
+func (s *ServiceSession) Device() DeviceEntry {
+	return s.device
+}
+
+// ConnectToService works like ConnectToService and tracks the returned connection
+func (s *ServiceSession) ConnectToService(serviceName string) (DeviceConnectionInterface, error) {
+	if err := s.checkOpen(); err != nil {
+		return nil, err
+	}
+	conn, err := ConnectToService(s.device, serviceName)
+	if err != nil {
+		return nil, err
+	}
+	return conn, s.Track(conn)
+}
+
+// ConnectToShimService works like ConnectToShimService and tracks the returned connection
+func (s *ServiceSession) ConnectToShimService(serviceName string) (DeviceConnectionInterface, error) {
+	if err := s.checkOpen(); err != nil {
+		return nil, err
+	}
+	conn, err := ConnectToShimService(s.device, serviceName)
+	if err != nil {
+		return nil, err
+	}
+	return conn, s.Track(conn)
+}
+
+// Track adds a connection that was opened elsewhere, f.ex. the Connection of a service package, to the session.
+// If the session is already closed, c is closed immediately and an error is returned.
+func (s *ServiceSession) Track(c io.Closer) error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closers = append(s.closers, c)
+		s.mu.Unlock()
+		return nil
+	}
+	s.mu.Unlock()
+	c.Close()
+	return fmt.Errorf("ServiceSession: session for device %s is already closed", s.device.Properties.SerialNumber)
+}
+
+// CloseAll closes all tracked connections in the reverse order they were opened. Connections that were already
+// closed by the caller are ignored. Calling CloseAll more than once is safe, subsequent calls do nothing.
+func (s *ServiceSession) CloseAll() error {
+	s.mu.Lock()
+	closers := s.closers
+	s.closers = nil
+	s.closed = true
+	s.mu.Unlock()
+
+	var errs []error
+	for i := len(closers) - 1; i >= 0; i-- {
+		if err := closers[i].Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("ServiceSession: failed closing connections: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+func (s *ServiceSession) checkOpen() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return fmt.Errorf("ServiceSession: session for device %s is already closed", s.device.Properties.SerialNumber)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package ios_test
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/danielpaulus/go-ios/ios"
+	"github.com/stretchr/testify/assert"
+)
+
+type trackedConn struct {
+	name   string
+	closed *[]string
+	err    error
+}
+
+func (c trackedConn) Close() error {
+	*c.closed = append(*c.closed, c.name)
+	return c.err
+}
+
+func TestServiceSessionCloseAll(t *testing.T) {
+	var closed []string
+	session := ios.NewServiceSession(ios.DeviceEntry{})
+	for _, name := range []string{"afc", "syslog", "instruments"} {
+		assert.NoError(t, session.Track(trackedConn{name: name, closed: &closed}))
+	}
+
+	assert.NoError(t, session.CloseAll())
+	assert.Equal(t, []string{"instruments", "syslog", "afc"}, closed)
+
+	assert.NoError(t, session.CloseAll(), "closing twice must be safe")
+	assert.Len(t, closed, 3, "connections must only be closed once")
+}
+
+func TestServiceSessionTrackAfterClose(t *testing.T) {
+	var closed []string
+	session := ios.NewServiceSession(ios.DeviceEntry{})
+	assert.NoError(t, session.CloseAll())
+
+	err := session.Track(trackedConn{name: "late", closed: &closed})
+
+	assert.Error(t, err)
+	assert.Equal(t, []string{"late"}, closed, "connections tracked after CloseAll are closed immediately")
+	_, err = session.ConnectToService("com.apple.afc")
+	assert.Error(t, err)
+}
+
+func TestServiceSessionCloseAllErrors(t *testing.T) {
+	var closed []string
+	session := ios.NewServiceSession(ios.DeviceEntry{})
+	assert.NoError(t, session.Track(trackedConn{name: "alreadyClosed", closed: &closed, err: fmt.Errorf("close: %w", net.ErrClosed)}))
+	assert.NoError(t, session.Track(trackedConn{name: "broken", closed: &closed, err: errors.New("broken pipe")}))
+	assert.NoError(t, session.Track(trackedConn{name: "ok", closed: &closed}))
+
+	err := session.CloseAll()
+
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "broken pipe")
+	}
+	assert.NotContains(t, err.Error(), net.ErrClosed.Error(), "connections closed by the caller are ignored")
+	assert.Equal(t, []string{"ok", "broken", "alreadyClosed"}, closed, "all connections are closed despite errors")
+}
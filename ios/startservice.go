@@ -53,13 +53,21 @@ func (lockDownConn *LockDownConnection) StartService(serviceName string) (StartS
 
 // StartService conveniently starts a service on a device and cleans up the used UsbMuxconnection.
 // It returns the service port as a uint16 in BigEndian byte order.
+// Services that only work over USB fail with ErrUSBRequired for devices connected over Wi-Fi, services the device
+// does not answer for over Wi-Fi do so after a timeout.
 func StartService(device DeviceEntry, serviceName string) (StartServiceResponse, error) {
+	if err := serviceAvailable(device, serviceName); err != nil {
+		return StartServiceResponse{}, err
+	}
+	if device.ConnectionPool != nil {
+		return device.ConnectionPool.StartService(device, serviceName)
+	}
 	lockdown, err := ConnectLockdownWithSession(device)
 	if err != nil {
 		return StartServiceResponse{}, err
 	}
 	defer lockdown.Close()
-	response, err := lockdown.StartService(serviceName)
+	response, err := startServiceOn(device, lockdown, serviceName)
 	if err != nil {
 		return response, err
 	}
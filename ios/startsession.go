@@ -33,6 +33,17 @@ type StartSessionResponse struct {
 	Error            string
 }
 
+// StartSessionError is returned by StartSession if lockdown rejected the session, f.ex. because the
+// device does not trust the pair record anymore.
+type StartSessionError struct {
+	// Reason is the error lockdown responded with, f.ex. InvalidHostID
+	Reason string
+}
+
+func (e StartSessionError) Error() string {
+	return fmt.Sprintf("failed to start new lockdown session: %s", e.Reason)
+}
+
 func startSessionResponsefromBytes(plistBytes []byte) StartSessionResponse {
 	decoder := plist.NewDecoder(bytes.NewReader(plistBytes))
 	var data StartSessionResponse
@@ -55,7 +66,7 @@ func (lockDownConn *LockDownConnection) StartSession(pairRecord PairRecord) (Sta
 	}
 	response := startSessionResponsefromBytes(resp)
 	if response.Error != "" {
-		return StartSessionResponse{}, fmt.Errorf("failed to start new lockdown session: %s", response.Error)
+		return StartSessionResponse{}, StartSessionError{Reason: response.Error}
 	}
 	lockDownConn.sessionID = response.SessionID
 	if response.EnableSessionSSL {
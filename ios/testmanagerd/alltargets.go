@@ -0,0 +1,101 @@
+package testmanagerd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/danielpaulus/go-ios/ios"
+)
+
+// WithAllTargets runs every test target of the .xctestrun file after another, see TestConfig.RunAllTargets
+func WithAllTargets() XCTestRunOption {
+	return func(config *TestConfig) {
+		config.RunAllTargets = true
+	}
+}
+
+// TargetResult summarizes the results of a single test target of a run with TestConfig.RunAllTargets
+type TargetResult struct {
+	BlueprintName string
+	// Passed includes expected failures, Failed includes stalled and crashed tests
+	Passed  int
+	Failed  int
+	Skipped int
+	// Err is the error the test session of the target ended with, f.ex. if the test runner crashed
+	Err error
+}
+
+// startAllTargets runs all test targets of the given test configuration of the .xctestrun file after another
+func startAllTargets(ctx context.Context, xctestrunFilePath string, device ios.DeviceEntry, listener *TestListener, configurationName string, opts ...XCTestRunOption) ([]TestSuite, error) {
+	file, err := os.Open(xctestrunFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("StartXCTestWithConfig: failed to open xctestrun file: %w", err)
+	}
+	targets, err := decodeTestTargets(file, configurationName)
+	file.Close()
+	if err != nil {
+		return nil, fmt.Errorf("StartXCTestWithConfig: %w", err)
+	}
+	if listener == nil {
+		listener = NewTestListener(io.Discard, io.Discard, "")
+	}
+	configs := make([]TestConfig, 0, len(targets))
+	for _, target := range targets {
+		target.xctestrunDir = filepath.Dir(xctestrunFilePath)
+		config, err := testConfigForTarget(target, device, listener, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("StartXCTestWithConfig: target %s: %w", target.BlueprintName, err)
+		}
+		configs = append(configs, config)
+	}
+	return runAllTargets(ctx, targets, configs, listener, RunTestWithConfig)
+}
+
+// runAllTargets runs the config of each target with run after another. The results of all targets are collected in
+// listener, a failing target does not stop the remaining ones.
+func runAllTargets(ctx context.Context, targets []schemeData, configs []TestConfig, listener *TestListener, run func(context.Context, TestConfig) ([]TestSuite, error)) ([]TestSuite, error) {
+	var errs []error
+	for i, config := range configs {
+		if i > 0 {
+			listener.restartSession()
+		}
+		firstSuite := len(listener.TestSuites)
+		_, err := run(ctx, config)
+		listener.TargetResults = append(listener.TargetResults, targetResultOf(targets[i].BlueprintName, listener.TestSuites[firstSuite:], err))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("target %s: %w", targets[i].BlueprintName, err))
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return listener.TestSuites, errors.Join(errs...)
+}
+
+// targetResultOf counts the test cases of suites by their status
+func targetResultOf(blueprintName string, suites []TestSuite, err error) TargetResult {
+	result := TargetResult{BlueprintName: blueprintName, Err: err}
+	result.Passed, result.Failed, result.Skipped = countTestCases(suites)
+	return result
+}
+
+// countTestCases counts the passed, failed and skipped test cases of suites, see TargetResult
+func countTestCases(suites []TestSuite) (passed int, failed int, skipped int) {
+	for _, suite := range suites {
+		for _, testCase := range suite.TestCases {
+			switch {
+			case isPassing(testCase.Status):
+				passed++
+			case isFailing(testCase.Status):
+				failed++
+			case testCase.Status == StatusSkipped:
+				skipped++
+			}
+		}
+	}
+	return passed, failed, skipped
+}
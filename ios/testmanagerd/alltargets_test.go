@@ -0,0 +1,63 @@
+package testmanagerd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunAllTargets(t *testing.T) {
+	listener := NewTestListener(io.Discard, io.Discard, t.TempDir())
+	targets := []schemeData{{BlueprintName: "LoginUITests"}, {BlueprintName: "CheckoutUITests"}}
+	configs := []TestConfig{{XctestConfigName: "LoginUITests.xctest"}, {XctestConfigName: "CheckoutUITests.xctest"}}
+
+	var ran []string
+	run := func(ctx context.Context, config TestConfig) ([]TestSuite, error) {
+		ran = append(ran, config.XctestConfigName)
+		switch config.XctestConfigName {
+		case "LoginUITests.xctest":
+			listener.testSuiteDidStart("LoginUITests", "2024-01-16 15:00:00 +0000")
+			listener.testCaseDidStartForClass("LoginUITests", "testLogin")
+			listener.testCaseDidFinishForTest("LoginUITests", "testLogin", "passed", 1)
+			listener.testCaseDidStartForClass("LoginUITests", "testLogout")
+			listener.testCaseDidFinishForTest("LoginUITests", "testLogout", "skipped", 0)
+			listener.testSuiteFinished("LoginUITests", "2024-01-16 15:00:01 +0000", 2, 0, 1, 0, 0, 0, 1, 1)
+			listener.didFinishExecutingTestPlan()
+			return listener.TestSuites, nil
+		default:
+			listener.testSuiteDidStart("CheckoutUITests", "2024-01-16 15:00:02 +0000")
+			listener.testCaseDidStartForClass("CheckoutUITests", "testPayment")
+			listener.testCaseFailedForClass("CheckoutUITests", "testPayment", "payment failed", "CheckoutUITests.swift", 12)
+			listener.testCaseDidFinishForTest("CheckoutUITests", "testPayment", "failed", 1)
+			listener.testCaseDidStartForClass("CheckoutUITests", "testRefund")
+			listener.sessionEndedAbnormally("lost connection", nil)
+			return listener.TestSuites, listener.err
+		}
+	}
+
+	suites, err := runAllTargets(context.Background(), targets, configs, listener, run)
+
+	assert.ErrorIs(t, err, ErrTestSessionCrashed)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "target CheckoutUITests")
+	}
+	assert.Equal(t, []string{"LoginUITests.xctest", "CheckoutUITests.xctest"}, ran)
+	assert.Len(t, suites, 2)
+	require.Len(t, listener.TargetResults, 2)
+	assert.Equal(t, TargetResult{BlueprintName: "LoginUITests", Passed: 1, Skipped: 1}, listener.TargetResults[0])
+	checkout := listener.TargetResults[1]
+	assert.Equal(t, "CheckoutUITests", checkout.BlueprintName)
+	assert.Equal(t, 0, checkout.Passed)
+	assert.Equal(t, 2, checkout.Failed, "the crashed test counts as failed")
+	assert.True(t, errors.Is(checkout.Err, ErrTestSessionCrashed))
+}
+
+func TestWithAllTargets(t *testing.T) {
+	var config TestConfig
+	WithAllTargets()(&config)
+	assert.True(t, config.RunAllTargets)
+}
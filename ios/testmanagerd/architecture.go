@@ -0,0 +1,21 @@
+package testmanagerd
+
+// preferredArchitectureKey is the launch option selecting the slice of a universal binary, it is passed through to
+// FrontBoard by both, instruments process control and CoreDevice app service
+const preferredArchitectureKey = "__PreferredArchitecture"
+
+// WithArchitecture launches the architecture slice arch of the test runner, f.ex. "arm64" or "arm64e", on devices
+// supporting more than one. Without it the device launches its preferred slice
+func WithArchitecture(arch string) XCTestRunOption {
+	return func(config *TestConfig) {
+		config.Architecture = arch
+	}
+}
+
+// withArchitecture adds the architecture to the launch options of the test runner if one was requested
+func withArchitecture(opts map[string]interface{}, architecture string) {
+	if architecture == "" {
+		return
+	}
+	opts[preferredArchitectureKey] = architecture
+}
@@ -0,0 +1,30 @@
+package testmanagerd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithArchitecture(t *testing.T) {
+	config := applyOptions(TestConfig{}, WithArchitecture("arm64e"))
+
+	assert.Equal(t, "arm64e", config.Architecture)
+}
+
+func TestTestRunnerLaunchOptionsArchitecture(t *testing.T) {
+	t.Run("device default", func(t *testing.T) {
+		opts := testRunnerLaunchOptions17(false, "")
+		assert.NotContains(t, opts, preferredArchitectureKey)
+		assert.Equal(t, uint64(1), opts["ActivateSuspended"])
+	})
+	t.Run("xcuitest runner", func(t *testing.T) {
+		opts := testRunnerLaunchOptions17(false, "arm64")
+		assert.Equal(t, "arm64", opts[preferredArchitectureKey])
+		assert.Equal(t, uint64(1), opts["ActivateSuspended"])
+	})
+	t.Run("xctest runner", func(t *testing.T) {
+		opts := testRunnerLaunchOptions17(true, "arm64e")
+		assert.Equal(t, map[string]interface{}{preferredArchitectureKey: "arm64e"}, opts)
+	})
+}
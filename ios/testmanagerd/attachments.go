@@ -0,0 +1,108 @@
+package testmanagerd
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+)
+
+// failureActivityType is the type of the activity in which XCTest reports the screenshot it captures automatically
+// when an assertion fails
+const failureActivityType = "com.apple.dt.xctest.activity-type.testAssertionFailure"
+
+// screenRecordingExtensions maps the uniform type identifiers of the screen recordings XCTest attaches to
+// UI tests with PreferredScreenCaptureFormat screenRecording to their file extension
+var screenRecordingExtensions = map[string]string{
+	"public.mpeg-4":             ".mp4",
+	"com.apple.quicktime-movie": ".mov",
+}
+
+// ScreenRecordings returns the screen recording attachments of all test cases of suites
+func ScreenRecordings(suites []TestSuite) []TestAttachment {
+	var recordings []TestAttachment
+	for _, suite := range suites {
+		for _, testCase := range suite.TestCases {
+			for _, attachment := range testCase.Attachments {
+				if _, ok := screenRecordingExtensions[attachment.UniformTypeIdentifier]; ok {
+					recordings = append(recordings, attachment)
+				}
+			}
+		}
+	}
+	return recordings
+}
+
+// screenRecordingPath returns a path in directory for a screen recording of the test case that does not exist yet.
+// The file is named <class>-<method> with the extension of the recording type, and a counter if the test case has
+// multiple recordings. ok is false if the attachment is not a screen recording.
+func screenRecordingPath(directory string, testCase *TestCase, uniformTypeIdentifier string) (path string, ok bool) {
+	extension, ok := screenRecordingExtensions[uniformTypeIdentifier]
+	if !ok {
+		return "", false
+	}
+	name := fmt.Sprintf("%s-%s", testCase.ClassName, testCase.MethodName)
+	path = filepath.Join(directory, name+extension)
+	for i := 2; ; i++ {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return path, true
+		}
+		path = filepath.Join(directory, fmt.Sprintf("%s-%d%s", name, i, extension))
+	}
+}
+
+// downscaleImage scales a png or jpeg image down so that neither its width nor its height exceed maxDimension while
+// keeping the aspect ratio. The image is encoded in its original format again. Images that already fit are returned unchanged.
+func downscaleImage(data []byte, maxDimension int) ([]byte, error) {
+	src, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("downscaleImage: failed to decode image: %w", err)
+	}
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= maxDimension && height <= maxDimension {
+		return data, nil
+	}
+
+	dstWidth, dstHeight := maxDimension, maxDimension
+	if width > height {
+		dstHeight = max(1, height*maxDimension/width)
+	} else {
+		dstWidth = max(1, width*maxDimension/height)
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+	// every destination pixel is the average of the source pixels it covers
+	for y := 0; y < dstHeight; y++ {
+		y0 := bounds.Min.Y + y*height/dstHeight
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*height/dstHeight)
+		for x := 0; x < dstWidth; x++ {
+			x0 := bounds.Min.X + x*width/dstWidth
+			x1 := max(x0+1, bounds.Min.X+(x+1)*width/dstWidth)
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+			dst.Set(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n)})
+		}
+	}
+
+	var out bytes.Buffer
+	switch format {
+	case "jpeg":
+		err = jpeg.Encode(&out, dst, nil)
+	default:
+		err = png.Encode(&out, dst)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("downscaleImage: failed to encode image: %w", err)
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,40 @@
+package testmanagerd
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// testSessionDrainTimeout is how long a cancelled test session waits for the test runner to report the results of
+// the running test before the runner is killed
+const testSessionDrainTimeout = 5 * time.Second
+
+// cancelTestSession ends the test session of a run whose context was cancelled. Like Xcode, the session is ended by
+// killing the test runner afterwards, there is no message asking the runner to stop. Until then its messages are
+// received until the session finished, one of closed is closed or testSessionDrainTimeout passed, so that the running
+// test can report its result. The results received so far are kept and err is returned by the run.
+func cancelTestSession(listener *TestListener, err error, closed ...<-chan struct{}) {
+	log.WithError(err).Info("test run cancelled, stopping the test session")
+
+	ended := make(chan struct{})
+	var endOnce sync.Once
+	end := func() { endOnce.Do(func() { close(ended) }) }
+	for _, c := range append(closed, listener.Done()) {
+		go func() {
+			select {
+			case <-c:
+				end()
+			case <-ended:
+			}
+		}()
+	}
+	select {
+	case <-ended:
+	case <-time.After(testSessionDrainTimeout):
+		log.Debug("test runner did not end the session in time")
+		end()
+	}
+	listener.sessionCancelled(err)
+}
@@ -0,0 +1,76 @@
+package testmanagerd
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+
+	dtx "github.com/danielpaulus/go-ios/ios/dtx_codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCancelTestSessionKeepsPartialResults(t *testing.T) {
+	listener := NewTestListener(io.Discard, io.Discard, t.TempDir())
+	listener.testSuiteDidStart("LoginUITests", "2024-01-16 15:00:00 +0000")
+	listener.testCaseDidStartForClass("LoginUITests", "testLogin")
+	listener.testCaseDidFinishForTest("LoginUITests", "testLogin", "passed", 1)
+	listener.testCaseDidStartForClass("LoginUITests", "testLogout")
+	connClosed := make(chan struct{})
+	close(connClosed)
+
+	cancelTestSession(listener, context.Canceled, connClosed)
+
+	assert.ErrorIs(t, listener.err, context.Canceled)
+	require.Len(t, listener.TestSuites, 1)
+	testCases := listener.TestSuites[0].TestCases
+	assert.Equal(t, StatusPassed, testCases[0].Status)
+	assert.Equal(t, StatusCancelled, testCases[1].Status)
+	assert.Nil(t, listener.runningTestSuite)
+	assert.Nil(t, listener.SessionCrash, "a cancelled run did not crash")
+}
+
+func TestCancelTestSessionReceivesPendingResults(t *testing.T) {
+	listener := NewTestListener(io.Discard, io.Discard, t.TempDir())
+	listener.testSuiteDidStart("LoginUITests", "2024-01-16 15:00:00 +0000")
+	listener.testCaseDidStartForClass("LoginUITests", "testLogin")
+	go func() {
+		listener.sessionMu.Lock()
+		defer listener.sessionMu.Unlock()
+		listener.testCaseDidFinishForTest("LoginUITests", "testLogin", "passed", 1)
+		listener.testSuiteFinished("LoginUITests", "2024-01-16 15:00:01 +0000", 1, 0, 0, 0, 0, 0, 1, 1)
+		listener.didFinishExecutingTestPlan()
+	}()
+
+	cancelTestSession(listener, context.Canceled)
+
+	assert.NoError(t, listener.err, "the session finished before it was cancelled")
+	require.Len(t, listener.TestSuites, 1)
+	assert.Equal(t, StatusPassed, listener.TestSuites[0].TestCases[0].Status)
+}
+
+func TestSessionCancelledWhileMessagesAreDispatched(t *testing.T) {
+	listener := NewTestListener(io.Discard, io.Discard, t.TempDir())
+	listener.testSuiteDidStart("LoginUITests", "2024-01-16 15:00:00 +0000")
+	listener.testCaseDidStartForClass("LoginUITests", "testLogin")
+	dispatcher := proxyDispatcher{testListener: listener}
+	// a message with missing arguments makes the dispatcher record a decoder error on the listener
+	invalidMessage := dtx.Message{Payload: []interface{}{"_XCT_testCaseDidStartForTestClass:method:"}}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			dispatcher.Dispatch(invalidMessage)
+		}
+	}()
+	listener.sessionCancelled(context.Canceled)
+	wg.Wait()
+
+	suites, err := listener.results()
+	require.Len(t, suites, 1)
+	assert.Equal(t, StatusCancelled, suites[0].TestCases[0].Status)
+	assert.Error(t, err)
+}
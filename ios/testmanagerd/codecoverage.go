@@ -0,0 +1,155 @@
+package testmanagerd
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/danielpaulus/go-ios/ios"
+	"github.com/danielpaulus/go-ios/ios/house_arrest"
+	"github.com/danielpaulus/go-ios/ios/installationproxy"
+	log "github.com/sirupsen/logrus"
+)
+
+// codeCoverageContainerDir is the directory relative to the data containers of the test runner and the app under
+// test that the instrumented binaries write their raw profiles to
+const codeCoverageContainerDir = "tmp/go-ios-coverage"
+
+// llvmProfileFileEnv tells the profiling runtime of binaries built with code coverage where to write the raw profile.
+// %p is replaced with the pid, so every process writes its own file
+const llvmProfileFileEnv = "LLVM_PROFILE_FILE"
+
+// CodeCoverageBuildableInfo describes a binary that is instrumented for code coverage, as listed in
+// CodeCoverageBuildableInfos of .xctestrun files. ProductPaths are the paths of the binaries on the build host,
+// llvm-cov needs them together with the merged profiles to produce a report
+type CodeCoverageBuildableInfo struct {
+	Name                string
+	BuildableIdentifier string
+	IncludeInReport     bool
+	Architectures       []string
+	ProductPaths        []string
+}
+
+// WithCodeCoverage collects the raw code coverage profiles of the test runner and the app under test and stores
+// them in dir after the run. If dir is empty, the ClangProfileDataDirectoryPath of the .xctestrun file is used,
+// see TestConfig.CodeCoverage
+func WithCodeCoverage(dir string) XCTestRunOption {
+	return func(config *TestConfig) {
+		config.CodeCoverage = true
+		if dir != "" {
+			config.CodeCoverageDir = dir
+		}
+	}
+}
+
+// containerFiles is the part of a house_arrest connection needed to pull files from an app container
+type containerFiles interface {
+	ListFiles(filePath string) ([]string, error)
+	ReadFile(filePath string) ([]byte, error)
+	Remove(filePath string) error
+}
+
+// startCodeCoverage lets the test runner and the UI target app of config write their raw profiles into their
+// data containers. The returned function pulls the profiles into config.CodeCoverageDir and adds them to
+// TestListener.CodeCoverageFiles.
+func startCodeCoverage(config *TestConfig) (func(), error) {
+	if config.CodeCoverageDir == "" {
+		return nil, errors.New("no directory for the coverage profiles, the xctestrun file does not specify a ClangProfileDataDirectoryPath")
+	}
+	if err := os.MkdirAll(config.CodeCoverageDir, 0o755); err != nil {
+		return nil, err
+	}
+	installationProxy, err := installationproxy.New(config.Device)
+	if err != nil {
+		return nil, err
+	}
+	apps, err := installationProxy.BrowseUserApps()
+	installationProxy.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	runner, err := getappInfo(config.TestRunnerBundleId, apps)
+	if err != nil {
+		return nil, err
+	}
+	bundleIDs := []string{config.TestRunnerBundleId}
+	config.Env = withProfileFile(config.Env, runner.homePath)
+	if config.BundleId != "" && !config.XcTest {
+		app, err := getappInfo(config.BundleId, apps)
+		if err != nil {
+			return nil, err
+		}
+		bundleIDs = append(bundleIDs, config.BundleId)
+		config.TargetAppEnv = withProfileFile(config.TargetAppEnv, app.homePath)
+	}
+
+	device, dir, listener := config.Device, config.CodeCoverageDir, config.Listener
+	return func() {
+		for _, bundleID := range bundleIDs {
+			files, err := pullCodeCoverage(device, bundleID, dir)
+			if err != nil {
+				log.WithFields(log.Fields{"error": err, "bundleID": bundleID}).Warn("failed pulling code coverage profiles")
+			}
+			if listener != nil {
+				listener.CodeCoverageFiles = append(listener.CodeCoverageFiles, files...)
+			}
+		}
+	}, nil
+}
+
+// withProfileFile returns a copy of env that lets instrumented binaries write their raw profiles into
+// codeCoverageContainerDir of the container at homePath
+func withProfileFile(env map[string]any, homePath string) map[string]any {
+	env = maps.Clone(env)
+	if env == nil {
+		env = map[string]any{}
+	}
+	env[llvmProfileFileEnv] = path.Join(homePath, codeCoverageContainerDir, "%p.profraw")
+	return env
+}
+
+func pullCodeCoverage(device ios.DeviceEntry, bundleID string, dir string) ([]string, error) {
+	houseArrestService, err := house_arrest.New(device, bundleID)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to the container of %s: %w", bundleID, err)
+	}
+	defer houseArrestService.Close()
+	return pullProfiles(houseArrestService, bundleID, dir)
+}
+
+// pullProfiles copies the raw profiles of the container to dir as <bundleID>-<pid>.profraw and removes them from
+// the device, so that later runs do not pull them again. It returns the paths of the pulled files.
+func pullProfiles(container containerFiles, bundleID string, dir string) ([]string, error) {
+	files, err := container.ListFiles(codeCoverageContainerDir)
+	if err != nil {
+		return nil, fmt.Errorf("could not list %s: %w", codeCoverageContainerDir, err)
+	}
+	var pulled []string
+	var errs []error
+	for _, f := range files {
+		if !strings.HasSuffix(f, ".profraw") {
+			continue
+		}
+		devicePath := path.Join(codeCoverageContainerDir, f)
+		content, err := container.ReadFile(devicePath)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not read %s: %w", devicePath, err))
+			continue
+		}
+		localPath := filepath.Join(dir, bundleID+"-"+f)
+		if err := os.WriteFile(localPath, content, 0o644); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		pulled = append(pulled, localPath)
+		if err := container.Remove(devicePath); err != nil {
+			log.WithFields(log.Fields{"error": err, "path": devicePath}).Debug("could not remove pulled coverage profile from device")
+		}
+	}
+	return pulled, errors.Join(errs...)
+}
@@ -0,0 +1,138 @@
+package testmanagerd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/danielpaulus/go-ios/ios"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const codeCoverageXCTestRun = `<?xml version="1.0" encoding="UTF-8"?>
+<plist version="1.0">
+<dict>
+	<key>CodeCoverageBuildableInfos</key>
+	<array>
+		<dict>
+			<key>Name</key>
+			<string>Runner.app</string>
+			<key>BuildableIdentifier</key>
+			<string>97C146ED1CF9000F007C117D:primary</string>
+			<key>IncludeInReport</key>
+			<true/>
+			<key>Architectures</key>
+			<array>
+				<string>arm64</string>
+			</array>
+			<key>ProductPaths</key>
+			<array>
+				<string>__TESTROOT__/Debug-iphoneos/Runner.app/Runner</string>
+			</array>
+		</dict>
+	</array>
+	<key>TestConfigurations</key>
+	<array>
+		<dict>
+			<key>Name</key>
+			<string>Test Scheme Action</string>
+			<key>TestTargets</key>
+			<array>
+				<dict>
+					<key>BlueprintName</key>
+					<string>RunnerTests</string>
+					<key>TestHostBundleIdentifier</key>
+					<string>com.example.runner</string>
+					<key>ClangProfileDataDirectoryPath</key>
+					<string>/DerivedData/Runner/Build/ProfileData</string>
+				</dict>
+			</array>
+		</dict>
+	</array>
+	<key>__xctestrun_metadata__</key>
+	<dict>
+		<key>FormatVersion</key>
+		<integer>2</integer>
+	</dict>
+</dict>
+</plist>`
+
+func TestParseCodeCoverage(t *testing.T) {
+	target, err := decode(strings.NewReader(codeCoverageXCTestRun), "")
+	require.NoError(t, err)
+	testConfig, err := target.buildTestConfig(ios.DeviceEntry{}, nil, nil)
+	require.NoError(t, err)
+
+	assert.False(t, testConfig.CodeCoverage)
+	assert.Equal(t, "/DerivedData/Runner/Build/ProfileData", testConfig.CodeCoverageDir)
+	assert.Equal(t, []CodeCoverageBuildableInfo{{
+		Name:                "Runner.app",
+		BuildableIdentifier: "97C146ED1CF9000F007C117D:primary",
+		IncludeInReport:     true,
+		Architectures:       []string{"arm64"},
+		ProductPaths:        []string{"__TESTROOT__/Debug-iphoneos/Runner.app/Runner"},
+	}}, testConfig.CodeCoverageBuildableInfos)
+
+	WithCodeCoverage("")(&testConfig)
+	assert.True(t, testConfig.CodeCoverage)
+	assert.Equal(t, "/DerivedData/Runner/Build/ProfileData", testConfig.CodeCoverageDir)
+	WithCodeCoverage("coverage")(&testConfig)
+	assert.Equal(t, "coverage", testConfig.CodeCoverageDir)
+}
+
+func TestWithProfileFile(t *testing.T) {
+	env := map[string]any{"KEY": "value"}
+	assert.Equal(t, map[string]any{
+		"KEY":              "value",
+		llvmProfileFileEnv: "/var/mobile/Containers/Data/Application/ABC/tmp/go-ios-coverage/%p.profraw",
+	}, withProfileFile(env, "/var/mobile/Containers/Data/Application/ABC"))
+	assert.Equal(t, map[string]any{"KEY": "value"}, env, "the environment of the config is not modified")
+}
+
+type fakeContainer struct {
+	files   map[string]string
+	removed []string
+}
+
+func (c *fakeContainer) ListFiles(dir string) ([]string, error) {
+	names := []string{".", ".."}
+	for p := range c.files {
+		if name, ok := strings.CutPrefix(p, dir+"/"); ok {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+func (c *fakeContainer) ReadFile(p string) ([]byte, error) {
+	content, ok := c.files[p]
+	if !ok {
+		return nil, errors.New("no such file")
+	}
+	return []byte(content), nil
+}
+
+func (c *fakeContainer) Remove(p string) error {
+	c.removed = append(c.removed, p)
+	return nil
+}
+
+func TestPullProfiles(t *testing.T) {
+	dir := t.TempDir()
+	container := &fakeContainer{files: map[string]string{
+		"tmp/go-ios-coverage/123.profraw": "runner profile",
+		"tmp/go-ios-coverage/notes.txt":   "not a profile",
+	}}
+
+	pulled, err := pullProfiles(container, "com.example.runner", dir)
+	require.NoError(t, err)
+	profile := filepath.Join(dir, "com.example.runner-123.profraw")
+	assert.Equal(t, []string{profile}, pulled)
+	content, err := os.ReadFile(profile)
+	require.NoError(t, err)
+	assert.Equal(t, "runner profile", string(content))
+	assert.Equal(t, []string{"tmp/go-ios-coverage/123.profraw"}, container.removed)
+}
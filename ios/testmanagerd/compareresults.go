@@ -0,0 +1,77 @@
+package testmanagerd
+
+import (
+	"sort"
+	"strings"
+)
+
+// ResultComparison lists the tests whose outcome changed between two test runs. Tests are identified by
+// {CLASS}/{METHOD}, all lists are sorted.
+type ResultComparison struct {
+	// Regressions failed in the current run but did not fail in the previous run, including tests that were added
+	Regressions []string
+	// Fixes failed in the previous run and passed in the current run
+	Fixes []string
+	// StillFailing failed in both runs
+	StillFailing []string
+	// Added are tests that only exist in the current run
+	Added []string
+	// Removed are tests that only exist in the previous run
+	Removed []string
+}
+
+// CompareResults compares the test results of two runs. Failed, crashed and stalled tests count as failing, passed
+// tests and expected failures as passing. Skipped tests are neither and are only reported as added or removed.
+func CompareResults(previous []TestSuite, current []TestSuite) ResultComparison {
+	previousStatus := testStatusByIdentifier(previous)
+	currentStatus := testStatusByIdentifier(current)
+
+	comparison := ResultComparison{}
+	for identifier, status := range currentStatus {
+		before, existed := previousStatus[identifier]
+		if !existed {
+			comparison.Added = append(comparison.Added, identifier)
+		}
+		switch {
+		case isFailing(status) && existed && isFailing(before):
+			comparison.StillFailing = append(comparison.StillFailing, identifier)
+		case isFailing(status):
+			comparison.Regressions = append(comparison.Regressions, identifier)
+		case isPassing(status) && existed && isFailing(before):
+			comparison.Fixes = append(comparison.Fixes, identifier)
+		}
+	}
+	for identifier := range previousStatus {
+		if _, exists := currentStatus[identifier]; !exists {
+			comparison.Removed = append(comparison.Removed, identifier)
+		}
+	}
+	for _, list := range [][]string{comparison.Regressions, comparison.Fixes, comparison.StillFailing, comparison.Added, comparison.Removed} {
+		sort.Strings(list)
+	}
+	return comparison
+}
+
+// testStatusByIdentifier maps the identifiers of all test cases to their status. If a test ran more than once, f.ex.
+// because of retries, a failure is kept.
+func testStatusByIdentifier(suites []TestSuite) map[string]TestCaseStatus {
+	statuses := map[string]TestCaseStatus{}
+	for _, suite := range suites {
+		for _, testCase := range suite.TestCases {
+			identifier := stripModuleName(testCase.ClassName) + "/" + strings.TrimSuffix(testCase.MethodName, "()")
+			if existing, ok := statuses[identifier]; ok && isFailing(existing) {
+				continue
+			}
+			statuses[identifier] = testCase.Status
+		}
+	}
+	return statuses
+}
+
+func isFailing(status TestCaseStatus) bool {
+	return status == StatusFailed || status == StatusCrashed || status == StatusStalled
+}
+
+func isPassing(status TestCaseStatus) bool {
+	return status == StatusPassed || status == StatusExpectedFailure
+}
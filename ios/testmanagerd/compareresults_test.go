@@ -0,0 +1,62 @@
+package testmanagerd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareResults(t *testing.T) {
+	previous := []TestSuite{
+		{Name: "LoginTests", TestCases: []TestCase{
+			{ClassName: "LoginTests", MethodName: "testLogin", Status: StatusPassed},
+			{ClassName: "LoginTests", MethodName: "testLogout", Status: StatusFailed},
+			{ClassName: "LoginTests", MethodName: "testResetPassword", Status: StatusFailed},
+			{ClassName: "LoginTests", MethodName: "testSignup", Status: StatusPassed},
+		}},
+		{Name: "CartTests", TestCases: []TestCase{
+			{ClassName: "CartTests", MethodName: "testCheckout", Status: StatusSkipped},
+			{ClassName: "CartTests", MethodName: "testLegacyCoupon", Status: StatusFailed},
+		}},
+	}
+	current := []TestSuite{
+		{Name: "LoginTests", TestCases: []TestCase{
+			{ClassName: "LoginTests", MethodName: "testLogin", Status: StatusCrashed},
+			{ClassName: "LoginTests", MethodName: "testLogout", Status: StatusPassed},
+			{ClassName: "LoginTests", MethodName: "testResetPassword", Status: StatusStalled},
+			{ClassName: "LoginTests", MethodName: "testSignup", Status: StatusPassed},
+		}},
+		{Name: "CartTests", TestCases: []TestCase{
+			{ClassName: "CartTests", MethodName: "testCheckout", Status: StatusFailed},
+			{ClassName: "CartTests", MethodName: "testEmptyCart", Status: StatusFailed},
+			{ClassName: "CartTests", MethodName: "testApplyCoupon", Status: StatusPassed},
+		}},
+	}
+
+	comparison := CompareResults(previous, current)
+
+	assert.Equal(t, ResultComparison{
+		Regressions:  []string{"CartTests/testCheckout", "CartTests/testEmptyCart", "LoginTests/testLogin"},
+		Fixes:        []string{"LoginTests/testLogout"},
+		StillFailing: []string{"LoginTests/testResetPassword"},
+		Added:        []string{"CartTests/testApplyCoupon", "CartTests/testEmptyCart"},
+		Removed:      []string{"CartTests/testLegacyCoupon"},
+	}, comparison)
+}
+
+func TestCompareResultsIdenticalRuns(t *testing.T) {
+	suites := []TestSuite{{Name: "LoginTests", TestCases: []TestCase{
+		{ClassName: "LoginTests", MethodName: "testLogin", Status: StatusPassed},
+		// a retried test counts as failing if any attempt failed
+		{ClassName: "LoginTests", MethodName: "testLogout", Status: StatusFailed},
+		{ClassName: "LoginTests", MethodName: "testLogout", Status: StatusPassed},
+	}}}
+
+	comparison := CompareResults(suites, suites)
+
+	assert.Empty(t, comparison.Regressions)
+	assert.Empty(t, comparison.Fixes)
+	assert.Equal(t, []string{"LoginTests/testLogout"}, comparison.StillFailing)
+	assert.Empty(t, comparison.Added)
+	assert.Empty(t, comparison.Removed)
+}
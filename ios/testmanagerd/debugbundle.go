@@ -0,0 +1,201 @@
+package testmanagerd
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"github.com/danielpaulus/go-ios/ios"
+	"howett.net/plist"
+)
+
+// redactedValue replaces the values of secret environment variables in debug bundles
+const redactedValue = "REDACTED"
+
+// secretNameParts are parts of environment variable names whose values are redacted, f.ex. API_TOKEN or DB_PASSWORD
+var secretNameParts = []string{"TOKEN", "SECRET", "PASSWORD", "PASSWD", "KEY", "AUTH", "CREDENTIAL", "COOKIE", "SESSION"}
+
+// debugBundleEnvironment describes the host and device the debug bundle was created for
+type debugBundleEnvironment struct {
+	GoIosVersion string
+	GoVersion    string
+	OS           string
+	Arch         string
+	Device       ios.DeviceProperties
+}
+
+// WriteDebugBundle writes a zip archive with reproduction material for bug reports about test runs to w. It contains
+//   - the raw .xctestrun file
+//   - xctestrun.json with all parsed test targets
+//   - testconfig.json with the TestConfig of the first target the test run would use, including opts. If it cannot be
+//     resolved, f.ex. because it depends on apps installed on the device, testconfig-error.txt contains the reason
+//   - environment.json with the go-ios, Go and host versions and the properties of device
+//
+// If redact is true, the values of environment variables whose names look like secrets, f.ex. API_TOKEN, are replaced
+// in all files, including the .xctestrun file.
+func WriteDebugBundle(w io.Writer, xctestrunFilePath string, device ios.DeviceEntry, redact bool, opts ...XCTestRunOption) error {
+	content, err := os.ReadFile(xctestrunFilePath)
+	if err != nil {
+		return fmt.Errorf("WriteDebugBundle: failed to read xctestrun file: %w", err)
+	}
+	var options TestConfig
+	for _, opt := range opts {
+		opt(&options)
+	}
+	targets, err := decodeTestTargets(bytes.NewReader(content), options.TestConfigurationName)
+	if err != nil {
+		return fmt.Errorf("WriteDebugBundle: %w", err)
+	}
+	if redact {
+		content, err = redactXCTestRun(content)
+		if err != nil {
+			return fmt.Errorf("WriteDebugBundle: %w", err)
+		}
+		for i := range targets {
+			targets[i].EnvironmentVariables = redactEnvironment(targets[i].EnvironmentVariables)
+			targets[i].TestingEnvironmentVariables = redactEnvironment(targets[i].TestingEnvironmentVariables)
+		}
+	}
+
+	archive := zip.NewWriter(w)
+	if err := writeZipFile(archive, filepath.Base(xctestrunFilePath), content); err != nil {
+		return fmt.Errorf("WriteDebugBundle: %w", err)
+	}
+	if err := writeZipJSON(archive, "xctestrun.json", targets); err != nil {
+		return fmt.Errorf("WriteDebugBundle: %w", err)
+	}
+
+	deviceInfo := ios.DeviceEntry{DeviceID: device.DeviceID, Properties: device.Properties}
+	testConfig, err := targets[0].buildTestConfig(deviceInfo, nil, nil)
+	if err != nil {
+		err = writeZipFile(archive, "testconfig-error.txt", []byte(err.Error()))
+	} else {
+		for _, opt := range opts {
+			opt(&testConfig)
+		}
+		if redact {
+			testConfig.Env = redactEnvironment(testConfig.Env)
+			for identifier, env := range testConfig.TestEnvironmentOverrides {
+				testConfig.TestEnvironmentOverrides[identifier] = redactEnvironment(env)
+			}
+		}
+		err = writeZipJSON(archive, "testconfig.json", testConfig)
+	}
+	if err != nil {
+		return fmt.Errorf("WriteDebugBundle: %w", err)
+	}
+
+	environment := debugBundleEnvironment{
+		GoIosVersion: goIosVersion(),
+		GoVersion:    runtime.Version(),
+		OS:           runtime.GOOS,
+		Arch:         runtime.GOARCH,
+		Device:       device.Properties,
+	}
+	if err := writeZipJSON(archive, "environment.json", environment); err != nil {
+		return fmt.Errorf("WriteDebugBundle: %w", err)
+	}
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("WriteDebugBundle: %w", err)
+	}
+	return nil
+}
+
+func writeZipFile(archive *zip.Writer, name string, content []byte) error {
+	f, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(content)
+	return err
+}
+
+func writeZipJSON(archive *zip.Writer, name string, v any) error {
+	content, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed encoding %s: %w", name, err)
+	}
+	return writeZipFile(archive, name, content)
+}
+
+// goIosVersion returns the version of the go-ios module the binary was built with
+func goIosVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if info.Main.Path == "github.com/danielpaulus/go-ios" {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == "github.com/danielpaulus/go-ios" {
+			return dep.Version
+		}
+	}
+	return "unknown"
+}
+
+// isSecretName returns true if the environment variable name looks like it contains a secret
+func isSecretName(name string) bool {
+	upper := strings.ToUpper(name)
+	for _, part := range secretNameParts {
+		if strings.Contains(upper, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactEnvironment returns a copy of env with the values of secret variables replaced
+func redactEnvironment(env map[string]any) map[string]any {
+	if env == nil {
+		return nil
+	}
+	redacted := make(map[string]any, len(env))
+	for name, value := range env {
+		if isSecretName(name) {
+			value = redactedValue
+		}
+		redacted[name] = value
+	}
+	return redacted
+}
+
+// redactXCTestRun replaces the values of secret variables in all EnvironmentVariables and TestingEnvironmentVariables
+// dictionaries of the xctestrun content and returns it as XML plist
+func redactXCTestRun(content []byte) ([]byte, error) {
+	var xctestrun any
+	if _, err := plist.Unmarshal(content, &xctestrun); err != nil {
+		return nil, fmt.Errorf("failed decoding xctestrun file: %w", err)
+	}
+	redacted, err := plist.MarshalIndent(redactPlistValue(xctestrun), plist.XMLFormat, "\t")
+	if err != nil {
+		return nil, fmt.Errorf("failed encoding xctestrun file: %w", err)
+	}
+	return redacted, nil
+}
+
+func redactPlistValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, child := range v {
+			if env, ok := child.(map[string]any); ok && (key == "EnvironmentVariables" || key == "TestingEnvironmentVariables") {
+				v[key] = redactEnvironment(env)
+				continue
+			}
+			v[key] = redactPlistValue(child)
+		}
+	case []any:
+		for i, child := range v {
+			v[i] = redactPlistValue(child)
+		}
+	}
+	return value
+}
@@ -0,0 +1,109 @@
+package testmanagerd
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/danielpaulus/go-ios/ios"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const debugBundleXCTestRun = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>RunnerTests</key>
+	<dict>
+		<key>TestHostBundleIdentifier</key>
+		<string>com.example.RunnerTests.xctrunner</string>
+		<key>TestBundlePath</key>
+		<string>__TESTHOST__/PlugIns/RunnerTests.xctest</string>
+		<key>IsUITestBundle</key>
+		<true/>
+		<key>EnvironmentVariables</key>
+		<dict>
+			<key>API_TOKEN</key>
+			<string>s3cr3t-token</string>
+			<key>LOG_LEVEL</key>
+			<string>debug</string>
+		</dict>
+		<key>OnlyTestIdentifiers</key>
+		<array>
+			<string>LoginTests/testLogin</string>
+		</array>
+	</dict>
+	<key>__xctestrun_metadata__</key>
+	<dict>
+		<key>FormatVersion</key>
+		<integer>1</integer>
+	</dict>
+</dict>
+</plist>`
+
+func readDebugBundle(t *testing.T, redact bool) map[string][]byte {
+	xctestrunPath := filepath.Join(t.TempDir(), "Runner.xctestrun")
+	require.NoError(t, os.WriteFile(xctestrunPath, []byte(debugBundleXCTestRun), 0o644))
+	device := ios.DeviceEntry{DeviceID: 3, Properties: ios.DeviceProperties{SerialNumber: "00008030-001A2B3C4D5E6F"}}
+
+	var bundle bytes.Buffer
+	err := WriteDebugBundle(&bundle, xctestrunPath, device, redact, WithLanguage("de", "DE"))
+	require.NoError(t, err)
+
+	archive, err := zip.NewReader(bytes.NewReader(bundle.Bytes()), int64(bundle.Len()))
+	require.NoError(t, err)
+	files := map[string][]byte{}
+	for _, f := range archive.File {
+		r, err := f.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(r)
+		require.NoError(t, err)
+		r.Close()
+		files[f.Name] = content
+	}
+	return files
+}
+
+func TestWriteDebugBundle(t *testing.T) {
+	files := readDebugBundle(t, false)
+
+	assert.Len(t, files, 4)
+	assert.Equal(t, debugBundleXCTestRun, string(files["Runner.xctestrun"]))
+
+	var targets []schemeData
+	require.NoError(t, json.Unmarshal(files["xctestrun.json"], &targets))
+	require.Len(t, targets, 1)
+	assert.Equal(t, "com.example.RunnerTests.xctrunner", targets[0].TestHostBundleIdentifier)
+
+	var config TestConfig
+	require.NoError(t, json.Unmarshal(files["testconfig.json"], &config))
+	assert.Equal(t, "com.example.RunnerTests.xctrunner", config.TestRunnerBundleId)
+	assert.Equal(t, []string{"LoginTests/testLogin"}, config.TestsToRun)
+	assert.Equal(t, "de", config.Language, "options are applied")
+	assert.Equal(t, map[string]any{"API_TOKEN": "s3cr3t-token", "LOG_LEVEL": "debug"}, config.Env)
+	assert.Equal(t, "00008030-001A2B3C4D5E6F", config.Device.Properties.SerialNumber)
+
+	var environment debugBundleEnvironment
+	require.NoError(t, json.Unmarshal(files["environment.json"], &environment))
+	assert.NotEmpty(t, environment.GoVersion)
+	assert.Equal(t, "00008030-001A2B3C4D5E6F", environment.Device.SerialNumber)
+}
+
+func TestWriteDebugBundleRedacted(t *testing.T) {
+	files := readDebugBundle(t, true)
+
+	for name, content := range files {
+		assert.NotContains(t, string(content), "s3cr3t-token", name)
+	}
+	assert.Contains(t, string(files["Runner.xctestrun"]), redactedValue)
+	assert.Contains(t, string(files["Runner.xctestrun"]), "debug", "other values are kept")
+
+	var config TestConfig
+	require.NoError(t, json.Unmarshal(files["testconfig.json"], &config))
+	assert.Equal(t, map[string]any{"API_TOKEN": redactedValue, "LOG_LEVEL": "debug"}, config.Env)
+}
@@ -0,0 +1,97 @@
+package testmanagerd
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	ios "github.com/danielpaulus/go-ios/ios"
+	log "github.com/sirupsen/logrus"
+)
+
+// WithEventStream writes every event of the test run as a line of JSON to w while the tests run, see
+// TestConfig.EventStream
+func WithEventStream(w io.Writer) XCTestRunOption {
+	return func(config *TestConfig) {
+		config.EventStream = w
+	}
+}
+
+// TestEventType is the kind of a TestEvent
+type TestEventType string
+
+const (
+	EventTestSuiteStarted  = TestEventType("testSuiteStarted")
+	EventTestSuiteFinished = TestEventType("testSuiteFinished")
+	EventTestStarted       = TestEventType("testStarted")
+	// EventTestFailed is sent for each failure of a test as soon as it is recorded, a test can fail more than once
+	EventTestFailed = TestEventType("testFailed")
+	// EventTestFinished contains the final status of the test, f.ex. passed, failed or skipped
+	EventTestFinished = TestEventType("testFinished")
+	EventAttachment   = TestEventType("attachment")
+	EventLog          = TestEventType("log")
+	// EventTestPlanFinished is the last event of a test run, with the locale of the device
+	EventTestPlanFinished = TestEventType("testPlanFinished")
+	// EventSessionCrashed is sent if the test session ended before the test plan finished, see SessionCrash. It
+	// contains the locale of the device like EventTestPlanFinished.
+	EventSessionCrashed = TestEventType("sessionCrashed")
+	// EventOutput is a line of stdout or stderr of the test runner, with the test case that was running when it was
+	// written
+	EventOutput = TestEventType("output")
+	// EventRunnerStarted is sent when the test runner was launched, with its process id
+	EventRunnerStarted = TestEventType("runnerStarted")
+)
+
+// TestEvent is a single event of a test run as written to TestConfig.EventStream. Only the fields relevant for the
+// Type are set.
+type TestEvent struct {
+	Type       TestEventType     `json:"type"`
+	Time       time.Time         `json:"time"`
+	Suite      string            `json:"suite,omitempty"`
+	ClassName  string            `json:"className,omitempty"`
+	MethodName string            `json:"methodName,omitempty"`
+	Status     TestCaseStatus    `json:"status,omitempty"`
+	Duration   float64           `json:"duration,omitempty"`
+	Error      *TestError        `json:"error,omitempty"`
+	Attachment *TestAttachment   `json:"attachment,omitempty"`
+	Message    string            `json:"message,omitempty"`
+	PID        uint64            `json:"pid,omitempty"`
+	Locale     *ios.DeviceLocale `json:"locale,omitempty"`
+}
+
+// eventStream encodes TestEvents as newline delimited JSON
+type eventStream struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+	now     func() time.Time
+}
+
+func newEventStream(w io.Writer) *eventStream {
+	return &eventStream{encoder: json.NewEncoder(w), now: time.Now}
+}
+
+func (s *eventStream) emit(event TestEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	event.Time = s.now()
+	if err := s.encoder.Encode(event); err != nil {
+		log.WithFields(log.Fields{"error": err, "event": event.Type}).Debug("failed writing test event")
+	}
+}
+
+// deviceLocale returns the locale of the device for the final event of the test run, nil if it was not read
+func (t *TestListener) deviceLocale() *ios.DeviceLocale {
+	if t.DeviceLocale == (ios.DeviceLocale{}) {
+		return nil
+	}
+	locale := t.DeviceLocale
+	return &locale
+}
+
+// emit writes event to the event stream of the listener, if there is one
+func (t *TestListener) emit(event TestEvent) {
+	if t.events != nil {
+		t.events.emit(event)
+	}
+}
@@ -0,0 +1,61 @@
+package testmanagerd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	ios "github.com/danielpaulus/go-ios/ios"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListenerStreamsEventsAsJSONLines(t *testing.T) {
+	var stream bytes.Buffer
+	listener := NewTestListener(io.Discard, io.Discard, t.TempDir())
+	listener.events = newEventStream(&stream)
+	now := time.Date(2024, 1, 16, 15, 0, 0, 0, time.UTC)
+	listener.events.now = func() time.Time { return now }
+	listener.DeviceLocale = ios.DeviceLocale{Language: "de", Locale: "de_DE", Region: "DE"}
+
+	listener.testSuiteDidStart("LoginUITests", "2024-01-16 15:00:00 +0000")
+	listener.testCaseDidStartForClass("LoginUITests", "testLogin")
+	listener.LogMessage("t = 0.01s Start Test")
+	listener.testCaseDidFinishForTest("LoginUITests", "testLogin", "passed", 1.5)
+	listener.testCaseDidStartForClass("LoginUITests", "testLogout")
+	listener.testCaseFailedForClass("LoginUITests", "testLogout", "XCTAssertTrue failed", "LoginUITests.swift", 42)
+	listener.testCaseDidFinishForTest("LoginUITests", "testLogout", "failed", 2)
+	listener.testSuiteFinished("LoginUITests", "2024-01-16 15:00:04 +0000", 2, 1, 0, 0, 0, 0, 3.5, 4)
+	listener.didFinishExecutingTestPlan()
+
+	var events []TestEvent
+	scanner := bufio.NewScanner(&stream)
+	for scanner.Scan() {
+		var event TestEvent
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event), scanner.Text())
+		events = append(events, event)
+	}
+	failure := &TestError{Message: "XCTAssertTrue failed", File: "LoginUITests.swift", Line: 42}
+	assert.Equal(t, []TestEvent{
+		{Type: EventTestSuiteStarted, Time: now, Suite: "LoginUITests"},
+		{Type: EventTestStarted, Time: now, ClassName: "LoginUITests", MethodName: "testLogin"},
+		{Type: EventLog, Time: now, Message: "t = 0.01s Start Test"},
+		{Type: EventTestFinished, Time: now, ClassName: "LoginUITests", MethodName: "testLogin", Status: StatusPassed, Duration: 1.5},
+		{Type: EventTestStarted, Time: now, ClassName: "LoginUITests", MethodName: "testLogout"},
+		{Type: EventTestFailed, Time: now, ClassName: "LoginUITests", MethodName: "testLogout", Error: failure},
+		{Type: EventTestFinished, Time: now, ClassName: "LoginUITests", MethodName: "testLogout", Status: StatusFailed, Duration: 2, Error: failure},
+		{Type: EventTestSuiteFinished, Time: now, Suite: "LoginUITests", Duration: 4},
+		{Type: EventTestPlanFinished, Time: now, Locale: &ios.DeviceLocale{Language: "de", Locale: "de_DE", Region: "DE"}},
+	}, events)
+}
+
+func TestListenerWithoutEventStream(t *testing.T) {
+	listener := NewTestListener(io.Discard, io.Discard, t.TempDir())
+	assert.NotPanics(t, func() {
+		listener.testSuiteDidStart("LoginUITests", "2024-01-16 15:00:00 +0000")
+		listener.LogMessage("message")
+	})
+}
@@ -0,0 +1,155 @@
+package testmanagerd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/danielpaulus/go-ios/ios"
+	"github.com/danielpaulus/go-ios/ios/instruments"
+	log "github.com/sirupsen/logrus"
+)
+
+// failureScreenshotMinInterval is the minimum time between two failure screenshots. Failures that happen in quick
+// succession, f.ex. because all remaining tests of a class fail after the app crashed, do not trigger new screenshots.
+const failureScreenshotMinInterval = 2 * time.Second
+
+// WithScreenshotOnFailure captures a screenshot of the device each time a test fails and stores it in dir,
+// see TestConfig.FailureScreenshotDir
+func WithScreenshotOnFailure(dir string) XCTestRunOption {
+	return func(config *TestConfig) {
+		config.FailureScreenshotDir = dir
+	}
+}
+
+// failureScreenshotQueueSize is the number of failure screenshots that can wait for the screenshot service. Failures
+// beyond that are not captured.
+const failureScreenshotQueueSize = 4
+
+// failureScreenshotter captures a screenshot with the screenshot instruments service when a test case fails. The
+// screenshots are captured on a separate goroutine, so that the messages of testmanagerd are not delayed.
+type failureScreenshotter struct {
+	dir      string
+	capture  func() ([]byte, error)
+	now      func() time.Time
+	listener *TestListener
+
+	// mu guards lastCapture, stopped and sending on requests
+	mu          sync.Mutex
+	lastCapture time.Time
+	stopped     bool
+	requests    chan failureScreenshotRequest
+	done        chan struct{}
+}
+
+// failureScreenshotRequest is a failed test case waiting for its screenshot
+type failureScreenshotRequest struct {
+	className  string
+	methodName string
+	failedAt   time.Time
+}
+
+// startFailureScreenshots connects to the screenshot service of the device and lets listener capture a screenshot
+// on every failed test. The returned function waits for the pending screenshots and closes the connection to the
+// screenshot service.
+func startFailureScreenshots(device ios.DeviceEntry, dir string, listener *TestListener) (func(), error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	screenshotService, err := instruments.NewScreenshotService(device)
+	if err != nil {
+		return nil, err
+	}
+	screenshotter := newFailureScreenshotter(dir, screenshotService.TakeScreenshot, listener)
+	listener.failureScreenshotter = screenshotter
+	return func() {
+		screenshotter.stop()
+		screenshotService.Close()
+	}, nil
+}
+
+func newFailureScreenshotter(dir string, capture func() ([]byte, error), listener *TestListener) *failureScreenshotter {
+	s := &failureScreenshotter{
+		dir:      dir,
+		capture:  capture,
+		now:      time.Now,
+		listener: listener,
+		requests: make(chan failureScreenshotRequest, failureScreenshotQueueSize),
+		done:     make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// testFailed queues a screenshot for the failed testCase. Failures right after the last screenshot and failures that
+// do not fit into the queue anymore are logged and dropped.
+func (s *failureScreenshotter) testFailed(testCase *TestCase) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		return
+	}
+	now := s.now()
+	if !s.lastCapture.IsZero() && now.Sub(s.lastCapture) < failureScreenshotMinInterval {
+		log.WithFields(log.Fields{"test": testCase.ClassName + "/" + testCase.MethodName, "lastScreenshot": now.Sub(s.lastCapture)}).
+			Info("no failure screenshot for rapid successive failure")
+		return
+	}
+	select {
+	case s.requests <- failureScreenshotRequest{className: testCase.ClassName, methodName: testCase.MethodName, failedAt: now}:
+		s.lastCapture = now
+	default:
+		log.WithField("test", testCase.ClassName+"/"+testCase.MethodName).Warn("no failure screenshot, too many screenshots are pending")
+	}
+}
+
+// stop waits until the queued screenshots were captured, no screenshots are captured afterwards
+func (s *failureScreenshotter) stop() {
+	s.mu.Lock()
+	if !s.stopped {
+		s.stopped = true
+		close(s.requests)
+	}
+	s.mu.Unlock()
+	<-s.done
+}
+
+func (s *failureScreenshotter) run() {
+	defer close(s.done)
+	for request := range s.requests {
+		s.captureScreenshot(request)
+	}
+}
+
+// captureScreenshot stores a screenshot as <class>-<method>.png and adds it to the attachments of the test case
+func (s *failureScreenshotter) captureScreenshot(request failureScreenshotRequest) {
+	screenshot, err := s.capture()
+	if err != nil {
+		log.WithError(err).Warn("failed capturing screenshot of failed test")
+		return
+	}
+	screenshotPath := filepath.Join(s.dir, failureScreenshotName(request.className, request.methodName))
+	if err := os.WriteFile(screenshotPath, screenshot, 0o644); err != nil {
+		log.WithError(err).Warn("failed writing screenshot of failed test")
+		return
+	}
+	s.listener.addAttachment(request.className, request.methodName, TestAttachment{
+		Name:                  "Failure Screenshot",
+		Path:                  screenshotPath,
+		Timestamp:             float64(request.failedAt.Unix()),
+		UniformTypeIdentifier: "public.png",
+	})
+}
+
+func failureScreenshotName(className string, methodName string) string {
+	return testCaseFileName(className, methodName, ".png")
+}
+
+// testCaseFileName returns <class>-<method><extension> with characters not allowed in file names replaced
+func testCaseFileName(className string, methodName string, extension string) string {
+	replacer := strings.NewReplacer("/", "_", "\\", "_", ":", "_", " ", "_")
+	return fmt.Sprintf("%s-%s%s", replacer.Replace(className), replacer.Replace(methodName), extension)
+}
@@ -0,0 +1,76 @@
+package testmanagerd
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFailureScreenshots(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 1, 16, 15, 36, 43, 0, time.UTC)
+	captures := 0
+	listener := NewTestListener(io.Discard, io.Discard, os.TempDir())
+	screenshotter := newFailureScreenshotter(dir, func() ([]byte, error) {
+		captures++
+		return []byte("png"), nil
+	}, listener)
+	screenshotter.now = func() time.Time { return now }
+	listener.failureScreenshotter = screenshotter
+	runTest := func(method string, status string) {
+		listener.testCaseDidStartForClass("LoginTests", method)
+		if status == "failed" {
+			listener.testCaseFailedForClass("LoginTests", method, "failed", "LoginTests.swift", 1)
+		}
+		listener.testCaseDidFinishForTest("LoginTests", method, status, 1)
+	}
+	listener.testSuiteDidStart("LoginTests", "2024-01-16 15:36:43 +0000")
+
+	runTest("testLogin", "failed")
+	runTest("testLogout", "passed")
+	now = now.Add(5 * time.Second)
+	runTest("testReset", "failed")
+	now = now.Add(500 * time.Millisecond)
+	runTest("testCascadingFailure", "failed")
+	screenshotter.stop()
+
+	assert.Equal(t, 2, captures, "a screenshot should be captured per failure, except for rapid cascading failures")
+	assert.FileExists(t, filepath.Join(dir, "LoginTests-testLogin.png"))
+	assert.FileExists(t, filepath.Join(dir, "LoginTests-testReset.png"))
+	testCases := listener.runningTestSuite.TestCases
+	assert.Equal(t, filepath.Join(dir, "LoginTests-testLogin.png"), testCases[0].Attachments[0].Path)
+	assert.Empty(t, testCases[1].Attachments)
+	assert.Empty(t, testCases[3].Attachments)
+}
+
+func TestFailureScreenshotDoesNotBlockListener(t *testing.T) {
+	release := make(chan struct{})
+	listener := NewTestListener(io.Discard, io.Discard, os.TempDir())
+	screenshotter := newFailureScreenshotter(t.TempDir(), func() ([]byte, error) {
+		<-release
+		return []byte("png"), nil
+	}, listener)
+	listener.failureScreenshotter = screenshotter
+	listener.testSuiteDidStart("LoginTests", "2024-01-16 15:36:43 +0000")
+
+	finished := make(chan struct{})
+	go func() {
+		listener.testCaseDidStartForClass("LoginTests", "testLogin")
+		listener.testCaseFailedForClass("LoginTests", "testLogin", "failed", "LoginTests.swift", 1)
+		listener.testCaseDidFinishForTest("LoginTests", "testLogin", "failed", 1)
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatal("the listener waited for the screenshot")
+	}
+
+	close(release)
+	screenshotter.stop()
+	assert.Len(t, listener.runningTestSuite.TestCases[0].Attachments, 1)
+}
@@ -0,0 +1,9 @@
+package testmanagerd
+
+// applyOptions returns config with opts applied
+func applyOptions(config TestConfig, opts ...XCTestRunOption) TestConfig {
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return config
+}
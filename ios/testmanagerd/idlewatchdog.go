@@ -0,0 +1,98 @@
+package testmanagerd
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	dtx "github.com/danielpaulus/go-ios/ios/dtx_codec"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrTestSessionIdle is returned if testmanagerd did not send any message for TestConfig.IdleTimeout. This happens if
+// the device or the test runner stops responding without closing the connection.
+var ErrTestSessionIdle = errors.New("test session idle")
+
+// WithIdleTimeout fails the test run if no message is received from testmanagerd for timeout, see TestConfig.IdleTimeout
+func WithIdleTimeout(timeout time.Duration) XCTestRunOption {
+	return func(config *TestConfig) {
+		config.IdleTimeout = timeout
+	}
+}
+
+// idleConnection is a connection whose activity the idle watchdog observes, implemented by dtx.Connection
+type idleConnection interface {
+	LastMessageReceived() time.Time
+	Channels() []dtx.ChannelState
+}
+
+// idleWatchdog fails the run of listener if none of its connections received a message for timeout
+type idleWatchdog struct {
+	timeout  time.Duration
+	listener *TestListener
+	conns    []idleConnection
+	now      func() time.Time
+}
+
+// startIdleWatchdog watches the testmanagerd connections of a test run if config.IdleTimeout is set. The returned
+// function stops watching.
+func startIdleWatchdog(config TestConfig, conns ...*dtx.Connection) func() {
+	if config.IdleTimeout <= 0 || config.Listener == nil {
+		return func() {}
+	}
+	watchdog := &idleWatchdog{timeout: config.IdleTimeout, listener: config.Listener, now: time.Now}
+	for _, conn := range conns {
+		watchdog.conns = append(watchdog.conns, conn)
+	}
+	stop := make(chan struct{})
+	go watchdog.run(stop)
+	var stopOnce sync.Once
+	return func() {
+		stopOnce.Do(func() { close(stop) })
+	}
+}
+
+func (w *idleWatchdog) run(stop <-chan struct{}) {
+	ticker := time.NewTicker(max(min(w.timeout/10, time.Second), 10*time.Millisecond))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-w.listener.Done():
+			return
+		case <-ticker.C:
+			if w.check() {
+				return
+			}
+		}
+	}
+}
+
+// check logs the state of all channels and fails the run if no connection received a message for the timeout. It
+// returns true if the run was failed.
+func (w *idleWatchdog) check() bool {
+	var lastReceived time.Time
+	for _, conn := range w.conns {
+		if received := conn.LastMessageReceived(); received.After(lastReceived) {
+			lastReceived = received
+		}
+	}
+	idle := w.now().Sub(lastReceived)
+	if idle < w.timeout {
+		return false
+	}
+	for i, conn := range w.conns {
+		for _, channel := range conn.Channels() {
+			log.WithFields(log.Fields{
+				"connection":     i,
+				"lastMessage":    conn.LastMessageReceived(),
+				"channel_code":   channel.Code,
+				"channel_id":     channel.Name,
+				"pendingReplies": channel.PendingReplies,
+			}).Error("testmanagerd stopped sending messages")
+		}
+	}
+	w.listener.sessionIdle(idle.Round(time.Second))
+	return true
+}
@@ -0,0 +1,91 @@
+package testmanagerd
+
+import (
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	dtx "github.com/danielpaulus/go-ios/ios/dtx_codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeIdleConnection struct {
+	lastReceived time.Time
+}
+
+func (c fakeIdleConnection) LastMessageReceived() time.Time {
+	return c.lastReceived
+}
+
+func (c fakeIdleConnection) Channels() []dtx.ChannelState {
+	return []dtx.ChannelState{{Code: 0, Name: "global_channel"}, {Code: 1, Name: "dtxproxy:XCTestManager_IDEInterface:XCTestManager_DaemonConnectionInterface", PendingReplies: 1}}
+}
+
+func TestIdleWatchdogFailsStalledRun(t *testing.T) {
+	start := time.Date(2024, 1, 16, 15, 0, 0, 0, time.UTC)
+	now := start.Add(20 * time.Second)
+	listener := NewTestListener(io.Discard, io.Discard, t.TempDir())
+	listener.testSuiteDidStart("LoginUITests", "2024-01-16 15:00:00 +0000")
+	listener.testCaseDidStartForClass("LoginUITests", "testLogin")
+	watchdog := &idleWatchdog{
+		timeout:  30 * time.Second,
+		listener: listener,
+		conns:    []idleConnection{fakeIdleConnection{lastReceived: start}, fakeIdleConnection{lastReceived: start.Add(5 * time.Second)}},
+		now:      func() time.Time { return now },
+	}
+
+	assert.False(t, watchdog.check(), "the last message was received 15s ago")
+	now = now.Add(20 * time.Second)
+	assert.True(t, watchdog.check())
+
+	assert.ErrorIs(t, listener.err, ErrTestSessionIdle)
+	assert.EqualError(t, listener.err, "test session idle: no message received from testmanagerd for 35s while LoginUITests/testLogin was running")
+	require.Len(t, listener.TestSuites, 1)
+	assert.Equal(t, StatusStalled, listener.TestSuites[0].TestCases[0].Status)
+	select {
+	case <-listener.Done():
+	default:
+		t.Fatal("the run must be finished")
+	}
+}
+
+func TestIdleWatchdogDisabled(t *testing.T) {
+	stop := startIdleWatchdog(TestConfig{Listener: NewTestListener(io.Discard, io.Discard, t.TempDir())})
+	assert.NotPanics(t, func() {
+		stop()
+		stop()
+	})
+}
+
+func TestIdleWatchdogWhileMessagesAreDispatched(t *testing.T) {
+	listener := NewTestListener(io.Discard, io.Discard, t.TempDir())
+	listener.testSuiteDidStart("LoginUITests", "2024-01-16 15:00:00 +0000")
+	listener.testCaseDidStartForClass("LoginUITests", "testLogin")
+	dispatcher := proxyDispatcher{testListener: listener}
+	// a message with missing arguments makes the dispatcher record a decoder error on the listener
+	invalidMessage := dtx.Message{Payload: []interface{}{"_XCT_testCaseDidStartForTestClass:method:"}}
+	watchdog := &idleWatchdog{
+		timeout:  time.Second,
+		listener: listener,
+		conns:    []idleConnection{fakeIdleConnection{}},
+		now:      time.Now,
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			dispatcher.Dispatch(invalidMessage)
+		}
+	}()
+	assert.True(t, watchdog.check())
+	wg.Wait()
+
+	suites, err := listener.results()
+	require.Len(t, suites, 1)
+	assert.Equal(t, StatusStalled, suites[0].TestCases[0].Status)
+	assert.Error(t, err)
+}
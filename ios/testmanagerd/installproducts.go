@@ -0,0 +1,115 @@
+package testmanagerd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/danielpaulus/go-ios/ios"
+	"github.com/danielpaulus/go-ios/ios/installationproxy"
+	"github.com/danielpaulus/go-ios/ios/zipconduit"
+	log "github.com/sirupsen/logrus"
+)
+
+// testRootToken is the placeholder for the build products directory in the paths of .xctestrun files
+const testRootToken = "__TESTROOT__"
+
+// installedAppListedTimeout is how long installTestProducts waits for installation_proxy to list an installed app
+const installedAppListedTimeout = 30 * time.Second
+
+// WithInstallTestProducts installs the test host, the UI test runner and the UI target app of the .xctestrun file
+// before running the tests, see TestConfig.InstallTestProducts. testRoot is the build products directory that
+// __TESTROOT__ in the paths of the file stands for. If empty, the directory of the .xctestrun file is used, which is
+// where xcodebuild build-for-testing puts it
+func WithInstallTestProducts(testRoot string) XCTestRunOption {
+	return func(config *TestConfig) {
+		config.InstallTestProducts = true
+		config.TestRoot = testRoot
+	}
+}
+
+// installTestProducts installs the apps of the targets of the .xctestrun file that are going to run. All targets
+// are installed if options.RunAllTargets is set, only the first one otherwise.
+func installTestProducts(device ios.DeviceEntry, xctestrunFilePath string, options TestConfig) error {
+	file, err := os.Open(xctestrunFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to open xctestrun file: %w", err)
+	}
+	targets, err := decodeTestTargets(file, options.TestConfigurationName)
+	file.Close()
+	if err != nil {
+		return err
+	}
+	if !options.RunAllTargets {
+		targets = targets[:1]
+	}
+	testRoot := options.TestRoot
+	if testRoot == "" {
+		testRoot = filepath.Dir(xctestrunFilePath)
+	}
+
+	installationProxy, err := installationproxy.New(device)
+	if err != nil {
+		return fmt.Errorf("cannot connect to installation proxy: %w", err)
+	}
+	defer installationProxy.Close()
+	for _, appPath := range testProductApps(targets, testRoot) {
+		if err := installTestProduct(device, installationProxy, appPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func installTestProduct(device ios.DeviceEntry, installationProxy *installationproxy.Connection, appPath string) error {
+	bundleID, err := zipconduit.BundleIDFromApp(appPath)
+	if err != nil {
+		return fmt.Errorf("cannot install %s: %w", appPath, err)
+	}
+	log.WithFields(log.Fields{"app": appPath, "bundleID": bundleID}).Info("installing test product")
+	conn, err := zipconduit.New(device)
+	if err != nil {
+		return fmt.Errorf("cannot connect to zipconduit: %w", err)
+	}
+	err = conn.SendFile(appPath)
+	conn.Close()
+	if err != nil {
+		return fmt.Errorf("failed installing %s: %w", appPath, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), installedAppListedTimeout)
+	defer cancel()
+	if _, err := installationProxy.WaitForAppInstalled(ctx, bundleID); err != nil {
+		return fmt.Errorf("failed installing %s: %w", appPath, err)
+	}
+	return nil
+}
+
+// testProductApps returns the paths of the apps the targets need with __TESTROOT__ replaced by testRoot. These are
+// the test hosts, the UI target apps and the top level apps of DependentProductPaths, each of them once. Frameworks,
+// test bundles and app extensions are part of these apps and not installed on their own.
+func testProductApps(targets []schemeData, testRoot string) []string {
+	var apps []string
+	seen := map[string]bool{}
+	add := func(p string) {
+		if p == "" || strings.Contains(p, "__TESTHOST__") {
+			return
+		}
+		p = filepath.Clean(strings.ReplaceAll(p, testRootToken, testRoot))
+		if !strings.HasSuffix(p, ".app") || strings.Contains(filepath.Dir(p)+string(filepath.Separator), ".app"+string(filepath.Separator)) || seen[p] {
+			return
+		}
+		seen[p] = true
+		apps = append(apps, p)
+	}
+	for _, target := range targets {
+		add(target.TestHostPath)
+		add(target.UITargetAppPath)
+		for _, p := range target.DependentProductPaths {
+			add(p)
+		}
+	}
+	return apps
+}
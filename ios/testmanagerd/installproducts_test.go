@@ -0,0 +1,41 @@
+package testmanagerd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTestProductApps(t *testing.T) {
+	targets := []schemeData{
+		{
+			TestHostPath:    "__TESTROOT__/Debug-iphoneos/LoginUITests-Runner.app",
+			UITargetAppPath: "__TESTROOT__/Debug-iphoneos/Login.app",
+			DependentProductPaths: []string{
+				"__TESTROOT__/Debug-iphoneos/Login.app",
+				"__TESTROOT__/Debug-iphoneos/Login.app/PlugIns/Widget.appex",
+				"__TESTROOT__/Debug-iphoneos/Helper.app",
+				"__TESTROOT__/Debug-iphoneos/LoginUITests-Runner.app/PlugIns/LoginUITests.xctest",
+				"__TESTROOT__/Debug-iphoneos/Login.app/Watch/Companion.app",
+				"__TESTROOT__/Debug-iphoneos/Shared.framework",
+			},
+		},
+		{
+			TestHostPath:   "__TESTROOT__/Debug-iphoneos/Login.app",
+			TestBundlePath: "__TESTHOST__/PlugIns/LoginTests.xctest",
+		},
+	}
+
+	assert.Equal(t, []string{
+		"/build/Debug-iphoneos/LoginUITests-Runner.app",
+		"/build/Debug-iphoneos/Login.app",
+		"/build/Debug-iphoneos/Helper.app",
+	}, testProductApps(targets, "/build"))
+}
+
+func TestWithInstallTestProducts(t *testing.T) {
+	var config TestConfig
+	WithInstallTestProducts("/build")(&config)
+	assert.True(t, config.InstallTestProducts)
+	assert.Equal(t, "/build", config.TestRoot)
+}
@@ -0,0 +1,132 @@
+package testmanagerd
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+
+	ios "github.com/danielpaulus/go-ios/ios"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string           `xml:"name,attr"`
+	Tests      int              `xml:"tests,attr"`
+	Failures   int              `xml:"failures,attr"`
+	Errors     int              `xml:"errors,attr"`
+	Skipped    int              `xml:"skipped,attr"`
+	Time       string           `xml:"time,attr"`
+	Timestamp  string           `xml:"timestamp,attr,omitempty"`
+	Properties *junitProperties `xml:"properties,omitempty"`
+	TestCases  []junitTestCase  `xml:"testcase"`
+}
+
+type junitProperties struct {
+	Properties []junitProperty `xml:"property"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+	Skipped   *struct{}     `xml:"skipped,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnitReport writes the results of a test run as JUnit XML report to w. Failed tests are reported as failures,
+// crashed and stalled tests as errors. The language and locale the device was set to are added to each test suite as
+// the properties AppleLanguages and AppleLocale.
+func WriteJUnitReport(w io.Writer, suites []TestSuite, locale ios.DeviceLocale) error {
+	report := junitTestSuites{}
+	properties := junitLocaleProperties(locale)
+	var total time.Duration
+	for _, suite := range suites {
+		junitSuite := junitTestSuite{Name: suite.Name, Time: junitDuration(suite.TotalDuration), Properties: properties}
+		if !suite.StartDate.IsZero() {
+			junitSuite.Timestamp = suite.StartDate.UTC().Format(time.RFC3339)
+		}
+		for _, testCase := range suite.TestCases {
+			junitCase := junitTestCase{ClassName: testCase.ClassName, Name: testCase.MethodName, Time: junitDuration(testCase.Duration)}
+			switch testCase.Status {
+			case StatusFailed:
+				junitCase.Failure = junitProblemFromError(testCase.Err)
+				junitSuite.Failures++
+			case StatusCrashed, StatusStalled, StatusCancelled:
+				junitCase.Error = junitProblemFromError(testCase.Err)
+				junitCase.Error.Type = string(testCase.Status)
+				junitSuite.Errors++
+			case StatusSkipped:
+				junitCase.Skipped = &struct{}{}
+				junitSuite.Skipped++
+			}
+			junitSuite.TestCases = append(junitSuite.TestCases, junitCase)
+		}
+		junitSuite.Tests = len(junitSuite.TestCases)
+
+		report.Tests += junitSuite.Tests
+		report.Failures += junitSuite.Failures
+		report.Errors += junitSuite.Errors
+		report.Skipped += junitSuite.Skipped
+		total += suite.TotalDuration
+		report.Suites = append(report.Suites, junitSuite)
+	}
+	report.Time = junitDuration(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("WriteJUnitReport: %w", err)
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return fmt.Errorf("WriteJUnitReport: %w", err)
+	}
+	return nil
+}
+
+func junitLocaleProperties(locale ios.DeviceLocale) *junitProperties {
+	var properties []junitProperty
+	if locale.Language != "" {
+		properties = append(properties, junitProperty{Name: "AppleLanguages", Value: locale.Language})
+	}
+	if locale.Locale != "" {
+		properties = append(properties, junitProperty{Name: "AppleLocale", Value: locale.Locale})
+	}
+	if len(properties) == 0 {
+		return nil
+	}
+	return &junitProperties{Properties: properties}
+}
+
+func junitProblemFromError(testErr TestError) *junitProblem {
+	problem := &junitProblem{Message: testErr.Message}
+	if testErr.File != "" {
+		problem.Text = fmt.Sprintf("%s:%d", testErr.File, testErr.Line)
+	}
+	return problem
+}
+
+func junitDuration(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
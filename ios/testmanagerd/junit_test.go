@@ -0,0 +1,66 @@
+package testmanagerd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	ios "github.com/danielpaulus/go-ios/ios"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteJUnitReport(t *testing.T) {
+	suites := []TestSuite{{
+		Name:          "LoginUITests",
+		StartDate:     time.Date(2024, 1, 16, 15, 0, 0, 0, time.UTC),
+		TotalDuration: 4500 * time.Millisecond,
+		TestCases: []TestCase{
+			{ClassName: "LoginUITests", MethodName: "testLogin", Status: StatusPassed, Duration: 1500 * time.Millisecond},
+			{ClassName: "LoginUITests", MethodName: "testLogout", Status: StatusFailed, Duration: time.Second,
+				Err: TestError{Message: "XCTAssertTrue failed", File: "LoginUITests.swift", Line: 42}},
+			{ClassName: "LoginUITests", MethodName: "testSignup", Status: StatusSkipped},
+			{ClassName: "LoginUITests", MethodName: "testReset", Status: StatusCrashed, Err: TestError{Message: "lost connection"}},
+		},
+	}}
+
+	var report bytes.Buffer
+	require.NoError(t, WriteJUnitReport(&report, suites, ios.DeviceLocale{}))
+
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites tests="4" failures="1" errors="1" skipped="1" time="4.500">
+  <testsuite name="LoginUITests" tests="4" failures="1" errors="1" skipped="1" time="4.500" timestamp="2024-01-16T15:00:00Z">
+    <testcase classname="LoginUITests" name="testLogin" time="1.500"></testcase>
+    <testcase classname="LoginUITests" name="testLogout" time="1.000">
+      <failure message="XCTAssertTrue failed">LoginUITests.swift:42</failure>
+    </testcase>
+    <testcase classname="LoginUITests" name="testSignup" time="0.000">
+      <skipped></skipped>
+    </testcase>
+    <testcase classname="LoginUITests" name="testReset" time="0.000">
+      <error message="lost connection" type="crashed"></error>
+    </testcase>
+  </testsuite>
+</testsuites>`, report.String())
+}
+
+func TestWriteJUnitReportDeviceLocale(t *testing.T) {
+	suites := []TestSuite{{
+		Name:      "LoginUITests",
+		TestCases: []TestCase{{ClassName: "LoginUITests", MethodName: "testLogin", Status: StatusPassed}},
+	}}
+
+	var report bytes.Buffer
+	require.NoError(t, WriteJUnitReport(&report, suites, ios.DeviceLocale{Language: "en", Locale: "en_GB", Region: "GB"}))
+
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites tests="1" failures="0" errors="0" skipped="0" time="0.000">
+  <testsuite name="LoginUITests" tests="1" failures="0" errors="0" skipped="0" time="0.000">
+    <properties>
+      <property name="AppleLanguages" value="en"></property>
+      <property name="AppleLocale" value="en_GB"></property>
+    </properties>
+    <testcase classname="LoginUITests" name="testLogin" time="0.000"></testcase>
+  </testsuite>
+</testsuites>`, report.String())
+}
@@ -0,0 +1,23 @@
+package testmanagerd
+
+import "maps"
+
+// WithEnvironment adds env to the environment variables of the test runner. The values replace the ones of the
+// .xctestrun file with the same name, f.ex. to override APP_DISTRIBUTOR_ID_OVERRIDE without editing the file
+func WithEnvironment(env map[string]any) XCTestRunOption {
+	return func(config *TestConfig) {
+		merged := maps.Clone(config.Env)
+		if merged == nil {
+			merged = map[string]any{}
+		}
+		maps.Copy(merged, env)
+		config.Env = merged
+	}
+}
+
+// WithArguments appends args to the launch arguments of the test runner, after the ones of the .xctestrun file
+func WithArguments(args ...string) XCTestRunOption {
+	return func(config *TestConfig) {
+		config.Args = append(append([]string{}, config.Args...), args...)
+	}
+}
@@ -0,0 +1,44 @@
+package testmanagerd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLaunchOverrides(t *testing.T) {
+	parsedEnv := map[string]any{"APP_DISTRIBUTOR_ID_OVERRIDE": "com.apple.AppStore", "KEEP": "1"}
+	parsedArgs := []string{"-FromXCTestRun"}
+	config := TestConfig{Env: parsedEnv, Args: parsedArgs}
+
+	WithEnvironment(map[string]any{"APP_DISTRIBUTOR_ID_OVERRIDE": "com.example.store", "NEW": "2"})(&config)
+	WithArguments("-UITesting", "YES")(&config)
+
+	assert.Equal(t, map[string]any{"APP_DISTRIBUTOR_ID_OVERRIDE": "com.example.store", "KEEP": "1", "NEW": "2"}, config.Env)
+	assert.Equal(t, []string{"-FromXCTestRun", "-UITesting", "YES"}, config.Args)
+	assert.Equal(t, "com.apple.AppStore", parsedEnv["APP_DISTRIBUTOR_ID_OVERRIDE"], "the parsed environment is not modified")
+	assert.Equal(t, []string{"-FromXCTestRun"}, parsedArgs)
+}
+
+func TestWithEnvironmentWithoutParsedEnvironment(t *testing.T) {
+	var config TestConfig
+	WithEnvironment(map[string]any{"KEY": "value"})(&config)
+	assert.Equal(t, map[string]any{"KEY": "value"}, config.Env)
+}
+
+func TestLaunchEnvironmentBeforeIOS17(t *testing.T) {
+	userEnv := map[string]interface{}{"MY_ENV": "value", "NSUnbufferedIO": "NO"}
+
+	for name, env := range map[string]map[string]interface{}{
+		"ios11": testRunnerEnv11("/tmp/config.xctestconfiguration", "session-id", "/bundle.xctest", userEnv),
+		"ios14": testRunnerEnv12("/tmp/config.xctestconfiguration", "session-id", "/bundle.xctest", userEnv),
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, "value", env["MY_ENV"])
+			assert.Equal(t, "NO", env["NSUnbufferedIO"], "the configured environment overrides the defaults")
+			assert.Equal(t, "session-id", env["XCTestSessionIdentifier"])
+			assert.Equal(t, "/bundle.xctest", env["XCTestBundlePath"])
+			assert.Equal(t, "/tmp/config.xctestconfiguration", env["XCTestConfigurationFilePath"])
+		})
+	}
+}
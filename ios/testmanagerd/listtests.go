@@ -0,0 +1,141 @@
+package testmanagerd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"howett.net/plist"
+)
+
+// xcTestCaseSymbol is the imported symbol of XCTestCase, test classes inherit from it
+const xcTestCaseSymbol = "_OBJC_CLASS_$_XCTestCase"
+
+// asyncTestSuffix is appended to the objc selectors of async Swift test methods, which take a completion handler
+const asyncTestSuffix = "WithCompletionHandler:"
+
+// TestClass is a test class of a test bundle with the names of its test methods. Swift classes are named
+// {PRODUCT_MODULE_NAME}.{CLASS}
+type TestClass struct {
+	Name    string
+	Methods []string
+}
+
+// ListTests enumerates the tests of the .xctest bundle at testBundlePath without running them. The tests are read
+// from the objc metadata of the bundle executable the same way XCTest discovers them: instance methods of
+// XCTestCase subclasses whose name starts with "test" that take no arguments and return void, and async Swift test
+// methods. Inherited test methods are listed for each subclass. Classes inheriting from XCTestCase through a class of
+// another image, f.ex. a test case class of a framework, can not be recognized and are missing.
+// Classes and methods are sorted by name like XCTest runs them.
+func ListTests(testBundlePath string) ([]TestClass, error) {
+	executable, err := bundleExecutable(testBundlePath)
+	if err != nil {
+		return nil, fmt.Errorf("ListTests: %w", err)
+	}
+	data, err := os.ReadFile(executable)
+	if err != nil {
+		return nil, fmt.Errorf("ListTests: cannot read test bundle executable: %w", err)
+	}
+	image, err := newObjcImage(data)
+	if err != nil {
+		return nil, fmt.Errorf("ListTests: cannot parse %s: %w", executable, err)
+	}
+	classes, err := image.classes()
+	if err != nil {
+		return nil, fmt.Errorf("ListTests: cannot read objc metadata of %s: %w", executable, err)
+	}
+	return testClasses(classes), nil
+}
+
+// TestIdentifiersOf returns the identifiers of all test methods of classes in the form {CLASS}/{METHOD}, which can
+// be used for TestConfig.TestsToRun, TestIdentifiersForClasses and NotExecutedTests
+func TestIdentifiersOf(classes []TestClass) []string {
+	var identifiers []string
+	for _, class := range classes {
+		for _, method := range class.Methods {
+			identifiers = append(identifiers, class.Name+"/"+method)
+		}
+	}
+	return identifiers
+}
+
+// testBundlePath resolves the TestBundlePath of the target with __TESTROOT__ replaced by testRoot
+func (data schemeData) testBundlePath(testRoot string) string {
+	testHost := strings.ReplaceAll(data.TestHostPath, testRootToken, testRoot)
+	bundlePath := strings.ReplaceAll(data.TestBundlePath, "__TESTHOST__", testHost)
+	return filepath.Clean(strings.ReplaceAll(bundlePath, testRootToken, testRoot))
+}
+
+// bundleExecutable returns the path of the executable of the bundle, named by CFBundleExecutable of its Info.plist
+// or after the bundle itself if there is none
+func bundleExecutable(bundlePath string) (string, error) {
+	name := strings.TrimSuffix(filepath.Base(bundlePath), filepath.Ext(bundlePath))
+	infoPlist, err := os.ReadFile(filepath.Join(bundlePath, "Info.plist"))
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("cannot read Info.plist: %w", err)
+	}
+	if err == nil {
+		var info struct {
+			CFBundleExecutable string
+		}
+		if _, err := plist.Unmarshal(infoPlist, &info); err != nil {
+			return "", fmt.Errorf("cannot parse Info.plist: %w", err)
+		}
+		if info.CFBundleExecutable != "" {
+			name = info.CFBundleExecutable
+		}
+	}
+	return filepath.Join(bundlePath, name), nil
+}
+
+// testClasses picks the test classes with their own and inherited test methods
+func testClasses(classes map[uint64]objcClass) []TestClass {
+	var result []TestClass
+	for _, class := range classes {
+		var methods []string
+		isTestCase := false
+		current := class
+		for depth := 0; depth < 64; depth++ {
+			for _, method := range current.methods {
+				if name, ok := testMethodName(method); ok && !slices.Contains(methods, name) {
+					methods = append(methods, name)
+				}
+			}
+			superclass, ok := classes[current.superclass]
+			if current.superclass == 0 || !ok {
+				// superclasses of other images can not be followed, the class is a test case if it reaches XCTestCase
+				isTestCase = current.superclassSymbol == xcTestCaseSymbol
+				break
+			}
+			current = superclass
+		}
+		if !isTestCase || len(methods) == 0 {
+			continue
+		}
+		sort.Strings(methods)
+		result = append(result, TestClass{Name: demangleClassName(class.name), Methods: methods})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// testMethodName returns the test name of instance methods XCTest runs as tests. Their name starts with "test",
+// they take no arguments and return void. The objc selectors of async Swift tests have the additional suffix
+// "WithCompletionHandler:" and take the completion handler block, XCTest names them without the suffix.
+func testMethodName(method objcMethod) (string, bool) {
+	if !strings.HasPrefix(method.name, "test") || !strings.HasPrefix(method.types, "v") {
+		return "", false
+	}
+	if name, ok := strings.CutSuffix(method.name, asyncTestSuffix); ok && !strings.Contains(name, ":") && strings.HasSuffix(method.types, "@?16") {
+		return name, true
+	}
+	if strings.Contains(method.name, ":") {
+		return "", false
+	}
+	return method.name, true
+}
@@ -0,0 +1,261 @@
+package testmanagerd
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testImageBase              = 0x100000000
+	testDataOffset             = 0x1000
+	testImportTestCase         = 0
+	testImportNSObject         = 1
+	testImportUIViewController = 2
+)
+
+// testImports are the symbols imported by testImage, indexed by their ordinal
+var testImports = []string{"_OBJC_CLASS_$_XCTestCase", "_OBJC_CLASS_$_NSObject", "_OBJC_CLASS_$_UIViewController"}
+
+// testImage builds a minimal arm64 Mach-O bundle whose __DATA segment contains objc metadata. Its pointers are
+// encoded as chained fixups in the DYLD_CHAINED_PTR_64_OFFSET format, XCTestCase and NSObject are imported.
+type testImage struct {
+	data []byte
+}
+
+func (b *testImage) addr() uint64 {
+	return testImageBase + testDataOffset + uint64(len(b.data))
+}
+
+func (b *testImage) put(content []byte) uint64 {
+	addr := b.addr()
+	b.data = append(b.data, content...)
+	for len(b.data)%8 != 0 {
+		b.data = append(b.data, 0)
+	}
+	return addr
+}
+
+func (b *testImage) putString(s string) uint64 {
+	return b.put(append([]byte(s), 0))
+}
+
+func (b *testImage) rebase(target uint64) uint64 {
+	return target - testImageBase
+}
+
+func bindTo(ordinal uint64) uint64 {
+	return 1<<63 | ordinal
+}
+
+func (b *testImage) putPointers(values ...uint64) uint64 {
+	content := make([]byte, 8*len(values))
+	for i, v := range values {
+		binary.LittleEndian.PutUint64(content[8*i:], v)
+	}
+	return b.put(content)
+}
+
+// putClass adds a class_t with its class_ro_t. methods is the address of a method list or 0.
+func (b *testImage) putClass(name string, superclass uint64, methods uint64, swift bool) uint64 {
+	nameAddr := b.putString(name)
+	ro := make([]byte, 72)
+	binary.LittleEndian.PutUint64(ro[24:], b.rebase(nameAddr))
+	if methods != 0 {
+		binary.LittleEndian.PutUint64(ro[32:], b.rebase(methods))
+	}
+	roAddr := b.put(ro)
+	if swift {
+		roAddr |= 1
+	}
+	return b.putPointers(0, superclass, 0, 0, b.rebase(roAddr))
+}
+
+// putSmallMethodList adds a relative method list whose names point to selector references
+func (b *testImage) putSmallMethodList(methods ...objcMethod) uint64 {
+	var selrefs, types []uint64
+	for _, method := range methods {
+		selrefs = append(selrefs, b.putPointers(b.rebase(b.putString(method.name))))
+		types = append(types, b.putString(method.types))
+	}
+	list := make([]byte, 8+12*len(methods))
+	binary.LittleEndian.PutUint32(list, methodListSmallFlag|12)
+	binary.LittleEndian.PutUint32(list[4:], uint32(len(methods)))
+	listAddr := b.addr()
+	for i := range methods {
+		entry := listAddr + 8 + 12*uint64(i)
+		binary.LittleEndian.PutUint32(list[8+12*i:], uint32(int32(int64(selrefs[i])-int64(entry))))
+		binary.LittleEndian.PutUint32(list[12+12*i:], uint32(int32(int64(types[i])-int64(entry+4))))
+	}
+	return b.put(list)
+}
+
+// putMethodList adds a method list with absolute pointers
+func (b *testImage) putMethodList(methods ...objcMethod) uint64 {
+	var entries []uint64
+	for _, method := range methods {
+		entries = append(entries, b.rebase(b.putString(method.name)), b.rebase(b.putString(method.types)), 0)
+	}
+	header := make([]byte, 8)
+	binary.LittleEndian.PutUint32(header, 24)
+	binary.LittleEndian.PutUint32(header[4:], uint32(len(methods)))
+	addr := b.put(header)
+	b.putPointers(entries...)
+	return addr
+}
+
+// macho returns the Mach-O file with classList as __objc_classlist section
+func (b *testImage) macho(classList []uint64) []byte {
+	for len(b.data)%8 != 0 {
+		b.data = append(b.data, 0)
+	}
+	classListAddr := b.putPointers()
+	for _, class := range classList {
+		b.putPointers(b.rebase(class))
+	}
+	data := b.data
+
+	symbolsOffset := 64 + 4*len(testImports)
+	fixups := make([]byte, symbolsOffset)
+	binary.LittleEndian.PutUint32(fixups[4:], 28)                     // starts_offset
+	binary.LittleEndian.PutUint32(fixups[8:], 64)                     // imports_offset
+	binary.LittleEndian.PutUint32(fixups[12:], uint32(symbolsOffset)) // symbols_offset
+	binary.LittleEndian.PutUint32(fixups[16:], uint32(len(testImports)))
+	binary.LittleEndian.PutUint32(fixups[20:], 1)                  // DYLD_CHAINED_IMPORT
+	binary.LittleEndian.PutUint32(fixups[28:], 2)                  // seg_count
+	binary.LittleEndian.PutUint32(fixups[36:], 12)                 // seg_info_offset of __DATA
+	binary.LittleEndian.PutUint16(fixups[46:], chainedPtr64Offset) // pointer_format
+	symbols := []byte{0}
+	for i, name := range testImports {
+		binary.LittleEndian.PutUint32(fixups[64+4*i:], uint32(len(symbols))<<9)
+		symbols = append(symbols, name...)
+		symbols = append(symbols, 0)
+	}
+	fixups = append(fixups, symbols...)
+	fixupsOffset := testDataOffset + len(data)
+
+	le := binary.LittleEndian
+	segment := func(name string, addr, size, offset uint64, sections int) []byte {
+		cmd := make([]byte, 72)
+		le.PutUint32(cmd, 0x19)
+		le.PutUint32(cmd[4:], uint32(72+80*sections))
+		copy(cmd[8:], name)
+		le.PutUint64(cmd[24:], addr)
+		le.PutUint64(cmd[32:], size)
+		le.PutUint64(cmd[40:], offset)
+		le.PutUint64(cmd[48:], size)
+		le.PutUint32(cmd[64:], uint32(sections))
+		return cmd
+	}
+	var commands []byte
+	commands = append(commands, segment("__TEXT", testImageBase, testDataOffset, 0, 0)...)
+	commands = append(commands, segment("__DATA", testImageBase+testDataOffset, uint64(len(data)), testDataOffset, 1)...)
+	section := make([]byte, 80)
+	copy(section, "__objc_classlist")
+	copy(section[16:], "__DATA")
+	le.PutUint64(section[32:], classListAddr)
+	le.PutUint64(section[40:], uint64(8*len(classList)))
+	le.PutUint32(section[48:], uint32(classListAddr-testImageBase))
+	commands = append(commands, section...)
+	fixupsCommand := make([]byte, 16)
+	le.PutUint32(fixupsCommand, chainedFixupsCommand)
+	le.PutUint32(fixupsCommand[4:], 16)
+	le.PutUint32(fixupsCommand[8:], uint32(fixupsOffset))
+	le.PutUint32(fixupsCommand[12:], uint32(len(fixups)))
+	commands = append(commands, fixupsCommand...)
+
+	header := make([]byte, 32)
+	le.PutUint32(header, 0xfeedfacf)
+	le.PutUint32(header[4:], 0x0100000c) // arm64
+	le.PutUint32(header[12:], 8)         // MH_BUNDLE
+	le.PutUint32(header[16:], 3)
+	le.PutUint32(header[20:], uint32(len(commands)))
+
+	file := make([]byte, testDataOffset)
+	copy(file, append(header, commands...))
+	file = append(file, data...)
+	return append(file, fixups...)
+}
+
+func testBundleBinary() []byte {
+	var b testImage
+	void := "v16@0:8"
+	loginTests := b.putClass("_TtC10MyAppTests10LoginTests", bindTo(testImportTestCase), b.putSmallMethodList(
+		objcMethod{name: "testLogout", types: void},
+		objcMethod{name: "setUp", types: void},
+		objcMethod{name: "testLogin", types: void},
+		objcMethod{name: "testWithUser:", types: "v24@0:8@16"},
+		objcMethod{name: "testUser", types: "@16@0:8"},
+		objcMethod{name: "testRefreshTokenWithCompletionHandler:", types: "v24@0:8@?16"},
+		objcMethod{name: "testWithUser:completionHandler:", types: "v32@0:8@16@?24"},
+	), true)
+	baseTests := b.putClass("BaseTests", bindTo(testImportTestCase), b.putMethodList(
+		objcMethod{name: "testShared", types: void},
+	), false)
+	checkoutTests := b.putClass("CheckoutTests", b.rebase(baseTests), b.putMethodList(
+		objcMethod{name: "testPay", types: void},
+	), false)
+	helper := b.putClass("Helper", bindTo(testImportNSObject), b.putMethodList(
+		objcMethod{name: "testNotATest", types: void},
+	), false)
+	viewController := b.putClass("TestViewController", bindTo(testImportUIViewController), b.putMethodList(
+		objcMethod{name: "testLayout", types: void},
+	), false)
+	subclass := b.putClass("CustomViewController", b.rebase(viewController), b.putMethodList(
+		objcMethod{name: "testTheme", types: void},
+	), false)
+	empty := b.putClass("EmptyTests", bindTo(testImportTestCase), 0, false)
+	return b.macho([]uint64{loginTests, baseTests, checkoutTests, helper, viewController, subclass, empty})
+}
+
+func TestListTests(t *testing.T) {
+	bundle := filepath.Join(t.TempDir(), "MyAppTests.xctest")
+	require.NoError(t, os.Mkdir(bundle, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(bundle, "MyAppTests"), testBundleBinary(), 0o644))
+
+	classes, err := ListTests(bundle)
+	require.NoError(t, err)
+	assert.Equal(t, []TestClass{
+		{Name: "BaseTests", Methods: []string{"testShared"}},
+		{Name: "CheckoutTests", Methods: []string{"testPay", "testShared"}},
+		{Name: "MyAppTests.LoginTests", Methods: []string{"testLogin", "testLogout", "testRefreshToken"}},
+	}, classes, "classes not inheriting from XCTestCase must not be listed")
+	assert.Equal(t, []string{
+		"BaseTests/testShared",
+		"CheckoutTests/testPay",
+		"CheckoutTests/testShared",
+		"MyAppTests.LoginTests/testLogin",
+		"MyAppTests.LoginTests/testLogout",
+		"MyAppTests.LoginTests/testRefreshToken",
+	}, TestIdentifiersOf(classes))
+}
+
+func TestListTestsOfInvalidBundle(t *testing.T) {
+	bundle := filepath.Join(t.TempDir(), "MyAppTests.xctest")
+	require.NoError(t, os.Mkdir(bundle, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(bundle, "MyAppTests"), []byte("not a binary"), 0o644))
+
+	_, err := ListTests(bundle)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "ListTests: cannot parse")
+	}
+}
+
+func TestResolveTestBundlePath(t *testing.T) {
+	target := schemeData{
+		TestHostPath:   "__TESTROOT__/Debug-iphoneos/Runner.app",
+		TestBundlePath: "__TESTHOST__/PlugIns/RunnerTests.xctest",
+	}
+	assert.Equal(t, "/build/Debug-iphoneos/Runner.app/PlugIns/RunnerTests.xctest", target.testBundlePath("/build"))
+}
+
+func TestDemangleClassName(t *testing.T) {
+	assert.Equal(t, "MyAppTests.LoginTests", demangleClassName("_TtC10MyAppTests10LoginTests"))
+	assert.Equal(t, "MyAppTests.Outer.Inner", demangleClassName("_TtCC10MyAppTests5Outer5Inner"))
+	assert.Equal(t, "LoginTests", demangleClassName("LoginTests"))
+	assert.Equal(t, "_TtC3Foo", demangleClassName("_TtC3Foo"))
+}
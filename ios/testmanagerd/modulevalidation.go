@@ -0,0 +1,46 @@
+package testmanagerd
+
+import (
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ModuleValidation controls what happens if TestsToRun or TestsToSkip contain identifiers of another module than the
+// ProductModuleName of the test target, see TestIdentifiersFromOtherModules
+type ModuleValidation int
+
+const (
+	// ModuleValidationWarn logs a warning for each identifier of another module, this is the default
+	ModuleValidationWarn ModuleValidation = iota
+	// ModuleValidationOff disables the validation
+	ModuleValidationOff
+	// ModuleValidationError fails the test run before the runner is started
+	ModuleValidationError
+)
+
+// WithModuleValidation sets how identifiers of other modules are reported, see TestConfig.ModuleValidation
+func WithModuleValidation(validation ModuleValidation) XCTestRunOption {
+	return func(config *TestConfig) {
+		config.ModuleValidation = validation
+	}
+}
+
+// validateTestIdentifierModules checks the test identifiers of config against its ProductModuleName
+func validateTestIdentifierModules(config TestConfig) error {
+	if config.ModuleValidation == ModuleValidationOff {
+		return nil
+	}
+	mismatches := TestIdentifiersFromOtherModules(append(append([]string{}, config.TestsToRun...), config.TestsToSkip...), config.ProductModuleName)
+	if len(mismatches) == 0 {
+		return nil
+	}
+	if config.ModuleValidation == ModuleValidationError {
+		return fmt.Errorf("test identifiers do not belong to module %s and would not run: %s", config.ProductModuleName, strings.Join(mismatches, ", "))
+	}
+	for _, identifier := range mismatches {
+		log.WithFields(log.Fields{"identifier": identifier, "module": config.ProductModuleName}).Warn("test identifier belongs to another module and will not run")
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package testmanagerd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateTestIdentifierModules(t *testing.T) {
+	config := TestConfig{
+		ProductModuleName: "RunnerUITests",
+		TestsToRun:        []string{"RunnerUITests.LoginTests/testLogin", "RunnerTests.ModelTests/testDecoding"},
+	}
+
+	assert.NoError(t, validateTestIdentifierModules(config), "the default only warns")
+
+	WithModuleValidation(ModuleValidationError)(&config)
+	err := validateTestIdentifierModules(config)
+	assert.EqualError(t, err, "test identifiers do not belong to module RunnerUITests and would not run: RunnerTests.ModelTests/testDecoding")
+
+	WithModuleValidation(ModuleValidationOff)(&config)
+	assert.NoError(t, validateTestIdentifierModules(config))
+}
+
+func TestValidateTestIdentifierModulesSkippedTests(t *testing.T) {
+	config := TestConfig{
+		ProductModuleName: "RunnerUITests",
+		TestsToSkip:       []string{"OtherUITests.CartTests"},
+		ModuleValidation:  ModuleValidationError,
+	}
+
+	if err := validateTestIdentifierModules(config); assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "OtherUITests.CartTests")
+	}
+}
@@ -0,0 +1,167 @@
+package testmanagerd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image/jpeg"
+	"os"
+	"time"
+)
+
+// mp4Timescale is the number of time units per second of the movie, durations are stored in milliseconds
+const mp4Timescale = 1000
+
+// mp4Writer writes a MP4 file with a single video track of JPEG frames (Motion JPEG). Every frame is shown until
+// the next one, so frames can be added at irregular intervals like they are delivered by the screenshot service.
+// The frames are written to the file as they are added, the index of the movie is written by close.
+type mp4Writer struct {
+	file          *os.File
+	width, height uint16
+	mdatStart     int64
+	offset        int64
+	sizes         []uint32
+	offsets       []uint32
+	durations     []uint32
+	lastFrame     time.Time
+}
+
+// newMP4Writer creates the file at path and writes the header of the movie
+func newMP4Writer(path string) (*mp4Writer, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	ftyp := mp4Box("ftyp", []byte("isom"), u32(0x200), []byte("isomiso2mp41"))
+	// the size of the media data box is set by close
+	mdatHeader := mp4Box("mdat")
+	if _, err := file.Write(append(ftyp, mdatHeader...)); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &mp4Writer{
+		file:      file,
+		mdatStart: int64(len(ftyp)),
+		offset:    int64(len(ftyp) + len(mdatHeader)),
+	}, nil
+}
+
+// addFrame appends the JPEG image frame shown from time t on. The size of the movie is taken from the first frame.
+func (w *mp4Writer) addFrame(frame []byte, t time.Time) error {
+	if len(w.sizes) == 0 {
+		config, err := jpeg.DecodeConfig(bytes.NewReader(frame))
+		if err != nil {
+			return fmt.Errorf("addFrame: invalid JPEG frame: %w", err)
+		}
+		w.width, w.height = uint16(config.Width), uint16(config.Height)
+	} else {
+		w.durations = append(w.durations, frameDuration(w.lastFrame, t))
+	}
+	if w.offset+int64(len(frame)) > 0xffffffff {
+		return fmt.Errorf("addFrame: movie exceeds 4GB")
+	}
+	if _, err := w.file.Write(frame); err != nil {
+		return err
+	}
+	w.sizes = append(w.sizes, uint32(len(frame)))
+	w.offsets = append(w.offsets, uint32(w.offset))
+	w.offset += int64(len(frame))
+	w.lastFrame = t
+	return nil
+}
+
+// close shows the last frame until end, writes the index of the movie and closes the file
+func (w *mp4Writer) close(end time.Time) error {
+	if len(w.sizes) > 0 {
+		w.durations = append(w.durations, frameDuration(w.lastFrame, end))
+	}
+	if _, err := w.file.WriteAt(u32(uint32(w.offset-w.mdatStart)), w.mdatStart); err != nil {
+		w.file.Close()
+		return err
+	}
+	if _, err := w.file.Write(w.moov()); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// frameDuration returns the time from start to end in milliseconds, at least 1
+func frameDuration(start time.Time, end time.Time) uint32 {
+	return uint32(max(1, end.Sub(start).Milliseconds()))
+}
+
+// moov returns the movie box describing the frames added so far
+func (w *mp4Writer) moov() []byte {
+	var duration uint32
+	for _, d := range w.durations {
+		duration += d
+	}
+	matrix := concat(u32(0x10000), u32(0), u32(0), u32(0), u32(0x10000), u32(0), u32(0), u32(0), u32(0x40000000))
+
+	mvhd := mp4FullBox("mvhd", 0, 0, u32(0), u32(0), u32(mp4Timescale), u32(duration), u32(0x10000), u16(0x100),
+		make([]byte, 10), matrix, make([]byte, 24), u32(2))
+	tkhd := mp4FullBox("tkhd", 0, 3, u32(0), u32(0), u32(1), u32(0), u32(duration), make([]byte, 8), u16(0), u16(0),
+		u16(0), u16(0), matrix, u32(uint32(w.width)<<16), u32(uint32(w.height)<<16))
+	mdhd := mp4FullBox("mdhd", 0, 0, u32(0), u32(0), u32(mp4Timescale), u32(duration), u16(0x55c4), u16(0))
+	hdlr := mp4FullBox("hdlr", 0, 0, u32(0), []byte("vide"), make([]byte, 12), []byte("VideoHandler\x00"))
+	vmhd := mp4FullBox("vmhd", 0, 1, make([]byte, 8))
+	dinf := mp4Box("dinf", mp4FullBox("dref", 0, 0, u32(1), mp4FullBox("url ", 0, 1)))
+
+	compressorName := make([]byte, 32)
+	compressorName[0] = byte(copy(compressorName[1:], "Photo - JPEG"))
+	sampleEntry := mp4Box("jpeg", make([]byte, 6), u16(1), make([]byte, 16), u16(w.width), u16(w.height),
+		u32(0x480000), u32(0x480000), u32(0), u16(1), compressorName, u16(0x18), u16(0xffff))
+	stsd := mp4FullBox("stsd", 0, 0, u32(1), sampleEntry)
+
+	var stts [][]byte
+	entries := 0
+	for i := 0; i < len(w.durations); {
+		j := i
+		for j < len(w.durations) && w.durations[j] == w.durations[i] {
+			j++
+		}
+		stts = append(stts, u32(uint32(j-i)), u32(w.durations[i]))
+		entries++
+		i = j
+	}
+	sizes := make([][]byte, len(w.sizes))
+	offsets := make([][]byte, len(w.offsets))
+	for i := range w.sizes {
+		sizes[i] = u32(w.sizes[i])
+		offsets[i] = u32(w.offsets[i])
+	}
+	stbl := mp4Box("stbl",
+		stsd,
+		mp4FullBox("stts", 0, 0, u32(uint32(entries)), concat(stts...)),
+		// every frame is a chunk of its own
+		mp4FullBox("stsc", 0, 0, u32(1), u32(1), u32(1), u32(1)),
+		mp4FullBox("stsz", 0, 0, u32(0), u32(uint32(len(w.sizes))), concat(sizes...)),
+		mp4FullBox("stco", 0, 0, u32(uint32(len(w.offsets))), concat(offsets...)),
+	)
+	mdia := mp4Box("mdia", mdhd, hdlr, mp4Box("minf", vmhd, dinf, stbl))
+	return mp4Box("moov", mvhd, mp4Box("trak", tkhd, mdia))
+}
+
+// mp4Box returns the box of type boxType with the concatenated payload
+func mp4Box(boxType string, payload ...[]byte) []byte {
+	content := concat(payload...)
+	return concat(u32(uint32(8+len(content))), []byte(boxType), content)
+}
+
+// mp4FullBox returns a box with version and flags
+func mp4FullBox(boxType string, version byte, flags uint32, payload ...[]byte) []byte {
+	return mp4Box(boxType, append([]byte{version, byte(flags >> 16), byte(flags >> 8), byte(flags)}, concat(payload...)...))
+}
+
+func concat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func u32(v uint32) []byte {
+	return binary.BigEndian.AppendUint32(nil, v)
+}
+
+func u16(v uint16) []byte {
+	return binary.BigEndian.AppendUint16(nil, v)
+}
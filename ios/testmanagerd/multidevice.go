@@ -0,0 +1,259 @@
+package testmanagerd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/danielpaulus/go-ios/ios"
+	log "github.com/sirupsen/logrus"
+)
+
+// ShardStrategy selects how RunXCTestOnDevices distributes the tests among the devices
+type ShardStrategy int
+
+const (
+	// ShardRoundRobin assigns the tests to the devices in turn
+	ShardRoundRobin ShardStrategy = iota
+	// ShardByDuration balances the expected run time of the devices based on the durations of a previous run
+	ShardByDuration
+)
+
+// ShardOptions configures how RunXCTestOnDevices splits the tests
+type ShardOptions struct {
+	Strategy ShardStrategy
+	// PreviousResults are the results of an earlier run that ShardByDuration takes the durations of the tests from.
+	// Tests without a previous result are expected to take the average duration
+	PreviousResults []TestSuite
+	// NewListener creates the TestListener of a device. If nil, a listener discarding all logs is used, it stores the
+	// attachments in a temporary directory of the device that is removed after the run
+	NewListener func(device ios.DeviceEntry) *TestListener
+}
+
+// DeviceShardResult contains the tests a single device ran and their results
+type DeviceShardResult struct {
+	UDID       string
+	TestsToRun []string
+	Suites     []TestSuite
+	Err        error
+}
+
+// MultiDeviceResult contains the merged results of all devices and the results of each device
+type MultiDeviceResult struct {
+	// Suites are the results of all devices, test suites with the same name are merged into one
+	Suites  []TestSuite
+	Devices []DeviceShardResult
+}
+
+// RunXCTestOnDevices splits the tests selected by the .xctestrun file and opts among devices and runs the shards
+// concurrently, one test session per device. The devices need to be resolved by the caller, f.ex. with ios.GetDevice
+// and the tunnel information on iOS 17 and later. Sharding requires the tests to be known up front, so either the
+// xctestrun file must contain OnlyTestIdentifiers or they have to be set with an option. A failing device does not
+// stop the others, the errors of all devices are returned together with all results.
+func RunXCTestOnDevices(ctx context.Context, xctestrunFilePath string, devices []ios.DeviceEntry, sharding ShardOptions, opts ...XCTestRunOption) (MultiDeviceResult, error) {
+	if len(devices) == 0 {
+		return MultiDeviceResult{}, errors.New("RunXCTestOnDevices: no devices given")
+	}
+	var options TestConfig
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if isZippedXCTestRun(xctestrunFilePath) {
+		extractedPath, cleanup, err := ExtractXCTestRun(xctestrunFilePath, options.WorkDir)
+		if err != nil {
+			return MultiDeviceResult{}, fmt.Errorf("RunXCTestOnDevices: %w", err)
+		}
+		defer cleanup()
+		xctestrunFilePath = extractedPath
+	}
+	results, err := parseFile(xctestrunFilePath, options.TestConfigurationName)
+	if err != nil {
+		return MultiDeviceResult{}, fmt.Errorf("RunXCTestOnDevices: %w", err)
+	}
+	testConfig, err := testConfigForTarget(results, devices[0], nil, opts...)
+	if err != nil {
+		return MultiDeviceResult{}, fmt.Errorf("RunXCTestOnDevices: %w", err)
+	}
+	if len(testConfig.TestsToRun) == 0 {
+		return MultiDeviceResult{}, errors.New("RunXCTestOnDevices: sharding requires TestsToRun, set OnlyTestIdentifiers in the xctestrun file")
+	}
+
+	var durations map[string]time.Duration
+	if sharding.Strategy == ShardByDuration {
+		durations = TestDurations(sharding.PreviousResults)
+	}
+	shards := ShardTests(testConfig.TestsToRun, len(devices), durations)
+
+	return runShards(ctx, devices, shards, func(ctx context.Context, device ios.DeviceEntry, shard []string) ([]TestSuite, error) {
+		var listener *TestListener
+		if sharding.NewListener != nil {
+			listener = sharding.NewListener(device)
+		} else {
+			var removeAttachments func()
+			var err error
+			listener, removeAttachments, err = newDiscardingListener(device)
+			if err != nil {
+				return nil, err
+			}
+			defer removeAttachments()
+		}
+		withShard := func(config *TestConfig) {
+			config.TestsToRun = shard
+		}
+		return StartXCTestWithConfig(ctx, xctestrunFilePath, device, listener, append(slices.Clone(opts), withShard)...)
+	})
+}
+
+// newDiscardingListener returns a TestListener for device that discards all logs and stores the attachments in a new
+// temporary directory, so that devices running concurrently do not share it. The returned function removes the directory.
+func newDiscardingListener(device ios.DeviceEntry) (*TestListener, func(), error) {
+	attachmentsDir, err := os.MkdirTemp("", "go-ios-attachments-"+device.Properties.SerialNumber+"-")
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot create the attachments directory: %w", err)
+	}
+	removeAttachments := func() {
+		if err := os.RemoveAll(attachmentsDir); err != nil {
+			log.WithError(err).Warn("could not remove the attachments directory")
+		}
+	}
+	return NewTestListener(io.Discard, io.Discard, attachmentsDir), removeAttachments, nil
+}
+
+// runShards runs the shard of each device concurrently with run. Devices with an empty shard are not used.
+func runShards(ctx context.Context, devices []ios.DeviceEntry, shards [][]string, run func(context.Context, ios.DeviceEntry, []string) ([]TestSuite, error)) (MultiDeviceResult, error) {
+	deviceResults := make([]DeviceShardResult, len(devices))
+	var wg sync.WaitGroup
+	for i, device := range devices {
+		deviceResults[i] = DeviceShardResult{UDID: device.Properties.SerialNumber, TestsToRun: shards[i]}
+		if len(shards[i]) == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(result *DeviceShardResult, device ios.DeviceEntry) {
+			defer wg.Done()
+			result.Suites, result.Err = run(ctx, device, result.TestsToRun)
+		}(&deviceResults[i], device)
+	}
+	wg.Wait()
+
+	var allSuites [][]TestSuite
+	var errs []error
+	for _, result := range deviceResults {
+		allSuites = append(allSuites, result.Suites)
+		if result.Err != nil {
+			errs = append(errs, fmt.Errorf("device %s: %w", result.UDID, result.Err))
+		}
+	}
+	return MultiDeviceResult{Suites: MergeTestSuites(allSuites...), Devices: deviceResults}, errors.Join(errs...)
+}
+
+// ShardTests splits tests into count shards. Without durations, the tests are assigned round robin. Otherwise the
+// longest tests are assigned first, each to the shard with the smallest total duration so far. durations are keyed by
+// {CLASS}/{METHOD} as returned by TestDurations, tests without a duration are expected to take the average duration.
+// The tests of each shard keep their original order.
+func ShardTests(tests []string, count int, durations map[string]time.Duration) [][]string {
+	shards := make([][]string, count)
+	if count <= 0 {
+		return shards
+	}
+	if len(durations) == 0 {
+		for i, test := range tests {
+			shards[i%count] = append(shards[i%count], test)
+		}
+		return shards
+	}
+
+	var sum time.Duration
+	for _, d := range durations {
+		sum += d
+	}
+	average := sum / time.Duration(len(durations))
+	expected := make([]time.Duration, len(tests))
+	order := make([]int, len(tests))
+	for i, test := range tests {
+		d, ok := durations[durationKey(test)]
+		if !ok {
+			d = average
+		}
+		expected[i] = d
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return expected[order[a]] > expected[order[b]]
+	})
+
+	totals := make([]time.Duration, count)
+	assigned := make([][]int, count)
+	for _, i := range order {
+		shortest := 0
+		for shard := 1; shard < count; shard++ {
+			if totals[shard] < totals[shortest] {
+				shortest = shard
+			}
+		}
+		totals[shortest] += expected[i]
+		assigned[shortest] = append(assigned[shortest], i)
+	}
+	for shard, indices := range assigned {
+		sort.Ints(indices)
+		for _, i := range indices {
+			shards[shard] = append(shards[shard], tests[i])
+		}
+	}
+	return shards
+}
+
+// TestDurations returns the duration of every test case of suites keyed by {CLASS}/{METHOD}. If a test ran more
+// than once, the longest duration is kept.
+func TestDurations(suites []TestSuite) map[string]time.Duration {
+	durations := map[string]time.Duration{}
+	for _, suite := range suites {
+		for _, testCase := range suite.TestCases {
+			key := stripModuleName(testCase.ClassName) + "/" + strings.TrimSuffix(testCase.MethodName, "()")
+			durations[key] = max(durations[key], testCase.Duration)
+		}
+	}
+	return durations
+}
+
+// durationKey converts a test identifier into the key used by TestDurations
+func durationKey(test string) string {
+	class, method, _ := strings.Cut(NormalizeTestIdentifier(test), "/")
+	return stripModuleName(class) + "/" + method
+}
+
+// MergeTestSuites combines the results of several test runs into one. Suites with the same name are merged, their
+// test cases are concatenated, the durations added up and the dates extended to cover all of them.
+func MergeTestSuites(results ...[]TestSuite) []TestSuite {
+	var merged []TestSuite
+	index := map[string]int{}
+	for _, suites := range results {
+		for _, suite := range suites {
+			i, ok := index[suite.Name]
+			if !ok {
+				index[suite.Name] = len(merged)
+				suite.TestCases = append([]TestCase{}, suite.TestCases...)
+				merged = append(merged, suite)
+				continue
+			}
+			m := &merged[i]
+			m.TestCases = append(m.TestCases, suite.TestCases...)
+			m.TestDuration += suite.TestDuration
+			m.TotalDuration += suite.TotalDuration
+			if suite.StartDate.Before(m.StartDate) {
+				m.StartDate = suite.StartDate
+			}
+			if suite.EndDate.After(m.EndDate) {
+				m.EndDate = suite.EndDate
+			}
+		}
+	}
+	return merged
+}
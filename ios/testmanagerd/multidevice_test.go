@@ -0,0 +1,94 @@
+package testmanagerd
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/danielpaulus/go-ios/ios"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShardTestsRoundRobin(t *testing.T) {
+	shards := ShardTests([]string{"A/test1", "A/test2", "B/test1", "B/test2", "C/test1"}, 2, nil)
+	assert.Equal(t, [][]string{{"A/test1", "B/test1", "C/test1"}, {"A/test2", "B/test2"}}, shards)
+}
+
+func TestShardTestsByDuration(t *testing.T) {
+	durations := TestDurations([]TestSuite{{TestCases: []TestCase{
+		{ClassName: "MyAppUITests.A", MethodName: "testSlow", Duration: 60 * time.Second},
+		{ClassName: "MyAppUITests.A", MethodName: "testFast", Duration: 10 * time.Second},
+		{ClassName: "MyAppUITests.B", MethodName: "testMedium()", Duration: 30 * time.Second},
+		{ClassName: "MyAppUITests.B", MethodName: "testOther", Duration: 20 * time.Second},
+	}}})
+	tests := []string{"MyAppUITests.A/testFast", "MyAppUITests.A/testSlow", "MyAppUITests.B/testMedium", "MyAppUITests.B/testOther", "MyAppUITests.C/testNew"}
+
+	shards := ShardTests(tests, 2, durations)
+
+	// testSlow and testOther take 80s, testFast, testMedium and testNew, which is expected to take the average of 30s, take 70s
+	assert.Equal(t, [][]string{
+		{"MyAppUITests.A/testSlow", "MyAppUITests.B/testOther"},
+		{"MyAppUITests.A/testFast", "MyAppUITests.B/testMedium", "MyAppUITests.C/testNew"},
+	}, shards)
+}
+
+func TestShardTestsMoreDevicesThanTests(t *testing.T) {
+	shards := ShardTests([]string{"A/test1"}, 3, nil)
+	assert.Equal(t, [][]string{{"A/test1"}, nil, nil}, shards)
+}
+
+func TestRunShardsMergesResults(t *testing.T) {
+	devices := []ios.DeviceEntry{
+		{Properties: ios.DeviceProperties{SerialNumber: "device1"}},
+		{Properties: ios.DeviceProperties{SerialNumber: "device2"}},
+		{Properties: ios.DeviceProperties{SerialNumber: "device3"}},
+	}
+	shards := [][]string{{"LoginTests/testLogin"}, {"LoginTests/testLogout"}, nil}
+	start := time.Date(2024, 1, 16, 15, 0, 0, 0, time.UTC)
+
+	var mu sync.Mutex
+	var ran []string
+	result, err := runShards(context.Background(), devices, shards, func(ctx context.Context, device ios.DeviceEntry, shard []string) ([]TestSuite, error) {
+		mu.Lock()
+		ran = append(ran, device.Properties.SerialNumber)
+		mu.Unlock()
+		if device.Properties.SerialNumber == "device2" {
+			return []TestSuite{{Name: "LoginTests", StartDate: start.Add(time.Second), EndDate: start.Add(5 * time.Second), TotalDuration: 4 * time.Second,
+				TestCases: []TestCase{{ClassName: "LoginTests", MethodName: "testLogout", Status: StatusCrashed}}}}, ErrTestSessionCrashed
+		}
+		return []TestSuite{{Name: "LoginTests", StartDate: start, EndDate: start.Add(2 * time.Second), TotalDuration: 2 * time.Second,
+			TestCases: []TestCase{{ClassName: "LoginTests", MethodName: "testLogin", Status: StatusPassed}}}}, nil
+	})
+
+	assert.ErrorIs(t, err, ErrTestSessionCrashed)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "device device2")
+	}
+	assert.ElementsMatch(t, []string{"device1", "device2"}, ran, "devices without tests are not used")
+	require.Len(t, result.Devices, 3)
+	assert.Equal(t, []string{"LoginTests/testLogout"}, result.Devices[1].TestsToRun)
+	assert.True(t, errors.Is(result.Devices[1].Err, ErrTestSessionCrashed))
+	require.Len(t, result.Suites, 1)
+	merged := result.Suites[0]
+	assert.Len(t, merged.TestCases, 2)
+	assert.Equal(t, start, merged.StartDate)
+	assert.Equal(t, start.Add(5*time.Second), merged.EndDate)
+	assert.Equal(t, 6*time.Second, merged.TotalDuration)
+}
+
+func TestDiscardingListenerHasOwnAttachmentsDirectory(t *testing.T) {
+	first, removeFirst, err := newDiscardingListener(ios.DeviceEntry{Properties: ios.DeviceProperties{SerialNumber: "device1"}})
+	require.NoError(t, err)
+	second, removeSecond, err := newDiscardingListener(ios.DeviceEntry{Properties: ios.DeviceProperties{SerialNumber: "device2"}})
+	require.NoError(t, err)
+	defer removeSecond()
+
+	assert.NotEqual(t, first.attachmentsDirectory, second.attachmentsDirectory)
+	assert.DirExists(t, first.attachmentsDirectory)
+	removeFirst()
+	assert.NoDirExists(t, first.attachmentsDirectory)
+	assert.DirExists(t, second.attachmentsDirectory)
+}
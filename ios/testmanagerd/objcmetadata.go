@@ -0,0 +1,376 @@
+package testmanagerd
+
+import (
+	"bytes"
+	"debug/macho"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// chainedFixupsCommand is LC_DYLD_CHAINED_FIXUPS. Binaries for iOS 15 and later encode their pointers as chained
+// fixups instead of plain addresses
+const chainedFixupsCommand = 0x80000034
+
+// pointer formats of chained fixups, see dyld_chained_ptr_format in mach-o/fixup-chains.h
+const (
+	chainedPtrArm64e           = 1
+	chainedPtr64               = 2
+	chainedPtr64Offset         = 6
+	chainedPtrArm64eUserland   = 9
+	chainedPtrArm64eUserland24 = 12
+)
+
+// flags of the method lists of the objc runtime
+const (
+	methodListSmallFlag           = 0x80000000
+	methodListDirectSelectorsFlag = 0x40000000
+	methodListEntsizeMask         = 0x0000fffc
+)
+
+// objcImage reads the objc class metadata of a 64 bit Mach-O image
+type objcImage struct {
+	file  *macho.File
+	data  []byte
+	order binary.ByteOrder
+	// base is the address the image is linked at, offsets of chained fixups are relative to it
+	base uint64
+	// pointerFormat is the format of the chained fixups, 0 if the image uses plain pointers
+	pointerFormat uint16
+	// imports are the symbol names of the chained fixup binds, indexed by their ordinal
+	imports []string
+}
+
+// objcClass is a class of the objc metadata of an image. Its superclass is either a class of the image at
+// superclass or an imported class named superclassSymbol, f.ex. _OBJC_CLASS_$_XCTestCase
+type objcClass struct {
+	name             string
+	superclass       uint64
+	superclassSymbol string
+	methods          []objcMethod
+}
+
+type objcMethod struct {
+	name  string
+	types string
+}
+
+// newObjcImage parses the Mach-O file in data. For universal binaries the arm64 slice is used.
+func newObjcImage(data []byte) (*objcImage, error) {
+	if fat, err := macho.NewFatFile(bytes.NewReader(data)); err == nil {
+		arch := fat.Arches[0]
+		for _, a := range fat.Arches {
+			if a.Cpu == macho.CpuArm64 {
+				arch = a
+				break
+			}
+		}
+		fat.Close()
+		if uint64(arch.Offset)+uint64(arch.Size) > uint64(len(data)) {
+			return nil, errors.New("truncated universal binary")
+		}
+		data = data[arch.Offset : arch.Offset+arch.Size]
+	}
+	file, err := macho.NewFile(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if file.Magic != macho.Magic64 {
+		return nil, errors.New("only 64 bit images are supported")
+	}
+	image := &objcImage{file: file, data: data, order: file.ByteOrder}
+	if text := file.Segment("__TEXT"); text != nil {
+		image.base = text.Addr
+	}
+	for _, load := range file.Loads {
+		raw := load.Raw()
+		if len(raw) >= 16 && image.order.Uint32(raw) == chainedFixupsCommand {
+			if err := image.parseChainedFixups(uint64(image.order.Uint32(raw[8:])), uint64(image.order.Uint32(raw[12:]))); err != nil {
+				return nil, fmt.Errorf("invalid chained fixups: %w", err)
+			}
+		}
+	}
+	return image, nil
+}
+
+// parseChainedFixups reads the pointer format and the imported symbols of the dyld_chained_fixups_header at offset
+func (m *objcImage) parseChainedFixups(offset uint64, size uint64) error {
+	fixups, err := m.slice(offset, size)
+	if err != nil {
+		return err
+	}
+	if len(fixups) < 28 {
+		return errors.New("header too short")
+	}
+	startsOffset := uint64(m.order.Uint32(fixups[4:]))
+	importsOffset := uint64(m.order.Uint32(fixups[8:]))
+	symbolsOffset := uint64(m.order.Uint32(fixups[12:]))
+	importsCount := uint64(m.order.Uint32(fixups[16:]))
+	importsFormat := m.order.Uint32(fixups[20:])
+
+	starts, err := sliceAt(fixups, startsOffset, 4)
+	if err != nil {
+		return err
+	}
+	segmentCount := uint64(m.order.Uint32(starts))
+	for i := uint64(0); i < segmentCount && m.pointerFormat == 0; i++ {
+		segmentOffset, err := sliceAt(fixups, startsOffset+4+4*i, 4)
+		if err != nil {
+			return err
+		}
+		if m.order.Uint32(segmentOffset) == 0 {
+			continue
+		}
+		segmentStarts, err := sliceAt(fixups, startsOffset+uint64(m.order.Uint32(segmentOffset)), 8)
+		if err != nil {
+			return err
+		}
+		m.pointerFormat = m.order.Uint16(segmentStarts[6:])
+	}
+
+	entrySize := map[uint32]uint64{1: 4, 2: 8, 3: 16}[importsFormat]
+	if entrySize == 0 {
+		return fmt.Errorf("unknown imports format %d", importsFormat)
+	}
+	for i := uint64(0); i < importsCount; i++ {
+		entry, err := sliceAt(fixups, importsOffset+i*entrySize, entrySize)
+		if err != nil {
+			return err
+		}
+		nameOffset := uint64(m.order.Uint32(entry) >> 9)
+		if importsFormat == 3 {
+			nameOffset = m.order.Uint64(entry) >> 32
+		}
+		name, err := cStringAt(fixups, symbolsOffset+nameOffset)
+		if err != nil {
+			return err
+		}
+		m.imports = append(m.imports, name)
+	}
+	return nil
+}
+
+// pointer decodes the pointer stored at addr. It returns the address it points to or, for pointers bound to
+// another image, the name of the imported symbol.
+func (m *objcImage) pointer(addr uint64) (uint64, string, error) {
+	raw, err := m.read(addr, 8)
+	if err != nil {
+		return 0, "", err
+	}
+	v := m.order.Uint64(raw)
+	if v == 0 {
+		// null pointers are not part of the fixup chains
+		return 0, "", nil
+	}
+	var bind bool
+	var ordinal, target uint64
+	switch m.pointerFormat {
+	case 0:
+		return v, "", nil
+	case chainedPtr64, chainedPtr64Offset:
+		bind = v>>63 == 1
+		ordinal = v & 0xffffff
+		target = v&0xfffffffff | (v>>36&0xff)<<56
+		if m.pointerFormat == chainedPtr64Offset {
+			target += m.base
+		}
+	case chainedPtrArm64e, chainedPtrArm64eUserland, chainedPtrArm64eUserland24:
+		auth := v>>63 == 1
+		bind = v>>62&1 == 1
+		ordinal = v & 0xffff
+		if m.pointerFormat == chainedPtrArm64eUserland24 {
+			ordinal = v & 0xffffff
+		}
+		switch {
+		case auth:
+			target = v&0xffffffff + m.base
+		case m.pointerFormat == chainedPtrArm64e:
+			target = v&0x7ffffffffff | (v>>43&0xff)<<56
+		default:
+			target = v&0x7ffffffffff | (v>>43&0xff)<<56 + m.base
+		}
+	default:
+		return 0, "", fmt.Errorf("unsupported chained pointer format %d", m.pointerFormat)
+	}
+	if bind {
+		if ordinal >= uint64(len(m.imports)) {
+			return 0, "", fmt.Errorf("invalid import ordinal %d", ordinal)
+		}
+		return 0, m.imports[ordinal], nil
+	}
+	return target, "", nil
+}
+
+// read returns size bytes of the image at the virtual address addr
+func (m *objcImage) read(addr uint64, size uint64) ([]byte, error) {
+	for _, load := range m.file.Loads {
+		segment, ok := load.(*macho.Segment)
+		if !ok || addr < segment.Addr || addr >= segment.Addr+segment.Filesz {
+			continue
+		}
+		if addr+size > segment.Addr+segment.Filesz {
+			return nil, fmt.Errorf("address %#x exceeds segment %s", addr, segment.Name)
+		}
+		return m.slice(segment.Offset+addr-segment.Addr, size)
+	}
+	return nil, fmt.Errorf("address %#x is not mapped", addr)
+}
+
+func (m *objcImage) cString(addr uint64) (string, error) {
+	for _, load := range m.file.Loads {
+		segment, ok := load.(*macho.Segment)
+		if !ok || addr < segment.Addr || addr >= segment.Addr+segment.Filesz {
+			continue
+		}
+		segmentData, err := m.slice(segment.Offset, segment.Filesz)
+		if err != nil {
+			return "", err
+		}
+		return cStringAt(segmentData, addr-segment.Addr)
+	}
+	return "", fmt.Errorf("address %#x is not mapped", addr)
+}
+
+func (m *objcImage) slice(offset uint64, size uint64) ([]byte, error) {
+	return sliceAt(m.data, offset, size)
+}
+
+// classes returns all classes listed in the __objc_classlist section of the image
+func (m *objcImage) classes() (map[uint64]objcClass, error) {
+	classes := map[uint64]objcClass{}
+	classList := m.file.Section("__objc_classlist")
+	if classList == nil {
+		return classes, nil
+	}
+	for entry := classList.Addr; entry+8 <= classList.Addr+classList.Size; entry += 8 {
+		addr, _, err := m.pointer(entry)
+		if err != nil {
+			return nil, err
+		}
+		class, err := m.class(addr)
+		if err != nil {
+			return nil, fmt.Errorf("class at %#x: %w", addr, err)
+		}
+		classes[addr] = class
+	}
+	return classes, nil
+}
+
+// class reads the class_t at addr and its class_ro_t
+func (m *objcImage) class(addr uint64) (objcClass, error) {
+	var class objcClass
+	var err error
+	class.superclass, class.superclassSymbol, err = m.pointer(addr + 8)
+	if err != nil {
+		return class, err
+	}
+	data, _, err := m.pointer(addr + 32)
+	if err != nil {
+		return class, err
+	}
+	// the low bits of the data pointer are flags, f.ex. for Swift classes
+	data &^= 7
+	nameAddr, _, err := m.pointer(data + 24)
+	if err != nil {
+		return class, err
+	}
+	if class.name, err = m.cString(nameAddr); err != nil {
+		return class, err
+	}
+	methodList, _, err := m.pointer(data + 32)
+	if err != nil || methodList == 0 {
+		return class, err
+	}
+	class.methods, err = m.methods(methodList)
+	return class, err
+}
+
+// methods reads the method_list_t at addr. Small method lists use offsets relative to their fields, the name
+// offset points to a selector reference or, with direct selectors, to the name itself.
+func (m *objcImage) methods(addr uint64) ([]objcMethod, error) {
+	header, err := m.read(addr, 8)
+	if err != nil {
+		return nil, err
+	}
+	flags := m.order.Uint32(header)
+	count := uint64(m.order.Uint32(header[4:]))
+	entrySize := uint64(flags & methodListEntsizeMask)
+	var methods []objcMethod
+	for i := uint64(0); i < count; i++ {
+		entry := addr + 8 + i*entrySize
+		var nameAddr, typesAddr uint64
+		if flags&methodListSmallFlag != 0 {
+			offsets, err := m.read(entry, 8)
+			if err != nil {
+				return nil, err
+			}
+			nameAddr = uint64(int64(entry) + int64(int32(m.order.Uint32(offsets))))
+			typesAddr = uint64(int64(entry+4) + int64(int32(m.order.Uint32(offsets[4:]))))
+			if flags&methodListDirectSelectorsFlag == 0 {
+				if nameAddr, _, err = m.pointer(nameAddr); err != nil {
+					return nil, err
+				}
+			}
+		} else {
+			if nameAddr, _, err = m.pointer(entry); err != nil {
+				return nil, err
+			}
+			if typesAddr, _, err = m.pointer(entry + 8); err != nil {
+				return nil, err
+			}
+		}
+		var method objcMethod
+		if method.name, err = m.cString(nameAddr); err != nil {
+			return nil, err
+		}
+		if method.types, err = m.cString(typesAddr); err != nil {
+			return nil, err
+		}
+		methods = append(methods, method)
+	}
+	return methods, nil
+}
+
+func sliceAt(data []byte, offset uint64, size uint64) ([]byte, error) {
+	if offset > uint64(len(data)) || size > uint64(len(data))-offset {
+		return nil, fmt.Errorf("offset %#x out of range", offset)
+	}
+	return data[offset : offset+size], nil
+}
+
+func cStringAt(data []byte, offset uint64) (string, error) {
+	if offset >= uint64(len(data)) {
+		return "", fmt.Errorf("offset %#x out of range", offset)
+	}
+	end := bytes.IndexByte(data[offset:], 0)
+	if end < 0 {
+		return "", fmt.Errorf("unterminated string at %#x", offset)
+	}
+	return string(data[offset : offset+uint64(end)]), nil
+}
+
+// demangleClassName converts the runtime names of Swift classes like _TtC10MyAppTests10LoginTests into
+// MyAppTests.LoginTests. Other names are returned unchanged.
+func demangleClassName(name string) string {
+	rest, ok := strings.CutPrefix(name, "_Tt")
+	if !ok || !strings.HasPrefix(rest, "C") {
+		return name
+	}
+	rest = strings.TrimLeft(rest, "C")
+	var parts []string
+	for rest != "" {
+		digits := len(rest) - len(strings.TrimLeft(rest, "0123456789"))
+		length, err := strconv.Atoi(rest[:digits])
+		if err != nil || digits+length > len(rest) {
+			return name
+		}
+		parts = append(parts, rest[digits:digits+length])
+		rest = rest[digits+length:]
+	}
+	if len(parts) < 2 {
+		return name
+	}
+	return strings.Join(parts, ".")
+}
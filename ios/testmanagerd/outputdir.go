@@ -0,0 +1,104 @@
+package testmanagerd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Layout of the results directory of a test run, see TestConfig.OutputDir
+const (
+	// OutputDirJUnitReport is the JUnit XML report of the test run
+	OutputDirJUnitReport = "junit.xml"
+	// OutputDirAttachments is the directory the attachments of all test cases are stored in
+	OutputDirAttachments = "attachments"
+	// OutputDirRunnerLog contains the log output of the test runner
+	OutputDirRunnerLog = "runner.log"
+	// OutputDirRecordings is the directory the screen recordings of UI tests are stored in as <class>-<method>.mp4
+	OutputDirRecordings = "recordings"
+	// OutputDirTestLogs is the directory the device logs of each test are stored in as <class>-<method>.log, see
+	// TestConfig.TestLogs
+	OutputDirTestLogs = "logs"
+	// OutputDirCrashReports is the directory the crash reports of failed tests are stored in, see TestConfig.CrashReports
+	OutputDirCrashReports = "crashreports"
+)
+
+// WithOutputDir writes the runner log, all attachments, screen recordings and a JUnit report of the test run to dir,
+// see TestConfig.OutputDir
+func WithOutputDir(dir string) XCTestRunOption {
+	return func(config *TestConfig) {
+		config.OutputDir = dir
+	}
+}
+
+// outputDir is a results directory that is populated during a test run
+type outputDir struct {
+	path      string
+	runnerLog *os.File
+	listener  *TestListener
+}
+
+// openOutputDir creates the layout of the results directory at path and redirects the log output and attachments
+// of listener into it. The log output is still written to the writers of the listener as well.
+func openOutputDir(path string, listener *TestListener) (*outputDir, error) {
+	attachmentsDirectory := filepath.Join(path, OutputDirAttachments)
+	if err := os.MkdirAll(attachmentsDirectory, 0o755); err != nil {
+		return nil, fmt.Errorf("openOutputDir: cannot create attachments directory: %w", err)
+	}
+	recordingsDirectory := filepath.Join(path, OutputDirRecordings)
+	if err := os.MkdirAll(recordingsDirectory, 0o755); err != nil {
+		return nil, fmt.Errorf("openOutputDir: cannot create recordings directory: %w", err)
+	}
+	runnerLog, err := os.Create(filepath.Join(path, OutputDirRunnerLog))
+	if err != nil {
+		return nil, fmt.Errorf("openOutputDir: cannot create runner log: %w", err)
+	}
+	listener.attachmentsDirectory = attachmentsDirectory
+	listener.screenRecordingsDirectory = recordingsDirectory
+	listener.logWriter = teeWriter(listener.logWriter, runnerLog)
+	listener.debugLogWriter = teeWriter(listener.debugLogWriter, runnerLog)
+	return &outputDir{path: path, runnerLog: runnerLog, listener: listener}, nil
+}
+
+// close writes the JUnit report for suites with the locale of the device and closes the runner log
+func (o *outputDir) close(suites []TestSuite) error {
+	report, err := os.Create(filepath.Join(o.path, OutputDirJUnitReport))
+	if err != nil {
+		return errors.Join(fmt.Errorf("outputDir: cannot create JUnit report: %w", err), o.runnerLog.Close())
+	}
+	defer report.Close()
+	return errors.Join(WriteJUnitReport(report, suites, o.listener.DeviceLocale), o.runnerLog.Close())
+}
+
+func teeWriter(w io.Writer, file *os.File) io.Writer {
+	if w == nil {
+		return file
+	}
+	return io.MultiWriter(w, file)
+}
+
+// runTestWithOutputDir runs the tests of testConfig and stores the results in testConfig.OutputDir
+func runTestWithOutputDir(ctx context.Context, testConfig TestConfig) ([]TestSuite, error) {
+	if testConfig.Listener == nil {
+		testConfig.Listener = NewTestListener(io.Discard, io.Discard, "")
+	}
+	dir, err := openOutputDir(testConfig.OutputDir, testConfig.Listener)
+	if err != nil {
+		return make([]TestSuite, 0), fmt.Errorf("RunTestWithConfig: %w", err)
+	}
+	if testConfig.TestLogs && testConfig.TestLogDir == "" {
+		testConfig.TestLogDir = filepath.Join(testConfig.OutputDir, OutputDirTestLogs)
+	}
+	if testConfig.CrashReports && testConfig.CrashReportDir == "" {
+		testConfig.CrashReportDir = filepath.Join(testConfig.OutputDir, OutputDirCrashReports)
+	}
+	testConfig.OutputDir = ""
+	suites, err := RunTestWithConfig(ctx, testConfig)
+	if closeErr := dir.close(suites); closeErr != nil {
+		return suites, errors.Join(err, fmt.Errorf("RunTestWithConfig: cannot write results to output directory: %w", closeErr))
+	}
+	return suites, err
+}
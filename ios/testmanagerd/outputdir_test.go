@@ -0,0 +1,95 @@
+package testmanagerd
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	ios "github.com/danielpaulus/go-ios/ios"
+	"github.com/danielpaulus/go-ios/ios/nskeyedarchiver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutputDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "results")
+	var consoleLog bytes.Buffer
+	listener := NewTestListener(&consoleLog, &consoleLog, os.TempDir())
+	listener.DeviceLocale = ios.DeviceLocale{Language: "de", Locale: "de_DE", Region: "DE"}
+
+	output, err := openOutputDir(dir, listener)
+	assert.NoError(t, err)
+
+	listener.LogMessage("runner started\n")
+	listener.testSuiteDidStart("LoginTests", "2024-01-16 15:36:43 +0000")
+	listener.testCaseDidStartForClass("LoginTests", "testLogin")
+	listener.testCaseFailedForClass("LoginTests", "testLogin", "login button missing", "LoginTests.swift", 12)
+	listener.testCaseFinished("LoginTests", "testLogin", nskeyedarchiver.XCActivityRecord{
+		Title:       "Automatic Screenshot",
+		Attachments: []nskeyedarchiver.XCTAttachment{{Name: "Screenshot", UniformTypeIdentifier: "public.png", Payload: []byte("png")}},
+	})
+	listener.testCaseDidFinishForTest("LoginTests", "testLogin", "failed", 1.5)
+	listener.testSuiteFinished("LoginTests", "2024-01-16 15:36:45 +0000", 1, 1, 0, 0, 1, 0, 1.5, 2)
+	listener.didFinishExecutingTestPlan()
+
+	assert.NoError(t, output.close(listener.TestSuites))
+
+	runnerLog, err := os.ReadFile(filepath.Join(dir, OutputDirRunnerLog))
+	assert.NoError(t, err)
+	assert.Equal(t, "runner started\n", string(runnerLog))
+	assert.Equal(t, "runner started\n", consoleLog.String(), "the listener writers should still receive the log")
+
+	attachments, err := os.ReadDir(filepath.Join(dir, OutputDirAttachments))
+	assert.NoError(t, err)
+	assert.Len(t, attachments, 1)
+	assert.Equal(t, filepath.Join(dir, OutputDirAttachments, attachments[0].Name()), listener.TestSuites[0].TestCases[0].Attachments[0].Path)
+
+	report, err := os.ReadFile(filepath.Join(dir, OutputDirJUnitReport))
+	assert.NoError(t, err)
+	assert.Contains(t, string(report), `<testsuites tests="1" failures="1" errors="0" skipped="0" time="2.000">`)
+	assert.Contains(t, string(report), `<testcase classname="LoginTests" name="testLogin" time="1.500">`)
+	assert.Contains(t, string(report), `<failure message="login button missing">LoginTests.swift:12</failure>`)
+	assert.Contains(t, string(report), `<property name="AppleLanguages" value="de"></property>`)
+	assert.Contains(t, string(report), `<property name="AppleLocale" value="de_DE"></property>`)
+}
+
+func TestOutputDirScreenRecordings(t *testing.T) {
+	dir := t.TempDir()
+	listener := NewTestListener(io.Discard, io.Discard, os.TempDir())
+	output, err := openOutputDir(dir, listener)
+	assert.NoError(t, err)
+
+	listener.testSuiteDidStart("LoginTests", "2024-01-16 15:36:43 +0000")
+	listener.testCaseDidStartForClass("LoginTests", "testLogin")
+	listener.testCaseFinished("LoginTests", "testLogin", nskeyedarchiver.XCActivityRecord{
+		Title: "Screen Recording",
+		Attachments: []nskeyedarchiver.XCTAttachment{
+			{Name: "Screen Recording", UniformTypeIdentifier: "public.mpeg-4", Payload: []byte("mp4 video")},
+			{Name: "Screen Recording", UniformTypeIdentifier: "com.apple.quicktime-movie", Payload: []byte("mov video")},
+			{Name: "Screenshot", UniformTypeIdentifier: "public.png", Payload: []byte("png")},
+		},
+	})
+	listener.testCaseFinished("LoginTests", "testLogin", nskeyedarchiver.XCActivityRecord{
+		Attachments: []nskeyedarchiver.XCTAttachment{{Name: "Screen Recording", UniformTypeIdentifier: "public.mpeg-4", Payload: []byte("second video")}},
+	})
+	listener.testSuiteFinished("LoginTests", "2024-01-16 15:36:45 +0000", 1, 0, 0, 0, 0, 0, 1, 2)
+	assert.NoError(t, output.close(listener.TestSuites))
+
+	recordingsDir := filepath.Join(dir, OutputDirRecordings)
+	for file, content := range map[string]string{
+		"LoginTests-testLogin.mp4":   "mp4 video",
+		"LoginTests-testLogin.mov":   "mov video",
+		"LoginTests-testLogin-2.mp4": "second video",
+	} {
+		written, err := os.ReadFile(filepath.Join(recordingsDir, file))
+		assert.NoError(t, err)
+		assert.Equal(t, content, string(written))
+	}
+	recordings := ScreenRecordings(listener.TestSuites)
+	assert.Len(t, recordings, 3)
+	assert.Equal(t, filepath.Join(recordingsDir, "LoginTests-testLogin.mp4"), recordings[0].Path)
+	screenshots, err := os.ReadDir(filepath.Join(dir, OutputDirAttachments))
+	assert.NoError(t, err)
+	assert.Len(t, screenshots, 1, "only the screenshot should be stored with the other attachments")
+}
@@ -0,0 +1,195 @@
+package testmanagerd
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/danielpaulus/go-ios/ios/installationproxy"
+	"github.com/danielpaulus/go-ios/ios/instruments"
+)
+
+// peakMemorySamplingInterval is the sysmontap sampling rate used for peak memory, the same rate Xcode uses
+const peakMemorySamplingInterval = 10
+
+// WithPeakMemory records the highest memory footprint of the test runner and of the app under test while each test
+// case runs in TestCase.PeakMemory, see TestConfig.PeakMemory
+func WithPeakMemory() XCTestRunOption {
+	return func(config *TestConfig) {
+		config.PeakMemory = true
+	}
+}
+
+// WithPeakCPU records the highest CPU usage of the test runner and of the app under test while each test case runs
+// in TestCase.PeakCPU, see TestConfig.PeakCPU
+func WithPeakCPU() XCTestRunOption {
+	return func(config *TestConfig) {
+		config.PeakCPU = true
+	}
+}
+
+// PeakMemory is the highest memory footprint in bytes observed while a test case ran. Values are 0 if no sample of
+// the process was taken during the test, sysmontap samples about once per second.
+type PeakMemory struct {
+	Runner uint64
+	App    uint64
+}
+
+// PeakCPU is the highest CPU usage in percent observed while a test case ran, 100 is one fully used core. Values are
+// 0 if no sample of the process was taken during the test.
+type PeakCPU struct {
+	Runner float64
+	App    float64
+}
+
+// processSample is the memory footprint and the CPU usage of the runner and the app when a sysmontap sample was
+// received
+type processSample struct {
+	time      time.Time
+	runner    uint64
+	app       uint64
+	runnerCPU float64
+	appCPU    float64
+}
+
+// processSampler collects the samples of a test run and assigns the peaks of each test case window to it
+type processSampler struct {
+	mu         sync.Mutex
+	samples    []processSample
+	testStarts map[string]time.Time
+	now        func() time.Time
+}
+
+func newProcessSampler() *processSampler {
+	return &processSampler{testStarts: map[string]time.Time{}, now: time.Now}
+}
+
+// startProcessSampling samples the memory footprint and the CPU usage of the test runner and the app under test of
+// config with sysmontap and lets the listener of config record the peaks of each test case. The returned function
+// stops sampling.
+func startProcessSampling(config TestConfig) (func(), error) {
+	installationProxy, err := installationproxy.New(config.Device)
+	if err != nil {
+		return nil, err
+	}
+	apps, err := installationProxy.BrowseUserApps()
+	installationProxy.Close()
+	if err != nil {
+		return nil, err
+	}
+	runner, err := getappInfo(config.TestRunnerBundleId, apps)
+	if err != nil {
+		return nil, err
+	}
+	var app appInfo
+	if config.BundleId != "" {
+		app, err = getappInfo(config.BundleId, apps)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sysmon, err := instruments.NewSysmontapService(config.Device, peakMemorySamplingInterval)
+	if err != nil {
+		return nil, fmt.Errorf("cannot start sysmontap: %w", err)
+	}
+	sampler := newProcessSampler()
+	config.Listener.processSampler = sampler
+	stats := sysmon.ReceiveProcessStats()
+	go func() {
+		for processes := range stats {
+			sampler.add(processSampleOf(processes, runner.executable, app.executable))
+		}
+	}()
+	return func() {
+		sysmon.Close()
+	}, nil
+}
+
+// processSampleOf picks the footprint and the CPU usage of the runner and the app from the processes of a sysmontap
+// sample by their executable names
+func processSampleOf(processes []instruments.ProcessStats, runnerExecutable string, appExecutable string) processSample {
+	var sample processSample
+	for _, process := range processes {
+		switch process.Name {
+		case "":
+		case runnerExecutable:
+			sample.runner = max(sample.runner, process.PhysFootprint)
+			sample.runnerCPU = max(sample.runnerCPU, cpuUsageOf(process))
+		case appExecutable:
+			sample.app = max(sample.app, process.PhysFootprint)
+			sample.appCPU = max(sample.appCPU, cpuUsageOf(process))
+		}
+	}
+	return sample
+}
+
+// cpuUsageOf returns the cpuUsage attribute of process, which sysmontap reports as float or as integer
+func cpuUsageOf(process instruments.ProcessStats) float64 {
+	switch usage := process.Attributes["cpuUsage"].(type) {
+	case float64:
+		return usage
+	case float32:
+		return float64(usage)
+	case uint64:
+		return float64(usage)
+	case int64:
+		return float64(usage)
+	}
+	return 0
+}
+
+func (s *processSampler) add(sample processSample) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sample.time = s.now()
+	s.samples = append(s.samples, sample)
+}
+
+func (s *processSampler) testStarted(className string, methodName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.testStarts[className+"/"+methodName] = s.now()
+}
+
+// testFinished sets the peak memory and CPU usage of the samples since testCase started and drops the samples that
+// are older
+func (s *processSampler) testFinished(testCase *TestCase) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := testCase.ClassName + "/" + testCase.MethodName
+	start, ok := s.testStarts[key]
+	if !ok {
+		return
+	}
+	delete(s.testStarts, key)
+	testCase.PeakMemory, testCase.PeakCPU = peakInWindow(s.samples, start, s.now())
+
+	earliest := start
+	for _, running := range s.testStarts {
+		if running.Before(earliest) {
+			earliest = running
+		}
+	}
+	i := 0
+	for i < len(s.samples) && s.samples[i].time.Before(earliest) {
+		i++
+	}
+	s.samples = s.samples[i:]
+}
+
+// peakInWindow returns the highest footprints and CPU usages of the samples taken between start and end
+func peakInWindow(samples []processSample, start time.Time, end time.Time) (PeakMemory, PeakCPU) {
+	var peak PeakMemory
+	var peakCPU PeakCPU
+	for _, sample := range samples {
+		if sample.time.Before(start) || sample.time.After(end) {
+			continue
+		}
+		peak.Runner = max(peak.Runner, sample.runner)
+		peak.App = max(peak.App, sample.app)
+		peakCPU.Runner = max(peakCPU.Runner, sample.runnerCPU)
+		peakCPU.App = max(peakCPU.App, sample.appCPU)
+	}
+	return peak, peakCPU
+}
@@ -0,0 +1,75 @@
+package testmanagerd
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/danielpaulus/go-ios/ios/instruments"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPeakInWindow(t *testing.T) {
+	start := time.Date(2024, 1, 16, 15, 0, 0, 0, time.UTC)
+	at := func(seconds int) time.Time { return start.Add(time.Duration(seconds) * time.Second) }
+	samples := []processSample{
+		{time: at(-1), runner: 900, app: 9000, runnerCPU: 90, appCPU: 190},
+		{time: at(0), runner: 100, app: 1000, runnerCPU: 10, appCPU: 50},
+		{time: at(1), runner: 300, app: 1500, runnerCPU: 30, appCPU: 120.5},
+		{time: at(2), runner: 200, app: 4000, runnerCPU: 20, appCPU: 80},
+		{time: at(3), runner: 800, app: 8000, runnerCPU: 5, appCPU: 1},
+	}
+
+	memory, cpu := peakInWindow(samples, at(0), at(2))
+	assert.Equal(t, PeakMemory{Runner: 300, App: 4000}, memory)
+	assert.Equal(t, PeakCPU{Runner: 30, App: 120.5}, cpu)
+	memory, cpu = peakInWindow(samples, at(3), at(4))
+	assert.Equal(t, PeakMemory{Runner: 800, App: 8000}, memory)
+	assert.Equal(t, PeakCPU{Runner: 5, App: 1}, cpu)
+	memory, cpu = peakInWindow(samples, at(5), at(6))
+	assert.Equal(t, PeakMemory{}, memory, "no sample during the test")
+	assert.Equal(t, PeakCPU{}, cpu, "no sample during the test")
+}
+
+func TestMemorySampleOf(t *testing.T) {
+	processes := []instruments.ProcessStats{
+		{Pid: 1, Name: "SpringBoard", PhysFootprint: 500, Attributes: map[string]interface{}{"cpuUsage": 3.0}},
+		{Pid: 2, Name: "MyAppUITests-Runner", PhysFootprint: 100, Attributes: map[string]interface{}{"cpuUsage": 12.5}},
+		{Pid: 3, Name: "MyApp", PhysFootprint: 200, Attributes: map[string]interface{}{"cpuUsage": uint64(80)}},
+	}
+
+	assert.Equal(t, processSample{runner: 100, app: 200, runnerCPU: 12.5, appCPU: 80}, processSampleOf(processes, "MyAppUITests-Runner", "MyApp"))
+	assert.Equal(t, processSample{runner: 100, runnerCPU: 12.5}, processSampleOf(processes, "MyAppUITests-Runner", ""))
+}
+
+func TestListenerRecordsPeakMemoryPerTest(t *testing.T) {
+	now := time.Date(2024, 1, 16, 15, 0, 0, 0, time.UTC)
+	sampler := newProcessSampler()
+	sampler.now = func() time.Time { return now }
+	tick := func() { now = now.Add(time.Second) }
+	listener := NewTestListener(io.Discard, io.Discard, t.TempDir())
+	listener.processSampler = sampler
+
+	listener.testSuiteDidStart("MyAppUITests", "2024-01-16 15:00:00 +0000")
+	listener.testCaseDidStartForClass("MyAppUITests", "testLogin")
+	tick()
+	sampler.add(processSample{runner: 100, app: 1000})
+	tick()
+	sampler.add(processSample{runner: 150, app: 3000, runnerCPU: 40, appCPU: 95})
+	tick()
+	listener.testCaseDidFinishForTest("MyAppUITests", "testLogin", "passed", 3)
+	tick()
+	sampler.add(processSample{runner: 500, app: 9000})
+	tick()
+	listener.testCaseDidStartForClass("MyAppUITests", "testLogout")
+	tick()
+	sampler.add(processSample{runner: 120, app: 2000})
+	tick()
+	listener.testCaseDidFinishForTest("MyAppUITests", "testLogout", "passed", 2)
+
+	testCases := listener.runningTestSuite.TestCases
+	assert.Equal(t, PeakMemory{Runner: 150, App: 3000}, testCases[0].PeakMemory)
+	assert.Equal(t, PeakCPU{Runner: 40, App: 95}, testCases[0].PeakCPU)
+	assert.Equal(t, PeakMemory{Runner: 120, App: 2000}, testCases[1].PeakMemory, "samples between tests are not counted")
+	assert.Len(t, sampler.samples, 1, "samples before the last test are dropped")
+}
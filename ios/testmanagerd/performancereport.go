@@ -0,0 +1,97 @@
+package testmanagerd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// PerformanceReportFormat is the file format of a report written by WritePerformanceReport
+type PerformanceReportFormat string
+
+const (
+	PerformanceReportJSON = PerformanceReportFormat("json")
+	PerformanceReportCSV  = PerformanceReportFormat("csv")
+)
+
+// TestPerformance are the timing and resource metrics of a single test case. Durations are in seconds, memory in
+// bytes and CPU usage in percent of one core. Memory and CPU usage are 0 unless TestConfig.PeakMemory or
+// TestConfig.PeakCPU was set for the run.
+type TestPerformance struct {
+	Suite             string         `json:"suite"`
+	ClassName         string         `json:"className"`
+	MethodName        string         `json:"methodName"`
+	Status            TestCaseStatus `json:"status"`
+	Duration          float64        `json:"duration"`
+	WallClockDuration float64        `json:"wallClockDuration"`
+	PeakMemoryRunner  uint64         `json:"peakMemoryRunner"`
+	PeakMemoryApp     uint64         `json:"peakMemoryApp"`
+	PeakCPURunner     float64        `json:"peakCpuRunner"`
+	PeakCPUApp        float64        `json:"peakCpuApp"`
+}
+
+// performanceReportHeader are the column names of CSV reports, in the order of the fields of TestPerformance
+var performanceReportHeader = []string{
+	"suite", "className", "methodName", "status", "duration", "wallClockDuration",
+	"peakMemoryRunner", "peakMemoryApp", "peakCpuRunner", "peakCpuApp",
+}
+
+// TestPerformances returns the metrics of all test cases of suites in the order they ran
+func TestPerformances(suites []TestSuite) []TestPerformance {
+	performances := []TestPerformance{}
+	for _, suite := range suites {
+		for _, testCase := range suite.TestCases {
+			performances = append(performances, TestPerformance{
+				Suite:             suite.Name,
+				ClassName:         testCase.ClassName,
+				MethodName:        testCase.MethodName,
+				Status:            testCase.Status,
+				Duration:          testCase.Duration.Seconds(),
+				WallClockDuration: testCase.WallClockDuration.Seconds(),
+				PeakMemoryRunner:  testCase.PeakMemory.Runner,
+				PeakMemoryApp:     testCase.PeakMemory.App,
+				PeakCPURunner:     testCase.PeakCPU.Runner,
+				PeakCPUApp:        testCase.PeakCPU.App,
+			})
+		}
+	}
+	return performances
+}
+
+// WritePerformanceReport writes the timing and resource metrics of every test case of suites to w, one row per test
+// case, to compare them between runs. JSON reports are an array of TestPerformance, CSV reports have a header row.
+func WritePerformanceReport(w io.Writer, suites []TestSuite, format PerformanceReportFormat) error {
+	performances := TestPerformances(suites)
+	switch format {
+	case PerformanceReportJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(performances); err != nil {
+			return fmt.Errorf("WritePerformanceReport: %w", err)
+		}
+		return nil
+	case PerformanceReportCSV:
+		writer := csv.NewWriter(w)
+		records := [][]string{performanceReportHeader}
+		for _, p := range performances {
+			records = append(records, []string{
+				p.Suite, p.ClassName, p.MethodName, string(p.Status),
+				formatSeconds(p.Duration), formatSeconds(p.WallClockDuration),
+				strconv.FormatUint(p.PeakMemoryRunner, 10), strconv.FormatUint(p.PeakMemoryApp, 10),
+				strconv.FormatFloat(p.PeakCPURunner, 'f', -1, 64), strconv.FormatFloat(p.PeakCPUApp, 'f', -1, 64),
+			})
+		}
+		if err := writer.WriteAll(records); err != nil {
+			return fmt.Errorf("WritePerformanceReport: %w", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("WritePerformanceReport: unknown format %q", format)
+}
+
+// formatSeconds formats a duration in seconds with millisecond precision
+func formatSeconds(seconds float64) string {
+	return strconv.FormatFloat(seconds, 'f', 3, 64)
+}
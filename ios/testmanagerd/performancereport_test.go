@@ -0,0 +1,70 @@
+package testmanagerd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func performanceReportSuites() []TestSuite {
+	return []TestSuite{{
+		Name: "LoginUITests",
+		TestCases: []TestCase{
+			{ClassName: "LoginUITests", MethodName: "testLogin", Status: StatusPassed, Duration: 1500 * time.Millisecond,
+				WallClockDuration: 1620 * time.Millisecond, PeakMemory: PeakMemory{Runner: 100, App: 2000}, PeakCPU: PeakCPU{Runner: 12.5, App: 80}},
+			{ClassName: "LoginUITests", MethodName: "testLogout", Status: StatusFailed, Duration: time.Second},
+		},
+	}}
+}
+
+func TestWritePerformanceReportCSV(t *testing.T) {
+	var report bytes.Buffer
+	require.NoError(t, WritePerformanceReport(&report, performanceReportSuites(), PerformanceReportCSV))
+
+	assert.Equal(t, `suite,className,methodName,status,duration,wallClockDuration,peakMemoryRunner,peakMemoryApp,peakCpuRunner,peakCpuApp
+LoginUITests,LoginUITests,testLogin,passed,1.500,1.620,100,2000,12.5,80
+LoginUITests,LoginUITests,testLogout,failed,1.000,0.000,0,0,0,0
+`, report.String())
+}
+
+func TestWritePerformanceReportJSON(t *testing.T) {
+	var report bytes.Buffer
+	require.NoError(t, WritePerformanceReport(&report, performanceReportSuites(), PerformanceReportJSON))
+
+	var performances []TestPerformance
+	require.NoError(t, json.Unmarshal(report.Bytes(), &performances))
+	assert.Equal(t, TestPerformances(performanceReportSuites()), performances)
+	assert.Equal(t, TestPerformance{Suite: "LoginUITests", ClassName: "LoginUITests", MethodName: "testLogin", Status: StatusPassed,
+		Duration: 1.5, WallClockDuration: 1.62, PeakMemoryRunner: 100, PeakMemoryApp: 2000, PeakCPURunner: 12.5, PeakCPUApp: 80}, performances[0])
+
+	report.Reset()
+	require.NoError(t, WritePerformanceReport(&report, nil, PerformanceReportJSON))
+	assert.Equal(t, "[]\n", report.String(), "a run without tests is an empty array")
+}
+
+func TestWritePerformanceReportUnknownFormat(t *testing.T) {
+	if err := WritePerformanceReport(io.Discard, nil, "xml"); assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "unknown format")
+	}
+}
+
+func TestListenerRecordsWallClockDuration(t *testing.T) {
+	now := time.Date(2024, 1, 16, 15, 0, 0, 0, time.UTC)
+	listener := NewTestListener(io.Discard, io.Discard, t.TempDir())
+	listener.now = func() time.Time { return now }
+
+	listener.testSuiteDidStart("LoginUITests", "2024-01-16 15:00:00 +0000")
+	listener.testCaseDidStartForClass("LoginUITests", "testLogin")
+	now = now.Add(1700 * time.Millisecond)
+	listener.testCaseDidFinishForTest("LoginUITests", "testLogin", "passed", 1.5)
+
+	testCase := listener.runningTestSuite.TestCases[0]
+	assert.Equal(t, 1500*time.Millisecond, testCase.Duration)
+	assert.Equal(t, 1700*time.Millisecond, testCase.WallClockDuration)
+	assert.Empty(t, listener.testStartTimes)
+}
@@ -20,6 +20,10 @@ type proxyDispatcher struct {
 
 func (p proxyDispatcher) Dispatch(m dtx.Message) {
 	var dispatcher = &p
+	if p.testListener != nil {
+		p.testListener.sessionMu.Lock()
+		defer p.testListener.sessionMu.Unlock()
+	}
 	defer func() {
 		if r := recover(); r != nil {
 			stacktrace := string(debug.Stack())
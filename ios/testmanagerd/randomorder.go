@@ -0,0 +1,11 @@
+package testmanagerd
+
+// WithRandomOrder runs the tests in random order to detect tests depending on each other. XCTest derives the order
+// from seed, so a failing order can be reproduced by running again with the same seed. The seed is recorded in
+// TestListener.RandomOrderSeed.
+func WithRandomOrder(seed uint64) XCTestRunOption {
+	return func(config *TestConfig) {
+		config.RandomOrder = true
+		config.RandomOrderSeed = seed
+	}
+}
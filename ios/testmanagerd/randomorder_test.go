@@ -0,0 +1,22 @@
+package testmanagerd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithRandomOrder(t *testing.T) {
+	config := applyOptions(TestConfig{TestsToRun: []string{"LoginTests/testLogin", "LoginTests/testLogout"}}, WithRandomOrder(42))
+
+	assert.True(t, config.RandomOrder)
+	assert.Equal(t, uint64(42), config.RandomOrderSeed)
+	assert.Equal(t, []string{"LoginTests/testLogin", "LoginTests/testLogout"}, config.TestsToRun, "XCTest shuffles the tests itself")
+
+	contents := map[string]interface{}{}
+	for _, opt := range config.xcTestConfigurationOptions() {
+		opt(contents)
+	}
+	assert.Equal(t, 1, contents["testExecutionOrdering"])
+	assert.Equal(t, uint64(42), contents["randomExecutionOrderingSeed"])
+}
@@ -0,0 +1,77 @@
+package testmanagerd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// WithRepeat runs the tests n times after another, see TestConfig.Repeat
+func WithRepeat(n int) XCTestRunOption {
+	return func(config *TestConfig) {
+		config.Repeat = n
+	}
+}
+
+// WithRepeatUntilFailure runs the tests again and again until an iteration fails, see TestConfig.RepeatUntilFailure
+func WithRepeatUntilFailure() XCTestRunOption {
+	return func(config *TestConfig) {
+		config.RepeatUntilFailure = true
+	}
+}
+
+// IterationResult summarizes a single iteration of a run with TestConfig.Repeat or TestConfig.RepeatUntilFailure
+type IterationResult struct {
+	// Iteration is the number of the iteration, starting at 1
+	Iteration int
+	// Passed includes expected failures, Failed includes stalled and crashed tests
+	Passed  int
+	Failed  int
+	Skipped int
+	// Err is the error the test session of the iteration ended with, f.ex. if the test runner crashed
+	Err error
+}
+
+// failed returns whether a test of the iteration failed or its session ended with an error
+func (r IterationResult) failed() bool {
+	return r.Failed > 0 || r.Err != nil
+}
+
+// runTestRepeatedly runs testConfig with run in a loop as configured by Repeat and RepeatUntilFailure. The results
+// of all iterations are collected in the listener and summarized in TestListener.IterationResults.
+func runTestRepeatedly(ctx context.Context, testConfig TestConfig, run func(context.Context, TestConfig) ([]TestSuite, error)) ([]TestSuite, error) {
+	if testConfig.Listener == nil {
+		testConfig.Listener = NewTestListener(io.Discard, io.Discard, "")
+	}
+	listener := testConfig.Listener
+	config := testConfig
+	config.Repeat = 0
+	config.RepeatUntilFailure = false
+
+	var errs []error
+	for iteration := 1; testConfig.Repeat <= 0 || iteration <= testConfig.Repeat; iteration++ {
+		if iteration > 1 {
+			listener.restartSession()
+		}
+		log.WithField("iteration", iteration).Info("running tests")
+		firstSuite := len(listener.TestSuites)
+		_, err := run(ctx, config)
+		result := IterationResult{Iteration: iteration, Err: err}
+		result.Passed, result.Failed, result.Skipped = countTestCases(listener.TestSuites[firstSuite:])
+		listener.IterationResults = append(listener.IterationResults, result)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("iteration %d: %w", iteration, err))
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		if testConfig.RepeatUntilFailure && result.failed() {
+			log.WithField("iteration", iteration).Info("stopping repeated test run after the first failing iteration")
+			break
+		}
+	}
+	return listener.TestSuites, errors.Join(errs...)
+}
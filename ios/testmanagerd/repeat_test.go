@@ -0,0 +1,122 @@
+package testmanagerd
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/danielpaulus/go-ios/ios"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyRun returns a run that passes testLogin in every iteration except the ones in failing
+func flakyRun(listener *TestListener, iterations *int, failing ...int) func(context.Context, TestConfig) ([]TestSuite, error) {
+	return func(ctx context.Context, config TestConfig) ([]TestSuite, error) {
+		*iterations++
+		status := "passed"
+		for _, iteration := range failing {
+			if *iterations == iteration {
+				status = "failed"
+			}
+		}
+		listener.testSuiteDidStart("LoginTests", "2024-01-16 15:00:00 +0000")
+		listener.testCaseDidStartForClass("LoginTests", "testLogin")
+		if status == "failed" {
+			listener.testCaseFailedForClass("LoginTests", "testLogin", "flaky", "LoginTests.swift", 12)
+		}
+		listener.testCaseDidFinishForTest("LoginTests", "testLogin", status, 1)
+		listener.testSuiteFinished("LoginTests", "2024-01-16 15:00:01 +0000", 1, 0, 0, 0, 0, 0, 1, 1)
+		listener.didFinishExecutingTestPlan()
+		return listener.TestSuites, nil
+	}
+}
+
+func TestRepeat(t *testing.T) {
+	listener := NewTestListener(io.Discard, io.Discard, t.TempDir())
+	iterations := 0
+	config := TestConfig{Listener: listener}
+	WithRepeat(3)(&config)
+
+	suites, err := runTestRepeatedly(context.Background(), config, flakyRun(listener, &iterations, 2))
+
+	require.NoError(t, err)
+	assert.Equal(t, 3, iterations, "failures do not stop a repeated run without RepeatUntilFailure")
+	assert.Len(t, suites, 3)
+	assert.Equal(t, []IterationResult{
+		{Iteration: 1, Passed: 1},
+		{Iteration: 2, Failed: 1},
+		{Iteration: 3, Passed: 1},
+	}, listener.IterationResults)
+}
+
+func TestRepeatUntilFailure(t *testing.T) {
+	listener := NewTestListener(io.Discard, io.Discard, t.TempDir())
+	iterations := 0
+	config := TestConfig{Listener: listener}
+	WithRepeatUntilFailure()(&config)
+
+	suites, err := runTestRepeatedly(context.Background(), config, flakyRun(listener, &iterations, 4))
+
+	require.NoError(t, err)
+	assert.Equal(t, 4, iterations)
+	assert.Len(t, suites, 4)
+	require.Len(t, listener.IterationResults, 4)
+	assert.Equal(t, IterationResult{Iteration: 4, Failed: 1}, listener.IterationResults[3])
+
+	t.Run("stops after Repeat iterations", func(t *testing.T) {
+		listener := NewTestListener(io.Discard, io.Discard, t.TempDir())
+		iterations := 0
+		config := TestConfig{Listener: listener, Repeat: 2, RepeatUntilFailure: true}
+
+		_, err := runTestRepeatedly(context.Background(), config, flakyRun(listener, &iterations, 4))
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, iterations)
+	})
+
+	t.Run("stops when the context is done", func(t *testing.T) {
+		listener := NewTestListener(io.Discard, io.Discard, t.TempDir())
+		ctx, cancel := context.WithCancel(context.Background())
+		iterations := 0
+		run := flakyRun(listener, &iterations)
+		config := TestConfig{Listener: listener, RepeatUntilFailure: true}
+
+		_, err := runTestRepeatedly(ctx, config, func(ctx context.Context, config TestConfig) ([]TestSuite, error) {
+			if iterations == 2 {
+				cancel()
+			}
+			return run(ctx, config)
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, 3, iterations)
+	})
+}
+
+func TestRepeatReadsDeviceLocaleOnce(t *testing.T) {
+	defer func(original func(ios.DeviceEntry) (ios.DeviceLocale, error)) {
+		getDeviceLocale = original
+	}(getDeviceLocale)
+	reads := 0
+	getDeviceLocale = func(device ios.DeviceEntry) (ios.DeviceLocale, error) {
+		reads++
+		return ios.DeviceLocale{Language: "de", Locale: "de_DE"}, nil
+	}
+	listener := NewTestListener(io.Discard, io.Discard, t.TempDir())
+	config := TestConfig{Listener: listener}
+	WithRepeat(3)(&config)
+	resolveDeviceLocale(&config)
+
+	iterations := 0
+	_, err := runTestRepeatedly(context.Background(), config, func(ctx context.Context, config TestConfig) ([]TestSuite, error) {
+		iterations++
+		resolveDeviceLocale(&config)
+		assert.Equal(t, "de_DE", config.deviceLocale.Locale)
+		return nil, nil
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, 3, iterations)
+	assert.Equal(t, 1, reads)
+}
@@ -0,0 +1,178 @@
+package testmanagerd
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/danielpaulus/go-ios/ios"
+	"github.com/danielpaulus/go-ios/ios/house_arrest"
+	"howett.net/plist"
+)
+
+// testSummariesSuffix is the suffix of the summary plist the test runner writes into a legacy results bundle,
+// f.ex. 'TestSummaries.plist' or 'action_TestSummaries.plist'
+const testSummariesSuffix = "TestSummaries.plist"
+
+// xcresultDataDirectory is the directory a results bundle in the xcresult format of Xcode 11 and later stores its
+// results in
+const xcresultDataDirectory = "Data"
+
+type testSummaries struct {
+	FormatVersion     string
+	TestableSummaries []testableSummary
+}
+
+type testableSummary struct {
+	TargetName string
+	Tests      []testSummary
+}
+
+type testSummary struct {
+	TestName         string
+	TestIdentifier   string
+	TestStatus       string
+	Duration         float64
+	FailureSummaries []testFailureSummary
+	Subtests         []testSummary
+}
+
+type testFailureSummary struct {
+	Message    string
+	FileName   string
+	LineNumber uint64
+}
+
+// ErrXCResultNotSupported is returned for results bundles in the xcresult format of Xcode 11 and later. They store
+// the results in a database instead of a TestSummaries.plist, which go-ios can not parse.
+var ErrXCResultNotSupported = errors.New("xcresult format not supported, only legacy results bundles with a TestSummaries.plist can be read")
+
+// PullLegacyResultsBundleSummary downloads the test summary of the legacy results bundle at bundlePath from the app
+// container of bundleID and parses it with ParseTestSummaries. Legacy results bundles are written by Xcode 10 and
+// earlier, bundlePath is relative to the app container, f.ex. 'tmp/TestResults'. The summary is looked up in
+// the bundle and its direct subdirectories. For bundles in the newer xcresult format ErrXCResultNotSupported is
+// returned. Use this as an alternative to the live results of a TestListener if events of the test session got lost.
+func PullLegacyResultsBundleSummary(device ios.DeviceEntry, bundleID string, bundlePath string) ([]TestSuite, error) {
+	houseArrestService, err := house_arrest.New(device, bundleID)
+	if err != nil {
+		return nil, fmt.Errorf("PullLegacyResultsBundleSummary: could not connect to the container of %s: %w", bundleID, err)
+	}
+	defer houseArrestService.Close()
+
+	summaryPath, err := findLegacyTestSummaries(houseArrestService.ListFiles, bundlePath)
+	if err != nil {
+		return nil, fmt.Errorf("PullLegacyResultsBundleSummary: %w", err)
+	}
+	content, err := houseArrestService.ReadFile(summaryPath)
+	if err != nil {
+		return nil, fmt.Errorf("PullLegacyResultsBundleSummary: could not read %s: %w", summaryPath, err)
+	}
+	return ParseTestSummaries(content)
+}
+
+// findLegacyTestSummaries returns the path of the TestSummaries.plist of the legacy results bundle at bundlePath.
+// A bundle containing the Data directory of the xcresult format instead results in ErrXCResultNotSupported.
+func findLegacyTestSummaries(listFiles func(string) ([]string, error), bundlePath string) (string, error) {
+	files, err := listFiles(bundlePath)
+	if err != nil {
+		return "", fmt.Errorf("could not list results bundle %s: %w", bundlePath, err)
+	}
+	var subdirectories []string
+	xcresult := false
+	for _, f := range files {
+		if f == "" || f == "." || f == ".." {
+			continue
+		}
+		if strings.HasSuffix(f, testSummariesSuffix) {
+			return path.Join(bundlePath, f), nil
+		}
+		if f == xcresultDataDirectory {
+			xcresult = true
+		}
+		if !strings.Contains(f, ".") {
+			subdirectories = append(subdirectories, f)
+		}
+	}
+	if xcresult {
+		return "", fmt.Errorf("results bundle %s: %w", bundlePath, ErrXCResultNotSupported)
+	}
+	for _, dir := range subdirectories {
+		dirPath := path.Join(bundlePath, dir)
+		files, err := listFiles(dirPath)
+		if err != nil {
+			continue
+		}
+		for _, f := range files {
+			if strings.HasSuffix(f, testSummariesSuffix) {
+				return path.Join(dirPath, f), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no %s found in results bundle %s", testSummariesSuffix, bundlePath)
+}
+
+// ParseTestSummaries parses the TestSummaries.plist of a results bundle into test suites. Each test class becomes
+// a TestSuite. Only the pass/fail summary, durations and the first failure of every test case are available
+// in this format, attachments and activities are not parsed.
+func ParseTestSummaries(content []byte) ([]TestSuite, error) {
+	var summaries testSummaries
+	if _, err := plist.Unmarshal(content, &summaries); err != nil {
+		return nil, fmt.Errorf("ParseTestSummaries: could not parse summary plist: %w", err)
+	}
+	suites := []TestSuite{}
+	for _, testable := range summaries.TestableSummaries {
+		for _, test := range testable.Tests {
+			suites = collectTestSuites(test, suites)
+		}
+	}
+	return suites, nil
+}
+
+// collectTestSuites walks the summary tree and appends a TestSuite for every group directly containing test cases
+func collectTestSuites(group testSummary, suites []TestSuite) []TestSuite {
+	suite := TestSuite{Name: group.TestName}
+	for _, subtest := range group.Subtests {
+		if subtest.TestStatus == "" {
+			suites = collectTestSuites(subtest, suites)
+			continue
+		}
+		testCase := testCaseFromSummary(group.TestName, subtest)
+		suite.TestDuration += testCase.Duration
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+	if len(suite.TestCases) > 0 {
+		suite.TotalDuration = suite.TestDuration
+		suites = append(suites, suite)
+	}
+	return suites
+}
+
+func testCaseFromSummary(className string, summary testSummary) TestCase {
+	methodName := strings.TrimSuffix(summary.TestName, "()")
+	if class, method, found := strings.Cut(summary.TestIdentifier, "/"); found {
+		className = class
+		methodName = strings.TrimSuffix(method, "()")
+	}
+	testCase := TestCase{
+		ClassName:  className,
+		MethodName: methodName,
+		Duration:   time.Duration(summary.Duration * float64(time.Second)),
+	}
+	switch summary.TestStatus {
+	case "Success":
+		testCase.Status = StatusPassed
+	case "Expected Failure":
+		testCase.Status = StatusExpectedFailure
+	case "Skipped":
+		testCase.Status = StatusSkipped
+	default:
+		testCase.Status = StatusFailed
+	}
+	if len(summary.FailureSummaries) > 0 {
+		failure := summary.FailureSummaries[0]
+		testCase.Err = TestError{Message: failure.Message, File: failure.FileName, Line: failure.LineNumber}
+	}
+	return testCase
+}
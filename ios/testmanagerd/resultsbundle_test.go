@@ -0,0 +1,140 @@
+package testmanagerd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testSummariesFixture = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>FormatVersion</key>
+	<string>1.2</string>
+	<key>TestableSummaries</key>
+	<array>
+		<dict>
+			<key>TargetName</key>
+			<string>FakeCounterUITests</string>
+			<key>Tests</key>
+			<array>
+				<dict>
+					<key>TestName</key>
+					<string>All tests</string>
+					<key>Subtests</key>
+					<array>
+						<dict>
+							<key>TestName</key>
+							<string>FakeCounterUITests.xctest</string>
+							<key>Subtests</key>
+							<array>
+								<dict>
+									<key>TestName</key>
+									<string>FakeCounterUITests</string>
+									<key>Subtests</key>
+									<array>
+										<dict>
+											<key>TestIdentifier</key>
+											<string>FakeCounterUITests/testCounter()</string>
+											<key>TestName</key>
+											<string>testCounter()</string>
+											<key>TestStatus</key>
+											<string>Success</string>
+											<key>Duration</key>
+											<real>1.5</real>
+										</dict>
+										<dict>
+											<key>TestIdentifier</key>
+											<string>FakeCounterUITests/testReset()</string>
+											<key>TestName</key>
+											<string>testReset()</string>
+											<key>TestStatus</key>
+											<string>Failure</string>
+											<key>Duration</key>
+											<real>0.5</real>
+											<key>FailureSummaries</key>
+											<array>
+												<dict>
+													<key>FileName</key>
+													<string>FakeCounterUITests.swift</string>
+													<key>LineNumber</key>
+													<integer>42</integer>
+													<key>Message</key>
+													<string>XCTAssertEqual failed: ("1") is not equal to ("0")</string>
+												</dict>
+											</array>
+										</dict>
+										<dict>
+											<key>TestIdentifier</key>
+											<string>FakeCounterUITests/testSkipped()</string>
+											<key>TestName</key>
+											<string>testSkipped()</string>
+											<key>TestStatus</key>
+											<string>Skipped</string>
+										</dict>
+									</array>
+								</dict>
+							</array>
+						</dict>
+					</array>
+				</dict>
+			</array>
+		</dict>
+	</array>
+</dict>
+</plist>`
+
+func TestParseTestSummaries(t *testing.T) {
+	suites, err := ParseTestSummaries([]byte(testSummariesFixture))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []TestSuite{
+		{
+			Name:          "FakeCounterUITests",
+			TestDuration:  2 * time.Second,
+			TotalDuration: 2 * time.Second,
+			TestCases: []TestCase{
+				{ClassName: "FakeCounterUITests", MethodName: "testCounter", Status: StatusPassed, Duration: 1500 * time.Millisecond},
+				{
+					ClassName:  "FakeCounterUITests",
+					MethodName: "testReset",
+					Status:     StatusFailed,
+					Duration:   500 * time.Millisecond,
+					Err:        TestError{Message: `XCTAssertEqual failed: ("1") is not equal to ("0")`, File: "FakeCounterUITests.swift", Line: 42},
+				},
+				{ClassName: "FakeCounterUITests", MethodName: "testSkipped", Status: StatusSkipped},
+			},
+		},
+	}, suites)
+}
+
+func TestParseTestSummariesInvalidPlist(t *testing.T) {
+	_, err := ParseTestSummaries([]byte("not a plist"))
+
+	assert.Error(t, err)
+}
+
+func TestFindLegacyTestSummaries(t *testing.T) {
+	bundles := map[string][]string{
+		"tmp/legacy.xcresult":        {".", "..", "1_Test", "Info.plist"},
+		"tmp/legacy.xcresult/1_Test": {"action.xcactivitylog", "action_TestSummaries.plist"},
+		"tmp/results.xcresult":       {".", "..", "Data", "Info.plist"},
+		"tmp/results.xcresult/Data":  {"data.0~abc", "refs.0~def"},
+		"tmp/empty.xcresult":         {".", ".."},
+	}
+	listFiles := func(path string) ([]string, error) {
+		return bundles[path], nil
+	}
+
+	summaryPath, err := findLegacyTestSummaries(listFiles, "tmp/legacy.xcresult")
+	assert.NoError(t, err)
+	assert.Equal(t, "tmp/legacy.xcresult/1_Test/action_TestSummaries.plist", summaryPath)
+
+	_, err = findLegacyTestSummaries(listFiles, "tmp/results.xcresult")
+	assert.ErrorIs(t, err, ErrXCResultNotSupported)
+
+	_, err = findLegacyTestSummaries(listFiles, "tmp/empty.xcresult")
+	assert.EqualError(t, err, "no TestSummaries.plist found in results bundle tmp/empty.xcresult")
+}
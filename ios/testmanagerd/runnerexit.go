@@ -0,0 +1,94 @@
+package testmanagerd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/danielpaulus/go-ios/ios/debugserver"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrRunnerExitedNonZero is returned by RunnerExitError if the test runner process exited with a non zero exit code
+var ErrRunnerExitedNonZero = errors.New("test runner exited with non zero exit code")
+
+// runnerExitTimeout is how long the test runner is given to exit by itself after the tests finished
+const runnerExitTimeout = 10 * time.Second
+
+// waitForProcessExit blocks until the process exited, it is replaced in tests
+var waitForProcessExit = debugserver.WaitForExit
+
+// WithRunnerExitCode records the exit code of the test runner in TestListener.RunnerExitCode, see
+// TestConfig.RunnerExitCode
+func WithRunnerExitCode() XCTestRunOption {
+	return func(config *TestConfig) {
+		config.RunnerExitCode = true
+	}
+}
+
+// RunnerExited records the exit code of the test runner process in RunnerExitCode. The runner can fail after the test
+// plan finished, f.ex. because of an assertion in a teardown hook, so the exit code is kept apart from the test results.
+// It is called with the exit code debugserver observes if TestConfig.RunnerExitCode is set. A non zero exit code of a
+// runner restarted within the same listener is not replaced by a later zero one.
+func (t *TestListener) RunnerExited(exitCode int) {
+	if t.RunnerExitCode != nil && *t.RunnerExitCode != 0 && exitCode == 0 {
+		return
+	}
+	t.RunnerExitCode = &exitCode
+}
+
+// RunnerExitError returns an error wrapping ErrRunnerExitedNonZero if the test runner exited with a non zero exit
+// code, independent of whether any test failed. It returns nil if the runner exited with 0 or the exit code is unknown.
+func (t *TestListener) RunnerExitError() error {
+	if t.RunnerExitCode == nil || *t.RunnerExitCode == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %d", ErrRunnerExitedNonZero, *t.RunnerExitCode)
+}
+
+// runnerExitWatch observes the test runner process until it exited or the watch is stopped
+type runnerExitWatch struct {
+	cancel context.CancelFunc
+	exited chan struct{}
+}
+
+// watchRunnerExit attaches debugserver to the test runner with pid if config.RunnerExitCode is set and reports its
+// exit code to config.Listener
+func watchRunnerExit(ctx context.Context, config TestConfig, pid uint64) runnerExitWatch {
+	exited := make(chan struct{})
+	if !config.RunnerExitCode {
+		close(exited)
+		return runnerExitWatch{cancel: func() {}, exited: exited}
+	}
+	watchCtx, cancel := context.WithCancel(ctx)
+	go func() {
+		defer close(exited)
+		exit, err := waitForProcessExit(watchCtx, config.Device, pid)
+		if err != nil {
+			if watchCtx.Err() == nil {
+				log.WithError(err).Warn("cannot observe the exit of the test runner")
+			}
+			return
+		}
+		log.WithField("pid", pid).Debugf("test runner exited with %d", exit.ExitCode())
+		config.Listener.RunnerExited(exit.ExitCode())
+	}()
+	return runnerExitWatch{cancel: cancel, exited: exited}
+}
+
+// wait gives the runner runnerExitTimeout to exit by itself before the watch is stopped
+func (w runnerExitWatch) wait() {
+	select {
+	case <-w.exited:
+	case <-time.After(runnerExitTimeout):
+		log.Warn("test runner did not exit in time, its exit code is unknown")
+	}
+	w.stop()
+}
+
+// stop stops observing the runner, the exit code is only recorded if the runner exited before
+func (w runnerExitWatch) stop() {
+	w.cancel()
+	<-w.exited
+}
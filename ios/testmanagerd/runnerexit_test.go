@@ -0,0 +1,96 @@
+package testmanagerd
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/danielpaulus/go-ios/ios"
+	"github.com/danielpaulus/go-ios/ios/debugserver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunnerExitCodeIsReportedWhenAllTestsPassed(t *testing.T) {
+	listener := NewTestListener(io.Discard, io.Discard, t.TempDir())
+	listener.testSuiteDidStart("MyAppUITests", "2024-01-16 15:00:00 +0000")
+	listener.testCaseDidStartForClass("MyAppUITests", "testLogin")
+	listener.testCaseDidFinishForTest("MyAppUITests", "testLogin", "passed", 1)
+	listener.testSuiteFinished("MyAppUITests", "2024-01-16 15:00:01 +0000", 1, 0, 0, 0, 0, 0, 1, 1)
+	listener.didFinishExecutingTestPlan()
+	listener.RunnerExited(3)
+
+	assert.NoError(t, listener.err)
+	assert.Equal(t, StatusPassed, listener.TestSuites[0].TestCases[0].Status)
+	if assert.NotNil(t, listener.RunnerExitCode) {
+		assert.Equal(t, 3, *listener.RunnerExitCode)
+	}
+	assert.ErrorIs(t, listener.RunnerExitError(), ErrRunnerExitedNonZero)
+}
+
+func TestRunnerExitError(t *testing.T) {
+	listener := NewTestListener(io.Discard, io.Discard, t.TempDir())
+	assert.NoError(t, listener.RunnerExitError(), "unknown exit code")
+	listener.RunnerExited(0)
+	assert.NoError(t, listener.RunnerExitError())
+}
+
+func TestWatchRunnerExitReportsExitCode(t *testing.T) {
+	defer func(original func(context.Context, ios.DeviceEntry, uint64) (debugserver.ProcessExit, error)) {
+		waitForProcessExit = original
+	}(waitForProcessExit)
+	var observedPid uint64
+	waitForProcessExit = func(ctx context.Context, device ios.DeviceEntry, pid uint64) (debugserver.ProcessExit, error) {
+		observedPid = pid
+		return debugserver.ProcessExit{Signal: 6}, nil
+	}
+	listener := NewTestListener(io.Discard, io.Discard, t.TempDir())
+	config := TestConfig{Listener: listener}
+	WithRunnerExitCode()(&config)
+
+	runnerExit := watchRunnerExit(context.Background(), config, 1234)
+	runnerExit.wait()
+
+	assert.Equal(t, uint64(1234), observedPid)
+	if assert.NotNil(t, listener.RunnerExitCode) {
+		assert.Equal(t, 134, *listener.RunnerExitCode)
+	}
+	assert.ErrorIs(t, listener.RunnerExitError(), ErrRunnerExitedNonZero)
+}
+
+func TestWatchRunnerExitStopsWithoutExit(t *testing.T) {
+	defer func(original func(context.Context, ios.DeviceEntry, uint64) (debugserver.ProcessExit, error)) {
+		waitForProcessExit = original
+	}(waitForProcessExit)
+	waitForProcessExit = func(ctx context.Context, device ios.DeviceEntry, pid uint64) (debugserver.ProcessExit, error) {
+		<-ctx.Done()
+		return debugserver.ProcessExit{}, ctx.Err()
+	}
+	listener := NewTestListener(io.Discard, io.Discard, t.TempDir())
+
+	runnerExit := watchRunnerExit(context.Background(), TestConfig{Listener: listener, RunnerExitCode: true}, 1234)
+	runnerExit.stop()
+
+	assert.Nil(t, listener.RunnerExitCode)
+}
+
+func TestWatchRunnerExitDisabled(t *testing.T) {
+	defer func(original func(context.Context, ios.DeviceEntry, uint64) (debugserver.ProcessExit, error)) {
+		waitForProcessExit = original
+	}(waitForProcessExit)
+	waitForProcessExit = func(ctx context.Context, device ios.DeviceEntry, pid uint64) (debugserver.ProcessExit, error) {
+		t.Error("debugserver must not be attached")
+		return debugserver.ProcessExit{}, nil
+	}
+	listener := NewTestListener(io.Discard, io.Discard, t.TempDir())
+
+	watchRunnerExit(context.Background(), TestConfig{Listener: listener}, 1234).wait()
+
+	assert.Nil(t, listener.RunnerExitCode)
+}
+
+func TestRunnerExitedKeepsNonZeroExitCode(t *testing.T) {
+	listener := NewTestListener(io.Discard, io.Discard, t.TempDir())
+	listener.RunnerExited(1)
+	listener.RunnerExited(0)
+	assert.ErrorIs(t, listener.RunnerExitError(), ErrRunnerExitedNonZero)
+}
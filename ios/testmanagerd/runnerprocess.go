@@ -0,0 +1,118 @@
+package testmanagerd
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/danielpaulus/go-ios/ios"
+	"github.com/danielpaulus/go-ios/ios/instruments"
+	log "github.com/sirupsen/logrus"
+)
+
+// runnerProcess is the process id of the test runner, set once the runner was launched
+type runnerProcess struct {
+	mu      sync.Mutex
+	pid     uint64
+	started chan struct{}
+}
+
+// startedChannel returns the channel closed when the runner was launched, the caller must hold mu
+func (r *runnerProcess) startedChannel() chan struct{} {
+	if r.started == nil {
+		r.started = make(chan struct{})
+	}
+	return r.started
+}
+
+// RunnerStarted is closed as soon as the test runner was launched, RunnerPID returns its process id from then on.
+// It is never closed if launching the runner failed.
+func (t *TestListener) RunnerStarted() <-chan struct{} {
+	t.runner.mu.Lock()
+	defer t.runner.mu.Unlock()
+	return t.runner.startedChannel()
+}
+
+// RunnerPID returns the process id of the test runner and whether it was launched already
+func (t *TestListener) RunnerPID() (uint64, bool) {
+	t.runner.mu.Lock()
+	defer t.runner.mu.Unlock()
+	return t.runner.pid, t.runner.pid != 0
+}
+
+// runnerStarted records the process id of the launched test runner. A runner restarted within the same listener,
+// f.ex. for TestConfig.TestEnvironmentOverrides, replaces the process id.
+func (t *TestListener) runnerStarted(pid uint64) {
+	t.runner.mu.Lock()
+	first := t.runner.pid == 0
+	t.runner.pid = pid
+	if first {
+		close(t.runner.startedChannel())
+	}
+	t.runner.mu.Unlock()
+	log.WithField("pid", pid).Debug("test runner started")
+	t.emit(TestEvent{Type: EventRunnerStarted, PID: pid})
+}
+
+// processStatsSource delivers sysmontap samples of all processes, implemented by the sysmontap service of instruments
+type processStatsSource interface {
+	ReceiveProcessStats() chan []instruments.ProcessStats
+	Close() error
+}
+
+// SampleTestRunner attaches sysmontap to the test runner of listener to profile it while the tests run. It sends the
+// CPU and memory usage of the runner process of every sample, taken each samplingInterval milliseconds, as soon as
+// the runner was launched. The returned channel is closed when ctx is done or the test session finished.
+func SampleTestRunner(ctx context.Context, device ios.DeviceEntry, listener *TestListener, samplingInterval int) (<-chan instruments.ProcessStats, error) {
+	sysmon, err := instruments.NewSysmontapService(device, samplingInterval)
+	if err != nil {
+		return nil, fmt.Errorf("SampleTestRunner: cannot start sysmontap: %w", err)
+	}
+	return sampleRunner(ctx, sysmon, listener), nil
+}
+
+// sampleRunner picks the samples of the test runner of listener from source until ctx is done or the session finished
+func sampleRunner(ctx context.Context, source processStatsSource, listener *TestListener) <-chan instruments.ProcessStats {
+	samples := make(chan instruments.ProcessStats)
+	stats := source.ReceiveProcessStats()
+	go func() {
+		defer close(samples)
+		defer func() {
+			source.Close()
+			// drain the samples sent until the closed service closes the channel
+			go func() {
+				for range stats {
+				}
+			}()
+		}()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-listener.Done():
+				return
+			case processes, ok := <-stats:
+				if !ok {
+					return
+				}
+				pid, started := listener.RunnerPID()
+				if !started {
+					continue
+				}
+				for _, process := range processes {
+					if process.Pid != pid {
+						continue
+					}
+					select {
+					case samples <- process:
+					case <-ctx.Done():
+						return
+					case <-listener.Done():
+						return
+					}
+				}
+			}
+		}
+	}()
+	return samples
+}
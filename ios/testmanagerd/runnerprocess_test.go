@@ -0,0 +1,89 @@
+package testmanagerd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/danielpaulus/go-ios/ios/instruments"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunnerPID(t *testing.T) {
+	listener := &TestListener{}
+	_, started := listener.RunnerPID()
+	assert.False(t, started)
+	select {
+	case <-listener.RunnerStarted():
+		t.Fatal("runner started before it was launched")
+	default:
+	}
+
+	listener.runnerStarted(123)
+	<-listener.RunnerStarted()
+	pid, started := listener.RunnerPID()
+	assert.True(t, started)
+	assert.Equal(t, uint64(123), pid)
+
+	listener.runnerStarted(456)
+	pid, _ = listener.RunnerPID()
+	assert.Equal(t, uint64(456), pid)
+}
+
+func TestRunnerStartedEvent(t *testing.T) {
+	var events bytes.Buffer
+	listener := NewTestListener(io.Discard, io.Discard, t.TempDir())
+	listener.events = newEventStream(&events)
+
+	listener.runnerStarted(42)
+
+	var event TestEvent
+	require.NoError(t, json.Unmarshal(events.Bytes(), &event))
+	assert.Equal(t, EventRunnerStarted, event.Type)
+	assert.Equal(t, uint64(42), event.PID)
+}
+
+type fakeProcessStats struct {
+	stats  chan []instruments.ProcessStats
+	closed chan struct{}
+}
+
+func (f fakeProcessStats) ReceiveProcessStats() chan []instruments.ProcessStats {
+	return f.stats
+}
+
+func (f fakeProcessStats) Close() error {
+	close(f.closed)
+	return nil
+}
+
+func TestSampleRunner(t *testing.T) {
+	source := fakeProcessStats{stats: make(chan []instruments.ProcessStats), closed: make(chan struct{})}
+	listener := NewTestListener(io.Discard, io.Discard, t.TempDir())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	samples := sampleRunner(ctx, source, listener)
+
+	// the process id is 0 until the runner was launched, which must not match any process
+	source.stats <- []instruments.ProcessStats{{Pid: 0, Name: "kernel_task"}}
+	listener.runnerStarted(7)
+	go func() {
+		source.stats <- []instruments.ProcessStats{{Pid: 3, Name: "App"}, {Pid: 7, Name: "Runner", PhysFootprint: 1024}}
+	}()
+
+	sample := <-samples
+	assert.Equal(t, instruments.ProcessStats{Pid: 7, Name: "Runner", PhysFootprint: 1024}, sample)
+
+	cancel()
+	select {
+	case _, ok := <-samples:
+		assert.False(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("samples were not closed after the context was cancelled")
+	}
+	<-source.closed
+}
@@ -0,0 +1,190 @@
+package testmanagerd
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/danielpaulus/go-ios/ios"
+	"github.com/danielpaulus/go-ios/ios/instruments"
+	log "github.com/sirupsen/logrus"
+)
+
+// screenRecordingIdleInterval is the time the recorder waits before checking again for a started test case, and
+// before retrying a screenshot that failed
+const screenRecordingIdleInterval = 100 * time.Millisecond
+
+// WithScreenRecording records the screen of the device while each test runs and stores the recordings in dir,
+// see TestConfig.ScreenRecordingDir
+func WithScreenRecording(dir string) XCTestRunOption {
+	return func(config *TestConfig) {
+		config.ScreenRecordingDir = dir
+	}
+}
+
+// screenRecorder captures screenshots as fast as the screenshot service delivers them while a test case runs and
+// writes them to a Motion JPEG MP4 file per test case
+type screenRecorder struct {
+	dir     string
+	capture func() ([]byte, error)
+	now     func() time.Time
+
+	mu        sync.Mutex
+	recording *mp4Writer
+	testCase  string
+}
+
+// startScreenRecording connects to the screenshot service of the device and lets listener record the screen while
+// each test case runs. The returned function stops recording and closes the connection to the screenshot service.
+func startScreenRecording(device ios.DeviceEntry, dir string, listener *TestListener) (func(), error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	screenshotService, err := instruments.NewScreenshotService(device)
+	if err != nil {
+		return nil, err
+	}
+	recorder := &screenRecorder{dir: dir, capture: screenshotService.TakeScreenshot, now: time.Now}
+	listener.screenRecorder = recorder
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		recorder.record(ctx)
+	}()
+	return func() {
+		cancel()
+		<-done
+		recorder.close()
+		screenshotService.Close()
+	}, nil
+}
+
+// record adds screenshots to the recording of the running test case until ctx is done
+func (r *screenRecorder) record(ctx context.Context) {
+	for ctx.Err() == nil {
+		testCase := r.runningTestCase()
+		if testCase == "" {
+			r.wait(ctx)
+			continue
+		}
+		captured := r.now()
+		screenshot, err := r.capture()
+		if err != nil {
+			log.WithError(err).Warn("failed capturing screenshot for the screen recording")
+			r.wait(ctx)
+			continue
+		}
+		frame, err := jpegFrame(screenshot)
+		if err != nil {
+			log.WithError(err).Warn("failed converting screenshot for the screen recording")
+			continue
+		}
+		r.addFrame(testCase, frame, captured)
+	}
+}
+
+func (r *screenRecorder) wait(ctx context.Context) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(screenRecordingIdleInterval):
+	}
+}
+
+// runningTestCase returns {CLASS}/{METHOD} of the test case that is recorded, or an empty string
+func (r *screenRecorder) runningTestCase() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.testCase
+}
+
+// addFrame adds frame to the recording if testCase, the test case running when the frame was captured, still runs
+func (r *screenRecorder) addFrame(testCase string, frame []byte, captured time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.recording == nil || r.testCase != testCase {
+		return
+	}
+	if err := r.recording.addFrame(frame, captured); err != nil {
+		log.WithError(err).Warn("failed writing frame of the screen recording")
+	}
+}
+
+// testStarted starts the recording <class>-<method>.mp4, the recording of a test case that did not finish is stopped
+func (r *screenRecorder) testStarted(className string, methodName string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stopRecording()
+	recording, err := newMP4Writer(filepath.Join(r.dir, testCaseFileName(className, methodName, ".mp4")))
+	if err != nil {
+		log.WithError(err).Warn("failed creating screen recording")
+		return
+	}
+	r.recording = recording
+	r.testCase = className + "/" + methodName
+}
+
+// testFinished stops the recording of testCase and adds it to the attachments of the test case. Recordings without
+// frames are removed.
+func (r *screenRecorder) testFinished(testCase *TestCase) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.recording == nil || r.testCase != testCase.ClassName+"/"+testCase.MethodName {
+		return
+	}
+	recordingPath := r.recording.file.Name()
+	frames := len(r.recording.sizes)
+	now := r.now()
+	if err := r.recording.close(now); err != nil {
+		log.WithError(err).Warn("failed writing screen recording")
+	}
+	r.recording = nil
+	r.testCase = ""
+	if frames == 0 {
+		os.Remove(recordingPath)
+		return
+	}
+	testCase.Attachments = append(testCase.Attachments, TestAttachment{
+		Name:                  "Screen Recording",
+		Path:                  recordingPath,
+		Timestamp:             float64(now.Unix()),
+		UniformTypeIdentifier: "public.mpeg-4",
+	})
+}
+
+// close stops the recording of a test case that did not finish
+func (r *screenRecorder) close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stopRecording()
+}
+
+// stopRecording finishes the current recording without attaching it, the caller must hold mu
+func (r *screenRecorder) stopRecording() {
+	if r.recording == nil {
+		return
+	}
+	if err := r.recording.close(r.now()); err != nil {
+		log.WithError(err).Warn("failed writing screen recording")
+	}
+	r.recording = nil
+	r.testCase = ""
+}
+
+// jpegFrame converts a PNG screenshot to a JPEG frame
+func jpegFrame(screenshot []byte) ([]byte, error) {
+	img, _, err := image.Decode(bytes.NewReader(screenshot))
+	if err != nil {
+		return nil, err
+	}
+	var frame bytes.Buffer
+	if err := jpeg.Encode(&frame, img, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	return frame.Bytes(), nil
+}
@@ -0,0 +1,102 @@
+package testmanagerd
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// findMP4Box returns the payload of the box at path, descending into the boxes of data
+func findMP4Box(t *testing.T, data []byte, path ...string) []byte {
+	for len(data) >= 8 {
+		size := binary.BigEndian.Uint32(data)
+		require.GreaterOrEqual(t, size, uint32(8))
+		require.LessOrEqual(t, int(size), len(data))
+		if string(data[4:8]) == path[0] {
+			if len(path) == 1 {
+				return data[8:size]
+			}
+			return findMP4Box(t, data[8:size], path[1:]...)
+		}
+		data = data[size:]
+	}
+	t.Fatalf("box %s not found", path[0])
+	return nil
+}
+
+func TestScreenRecording(t *testing.T) {
+	var screenshot bytes.Buffer
+	require.NoError(t, png.Encode(&screenshot, image.NewRGBA(image.Rect(0, 0, 30, 60))))
+	dir := t.TempDir()
+	now := time.Date(2024, 1, 16, 15, 36, 43, 0, time.UTC)
+	ctx, cancel := context.WithCancel(context.Background())
+	captures := 0
+	recorder := &screenRecorder{
+		dir: dir,
+		capture: func() ([]byte, error) {
+			captures++
+			if captures == 3 {
+				cancel()
+			}
+			return screenshot.Bytes(), nil
+		},
+		now: func() time.Time {
+			now = now.Add(40 * time.Millisecond)
+			return now
+		},
+	}
+	listener := NewTestListener(io.Discard, io.Discard, os.TempDir())
+	listener.screenRecorder = recorder
+	listener.testSuiteDidStart("LoginTests", "2024-01-16 15:36:43 +0000")
+
+	listener.testCaseDidStartForClass("LoginTests", "testLogin")
+	recorder.record(ctx)
+	listener.testCaseDidFinishForTest("LoginTests", "testLogin", "passed", 1)
+	listener.testCaseDidStartForClass("LoginTests", "testNotRecorded")
+	listener.testCaseDidFinishForTest("LoginTests", "testNotRecorded", "passed", 1)
+
+	recordingPath := filepath.Join(dir, "LoginTests-testLogin.mp4")
+	testCases := listener.runningTestSuite.TestCases
+	require.Len(t, testCases[0].Attachments, 1)
+	assert.Equal(t, recordingPath, testCases[0].Attachments[0].Path)
+	assert.Equal(t, "public.mpeg-4", testCases[0].Attachments[0].UniformTypeIdentifier)
+	assert.Empty(t, testCases[1].Attachments, "recordings without frames should be dropped")
+	assert.NoFileExists(t, filepath.Join(dir, "LoginTests-testNotRecorded.mp4"))
+
+	movie, err := os.ReadFile(recordingPath)
+	require.NoError(t, err)
+	assert.Equal(t, "isom", string(findMP4Box(t, movie, "ftyp")[:4]))
+	stbl := findMP4Box(t, movie, "moov", "trak", "mdia", "minf", "stbl")
+	sampleEntry := findMP4Box(t, findMP4Box(t, stbl, "stsd")[8:], "jpeg")
+	assert.Equal(t, uint16(30), binary.BigEndian.Uint16(sampleEntry[24:]))
+	assert.Equal(t, uint16(60), binary.BigEndian.Uint16(sampleEntry[26:]))
+
+	stsz := findMP4Box(t, stbl, "stsz")
+	require.Equal(t, uint32(3), binary.BigEndian.Uint32(stsz[8:]))
+	stco := findMP4Box(t, stbl, "stco")
+	require.Equal(t, uint32(3), binary.BigEndian.Uint32(stco[4:]))
+	for i := 0; i < 3; i++ {
+		offset := binary.BigEndian.Uint32(stco[8+4*i:])
+		size := binary.BigEndian.Uint32(stsz[12+4*i:])
+		frame := movie[offset : offset+size]
+		_, format, err := image.DecodeConfig(bytes.NewReader(frame))
+		require.NoError(t, err)
+		assert.Equal(t, "jpeg", format)
+	}
+	// the frames were captured 40ms apart and the last one is shown until the test finished
+	stts := findMP4Box(t, stbl, "stts")
+	assert.Equal(t, []uint32{1, 3, 40}, []uint32{
+		binary.BigEndian.Uint32(stts[4:]), binary.BigEndian.Uint32(stts[8:]), binary.BigEndian.Uint32(stts[12:]),
+	})
+	assert.Equal(t, uint32(120), binary.BigEndian.Uint32(findMP4Box(t, movie, "moov", "mvhd")[16:]))
+}
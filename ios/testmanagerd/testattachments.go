@@ -0,0 +1,82 @@
+package testmanagerd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WithAttachmentsDir stores every attachment of the test run in dir, in a directory per test case,
+// see TestConfig.AttachmentsDir
+func WithAttachmentsDir(dir string) XCTestRunOption {
+	return func(config *TestConfig) {
+		config.AttachmentsDir = dir
+	}
+}
+
+// storeAttachmentsByTest creates dir and lets listener store all attachments in it, in a directory per test case
+func storeAttachmentsByTest(dir string, listener *TestListener) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	listener.testAttachmentsDirectory = dir
+	return nil
+}
+
+// attachmentExtensions maps the uniform type identifiers of common XCTest attachments to their file extension
+var attachmentExtensions = map[string]string{
+	"public.png":                ".png",
+	"public.jpeg":               ".jpg",
+	"public.heic":               ".heic",
+	"public.mpeg-4":             ".mp4",
+	"com.apple.quicktime-movie": ".mov",
+	"public.plain-text":         ".txt",
+	"public.utf8-plain-text":    ".txt",
+	"public.json":               ".json",
+	"public.xml":                ".xml",
+	"public.html":               ".html",
+	"com.apple.property-list":   ".plist",
+	"com.apple.crashreport":     ".crash",
+	"com.apple.ips":             ".ips",
+}
+
+// testAttachmentPath returns a path for an attachment of the test case that does not exist yet and creates its
+// directory. The attachment is stored as <directory>/<class>/<method>/<name> with the extension of its type, and
+// a counter if the test case has multiple attachments with the same name.
+func testAttachmentPath(directory string, testCase *TestCase, name string, uniformTypeIdentifier string) (string, error) {
+	testDirectory := filepath.Join(directory, sanitizeFileName(testCase.ClassName), sanitizeFileName(testCase.MethodName))
+	if err := os.MkdirAll(testDirectory, 0o755); err != nil {
+		return "", fmt.Errorf("testAttachmentPath: %w", err)
+	}
+	name = sanitizeFileName(name)
+	if name == "" {
+		name = "attachment"
+	}
+	extension := attachmentExtensions[uniformTypeIdentifier]
+	if strings.EqualFold(filepath.Ext(name), extension) {
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	path := filepath.Join(testDirectory, name+extension)
+	for i := 2; ; i++ {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return path, nil
+		}
+		path = filepath.Join(testDirectory, fmt.Sprintf("%s-%d%s", name, i, extension))
+	}
+}
+
+// sanitizeFileName replaces the characters of name that are not allowed in file names on common file systems
+func sanitizeFileName(name string) string {
+	name = strings.TrimSuffix(strings.TrimSpace(name), "()")
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) || r < ' ' {
+			return '_'
+		}
+		return r
+	}, name)
+	if name == "." || name == ".." {
+		return "_"
+	}
+	return name
+}
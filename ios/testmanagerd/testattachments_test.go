@@ -0,0 +1,60 @@
+package testmanagerd
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/danielpaulus/go-ios/ios/nskeyedarchiver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttachmentsStoredByTest(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "attachments")
+	listener := NewTestListener(io.Discard, io.Discard, t.TempDir())
+	require.NoError(t, storeAttachmentsByTest(dir, listener))
+
+	listener.testSuiteDidStart("LoginTests", "2024-01-16 15:36:43 +0000")
+	listener.testCaseDidStartForClass("LoginTests", "testLogin()")
+	listener.testCaseFinished("LoginTests", "testLogin()", nskeyedarchiver.XCActivityRecord{
+		Title: "Automatic Screenshot",
+		Attachments: []nskeyedarchiver.XCTAttachment{
+			{Name: "Screenshot", UniformTypeIdentifier: "public.png", Payload: []byte("first")},
+			{Name: "Screenshot", UniformTypeIdentifier: "public.png", Payload: []byte("second")},
+			{Name: "app.crash", UniformTypeIdentifier: "com.apple.crashreport", Payload: []byte("crash log")},
+			{Name: "a/b", UniformTypeIdentifier: "public.data", Payload: []byte("data")},
+			{UniformTypeIdentifier: "public.plain-text", Payload: []byte("text")},
+		},
+	})
+
+	testDir := filepath.Join(dir, "LoginTests", "testLogin")
+	expected := map[string]string{
+		"Screenshot.png":   "first",
+		"Screenshot-2.png": "second",
+		"app.crash":        "crash log",
+		"a_b":              "data",
+		"attachment.txt":   "text",
+	}
+	for file, content := range expected {
+		written, err := os.ReadFile(filepath.Join(testDir, file))
+		require.NoError(t, err, file)
+		assert.Equal(t, content, string(written))
+	}
+	attachments := listener.runningTestSuite.TestCases[0].Attachments
+	require.Len(t, attachments, len(expected))
+	assert.Equal(t, filepath.Join(testDir, "Screenshot.png"), attachments[0].Path)
+}
+
+func TestAttachmentsDirKeepsSystemAttachments(t *testing.T) {
+	assert.Empty(t, TestConfig{}.xcTestConfigurationOptions())
+
+	var config TestConfig
+	WithAttachmentsDir("attachments")(&config)
+	opts := config.xcTestConfigurationOptions()
+	require.Len(t, opts, 1)
+	contents := map[string]interface{}{}
+	opts[0](contents)
+	assert.Equal(t, map[string]interface{}{"systemAttachmentLifetime": 0}, contents)
+}
@@ -0,0 +1,209 @@
+package testmanagerd
+
+import (
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/danielpaulus/go-ios/ios"
+	"github.com/danielpaulus/go-ios/ios/house_arrest"
+	"github.com/danielpaulus/go-ios/ios/installationproxy"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// injectedTestBundleDirectory is the directory in the container of the test runner injected test bundles are copied to
+const injectedTestBundleDirectory = "tmp/injectedtests"
+
+// testRunnerSuffix is the bundle id suffix of the test runners Xcode builds, f.ex. for UI test targets or WebDriverAgent
+const testRunnerSuffix = ".xctrunner"
+
+// WithTestBundleHost injects the test targets of an .xctestrun file that are not hosted by an app into the installed
+// test runner with bundleID, see TestConfig.TestBundleHost
+func WithTestBundleHost(bundleID string) XCTestRunOption {
+	return func(config *TestConfig) {
+		config.TestBundleHost = bundleID
+	}
+}
+
+// containerUploader is the part of a house_arrest connection needed to copy a bundle into an app container
+type containerUploader interface {
+	MkDir(dirPath string) error
+	SendFile(fileContents []byte, filePath string) error
+}
+
+// prepareInjectedTestBundle copies the bundle at InjectedTestBundlePath to the container of the test runner and configures
+// testConfig to inject it into the runner. It returns a function removing the bundle from the container again.
+func prepareInjectedTestBundle(testConfig *TestConfig, version *semver.Version) (func(), error) {
+	bundlePath := filepath.Clean(testConfig.InjectedTestBundlePath)
+	if err := validateTestBundle(bundlePath); err != nil {
+		return nil, err
+	}
+	if testConfig.XctestConfigName == "" {
+		testConfig.XctestConfigName = filepath.Base(bundlePath)
+	}
+	testConfig.XcTest = true
+
+	homePath, err := containerHomePath(testConfig.Device, testConfig.TestRunnerBundleId)
+	if err != nil {
+		return nil, err
+	}
+	houseArrestService, err := house_arrest.New(testConfig.Device, testConfig.TestRunnerBundleId)
+	if err != nil {
+		return nil, fmt.Errorf("cannot access the container of %s: %w", testConfig.TestRunnerBundleId, err)
+	}
+	defer houseArrestService.Close()
+
+	uploadDir := path.Join(injectedTestBundleDirectory, uuid.New().String())
+	relativeBundlePath := path.Join(uploadDir, filepath.Base(bundlePath))
+	if err := houseArrestService.MkDir(injectedTestBundleDirectory); err != nil {
+		return nil, fmt.Errorf("cannot create %s: %w", injectedTestBundleDirectory, err)
+	}
+	if err := houseArrestService.MkDir(uploadDir); err != nil {
+		return nil, fmt.Errorf("cannot create %s: %w", uploadDir, err)
+	}
+	log.WithFields(log.Fields{"bundle": bundlePath, "runner": testConfig.TestRunnerBundleId}).Info("uploading test bundle")
+	if err := uploadBundle(houseArrestService, bundlePath, relativeBundlePath); err != nil {
+		return nil, fmt.Errorf("cannot upload %s: %w", bundlePath, err)
+	}
+
+	testConfig.deviceTestBundlePath = path.Join(homePath, relativeBundlePath)
+	testConfig.Env = withTestBundleInjection(testConfig.Env, version)
+
+	device, runnerBundleID := testConfig.Device, testConfig.TestRunnerBundleId
+	return func() {
+		houseArrestService, err := house_arrest.New(device, runnerBundleID)
+		if err != nil {
+			log.WithError(err).Warn("could not remove the injected test bundle from the test runner")
+			return
+		}
+		defer houseArrestService.Close()
+		if err := houseArrestService.RemoveAll(uploadDir); err != nil {
+			log.WithError(err).Warn("could not remove the injected test bundle from the test runner")
+		}
+	}, nil
+}
+
+// findTestBundleHost returns the bundle id of an installed test runner in apps a test bundle can be injected into.
+// iOS has no xctest agent on the device that runs bare test bundles, they are always loaded into an app process,
+// and any test runner built by Xcode loads them with libXCTestBundleInject. If several are installed, the first by
+// bundle id is used.
+func findTestBundleHost(apps []installationproxy.AppInfo) (string, error) {
+	var runners []string
+	for _, app := range apps {
+		if strings.HasSuffix(app.CFBundleIdentifier, testRunnerSuffix) {
+			runners = append(runners, app.CFBundleIdentifier)
+		}
+	}
+	if len(runners) == 0 {
+		return "", fmt.Errorf("no test runner (an app with a bundle id ending in %s) is installed to run the test bundle in, install one, f.ex. the runner of a UI test target", testRunnerSuffix)
+	}
+	slices.Sort(runners)
+	return runners[0], nil
+}
+
+// discoverTestBundleHost sets TestRunnerBundleId of testConfig to an installed test runner of the device, see
+// findTestBundleHost
+func discoverTestBundleHost(testConfig *TestConfig) error {
+	installationProxy, err := installationproxy.New(testConfig.Device)
+	if err != nil {
+		return fmt.Errorf("cannot connect to installation proxy: %w", err)
+	}
+	defer installationProxy.Close()
+	apps, err := installationProxy.BrowseUserApps()
+	if err != nil {
+		return fmt.Errorf("cannot browse user apps: %w", err)
+	}
+	runner, err := findTestBundleHost(apps)
+	if err != nil {
+		return err
+	}
+	log.WithFields(log.Fields{"bundle": testConfig.InjectedTestBundlePath, "runner": runner}).Info("injecting test bundle into installed test runner")
+	testConfig.TestRunnerBundleId = runner
+	return nil
+}
+
+// validateTestBundle checks that bundlePath is a .xctest bundle directory
+func validateTestBundle(bundlePath string) error {
+	if filepath.Ext(bundlePath) != ".xctest" {
+		return fmt.Errorf("%s is not a .xctest bundle", bundlePath)
+	}
+	info, err := os.Stat(bundlePath)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", bundlePath)
+	}
+	return nil
+}
+
+// containerHomePath returns the path of the data container of the app with bundleID on the device
+func containerHomePath(device ios.DeviceEntry, bundleID string) (string, error) {
+	installationProxy, err := installationproxy.New(device)
+	if err != nil {
+		return "", fmt.Errorf("cannot connect to installation proxy: %w", err)
+	}
+	defer installationProxy.Close()
+	apps, err := installationProxy.BrowseUserApps()
+	if err != nil {
+		return "", fmt.Errorf("cannot browse user apps: %w", err)
+	}
+	info, err := getappInfo(bundleID, apps)
+	if err != nil {
+		return "", err
+	}
+	if info.homePath == "" {
+		return "", fmt.Errorf("%s has no data container", bundleID)
+	}
+	return info.homePath, nil
+}
+
+// uploadBundle copies the directory bundlePath on the host to dest in the app container, keeping its structure.
+// Symbolic links to files are copied as regular files.
+func uploadBundle(uploader containerUploader, bundlePath string, dest string) error {
+	return filepath.WalkDir(bundlePath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relative, err := filepath.Rel(bundlePath, p)
+		if err != nil {
+			return err
+		}
+		target := path.Join(dest, filepath.ToSlash(relative))
+		if d.IsDir() {
+			return uploader.MkDir(target)
+		}
+		contents, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		return uploader.SendFile(contents, target)
+	})
+}
+
+// withTestBundleInjection returns a copy of env that inserts libXCTestBundleInject into the test runner, which loads
+// the test bundle from XCTestBundlePath. Libraries already listed in DYLD_INSERT_LIBRARIES of env are kept.
+func withTestBundleInjection(env map[string]interface{}, version *semver.Version) map[string]interface{} {
+	libraries := []string{"/Developer/usr/lib/libMainThreadChecker.dylib", "/Developer/usr/lib/libXCTestBundleInject.dylib"}
+	if !version.LessThan(ios.IOS17()) {
+		libraries[1] = "/System/Developer/usr/lib/libXCTestBundleInject.dylib"
+	}
+	if existing, ok := env["DYLD_INSERT_LIBRARIES"].(string); ok && existing != "" {
+		for _, library := range strings.Split(existing, ":") {
+			if library != libraries[0] && library != libraries[1] {
+				libraries = append(libraries, library)
+			}
+		}
+	}
+	result := make(map[string]interface{}, len(env)+1)
+	maps.Copy(result, env)
+	result["DYLD_INSERT_LIBRARIES"] = strings.Join(libraries, ":")
+	return result
+}
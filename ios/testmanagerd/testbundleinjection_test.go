@@ -0,0 +1,163 @@
+package testmanagerd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Masterminds/semver"
+	"github.com/danielpaulus/go-ios/ios"
+	"github.com/danielpaulus/go-ios/ios/installationproxy"
+	"github.com/danielpaulus/go-ios/ios/nskeyedarchiver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeUploader struct {
+	dirs  []string
+	files map[string][]byte
+}
+
+func (f *fakeUploader) MkDir(dirPath string) error {
+	f.dirs = append(f.dirs, dirPath)
+	return nil
+}
+
+func (f *fakeUploader) SendFile(fileContents []byte, filePath string) error {
+	f.files[filePath] = fileContents
+	return nil
+}
+
+func TestUploadBundle(t *testing.T) {
+	bundle := filepath.Join(t.TempDir(), "UnitTests.xctest")
+	require.NoError(t, os.MkdirAll(filepath.Join(bundle, "Frameworks", "Lib.framework"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(bundle, "UnitTests"), []byte("binary"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(bundle, "Info.plist"), []byte("plist"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(bundle, "Frameworks", "Lib.framework", "Lib"), []byte("lib"), 0o644))
+
+	container := &fakeUploader{files: map[string][]byte{}}
+	require.NoError(t, uploadBundle(container, bundle, "tmp/injectedtests/1/UnitTests.xctest"))
+
+	assert.Equal(t, []string{
+		"tmp/injectedtests/1/UnitTests.xctest",
+		"tmp/injectedtests/1/UnitTests.xctest/Frameworks",
+		"tmp/injectedtests/1/UnitTests.xctest/Frameworks/Lib.framework",
+	}, container.dirs)
+	assert.Equal(t, map[string][]byte{
+		"tmp/injectedtests/1/UnitTests.xctest/UnitTests":                    []byte("binary"),
+		"tmp/injectedtests/1/UnitTests.xctest/Info.plist":                   []byte("plist"),
+		"tmp/injectedtests/1/UnitTests.xctest/Frameworks/Lib.framework/Lib": []byte("lib"),
+	}, container.files)
+}
+
+func TestValidateTestBundle(t *testing.T) {
+	dir := t.TempDir()
+	bundle := filepath.Join(dir, "UnitTests.xctest")
+	require.NoError(t, os.Mkdir(bundle, 0o755))
+	file := filepath.Join(dir, "File.xctest")
+	require.NoError(t, os.WriteFile(file, nil, 0o644))
+
+	assert.NoError(t, validateTestBundle(bundle))
+	if err := validateTestBundle(dir); assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "is not a .xctest bundle")
+	}
+	if err := validateTestBundle(file); assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "is not a directory")
+	}
+	assert.Error(t, validateTestBundle(filepath.Join(dir, "Missing.xctest")))
+}
+
+func TestWithTestBundleInjection(t *testing.T) {
+	t.Run("before iOS 17", func(t *testing.T) {
+		env := map[string]interface{}{"KEY": "value"}
+		injected := withTestBundleInjection(env, semver.MustParse("16.4"))
+
+		assert.Equal(t, map[string]interface{}{
+			"KEY":                   "value",
+			"DYLD_INSERT_LIBRARIES": "/Developer/usr/lib/libMainThreadChecker.dylib:/Developer/usr/lib/libXCTestBundleInject.dylib",
+		}, injected)
+		assert.NotContains(t, env, "DYLD_INSERT_LIBRARIES")
+	})
+	t.Run("iOS 17 keeps own libraries", func(t *testing.T) {
+		env := map[string]interface{}{"DYLD_INSERT_LIBRARIES": "/usr/lib/libCustom.dylib:/Developer/usr/lib/libMainThreadChecker.dylib"}
+		injected := withTestBundleInjection(env, semver.MustParse("17.0"))
+
+		assert.Equal(t, "/Developer/usr/lib/libMainThreadChecker.dylib:/System/Developer/usr/lib/libXCTestBundleInject.dylib:/usr/lib/libCustom.dylib", injected["DYLD_INSERT_LIBRARIES"])
+	})
+}
+
+func TestInjectedTestBundlePath(t *testing.T) {
+	config := TestConfig{XctestConfigName: "UnitTests.xctest"}
+	assert.Equal(t, "/private/var/containers/Runner.app/PlugIns/UnitTests.xctest", config.testBundlePath("/private/var/containers/Runner.app"))
+	assert.Empty(t, config.xcTestConfigurationOptions())
+
+	config.deviceTestBundlePath = "/private/var/mobile/Containers/Data/Runner/tmp/injectedtests/1/UnitTests.xctest"
+	assert.Equal(t, config.deviceTestBundlePath, config.testBundlePath("/private/var/containers/Runner.app"))
+
+	testConfig := nskeyedarchiver.NewXCTestConfiguration("UnitTests", [16]byte{}, "", "", "PlugIns/UnitTests.xctest", nil, nil, true, semver.MustParse("17.0"), config.xcTestConfigurationOptions()...)
+	archived, err := nskeyedarchiver.ArchiveXML(testConfig)
+	require.NoError(t, err)
+	assert.Contains(t, archived, "file://"+config.deviceTestBundlePath)
+	assert.NotContains(t, archived, "PlugIns/UnitTests.xctest")
+}
+
+func TestTargetWithoutHostAppIsInjected(t *testing.T) {
+	target, err := parseXCTestRunContent(t, `<?xml version="1.0" encoding="UTF-8"?>
+		<plist version="1.0">
+		<dict>
+			<key>UnitTests</key>
+			<dict>
+				<key>BlueprintName</key>
+				<string>UnitTests</string>
+				<key>IsAppHostedTestBundle</key>
+				<false/>
+				<key>TestBundlePath</key>
+				<string>__TESTROOT__/Debug-iphoneos/UnitTests.xctest</string>
+				<key>TestHostPath</key>
+				<string>__PLATFORMS__/iPhoneOS.platform/Developer/Library/Xcode/Agents/xctest</string>
+			</dict>
+			<key>__xctestrun_metadata__</key>
+			<dict>
+				<key>FormatVersion</key>
+				<integer>1</integer>
+			</dict>
+		</dict>
+		</plist>`)
+	require.NoError(t, err)
+	assert.False(t, target.isAppHosted())
+	withTestRoot := func(config *TestConfig) { config.TestRoot = "/build" }
+
+	config, err := testConfigForTarget(target, ios.DeviceEntry{}, nil, withTestRoot)
+	require.NoError(t, err)
+	assert.Empty(t, config.TestRunnerBundleId, "an installed test runner is picked when the test runs")
+	assert.Equal(t, filepath.Clean("/build/Debug-iphoneos/UnitTests.xctest"), config.InjectedTestBundlePath)
+
+	config, err = testConfigForTarget(target, ios.DeviceEntry{}, nil, withTestRoot, WithTestBundleHost("com.example.RunnerUITests.xctrunner"))
+	require.NoError(t, err)
+	assert.Equal(t, "com.example.RunnerUITests.xctrunner", config.TestRunnerBundleId)
+	assert.Equal(t, filepath.Clean("/build/Debug-iphoneos/UnitTests.xctest"), config.InjectedTestBundlePath)
+	assert.Equal(t, "UnitTests.xctest", config.XctestConfigName)
+	assert.True(t, config.XcTest)
+}
+
+func TestTargetsAreAppHostedByDefault(t *testing.T) {
+	hosted := true
+	assert.True(t, schemeData{}.isAppHosted())
+	assert.True(t, schemeData{IsAppHostedTestBundle: &hosted}.isAppHosted())
+}
+
+func TestFindTestBundleHost(t *testing.T) {
+	apps := []installationproxy.AppInfo{
+		{CFBundleIdentifier: "com.example.app"},
+		{CFBundleIdentifier: "com.facebook.WebDriverAgentRunner.xctrunner"},
+		{CFBundleIdentifier: "com.example.AppUITests.xctrunner"},
+	}
+	runner, err := findTestBundleHost(apps)
+	require.NoError(t, err)
+	assert.Equal(t, "com.example.AppUITests.xctrunner", runner)
+
+	_, err = findTestBundleHost(apps[:1])
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "no test runner")
+	}
+}
@@ -0,0 +1,44 @@
+package testmanagerd
+
+import "fmt"
+
+// WithTestConfiguration runs the test targets of the test configuration with the given name of an .xctestrun file with
+// FormatVersion 2 instead of the first one, see TestConfig.TestConfigurationName and TestConfigurationNames
+func WithTestConfiguration(name string) XCTestRunOption {
+	return func(config *TestConfig) {
+		config.TestConfigurationName = name
+	}
+}
+
+// WithTestPlan makes sure the .xctestrun file was generated for the test plan with the given name. Running a file of
+// another test plan, or one with FormatVersion 1 which has no test plan, fails before the tests are started. An empty
+// name accepts any test plan.
+func WithTestPlan(name string) XCTestRunOption {
+	return func(config *TestConfig) {
+		if name != "" {
+			config.TestPlanName = name
+		}
+	}
+}
+
+// testPlanMismatch describes why the test plan of an .xctestrun file is not the expected one
+func testPlanMismatch(actual string, expected string) error {
+	if actual == "" {
+		return fmt.Errorf("expected test plan %s, but the xctestrun file has no test plan", expected)
+	}
+	return fmt.Errorf("expected test plan %s, but the xctestrun file was generated for test plan %s", expected, actual)
+}
+
+// TestConfigurationNames returns the names of all test configurations of an .xctestrun file with FormatVersion 2 in
+// the order they are listed in the file
+func TestConfigurationNames(xctestrunFilePath string) ([]string, error) {
+	testPlan, err := ParseTestPlan(xctestrunFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("TestConfigurationNames: %w", err)
+	}
+	names := make([]string, 0, len(testPlan.Configurations))
+	for _, configuration := range testPlan.Configurations {
+		names = append(names, configuration.Name)
+	}
+	return names, nil
+}
@@ -0,0 +1,137 @@
+package testmanagerd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/danielpaulus/go-ios/ios"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const multiConfigurationXCTestRun = `<?xml version="1.0" encoding="UTF-8"?>
+<plist version="1.0">
+<dict>
+	<key>TestConfigurations</key>
+	<array>
+		<dict>
+			<key>Name</key>
+			<string>English</string>
+			<key>TestTargets</key>
+			<array>
+				<dict>
+					<key>BlueprintName</key>
+					<string>LoginUITests</string>
+					<key>TestLanguage</key>
+					<string>en</string>
+				</dict>
+			</array>
+		</dict>
+		<dict>
+			<key>Name</key>
+			<string>German</string>
+			<key>TestTargets</key>
+			<array>
+				<dict>
+					<key>BlueprintName</key>
+					<string>LoginUITests</string>
+					<key>TestLanguage</key>
+					<string>de</string>
+				</dict>
+			</array>
+		</dict>
+		<dict>
+			<key>Name</key>
+			<string>Empty</string>
+			<key>TestTargets</key>
+			<array/>
+		</dict>
+	</array>
+	<key>__xctestrun_metadata__</key>
+	<dict>
+		<key>FormatVersion</key>
+		<integer>2</integer>
+	</dict>
+</dict>
+</plist>`
+
+func TestTestConfigurationNames(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "multi.xctestrun")
+	require.NoError(t, os.WriteFile(path, []byte(multiConfigurationXCTestRun), 0o644))
+
+	names, err := TestConfigurationNames(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"English", "German", "Empty"}, names)
+}
+
+func TestSelectTestConfiguration(t *testing.T) {
+	targets, err := decodeTestTargets(strings.NewReader(multiConfigurationXCTestRun), "")
+	require.NoError(t, err)
+	assert.Equal(t, "en", targets[0].TestLanguage, "the first configuration is used by default")
+
+	targets, err = decodeTestTargets(strings.NewReader(multiConfigurationXCTestRun), "German")
+	require.NoError(t, err)
+	require.Len(t, targets, 1)
+	assert.Equal(t, "de", targets[0].TestLanguage)
+
+	_, err = decodeTestTargets(strings.NewReader(multiConfigurationXCTestRun), "French")
+	assert.EqualError(t, err, "the provided .xctestrun file does not contain the test configuration French, available configurations: English, German, Empty")
+
+	_, err = decodeTestTargets(strings.NewReader(multiConfigurationXCTestRun), "Empty")
+	assert.EqualError(t, err, "the test configuration Empty does not contain any test targets")
+}
+
+func TestSelectTestConfigurationRequiresFormatVersion2(t *testing.T) {
+	_, err := decodeTestTargets(strings.NewReader(`<?xml version="1.0" encoding="UTF-8"?>
+<plist version="1.0">
+<dict>
+	<key>LoginUITests</key>
+	<dict>
+		<key>BlueprintName</key>
+		<string>LoginUITests</string>
+	</dict>
+	<key>__xctestrun_metadata__</key>
+	<dict>
+		<key>FormatVersion</key>
+		<integer>1</integer>
+	</dict>
+</dict>
+</plist>`), "German")
+	assert.EqualError(t, err, "test configurations are only available in .xctestrun format version 2, cannot select German")
+}
+
+func TestWithTestConfiguration(t *testing.T) {
+	var config TestConfig
+	WithTestConfiguration("German")(&config)
+	assert.Equal(t, "German", config.TestConfigurationName)
+}
+
+func TestTestPlanOfTargets(t *testing.T) {
+	targets, err := decodeTestTargets(strings.NewReader(xcTestRunFileFormatVersion2), "")
+	require.NoError(t, err)
+	assert.Equal(t, "RunnerUITests", targets[0].TestPlanName)
+	assert.True(t, targets[0].TestPlanIsDefault)
+
+	config, err := testConfigForTarget(targets[0], ios.DeviceEntry{}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "RunnerUITests", config.TestPlanName)
+	assert.True(t, config.TestPlanIsDefault)
+}
+
+func TestWithTestPlan(t *testing.T) {
+	targets, err := decodeTestTargets(strings.NewReader(xcTestRunFileFormatVersion2), "")
+	require.NoError(t, err)
+
+	_, err = testConfigForTarget(targets[0], ios.DeviceEntry{}, nil, WithTestPlan("RunnerUITests"))
+	assert.NoError(t, err)
+	_, err = testConfigForTarget(targets[0], ios.DeviceEntry{}, nil, WithTestPlan(""))
+	assert.NoError(t, err, "an empty name accepts any test plan")
+
+	_, err = testConfigForTarget(targets[0], ios.DeviceEntry{}, nil, WithTestPlan("SmokeTests"))
+	assert.EqualError(t, err, "StartXCTestWithConfig: expected test plan SmokeTests, but the xctestrun file was generated for test plan RunnerUITests")
+
+	_, err = testConfigForTarget(schemeData{TestHostBundleIdentifier: "com.example.Runner"}, ios.DeviceEntry{}, nil, WithTestPlan("SmokeTests"))
+	assert.EqualError(t, err, "StartXCTestWithConfig: expected test plan SmokeTests, but the xctestrun file has no test plan")
+}
@@ -0,0 +1,156 @@
+package testmanagerd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/danielpaulus/go-ios/ios/crashreport"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// crashReportGracePeriod is how long after a test case failed a crash report written by the device is still
+	// assigned to it. ReportCrash needs a few seconds to write the report after the process died.
+	crashReportGracePeriod = 30 * time.Second
+	// crashReportClockTolerance is the difference between the clocks of the host and the device that is accepted
+	// when comparing the times of crash reports to the times of test cases
+	crashReportClockTolerance = 5 * time.Second
+)
+
+// WithCrashReports downloads the crash reports the test runner and the app under test produce while a test fails
+// or crashes to dir and attaches them to the test case. If dir is empty, the crashreports/ directory of the
+// OutputDir is used, see TestConfig.CrashReports
+func WithCrashReports(dir string) XCTestRunOption {
+	return func(config *TestConfig) {
+		config.CrashReports = true
+		if dir != "" {
+			config.CrashReportDir = dir
+		}
+	}
+}
+
+// testCaseWindow is the time a test case ran. end is zero if the test case never finished, f.ex. because the test
+// runner crashed
+type testCaseWindow struct {
+	start time.Time
+	end   time.Time
+}
+
+// crashReportHarvester records when each test case ran and assigns the crash reports that were written during the
+// test run to the failed test cases after the run
+type crashReportHarvester struct {
+	dir        string
+	processes  []string
+	runStarted time.Time
+	now        func() time.Time
+	list       func(pattern string, since time.Time) ([]crashreport.Report, error)
+	download   func(name string, targetPath string) error
+	windows    map[string]*testCaseWindow
+}
+
+// startCrashReportHarvesting lets the listener of config record the test case times. The returned function
+// downloads the crash reports of the test runner and the app under test that belong to failed test cases and adds
+// them to the attachments of these test cases.
+func startCrashReportHarvesting(config TestConfig) (func(), error) {
+	if config.CrashReportDir == "" {
+		return nil, errors.New("no directory for the crash reports, set CrashReportDir or OutputDir")
+	}
+	if err := os.MkdirAll(config.CrashReportDir, 0o755); err != nil {
+		return nil, err
+	}
+	processes, err := testProcessNames(config)
+	if err != nil {
+		return nil, err
+	}
+	device, listener := config.Device, config.Listener
+	harvester := &crashReportHarvester{
+		dir:        config.CrashReportDir,
+		processes:  processes,
+		runStarted: time.Now(),
+		now:        time.Now,
+		list: func(pattern string, since time.Time) ([]crashreport.Report, error) {
+			return crashreport.ListReportsSince(device, pattern, since)
+		},
+		download: func(name string, targetPath string) error {
+			return crashreport.DownloadReport(device, name, targetPath)
+		},
+	}
+	listener.crashReports = harvester
+	return func() {
+		harvester.harvest(listener.TestSuites)
+	}, nil
+}
+
+func (h *crashReportHarvester) testStarted(className string, methodName string) {
+	if h.windows == nil {
+		h.windows = map[string]*testCaseWindow{}
+	}
+	h.windows[className+"/"+methodName] = &testCaseWindow{start: h.now()}
+}
+
+func (h *crashReportHarvester) testFinished(testCase *TestCase) {
+	if window, ok := h.windows[testCase.ClassName+"/"+testCase.MethodName]; ok {
+		window.end = h.now()
+	}
+}
+
+// harvest downloads the crash reports written since the run started and attaches each of them to the failed test
+// case of suites that ran when the report was written. Reports that do not belong to a failed test case are ignored.
+func (h *crashReportHarvester) harvest(suites []TestSuite) {
+	var reports []crashreport.Report
+	for _, process := range h.processes {
+		processReports, err := h.list(process+"*", h.runStarted.Add(-crashReportClockTolerance))
+		if err != nil {
+			log.WithFields(log.Fields{"error": err, "process": process}).Warn("could not list crash reports")
+			continue
+		}
+		reports = append(reports, processReports...)
+	}
+	for _, report := range reports {
+		testCase := h.failedTestCaseAt(suites, report.ModTime)
+		if testCase == nil {
+			log.WithField("report", report.Name).Debug("crash report does not belong to a failed test")
+			continue
+		}
+		reportPath := filepath.Join(h.dir, testCaseFileName(testCase.ClassName, testCase.MethodName, "-"+report.Name))
+		if err := h.download(report.Name, reportPath); err != nil {
+			log.WithFields(log.Fields{"error": err, "report": report.Name}).Warn("could not download crash report")
+			continue
+		}
+		log.WithFields(log.Fields{"report": report.Name, "test": testCase.ClassName + "/" + testCase.MethodName}).Info("downloaded crash report of failed test")
+		testCase.Attachments = append(testCase.Attachments, TestAttachment{
+			Name:                  report.Name,
+			Path:                  reportPath,
+			Timestamp:             float64(report.ModTime.Unix()),
+			UniformTypeIdentifier: "com.apple.crashreport",
+		})
+	}
+}
+
+// failedTestCaseAt returns the failed, stalled or crashed test case of suites that was the last one to start before
+// t, if t is not later than crashReportGracePeriod after the test case finished
+func (h *crashReportHarvester) failedTestCaseAt(suites []TestSuite, t time.Time) *TestCase {
+	var match *TestCase
+	var matchStart time.Time
+	for i := range suites {
+		for j := range suites[i].TestCases {
+			testCase := &suites[i].TestCases[j]
+			if testCase.Status != StatusFailed && testCase.Status != StatusStalled && testCase.Status != StatusCrashed {
+				continue
+			}
+			window, ok := h.windows[testCase.ClassName+"/"+testCase.MethodName]
+			if !ok || window.start.Add(-crashReportClockTolerance).After(t) {
+				continue
+			}
+			if !window.end.IsZero() && t.After(window.end.Add(crashReportGracePeriod)) {
+				continue
+			}
+			if match == nil || window.start.After(matchStart) {
+				match, matchStart = testCase, window.start
+			}
+		}
+	}
+	return match
+}
@@ -0,0 +1,103 @@
+package testmanagerd
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/danielpaulus/go-ios/ios"
+	"github.com/danielpaulus/go-ios/ios/crashreport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCrashReportHarvesting(t *testing.T) {
+	dir := t.TempDir()
+	runStarted := time.Date(2024, 1, 16, 15, 36, 43, 0, time.UTC)
+	now := runStarted
+	var patterns []string
+	harvester := &crashReportHarvester{
+		dir:        dir,
+		processes:  []string{"UITests-Runner", "MyApp"},
+		runStarted: runStarted,
+		now:        func() time.Time { return now },
+		list: func(pattern string, since time.Time) ([]crashreport.Report, error) {
+			patterns = append(patterns, pattern)
+			assert.Equal(t, runStarted.Add(-crashReportClockTolerance), since)
+			if pattern != "MyApp*" {
+				return nil, nil
+			}
+			return []crashreport.Report{
+				// written while testLogin failed
+				{Name: "MyApp-2024-01-16-153650.ips", ModTime: runStarted.Add(7 * time.Second)},
+				// written while the passing testLogout ran, shortly after testLogin failed
+				{Name: "MyApp-2024-01-16-153705.ips", ModTime: runStarted.Add(22 * time.Second)},
+				// written long after testLogin failed while no other test failed
+				{Name: "MyApp-2024-01-16-153900.ips", ModTime: runStarted.Add(137 * time.Second)},
+			}, nil
+		},
+		download: func(name string, targetPath string) error {
+			return os.WriteFile(targetPath, []byte(name), 0o644)
+		},
+	}
+	listener := NewTestListener(io.Discard, io.Discard, os.TempDir())
+	listener.crashReports = harvester
+	runTest := func(method string, status string, duration time.Duration) {
+		listener.testCaseDidStartForClass("LoginTests", method)
+		now = now.Add(duration)
+		if status == "failed" {
+			listener.testCaseFailedForClass("LoginTests", method, "crashed", "LoginTests.swift", 1)
+		}
+		listener.testCaseDidFinishForTest("LoginTests", method, status, duration.Seconds())
+	}
+	listener.testSuiteDidStart("LoginTests", "2024-01-16 15:36:43 +0000")
+	now = now.Add(5 * time.Second)
+	runTest("testLogin", "failed", 5*time.Second)
+	runTest("testLogout", "passed", 100*time.Second)
+	listener.testSuiteFinished("LoginTests", "2024-01-16 15:38:43 +0000", 2, 1, 0, 0, 0, 0, 105, 105)
+
+	harvester.harvest(listener.TestSuites)
+
+	assert.Equal(t, []string{"UITests-Runner*", "MyApp*"}, patterns)
+	testCases := listener.TestSuites[0].TestCases
+	require.Len(t, testCases[0].Attachments, 2)
+	assert.Equal(t, filepath.Join(dir, "LoginTests-testLogin-MyApp-2024-01-16-153650.ips"), testCases[0].Attachments[0].Path)
+	assert.Equal(t, "MyApp-2024-01-16-153705.ips", testCases[0].Attachments[1].Name)
+	assert.Equal(t, "com.apple.crashreport", testCases[0].Attachments[1].UniformTypeIdentifier)
+	assert.Empty(t, testCases[1].Attachments)
+	assert.FileExists(t, testCases[0].Attachments[1].Path)
+	assert.NoFileExists(t, filepath.Join(dir, "LoginTests-testLogin-MyApp-2024-01-16-153900.ips"))
+}
+
+func TestCrashReportOfCrashedTestCase(t *testing.T) {
+	started := time.Date(2024, 1, 16, 15, 36, 43, 0, time.UTC)
+	harvester := &crashReportHarvester{now: func() time.Time { return started }}
+	harvester.testStarted("LoginTests", "testLogin")
+	suites := []TestSuite{{TestCases: []TestCase{{ClassName: "LoginTests", MethodName: "testLogin", Status: StatusCrashed}}}}
+
+	assert.Equal(t, &suites[0].TestCases[0], harvester.failedTestCaseAt(suites, started.Add(10*time.Minute)),
+		"a test case that never finished should get the reports written after it started")
+	assert.Nil(t, harvester.failedTestCaseAt(suites, started.Add(-time.Minute)))
+}
+
+func TestFindCrashReportsOfSession(t *testing.T) {
+	defer func(original func(ios.DeviceEntry, string, time.Time) ([]crashreport.Report, error)) {
+		listCrashReportsSince = original
+	}(listCrashReportsSince)
+	sessionStarted := time.Date(2024, 1, 16, 15, 36, 43, 0, time.UTC)
+	var pattern string
+	var since time.Time
+	listCrashReportsSince = func(device ios.DeviceEntry, p string, s time.Time) ([]crashreport.Report, error) {
+		pattern, since = p, s
+		return []crashreport.Report{{Name: "MyAppUITests-Runner-2024-01-16-153700.ips", ModTime: sessionStarted.Add(17 * time.Second)}}, nil
+	}
+
+	reports := findCrashReports(ios.DeviceEntry{}, "MyAppUITests-Runner", sessionStarted)
+
+	assert.Equal(t, []string{"MyAppUITests-Runner-2024-01-16-153700.ips"}, reports)
+	assert.Equal(t, "MyAppUITests-Runner*", pattern)
+	assert.Equal(t, sessionStarted.Add(-crashReportClockTolerance), since, "reports of previous runs are not listed")
+	assert.Nil(t, findCrashReports(ios.DeviceEntry{}, "", sessionStarted))
+}
@@ -0,0 +1,131 @@
+package testmanagerd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// WithTestEnvironment runs the tests selected by identifier in a separate session of the test runner that has env
+// added to its environment, see TestConfig.TestEnvironmentOverrides
+func WithTestEnvironment(identifier string, env map[string]any) XCTestRunOption {
+	return func(config *TestConfig) {
+		if config.TestEnvironmentOverrides == nil {
+			config.TestEnvironmentOverrides = map[string]map[string]any{}
+		}
+		config.TestEnvironmentOverrides[identifier] = env
+	}
+}
+
+// runTestWithEnvironmentOverrides runs each session of testEnvironmentSessions after another and returns the
+// results of all of them
+func runTestWithEnvironmentOverrides(ctx context.Context, testConfig TestConfig) ([]TestSuite, error) {
+	if testConfig.Listener == nil {
+		testConfig.Listener = NewTestListener(io.Discard, io.Discard, "")
+	}
+	var errs []error
+	for i, session := range testEnvironmentSessions(testConfig) {
+		if i > 0 {
+			testConfig.Listener.restartSession()
+		}
+		if _, err := RunTestWithConfig(ctx, session); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return testConfig.Listener.TestSuites, errors.Join(errs...)
+}
+
+// testEnvironmentSessions splits the test run into one session with all tests that have no environment override,
+// followed by a session for each test identifier in TestEnvironmentOverrides that runs only the selected tests with
+// the overridden environment. If overrides overlap, f.ex. for a class and one of its methods, the tests of the more
+// specific override run only in its session. Overrides of tests that are not selected by TestsToRun or that are
+// skipped by TestsToSkip get no session.
+func testEnvironmentSessions(testConfig TestConfig) []TestConfig {
+	overrides := map[string]map[string]any{}
+	for identifier, env := range testConfig.TestEnvironmentOverrides {
+		overrides[NormalizeTestIdentifier(identifier)] = env
+	}
+	identifiers := make([]string, 0, len(overrides))
+	for identifier := range overrides {
+		identifiers = append(identifiers, identifier)
+	}
+	sort.Strings(identifiers)
+
+	base := testConfig
+	base.TestEnvironmentOverrides = nil
+	testsToRun := NormalizeTestIdentifiers(base.TestsToRun)
+	testsToSkip := NormalizeTestIdentifiers(base.TestsToSkip)
+
+	var sessions []TestConfig
+	remaining := base
+	if len(base.TestsToRun) > 0 {
+		remaining.TestsToRun = slices.DeleteFunc(slices.Clone(testsToRun), func(test string) bool {
+			return slices.ContainsFunc(identifiers, func(identifier string) bool { return includesTest(identifier, test) })
+		})
+	}
+	// if all selected tests are overridden, an empty TestsToRun would run all tests
+	if len(base.TestsToRun) == 0 || len(remaining.TestsToRun) > 0 {
+		remaining.TestsToSkip = append(slices.Clone(base.TestsToSkip), identifiers...)
+		sessions = append(sessions, remaining)
+	}
+
+	for _, identifier := range identifiers {
+		if slices.ContainsFunc(testsToSkip, func(skipped string) bool { return includesTest(skipped, identifier) }) {
+			continue
+		}
+		// tests of a more specific override, f.ex. a method of an overridden class, run only in its own session
+		moreSpecific := slices.DeleteFunc(slices.Clone(identifiers), func(other string) bool {
+			return other == identifier || !includesTest(identifier, other)
+		})
+		selected := slices.DeleteFunc(selectedTests(identifier, testsToRun), func(test string) bool {
+			return slices.ContainsFunc(moreSpecific, func(other string) bool { return includesTest(other, test) })
+		})
+		if len(selected) == 0 {
+			continue
+		}
+		session := base
+		session.TestsToRun = selected
+		session.TestsToSkip = append(slices.Clone(base.TestsToSkip), moreSpecific...)
+		session.Env = maps.Clone(base.Env)
+		if session.Env == nil {
+			session.Env = map[string]any{}
+		}
+		maps.Copy(session.Env, overrides[identifier])
+		sessions = append(sessions, session)
+	}
+	return sessions
+}
+
+// selectedTests returns the tests of identifier that testsToRun selects. That is identifier itself if testsToRun
+// is empty or contains it or its class, otherwise the tests of testsToRun that belong to identifier.
+func selectedTests(identifier string, testsToRun []string) []string {
+	if len(testsToRun) == 0 || slices.ContainsFunc(testsToRun, func(test string) bool { return includesTest(test, identifier) }) {
+		return []string{identifier}
+	}
+	var selected []string
+	for _, test := range testsToRun {
+		if includesTest(identifier, test) {
+			selected = append(selected, test)
+		}
+	}
+	return selected
+}
+
+// includesTest returns true if test is the test identifier or a test method of the class identifier
+func includesTest(identifier string, test string) bool {
+	return test == identifier || strings.HasPrefix(test, identifier+"/")
+}
+
+// restartSession prepares the listener for another session of the test runner. The results of the previous
+// sessions are kept.
+func (t *TestListener) restartSession() {
+	t.finished = make(chan struct{})
+	t.finishedOnce = sync.Once{}
+	t.err = nil
+	t.runningTestSuite = nil
+}
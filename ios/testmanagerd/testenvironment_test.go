@@ -0,0 +1,108 @@
+package testmanagerd
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTestEnvironmentSessions(t *testing.T) {
+	config := TestConfig{
+		TestRunnerBundleId: "com.example.RunnerUITests.xctrunner",
+		Env:                map[string]any{"TERM": "dumb", "API_URL": "https://prod.example.com"},
+		TestsToSkip:        []string{"FlakyTests"},
+	}
+	WithTestEnvironment("CheckoutTests.testPayment", map[string]any{"API_URL": "https://staging.example.com"})(&config)
+
+	sessions := testEnvironmentSessions(config)
+
+	assert.Len(t, sessions, 2)
+	assert.Nil(t, sessions[0].TestsToRun)
+	assert.Equal(t, []string{"FlakyTests", "CheckoutTests/testPayment"}, sessions[0].TestsToSkip)
+	assert.Equal(t, "https://prod.example.com", sessions[0].Env["API_URL"])
+
+	assert.Equal(t, []string{"CheckoutTests/testPayment"}, sessions[1].TestsToRun)
+	assert.Equal(t, map[string]any{"TERM": "dumb", "API_URL": "https://staging.example.com"}, sessions[1].Env, "the targeted test should receive the override")
+	assert.Nil(t, sessions[1].TestEnvironmentOverrides)
+	assert.Equal(t, "https://prod.example.com", config.Env["API_URL"], "the environment of the run must not be modified")
+}
+
+func TestTestEnvironmentSessionsWithOnlyOverriddenTests(t *testing.T) {
+	config := TestConfig{TestsToRun: []string{"CheckoutTests/testPayment"}}
+	WithTestEnvironment("CheckoutTests/testPayment", map[string]any{"DEBUG": "1"})(&config)
+
+	sessions := testEnvironmentSessions(config)
+
+	assert.Len(t, sessions, 1, "no session without tests should be started, as it would run all tests")
+	assert.Equal(t, map[string]any{"DEBUG": "1"}, sessions[0].Env)
+}
+
+func TestTestEnvironmentSessionsOnlyForSelectedTests(t *testing.T) {
+	config := TestConfig{
+		TestsToRun:  []string{"LoginTests", "CheckoutTests/testPayment", "CheckoutTests/testRefund"},
+		TestsToSkip: []string{"LoginTests/testLogout", "FlakyTests"},
+	}
+	WithTestEnvironment("LoginTests/testLogin", map[string]any{"DEBUG": "1"})(&config)
+	WithTestEnvironment("LoginTests/testLogout", map[string]any{"DEBUG": "1"})(&config)
+	WithTestEnvironment("CheckoutTests", map[string]any{"DEBUG": "1"})(&config)
+	WithTestEnvironment("FlakyTests/testRetry", map[string]any{"DEBUG": "1"})(&config)
+	WithTestEnvironment("SearchTests/testSearch", map[string]any{"DEBUG": "1"})(&config)
+
+	sessions := testEnvironmentSessions(config)
+
+	assert.Len(t, sessions, 3, "skipped and not selected tests must not get a session")
+	assert.Equal(t, []string{"LoginTests"}, sessions[0].TestsToRun)
+	assert.Equal(t, []string{"CheckoutTests/testPayment", "CheckoutTests/testRefund"}, sessions[1].TestsToRun, "only the selected tests of the overridden class should run")
+	assert.Equal(t, []string{"LoginTests/testLogin"}, sessions[2].TestsToRun)
+	for _, session := range sessions[1:] {
+		assert.Equal(t, config.TestsToSkip, session.TestsToSkip)
+	}
+}
+
+func TestTestEnvironmentSessionsWithOverlappingOverrides(t *testing.T) {
+	config := TestConfig{TestsToSkip: []string{"FlakyTests"}}
+	WithTestEnvironment("CheckoutTests", map[string]any{"API_URL": "https://staging.example.com"})(&config)
+	WithTestEnvironment("CheckoutTests/testPayment", map[string]any{"API_URL": "https://payments.example.com"})(&config)
+
+	sessions := testEnvironmentSessions(config)
+
+	assert.Len(t, sessions, 3)
+	assert.Equal(t, []string{"FlakyTests", "CheckoutTests", "CheckoutTests/testPayment"}, sessions[0].TestsToSkip)
+	assert.Equal(t, []string{"CheckoutTests"}, sessions[1].TestsToRun)
+	assert.Equal(t, []string{"FlakyTests", "CheckoutTests/testPayment"}, sessions[1].TestsToSkip, "the method must not run again in the session of its class")
+	assert.Equal(t, "https://staging.example.com", sessions[1].Env["API_URL"])
+	assert.Equal(t, []string{"CheckoutTests/testPayment"}, sessions[2].TestsToRun)
+	assert.Equal(t, []string{"FlakyTests"}, sessions[2].TestsToSkip)
+	assert.Equal(t, "https://payments.example.com", sessions[2].Env["API_URL"])
+}
+
+func TestTestEnvironmentSessionsWithOverlappingOverridesOfSelectedTests(t *testing.T) {
+	config := TestConfig{TestsToRun: []string{"CheckoutTests/testPayment", "CheckoutTests/testRefund"}}
+	WithTestEnvironment("CheckoutTests", map[string]any{"DEBUG": "1"})(&config)
+	WithTestEnvironment("CheckoutTests/testPayment", map[string]any{"DEBUG": "2"})(&config)
+
+	sessions := testEnvironmentSessions(config)
+
+	assert.Len(t, sessions, 2)
+	assert.Equal(t, []string{"CheckoutTests/testRefund"}, sessions[0].TestsToRun)
+	assert.Equal(t, "1", sessions[0].Env["DEBUG"])
+	assert.Equal(t, []string{"CheckoutTests/testPayment"}, sessions[1].TestsToRun)
+	assert.Equal(t, "2", sessions[1].Env["DEBUG"])
+}
+
+func TestListenerRestartSessionKeepsResults(t *testing.T) {
+	listener := NewTestListener(io.Discard, io.Discard, t.TempDir())
+	listener.testSuiteDidStart("LoginTests", "2024-01-16 15:36:43 +0000")
+	listener.testSuiteFinished("LoginTests", "2024-01-16 15:36:45 +0000", 0, 0, 0, 0, 0, 0, 1, 2)
+	listener.didFinishExecutingTestPlan()
+
+	listener.restartSession()
+
+	select {
+	case <-listener.Done():
+		t.Fatal("a restarted session must not be finished")
+	default:
+	}
+	assert.Len(t, listener.TestSuites, 1)
+}
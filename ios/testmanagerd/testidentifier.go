@@ -0,0 +1,218 @@
+package testmanagerd
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// NormalizeTestIdentifier converts a test identifier into the form {PRODUCT_MODULE_NAME}.{CLASS}/{METHOD} that is
+// expected by XCTest. Identifiers are often written in dot notation like 'LoginTests.testLogin' which XCTest would
+// interpret as the class 'testLogin' in the module 'LoginTests'. If the identifier does not contain a slash and the last
+// dot separated component starts with a lower case letter, it is treated as the method name.
+// Trailing parentheses of Swift method names like 'testLogin()' are removed.
+func NormalizeTestIdentifier(identifier string) string {
+	identifier = strings.TrimSpace(identifier)
+	identifier = strings.TrimSuffix(identifier, "()")
+	if strings.Contains(identifier, "/") {
+		return identifier
+	}
+	i := strings.LastIndex(identifier, ".")
+	if i < 0 {
+		return identifier
+	}
+	method := identifier[i+1:]
+	first, _ := utf8.DecodeRuneInString(method)
+	if !unicode.IsLower(first) {
+		return identifier
+	}
+	return identifier[:i] + "/" + method
+}
+
+// NormalizeTestIdentifiers applies NormalizeTestIdentifier to all identifiers. A nil slice is returned unchanged
+// as it means that no test filter is applied.
+func NormalizeTestIdentifiers(identifiers []string) []string {
+	if identifiers == nil {
+		return nil
+	}
+	normalized := make([]string, len(identifiers))
+	for i, identifier := range identifiers {
+		normalized[i] = NormalizeTestIdentifier(identifier)
+	}
+	return normalized
+}
+
+// TestIdentifiersForClasses builds OnlyTestIdentifiers that select all test methods of the given classes.
+// enumeratedTests are the identifiers of all tests of the test bundle in the form {CLASS}/{METHOD} or
+// {PRODUCT_MODULE_NAME}.{CLASS}/{METHOD}. Each class is expanded to the identifiers of its enumerated methods,
+// classes may be given with or without the module name. Classes without enumerated methods and all classes if
+// enumeratedTests is nil are passed through as class level identifiers, which XCTest expands itself.
+func TestIdentifiersForClasses(classNames []string, enumeratedTests []string) []string {
+	identifiers := []string{}
+	for _, className := range classNames {
+		className = strings.TrimSpace(className)
+		if className == "" {
+			continue
+		}
+		var methods []string
+		for _, test := range NormalizeTestIdentifiers(enumeratedTests) {
+			testClass, _, found := strings.Cut(test, "/")
+			if !found {
+				continue
+			}
+			if testClass == className || stripModuleName(testClass) == className || testClass == stripModuleName(className) {
+				methods = append(methods, test)
+			}
+		}
+		if len(methods) == 0 {
+			identifiers = append(identifiers, className)
+			continue
+		}
+		identifiers = append(identifiers, methods...)
+	}
+	return identifiers
+}
+
+// ExpandTestIdentifiers expands the identifiers containing wildcards to the enumerated tests they match, as XCTest
+// only accepts exact identifiers. Wildcards are the patterns of path.Match and can be used for the class, f.ex.
+// 'Login*', and the method, f.ex. 'LoginTests/test*Login'. Classes match with or without the module name.
+// A pattern without method is expanded to the class level identifiers of the matching classes, so that XCTest also
+// runs the tests of these classes that are not enumerated. enumeratedTests are identifiers in the form
+// {CLASS}/{METHOD} or {PRODUCT_MODULE_NAME}.{CLASS}/{METHOD}.
+// Identifiers without wildcards and patterns that match no test are returned unchanged, duplicates are removed.
+// A nil slice is returned unchanged.
+func ExpandTestIdentifiers(identifiers []string, enumeratedTests []string) []string {
+	if identifiers == nil {
+		return nil
+	}
+	enumerated := NormalizeTestIdentifiers(enumeratedTests)
+	expanded := []string{}
+	add := func(identifier string) {
+		if !slices.Contains(expanded, identifier) {
+			expanded = append(expanded, identifier)
+		}
+	}
+	for _, identifier := range NormalizeTestIdentifiers(identifiers) {
+		if !hasWildcard(identifier) {
+			add(identifier)
+			continue
+		}
+		classPattern, methodPattern, hasMethod := strings.Cut(identifier, "/")
+		matched := false
+		for _, test := range enumerated {
+			class, method, found := strings.Cut(test, "/")
+			if !found || !matchClass(classPattern, class) {
+				continue
+			}
+			if !hasMethod {
+				add(class)
+				matched = true
+				continue
+			}
+			if ok, _ := path.Match(methodPattern, method); ok {
+				add(test)
+				matched = true
+			}
+		}
+		if !matched {
+			log.WithField("identifier", identifier).Warn("test identifier matches no enumerated test")
+			add(identifier)
+		}
+	}
+	return expanded
+}
+
+// expandTestIdentifiers expands the wildcards of TestsToRun and TestsToSkip of config with ExpandTestIdentifiers.
+// The tests are enumerated from the test bundle on the host if config has no EnumeratedTests.
+func expandTestIdentifiers(config *TestConfig) error {
+	if !slices.ContainsFunc(config.TestsToRun, hasWildcard) && !slices.ContainsFunc(config.TestsToSkip, hasWildcard) {
+		return nil
+	}
+	enumerated := config.EnumeratedTests
+	if enumerated == nil {
+		bundlePath := config.hostTestBundlePath
+		if config.InjectedTestBundlePath != "" {
+			bundlePath = config.InjectedTestBundlePath
+		}
+		if bundlePath == "" {
+			return errors.New("test identifiers with wildcards need the test bundle on the host or EnumeratedTests")
+		}
+		classes, err := ListTests(bundlePath)
+		if err != nil {
+			return fmt.Errorf("cannot enumerate tests to expand test identifiers with wildcards: %w", err)
+		}
+		enumerated = TestIdentifiersOf(classes)
+	}
+	config.TestsToRun = ExpandTestIdentifiers(config.TestsToRun, enumerated)
+	config.TestsToSkip = ExpandTestIdentifiers(config.TestsToSkip, enumerated)
+	config.EnumeratedTests = enumerated
+	return nil
+}
+
+// hasWildcard returns whether identifier contains a pattern of path.Match
+func hasWildcard(identifier string) bool {
+	return strings.ContainsAny(identifier, "*?[")
+}
+
+// matchClass returns whether the class pattern matches class, with or without the module name of either of them
+func matchClass(pattern string, class string) bool {
+	for _, candidate := range [][2]string{{pattern, class}, {pattern, stripModuleName(class)}, {stripModuleName(pattern), class}} {
+		if ok, _ := path.Match(candidate[0], candidate[1]); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// stripModuleName removes the {PRODUCT_MODULE_NAME} prefix of a class identifier
+func stripModuleName(class string) string {
+	return class[strings.LastIndex(class, ".")+1:]
+}
+
+// NotExecutedTests returns the enumerated tests that have no result in suites, f.ex. because the test runner
+// crashed before they were started. Tests that were skipped have a result and are not returned.
+// enumeratedTests are identifiers in the form {CLASS}/{METHOD} or {PRODUCT_MODULE_NAME}.{CLASS}/{METHOD} and are
+// returned normalized in their original order.
+func NotExecutedTests(enumeratedTests []string, suites []TestSuite) []string {
+	executed := map[string]struct{}{}
+	for _, suite := range suites {
+		for _, testCase := range suite.TestCases {
+			executed[stripModuleName(testCase.ClassName)+"/"+strings.TrimSuffix(testCase.MethodName, "()")] = struct{}{}
+		}
+	}
+	notExecuted := []string{}
+	for _, test := range NormalizeTestIdentifiers(enumeratedTests) {
+		class, method, found := strings.Cut(test, "/")
+		if !found {
+			continue
+		}
+		if _, ok := executed[stripModuleName(class)+"/"+method]; !ok {
+			notExecuted = append(notExecuted, test)
+		}
+	}
+	return notExecuted
+}
+
+// TestIdentifiersFromOtherModules returns the identifiers whose {PRODUCT_MODULE_NAME} is not moduleName. XCTest
+// silently ignores such identifiers, f.ex. when tests of another test target were listed by mistake.
+// Identifiers without a module name can not be checked and are never returned.
+func TestIdentifiersFromOtherModules(identifiers []string, moduleName string) []string {
+	mismatches := []string{}
+	if moduleName == "" {
+		return mismatches
+	}
+	for _, identifier := range NormalizeTestIdentifiers(identifiers) {
+		class, _, _ := strings.Cut(identifier, "/")
+		module, _, found := strings.Cut(class, ".")
+		if found && module != moduleName {
+			mismatches = append(mismatches, identifier)
+		}
+	}
+	return mismatches
+}
@@ -0,0 +1,156 @@
+package testmanagerd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeTestIdentifier(t *testing.T) {
+	tests := map[string]string{
+		"LoginTests/testLogin":               "LoginTests/testLogin",
+		"LoginTests.testLogin":               "LoginTests/testLogin",
+		"LoginTests.testLogin()":             "LoginTests/testLogin",
+		"RunnerUITests.LoginTests/testLogin": "RunnerUITests.LoginTests/testLogin",
+		"RunnerUITests.LoginTests.testLogin": "RunnerUITests.LoginTests/testLogin",
+		"RunnerUITests.LoginTests":           "RunnerUITests.LoginTests",
+		"LoginTests":                         "LoginTests",
+		" LoginTests.testLogin ":             "LoginTests/testLogin",
+	}
+	for identifier, expected := range tests {
+		assert.Equal(t, expected, NormalizeTestIdentifier(identifier), "normalizing %s", identifier)
+	}
+}
+
+func TestNormalizeTestIdentifiersKeepsNil(t *testing.T) {
+	assert.Nil(t, NormalizeTestIdentifiers(nil))
+	assert.Equal(t, []string{"LoginTests/testLogin", "LoginTests/testLogout"}, NormalizeTestIdentifiers([]string{"LoginTests.testLogin", "LoginTests/testLogout"}))
+}
+
+func TestTestIdentifiersForClasses(t *testing.T) {
+	enumerated := []string{
+		"LoginTests/testLogin",
+		"LoginTests/testLogout()",
+		"RunnerUITests.CartTests/testCheckout",
+		"RunnerUITests.CartTests/testEmptyCart",
+		"SettingsTests/testDarkMode",
+	}
+
+	t.Run("expands classes to their enumerated methods", func(t *testing.T) {
+		identifiers := TestIdentifiersForClasses([]string{"LoginTests", "CartTests"}, enumerated)
+
+		assert.Equal(t, []string{
+			"LoginTests/testLogin",
+			"LoginTests/testLogout",
+			"RunnerUITests.CartTests/testCheckout",
+			"RunnerUITests.CartTests/testEmptyCart",
+		}, identifiers)
+	})
+
+	t.Run("passes classes without enumerated methods through", func(t *testing.T) {
+		identifiers := TestIdentifiersForClasses([]string{"RunnerUITests.SettingsTests", "UnknownTests"}, enumerated)
+
+		assert.Equal(t, []string{"SettingsTests/testDarkMode", "UnknownTests"}, identifiers)
+	})
+
+	t.Run("uses class level identifiers without enumeration", func(t *testing.T) {
+		assert.Equal(t, []string{"LoginTests", "CartTests"}, TestIdentifiersForClasses([]string{"LoginTests", "CartTests"}, nil))
+	})
+}
+
+func TestNotExecutedTests(t *testing.T) {
+	enumerated := []string{
+		"RunnerUITests.LoginTests/testLogin",
+		"RunnerUITests.LoginTests/testLogout()",
+		"RunnerUITests.LoginTests/testResetPassword",
+		"CartTests/testCheckout",
+		"CartTests/testEmptyCart",
+	}
+	// the runner crashed in testLogout, all following tests were never started
+	suites := []TestSuite{
+		{
+			Name: "LoginTests",
+			TestCases: []TestCase{
+				{ClassName: "LoginTests", MethodName: "testLogin", Status: StatusPassed},
+				{ClassName: "LoginTests", MethodName: "testLogout", Status: StatusCrashed},
+			},
+		},
+	}
+
+	assert.Equal(t, []string{
+		"RunnerUITests.LoginTests/testResetPassword",
+		"CartTests/testCheckout",
+		"CartTests/testEmptyCart",
+	}, NotExecutedTests(enumerated, suites))
+
+	suites = append(suites, TestSuite{Name: "CartTests", TestCases: []TestCase{
+		{ClassName: "CartTests", MethodName: "testCheckout", Status: StatusSkipped},
+		{ClassName: "CartTests", MethodName: "testEmptyCart", Status: StatusFailed},
+	}})
+	assert.Equal(t, []string{"RunnerUITests.LoginTests/testResetPassword"}, NotExecutedTests(enumerated, suites), "skipped tests are not reported")
+}
+
+func TestTestIdentifiersFromOtherModules(t *testing.T) {
+	identifiers := []string{
+		"RunnerUITests.LoginTests/testLogin",
+		"LoginTests/testLogout",
+		"RunnerTests.ModelTests/testDecoding",
+		"RunnerTests.ModelTests",
+		"CartTests.testCheckout",
+	}
+
+	assert.Equal(t, []string{"RunnerTests.ModelTests/testDecoding", "RunnerTests.ModelTests"}, TestIdentifiersFromOtherModules(identifiers, "RunnerUITests"))
+	assert.Empty(t, TestIdentifiersFromOtherModules(identifiers, ""), "nothing can be validated without a module name")
+}
+
+func TestExpandTestIdentifiers(t *testing.T) {
+	enumerated := []string{
+		"RunnerUITests.LoginTests/testLogin",
+		"RunnerUITests.LoginTests/testFailedLogin",
+		"RunnerUITests.LoginTests/testLogout",
+		"LoginFlowTests/testBiometricLogin",
+		"CartTests/testCheckout",
+	}
+
+	t.Run("expands method patterns", func(t *testing.T) {
+		identifiers := ExpandTestIdentifiers([]string{"LoginTests/test*Login", "*/testCheckout"}, enumerated)
+
+		assert.Equal(t, []string{
+			"RunnerUITests.LoginTests/testLogin",
+			"RunnerUITests.LoginTests/testFailedLogin",
+			"CartTests/testCheckout",
+		}, identifiers)
+	})
+
+	t.Run("expands class patterns to class level identifiers", func(t *testing.T) {
+		identifiers := ExpandTestIdentifiers([]string{"Login*", "RunnerUITests.Cart?ests"}, enumerated)
+
+		assert.Equal(t, []string{"RunnerUITests.LoginTests", "LoginFlowTests", "CartTests"}, identifiers)
+	})
+
+	t.Run("keeps exact identifiers and unmatched patterns", func(t *testing.T) {
+		identifiers := ExpandTestIdentifiers([]string{"CartTests", "LoginTests.testLogin", "Settings*", "LoginTests/testLog*", "LoginTests/testLogin"}, enumerated)
+
+		assert.Equal(t, []string{"CartTests", "LoginTests/testLogin", "Settings*", "RunnerUITests.LoginTests/testLogin", "RunnerUITests.LoginTests/testLogout"}, identifiers)
+	})
+
+	t.Run("keeps nil", func(t *testing.T) {
+		assert.Nil(t, ExpandTestIdentifiers(nil, enumerated))
+	})
+}
+
+func TestExpandTestIdentifiersOfConfig(t *testing.T) {
+	config := TestConfig{
+		TestsToRun:      []string{"LoginTests/testLog*"},
+		TestsToSkip:     []string{"LoginTests/testLogout"},
+		EnumeratedTests: []string{"LoginTests/testLogin", "LoginTests/testLogout"},
+	}
+	assert.NoError(t, expandTestIdentifiers(&config))
+	assert.Equal(t, []string{"LoginTests/testLogin", "LoginTests/testLogout"}, config.TestsToRun)
+	assert.Equal(t, []string{"LoginTests/testLogout"}, config.TestsToSkip)
+
+	assert.NoError(t, expandTestIdentifiers(&TestConfig{TestsToRun: []string{"LoginTests"}}), "identifiers without wildcards need no enumeration")
+	if err := expandTestIdentifiers(&TestConfig{TestsToSkip: []string{"Login*"}}); assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "need the test bundle on the host")
+	}
+}
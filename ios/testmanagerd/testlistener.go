@@ -10,6 +10,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/danielpaulus/go-ios/ios"
 	"github.com/danielpaulus/go-ios/ios/nskeyedarchiver"
 	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
@@ -17,14 +18,65 @@ import (
 
 // TestListener collects test results from the test execution
 type TestListener struct {
-	finished             chan struct{}
-	finishedOnce         sync.Once
+	finished     chan struct{}
+	finishedOnce sync.Once
+	// sessionMu serializes the messages of testmanagerd, which the dispatcher delivers on the goroutine reading the
+	// DTX connection, with ending the session from other goroutines, see sessionCancelled and sessionIdle
+	sessionMu            sync.Mutex
 	err                  error
 	logWriter            io.Writer
 	debugLogWriter       io.Writer
 	attachmentsDirectory string
 	TestSuites           []TestSuite
 	runningTestSuite     *TestSuite
+	// DeviceLocale is the language and region the device was set to when the test run started
+	DeviceLocale ios.DeviceLocale
+	// LaunchEnvironment is the environment the test runner was launched with. From iOS 17 on it is reported back by
+	// the device, before iOS 17 the device does not report it and it is the environment sent with the launch request
+	LaunchEnvironment map[string]interface{}
+	// RandomOrderSeed is the seed XCTest shuffled the tests with, it is nil if they ran in the default order
+	RandomOrderSeed *uint64
+	// SessionCrash is set if the test session ended before the test plan finished, f.ex. because the test runner crashed
+	SessionCrash *SessionCrash
+	// RunnerExitCode is the exit code of the test runner process, see RunnerExited. It is nil if the exit code was not
+	// reported and is independent of the test results, the runner can exit with an error after all tests passed
+	RunnerExitCode *int
+	// TargetResults summarizes the results of each test target if all targets of an .xctestrun file were run, see
+	// TestConfig.RunAllTargets
+	TargetResults []TargetResult
+	// IterationResults summarizes the results of each iteration if the tests were repeated, see TestConfig.Repeat
+	IterationResults []IterationResult
+	// CodeCoverageFiles are the paths of the code coverage profiles pulled from the device, see TestConfig.CodeCoverage
+	CodeCoverageFiles []string
+	// failureScreenshotMaxDimension is the maximum width and height of screenshots captured on test failures, see TestConfig
+	failureScreenshotMaxDimension int
+	// failureScreenshotter captures a screenshot when a test case fails, if enabled in the TestConfig
+	failureScreenshotter *failureScreenshotter
+	// screenRecordingsDirectory is the directory screen recording attachments are stored in, named by test case.
+	// If empty, they are stored in attachmentsDirectory like all other attachments
+	screenRecordingsDirectory string
+	// testAttachmentsDirectory is the directory all attachments are stored in, in a directory per test case, see
+	// TestConfig.AttachmentsDir. It takes precedence over attachmentsDirectory and screenRecordingsDirectory
+	testAttachmentsDirectory string
+	// processSampler records the peak memory and CPU usage of each test case, if enabled in the TestConfig
+	processSampler *processSampler
+	// testLogs writes the device log of each test case to a file, if enabled in the TestConfig
+	testLogs *testLogCapture
+	// screenRecorder records the screen while each test case runs, if enabled in the TestConfig
+	screenRecorder *screenRecorder
+	// crashReports records when each test case ran to assign crash reports to failed test cases, if enabled in the
+	// TestConfig
+	crashReports *crashReportHarvester
+	// testStartTimes are the times the running test cases started at by {CLASS}/{METHOD}, for TestCase.WallClockDuration
+	testStartTimes map[string]time.Time
+	// now returns the current time, replaced in tests
+	now func() time.Time
+	// output correlates the console output of the test runner with the running test case, see OutputWriter
+	output testOutput
+	// events receives every test event if TestConfig.EventStream is set
+	events *eventStream
+	// runner is the process of the test runner, see RunnerPID
+	runner runnerProcess
 }
 
 type TestSuite struct {
@@ -43,6 +95,14 @@ type TestCase struct {
 	Err         TestError
 	Duration    time.Duration
 	Attachments []TestAttachment
+	// WallClockDuration is the time between the start and the end of the test case as seen by go-ios. Unlike Duration,
+	// which XCTest measures on the device, it includes the time the messages about the test took to arrive.
+	WallClockDuration time.Duration
+	// PeakMemory and PeakCPU are only recorded if TestConfig.PeakMemory or TestConfig.PeakCPU is set
+	PeakMemory PeakMemory
+	PeakCPU    PeakCPU
+	// Output contains the lines written to TestListener.OutputWriter while the test case ran
+	Output []string
 }
 
 type TestCaseStatus string
@@ -51,12 +111,26 @@ const (
 	StatusFailed          = TestCaseStatus("failed")           // Defined by Apple
 	StatusPassed          = TestCaseStatus("passed")           // Defined by Apple
 	StatusExpectedFailure = TestCaseStatus("expected failure") // Defined by Apple
+	StatusSkipped         = TestCaseStatus("skipped")          // Defined by Apple
 	StatusStalled         = TestCaseStatus("stalled")          // Defined by us
+	StatusCrashed         = TestCaseStatus("crashed")          // Defined by us
+	StatusCancelled       = TestCaseStatus("cancelled")        // Defined by us
 
 	// Test suite counter constants
 	unknownCount uint64 = 0
 )
 
+// ErrTestSessionCrashed is returned if the test session ended abnormally before the test plan finished. This
+// happens if the test runner crashes, and is different from failing tests.
+var ErrTestSessionCrashed = errors.New("test session crashed")
+
+// SessionCrash describes the abnormal end of a test session
+type SessionCrash struct {
+	Reason string
+	// CrashReports contains the names of the crash reports of the test runner that were found on the device
+	CrashReports []string
+}
+
 type TestError struct {
 	Message string
 	File    string
@@ -79,10 +153,21 @@ func NewTestListener(logWriter io.Writer, debugLogWriter io.Writer, attachmentsD
 		debugLogWriter:       debugLogWriter,
 		TestSuites:           make([]TestSuite, 0),
 		attachmentsDirectory: attachmentsDirectory,
+		testStartTimes:       map[string]time.Time{},
+		now:                  time.Now,
 	}
 }
 
+// currentTime returns the current time of the clock of the listener
+func (t *TestListener) currentTime() time.Time {
+	if t.now == nil {
+		return time.Now()
+	}
+	return t.now()
+}
+
 func (t *TestListener) didFinishExecutingTestPlan() {
+	t.emit(TestEvent{Type: EventTestPlanFinished, Locale: t.deviceLocale()})
 	t.executionFinished()
 }
 
@@ -125,6 +210,18 @@ func (t *TestListener) testCaseFinished(testClass string, testMethod string, xcA
 
 	for _, attachment := range xcActivityRecord.Attachments {
 		attachmentsPath := filepath.Join(t.attachmentsDirectory, uuid.New().String())
+		if t.testAttachmentsDirectory != "" {
+			path, err := testAttachmentPath(t.testAttachmentsDirectory, testCase, attachment.Name, attachment.UniformTypeIdentifier)
+			if err != nil {
+				log.WithFields(log.Fields{"error": err, "attachment": attachment.Name}).Warn("Cannot create attachments directory of test case, storing attachment in default directory")
+			} else {
+				attachmentsPath = path
+			}
+		} else if t.screenRecordingsDirectory != "" {
+			if recordingPath, ok := screenRecordingPath(t.screenRecordingsDirectory, testCase, attachment.UniformTypeIdentifier); ok {
+				attachmentsPath = recordingPath
+			}
+		}
 		file, err := os.Create(attachmentsPath)
 		if err != nil {
 			log.WithFields(log.Fields{"error": err, "attachment": attachment.Name}).Warn("Received testCaseFinished with activity record but failed writing attachments to disk. Ignoring attachment")
@@ -132,7 +229,16 @@ func (t *TestListener) testCaseFinished(testClass string, testMethod string, xcA
 		}
 		defer file.Close()
 
-		file.Write(attachment.Payload)
+		payload := attachment.Payload
+		if t.shouldDownscale(xcActivityRecord, attachment) {
+			downscaled, err := downscaleImage(payload, t.failureScreenshotMaxDimension)
+			if err != nil {
+				log.WithFields(log.Fields{"error": err, "attachment": attachment.Name}).Warn("Failed downscaling failure screenshot, keeping full resolution")
+			} else {
+				payload = downscaled
+			}
+		}
+		file.Write(payload)
 		testCase.Attachments = append(testCase.Attachments, TestAttachment{
 			Name:                  strings.Clone(attachment.Name),
 			Timestamp:             attachment.Timestamp,
@@ -141,9 +247,19 @@ func (t *TestListener) testCaseFinished(testClass string, testMethod string, xcA
 			Type:                  strings.Clone(xcActivityRecord.ActivityType),
 			UniformTypeIdentifier: strings.Clone(attachment.UniformTypeIdentifier),
 		})
+		t.emit(TestEvent{Type: EventAttachment, ClassName: testCase.ClassName, MethodName: testCase.MethodName, Attachment: &testCase.Attachments[len(testCase.Attachments)-1]})
 	}
 }
 
+// shouldDownscale returns true for screenshots XCTest captured because of a test failure. Screenshots that were
+// explicitly requested by a test always keep their full resolution.
+func (t *TestListener) shouldDownscale(xcActivityRecord nskeyedarchiver.XCActivityRecord, attachment nskeyedarchiver.XCTAttachment) bool {
+	if t.failureScreenshotMaxDimension <= 0 || xcActivityRecord.ActivityType != failureActivityType {
+		return false
+	}
+	return attachment.UniformTypeIdentifier == "public.png" || attachment.UniformTypeIdentifier == "public.jpeg"
+}
+
 func (t *TestListener) testSuiteDidStart(suiteName string, date string) {
 	d, err := time.Parse(time.DateTime+" +0000", date)
 	if err != nil {
@@ -161,6 +277,7 @@ func (t *TestListener) testSuiteDidStart(suiteName string, date string) {
 		StartDate: d,
 		TestCases: make([]TestCase, 0),
 	}
+	t.emit(TestEvent{Type: EventTestSuiteStarted, Suite: suiteName})
 }
 
 func (t *TestListener) testCaseDidStartForClass(testClass string, testMethod string) {
@@ -169,6 +286,24 @@ func (t *TestListener) testCaseDidStartForClass(testClass string, testMethod str
 		ClassName:  testClass,
 		MethodName: testMethod,
 	})
+	if t.testStartTimes == nil {
+		t.testStartTimes = map[string]time.Time{}
+	}
+	t.testStartTimes[testClass+"/"+testMethod] = t.currentTime()
+	t.output.testStarted(testClass, testMethod)
+	t.emit(TestEvent{Type: EventTestStarted, ClassName: testClass, MethodName: testMethod})
+	if t.processSampler != nil {
+		t.processSampler.testStarted(testClass, testMethod)
+	}
+	if t.testLogs != nil {
+		t.testLogs.testStarted(testClass, testMethod)
+	}
+	if t.screenRecorder != nil {
+		t.screenRecorder.testStarted(testClass, testMethod)
+	}
+	if t.crashReports != nil {
+		t.crashReports.testStarted(testClass, testMethod)
+	}
 }
 
 func (t *TestListener) testCaseFailedForClass(testClass string, testMethod string, message string, file string, line uint64) {
@@ -189,6 +324,7 @@ func (t *TestListener) testCaseFailedForClass(testClass string, testMethod strin
 		File:    file,
 		Line:    line,
 	}
+	t.emit(TestEvent{Type: EventTestFailed, ClassName: testClass, MethodName: testMethod, Error: &testCase.Err})
 }
 
 func (t *TestListener) testCaseDidFinishForTest(testClass string, testMethod string, status string, duration float64) {
@@ -206,6 +342,33 @@ func (t *TestListener) testCaseDidFinishForTest(testClass string, testMethod str
 		}
 
 		testCase.Duration = d
+		key := testClass + "/" + testMethod
+		if start, ok := t.testStartTimes[key]; ok {
+			testCase.WallClockDuration = t.currentTime().Sub(start)
+			delete(t.testStartTimes, key)
+		}
+
+		if t.failureScreenshotter != nil && testCase.Status == StatusFailed {
+			t.failureScreenshotter.testFailed(testCase)
+		}
+		if t.processSampler != nil {
+			t.processSampler.testFinished(testCase)
+		}
+		if t.testLogs != nil {
+			t.testLogs.testFinished(testCase)
+		}
+		if t.screenRecorder != nil {
+			t.screenRecorder.testFinished(testCase)
+		}
+		if t.crashReports != nil {
+			t.crashReports.testFinished(testCase)
+		}
+		t.output.testFinished(testCase)
+		event := TestEvent{Type: EventTestFinished, ClassName: testClass, MethodName: testMethod, Status: testCase.Status, Duration: d.Seconds()}
+		if testCase.Err != (TestError{}) {
+			event.Error = &testCase.Err
+		}
+		t.emit(event)
 	}
 }
 
@@ -240,10 +403,12 @@ func (t *TestListener) testSuiteFinished(suiteName string, date string, testCoun
 
 	t.TestSuites = append(t.TestSuites, *t.runningTestSuite)
 	t.runningTestSuite = nil
+	t.emit(TestEvent{Type: EventTestSuiteFinished, Suite: suiteName, Duration: ts.TotalDuration.Seconds()})
 }
 
 func (t *TestListener) LogMessage(msg string) {
 	t.logWriter.Write([]byte(msg))
+	t.emit(TestEvent{Type: EventLog, Message: msg})
 }
 
 func (t *TestListener) LogDebugMessage(msg string) {
@@ -260,10 +425,89 @@ func (t *TestListener) FinishWithError(err error) {
 	t.executionFinished()
 }
 
+// sessionEndedAbnormally reports a session crash if the test plan has not finished yet. The test case that was
+// running is marked as crashed and the running test suite is finalized.
+func (t *TestListener) sessionEndedAbnormally(reason string, crashReports []string) {
+	t.sessionMu.Lock()
+	defer t.sessionMu.Unlock()
+	select {
+	case <-t.finished:
+		return
+	default:
+	}
+	t.closeRunningTestSuite(StatusCrashed, reason)
+	t.SessionCrash = &SessionCrash{Reason: reason, CrashReports: crashReports}
+	t.emit(TestEvent{Type: EventSessionCrashed, Message: reason, Locale: t.deviceLocale()})
+	t.err = fmt.Errorf("%w: %s", ErrTestSessionCrashed, reason)
+	t.executionFinished()
+}
+
+// sessionCancelled ends the session of a run whose context was cancelled. The test case that was interrupted is
+// marked as cancelled and err is returned by the run. Results of a session that already finished are kept as they are.
+func (t *TestListener) sessionCancelled(err error) {
+	t.sessionMu.Lock()
+	defer t.sessionMu.Unlock()
+	select {
+	case <-t.finished:
+		return
+	default:
+	}
+	t.closeRunningTestSuite(StatusCancelled, "test run cancelled")
+	t.err = err
+	t.executionFinished()
+}
+
+// sessionIdle ends the session after testmanagerd did not send any message for idle, see TestConfig.IdleTimeout.
+// The test case that was running is marked as stalled and the run fails with ErrTestSessionIdle.
+func (t *TestListener) sessionIdle(idle time.Duration) {
+	t.sessionMu.Lock()
+	defer t.sessionMu.Unlock()
+	select {
+	case <-t.finished:
+		return
+	default:
+	}
+	reason := fmt.Sprintf("no message received from testmanagerd for %s", idle)
+	if ts := t.runningTestSuite; ts != nil && len(ts.TestCases) > 0 {
+		if testCase := ts.TestCases[len(ts.TestCases)-1]; testCase.Status == "" {
+			reason += fmt.Sprintf(" while %s/%s was running", testCase.ClassName, testCase.MethodName)
+		}
+	}
+	t.closeRunningTestSuite(StatusStalled, reason)
+	t.err = fmt.Errorf("%w: %s", ErrTestSessionIdle, reason)
+	t.executionFinished()
+}
+
+// closeRunningTestSuite adds the running test suite to TestSuites. Its last test case gets status and reason as error
+// if it did not finish.
+func (t *TestListener) closeRunningTestSuite(status TestCaseStatus, reason string) {
+	ts := t.runningTestSuite
+	if ts == nil {
+		return
+	}
+	if len(ts.TestCases) > 0 {
+		testCase := &ts.TestCases[len(ts.TestCases)-1]
+		if testCase.Status == "" {
+			testCase.Status = status
+			testCase.Err = TestError{Message: reason}
+		}
+	}
+	t.TestSuites = append(t.TestSuites, *ts)
+	t.runningTestSuite = nil
+}
+
 func (t *TestListener) Done() <-chan struct{} {
 	return t.finished
 }
 
+// results returns the test suites and the error of the session, testmanagerd can still send messages when the run
+// returns
+func (t *TestListener) results() ([]TestSuite, error) {
+	t.sessionMu.Lock()
+	defer t.sessionMu.Unlock()
+	return t.TestSuites, t.err
+}
+
 func (t *TestListener) findTestCase(className string, methodName string) *TestCase {
 	ts := t.findTestSuite(className)
 
@@ -277,6 +521,35 @@ func (t *TestListener) findTestCase(className string, methodName string) *TestCa
 	return nil
 }
 
+// addAttachment adds attachment to the last test case named className/methodName, which may have finished already.
+// It is used for attachments that are created on other goroutines than the one delivering the messages of
+// testmanagerd.
+func (t *TestListener) addAttachment(className string, methodName string, attachment TestAttachment) {
+	t.sessionMu.Lock()
+	defer t.sessionMu.Unlock()
+	var testCase *TestCase
+	if t.runningTestSuite != nil {
+		testCase = lastTestCaseNamed(t.runningTestSuite.TestCases, className, methodName)
+	}
+	for i := len(t.TestSuites) - 1; i >= 0 && testCase == nil; i-- {
+		testCase = lastTestCaseNamed(t.TestSuites[i].TestCases, className, methodName)
+	}
+	if testCase == nil {
+		log.WithField("test", className+"/"+methodName).Warn("cannot add attachment, the test case is unknown")
+		return
+	}
+	testCase.Attachments = append(testCase.Attachments, attachment)
+}
+
+func lastTestCaseNamed(testCases []TestCase, className string, methodName string) *TestCase {
+	for i := len(testCases) - 1; i >= 0; i-- {
+		if testCases[i].ClassName == className && testCases[i].MethodName == methodName {
+			return &testCases[i]
+		}
+	}
+	return nil
+}
+
 func (t *TestListener) findTestSuite(className string) *TestSuite {
 	if t.runningTestSuite != nil && t.runningTestSuite.Name == className {
 		return t.runningTestSuite
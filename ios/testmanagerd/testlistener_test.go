@@ -1,6 +1,9 @@
 package testmanagerd
 
 import (
+	"bytes"
+	"image"
+	"image/png"
 	"io"
 	"os"
 	"sync"
@@ -226,6 +229,50 @@ func TestFinishExecutingTestPlan(t *testing.T) {
 
 		assert.Equal(t, "test", string(attachment), "Attachment content should be put in a file")
 	})
+
+	t.Run("Downscale failure screenshots only", func(t *testing.T) {
+		testListener := NewTestListener(io.Discard, io.Discard, t.TempDir())
+		testListener.failureScreenshotMaxDimension = 100
+
+		var screenshot bytes.Buffer
+		assert.NoError(t, png.Encode(&screenshot, image.NewRGBA(image.Rect(0, 0, 400, 200))))
+		attachments := []nskeyedarchiver.XCTAttachment{{Name: "Screenshot", UniformTypeIdentifier: "public.png", Payload: screenshot.Bytes()}}
+
+		testListener.testSuiteDidStart("mysuite", "2024-01-16 15:36:43 +0000")
+		testListener.testCaseDidStartForClass("mysuite", "mymethod")
+		testListener.testCaseFinished("mysuite", "mymethod", nskeyedarchiver.XCActivityRecord{
+			Title:        "Screenshot",
+			ActivityType: "com.apple.dt.xctest.activity-type.userCreated",
+			Attachments:  attachments,
+		})
+		testListener.testCaseFinished("mysuite", "mymethod", nskeyedarchiver.XCActivityRecord{
+			Title:        "Assertion Failure",
+			ActivityType: failureActivityType,
+			Attachments:  attachments,
+		})
+
+		testCase := testListener.runningTestSuite.TestCases[0]
+		assert.Equal(t, 2, len(testCase.Attachments))
+		requested := decodeImageConfig(t, testCase.Attachments[0].Path)
+		assert.Equal(t, 400, requested.Width, "explicitly requested screenshots must keep their resolution")
+		assert.Equal(t, 200, requested.Height, "explicitly requested screenshots must keep their resolution")
+		failure := decodeImageConfig(t, testCase.Attachments[1].Path)
+		assert.Equal(t, 100, failure.Width, "failure screenshots must be downscaled")
+		assert.Equal(t, 50, failure.Height, "failure screenshots must be downscaled")
+	})
+}
+
+func decodeImageConfig(t *testing.T, path string) image.Config {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	config, err := png.DecodeConfig(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return config
 }
 
 type assertionWriter struct {
@@ -239,3 +286,43 @@ func (w *assertionWriter) Write(p []byte) (n int, err error) {
 
 	return len(p), nil
 }
+
+func TestSessionEndedAbnormally(t *testing.T) {
+	t.Run("Report a crash if the connection closes before the test plan finished", func(t *testing.T) {
+		testListener := NewTestListener(io.Discard, io.Discard, os.TempDir())
+
+		testListener.testSuiteDidStart("mysuite", "2024-01-16 15:36:43 +0000")
+		testListener.testCaseDidStartForClass("mysuite", "mymethod1")
+		testListener.testCaseDidFinishForTest("mysuite", "mymethod1", "passed", 1.0)
+		testListener.testCaseDidStartForClass("mysuite", "mymethod2")
+		// the connection closes without testCaseDidFinishForTest, testSuiteFinished and didFinishExecutingTestPlan
+		testListener.sessionEndedAbnormally(lostConnectionReason, []string{"RunnerUITests-Runner-2024-01-16-153650.ips"})
+
+		assert.ErrorIs(t, testListener.err, ErrTestSessionCrashed)
+		assert.Equal(t, &SessionCrash{Reason: lostConnectionReason, CrashReports: []string{"RunnerUITests-Runner-2024-01-16-153650.ips"}}, testListener.SessionCrash)
+		assert.Equal(t, 1, len(testListener.TestSuites), "the running test suite must be finalized")
+		testCases := testListener.TestSuites[0].TestCases
+		assert.Equal(t, StatusPassed, testCases[0].Status)
+		assert.Equal(t, StatusCrashed, testCases[1].Status, "the running test case must be marked as crashed")
+		select {
+		case <-testListener.Done():
+		default:
+			t.Error("listener must be done after a session crash")
+		}
+	})
+
+	t.Run("Ignore a closed connection after the test plan finished", func(t *testing.T) {
+		testListener := NewTestListener(io.Discard, io.Discard, os.TempDir())
+
+		testListener.testSuiteDidStart("mysuite", "2024-01-16 15:36:43 +0000")
+		testListener.testCaseDidStartForClass("mysuite", "mymethod1")
+		testListener.testCaseFailedForClass("mysuite", "mymethod1", "assertion failed", "file.swift", 12)
+		testListener.testCaseDidFinishForTest("mysuite", "mymethod1", "failed", 1.0)
+		testListener.testSuiteFinished("mysuite", "2024-01-16 15:36:44 +0000", 1, 1, 0, 0, 0, 0, 1.0, 1.0)
+		testListener.didFinishExecutingTestPlan()
+		testListener.sessionEndedAbnormally(lostConnectionReason, nil)
+
+		assert.NoError(t, testListener.err, "test failures must not be reported as session crash")
+		assert.Nil(t, testListener.SessionCrash)
+	})
+}
@@ -0,0 +1,168 @@
+package testmanagerd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/danielpaulus/go-ios/ios/syslog"
+	log "github.com/sirupsen/logrus"
+)
+
+// WithTestLogs captures the device log while each test runs and stores the messages of the test runner and the app
+// under test in dir as <class>-<method>.log. If dir is empty, the logs/ directory of the OutputDir is used,
+// see TestConfig.TestLogs
+func WithTestLogs(dir string) XCTestRunOption {
+	return func(config *TestConfig) {
+		config.TestLogs = true
+		if dir != "" {
+			config.TestLogDir = dir
+		}
+	}
+}
+
+// logMessageSource delivers the messages of the device log, implemented by syslog.Connection
+type logMessageSource interface {
+	ReadLogMessage() (string, error)
+	Close() error
+}
+
+// testLogCapture writes the log messages of a set of processes to a file per test case while the test case runs.
+// Messages that arrive while no test case runs are dropped.
+type testLogCapture struct {
+	dir       string
+	processes map[string]bool
+	parse     func(string) (*syslog.LogEntry, error)
+	now       func() time.Time
+
+	mu       sync.Mutex
+	file     *os.File
+	testCase string
+}
+
+// startTestLogCapture connects to the syslog of the device and lets the listener of config write the messages of
+// the test runner and the app under test to a file per test case. The returned function stops the capture.
+func startTestLogCapture(config TestConfig) (func(), error) {
+	if config.TestLogDir == "" {
+		return nil, errors.New("no directory for the test logs, set TestLogDir or OutputDir")
+	}
+	if err := os.MkdirAll(config.TestLogDir, 0o755); err != nil {
+		return nil, err
+	}
+	processes, err := testProcessNames(config)
+	if err != nil {
+		return nil, err
+	}
+	syslogConnection, err := syslog.New(config.Device)
+	if err != nil {
+		return nil, err
+	}
+	capture := newTestLogCapture(config.TestLogDir, processes)
+	config.Listener.testLogs = capture
+	go capture.read(syslogConnection)
+	return func() {
+		syslogConnection.Close()
+		capture.close()
+	}, nil
+}
+
+func newTestLogCapture(dir string, processes []string) *testLogCapture {
+	capture := &testLogCapture{
+		dir:       dir,
+		processes: map[string]bool{},
+		parse:     syslog.Parser(),
+		now:       time.Now,
+	}
+	for _, process := range processes {
+		capture.processes[process] = true
+	}
+	return capture
+}
+
+// read writes the messages of source until it fails, which happens when it is closed
+func (c *testLogCapture) read(source logMessageSource) {
+	for {
+		message, err := source.ReadLogMessage()
+		if err != nil {
+			log.WithError(err).Debug("stopped reading the device log for test logs")
+			return
+		}
+		c.write(message)
+	}
+}
+
+// write appends message to the log of the running test case if it was logged by one of the captured processes
+func (c *testLogCapture) write(message string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.file == nil {
+		return
+	}
+	message = strings.TrimRight(message, "\x00\n")
+	entry, err := c.parse(message)
+	if err != nil {
+		return
+	}
+	// the process is logged with its subsystem, f.ex. MyApp(UIKitCore)
+	process, _, _ := strings.Cut(entry.Process, "(")
+	if !c.processes[process] {
+		return
+	}
+	if _, err := fmt.Fprintln(c.file, message); err != nil {
+		log.WithError(err).Warn("failed writing test log")
+	}
+}
+
+// testStarted creates the log file of the test case, the log of a test case that did not finish is closed
+func (c *testLogCapture) testStarted(className string, methodName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closeFile()
+	file, err := os.Create(filepath.Join(c.dir, testCaseFileName(className, methodName, ".log")))
+	if err != nil {
+		log.WithError(err).Warn("failed creating test log")
+		return
+	}
+	c.file = file
+	c.testCase = className + "/" + methodName
+}
+
+// testFinished closes the log file of testCase and adds it to the attachments of the test case
+func (c *testLogCapture) testFinished(testCase *TestCase) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.file == nil || c.testCase != testCase.ClassName+"/"+testCase.MethodName {
+		return
+	}
+	logPath := c.file.Name()
+	c.closeFile()
+	testCase.Attachments = append(testCase.Attachments, TestAttachment{
+		Name:                  "Device Log",
+		Path:                  logPath,
+		Timestamp:             float64(c.now().Unix()),
+		UniformTypeIdentifier: "public.plain-text",
+	})
+}
+
+// close closes the log file of a test case that did not finish
+func (c *testLogCapture) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closeFile()
+}
+
+// closeFile closes the log file of the running test case, the caller must hold mu
+func (c *testLogCapture) closeFile() {
+	if c.file == nil {
+		return
+	}
+	if err := c.file.Close(); err != nil {
+		log.WithError(err).Warn("failed closing test log")
+	}
+	c.file = nil
+	c.testCase = ""
+}
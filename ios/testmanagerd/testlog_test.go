@@ -0,0 +1,73 @@
+package testmanagerd
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeLogSource returns the given messages and io.EOF afterwards
+type fakeLogSource struct {
+	messages []string
+}
+
+func (f *fakeLogSource) ReadLogMessage() (string, error) {
+	if len(f.messages) == 0 {
+		return "", io.EOF
+	}
+	message := f.messages[0]
+	f.messages = f.messages[1:]
+	return message, nil
+}
+
+func (f *fakeLogSource) Close() error {
+	return nil
+}
+
+func TestTestLogs(t *testing.T) {
+	dir := t.TempDir()
+	capture := newTestLogCapture(dir, []string{"UITests-Runner", "MyApp"})
+	listener := NewTestListener(io.Discard, io.Discard, os.TempDir())
+	listener.testLogs = capture
+	listener.testSuiteDidStart("LoginTests", "2024-01-16 15:36:43 +0000")
+
+	capture.read(&fakeLogSource{messages: []string{"Jan 16 15:36:43 iPhone MyApp[12] <Notice>: before the test\x00"}})
+	listener.testCaseDidStartForClass("LoginTests", "testLogin")
+	capture.read(&fakeLogSource{messages: []string{
+		"Jan 16 15:36:44 iPhone MyApp(UIKitCore)[12] <Notice>: login screen shown\x00",
+		"Jan 16 15:36:44 iPhone SpringBoard(FrontBoard)[58] <Notice>: not captured\x00",
+		"Jan 16 15:36:45 iPhone UITests-Runner(XCTestCore)[34] <Error>: tapped login\x00",
+		"not a syslog line\x00",
+	}})
+	listener.testCaseDidFinishForTest("LoginTests", "testLogin", "passed", 1)
+	capture.read(&fakeLogSource{messages: []string{"Jan 16 15:36:46 iPhone MyApp[12] <Notice>: after the test\x00"}})
+
+	logPath := filepath.Join(dir, "LoginTests-testLogin.log")
+	contents, err := os.ReadFile(logPath)
+	require.NoError(t, err)
+	assert.Equal(t, "Jan 16 15:36:44 iPhone MyApp(UIKitCore)[12] <Notice>: login screen shown\n"+
+		"Jan 16 15:36:45 iPhone UITests-Runner(XCTestCore)[34] <Error>: tapped login\n", string(contents))
+
+	attachments := listener.runningTestSuite.TestCases[0].Attachments
+	require.Len(t, attachments, 1)
+	assert.Equal(t, "Device Log", attachments[0].Name)
+	assert.Equal(t, logPath, attachments[0].Path)
+}
+
+func TestTestLogsOfUnfinishedTest(t *testing.T) {
+	dir := t.TempDir()
+	capture := newTestLogCapture(dir, []string{"MyApp"})
+	capture.testStarted("LoginTests", "testCrash")
+	capture.write("Jan 16 15:36:44 iPhone MyApp[12] <Fault>: crashing\x00")
+	capture.testStarted("LoginTests", "testNext")
+	capture.close()
+
+	contents, err := os.ReadFile(filepath.Join(dir, "LoginTests-testCrash.log"))
+	require.NoError(t, err)
+	assert.Equal(t, "Jan 16 15:36:44 iPhone MyApp[12] <Fault>: crashing\n", string(contents))
+	assert.FileExists(t, filepath.Join(dir, "LoginTests-testNext.log"))
+}
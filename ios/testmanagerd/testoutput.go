@@ -0,0 +1,115 @@
+package testmanagerd
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/danielpaulus/go-ios/ios/instruments"
+)
+
+// TestOutputLine is a line of console output of the test runner together with the test case that was running when
+// the line was written. ClassName and MethodName are empty for output written outside of a test case.
+type TestOutputLine struct {
+	ClassName  string
+	MethodName string
+	Line       string
+}
+
+// testOutput correlates the console output of the test runner with the test case events of a TestListener. Its zero
+// value is ready to use.
+type testOutput struct {
+	mu         sync.Mutex
+	className  string
+	methodName string
+	partial    []byte
+	lines      map[string][]string
+	handler    func(TestOutputLine)
+	// events receives an EventOutput for every line, if set
+	events *eventStream
+}
+
+// OutputWriter returns a writer that tags every line written to it with the test case that is running at that time.
+// The lines are stored in TestCase.Output when the test case finishes and are passed to the handler set with
+// OnTestOutput. The stdout and stderr of the test runner are written to it automatically, other output like the
+// syslog of the device can be fed into it as well.
+func (t *TestListener) OutputWriter() io.Writer {
+	return &t.output
+}
+
+// runnerOutputWriter returns the writer the stdout and stderr of the test runner are copied to. The output is written
+// to the log writer of the listener and to OutputWriter.
+func (t *TestListener) runnerOutputWriter() io.Writer {
+	return io.MultiWriter(t.logWriter, &t.output)
+}
+
+// runnerOutput writes the output the test runner sent over process control to runnerOutputWriter
+func (t *TestListener) runnerOutput(output instruments.ProcessOutput) {
+	t.runnerOutputWriter().Write([]byte(output.Message))
+}
+
+// OnTestOutput sets a handler that receives each line written to OutputWriter as soon as it is complete. The handler
+// is called synchronously and must not block.
+func (t *TestListener) OnTestOutput(handler func(TestOutputLine)) {
+	t.output.mu.Lock()
+	defer t.output.mu.Unlock()
+	t.output.handler = handler
+}
+
+func (o *testOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.partial = append(o.partial, p...)
+	for {
+		i := bytes.IndexByte(o.partial, '\n')
+		if i < 0 {
+			break
+		}
+		o.addLine(strings.TrimSuffix(string(o.partial[:i]), "\r"))
+		o.partial = o.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+func (o *testOutput) addLine(line string) {
+	if o.className != "" || o.methodName != "" {
+		if o.lines == nil {
+			o.lines = map[string][]string{}
+		}
+		key := o.className + "/" + o.methodName
+		o.lines[key] = append(o.lines[key], line)
+	}
+	if o.handler != nil {
+		o.handler(TestOutputLine{ClassName: o.className, MethodName: o.methodName, Line: line})
+	}
+	if o.events != nil {
+		o.events.emit(TestEvent{Type: EventOutput, ClassName: o.className, MethodName: o.methodName, Message: line})
+	}
+}
+
+func (o *testOutput) setEvents(events *eventStream) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.events = events
+}
+
+func (o *testOutput) testStarted(className string, methodName string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.className = className
+	o.methodName = methodName
+}
+
+// testFinished stores the output lines written while testCase ran in TestCase.Output
+func (o *testOutput) testFinished(testCase *TestCase) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	key := testCase.ClassName + "/" + testCase.MethodName
+	testCase.Output = append(testCase.Output, o.lines[key]...)
+	delete(o.lines, key)
+	if o.className == testCase.ClassName && o.methodName == testCase.MethodName {
+		o.className = ""
+		o.methodName = ""
+	}
+}
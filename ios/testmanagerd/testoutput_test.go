@@ -0,0 +1,70 @@
+package testmanagerd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/danielpaulus/go-ios/ios/instruments"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListenerTagsOutputWithRunningTest(t *testing.T) {
+	listener := NewTestListener(io.Discard, io.Discard, t.TempDir())
+	var streamed []TestOutputLine
+	listener.OnTestOutput(func(line TestOutputLine) {
+		streamed = append(streamed, line)
+	})
+	output := listener.OutputWriter()
+
+	listener.testSuiteDidStart("MyAppUITests", "2024-01-16 15:00:00 +0000")
+	io.WriteString(output, "setting up\n")
+	listener.testCaseDidStartForClass("MyAppUITests", "testLogin")
+	io.WriteString(output, "logging in\r\nentering pass")
+	io.WriteString(output, "word\n")
+	listener.testCaseDidFinishForTest("MyAppUITests", "testLogin", "passed", 1)
+	io.WriteString(output, "between tests\n")
+	listener.testCaseDidStartForClass("MyAppUITests", "testLogout")
+	io.WriteString(output, "logging out\n")
+	listener.testCaseDidFinishForTest("MyAppUITests", "testLogout", "passed", 1)
+
+	testCases := listener.runningTestSuite.TestCases
+	assert.Equal(t, []string{"logging in", "entering password"}, testCases[0].Output)
+	assert.Equal(t, []string{"logging out"}, testCases[1].Output)
+	assert.Equal(t, []TestOutputLine{
+		{Line: "setting up"},
+		{ClassName: "MyAppUITests", MethodName: "testLogin", Line: "logging in"},
+		{ClassName: "MyAppUITests", MethodName: "testLogin", Line: "entering password"},
+		{Line: "between tests"},
+		{ClassName: "MyAppUITests", MethodName: "testLogout", Line: "logging out"},
+	}, streamed)
+}
+
+func TestListenerStreamsRunnerOutput(t *testing.T) {
+	var log, stream bytes.Buffer
+	listener := NewTestListener(&log, io.Discard, t.TempDir())
+	listener.events = newEventStream(&stream)
+	now := time.Date(2024, 1, 16, 15, 0, 0, 0, time.UTC)
+	listener.events.now = func() time.Time { return now }
+	listener.output.setEvents(listener.events)
+
+	listener.testSuiteDidStart("LoginUITests", "2024-01-16 15:00:00 +0000")
+	listener.testCaseDidStartForClass("LoginUITests", "testLogin")
+	listener.runnerOutput(instruments.ProcessOutput{Pid: 4711, Message: "XCTAssertTrue failed\n"})
+	listener.testCaseDidFinishForTest("LoginUITests", "testLogin", "failed", 1)
+
+	assert.Equal(t, "XCTAssertTrue failed\n", log.String())
+	assert.Equal(t, []string{"XCTAssertTrue failed"}, listener.runningTestSuite.TestCases[0].Output)
+	var events []TestEvent
+	decoder := json.NewDecoder(&stream)
+	for decoder.More() {
+		var event TestEvent
+		require.NoError(t, decoder.Decode(&event))
+		events = append(events, event)
+	}
+	require.Len(t, events, 4)
+	assert.Equal(t, TestEvent{Type: EventOutput, Time: now, ClassName: "LoginUITests", MethodName: "testLogin", Message: "XCTAssertTrue failed"}, events[2])
+}
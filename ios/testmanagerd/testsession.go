@@ -0,0 +1,106 @@
+package testmanagerd
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/danielpaulus/go-ios/ios"
+	dtx "github.com/danielpaulus/go-ios/ios/dtx_codec"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// dialFunc opens a DTX connection to a testmanagerd service, like dtx.NewUsbmuxdConnection
+type dialFunc func(device ios.DeviceEntry, serviceName string) (*dtx.Connection, error)
+
+// testSession is a single run of a test runner. It owns the connections to testmanagerd and with them the channels
+// and dispatchers of the run, nothing of it is shared with other sessions. This allows running several sessions on the
+// same device at the same time, f.ex. WebDriverAgent next to a UI test suite, as long as they use different test
+// runners.
+type testSession struct {
+	id       uuid.UUID
+	log      *log.Entry
+	released func()
+
+	mu    sync.Mutex
+	conns []*dtx.Connection
+}
+
+// runningSessions contains the test runners with a running session by device, see claimTestRunner
+var runningSessions = struct {
+	sync.Mutex
+	runners map[string]uuid.UUID
+}{runners: map[string]uuid.UUID{}}
+
+// newTestSession starts a session for the test runner of config. It fails if another session of this process runs
+// the same test runner on the device, as launching the runner again would terminate the other session.
+func newTestSession(config TestConfig) (*testSession, error) {
+	id := uuid.New()
+	udid := config.Device.Properties.SerialNumber
+	release, err := claimTestRunner(udid, config.TestRunnerBundleId, id)
+	if err != nil {
+		return nil, err
+	}
+	return &testSession{
+		id:       id,
+		log:      log.WithFields(log.Fields{"session": id, "udid": udid, "runner": config.TestRunnerBundleId}),
+		released: release,
+	}, nil
+}
+
+// claimTestRunner registers the session id as the only one running bundleID on the device with udid. The returned
+// function releases the test runner again.
+func claimTestRunner(udid string, bundleID string, id uuid.UUID) (func(), error) {
+	key := udid + "/" + bundleID
+	runningSessions.Lock()
+	defer runningSessions.Unlock()
+	if running, ok := runningSessions.runners[key]; ok {
+		return nil, fmt.Errorf("test runner %s is already used by test session %s on device %s", bundleID, running, udid)
+	}
+	runningSessions.runners[key] = id
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			runningSessions.Lock()
+			defer runningSessions.Unlock()
+			delete(runningSessions.runners, key)
+		})
+	}, nil
+}
+
+// connect opens a new connection to serviceName on device with dial. It is closed together with the session.
+func (s *testSession) connect(dial dialFunc, device ios.DeviceEntry, serviceName string) (*dtx.Connection, error) {
+	conn, err := dial(device, serviceName)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conns = append(s.conns, conn)
+	return conn, nil
+}
+
+// connections returns the connections of the session in the order they were opened
+func (s *testSession) connections() []*dtx.Connection {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*dtx.Connection{}, s.conns...)
+}
+
+// closed returns the Closed channels of all connections of the session
+func (s *testSession) closed() []<-chan struct{} {
+	conns := s.connections()
+	closed := make([]<-chan struct{}, len(conns))
+	for i, conn := range conns {
+		closed[i] = conn.Closed()
+	}
+	return closed
+}
+
+// close closes all connections of the session and releases its test runner for other sessions
+func (s *testSession) close() {
+	for _, conn := range s.connections() {
+		conn.Close()
+	}
+	s.released()
+}
@@ -0,0 +1,94 @@
+package testmanagerd
+
+import (
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/danielpaulus/go-ios/ios"
+	dtx "github.com/danielpaulus/go-ios/ios/dtx_codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pipeDial opens DTX connections over in-memory pipes and keeps the device side of each of them
+type pipeDial struct {
+	mu       sync.Mutex
+	services []string
+	devices  []net.Conn
+}
+
+func (p *pipeDial) dial(_ ios.DeviceEntry, serviceName string) (*dtx.Connection, error) {
+	host, device := net.Pipe()
+	p.mu.Lock()
+	p.services = append(p.services, serviceName)
+	p.devices = append(p.devices, device)
+	p.mu.Unlock()
+	return dtx.NewConnection(ios.NewDeviceConnectionWithRWC(host))
+}
+
+func deviceWithUdid(udid string) ios.DeviceEntry {
+	return ios.DeviceEntry{Properties: ios.DeviceProperties{SerialNumber: udid}}
+}
+
+func TestConcurrentTestSessionsOnOneDevice(t *testing.T) {
+	device := deviceWithUdid("udid")
+	dial := &pipeDial{}
+	wda, err := newTestSession(TestConfig{Device: device, TestRunnerBundleId: "com.facebook.WebDriverAgentRunner.xctrunner"})
+	require.NoError(t, err)
+	uiTests, err := newTestSession(TestConfig{Device: device, TestRunnerBundleId: "com.example.UITests.xctrunner"})
+	require.NoError(t, err)
+	defer uiTests.close()
+	assert.NotEqual(t, wda.id, uiTests.id)
+
+	var wg sync.WaitGroup
+	for _, session := range []*testSession{wda, uiTests} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 2; i++ {
+				_, err := session.connect(dial.dial, device, testmanagerdiOS17)
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+	require.Len(t, wda.connections(), 2)
+	require.Len(t, uiTests.connections(), 2)
+	assert.NotContains(t, uiTests.connections(), wda.connections()[0])
+	assert.NotContains(t, uiTests.connections(), wda.connections()[1])
+
+	wda.close()
+	for _, closed := range wda.closed() {
+		assert.NotNil(t, closed)
+		<-closed
+	}
+	for _, conn := range uiTests.connections() {
+		select {
+		case <-conn.Closed():
+			t.Fatal("closing a session closed a connection of another session")
+		default:
+		}
+	}
+}
+
+func TestTestSessionClaimsTestRunner(t *testing.T) {
+	config := TestConfig{Device: deviceWithUdid("udid"), TestRunnerBundleId: "com.example.UITests.xctrunner"}
+	first, err := newTestSession(config)
+	require.NoError(t, err)
+
+	_, err = newTestSession(config)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "test runner com.example.UITests.xctrunner is already used by test session "+first.id.String())
+	}
+
+	otherDevice, err := newTestSession(TestConfig{Device: deviceWithUdid("other"), TestRunnerBundleId: config.TestRunnerBundleId})
+	require.NoError(t, err)
+	otherDevice.close()
+
+	first.close()
+	first.close()
+	again, err := newTestSession(config)
+	require.NoError(t, err)
+	again.close()
+}
@@ -0,0 +1,158 @@
+package testmanagerd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Masterminds/semver"
+	"github.com/danielpaulus/go-ios/ios"
+	"github.com/danielpaulus/go-ios/ios/tunnel"
+	log "github.com/sirupsen/logrus"
+)
+
+// testTunnelStartTimeout is how long starting a tunnel for a test run may take, which includes accepting the pairing
+// dialog on iOS 17.0 to 17.4 devices that were not paired with the host yet
+const testTunnelStartTimeout = time.Minute
+
+// TunnelMode selects the tunnel RunTestWithConfig starts for iOS 17+ devices without a tunnel, see TestConfig.TunnelMode
+type TunnelMode string
+
+const (
+	// TunnelModeAuto uses the user space network stack for iOS 17.4+ and a TUN interface for older versions
+	TunnelModeAuto TunnelMode = ""
+	// TunnelModeUserspace runs the network stack of the tunnel in user space, which is supported for iOS 17.4+
+	TunnelModeUserspace TunnelMode = "userspace"
+	// TunnelModeKernel creates a TUN interface for the tunnel, which requires root permissions
+	TunnelModeKernel TunnelMode = "kernel"
+	// TunnelModeOff does not start a tunnel, running tests on iOS 17+ devices without RSD information fails
+	TunnelModeOff TunnelMode = "off"
+)
+
+// WithTunnelMode selects the tunnel that is started for iOS 17+ devices without a tunnel, see TestConfig.TunnelMode
+func WithTunnelMode(mode TunnelMode) XCTestRunOption {
+	return func(config *TestConfig) {
+		config.TunnelMode = mode
+	}
+}
+
+// testTunnelStarter provides the tunnel to an iOS 17+ device for a test run
+type testTunnelStarter struct {
+	// agentTunnel returns the tunnel the go-ios agent runs for the device
+	agentTunnel func(udid string) (tunnel.Tunnel, error)
+	// start starts a tunnel to the device, the returned function closes it
+	start func(ctx context.Context, device ios.DeviceEntry, userspaceTUN bool) (tunnel.Tunnel, func() error, error)
+	// connect returns device with the RSD information of the device reachable through t
+	connect func(device ios.DeviceEntry, t tunnel.Tunnel) (ios.DeviceEntry, error)
+}
+
+// startTestTunnel sets up config.Device to be reachable through a tunnel if it runs iOS 17+ and has no RSD
+// information yet. The tunnel of the go-ios agent is used if it runs one for the device, otherwise a tunnel is started
+// as configured by TunnelMode. The returned function closes a started tunnel.
+func startTestTunnel(ctx context.Context, config *TestConfig) (func(), error) {
+	if config.Device.Rsd != nil || config.TunnelMode == TunnelModeOff {
+		return func() {}, nil
+	}
+	version, err := ios.GetProductVersion(config.Device)
+	if err != nil {
+		return nil, err
+	}
+	if version.LessThan(ios.IOS17()) {
+		return func() {}, nil
+	}
+	pairRecordPath := config.TunnelPairRecordPath
+	if pairRecordPath == "" {
+		pairRecordPath = "."
+	}
+	starter := testTunnelStarter{
+		agentTunnel: func(udid string) (tunnel.Tunnel, error) {
+			if !tunnel.IsAgentRunning() {
+				return tunnel.Tunnel{}, errors.New("go-ios agent is not running")
+			}
+			return tunnel.TunnelInfoForDevice(udid, ios.HttpApiHost(), ios.HttpApiPort())
+		},
+		start: func(ctx context.Context, device ios.DeviceEntry, userspaceTUN bool) (tunnel.Tunnel, func() error, error) {
+			pm, err := tunnel.NewPairRecordManager(pairRecordPath)
+			if err != nil {
+				return tunnel.Tunnel{}, nil, err
+			}
+			t, err := tunnel.StartTunnel(ctx, device, pm, userspaceTUN)
+			if err != nil {
+				return tunnel.Tunnel{}, nil, err
+			}
+			return t, t.Close, nil
+		},
+		connect: deviceWithTunnel,
+	}
+	return starter.startTunnel(ctx, config, version)
+}
+
+func (s testTunnelStarter) startTunnel(ctx context.Context, config *TestConfig, version *semver.Version) (func(), error) {
+	udid := config.Device.Properties.SerialNumber
+	if t, err := s.agentTunnel(udid); err == nil {
+		device, err := s.connect(config.Device, t)
+		if err == nil {
+			log.WithField("udid", udid).Info("using the tunnel of the go-ios agent")
+			config.Device = device
+			return func() {}, nil
+		}
+		log.WithFields(log.Fields{"error": err, "udid": udid}).Warn("could not connect to the device through the tunnel of the go-ios agent")
+	}
+
+	var userspaceTUN bool
+	switch config.TunnelMode {
+	case TunnelModeAuto:
+		userspaceTUN = version.GreaterThan(semver.MustParse("17.4.0"))
+	case TunnelModeUserspace:
+		userspaceTUN = true
+	case TunnelModeKernel:
+	default:
+		return nil, fmt.Errorf("unknown tunnel mode '%s'", config.TunnelMode)
+	}
+	log.WithFields(log.Fields{"udid": udid, "userspace": userspaceTUN}).Info("no tunnel to the device, starting one for the test run")
+	startCtx, cancel := context.WithTimeout(ctx, testTunnelStartTimeout)
+	defer cancel()
+	t, closeTunnel, err := s.start(startCtx, config.Device, userspaceTUN)
+	if err != nil {
+		return nil, fmt.Errorf("cannot start a tunnel to the iOS %s device, use 'ios tunnel start' instead: %w", version, err)
+	}
+	device, err := s.connect(config.Device, t)
+	if err != nil {
+		if err := closeTunnel(); err != nil {
+			log.WithError(err).Warn("failed closing the tunnel of the test run")
+		}
+		return nil, fmt.Errorf("cannot connect to the device through the tunnel: %w", err)
+	}
+	config.Device = device
+	return func() {
+		log.WithField("udid", udid).Info("closing the tunnel of the test run")
+		if err := closeTunnel(); err != nil {
+			log.WithError(err).Warn("failed closing the tunnel of the test run")
+		}
+	}, nil
+}
+
+// deviceWithTunnel returns device with the address and the RSD services of the device reachable through t
+func deviceWithTunnel(device ios.DeviceEntry, t tunnel.Tunnel) (ios.DeviceEntry, error) {
+	if t.Address == "" {
+		return ios.DeviceEntry{}, errors.New("tunnel has no device address")
+	}
+	device.UserspaceTUN = t.UserspaceTUN
+	if t.UserspaceTUN {
+		device.UserspaceTUNHost = ios.HttpApiHost()
+		device.UserspaceTUNPort = t.UserspaceTUNPort
+	}
+	rsdService, err := ios.NewWithAddrPortDevice(t.Address, t.RsdPort, device)
+	if err != nil {
+		return ios.DeviceEntry{}, err
+	}
+	defer rsdService.Close()
+	rsdProvider, err := rsdService.Handshake()
+	if err != nil {
+		return ios.DeviceEntry{}, err
+	}
+	device.Address = t.Address
+	device.Rsd = rsdProvider
+	return device, nil
+}
@@ -0,0 +1,111 @@
+package testmanagerd
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Masterminds/semver"
+	"github.com/danielpaulus/go-ios/ios"
+	"github.com/danielpaulus/go-ios/ios/tunnel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTunnelStarter records the tunnels started through it, agent is the tunnel of the go-ios agent if not nil
+type fakeTunnelStarter struct {
+	agent        *tunnel.Tunnel
+	started      []bool
+	closed       int
+	connectError error
+}
+
+func (f *fakeTunnelStarter) starter() testTunnelStarter {
+	return testTunnelStarter{
+		agentTunnel: func(udid string) (tunnel.Tunnel, error) {
+			if f.agent == nil {
+				return tunnel.Tunnel{}, errors.New("go-ios agent is not running")
+			}
+			return *f.agent, nil
+		},
+		start: func(ctx context.Context, device ios.DeviceEntry, userspaceTUN bool) (tunnel.Tunnel, func() error, error) {
+			f.started = append(f.started, userspaceTUN)
+			t := tunnel.Tunnel{Address: "fd00::1", RsdPort: 58783, Udid: device.Properties.SerialNumber}
+			return t, func() error {
+				f.closed++
+				return nil
+			}, nil
+		},
+		connect: func(device ios.DeviceEntry, t tunnel.Tunnel) (ios.DeviceEntry, error) {
+			if f.connectError != nil {
+				return ios.DeviceEntry{}, f.connectError
+			}
+			device.Address = t.Address
+			return device, nil
+		},
+	}
+}
+
+func TestTestTunnel(t *testing.T) {
+	device := ios.DeviceEntry{Properties: ios.DeviceProperties{SerialNumber: "00008110-0001"}}
+
+	t.Run("starts a tunnel and closes it after the run", func(t *testing.T) {
+		fake := &fakeTunnelStarter{}
+		config := TestConfig{Device: device}
+
+		closeTunnel, err := fake.starter().startTunnel(context.Background(), &config, semver.MustParse("17.5.1"))
+
+		require.NoError(t, err)
+		assert.Equal(t, "fd00::1", config.Device.Address)
+		assert.Equal(t, []bool{true}, fake.started, "iOS 17.4+ uses the userspace network stack by default")
+		assert.Equal(t, 0, fake.closed)
+		closeTunnel()
+		assert.Equal(t, 1, fake.closed)
+	})
+
+	t.Run("uses a TUN interface before iOS 17.4", func(t *testing.T) {
+		fake := &fakeTunnelStarter{}
+		config := TestConfig{Device: device}
+
+		_, err := fake.starter().startTunnel(context.Background(), &config, semver.MustParse("17.2.0"))
+
+		require.NoError(t, err)
+		assert.Equal(t, []bool{false}, fake.started)
+	})
+
+	t.Run("uses the configured tunnel mode", func(t *testing.T) {
+		fake := &fakeTunnelStarter{}
+		config := TestConfig{Device: device}
+		WithTunnelMode(TunnelModeKernel)(&config)
+
+		_, err := fake.starter().startTunnel(context.Background(), &config, semver.MustParse("17.5.1"))
+
+		require.NoError(t, err)
+		assert.Equal(t, []bool{false}, fake.started)
+	})
+
+	t.Run("uses the tunnel of the go-ios agent", func(t *testing.T) {
+		fake := &fakeTunnelStarter{agent: &tunnel.Tunnel{Address: "fd00::2", RsdPort: 58783}}
+		config := TestConfig{Device: device}
+
+		closeTunnel, err := fake.starter().startTunnel(context.Background(), &config, semver.MustParse("17.5.1"))
+
+		require.NoError(t, err)
+		assert.Equal(t, "fd00::2", config.Device.Address)
+		assert.Empty(t, fake.started)
+		closeTunnel()
+		assert.Equal(t, 0, fake.closed, "the tunnel of the agent stays open")
+	})
+
+	t.Run("closes the tunnel if the device is not reachable", func(t *testing.T) {
+		fake := &fakeTunnelStarter{connectError: errors.New("connection refused")}
+		config := TestConfig{Device: device}
+
+		_, err := fake.starter().startTunnel(context.Background(), &config, semver.MustParse("17.5.1"))
+
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "connection refused")
+		}
+		assert.Equal(t, 1, fake.closed)
+	})
+}
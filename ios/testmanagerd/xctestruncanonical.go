@@ -0,0 +1,73 @@
+package testmanagerd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// CanonicalXCTestRun parses the xctestrun file and returns a deterministic JSON representation of its test targets.
+// Map keys are sorted, paths are cleaned and test identifiers are normalized and sorted, so equivalent files always
+// produce the same bytes. This can be used to compute cache keys for test runs, f.ex. by hashing the result.
+func CanonicalXCTestRun(xctestrunFilePath string) ([]byte, error) {
+	content, err := os.ReadFile(xctestrunFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("CanonicalXCTestRun: failed to read xctestrun file: %w", err)
+	}
+	return canonicalXCTestRun(content)
+}
+
+func canonicalXCTestRun(content []byte) ([]byte, error) {
+	targets, err := decodeTestTargets(bytes.NewReader(content), "")
+	if err != nil {
+		return nil, fmt.Errorf("CanonicalXCTestRun: %w", err)
+	}
+	canonicalTargets := make([]schemeData, len(targets))
+	for i, target := range targets {
+		canonicalTargets[i] = target.canonical()
+	}
+	// encoding/json writes struct fields in declaration order and map keys sorted
+	canonical, err := json.Marshal(canonicalTargets)
+	if err != nil {
+		return nil, fmt.Errorf("CanonicalXCTestRun: %w", err)
+	}
+	return canonical, nil
+}
+
+// canonical returns a copy of data with cleaned paths and sorted, normalized test identifiers
+func (data schemeData) canonical() schemeData {
+	data.TestBundlePath = canonicalPath(data.TestBundlePath)
+	data.UITargetAppPath = canonicalPath(data.UITargetAppPath)
+	data.OnlyTestIdentifiers = sortedTestIdentifiers(data.OnlyTestIdentifiers)
+	data.SkipTestIdentifiers = sortedTestIdentifiers(data.SkipTestIdentifiers)
+	if len(data.CommandLineArguments) == 0 {
+		data.CommandLineArguments = nil
+	}
+	if len(data.EnvironmentVariables) == 0 {
+		data.EnvironmentVariables = nil
+	}
+	if len(data.TestingEnvironmentVariables) == 0 {
+		data.TestingEnvironmentVariables = nil
+	}
+	return data
+}
+
+func canonicalPath(p string) string {
+	if p == "" {
+		return ""
+	}
+	return path.Clean(strings.ReplaceAll(p, "\\", "/"))
+}
+
+func sortedTestIdentifiers(identifiers []string) []string {
+	if len(identifiers) == 0 {
+		return nil
+	}
+	sorted := NormalizeTestIdentifiers(identifiers)
+	sort.Strings(sorted)
+	return sorted
+}
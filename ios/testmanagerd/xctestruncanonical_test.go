@@ -0,0 +1,73 @@
+package testmanagerd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// canonicalTestRunFixture returns a version 1 xctestrun file whose environment entries are written in the given order
+func canonicalTestRunFixture(envKeys []string, onlyTestIdentifiers []string) string {
+	var env strings.Builder
+	for _, key := range envKeys {
+		fmt.Fprintf(&env, "<key>%s</key><string>value-%s</string>\n", key, key)
+	}
+	var identifiers strings.Builder
+	for _, identifier := range onlyTestIdentifiers {
+		fmt.Fprintf(&identifiers, "<string>%s</string>\n", identifier)
+	}
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<plist version="1.0">
+<dict>
+	<key>RunnerTests</key>
+	<dict>
+		<key>BlueprintName</key>
+		<string>RunnerTests</string>
+		<key>TestBundlePath</key>
+		<string>__TESTHOST__/./PlugIns//RunnerTests.xctest</string>
+		<key>TestHostBundleIdentifier</key>
+		<string>com.example.Runner</string>
+		<key>IsUITestBundle</key>
+		<true/>
+		<key>EnvironmentVariables</key>
+		<dict>
+` + env.String() + `		</dict>
+		<key>OnlyTestIdentifiers</key>
+		<array>
+` + identifiers.String() + `		</array>
+	</dict>
+	<key>__xctestrun_metadata__</key>
+	<dict>
+		<key>FormatVersion</key>
+		<integer>1</integer>
+	</dict>
+</dict>
+</plist>`
+}
+
+func TestCanonicalXCTestRunIsDeterministic(t *testing.T) {
+	var envKeys []string
+	for i := 0; i < 50; i++ {
+		envKeys = append(envKeys, fmt.Sprintf("ENV_%02d", i))
+	}
+	reversedKeys := make([]string, len(envKeys))
+	for i, key := range envKeys {
+		reversedKeys[len(envKeys)-1-i] = key
+	}
+
+	expected, err := canonicalXCTestRun([]byte(canonicalTestRunFixture(envKeys, []string{"LoginTests/testLogin", "CartTests.testCheckout()"})))
+	assert.NoError(t, err)
+	for i := 0; i < 20; i++ {
+		canonical, err := canonicalXCTestRun([]byte(canonicalTestRunFixture(reversedKeys, []string{"CartTests/testCheckout", "LoginTests/testLogin"})))
+		assert.NoError(t, err)
+		assert.Equal(t, string(expected), string(canonical))
+	}
+
+	var targets []map[string]any
+	assert.NoError(t, json.Unmarshal(expected, &targets))
+	assert.Equal(t, "__TESTHOST__/PlugIns/RunnerTests.xctest", targets[0]["TestBundlePath"])
+	assert.Equal(t, []any{"CartTests/testCheckout", "LoginTests/testLogin"}, targets[0]["OnlyTestIdentifiers"])
+}
@@ -2,7 +2,10 @@ package testmanagerd
 
 import (
 	"github.com/danielpaulus/go-ios/ios"
+	"github.com/danielpaulus/go-ios/ios/installationproxy"
+	"github.com/danielpaulus/go-ios/ios/nskeyedarchiver"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -125,7 +128,7 @@ func createAndParseXCTestRunFile(t *testing.T) schemeData {
 	tempFile.Close()
 
 	// Act: Use the codec to parse the temp file
-	xcTestRunData, err := parseFile(tempFile.Name())
+	xcTestRunData, err := parseFile(tempFile.Name(), "")
 
 	// Assert: Verify the parsed data
 	assert.NoError(t, err, "Failed to parse .xctestrun file")
@@ -144,6 +147,17 @@ func TestTestBundlePath(t *testing.T) {
 	assert.Equal(t, "__TESTHOST__/PlugIns/RunnerTests.xctest", xcTestRunData.TestBundlePath, "TestBundlePath mismatch")
 }
 
+func TestBlueprintProvider(t *testing.T) {
+	xcTestRunData := createAndParseXCTestRunFile(t)
+	assert.Equal(t, "Runner", xcTestRunData.BlueprintProviderName, "BlueprintProviderName mismatch")
+	assert.Equal(t, "Runner.xcodeproj", xcTestRunData.BlueprintProviderRelativePath, "BlueprintProviderRelativePath mismatch")
+
+	testConfig, err := xcTestRunData.buildTestConfig(ios.DeviceEntry{}, &TestListener{}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "Runner", testConfig.BlueprintProviderName)
+	assert.Equal(t, "Runner.xcodeproj", testConfig.BlueprintProviderRelativePath)
+}
+
 func TestEnvironmentVariables(t *testing.T) {
 	xcTestRunData := createAndParseXCTestRunFile(t)
 	assert.Equal(t, map[string]any{
@@ -189,13 +203,13 @@ func TestIsUITestBundle(t *testing.T) {
 	assert.Equal(t, true, xcTestRunData.IsUITestBundle, "IsUITestBundle mismatch")
 }
 
-func TestParseXCTestRunNotSupportedForFormatVersionOtherThanOne(t *testing.T) {
+func TestParseXCTestRunNotSupportedForFormatVersionOtherThanOneAndTwo(t *testing.T) {
 	// Arrange: Create a temporary .xctestrun file with mock data
 	tempFile, err := os.CreateTemp("", "testfile*.xctestrun")
 	assert.NoError(t, err, "Failed to create temp file")
 	defer os.Remove(tempFile.Name()) // Cleanup after test
 
-	xcTestRunFileFormatVersion2 := `
+	xcTestRunFileFormatVersion3 := `
 		<?xml version="1.0" encoding="UTF-8"?>
 		<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
 		<plist version="1.0">
@@ -203,20 +217,361 @@ func TestParseXCTestRunNotSupportedForFormatVersionOtherThanOne(t *testing.T) {
 			<key>__xctestrun_metadata__</key>
 			<dict>
 				<key>FormatVersion</key>
-				<integer>2</integer>
+				<integer>3</integer>
 			</dict>
 		</dict>
 		</plist>
 	`
-	_, err = tempFile.WriteString(xcTestRunFileFormatVersion2)
+	_, err = tempFile.WriteString(xcTestRunFileFormatVersion3)
 	assert.NoError(t, err, "Failed to write mock data to temp file")
 	tempFile.Close()
 
 	// Act: Use the codec to parse the temp file
-	_, err = parseFile(tempFile.Name())
+	_, err = parseFile(tempFile.Name(), "")
 
 	// Assert the Error Message
-	assert.Equal(t, "the provided .xctestrun file used format version 2, which is not yet supported", err.Error(), "Error Message mismatch")
+	assert.Equal(t, "the provided .xctestrun format version 3 is not supported", err.Error(), "Error Message mismatch")
+}
+
+func TestDefaultTestExecutionTimeAllowance(t *testing.T) {
+	xcTestRunData := createAndParseXCTestRunFile(t)
+	assert.Equal(t, uint64(600), xcTestRunData.DefaultTestExecutionTimeAllowance, "DefaultTestExecutionTimeAllowance mismatch")
+	assert.Equal(t, false, xcTestRunData.TestTimeoutsEnabled, "TestTimeoutsEnabled mismatch")
+}
+
+// Helper function to write the given content to a temporary .xctestrun file and parse it
+func parseXCTestRunContent(t *testing.T, content string) (schemeData, error) {
+	tempFile, err := os.CreateTemp("", "testfile*.xctestrun")
+	assert.NoError(t, err, "Failed to create temp file")
+	defer os.Remove(tempFile.Name()) // Cleanup after test
+
+	_, err = tempFile.WriteString(content)
+	assert.NoError(t, err, "Failed to write mock data to temp file")
+	tempFile.Close()
+
+	return parseFile(tempFile.Name(), "")
+}
+
+const xcTestRunFileFormatVersion2 = `
+	<?xml version="1.0" encoding="UTF-8"?>
+	<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+	<plist version="1.0">
+	<dict>
+		<key>ContainerInfo</key>
+		<dict>
+			<key>ContainerName</key>
+			<string>Runner</string>
+			<key>SchemeName</key>
+			<string>RunnerUITestsScheme</string>
+		</dict>
+		<key>TestConfigurations</key>
+		<array>
+			<dict>
+				<key>Name</key>
+				<string>Test Scheme Action</string>
+				<key>TestTargets</key>
+				<array>
+					<dict>
+						<key>BlueprintName</key>
+						<string>RunnerUITests</string>
+						<key>BlueprintProviderName</key>
+						<string>Runner</string>
+						<key>BlueprintProviderRelativePath</key>
+						<string>ios/Runner.xcodeproj</string>
+						<key>CommandLineArguments</key>
+						<array>
+							<string>-verbose</string>
+						</array>
+						<key>DefaultTestExecutionTimeAllowance</key>
+						<integer>300</integer>
+						<key>EnvironmentVariables</key>
+						<dict>
+							<key>TERM</key>
+							<string>dumb</string>
+						</dict>
+						<key>IsUITestBundle</key>
+						<true/>
+						<key>OnlyTestIdentifiers</key>
+						<array>
+							<string>LoginTests/testLogin</string>
+						</array>
+						<key>PreferredScreenCaptureFormat</key>
+						<string>screenshots</string>
+						<key>TestBundlePath</key>
+						<string>__TESTHOST__/PlugIns/RunnerUITests.xctest</string>
+						<key>TestHostBundleIdentifier</key>
+						<string>com.example.myApp.RunnerUITests.xctrunner</string>
+						<key>TestHostPath</key>
+						<string>__TESTROOT__/Release-iphoneos/RunnerUITests-Runner.app</string>
+						<key>TestTimeoutsEnabled</key>
+						<true/>
+						<key>TestingEnvironmentVariables</key>
+						<dict>
+							<key>XCInjectBundleInto</key>
+							<string>unused</string>
+						</dict>
+						<key>UITargetAppPath</key>
+						<string>__TESTROOT__/Release-iphoneos/Runner.app</string>
+					</dict>
+				</array>
+			</dict>
+		</array>
+		<key>TestPlan</key>
+		<dict>
+			<key>IsDefault</key>
+			<true/>
+			<key>Name</key>
+			<string>RunnerUITests</string>
+		</dict>
+		<key>__xctestrun_metadata__</key>
+		<dict>
+			<key>FormatVersion</key>
+			<integer>2</integer>
+		</dict>
+	</dict>
+	</plist>
+`
+
+func TestParseXCTestRunFormatVersion2(t *testing.T) {
+	xcTestRunData, err := parseXCTestRunContent(t, xcTestRunFileFormatVersion2)
+
+	assert.NoError(t, err, "Failed to parse .xctestrun file")
+	assert.Equal(t, "com.example.myApp.RunnerUITests.xctrunner", xcTestRunData.TestHostBundleIdentifier, "TestHostBundleIdentifier mismatch")
+	assert.Equal(t, "__TESTHOST__/PlugIns/RunnerUITests.xctest", xcTestRunData.TestBundlePath, "TestBundlePath mismatch")
+	assert.Equal(t, "__TESTROOT__/Release-iphoneos/Runner.app", xcTestRunData.UITargetAppPath, "UITargetAppPath mismatch")
+	assert.Equal(t, []string{"-verbose"}, xcTestRunData.CommandLineArguments, "CommandLineArguments mismatch")
+	assert.Equal(t, []string{"LoginTests/testLogin"}, xcTestRunData.OnlyTestIdentifiers, "OnlyTestIdentifiers mismatch")
+	assert.Equal(t, true, xcTestRunData.IsUITestBundle, "IsUITestBundle mismatch")
+	assert.Equal(t, true, xcTestRunData.TestTimeoutsEnabled, "TestTimeoutsEnabled mismatch")
+	assert.Equal(t, uint64(300), xcTestRunData.DefaultTestExecutionTimeAllowance, "DefaultTestExecutionTimeAllowance mismatch")
+	assert.Equal(t, "Runner", xcTestRunData.ContainerName, "ContainerName mismatch")
+	assert.Equal(t, "RunnerUITestsScheme", xcTestRunData.SchemeName, "SchemeName mismatch")
+}
+
+func TestParseTestPlanContainerInfo(t *testing.T) {
+	testPlan, err := decodeTestPlan([]byte(xcTestRunFileFormatVersion2))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Runner", testPlan.ContainerName)
+	assert.Equal(t, "RunnerUITestsScheme", testPlan.SchemeName)
+	assert.Equal(t, "Runner", testPlan.Configurations[0].Targets[0].BlueprintProviderName)
+	assert.Equal(t, "ios/Runner.xcodeproj", testPlan.Configurations[0].Targets[0].BlueprintProviderRelativePath)
+}
+
+func TestParseXCTestRunFormatVersion2WithoutTestTargets(t *testing.T) {
+	_, err := parseXCTestRunContent(t, `<?xml version="1.0" encoding="UTF-8"?>
+		<plist version="1.0">
+		<dict>
+			<key>TestConfigurations</key>
+			<array/>
+			<key>__xctestrun_metadata__</key>
+			<dict>
+				<key>FormatVersion</key>
+				<integer>2</integer>
+			</dict>
+		</dict>
+		</plist>`)
+
+	assert.EqualError(t, err, "the provided .xctestrun file does not contain any test targets")
+}
+
+func TestParseXCTestRunFormatVersion2InheritsConfigurationEnvironment(t *testing.T) {
+	targets, err := parseVersion2([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+		<plist version="1.0">
+		<dict>
+			<key>TestConfigurations</key>
+			<array>
+				<dict>
+					<key>Name</key>
+					<string>Staging</string>
+					<key>EnvironmentVariables</key>
+					<dict>
+						<key>API_URL</key>
+						<string>https://staging.example.com</string>
+						<key>LOG_LEVEL</key>
+						<string>info</string>
+					</dict>
+					<key>TestingEnvironmentVariables</key>
+					<dict>
+						<key>DYLD_INSERT_LIBRARIES</key>
+						<string>__TESTHOST__/Frameworks/libXCTestBundleInject.dylib</string>
+					</dict>
+					<key>TestTargets</key>
+					<array>
+						<dict>
+							<key>BlueprintName</key>
+							<string>LoginUITests</string>
+							<key>EnvironmentVariables</key>
+							<dict>
+								<key>LOG_LEVEL</key>
+								<string>debug</string>
+							</dict>
+						</dict>
+						<dict>
+							<key>BlueprintName</key>
+							<string>CartUITests</string>
+						</dict>
+					</array>
+				</dict>
+			</array>
+			<key>__xctestrun_metadata__</key>
+			<dict>
+				<key>FormatVersion</key>
+				<integer>2</integer>
+			</dict>
+		</dict>
+		</plist>`), "")
+
+	assert.NoError(t, err)
+	assert.Len(t, targets, 2)
+	assert.Equal(t, map[string]any{"API_URL": "https://staging.example.com", "LOG_LEVEL": "debug"}, targets[0].EnvironmentVariables, "target variables take precedence")
+	assert.Equal(t, map[string]any{"API_URL": "https://staging.example.com", "LOG_LEVEL": "info"}, targets[1].EnvironmentVariables)
+	for _, target := range targets {
+		assert.Equal(t, map[string]any{"DYLD_INSERT_LIBRARIES": "__TESTHOST__/Frameworks/libXCTestBundleInject.dylib"}, target.TestingEnvironmentVariables, target.BlueprintName)
+	}
+}
+
+func TestConfigDefaultTestExecutionTimeAllowance(t *testing.T) {
+	xcTestRunData, err := parseXCTestRunContent(t, xcTestRunFileFormatVersion2)
+	assert.NoError(t, err, "Failed to parse .xctestrun file")
+
+	testConfig, err := xcTestRunData.buildTestConfig(ios.DeviceEntry{}, &TestListener{}, nil)
+
+	assert.NoError(t, err, "Error converting to TestConfig")
+	assert.Equal(t, true, testConfig.TestTimeoutsEnabled, "TestTimeoutsEnabled mismatch")
+	assert.Equal(t, uint64(300), testConfig.DefaultTestExecutionTimeAllowance, "DefaultTestExecutionTimeAllowance mismatch")
+	assert.Len(t, testConfig.xcTestConfigurationOptions(), 2)
+}
+
+func TestConfigUITargetAppLaunchConfiguration(t *testing.T) {
+	targets, err := decodeTestTargets(strings.NewReader(`<?xml version="1.0" encoding="UTF-8"?>
+	<plist version="1.0">
+	<dict>
+		<key>TestConfigurations</key>
+		<array>
+			<dict>
+				<key>Name</key>
+				<string>Test Scheme Action</string>
+				<key>TestTargets</key>
+				<array>
+					<dict>
+						<key>BlueprintName</key>
+						<string>LoginUITests</string>
+						<key>TestHostBundleIdentifier</key>
+						<string>com.example.myApp.LoginUITests.xctrunner</string>
+						<key>TestBundlePath</key>
+						<string>__TESTHOST__/PlugIns/LoginUITests.xctest</string>
+						<key>IsUITestBundle</key>
+						<true/>
+						<key>UITargetAppCommandLineArguments</key>
+						<array>
+							<string>-UITesting</string>
+							<string>YES</string>
+						</array>
+						<key>UITargetAppEnvironmentVariables</key>
+						<dict>
+							<key>API_URL</key>
+							<string>https://staging.example.com</string>
+						</dict>
+					</dict>
+				</array>
+			</dict>
+		</array>
+		<key>__xctestrun_metadata__</key>
+		<dict>
+			<key>FormatVersion</key>
+			<integer>2</integer>
+		</dict>
+	</dict>
+	</plist>`), "")
+	assert.NoError(t, err)
+
+	testConfig, err := targets[0].buildTestConfig(ios.DeviceEntry{}, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"-UITesting", "YES"}, testConfig.TargetAppArgs)
+	assert.Equal(t, map[string]any{"API_URL": "https://staging.example.com"}, testConfig.TargetAppEnv)
+	assert.Empty(t, testConfig.Args, "the arguments of the target app are not passed to the test runner")
+
+	contents := map[string]interface{}{}
+	for _, opt := range testConfig.xcTestConfigurationOptions() {
+		opt(contents)
+	}
+	assert.Equal(t, []interface{}{"-UITesting", "YES"}, contents["targetApplicationArguments"])
+	assert.Equal(t, map[string]any{"API_URL": "https://staging.example.com"}, contents["targetApplicationEnvironment"])
+}
+
+func TestConfigPreferredScreenCaptureFormatPerTarget(t *testing.T) {
+	content := `<?xml version="1.0" encoding="UTF-8"?>
+	<plist version="1.0">
+	<dict>
+		<key>TestConfigurations</key>
+		<array>
+			<dict>
+				<key>Name</key>
+				<string>Test Scheme Action</string>
+				<key>TestTargets</key>
+				<array>
+					<dict>
+						<key>BlueprintName</key>
+						<string>LoginUITests</string>
+						<key>TestHostBundleIdentifier</key>
+						<string>com.example.myApp.LoginUITests.xctrunner</string>
+						<key>TestBundlePath</key>
+						<string>__TESTHOST__/PlugIns/LoginUITests.xctest</string>
+						<key>IsUITestBundle</key>
+						<true/>
+						<key>PreferredScreenCaptureFormat</key>
+						<string>screenshots</string>
+					</dict>
+					<dict>
+						<key>BlueprintName</key>
+						<string>CheckoutUITests</string>
+						<key>TestHostBundleIdentifier</key>
+						<string>com.example.myApp.CheckoutUITests.xctrunner</string>
+						<key>TestBundlePath</key>
+						<string>__TESTHOST__/PlugIns/CheckoutUITests.xctest</string>
+						<key>IsUITestBundle</key>
+						<true/>
+						<key>PreferredScreenCaptureFormat</key>
+						<string>screenRecording</string>
+					</dict>
+				</array>
+			</dict>
+		</array>
+		<key>__xctestrun_metadata__</key>
+		<dict>
+			<key>FormatVersion</key>
+			<integer>2</integer>
+		</dict>
+	</dict>
+	</plist>`
+
+	targets, err := decodeTestTargets(strings.NewReader(content), "")
+	assert.NoError(t, err, "Failed to parse .xctestrun file")
+	if !assert.Len(t, targets, 2) {
+		return
+	}
+
+	loginConfig, err := targets[0].buildTestConfig(ios.DeviceEntry{}, nil, nil)
+	assert.NoError(t, err)
+	checkoutConfig, err := targets[1].buildTestConfig(ios.DeviceEntry{}, nil, nil)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "LoginUITests.xctest", loginConfig.XctestConfigName)
+	assert.Equal(t, "screenshots", loginConfig.PreferredScreenCaptureFormat)
+	assert.Equal(t, "CheckoutUITests.xctest", checkoutConfig.XctestConfigName)
+	assert.Equal(t, "screenRecording", checkoutConfig.PreferredScreenCaptureFormat)
+
+	loginContents := map[string]interface{}{}
+	for _, opt := range loginConfig.xcTestConfigurationOptions() {
+		opt(loginContents)
+	}
+	checkoutContents := map[string]interface{}{}
+	for _, opt := range checkoutConfig.xcTestConfigurationOptions() {
+		opt(checkoutContents)
+	}
+	assert.Equal(t, nskeyedarchiver.ScreenCaptureFormatScreenshots, loginContents["preferredScreenCaptureFormat"])
+	assert.Equal(t, nskeyedarchiver.ScreenCaptureFormatScreenRecording, checkoutContents["preferredScreenCaptureFormat"])
 }
 
 // Helper function to create testConfig from parsed mock data
@@ -231,7 +586,7 @@ func createTestConfigFromParsedMockData(t *testing.T) (TestConfig, ios.DeviceEnt
 	mockListener := &TestListener{}
 
 	// Act: Convert XCTestRunData to TestConfig
-	testConfig, err := xcTestRunData.buildTestConfig(mockDevice, mockListener)
+	testConfig, err := xcTestRunData.buildTestConfig(mockDevice, mockListener, nil)
 
 	// Assert: Validate the returned TestConfig
 	assert.NoError(t, err, "Error converting to TestConfig")
@@ -288,6 +643,11 @@ func TestConfigXcTest(t *testing.T) {
 	assert.Equal(t, false, testConfig.XcTest, "XcTest mismatch")
 }
 
+func TestConfigProductModuleName(t *testing.T) {
+	testConfig, _, _ := createTestConfigFromParsedMockData(t)
+	assert.Equal(t, "RunnerTests", testConfig.ProductModuleName, "ProductModuleName mismatch")
+}
+
 func TestConfigDevice(t *testing.T) {
 	testConfig, mockDevice, _ := createTestConfigFromParsedMockData(t)
 	assert.Equal(t, mockDevice, testConfig.Device, "Device mismatch")
@@ -297,3 +657,196 @@ func TestConfigListener(t *testing.T) {
 	testConfig, _, mockListener := createTestConfigFromParsedMockData(t)
 	assert.Equal(t, mockListener, testConfig.Listener, "Listener mismatch")
 }
+
+func TestConfigExpandsUITargetAppContainer(t *testing.T) {
+	data := schemeData{
+		TestHostBundleIdentifier: "com.example.myApp.UITests.xctrunner",
+		TestBundlePath:           "__TESTHOST__/PlugIns/RunnerUITests.xctest",
+		UITargetAppPath:          "__TESTROOT__/Release-iphoneos/Runner.app",
+		IsUITestBundle:           true,
+		EnvironmentVariables: map[string]any{
+			"FIXTURES_DIR": UITargetAppContainerToken + "/Documents/fixtures",
+			"TERM":         "dumb",
+		},
+		TestingEnvironmentVariables: map[string]any{
+			"APP_HOME": UITargetAppContainerToken,
+		},
+	}
+	installedApps := []installationproxy.AppInfo{
+		{
+			CFBundleIdentifier:   "com.example.myApp.UITests.xctrunner",
+			Path:                 "/private/var/containers/Bundle/Application/AAAA/RunnerUITests-Runner.app",
+			EnvironmentVariables: map[string]interface{}{"HOME": "/private/var/mobile/Containers/Data/Application/BBBB"},
+		},
+		{
+			CFBundleIdentifier:   "com.example.myApp",
+			Path:                 "/private/var/containers/Bundle/Application/CCCC/Runner.app",
+			EnvironmentVariables: map[string]interface{}{"HOME": "/private/var/mobile/Containers/Data/Application/DDDD"},
+		},
+	}
+
+	testConfig, err := data.buildTestConfig(ios.DeviceEntry{}, &TestListener{}, installedApps)
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"FIXTURES_DIR": "/private/var/mobile/Containers/Data/Application/DDDD/Documents/fixtures",
+		"TERM":         "dumb",
+		"APP_HOME":     "/private/var/mobile/Containers/Data/Application/DDDD",
+	}, testConfig.Env)
+}
+
+func TestConfigFailsIfUITargetAppIsNotInstalled(t *testing.T) {
+	data := schemeData{
+		UITargetAppPath:      "__TESTROOT__/Release-iphoneos/Runner.app",
+		IsUITestBundle:       true,
+		EnvironmentVariables: map[string]any{"APP_HOME": UITargetAppContainerToken},
+	}
+
+	_, err := data.buildTestConfig(ios.DeviceEntry{}, &TestListener{}, nil)
+
+	assert.Error(t, err)
+}
+
+func TestParseTestPlanWithOnlyTestIdentifiers(t *testing.T) {
+	content := `<?xml version="1.0" encoding="UTF-8"?>
+	<plist version="1.0">
+	<dict>
+		<key>TestConfigurations</key>
+		<array>
+			<dict>
+				<key>Name</key>
+				<string>Smoke</string>
+				<key>TestTargets</key>
+				<array>
+					<dict>
+						<key>BlueprintName</key>
+						<string>RunnerUITests</string>
+						<key>OnlyTestIdentifiers</key>
+						<array>
+							<string>LoginTests/testLogin</string>
+							<string>CheckoutTests.testCheckout</string>
+						</array>
+					</dict>
+				</array>
+			</dict>
+			<dict>
+				<key>Name</key>
+				<string>Full</string>
+				<key>TestTargets</key>
+				<array>
+					<dict>
+						<key>BlueprintName</key>
+						<string>RunnerUITests</string>
+						<key>SkipTestIdentifiers</key>
+						<array>
+							<string>FlakyTests</string>
+						</array>
+					</dict>
+				</array>
+			</dict>
+		</array>
+		<key>TestPlan</key>
+		<dict>
+			<key>IsDefault</key>
+			<true/>
+			<key>Name</key>
+			<string>RunnerUITests</string>
+		</dict>
+		<key>__xctestrun_metadata__</key>
+		<dict>
+			<key>FormatVersion</key>
+			<integer>2</integer>
+		</dict>
+	</dict>
+	</plist>`
+
+	testPlan, err := decodeTestPlan([]byte(content))
+
+	assert.NoError(t, err)
+	assert.Equal(t, TestPlan{
+		Name:      "RunnerUITests",
+		IsDefault: true,
+		Configurations: []TestPlanConfiguration{
+			{
+				Name: "Smoke",
+				Targets: []TestPlanTarget{{
+					BlueprintName:       "RunnerUITests",
+					OnlyTestIdentifiers: []string{"LoginTests/testLogin", "CheckoutTests/testCheckout"},
+				}},
+			},
+			{
+				Name: "Full",
+				Targets: []TestPlanTarget{{
+					BlueprintName:       "RunnerUITests",
+					SkipTestIdentifiers: []string{"FlakyTests"},
+				}},
+			},
+		},
+	}, testPlan)
+}
+
+func TestParseTestPlanFailsForFormatVersion1(t *testing.T) {
+	_, err := decodeTestPlan([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+		<plist version="1.0">
+		<dict>
+			<key>__xctestrun_metadata__</key>
+			<dict>
+				<key>FormatVersion</key>
+				<integer>1</integer>
+			</dict>
+		</dict>
+		</plist>`))
+
+	assert.EqualError(t, err, "test plans are only available in .xctestrun format version 2, got version 1")
+}
+
+func TestLanguageLaunchArguments(t *testing.T) {
+	data := schemeData{
+		TestHostBundleIdentifier: "com.example.myApp.RunnerUITests.xctrunner",
+		TestBundlePath:           "__TESTHOST__/PlugIns/RunnerUITests.xctest",
+		CommandLineArguments:     []string{"-verbose", "-AppleLanguages", "(fr)"},
+		TestLanguage:             "en",
+		TestRegion:               "US",
+	}
+
+	t.Run("uses TestLanguage and TestRegion of the xctestrun file", func(t *testing.T) {
+		testConfig, err := data.buildTestConfig(ios.DeviceEntry{}, nil, nil)
+		assert.NoError(t, err)
+
+		assert.Equal(t, []string{"-verbose", "-AppleLanguages", "(en)", "-AppleLocale", "en_US"}, testConfig.launchArguments())
+	})
+
+	t.Run("run option takes precedence over the xctestrun file", func(t *testing.T) {
+		testConfig, err := data.buildTestConfig(ios.DeviceEntry{}, nil, nil)
+		assert.NoError(t, err)
+
+		WithLanguage("de", "AT")(&testConfig)
+
+		assert.Equal(t, []string{"-verbose", "-AppleLanguages", "(de)", "-AppleLocale", "de_AT"}, testConfig.launchArguments())
+	})
+
+	t.Run("keeps the arguments if no language is set", func(t *testing.T) {
+		testConfig := TestConfig{Args: []string{"-verbose"}}
+
+		assert.Equal(t, []string{"-verbose"}, testConfig.launchArguments())
+	})
+}
+
+func TestConfigRejectsIllegalEnvironmentVariableNames(t *testing.T) {
+	data := schemeData{
+		IsUITestBundle:       true,
+		EnvironmentVariables: map[string]any{"VALID": "1", "KEY=VALUE": "2"},
+	}
+
+	_, err := data.buildTestConfig(ios.DeviceEntry{}, &TestListener{}, nil)
+
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "'KEY=VALUE'")
+	}
+}
+
+func TestValidateEnvironmentVariableNames(t *testing.T) {
+	assert.NoError(t, ValidateEnvironmentVariableNames(map[string]any{"DYLD_PRINT_STATISTICS": "1"}))
+	assert.EqualError(t, ValidateEnvironmentVariableNames(map[string]any{"": "1"}), "environment variable name must not be empty")
+	assert.EqualError(t, ValidateEnvironmentVariableNames(map[string]any{"A=B": "1"}), "environment variable name 'A=B' must not contain '='")
+}
@@ -4,14 +4,18 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/danielpaulus/go-ios/ios"
+	"github.com/danielpaulus/go-ios/ios/installationproxy"
+	log "github.com/sirupsen/logrus"
 	"howett.net/plist"
 	"io"
 	"maps"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 )
 
-// xctestrunutils provides utilities for parsing `.xctestrun` files with FormatVersion 1.
+// xctestrunutils provides utilities for parsing `.xctestrun` files with FormatVersion 1 and 2.
 // It simplifies the extraction of test configurations and metadata into structured objects (`xCTestRunData`),
 // enabling efficient setup for iOS test execution.
 //
@@ -19,26 +23,61 @@ import (
 // - Parses `.xctestrun` files to extract test metadata and configurations.
 // - Supports building `TestConfig` objects for test execution.
 //
-// Note: Only `.xctestrun` files with `FormatVersion` 1 are supported. For other versions,
+// Note: Only `.xctestrun` files with `FormatVersion` 1 and 2 are supported. For other versions,
 // contributions or requests for support can be made in the relevant GitHub repository.
 
 // xCTestRunData represents the structure of an .xctestrun file
 
+// UITargetAppContainerToken can be used in the values of EnvironmentVariables and TestingEnvironmentVariables of UI test
+// bundles. It is replaced with the path of the UI target app's data container on the device before the test runner is launched.
+const UITargetAppContainerToken = "__UITARGETAPP_CONTAINER__"
+
 // schemeData represents the structure of a scheme-specific test configuration
 type schemeData struct {
-	TestHostBundleIdentifier    string
-	TestBundlePath              string
-	SkipTestIdentifiers         []string
-	OnlyTestIdentifiers         []string
-	IsUITestBundle              bool
-	CommandLineArguments        []string
-	EnvironmentVariables        map[string]any
-	TestingEnvironmentVariables map[string]any
+	BlueprintName                   string
+	BlueprintProviderName           string
+	BlueprintProviderRelativePath   string
+	TestHostBundleIdentifier        string
+	TestBundlePath                  string
+	TestHostPath                    string
+	DependentProductPaths           []string
+	UITargetAppPath                 string
+	UITargetAppCommandLineArguments []string
+	UITargetAppEnvironmentVariables map[string]any
+	SkipTestIdentifiers             []string
+	OnlyTestIdentifiers             []string
+	IsUITestBundle                  bool
+	// IsAppHostedTestBundle is false for test targets without host application, it is missing in most files
+	IsAppHostedTestBundle             *bool
+	CommandLineArguments              []string
+	EnvironmentVariables              map[string]any
+	TestingEnvironmentVariables       map[string]any
+	TestTimeoutsEnabled               bool
+	DefaultTestExecutionTimeAllowance uint64
+	PreferredScreenCaptureFormat      string
+	TestLanguage                      string
+	TestRegion                        string
+	ProductModuleName                 string
+	// ClangProfileDataDirectoryPath is the directory on the build host Xcode collects the code coverage profiles in
+	ClangProfileDataDirectoryPath string
+	// CodeCoverageBuildableInfos are the binaries instrumented for code coverage. In FormatVersion 2 they are listed
+	// once for all test targets
+	CodeCoverageBuildableInfos []CodeCoverageBuildableInfo
+	// ContainerName and SchemeName are set from the ContainerInfo of .xctestrun files with FormatVersion 2
+	ContainerName string `plist:"-"`
+	SchemeName    string `plist:"-"`
+	// TestPlanName and TestPlanIsDefault are set from the TestPlan of .xctestrun files with FormatVersion 2
+	TestPlanName      string `plist:"-"`
+	TestPlanIsDefault bool   `plist:"-"`
+	// xctestrunDir is the directory of the .xctestrun file the target was read from, the default __TESTROOT__
+	xctestrunDir string
 }
 
-func (data schemeData) buildTestConfig(device ios.DeviceEntry, listener *TestListener) (TestConfig, error) {
-	testsToRun := data.OnlyTestIdentifiers
-	testsToSkip := data.SkipTestIdentifiers
+// buildTestConfig converts the parsed scheme into a TestConfig. installedApps is only needed if the environment
+// references the UI target app container with UITargetAppContainerToken and can be nil otherwise.
+func (data schemeData) buildTestConfig(device ios.DeviceEntry, listener *TestListener, installedApps []installationproxy.AppInfo) (TestConfig, error) {
+	testsToRun := NormalizeTestIdentifiers(data.OnlyTestIdentifiers)
+	testsToSkip := NormalizeTestIdentifiers(data.SkipTestIdentifiers)
 
 	testEnv := make(map[string]any)
 	if data.IsUITestBundle {
@@ -46,6 +85,22 @@ func (data schemeData) buildTestConfig(device ios.DeviceEntry, listener *TestLis
 		maps.Copy(testEnv, data.TestingEnvironmentVariables)
 	}
 
+	if data.referencesUITargetAppContainer() {
+		containerPath, err := data.uiTargetAppContainerPath(installedApps)
+		if err != nil {
+			return TestConfig{}, err
+		}
+		for key, value := range testEnv {
+			if s, ok := value.(string); ok {
+				testEnv[key] = strings.ReplaceAll(s, UITargetAppContainerToken, containerPath)
+			}
+		}
+	}
+
+	if err := ValidateEnvironmentVariableNames(testEnv); err != nil {
+		return TestConfig{}, fmt.Errorf("invalid environment in xctestrun file: %w", err)
+	}
+
 	// Extract only the file name
 	var testBundlePath = filepath.Base(data.TestBundlePath)
 
@@ -60,42 +115,142 @@ func (data schemeData) buildTestConfig(device ios.DeviceEntry, listener *TestLis
 		XcTest:             !data.IsUITestBundle,
 		Device:             device,
 		Listener:           listener,
+
+		TestTimeoutsEnabled:               data.TestTimeoutsEnabled,
+		DefaultTestExecutionTimeAllowance: data.DefaultTestExecutionTimeAllowance,
+		PreferredScreenCaptureFormat:      data.PreferredScreenCaptureFormat,
+		Language:                          data.TestLanguage,
+		Region:                            data.TestRegion,
+		ProductModuleName:                 data.ProductModuleName,
+		BlueprintProviderName:             data.BlueprintProviderName,
+		BlueprintProviderRelativePath:     data.BlueprintProviderRelativePath,
+		CodeCoverageDir:                   data.ClangProfileDataDirectoryPath,
+		CodeCoverageBuildableInfos:        data.CodeCoverageBuildableInfos,
+		TestPlanName:                      data.TestPlanName,
+		TestPlanIsDefault:                 data.TestPlanIsDefault,
+	}
+	if data.IsUITestBundle {
+		testConfig.TargetAppArgs = data.UITargetAppCommandLineArguments
+		testConfig.TargetAppEnv = data.UITargetAppEnvironmentVariables
 	}
 
 	return testConfig, nil
 }
 
-// parseFile reads the .xctestrun file and decodes it into a map
-func parseFile(filePath string) (schemeData, error) {
+// isAppHosted returns true unless the target is a test bundle without host application
+func (data schemeData) isAppHosted() bool {
+	return data.IsAppHostedTestBundle == nil || *data.IsAppHostedTestBundle
+}
+
+// ValidateEnvironmentVariableNames checks that all keys of env can be used as environment variable names when launching
+// a process on the device. Empty names and names containing '=' make the launch fail, so they are rejected with an
+// error naming the offending key.
+func ValidateEnvironmentVariableNames(env map[string]any) error {
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name == "" {
+			return fmt.Errorf("environment variable name must not be empty")
+		}
+		if strings.Contains(name, "=") {
+			return fmt.Errorf("environment variable name '%s' must not contain '='", name)
+		}
+	}
+	return nil
+}
+
+// referencesUITargetAppContainer returns true if any environment variable passed to a UI test bundle contains the UITargetAppContainerToken
+func (data schemeData) referencesUITargetAppContainer() bool {
+	if !data.IsUITestBundle {
+		return false
+	}
+	for _, env := range []map[string]any{data.EnvironmentVariables, data.TestingEnvironmentVariables} {
+		for _, value := range env {
+			if s, ok := value.(string); ok && strings.Contains(s, UITargetAppContainerToken) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// uiTargetAppContainerPath looks up the installed UI target app by the name of its bundle and returns its data container path
+func (data schemeData) uiTargetAppContainerPath(installedApps []installationproxy.AppInfo) (string, error) {
+	if data.UITargetAppPath == "" {
+		return "", fmt.Errorf("environment references %s, but the xctestrun file does not specify a UITargetAppPath", UITargetAppContainerToken)
+	}
+	appBundleName := filepath.Base(data.UITargetAppPath)
+	for _, app := range installedApps {
+		if filepath.Base(app.Path) != appBundleName {
+			continue
+		}
+		if home, ok := app.EnvironmentVariables["HOME"].(string); ok && home != "" {
+			return home, nil
+		}
+		return "", fmt.Errorf("could not determine the container path of UI target app %s", app.CFBundleIdentifier)
+	}
+	return "", fmt.Errorf("did not find UI target app '%s' on device. Is it installed?", appBundleName)
+}
+
+// parseFile reads the .xctestrun file and decodes it into a map. configurationName selects the test configuration of
+// files with FormatVersion 2, see TestConfig.TestConfigurationName
+func parseFile(filePath string, configurationName string) (schemeData, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
 		return schemeData{}, fmt.Errorf("failed to open xctestrun file: %w", err)
 	}
 	defer file.Close()
-	return decode(file)
+	data, err := decode(file, configurationName)
+	data.xctestrunDir = filepath.Dir(filePath)
+	return data, err
 }
 
-// decode decodes the binary xctestrun content into the xCTestRunData struct
-func decode(r io.Reader) (schemeData, error) {
+// decode decodes the binary xctestrun content into the xCTestRunData struct. If the file contains
+// more than one test target, only the first one is returned.
+func decode(r io.Reader, configurationName string) (schemeData, error) {
+	targets, err := decodeTestTargets(r, configurationName)
+	if err != nil {
+		return schemeData{}, err
+	}
+	if len(targets) > 1 {
+		log.Warnf("xctestrun file contains %d test targets, using the first target %s", len(targets), targets[0].BlueprintName)
+	}
+	return targets[0], nil
+}
+
+// decodeTestTargets decodes the binary xctestrun content and returns all test targets of the test configuration with
+// the given name, each of them with its own configuration. If configurationName is empty, the first test configuration
+// with test targets is used.
+func decodeTestTargets(r io.Reader, configurationName string) ([]schemeData, error) {
 	// Read the entire content once
 	xctestrunFileContent, err := io.ReadAll(r)
 	if err != nil {
-		return schemeData{}, fmt.Errorf("unable to read xctestrun content: %w", err)
+		return nil, fmt.Errorf("unable to read xctestrun content: %w", err)
 	}
 
 	// First, we only parse the version property of the xctestrun file. The rest of the parsing depends on this version.
 	version, err := getFormatVersion(xctestrunFileContent)
 	if err != nil {
-		return schemeData{}, err
+		return nil, err
 	}
 
 	switch version {
 	case 1:
-		return parseVersion1(xctestrunFileContent)
+		if configurationName != "" {
+			return nil, fmt.Errorf("test configurations are only available in .xctestrun format version 2, cannot select %s", configurationName)
+		}
+		target, err := parseVersion1(xctestrunFileContent)
+		if err != nil {
+			return nil, err
+		}
+		return []schemeData{target}, nil
 	case 2:
-		return schemeData{}, fmt.Errorf("the provided .xctestrun file used format version 2, which is not yet supported")
+		return parseVersion2(xctestrunFileContent, configurationName)
 	default:
-		return schemeData{}, fmt.Errorf("the provided .xctestrun format version %d is not supported", version)
+		return nil, fmt.Errorf("the provided .xctestrun format version %d is not supported", version)
 	}
 }
 
@@ -153,3 +308,152 @@ func parseVersion1(content []byte) (schemeData, error) {
 	}
 	return schemeData{}, nil
 }
+
+// xCTestRunVersion2 is the static structure of xctestrun files in version 2. They list the test targets of each
+// test configuration of the test plan the file was generated for.
+type xCTestRunVersion2 struct {
+	ContainerInfo struct {
+		ContainerName string
+		SchemeName    string
+	}
+	TestPlan struct {
+		Name      string
+		IsDefault bool
+	}
+	CodeCoverageBuildableInfos []CodeCoverageBuildableInfo
+	TestConfigurations         []struct {
+		Name string
+		// EnvironmentVariables and TestingEnvironmentVariables of a configuration are inherited by all of its
+		// test targets
+		EnvironmentVariables        map[string]any
+		TestingEnvironmentVariables map[string]any
+		TestTargets                 []schemeData
+	}
+}
+
+// TestPlan describes the tests that a test plan selects in each of its configurations
+type TestPlan struct {
+	Name      string
+	IsDefault bool
+	// ContainerName is the name of the project or workspace and SchemeName the name of the scheme the
+	// xctestrun file was built for, f.ex. to label reports
+	ContainerName  string
+	SchemeName     string
+	Configurations []TestPlanConfiguration
+}
+
+// TestPlanConfiguration contains the test selection of all test targets of a single test plan configuration
+type TestPlanConfiguration struct {
+	Name    string
+	Targets []TestPlanTarget
+}
+
+// TestPlanTarget contains the tests of a test target that are selected by a test plan configuration. If
+// OnlyTestIdentifiers is empty, all tests except the ones in SkipTestIdentifiers are selected.
+type TestPlanTarget struct {
+	BlueprintName string
+	// BlueprintProviderName is the name of the Xcode project defining the test target and BlueprintProviderRelativePath
+	// the path of the project relative to the source root, f.ex. "Runner.xcodeproj"
+	BlueprintProviderName         string
+	BlueprintProviderRelativePath string
+	OnlyTestIdentifiers           []string
+	SkipTestIdentifiers           []string
+}
+
+// ParseTestPlan reads the test plan and the test selection of all its configurations from a xctestrun file with
+// FormatVersion 2. The identifiers are normalized with NormalizeTestIdentifier so they can be used in a TestConfig directly.
+func ParseTestPlan(xctestrunFilePath string) (TestPlan, error) {
+	content, err := os.ReadFile(xctestrunFilePath)
+	if err != nil {
+		return TestPlan{}, fmt.Errorf("ParseTestPlan: failed to read xctestrun file: %w", err)
+	}
+	return decodeTestPlan(content)
+}
+
+func decodeTestPlan(content []byte) (TestPlan, error) {
+	version, err := getFormatVersion(content)
+	if err != nil {
+		return TestPlan{}, err
+	}
+	if version != 2 {
+		return TestPlan{}, fmt.Errorf("test plans are only available in .xctestrun format version 2, got version %d", version)
+	}
+
+	var xctestrun xCTestRunVersion2
+	if _, err := plist.Unmarshal(content, &xctestrun); err != nil {
+		return TestPlan{}, fmt.Errorf("failed to unmarshal plist: %w", err)
+	}
+
+	testPlan := TestPlan{
+		Name:          xctestrun.TestPlan.Name,
+		IsDefault:     xctestrun.TestPlan.IsDefault,
+		ContainerName: xctestrun.ContainerInfo.ContainerName,
+		SchemeName:    xctestrun.ContainerInfo.SchemeName,
+	}
+	for _, configuration := range xctestrun.TestConfigurations {
+		planConfiguration := TestPlanConfiguration{Name: configuration.Name}
+		for _, target := range configuration.TestTargets {
+			planConfiguration.Targets = append(planConfiguration.Targets, TestPlanTarget{
+				BlueprintName:                 target.BlueprintName,
+				BlueprintProviderName:         target.BlueprintProviderName,
+				BlueprintProviderRelativePath: target.BlueprintProviderRelativePath,
+				OnlyTestIdentifiers:           NormalizeTestIdentifiers(target.OnlyTestIdentifiers),
+				SkipTestIdentifiers:           NormalizeTestIdentifiers(target.SkipTestIdentifiers),
+			})
+		}
+		testPlan.Configurations = append(testPlan.Configurations, planConfiguration)
+	}
+	return testPlan, nil
+}
+
+func parseVersion2(content []byte, configurationName string) ([]schemeData, error) {
+	var xctestrun xCTestRunVersion2
+	if _, err := plist.Unmarshal(content, &xctestrun); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal plist: %w", err)
+	}
+
+	var names []string
+	for _, configuration := range xctestrun.TestConfigurations {
+		names = append(names, configuration.Name)
+		if configurationName != "" && configuration.Name != configurationName {
+			continue
+		}
+		if len(configuration.TestTargets) == 0 {
+			if configurationName != "" {
+				return nil, fmt.Errorf("the test configuration %s does not contain any test targets", configurationName)
+			}
+			continue
+		}
+		if configurationName == "" && len(xctestrun.TestConfigurations) > 1 {
+			log.Warnf("xctestrun file contains multiple test configurations, using configuration %s. Select another one with WithTestConfiguration", configuration.Name)
+		}
+		targets := configuration.TestTargets
+		for i := range targets {
+			targets[i].ContainerName = xctestrun.ContainerInfo.ContainerName
+			targets[i].SchemeName = xctestrun.ContainerInfo.SchemeName
+			targets[i].TestPlanName = xctestrun.TestPlan.Name
+			targets[i].TestPlanIsDefault = xctestrun.TestPlan.IsDefault
+			if len(targets[i].CodeCoverageBuildableInfos) == 0 {
+				targets[i].CodeCoverageBuildableInfos = xctestrun.CodeCoverageBuildableInfos
+			}
+			targets[i].EnvironmentVariables = inheritEnvironment(configuration.EnvironmentVariables, targets[i].EnvironmentVariables)
+			targets[i].TestingEnvironmentVariables = inheritEnvironment(configuration.TestingEnvironmentVariables, targets[i].TestingEnvironmentVariables)
+		}
+		return targets, nil
+	}
+	if configurationName != "" {
+		return nil, fmt.Errorf("the provided .xctestrun file does not contain the test configuration %s, available configurations: %s", configurationName, strings.Join(names, ", "))
+	}
+	return nil, fmt.Errorf("the provided .xctestrun file does not contain any test targets")
+}
+
+// inheritEnvironment merges the environment of a test configuration into the environment of one of its targets.
+// Variables defined by the target take precedence.
+func inheritEnvironment(configurationEnv map[string]any, targetEnv map[string]any) map[string]any {
+	if len(configurationEnv) == 0 {
+		return targetEnv
+	}
+	merged := maps.Clone(configurationEnv)
+	maps.Copy(merged, targetEnv)
+	return merged
+}
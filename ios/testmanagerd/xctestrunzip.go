@@ -0,0 +1,56 @@
+package testmanagerd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/danielpaulus/go-ios/ios"
+	log "github.com/sirupsen/logrus"
+)
+
+// WithWorkDir sets the directory temporary files of the test run are created in, see TestConfig.WorkDir
+func WithWorkDir(dir string) XCTestRunOption {
+	return func(config *TestConfig) {
+		config.WorkDir = dir
+	}
+}
+
+// ExtractXCTestRun extracts a zip archive containing an .xctestrun file, f.ex. the zipped products of
+// 'xcodebuild build-for-testing', into a new temporary directory inside workDir and returns the path of the
+// .xctestrun file. If workDir is empty, the default directory for temporary files is used.
+// The returned cleanup function removes the extracted files and must be called when the test run is done.
+func ExtractXCTestRun(zipPath string, workDir string) (string, func(), error) {
+	if workDir != "" {
+		if err := os.MkdirAll(workDir, 0o755); err != nil {
+			return "", nil, fmt.Errorf("ExtractXCTestRun: cannot create work directory: %w", err)
+		}
+	}
+	dir, err := os.MkdirTemp(workDir, "xctestrun")
+	if err != nil {
+		return "", nil, fmt.Errorf("ExtractXCTestRun: cannot create temporary directory: %w", err)
+	}
+	cleanup := func() {
+		if err := os.RemoveAll(dir); err != nil {
+			log.WithFields(log.Fields{"dir": dir}).Warn("failed removing extracted xctestrun directory")
+		}
+	}
+	files, _, err := ios.Unzip(zipPath, dir)
+	if err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("ExtractXCTestRun: cannot extract %s: %w", zipPath, err)
+	}
+	for _, file := range files {
+		if strings.HasSuffix(file, ".xctestrun") && !strings.Contains(file, "__MACOSX") {
+			return file, cleanup, nil
+		}
+	}
+	cleanup()
+	return "", nil, fmt.Errorf("ExtractXCTestRun: %s does not contain an .xctestrun file", zipPath)
+}
+
+// isZippedXCTestRun returns true if path points to a zip archive instead of an .xctestrun file
+func isZippedXCTestRun(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".zip")
+}
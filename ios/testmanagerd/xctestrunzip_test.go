@@ -0,0 +1,73 @@
+package testmanagerd
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeZip(t *testing.T, files map[string]string) string {
+	zipPath := filepath.Join(t.TempDir(), "products.zip")
+	f, err := os.Create(zipPath)
+	require.NoError(t, err)
+	defer f.Close()
+	w := zip.NewWriter(f)
+	for name, content := range files {
+		fw, err := w.Create(name)
+		require.NoError(t, err)
+		_, err = fw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	return zipPath
+}
+
+func TestExtractXCTestRunUsesWorkDir(t *testing.T) {
+	zipPath := writeZip(t, map[string]string{
+		"Products/Runner_iphoneos17.0-arm64.xctestrun":            "xctestrun",
+		"Products/Debug-iphoneos/Runner.app/Info.plist":           "plist",
+		"__MACOSX/Products/._Runner_iphoneos17.0-arm64.xctestrun": "resource fork",
+	})
+	workDir := filepath.Join(t.TempDir(), "ci-work")
+
+	xctestrunPath, cleanup, err := ExtractXCTestRun(zipPath, workDir)
+
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(xctestrunPath, workDir+string(os.PathSeparator)), "expected %s to be inside %s", xctestrunPath, workDir)
+	assert.Equal(t, "Runner_iphoneos17.0-arm64.xctestrun", filepath.Base(xctestrunPath))
+	content, err := os.ReadFile(xctestrunPath)
+	require.NoError(t, err)
+	assert.Equal(t, "xctestrun", string(content))
+
+	cleanup()
+	entries, err := os.ReadDir(workDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "cleanup should remove all extracted files")
+}
+
+func TestExtractXCTestRunWithoutXCTestRunFile(t *testing.T) {
+	zipPath := writeZip(t, map[string]string{"Products/Debug-iphoneos/Runner.app/Info.plist": "plist"})
+	workDir := t.TempDir()
+
+	_, _, err := ExtractXCTestRun(zipPath, workDir)
+
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "does not contain an .xctestrun file")
+	}
+	entries, err := os.ReadDir(workDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "the extracted files should be removed on errors")
+}
+
+func TestWithWorkDir(t *testing.T) {
+	config := TestConfig{}
+	WithWorkDir("/ci/work")(&config)
+	assert.Equal(t, "/ci/work", config.WorkDir)
+	assert.True(t, isZippedXCTestRun("/ci/products.ZIP"))
+	assert.False(t, isZippedXCTestRun("/ci/Runner.xctestrun"))
+}
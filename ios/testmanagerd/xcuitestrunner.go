@@ -7,10 +7,13 @@ import (
 	"io"
 	"maps"
 	"path"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/Masterminds/semver"
 	"github.com/danielpaulus/go-ios/ios/appservice"
+	"github.com/danielpaulus/go-ios/ios/crashreport"
 
 	"github.com/danielpaulus/go-ios/ios/house_arrest"
 
@@ -234,6 +237,12 @@ type TestConfig struct {
 	Env map[string]any
 	// Args are passed to the test runner as launch arguments
 	Args []string
+	// Language is the language the test runner is started with (f.ex. "de"), regardless of the device language.
+	// It is passed as -AppleLanguages launch argument. If empty, the device language is used
+	Language string
+	// Region is the region the test runner is started with (f.ex. "DE" or "de_DE"). It is passed as -AppleLocale
+	// launch argument. If empty, the device region is used
+	Region string
 	// TestsToRun specifies a list of tests that should be executed. All other tests are ignored. To execute all tests
 	// pass nil.
 	// The format of the values is {PRODUCT_MODULE_NAME}.{CLASS}/{METHOD} where {PRODUCT_MODULE_NAME} and {METHOD} are
@@ -241,39 +250,451 @@ type TestConfig struct {
 	TestsToRun []string
 	// TestsToSkip specifies a list of tests that should be skipped. See TestsToRun for the format
 	TestsToSkip []string
+	// EnumeratedTests are the identifiers of all tests of the test bundle, see TestIdentifiersOf. They are needed to
+	// expand TestsToRun and TestsToSkip containing wildcards, see ExpandTestIdentifiers. If nil, the tests are
+	// enumerated from the test bundle of the .xctestrun file or InjectedTestBundlePath on the host
+	EnumeratedTests []string
+	// hostTestBundlePath is the path of the test bundle of the .xctestrun file on the host
+	hostTestBundlePath string
+	// Repeat runs the tests this many times after another in new sessions of the test runner, f.ex. to reproduce flaky
+	// tests. The results of all iterations are returned and summarized in TestListener.IterationResults. Together
+	// with RepeatUntilFailure it is the maximum number of iterations. 0 and 1 run the tests once
+	Repeat int
+	// RepeatUntilFailure repeats the tests until a test fails or the session of an iteration ends with an error. The
+	// tests are repeated until ctx is done if Repeat is 0
+	RepeatUntilFailure bool
+	// TunnelMode controls how a tunnel is started for iOS 17+ devices that have no RSD information yet. Unless it is
+	// TunnelModeOff, the tunnel of the go-ios agent is used or a tunnel is started for the test run and closed after it
+	TunnelMode TunnelMode
+	// TunnelPairRecordPath is the directory of the pair records of tunnels to iOS 17.0 to 17.4 devices that are
+	// started for the test run, defaults to the current directory
+	TunnelPairRecordPath string
 	// XcTest needs to be set to true if the TestRunnerBundleId is a unit test and not a UI test
 	XcTest bool
+	// TestTimeoutsEnabled enforces DefaultTestExecutionTimeAllowance for each test
+	TestTimeoutsEnabled bool
+	// DefaultTestExecutionTimeAllowance is the time in seconds a single test is allowed to run, if TestTimeoutsEnabled is set
+	DefaultTestExecutionTimeAllowance uint64
+	// PreferredScreenCaptureFormat is either "screenRecording" or "screenshots" and controls how XCTest captures the
+	// screen during UI tests. If empty, the XCTest default (screen recordings) is used
+	PreferredScreenCaptureFormat string
+	// FailureScreenshotMaxDimension downscales the screenshots XCTest captures on test failures so that their width and
+	// height do not exceed this value, to reduce the storage needed for attachments. Screenshots requested explicitly by
+	// the tests keep their full resolution. 0 keeps all screenshots in full resolution
+	FailureScreenshotMaxDimension int
+	// OutputDir is a directory the results of the test run are stored in. It contains the JUnit report (junit.xml),
+	// the attachments of all test cases (attachments/), the screen recordings of UI tests named by test case
+	// (recordings/) and the log output of the test runner (runner.log).
+	// Attachments are stored there instead of the attachments directory of the Listener. If empty, no results
+	// directory is written
+	OutputDir string
+	// FailureScreenshotDir enables capturing a screenshot of the device each time a test fails, independent of
+	// the attachments XCTest creates. The screenshots are stored in this directory as <class>-<method>.png and added
+	// to the attachments of the test case. Failures within two seconds after a screenshot do not trigger a new one.
+	// Requires a Listener. If empty, no screenshots are captured
+	FailureScreenshotDir string
+	// AttachmentsDir stores every attachment of the test run, like screenshots, screen recordings and crash logs, in
+	// this directory as <class>/<method>/<attachment name>. The attachments XCTest creates by itself, which it deletes
+	// by default, are kept as well. Requires a Listener. If empty, the attachments are stored in the attachments
+	// directory of the Listener or OutputDir
+	AttachmentsDir string
+	// InstallTestProducts installs the test host, the UI test runner and the UI target app listed in the .xctestrun
+	// file with zipconduit before the tests run, so that only the build products directory and the .xctestrun file
+	// are needed
+	InstallTestProducts bool
+	// TestRoot is the build products directory __TESTROOT__ in the paths of the .xctestrun file is replaced with if
+	// InstallTestProducts is set. If empty, the directory of the .xctestrun file is used
+	TestRoot string
+	// CodeCoverage collects the raw code coverage profiles (.profraw) the test runner and the UI target app write
+	// when they are built with code coverage enabled. They are pulled from the app containers after the run and
+	// stored in CodeCoverageDir as <bundleID>-<pid>.profraw, see TestListener.CodeCoverageFiles. Merge them with
+	// llvm-profdata and pass the binaries of CodeCoverageBuildableInfos to llvm-cov to produce a report
+	CodeCoverage bool
+	// CodeCoverageDir is the directory the code coverage profiles are stored in. It is set from the
+	// ClangProfileDataDirectoryPath of the .xctestrun file
+	CodeCoverageDir string
+	// CodeCoverageBuildableInfos are the binaries instrumented for code coverage, set from the .xctestrun file
+	CodeCoverageBuildableInfos []CodeCoverageBuildableInfo
+	// TestEnvironmentOverrides maps test identifiers (see TestsToRun for the format) to environment variables that
+	// are only set for these tests. The test runner is restarted with the overridden environment for each of them
+	// after all other tests ran. The results of all sessions are combined
+	TestEnvironmentOverrides map[string]map[string]any
+	// WorkDir is the directory temporary files like extracted zipped .xctestrun files are created in. They are
+	// removed after the test run. If empty, the default directory for temporary files is used
+	WorkDir string
+	// TestConfigurationName selects the test configuration of .xctestrun files with FormatVersion 2 whose test targets
+	// are run. If empty, the first configuration containing test targets is used
+	TestConfigurationName string
+	// TestPlanName and TestPlanIsDefault are set from the TestPlan of .xctestrun files with FormatVersion 2. If
+	// TestPlanName is set with WithTestPlan, running a file generated for another test plan fails
+	TestPlanName      string
+	TestPlanIsDefault bool
+	// IdleTimeout fails the run with ErrTestSessionIdle if no message is received from testmanagerd for this long
+	// while the tests run, instead of waiting forever for a device that stopped responding. 0 disables the check
+	IdleTimeout time.Duration
+	// RunAllTargets runs every test target of the .xctestrun file after another instead of only the first one. The
+	// results of each target are summarized in TestListener.TargetResults
+	RunAllTargets bool
+	// TargetAppArgs and TargetAppEnv are the launch arguments and environment of the UI target app, set from
+	// UITargetAppCommandLineArguments and UITargetAppEnvironmentVariables of the xctestrun file. XCUIApplication uses
+	// them when it launches the app, like when running the tests in Xcode
+	TargetAppArgs []string
+	TargetAppEnv  map[string]any
+	// EventStream receives every event of the test run, like started and finished tests, failures, attachments and
+	// log messages, as newline delimited JSON encoded TestEvent while the tests run. It requires a Listener
+	EventStream io.Writer
+	// ProductModuleName is the module of the test target, set from the xctestrun file. It is used to detect
+	// TestsToRun and TestsToSkip that belong to another module, see ModuleValidation
+	ProductModuleName string
+	// BlueprintProviderName is the name of the Xcode project defining the test target and BlueprintProviderRelativePath
+	// the path of the project relative to the source root, f.ex. "Runner.xcodeproj". Both are set from the xctestrun
+	// file and allow linking results back to the project
+	BlueprintProviderName         string
+	BlueprintProviderRelativePath string
+	// ModuleValidation controls whether identifiers of other modules are ignored, logged as warning (the default) or
+	// fail the test run. It is only applied to tests started from an xctestrun file
+	ModuleValidation ModuleValidation
+	// Architecture is the slice of the test runner that is launched on devices supporting more than one, f.ex.
+	// "arm64" or "arm64e". If empty, the device picks its preferred slice
+	Architecture string
+	// RandomOrder lets XCTest run the tests in random order using RandomOrderSeed, see WithRandomOrder
+	RandomOrder bool
+	// RandomOrderSeed is the seed of the random order, running with the same seed reproduces the order
+	RandomOrderSeed uint64
+	// PeakMemory samples the memory footprint of the test runner and the app under test during the run and records
+	// the peak of each test case in TestCase.PeakMemory
+	PeakMemory bool
+	// PeakCPU samples the CPU usage of the test runner and the app under test during the run and records the peak of
+	// each test case in TestCase.PeakCPU
+	PeakCPU bool
+	// InjectedTestBundlePath is the path on the host of a .xctest bundle that is injected into an installed test runner,
+	// f.ex. a unit test target without host application. The bundle is copied to the container of TestRunnerBundleId
+	// and loaded by the runner instead of the bundle in its PlugIns directory. iOS has no xctest agent to run bare
+	// bundles, so an installed test runner app is always needed. If TestRunnerBundleId is empty, an installed app with
+	// a bundle id ending in .xctrunner is used. XctestConfigName defaults to the name of the bundle and XcTest is implied
+	InjectedTestBundlePath string
+	// TestBundleHost is the bundle id of the installed test runner the test targets of an .xctestrun file that are not
+	// hosted by an app (IsAppHostedTestBundle is false) are injected into, see InjectedTestBundlePath. If empty, an
+	// installed test runner of the device is picked
+	TestBundleHost string
+	// deviceTestBundlePath is the path of the uploaded InjectedTestBundlePath on the device
+	deviceTestBundlePath string
+	// deviceLocale is the locale of the device read before the test run, see resolveDeviceLocale
+	deviceLocale *ios.DeviceLocale
+	// TestLogs captures the syslog of the device while each test runs and writes the messages of the test runner and
+	// the app under test to TestLogDir as <class>-<method>.log. The log file is added to the attachments of the test
+	// case. Requires a Listener
+	TestLogs bool
+	// TestLogDir is the directory the per test logs are stored in. If empty, the logs/ directory of OutputDir is used
+	TestLogDir string
+	// ScreenRecordingDir enables recording the screen of the device while each test runs, independent of the screen
+	// recordings XCTest creates. The recordings are stored in this directory as <class>-<method>.mp4 made of the
+	// screenshots of the device as Motion JPEG, at the frame rate the device delivers screenshots at. They are added
+	// to the attachments of the test case. Requires a Listener. If empty, the screen is not recorded
+	ScreenRecordingDir string
+	// CrashReports downloads the crash reports of the test runner and the app under test that the device writes while a
+	// test case fails or crashes. They are stored in CrashReportDir as <class>-<method>-<report name> and added to the
+	// attachments of the test case after the run. Requires a Listener
+	CrashReports bool
+	// CrashReportDir is the directory the crash reports are stored in. If empty, the crashreports/ directory of
+	// OutputDir is used
+	CrashReportDir string
+	// RunnerExitCode attaches debugserver to the test runner to record its exit code in TestListener.RunnerExitCode.
+	// The runner is given runnerExitTimeout to exit by itself after the tests finished before it is killed
+	RunnerExitCode bool
 	// The device on which the test is executed
 	Device ios.DeviceEntry
 	// The listener for receiving results
 	Listener *TestListener
 }
 
-func StartXCTestWithConfig(ctx context.Context, xctestrunFilePath string, device ios.DeviceEntry, listener *TestListener) ([]TestSuite, error) {
-	results, err := parseFile(xctestrunFilePath)
+// xcTestConfigurationOptions returns the options for the XCTestConfiguration that is sent to the test runner
+func (c TestConfig) xcTestConfigurationOptions() []nskeyedarchiver.XCTestConfigurationOption {
+	var opts []nskeyedarchiver.XCTestConfigurationOption
+	if c.TestTimeoutsEnabled && c.DefaultTestExecutionTimeAllowance > 0 {
+		opts = append(opts, nskeyedarchiver.WithDefaultTestExecutionTimeAllowance(c.DefaultTestExecutionTimeAllowance))
+	}
+	switch c.PreferredScreenCaptureFormat {
+	case "":
+	case "screenRecording":
+		opts = append(opts, nskeyedarchiver.WithPreferredScreenCaptureFormat(nskeyedarchiver.ScreenCaptureFormatScreenRecording))
+	case "screenshots":
+		opts = append(opts, nskeyedarchiver.WithPreferredScreenCaptureFormat(nskeyedarchiver.ScreenCaptureFormatScreenshots))
+	default:
+		log.Warnf("unknown PreferredScreenCaptureFormat %s, using the default format", c.PreferredScreenCaptureFormat)
+	}
+	if c.RandomOrder {
+		opts = append(opts, nskeyedarchiver.WithRandomExecutionOrdering(c.RandomOrderSeed))
+	}
+	if len(c.TargetAppArgs) > 0 {
+		opts = append(opts, nskeyedarchiver.WithTargetApplicationArguments(c.TargetAppArgs))
+	}
+	if len(c.TargetAppEnv) > 0 {
+		opts = append(opts, nskeyedarchiver.WithTargetApplicationEnvironment(c.TargetAppEnv))
+	}
+	if c.AttachmentsDir != "" {
+		opts = append(opts, nskeyedarchiver.WithKeepSystemAttachments())
+	}
+	if c.deviceTestBundlePath != "" {
+		opts = append(opts, nskeyedarchiver.WithTestBundleURL(c.deviceTestBundlePath))
+	}
+	return opts
+}
+
+// testBundlePath returns the path of the test bundle on the device, which is the uploaded injected test bundle or the
+// bundle in the PlugIns directory of the test runner at runnerPath
+func (c TestConfig) testBundlePath(runnerPath string) string {
+	if c.deviceTestBundlePath != "" {
+		return c.deviceTestBundlePath
+	}
+	return runnerPath + "/PlugIns/" + c.XctestConfigName
+}
+
+// launchArguments returns Args with the arguments for Language and Region added. They replace the values for
+// -AppleLanguages and -AppleLocale that are already part of Args.
+func (c TestConfig) launchArguments() []string {
+	var overridden []string
+	if c.Language != "" {
+		overridden = append(overridden, "-AppleLanguages")
+	}
+	if c.Region != "" {
+		overridden = append(overridden, "-AppleLocale")
+	}
+	if len(overridden) == 0 {
+		return c.Args
+	}
+
+	args := make([]string, 0, len(c.Args)+4)
+	for i := 0; i < len(c.Args); i++ {
+		if slices.Contains(overridden, c.Args[i]) {
+			// skip the value of the argument too
+			i++
+			continue
+		}
+		args = append(args, c.Args[i])
+	}
+	if c.Language != "" {
+		args = append(args, "-AppleLanguages", fmt.Sprintf("(%s)", c.Language))
+	}
+	if c.Region != "" {
+		locale := c.Region
+		if c.Language != "" && !strings.Contains(locale, "_") {
+			locale = c.Language + "_" + locale
+		}
+		args = append(args, "-AppleLocale", locale)
+	}
+	return args
+}
+
+// XCTestRunOption changes the TestConfig that is created from a .xctestrun file. Options take precedence over the
+// values of the file.
+type XCTestRunOption func(config *TestConfig)
+
+// WithLanguage runs the tests with the given language and region instead of the TestLanguage and TestRegion of the
+// .xctestrun file. Empty values keep the value of the file.
+func WithLanguage(language string, region string) XCTestRunOption {
+	return func(config *TestConfig) {
+		if language != "" {
+			config.Language = language
+		}
+		if region != "" {
+			config.Region = region
+		}
+	}
+}
+
+// StartXCTestWithConfig runs the tests of an .xctestrun file or of a zip archive containing one, see ExtractXCTestRun
+func StartXCTestWithConfig(ctx context.Context, xctestrunFilePath string, device ios.DeviceEntry, listener *TestListener, opts ...XCTestRunOption) ([]TestSuite, error) {
+	var options TestConfig
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if isZippedXCTestRun(xctestrunFilePath) {
+		extractedPath, cleanup, err := ExtractXCTestRun(xctestrunFilePath, options.WorkDir)
+		if err != nil {
+			return nil, fmt.Errorf("StartXCTestWithConfig: %w", err)
+		}
+		defer cleanup()
+		xctestrunFilePath = extractedPath
+	}
+	if options.InstallTestProducts {
+		if err := installTestProducts(device, xctestrunFilePath, options); err != nil {
+			return nil, fmt.Errorf("StartXCTestWithConfig: %w", err)
+		}
+	}
+	if options.RunAllTargets {
+		return startAllTargets(ctx, xctestrunFilePath, device, listener, options.TestConfigurationName, opts...)
+	}
+	results, err := parseFile(xctestrunFilePath, options.TestConfigurationName)
 	if err != nil {
 		log.Errorf("Error parsing xctestrun file: %v", err)
 		return nil, err
 	}
-
-	testConfig, err := results.buildTestConfig(device, listener)
+	testConfig, err := testConfigForTarget(results, device, listener, opts...)
 	if err != nil {
-		log.Errorf("Error while constructing the test config: %v", err)
 		return nil, err
 	}
-
 	return RunTestWithConfig(ctx, testConfig)
 }
 
+// testConfigForTarget builds the TestConfig of a test target of an .xctestrun file and applies opts to it
+func testConfigForTarget(results schemeData, device ios.DeviceEntry, listener *TestListener, opts ...XCTestRunOption) (TestConfig, error) {
+	var installedApps []installationproxy.AppInfo
+	if results.referencesUITargetAppContainer() {
+		installationProxy, err := installationproxy.New(device)
+		if err != nil {
+			return TestConfig{}, fmt.Errorf("StartXCTestWithConfig: cannot connect to installation proxy: %w", err)
+		}
+		installedApps, err = installationProxy.BrowseUserApps()
+		installationProxy.Close()
+		if err != nil {
+			return TestConfig{}, fmt.Errorf("StartXCTestWithConfig: cannot browse user apps: %w", err)
+		}
+	}
+
+	testConfig, err := results.buildTestConfig(device, listener, installedApps)
+	if err != nil {
+		log.Errorf("Error while constructing the test config: %v", err)
+		return TestConfig{}, err
+	}
+	for _, opt := range opts {
+		opt(&testConfig)
+	}
+	testRoot := testConfig.TestRoot
+	if testRoot == "" {
+		testRoot = results.xctestrunDir
+	}
+	if testRoot != "" {
+		testConfig.hostTestBundlePath = results.testBundlePath(testRoot)
+	}
+	if !results.isAppHosted() {
+		// without TestBundleHost, RunTestWithConfig picks an installed test runner of the device
+		testConfig.TestRunnerBundleId = testConfig.TestBundleHost
+		testConfig.InjectedTestBundlePath = results.testBundlePath(testRoot)
+	}
+	if testConfig.TestPlanName != results.TestPlanName {
+		return TestConfig{}, fmt.Errorf("StartXCTestWithConfig: %w", testPlanMismatch(results.TestPlanName, testConfig.TestPlanName))
+	}
+	if err := ValidateEnvironmentVariableNames(testConfig.Env); err != nil {
+		return TestConfig{}, fmt.Errorf("StartXCTestWithConfig: %w", err)
+	}
+	if err := validateTestIdentifierModules(testConfig); err != nil {
+		return TestConfig{}, fmt.Errorf("StartXCTestWithConfig: %w", err)
+	}
+	return testConfig, nil
+}
+
 func RunTestWithConfig(ctx context.Context, testConfig TestConfig) ([]TestSuite, error) {
+	if len(testConfig.TestRunnerBundleId) == 0 && testConfig.InjectedTestBundlePath != "" {
+		if err := discoverTestBundleHost(&testConfig); err != nil {
+			return make([]TestSuite, 0), fmt.Errorf("RunTestWithConfig: %w", err)
+		}
+	}
 	if len(testConfig.TestRunnerBundleId) == 0 {
 		return nil, fmt.Errorf("RunTestWithConfig: testConfig.TestRunnerBundleId can not be empty")
 	}
+	if err := expandTestIdentifiers(&testConfig); err != nil {
+		return make([]TestSuite, 0), fmt.Errorf("RunTestWithConfig: %w", err)
+	}
+	closeTunnel, err := startTestTunnel(ctx, &testConfig)
+	if err != nil {
+		return make([]TestSuite, 0), fmt.Errorf("RunTestWithConfig: %w", err)
+	}
+	defer closeTunnel()
+	resolveDeviceLocale(&testConfig)
+	if testConfig.OutputDir != "" {
+		return runTestWithOutputDir(ctx, testConfig)
+	}
+	if testConfig.Repeat > 1 || testConfig.RepeatUntilFailure {
+		return runTestRepeatedly(ctx, testConfig, RunTestWithConfig)
+	}
+	if len(testConfig.TestEnvironmentOverrides) > 0 {
+		return runTestWithEnvironmentOverrides(ctx, testConfig)
+	}
 	version, err := ios.GetProductVersion(testConfig.Device)
 	if err != nil {
 		return make([]TestSuite, 0), fmt.Errorf("RunXCUIWithBundleIdsCtx: cannot determine iOS version: %w", err)
 	}
 
+	if testConfig.Listener != nil {
+		testConfig.Listener.DeviceLocale = *testConfig.deviceLocale
+		testConfig.Listener.failureScreenshotMaxDimension = testConfig.FailureScreenshotMaxDimension
+		if testConfig.EventStream != nil {
+			testConfig.Listener.events = newEventStream(testConfig.EventStream)
+			testConfig.Listener.output.setEvents(testConfig.Listener.events)
+		}
+	}
+
+	if testConfig.RandomOrder {
+		log.WithField("seed", testConfig.RandomOrderSeed).Info("running tests in random order")
+		if testConfig.Listener != nil {
+			seed := testConfig.RandomOrderSeed
+			testConfig.Listener.RandomOrderSeed = &seed
+		}
+	}
+
+	if testConfig.FailureScreenshotDir != "" && testConfig.Listener != nil {
+		stopScreenshots, err := startFailureScreenshots(testConfig.Device, testConfig.FailureScreenshotDir, testConfig.Listener)
+		if err != nil {
+			return make([]TestSuite, 0), fmt.Errorf("RunTestWithConfig: cannot start failure screenshots: %w", err)
+		}
+		defer stopScreenshots()
+	}
+
+	if testConfig.ScreenRecordingDir != "" && testConfig.Listener != nil {
+		stopRecording, err := startScreenRecording(testConfig.Device, testConfig.ScreenRecordingDir, testConfig.Listener)
+		if err != nil {
+			return make([]TestSuite, 0), fmt.Errorf("RunTestWithConfig: cannot start screen recording: %w", err)
+		}
+		defer stopRecording()
+	}
+
+	if testConfig.AttachmentsDir != "" && testConfig.Listener != nil {
+		if err := storeAttachmentsByTest(testConfig.AttachmentsDir, testConfig.Listener); err != nil {
+			return make([]TestSuite, 0), fmt.Errorf("RunTestWithConfig: cannot create attachments directory: %w", err)
+		}
+	}
+
+	if testConfig.CodeCoverage {
+		collectCodeCoverage, err := startCodeCoverage(&testConfig)
+		if err != nil {
+			return make([]TestSuite, 0), fmt.Errorf("RunTestWithConfig: cannot enable code coverage: %w", err)
+		}
+		defer collectCodeCoverage()
+	}
+
+	if testConfig.TestLogs && testConfig.Listener != nil {
+		stopTestLogs, err := startTestLogCapture(testConfig)
+		if err != nil {
+			return make([]TestSuite, 0), fmt.Errorf("RunTestWithConfig: cannot start capturing test logs: %w", err)
+		}
+		defer stopTestLogs()
+	}
+
+	if testConfig.CrashReports && testConfig.Listener != nil {
+		harvestCrashReports, err := startCrashReportHarvesting(testConfig)
+		if err != nil {
+			return make([]TestSuite, 0), fmt.Errorf("RunTestWithConfig: cannot start collecting crash reports: %w", err)
+		}
+		defer harvestCrashReports()
+	}
+
+	if testConfig.InjectedTestBundlePath != "" && testConfig.deviceTestBundlePath == "" {
+		removeInjectedTestBundle, err := prepareInjectedTestBundle(&testConfig, version)
+		if err != nil {
+			return make([]TestSuite, 0), fmt.Errorf("RunTestWithConfig: cannot inject the test bundle: %w", err)
+		}
+		defer removeInjectedTestBundle()
+	}
+
+	if (testConfig.PeakMemory || testConfig.PeakCPU) && testConfig.Listener != nil {
+		stopSampling, err := startProcessSampling(testConfig)
+		if err != nil {
+			return make([]TestSuite, 0), fmt.Errorf("RunTestWithConfig: cannot start process sampling: %w", err)
+		}
+		defer stopSampling()
+	}
+
 	if version.LessThan(ios.IOS14()) {
 		log.Debugf("iOS version: %s detected, running with ios11 support", version)
 		return runXCUIWithBundleIdsXcode11Ctx(ctx, testConfig, version)
@@ -293,17 +714,21 @@ func runXUITestWithBundleIdsXcode15Ctx(
 	config TestConfig,
 	version *semver.Version,
 ) ([]TestSuite, error) {
-	conn1, err := dtx.NewTunnelConnection(config.Device, testmanagerdiOS17)
+	session, err := newTestSession(config)
+	if err != nil {
+		return make([]TestSuite, 0), fmt.Errorf("runXUITestWithBundleIdsXcode15Ctx: %w", err)
+	}
+	defer session.close()
+
+	conn1, err := session.connect(dtx.NewTunnelConnection, config.Device, testmanagerdiOS17)
 	if err != nil {
 		return make([]TestSuite, 0), fmt.Errorf("runXUITestWithBundleIdsXcode15Ctx: cannot create a tunnel connection to testmanagerd: %w", err)
 	}
-	defer conn1.Close()
 
-	conn2, err := dtx.NewTunnelConnection(config.Device, testmanagerdiOS17)
+	conn2, err := session.connect(dtx.NewTunnelConnection, config.Device, testmanagerdiOS17)
 	if err != nil {
 		return make([]TestSuite, 0), fmt.Errorf("runXUITestWithBundleIdsXcode15Ctx: cannot create a tunnel connection to testmanagerd: %w", err)
 	}
-	defer conn2.Close()
 
 	installationProxy, err := installationproxy.New(config.Device)
 	if err != nil {
@@ -333,8 +758,8 @@ func runXUITestWithBundleIdsXcode15Ctx(
 		info.targetApp = appInfo
 	}
 
-	testSessionID := uuid.New()
-	testconfig := createTestConfig(info, testSessionID, config.XctestConfigName, config.TestsToRun, config.TestsToSkip, config.XcTest, version)
+	testSessionID := session.id
+	testconfig := createTestConfig(info, testSessionID, config.XctestConfigName, config.TestsToRun, config.TestsToSkip, config.XcTest, version, config.xcTestConfigurationOptions()...)
 	ideDaemonProxy1 := newDtxProxyWithConfig(conn1, testconfig, config.Listener)
 
 	localCaps := nskeyedarchiver.XCTCapabilities{CapabilitiesDictionary: map[string]interface{}{
@@ -353,7 +778,7 @@ func runXUITestWithBundleIdsXcode15Ctx(
 	if err != nil {
 		return make([]TestSuite, 0), fmt.Errorf("runXUITestWithBundleIdsXcode15Ctx: cannot initiate a IDE session: %w", err)
 	}
-	log.WithField("receivedCaps", receivedCaps).Info("got capabilities")
+	session.log.WithField("receivedCaps", receivedCaps).Info("got capabilities")
 
 	appserviceConn, err := appservice.New(config.Device)
 	if err != nil {
@@ -361,14 +786,19 @@ func runXUITestWithBundleIdsXcode15Ctx(
 	}
 	defer appserviceConn.Close()
 
-	testRunnerLaunch, err := startTestRunner17(appserviceConn, config.TestRunnerBundleId, strings.ToUpper(testSessionID.String()), info.testApp.path+"/PlugIns/"+config.XctestConfigName, config.Args, config.Env, config.XcTest)
+	sessionStarted := time.Now()
+	testRunnerLaunch, err := startTestRunner17(appserviceConn, config.TestRunnerBundleId, strings.ToUpper(testSessionID.String()), config.testBundlePath(info.testApp.path), config.launchArguments(), config.Env, config.XcTest, config.Architecture)
 	if err != nil {
 		return make([]TestSuite, 0), fmt.Errorf("runXUITestWithBundleIdsXcode15Ctx: cannot start test runner: %w", err)
 	}
+	config.Listener.LaunchEnvironment = testRunnerLaunch.Environment
+	config.Listener.runnerStarted(uint64(testRunnerLaunch.Pid))
+	runnerExit := watchRunnerExit(ctx, config, uint64(testRunnerLaunch.Pid))
+	defer runnerExit.stop()
 
 	defer testRunnerLaunch.Close()
 	go func() {
-		_, err := io.Copy(config.Listener.logWriter, testRunnerLaunch)
+		_, err := io.Copy(config.Listener.runnerOutputWriter(), testRunnerLaunch)
 		if err != nil {
 			log.Warn("copying stdout failed", log.WithError(err))
 		}
@@ -379,12 +809,12 @@ func runXUITestWithBundleIdsXcode15Ctx(
 	if err != nil {
 		return make([]TestSuite, 0), fmt.Errorf("runXUITestWithBundleIdsXcode15Ctx: cannot initiate a control session with capabilities: %w", err)
 	}
-	log.WithField("caps", caps).Info("got capabilities")
+	session.log.WithField("caps", caps).Info("got capabilities")
 	authorized, err := ideDaemonProxy2.daemonConnection.authorizeTestSessionWithProcessID(uint64(testRunnerLaunch.Pid))
 	if err != nil {
 		return make([]TestSuite, 0), fmt.Errorf("runXUITestWithBundleIdsXcode15Ctx: cannot authorize test session: %w", err)
 	}
-	log.WithField("authorized", authorized).Info("authorized")
+	session.log.WithField("authorized", authorized).Info("authorized")
 
 	ideInterfaceChannel := ideDaemonProxy1.dtxConnection.ForChannelRequest(proxyDispatcher{id: "dtxproxy:XCTestDriverInterface:XCTestManager_IDEInterface"})
 
@@ -393,38 +823,41 @@ func runXUITestWithBundleIdsXcode15Ctx(
 	if err != nil {
 		return make([]TestSuite, 0), fmt.Errorf("runXUITestWithBundleIdsXcode15Ctx: cannot start executing test plan: %w", err)
 	}
+	stopIdleWatchdog := startIdleWatchdog(config, session.connections()...)
+	defer stopIdleWatchdog()
 
 	select {
 	case <-conn1.Closed():
-		log.Debug("conn1 closed")
+		session.log.Debug("conn1 closed")
 		if !errors.Is(conn1.Err(), dtx.ErrConnectionClosed) {
-			log.WithError(conn1.Err()).Error("conn1 closed unexpectedly")
+			session.log.WithError(conn1.Err()).Error("conn1 closed unexpectedly")
 		}
-		config.Listener.FinishWithError(errors.New("lost connection to testmanagerd. the test-runner may have been killed"))
+		config.Listener.sessionEndedAbnormally(lostConnectionReason, findCrashReports(config.Device, info.testApp.executable, sessionStarted))
 		break
 	case <-conn2.Closed():
-		log.Debug("conn2 closed")
+		session.log.Debug("conn2 closed")
 		if !errors.Is(conn2.Err(), dtx.ErrConnectionClosed) {
-			log.WithError(conn2.Err()).Error("conn2 closed unexpectedly")
+			session.log.WithError(conn2.Err()).Error("conn2 closed unexpectedly")
 		}
-		config.Listener.FinishWithError(errors.New("lost connection to testmanagerd. the test-runner may have been killed"))
+		config.Listener.sessionEndedAbnormally(lostConnectionReason, findCrashReports(config.Device, info.testApp.executable, sessionStarted))
 		break
 	case <-config.Listener.Done():
 		break
 	case <-ctx.Done():
-		break
+		cancelTestSession(config.Listener, ctx.Err(), session.closed()...)
 	}
-	log.Infof("Killing test runner with pid %d ...", testRunnerLaunch.Pid)
+	runnerExit.wait()
+	session.log.Infof("Killing test runner with pid %d ...", testRunnerLaunch.Pid)
 	err = killTestRunner(appserviceConn, testRunnerLaunch.Pid)
 	if err != nil {
-		log.Infof("Nothing to kill, process with pid %d is already dead", testRunnerLaunch.Pid)
+		session.log.Infof("Nothing to kill, process with pid %d is already dead", testRunnerLaunch.Pid)
 	} else {
-		log.Info("Test runner killed with success")
+		session.log.Info("Test runner killed with success")
 	}
 
-	log.Debugf("Done running test")
+	session.log.Debugf("Done running test")
 
-	return config.Listener.TestSuites, config.Listener.err
+	return config.Listener.results()
 }
 
 type processKiller interface {
@@ -442,7 +875,7 @@ func killTestRunner(killer processKiller, pid int) error {
 	return nil
 }
 
-func startTestRunner17(appserviceConn *appservice.Connection, bundleID string, sessionIdentifier string, testBundlePath string, testArgs []string, testEnv map[string]interface{}, isXCTest bool) (appservice.LaunchedAppWithStdIo, error) {
+func startTestRunner17(appserviceConn *appservice.Connection, bundleID string, sessionIdentifier string, testBundlePath string, testArgs []string, testEnv map[string]interface{}, isXCTest bool, architecture string) (appservice.LaunchedAppWithStdIo, error) {
 	args := []interface{}{}
 	for _, arg := range testArgs {
 		args = append(args, arg)
@@ -477,21 +910,11 @@ func startTestRunner17(appserviceConn *appservice.Connection, bundleID string, s
 			log.Debugf("adding extra env %s=%s", key, value)
 		}
 	}
-	var opts = map[string]interface{}{}
-
-	if !isXCTest {
-		opts = map[string]interface{}{
-			"ActivateSuspended":   uint64(1),
-			"StartSuspendedKey":   uint64(0),
-			"__ActivateSuspended": uint64(1),
-		}
-	}
-
 	appLaunch, err := appserviceConn.LaunchAppWithStdIo(
 		bundleID,
 		args,
 		env,
-		opts,
+		testRunnerLaunchOptions17(isXCTest, architecture),
 		true,
 	)
 
@@ -502,8 +925,22 @@ func startTestRunner17(appserviceConn *appservice.Connection, bundleID string, s
 	return appLaunch, nil
 }
 
-func setupXcuiTest(device ios.DeviceEntry, bundleID string, testRunnerBundleID string, xctestConfigFileName string, testsToRun []string, testsToSkip []string, isXCTest bool, version *semver.Version) (uuid.UUID, string, nskeyedarchiver.XCTestConfiguration, testInfo, error) {
-	testSessionID := uuid.New()
+// testRunnerLaunchOptions17 returns the platform specific options of the app service launch request of the runner
+func testRunnerLaunchOptions17(isXCTest bool, architecture string) map[string]interface{} {
+	var opts = map[string]interface{}{}
+
+	if !isXCTest {
+		opts = map[string]interface{}{
+			"ActivateSuspended":   uint64(1),
+			"StartSuspendedKey":   uint64(0),
+			"__ActivateSuspended": uint64(1),
+		}
+	}
+	withArchitecture(opts, architecture)
+	return opts
+}
+
+func setupXcuiTest(testSessionID uuid.UUID, device ios.DeviceEntry, bundleID string, testRunnerBundleID string, xctestConfigFileName string, testsToRun []string, testsToSkip []string, isXCTest bool, version *semver.Version, opts ...nskeyedarchiver.XCTestConfigurationOption) (uuid.UUID, string, nskeyedarchiver.XCTestConfiguration, testInfo, error) {
 	installationProxy, err := installationproxy.New(device)
 	if err != nil {
 		return uuid.UUID{}, "", nskeyedarchiver.XCTestConfiguration{}, testInfo{}, err
@@ -540,7 +977,7 @@ func setupXcuiTest(device ios.DeviceEntry, bundleID string, testRunnerBundleID s
 		return uuid.UUID{}, "", nskeyedarchiver.XCTestConfiguration{}, testInfo{}, err
 	}
 	log.Debugf("creating test config")
-	testConfigPath, testConfig, err := createTestConfigOnDevice(testSessionID, info, houseArrestService, xctestConfigFileName, testsToRun, testsToSkip, isXCTest, version)
+	testConfigPath, testConfig, err := createTestConfigOnDevice(testSessionID, info, houseArrestService, xctestConfigFileName, testsToRun, testsToSkip, isXCTest, version, opts...)
 	if err != nil {
 		return uuid.UUID{}, "", nskeyedarchiver.XCTestConfiguration{}, testInfo{}, err
 	}
@@ -548,14 +985,14 @@ func setupXcuiTest(device ios.DeviceEntry, bundleID string, testRunnerBundleID s
 	return testSessionID, testConfigPath, testConfig, info, nil
 }
 
-func createTestConfigOnDevice(testSessionID uuid.UUID, info testInfo, houseArrestService *house_arrest.Connection, xctestConfigFileName string, testsToRun []string, testsToSkip []string, isXCTest bool, version *semver.Version) (string, nskeyedarchiver.XCTestConfiguration, error) {
+func createTestConfigOnDevice(testSessionID uuid.UUID, info testInfo, houseArrestService *house_arrest.Connection, xctestConfigFileName string, testsToRun []string, testsToSkip []string, isXCTest bool, version *semver.Version, opts ...nskeyedarchiver.XCTestConfigurationOption) (string, nskeyedarchiver.XCTestConfiguration, error) {
 	relativeXcTestConfigPath := path.Join("tmp", testSessionID.String()+".xctestconfiguration")
 	xctestConfigPath := path.Join(info.testApp.homePath, relativeXcTestConfigPath)
 
 	testBundleURL := path.Join(info.testApp.path, "PlugIns", xctestConfigFileName)
 
 	productModuleName := strings.ReplaceAll(xctestConfigFileName, ".xctest", "")
-	config := nskeyedarchiver.NewXCTestConfiguration(productModuleName, testSessionID, info.targetApp.bundleID, info.targetApp.path, testBundleURL, testsToRun, testsToSkip, isXCTest, version)
+	config := nskeyedarchiver.NewXCTestConfiguration(productModuleName, testSessionID, info.targetApp.bundleID, info.targetApp.path, testBundleURL, testsToRun, testsToSkip, isXCTest, version, opts...)
 	result, err := nskeyedarchiver.ArchiveXML(config)
 	if err != nil {
 		return "", nskeyedarchiver.XCTestConfiguration{}, err
@@ -565,13 +1002,54 @@ func createTestConfigOnDevice(testSessionID uuid.UUID, info testInfo, houseArres
 	if err != nil {
 		return "", nskeyedarchiver.XCTestConfiguration{}, err
 	}
-	return xctestConfigPath, nskeyedarchiver.NewXCTestConfiguration(productModuleName, testSessionID, info.targetApp.bundleID, info.targetApp.path, testBundleURL, testsToRun, testsToSkip, isXCTest, version), nil
+	return xctestConfigPath, nskeyedarchiver.NewXCTestConfiguration(productModuleName, testSessionID, info.targetApp.bundleID, info.targetApp.path, testBundleURL, testsToRun, testsToSkip, isXCTest, version, opts...), nil
 }
 
-func createTestConfig(info testInfo, testSessionID uuid.UUID, xctestConfigFileName string, testsToRun []string, testsToSkip []string, isXCTest bool, version *semver.Version) nskeyedarchiver.XCTestConfiguration {
+func createTestConfig(info testInfo, testSessionID uuid.UUID, xctestConfigFileName string, testsToRun []string, testsToSkip []string, isXCTest bool, version *semver.Version, opts ...nskeyedarchiver.XCTestConfigurationOption) nskeyedarchiver.XCTestConfiguration {
 	// the default value for this generated by Xcode is the target name, and the same name is used for the '.xctest' bundle name per default
 	productModuleName := strings.ReplaceAll(xctestConfigFileName, ".xctest", "")
-	return nskeyedarchiver.NewXCTestConfiguration(productModuleName, testSessionID, info.targetApp.bundleID, info.targetApp.path, "PlugIns/"+xctestConfigFileName, testsToRun, testsToSkip, isXCTest, version)
+	return nskeyedarchiver.NewXCTestConfiguration(productModuleName, testSessionID, info.targetApp.bundleID, info.targetApp.path, "PlugIns/"+xctestConfigFileName, testsToRun, testsToSkip, isXCTest, version, opts...)
+}
+
+// getDeviceLocale reads the locale of the device, it is replaced in tests
+var getDeviceLocale = ios.GetDeviceLocale
+
+// resolveDeviceLocale reads the locale of the device into testConfig unless it was already read. Each read opens a
+// lockdown session, so it is done once before RunTestWithConfig splits the run into repeated iterations or
+// environment override sessions, which all reuse the locale of the config they were derived from.
+func resolveDeviceLocale(testConfig *TestConfig) {
+	if testConfig.deviceLocale != nil {
+		return
+	}
+	locale, err := getDeviceLocale(testConfig.Device)
+	if err != nil {
+		log.WithError(err).Warn("could not read device locale before the test run")
+	}
+	testConfig.deviceLocale = &locale
+}
+
+const lostConnectionReason = "lost connection to testmanagerd. the test-runner may have been killed"
+
+// listCrashReportsSince lists the crash reports on the device, it is replaced in tests
+var listCrashReportsSince = crashreport.ListReportsSince
+
+// findCrashReports returns the names of the crash reports of the given process on the device that were written since
+// the session started, older reports belong to previous runs. Errors are only logged as the crash reports are
+// additional information for a test session crash.
+func findCrashReports(device ios.DeviceEntry, processName string, sessionStarted time.Time) []string {
+	if processName == "" {
+		return nil
+	}
+	reports, err := listCrashReportsSince(device, processName+"*", sessionStarted.Add(-crashReportClockTolerance))
+	if err != nil {
+		log.WithError(err).Warn("could not list crash reports of the test runner")
+		return nil
+	}
+	var names []string
+	for _, report := range reports {
+		names = append(names, report.Name)
+	}
+	return names
 }
 
 type testInfo struct {
@@ -582,6 +1060,7 @@ type testInfo struct {
 type appInfo struct {
 	path       string
 	bundleName string
+	executable string
 	bundleID   string
 	homePath   string
 }
@@ -592,6 +1071,7 @@ func getappInfo(bundleID string, apps []installationproxy.AppInfo) (appInfo, err
 			info := appInfo{
 				path:       app.Path,
 				bundleName: app.CFBundleName,
+				executable: app.CFBundleExecutable,
 				bundleID:   app.CFBundleIdentifier,
 			}
 			if home, ok := app.EnvironmentVariables["HOME"].(string); ok {
@@ -603,3 +1083,30 @@ func getappInfo(bundleID string, apps []installationproxy.AppInfo) (appInfo, err
 
 	return appInfo{}, fmt.Errorf("Did not find test app for '%s' on device. Is it installed?", bundleID)
 }
+
+// testProcessNames returns the executable names of the test runner and the app under test of config
+func testProcessNames(config TestConfig) ([]string, error) {
+	installationProxy, err := installationproxy.New(config.Device)
+	if err != nil {
+		return nil, err
+	}
+	apps, err := installationProxy.BrowseUserApps()
+	installationProxy.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	runner, err := getappInfo(config.TestRunnerBundleId, apps)
+	if err != nil {
+		return nil, err
+	}
+	processes := []string{runner.executable}
+	if config.BundleId != "" && config.BundleId != config.TestRunnerBundleId {
+		app, err := getappInfo(config.BundleId, apps)
+		if err != nil {
+			return nil, err
+		}
+		processes = append(processes, app.executable)
+	}
+	return processes, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"maps"
+	"time"
 
 	"github.com/Masterminds/semver"
 	dtx "github.com/danielpaulus/go-ios/ios/dtx_codec"
@@ -16,26 +17,30 @@ func runXCUIWithBundleIdsXcode11Ctx(
 	config TestConfig,
 	version *semver.Version,
 ) ([]TestSuite, error) {
-	log.Debugf("set up xcuitest")
-	testSessionId, xctestConfigPath, testConfig, testInfo, err := setupXcuiTest(config.Device, config.BundleId, config.TestRunnerBundleId, config.XctestConfigName, config.TestsToRun, config.TestsToSkip, config.XcTest, version)
+	session, err := newTestSession(config)
+	if err != nil {
+		return make([]TestSuite, 0), fmt.Errorf("RunXCUIWithBundleIdsXcode11Ctx: %w", err)
+	}
+	defer session.close()
+
+	session.log.Debugf("set up xcuitest")
+	testSessionId, xctestConfigPath, testConfig, testInfo, err := setupXcuiTest(session.id, config.Device, config.BundleId, config.TestRunnerBundleId, config.XctestConfigName, config.TestsToRun, config.TestsToSkip, config.XcTest, version, config.xcTestConfigurationOptions()...)
 	if err != nil {
 		return make([]TestSuite, 0), fmt.Errorf("RunXCUIWithBundleIdsXcode11Ctx: cannot create test config: %w", err)
 	}
-	log.Debugf("test session setup ok")
-	conn, err := dtx.NewUsbmuxdConnection(config.Device, testmanagerd)
+	session.log.Debugf("test session setup ok")
+	conn, err := session.connect(dtx.NewUsbmuxdConnection, config.Device, testmanagerd)
 	if err != nil {
 		return make([]TestSuite, 0), fmt.Errorf("RunXCUIWithBundleIdsXcode11Ctx: cannot create a usbmuxd connection to testmanagerd: %w", err)
 	}
-	defer conn.Close()
 
 	ideDaemonProxy := newDtxProxyWithConfig(conn, testConfig, config.Listener)
 
-	conn2, err := dtx.NewUsbmuxdConnection(config.Device, testmanagerd)
+	conn2, err := session.connect(dtx.NewUsbmuxdConnection, config.Device, testmanagerd)
 	if err != nil {
 		return make([]TestSuite, 0), fmt.Errorf("RunXCUIWithBundleIdsXcode11Ctx: cannot create a usbmuxd connection to testmanagerd: %w", err)
 	}
-	defer conn2.Close()
-	log.Debug("connections ready")
+	session.log.Debug("connections ready")
 	ideDaemonProxy2 := newDtxProxyWithConfig(conn2, testConfig, config.Listener)
 	ideDaemonProxy2.ideInterface.testConfig = testConfig
 	// TODO: fixme
@@ -45,17 +50,23 @@ func runXCUIWithBundleIdsXcode11Ctx(
 		return make([]TestSuite, 0), fmt.Errorf("RunXCUIWithBundleIdsXcode11Ctx: cannot initiate a test session: %w", err)
 	}
 
-	pControl, err := instruments.NewProcessControl(config.Device)
+	pControl, err := instruments.NewProcessControlWithOutput(config.Device, config.Listener.runnerOutput)
 	if err != nil {
 		return make([]TestSuite, 0), fmt.Errorf("RunXCUIWithBundleIdsXcode11Ctx: cannot connect to process control: %w", err)
 	}
 	defer pControl.Close()
 
-	pid, err := startTestRunner11(pControl, xctestConfigPath, config.TestRunnerBundleId, testSessionId.String(), testInfo.testApp.path+"/PlugIns/"+config.XctestConfigName, config.Args, config.Env)
+	sessionStarted := time.Now()
+	env := testRunnerEnv11(xctestConfigPath, testSessionId.String(), config.testBundlePath(testInfo.testApp.path), config.Env)
+	pid, err := startTestRunner11(pControl, config.TestRunnerBundleId, env, config.launchArguments(), config.Architecture)
 	if err != nil {
 		return make([]TestSuite, 0), fmt.Errorf("RunXCUIWithBundleIdsXcode11Ctx: cannot start the test runner: %w", err)
 	}
 	log.Debugf("Runner started with pid:%d, waiting for testBundleReady", pid)
+	config.Listener.LaunchEnvironment = env
+	config.Listener.runnerStarted(pid)
+	runnerExit := watchRunnerExit(ctx, config, pid)
+	defer runnerExit.stop()
 
 	err = ideDaemonProxy2.daemonConnection.initiateControlSession(pid, protocolVersion)
 	if err != nil {
@@ -69,46 +80,48 @@ func runXCUIWithBundleIdsXcode11Ctx(
 	if err != nil {
 		return make([]TestSuite, 0), fmt.Errorf("RunXCUIWithBundleIdsXcode11Ctx: cannot start executing test plan: %w", err)
 	}
+	stopIdleWatchdog := startIdleWatchdog(config, session.connections()...)
+	defer stopIdleWatchdog()
 
 	select {
 	case <-conn.Closed():
-		log.Debug("conn closed")
+		session.log.Debug("conn closed")
 		if conn.Err() != dtx.ErrConnectionClosed {
-			log.WithError(conn.Err()).Error("conn closed unexpectedly")
+			session.log.WithError(conn.Err()).Error("conn closed unexpectedly")
 		}
+		config.Listener.sessionEndedAbnormally(lostConnectionReason, findCrashReports(config.Device, testInfo.testApp.executable, sessionStarted))
 		break
 	case <-conn2.Closed():
-		log.Debug("conn2 closed")
+		session.log.Debug("conn2 closed")
 		if conn2.Err() != dtx.ErrConnectionClosed {
-			log.WithError(conn2.Err()).Error("conn2 closed unexpectedly")
+			session.log.WithError(conn2.Err()).Error("conn2 closed unexpectedly")
 		}
+		config.Listener.sessionEndedAbnormally(lostConnectionReason, findCrashReports(config.Device, testInfo.testApp.executable, sessionStarted))
 		break
 	case <-config.Listener.Done():
 		break
 	case <-ctx.Done():
-		break
+		cancelTestSession(config.Listener, ctx.Err(), session.closed()...)
 	}
-	log.Infof("Killing test runner with pid %d ...", pid)
+	runnerExit.wait()
+	session.log.Infof("Killing test runner with pid %d ...", pid)
 	err = pControl.KillProcess(pid)
 	if err != nil {
-		log.Infof("Nothing to kill, process with pid %d is already dead", pid)
+		session.log.Infof("Nothing to kill, process with pid %d is already dead", pid)
 	} else {
-		log.Info("Test runner killed with success")
+		session.log.Info("Test runner killed with success")
 	}
 
-	log.Debugf("Done running test")
+	session.log.Debugf("Done running test")
 
-	return config.Listener.TestSuites, config.Listener.err
+	return config.Listener.results()
 }
 
-func startTestRunner11(pControl *instruments.ProcessControl, xctestConfigPath string, bundleID string,
-	sessionIdentifier string, testBundlePath string, wdaargs []string, wdaenv map[string]interface{},
-) (uint64, error) {
-	args := []interface{}{}
-	for _, arg := range wdaargs {
-		args = append(args, arg)
-	}
+// testRunnerEnv11 returns the environment startTestRunner11 launches the test runner with, wdaenv is added to the
+// variables XCTest needs to find the test session
+func testRunnerEnv11(xctestConfigPath string, sessionIdentifier string, testBundlePath string, wdaenv map[string]interface{}) map[string]interface{} {
 	env := map[string]interface{}{
+		"NSUnbufferedIO":              "YES",
 		"XCTestBundlePath":            testBundlePath,
 		"XCTestConfigurationFilePath": xctestConfigPath,
 		"XCTestSessionIdentifier":     sessionIdentifier,
@@ -121,11 +134,20 @@ func startTestRunner11(pControl *instruments.ProcessControl, xctestConfigPath st
 			log.Debugf("adding extra env %s=%s", key, value)
 		}
 	}
+	return env
+}
+
+func startTestRunner11(pControl *instruments.ProcessControl, bundleID string, env map[string]interface{}, wdaargs []string, architecture string) (uint64, error) {
+	args := []interface{}{}
+	for _, arg := range wdaargs {
+		args = append(args, arg)
+	}
 
 	opts := map[string]interface{}{
 		"StartSuspendedKey": uint64(0),
 		"ActivateSuspended": uint64(1),
 	}
+	withArchitecture(opts, architecture)
 
 	return pControl.StartProcess(bundleID, env, args, opts)
 }
@@ -38,6 +38,8 @@ type Tunnel struct {
 	UserspaceTUN     bool `json:"userspaceTun"`
 	UserspaceTUNPort int  `json:"userspaceTunPort"`
 	closer           func() error
+	// done is closed when the connection of the tunnel to the device ended
+	done <-chan struct{}
 }
 
 // Close closes the connection to the device and removes the virtual network interface from the host
@@ -45,6 +47,17 @@ func (t Tunnel) Close() error {
 	return t.closer()
 }
 
+// lost returns true if the connection of the tunnel to the device ended, f.ex. because the device was restarted or
+// went to sleep, and the tunnel needs to be started again
+func (t Tunnel) lost() bool {
+	select {
+	case <-t.done:
+		return true
+	default:
+		return false
+	}
+}
+
 // ManualPairAndConnectToTunnel tries to verify an existing pairing, and if this fails it triggers a new manual pairing process.
 // After a successful pairing a tunnel for this device gets started and the tunnel information is returned
 func ManualPairAndConnectToTunnel(ctx context.Context, device ios.DeviceEntry, p PairRecordManager) (Tunnel, error) {
@@ -147,7 +160,9 @@ func connectToTunnel(ctx context.Context, info tunnelListener, addr string, devi
 	// doing it like this allows us to have a context with a timeout for the tunnel creation, but the tunnel itself
 	tunnelCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
 
+	done := make(chan struct{})
 	go func() {
+		defer close(done)
 		err := forwardDataToInterface(tunnelCtx, conn, utunIface)
 		if err != nil {
 			logrus.WithError(err).Error("failed to forward data to tunnel interface")
@@ -173,6 +188,7 @@ func connectToTunnel(ctx context.Context, info tunnelListener, addr string, devi
 		RsdPort: int(tunnelInfo.ServerRSDPort),
 		Udid:    device.Properties.SerialNumber,
 		closer:  closeFunc,
+		done:    done,
 	}, nil
 }
 
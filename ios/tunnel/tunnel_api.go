@@ -265,6 +265,7 @@ func (m *TunnelManager) FirstUpdateCompleted() bool {
 }
 
 // UpdateTunnels checks for connected devices and starts a new tunnel if needed
+// Tunnels whose connection to the device was lost are started again, userspace tunnels keep their port
 // On device disconnects the tunnel resources get cleaned up
 func (m *TunnelManager) UpdateTunnels(ctx context.Context) error {
 
@@ -279,8 +280,17 @@ func (m *TunnelManager) UpdateTunnels(ctx context.Context) error {
 	}
 	for _, d := range devices.DeviceList {
 		udid := d.Properties.SerialNumber
-		if _, exists := localTunnels[udid]; exists {
-			continue
+		if t, exists := localTunnels[udid]; exists {
+			if !t.lost() {
+				continue
+			}
+			// keep the tunnels of connected devices running, clients only need to look up the new address
+			log.WithField("udid", udid).Info("tunnel to the device was lost, restarting it")
+			_ = m.stopTunnel(t)
+			delete(localTunnels, udid)
+			if t.UserspaceTUN {
+				d.UserspaceTUNPort = t.UserspaceTUNPort
+			}
 		}
 		if m.userspaceTUN && d.UserspaceTUNPort == 0 {
 			d.UserspaceTUNPort = ios.HttpApiPort() + m.portOffset
@@ -369,6 +379,18 @@ func (m *TunnelManager) FindTunnel(udid string) (Tunnel, error) {
 	return Tunnel{}, nil
 }
 
+// StartTunnel starts a tunnel to device without the go-ios agent, the caller has to close it. Devices with iOS 17.0
+// up to 17.4 get paired manually if needed, with the pair records stored in p. With userspaceTUN the network stack
+// runs in user space and is reachable through the local port device.UserspaceTUNPort, or a free port if it is 0.
+// Otherwise a TUN interface is created, which requires root permissions.
+func StartTunnel(ctx context.Context, device ios.DeviceEntry, p PairRecordManager, userspaceTUN bool) (Tunnel, error) {
+	version, err := ios.GetProductVersion(device)
+	if err != nil {
+		return Tunnel{}, fmt.Errorf("StartTunnel: failed to get device version: %w", err)
+	}
+	return manualPairingTunnelStart{}.StartTunnel(ctx, device, p, version, userspaceTUN)
+}
+
 type tunnelStarter interface {
 	StartTunnel(ctx context.Context, device ios.DeviceEntry, p PairRecordManager, version *semver.Version, userspaceTUN bool) (Tunnel, error)
 }
@@ -384,10 +406,7 @@ func (m manualPairingTunnelStart) StartTunnel(ctx context.Context, device ios.De
 
 	if version.GreaterThan(semver.MustParse("17.4.0")) {
 		if userspaceTUN {
-			tun, err := ConnectUserSpaceTunnelLockdown(device, device.UserspaceTUNPort)
-			tun.UserspaceTUN = true
-			tun.UserspaceTUNPort = device.UserspaceTUNPort
-			return tun, err
+			return ConnectUserSpaceTunnelLockdown(device, device.UserspaceTUNPort)
 		}
 		return ConnectTunnelLockdown(device)
 	}
@@ -39,7 +39,9 @@ func connectToTunnelLockdown(ctx context.Context, device ios.DeviceEntry, connTo
 	// doing it like this allows us to have a context with a timeout for the tunnel creation, but the tunnel itself
 	tunnelCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
 
+	done := make(chan struct{})
 	go func() {
+		defer close(done)
 		err := forwardTCPToInterface(tunnelCtx, tunnelInfo.ClientParameters.Mtu, connToDevice, utunIface)
 		if err != nil {
 			logrus.WithError(err).Error("failed to forward data to tunnel interface")
@@ -62,6 +64,7 @@ func connectToTunnelLockdown(ctx context.Context, device ios.DeviceEntry, connTo
 		RsdPort: int(tunnelInfo.ServerRSDPort),
 		Udid:    device.Properties.SerialNumber,
 		closer:  closeFunc,
+		done:    done,
 	}, nil
 }
 
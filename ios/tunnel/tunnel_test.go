@@ -0,0 +1,17 @@
+package tunnel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTunnelLost(t *testing.T) {
+	done := make(chan struct{})
+	tunnel := Tunnel{done: done}
+	assert.False(t, tunnel.lost())
+
+	close(done)
+	assert.True(t, tunnel.lost(), "the connection to the device ended")
+	assert.False(t, Tunnel{}.lost(), "tunnels that do not report their connection are never lost")
+}
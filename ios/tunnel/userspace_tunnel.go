@@ -47,6 +47,7 @@ type UserSpaceTUNInterface struct {
 	//If EnableSniffer, raw TCP packets will be dumped to the console.
 	EnableSniffer bool
 	networkStack  *stack.Stack
+	endpoint      *Endpoint
 }
 
 func (iface *UserSpaceTUNInterface) TunnelRWCThroughInterface(localPort uint16, remoteAddr net.IP, remotePort uint16, rw io.ReadWriteCloser) error {
@@ -146,11 +147,12 @@ func (iface *UserSpaceTUNInterface) Init(mtu uint32, connToTUNIface io.ReadWrite
 
 	// connToTUNIface needs to be connection that understands IP packets,
 	// so we can use it to link it against a virtual network interface
-	var linkEP stack.LinkEndpoint
-	linkEP, err := RWCEndpointNew(connToTUNIface, mtu, 0)
+	endpoint, err := RWCEndpointNew(connToTUNIface, mtu, 0)
 	if err != nil {
 		return fmt.Errorf("initVirtualInterface: RWCEndpointNew failed: %+v", err)
 	}
+	iface.endpoint = endpoint
+	var linkEP stack.LinkEndpoint = endpoint
 
 	nicID := tcpip.NICID(iface.networkStack.UniqueID())
 	iface.nicID = nicID
@@ -179,6 +181,8 @@ func (iface *UserSpaceTUNInterface) Init(mtu uint32, connToTUNIface io.ReadWrite
 	return nil
 }
 
+// ConnectUserSpaceTunnelLockdown starts a tunnel with a user space network stack that is reachable through the local
+// port ifacePort. If ifacePort is 0 a free port is used, Tunnel.UserspaceTUNPort contains the port in both cases.
 func ConnectUserSpaceTunnelLockdown(device ios.DeviceEntry, ifacePort int) (Tunnel, error) {
 	conn, err := ios.ConnectToService(device, coreDeviceProxy)
 	if err != nil {
@@ -205,18 +209,27 @@ func connectToUserspaceTunnelLockdown(ctx context.Context, device ios.DeviceEntr
 		return Tunnel{}, fmt.Errorf("could not setup listener. %w", err)
 	}
 
-	listener.Addr()
 	go listenToConns(iface, listener)
 
+	// the endpoint stops reading packets when the connection to the device ended
+	done := make(chan struct{})
+	go func() {
+		iface.endpoint.Wait()
+		close(done)
+	}()
+
 	closeFunc := func() error {
 		iface.networkStack.Close()
 		return errors.Join(connToDevice.Close(), listener.Close())
 	}
 	return Tunnel{
-		Address: tunnelInfo.ServerAddress,
-		RsdPort: int(tunnelInfo.ServerRSDPort),
-		Udid:    device.Properties.SerialNumber,
-		closer:  closeFunc,
+		Address:          tunnelInfo.ServerAddress,
+		RsdPort:          int(tunnelInfo.ServerRSDPort),
+		Udid:             device.Properties.SerialNumber,
+		UserspaceTUN:     true,
+		UserspaceTUNPort: listener.Addr().(*net.TCPAddr).Port,
+		closer:           closeFunc,
+		done:             done,
 	}, nil
 }
 
@@ -0,0 +1,533 @@
+package usbdirect
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/danielpaulus/go-ios/ios"
+	log "github.com/sirupsen/logrus"
+)
+
+// The USB mux protocol carries TCP like connections to the services of a device over the bulk endpoints of its mux
+// interface. It is the protocol usbmuxd speaks with the devices, the constants follow usbmuxd's device.c.
+const (
+	protoVersion = 0
+	protoControl = 1
+	protoSetup   = 2
+	protoTCP     = 6
+)
+
+const (
+	muxMagic = 0xfeedface
+	// muxVersion is the protocol version with sequence numbers in the packet header
+	muxVersion     = 2
+	muxHeaderLen   = 16
+	muxHeaderV1Len = 8
+	tcpHeaderLen   = 20
+	// maxPacketLen is the size of the largest packet read or sent, the limit usbmuxd uses
+	maxPacketLen  = 3 * 16384
+	maxPayloadLen = maxPacketLen - muxHeaderLen - tcpHeaderLen
+	// receiveWindow is the number of bytes buffered for each connection until Read consumes them. The free part of
+	// it is announced to the device, the window field holds it shifted by 8 bits.
+	receiveWindow = 131072
+	// handshakeTimeout is how long the device may take to answer the version request or a new connection
+	handshakeTimeout = 10 * time.Second
+)
+
+const (
+	tcpFIN = 0x01
+	tcpSYN = 0x02
+	tcpRST = 0x04
+	tcpACK = 0x10
+)
+
+// control packet types sent by the device
+const (
+	controlError = 3
+	controlInfo  = 4
+)
+
+var (
+	errDeviceClosed      = errors.New("usbdirect: device was detached")
+	errConnectionRefused = errors.New("usbdirect: connection refused by the device")
+	errConnectionClosed  = errors.New("usbdirect: use of closed connection")
+)
+
+type muxHeader struct {
+	Protocol uint32
+	Length   uint32
+	Magic    uint32
+	TxSeq    uint16
+	RxSeq    uint16
+}
+
+// muxHeaderV1 is used until the device agreed on muxVersion
+type muxHeaderV1 struct {
+	Protocol uint32
+	Length   uint32
+}
+
+type versionHeader struct {
+	Major   uint32
+	Minor   uint32
+	Padding uint32
+}
+
+type tcpHeader struct {
+	SourcePort      uint16
+	DestinationPort uint16
+	Seq             uint32
+	Ack             uint32
+	Offset          uint8
+	Flags           uint8
+	Window          uint16
+	Checksum        uint16
+	Urgent          uint16
+}
+
+// transport carries the packets of the mux interface of a device, see usb.go
+type transport interface {
+	// Read reads from the in endpoint, a packet can span several reads and a read can contain several packets
+	io.Reader
+	// WritePacket sends a single packet to the out endpoint
+	WritePacket(packet []byte) error
+	Close() error
+}
+
+// muxDevice speaks the USB mux protocol with a single device
+type muxDevice struct {
+	transport  transport
+	properties ios.DeviceProperties
+
+	// version is only changed by the handshake, before the read loop starts
+	version int
+	writeMu sync.Mutex
+	txSeq   uint16
+	rxSeq   atomic.Uint32
+
+	mu       sync.Mutex
+	conns    map[uint16]*muxConn
+	lastPort uint16
+	closed   bool
+	err      error
+	done     chan struct{}
+}
+
+// newMuxDevice agrees on the protocol version with the device and starts reading its packets. The device is closed
+// when reading fails, f.ex. because it was detached.
+func newMuxDevice(t transport, properties ios.DeviceProperties) (*muxDevice, error) {
+	d := &muxDevice{
+		transport:  t,
+		properties: properties,
+		conns:      map[uint16]*muxConn{},
+		done:       make(chan struct{}),
+	}
+	timeout := time.AfterFunc(handshakeTimeout, func() { t.Close() })
+	err := d.handshake()
+	if !timeout.Stop() && err != nil {
+		err = fmt.Errorf("device did not answer within %s: %w", handshakeTimeout, err)
+	}
+	if err != nil {
+		t.Close()
+		return nil, fmt.Errorf("newMuxDevice: handshake with %s failed: %w", properties.SerialNumber, err)
+	}
+	go d.readLoop()
+	return d, nil
+}
+
+func (d *muxDevice) handshake() error {
+	err := d.sendPacket(protoVersion, structBytes(versionHeader{Major: muxVersion}))
+	if err != nil {
+		return err
+	}
+	protocol, payload, err := d.readPacket()
+	if err != nil {
+		return err
+	}
+	if protocol != protoVersion {
+		return fmt.Errorf("expected a version packet but received protocol %d", protocol)
+	}
+	var version versionHeader
+	err = binary.Read(bytes.NewReader(payload), binary.BigEndian, &version)
+	if err != nil {
+		return fmt.Errorf("invalid version packet: %w", err)
+	}
+	if version.Major != muxVersion {
+		return fmt.Errorf("unsupported mux protocol version %d.%d", version.Major, version.Minor)
+	}
+	d.version = muxVersion
+	d.rxSeq.Store(0xffff)
+	return d.sendPacket(protoSetup, []byte{0x07})
+}
+
+func (d *muxDevice) sendPacket(protocol uint32, payload ...[]byte) error {
+	d.writeMu.Lock()
+	defer d.writeMu.Unlock()
+	length := 0
+	for _, p := range payload {
+		length += len(p)
+	}
+	var packet bytes.Buffer
+	if d.version < muxVersion {
+		binary.Write(&packet, binary.BigEndian, muxHeaderV1{Protocol: protocol, Length: uint32(muxHeaderV1Len + length)})
+	} else {
+		binary.Write(&packet, binary.BigEndian, muxHeader{
+			Protocol: protocol,
+			Length:   uint32(muxHeaderLen + length),
+			Magic:    muxMagic,
+			TxSeq:    d.txSeq,
+			RxSeq:    uint16(d.rxSeq.Load()),
+		})
+		d.txSeq++
+	}
+	for _, p := range payload {
+		packet.Write(p)
+	}
+	return d.transport.WritePacket(packet.Bytes())
+}
+
+// sendTCP sends a TCP packet, the caller sets the window of header
+func (d *muxDevice) sendTCP(header tcpHeader, data []byte) error {
+	header.Offset = (tcpHeaderLen / 4) << 4
+	return d.sendPacket(protoTCP, structBytes(header), data)
+}
+
+func (d *muxDevice) readPacket() (uint32, []byte, error) {
+	var protocol, length uint32
+	headerLen := uint32(muxHeaderLen)
+	if d.version < muxVersion {
+		var header muxHeaderV1
+		err := binary.Read(d.transport, binary.BigEndian, &header)
+		if err != nil {
+			return 0, nil, err
+		}
+		protocol, length, headerLen = header.Protocol, header.Length, muxHeaderV1Len
+	} else {
+		var header muxHeader
+		err := binary.Read(d.transport, binary.BigEndian, &header)
+		if err != nil {
+			return 0, nil, err
+		}
+		if header.Magic != muxMagic {
+			return 0, nil, fmt.Errorf("invalid packet magic %x", header.Magic)
+		}
+		d.rxSeq.Store(uint32(header.TxSeq))
+		protocol, length = header.Protocol, header.Length
+	}
+	if length < headerLen || length > maxPacketLen+headerLen {
+		return 0, nil, fmt.Errorf("invalid packet length %d", length)
+	}
+	payload := make([]byte, length-headerLen)
+	_, err := io.ReadFull(d.transport, payload)
+	return protocol, payload, err
+}
+
+func (d *muxDevice) readLoop() {
+	for {
+		protocol, payload, err := d.readPacket()
+		if err != nil {
+			d.close(err)
+			return
+		}
+		switch protocol {
+		case protoTCP:
+			d.handleTCP(payload)
+		case protoControl:
+			d.handleControl(payload)
+		default:
+			log.Debugf("usbdirect: ignoring packet with protocol %d from %s", protocol, d.properties.SerialNumber)
+		}
+	}
+}
+
+func (d *muxDevice) handleControl(payload []byte) {
+	if len(payload) == 0 {
+		return
+	}
+	entry := log.WithField("udid", d.properties.SerialNumber)
+	switch payload[0] {
+	case controlError:
+		entry.Warnf("usbdirect: device error: %s", payload[1:])
+	case controlInfo:
+		entry.Debugf("usbdirect: device info: %s", payload[1:])
+	default:
+		entry.Debugf("usbdirect: control packet %d: %x", payload[0], payload[1:])
+	}
+}
+
+func (d *muxDevice) handleTCP(payload []byte) {
+	var header tcpHeader
+	err := binary.Read(bytes.NewReader(payload), binary.BigEndian, &header)
+	if err != nil {
+		log.Debugf("usbdirect: ignoring short TCP packet from %s", d.properties.SerialNumber)
+		return
+	}
+	dataOffset := int(header.Offset>>4) * 4
+	if dataOffset < tcpHeaderLen || dataOffset > len(payload) {
+		log.Debugf("usbdirect: ignoring TCP packet with invalid offset from %s", d.properties.SerialNumber)
+		return
+	}
+	d.mu.Lock()
+	conn := d.conns[header.DestinationPort]
+	d.mu.Unlock()
+	if conn == nil {
+		if header.Flags&tcpRST == 0 {
+			d.sendTCP(tcpHeader{SourcePort: header.DestinationPort, DestinationPort: header.SourcePort, Flags: tcpRST, Window: receiveWindow >> 8}, nil)
+		}
+		return
+	}
+	conn.handle(header, payload[dataOffset:])
+}
+
+// connect opens a connection to port on the device
+func (d *muxDevice) connect(port uint16) (*muxConn, error) {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return nil, errDeviceClosed
+	}
+	for {
+		d.lastPort++
+		if _, ok := d.conns[d.lastPort]; d.lastPort != 0 && !ok {
+			break
+		}
+	}
+	c := &muxConn{device: d, port: d.lastPort, devicePort: port}
+	c.cond = sync.NewCond(&c.mu)
+	d.conns[c.port] = c
+	d.mu.Unlock()
+
+	timeout := time.AfterFunc(handshakeTimeout, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.state == connStateConnecting {
+			c.closeLocked(fmt.Errorf("usbdirect: device did not answer within %s", handshakeTimeout))
+		}
+	})
+	defer timeout.Stop()
+	err := d.sendTCP(tcpHeader{SourcePort: c.port, DestinationPort: port, Flags: tcpSYN, Window: receiveWindow >> 8}, nil)
+	if err != nil {
+		c.mu.Lock()
+		c.closeLocked(err)
+		c.mu.Unlock()
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.state == connStateConnecting {
+		c.cond.Wait()
+	}
+	if c.state != connStateConnected {
+		return nil, c.err
+	}
+	return c, nil
+}
+
+func (d *muxDevice) removeConn(port uint16) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.conns, port)
+}
+
+// close closes the device and all of its connections, err is the reason
+func (d *muxDevice) close(err error) {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return
+	}
+	d.closed = true
+	d.err = err
+	conns := make([]*muxConn, 0, len(d.conns))
+	for _, c := range d.conns {
+		conns = append(conns, c)
+	}
+	d.mu.Unlock()
+
+	for _, c := range conns {
+		c.mu.Lock()
+		if c.state == connStateConnecting {
+			c.closeLocked(errDeviceClosed)
+		} else {
+			c.closeLocked(io.EOF)
+		}
+		c.mu.Unlock()
+	}
+	d.transport.Close()
+	close(d.done)
+}
+
+type connState int
+
+const (
+	connStateConnecting connState = iota
+	connStateConnected
+	connStateClosed
+)
+
+// muxConn is a connection to a service port of a device. Data is acknowledged as soon as it arrives, data sent to
+// the device is held back while the window of the device is full.
+type muxConn struct {
+	device     *muxDevice
+	port       uint16
+	devicePort uint16
+
+	writeMu sync.Mutex
+
+	mu    sync.Mutex
+	cond  *sync.Cond
+	state connState
+	err   error
+	// seq is the sequence number of the next byte sent, ack the one of the next byte expected from the device
+	seq       uint32
+	ack       uint32
+	deviceAck uint32
+	window    uint32
+	received  bytes.Buffer
+	// advertised is the receive window last announced to the device, see receiveWindowLocked
+	advertised uint32
+}
+
+func (c *muxConn) handle(header tcpHeader, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if header.Flags&tcpRST != 0 {
+		if c.state == connStateConnecting {
+			c.closeLocked(errConnectionRefused)
+		} else {
+			c.closeLocked(io.EOF)
+		}
+		return
+	}
+	switch c.state {
+	case connStateConnecting:
+		if header.Flags != tcpSYN|tcpACK {
+			return
+		}
+		c.seq++
+		c.ack = header.Seq + 1
+		c.deviceAck = header.Ack
+		c.window = uint32(header.Window) << 8
+		c.state = connStateConnected
+		c.sendAckLocked()
+	case connStateConnected:
+		c.deviceAck = header.Ack
+		c.window = uint32(header.Window) << 8
+		if len(data) > 0 {
+			c.received.Write(data)
+			c.ack += uint32(len(data))
+			c.sendAckLocked()
+		}
+		if header.Flags&tcpFIN != 0 {
+			c.closeLocked(io.EOF)
+		}
+	}
+	c.cond.Broadcast()
+}
+
+func (c *muxConn) sendAckLocked() {
+	err := c.device.sendTCP(c.tcpHeaderLocked(tcpACK), nil)
+	if err != nil {
+		log.WithError(err).Debug("usbdirect: failed sending ACK")
+	}
+}
+
+// tcpHeaderLocked returns the header of the next packet of the connection, which announces the free space of the
+// receive buffer as window
+func (c *muxConn) tcpHeaderLocked(flags uint8) tcpHeader {
+	c.advertised = c.receiveWindowLocked()
+	return tcpHeader{SourcePort: c.port, DestinationPort: c.devicePort, Seq: c.seq, Ack: c.ack, Flags: flags, Window: uint16(c.advertised >> 8)}
+}
+
+// receiveWindowLocked returns the number of bytes the device may send until Read consumed the received data
+func (c *muxConn) receiveWindowLocked() uint32 {
+	if c.received.Len() >= receiveWindow {
+		return 0
+	}
+	return uint32(receiveWindow - c.received.Len())
+}
+
+// closeLocked closes the connection with err, which Read returns once the received data was read
+func (c *muxConn) closeLocked(err error) {
+	if c.state == connStateClosed {
+		return
+	}
+	c.state = connStateClosed
+	c.err = err
+	c.cond.Broadcast()
+	c.device.removeConn(c.port)
+}
+
+func (c *muxConn) Read(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.received.Len() == 0 && c.state != connStateClosed {
+		c.cond.Wait()
+	}
+	if c.received.Len() > 0 {
+		n, err := c.received.Read(p)
+		// announce the free space once half of the buffer became available, not for every read
+		if c.state == connStateConnected && c.receiveWindowLocked() >= c.advertised+receiveWindow/2 {
+			c.sendAckLocked()
+		}
+		return n, err
+	}
+	return 0, c.err
+}
+
+func (c *muxConn) Write(p []byte) (int, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	written := 0
+	for len(p) > 0 {
+		c.mu.Lock()
+		for c.state == connStateConnected && c.seq-c.deviceAck >= c.window {
+			c.cond.Wait()
+		}
+		if c.state != connStateConnected {
+			err := c.err
+			c.mu.Unlock()
+			if err == io.EOF {
+				err = io.ErrClosedPipe
+			}
+			return written, err
+		}
+		n := min(len(p), maxPayloadLen, int(c.window-(c.seq-c.deviceAck)))
+		header := c.tcpHeaderLocked(tcpACK)
+		c.seq += uint32(n)
+		c.mu.Unlock()
+
+		err := c.device.sendTCP(header, p[:n])
+		if err != nil {
+			return written, err
+		}
+		written += n
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// Close resets the connection, data that was not read yet is dropped
+func (c *muxConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.state == connStateClosed {
+		return nil
+	}
+	err := c.device.sendTCP(c.tcpHeaderLocked(tcpRST), nil)
+	c.closeLocked(errConnectionClosed)
+	c.received.Reset()
+	return err
+}
+
+func structBytes(v any) []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.BigEndian, v)
+	return b.Bytes()
+}
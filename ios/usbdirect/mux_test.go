@@ -0,0 +1,213 @@
+package usbdirect
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/danielpaulus/go-ios/ios"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	echoPort = 1234
+	// fakeDeviceWindow is small, so that sending more than a few hundred bytes has to wait for the device
+	fakeDeviceWindow = 1
+)
+
+// fakeDevice is the device side of the mux protocol and the transport to it. It echoes the data sent to
+// connections to echoPort and refuses connections to all other ports.
+type fakeDevice struct {
+	t          *testing.T
+	toHost     *io.PipeWriter
+	fromDevice *io.PipeReader
+	packets    chan []byte
+	closed     chan struct{}
+	closeOnce  sync.Once
+
+	version int
+	txSeq   uint16
+	seq     map[uint16]uint32
+}
+
+func newFakeDevice(t *testing.T) *fakeDevice {
+	fromDevice, toHost := io.Pipe()
+	d := &fakeDevice{t: t, toHost: toHost, fromDevice: fromDevice, packets: make(chan []byte, 16), closed: make(chan struct{}), seq: map[uint16]uint32{}}
+	go d.run()
+	return d
+}
+
+func (d *fakeDevice) Read(p []byte) (int, error) {
+	return d.fromDevice.Read(p)
+}
+
+func (d *fakeDevice) WritePacket(packet []byte) error {
+	select {
+	case d.packets <- bytes.Clone(packet):
+		return nil
+	case <-d.closed:
+		return io.ErrClosedPipe
+	}
+}
+
+func (d *fakeDevice) Close() error {
+	d.closeOnce.Do(func() {
+		close(d.closed)
+		d.toHost.Close()
+	})
+	return nil
+}
+
+func (d *fakeDevice) run() {
+	for {
+		var packet []byte
+		select {
+		case packet = <-d.packets:
+		case <-d.closed:
+			return
+		}
+		reader := bytes.NewReader(packet)
+		var protocol uint32
+		if d.version < muxVersion {
+			var header muxHeaderV1
+			require.NoError(d.t, binary.Read(reader, binary.BigEndian, &header))
+			require.Equal(d.t, uint32(protoVersion), header.Protocol)
+			d.version = muxVersion
+			d.send(protoVersion, structBytes(versionHeader{Major: muxVersion}), muxHeaderV1Len)
+			continue
+		}
+		var header muxHeader
+		require.NoError(d.t, binary.Read(reader, binary.BigEndian, &header))
+		require.Equal(d.t, uint32(muxMagic), header.Magic)
+		protocol = header.Protocol
+		if protocol != protoTCP {
+			continue
+		}
+		var tcp tcpHeader
+		require.NoError(d.t, binary.Read(reader, binary.BigEndian, &tcp))
+		data, _ := io.ReadAll(reader)
+		d.handleTCP(tcp, data)
+	}
+}
+
+func (d *fakeDevice) handleTCP(tcp tcpHeader, data []byte) {
+	reply := tcpHeader{SourcePort: tcp.DestinationPort, DestinationPort: tcp.SourcePort, Offset: 0x50, Window: fakeDeviceWindow}
+	switch {
+	case tcp.Flags&tcpRST != 0:
+		delete(d.seq, tcp.SourcePort)
+		return
+	case tcp.Flags == tcpSYN && tcp.DestinationPort != echoPort:
+		reply.Flags = tcpRST
+	case tcp.Flags == tcpSYN:
+		// the SYN counts as one byte
+		d.seq[tcp.SourcePort] = 101
+		reply.Flags, reply.Seq, reply.Ack = tcpSYN|tcpACK, 100, tcp.Seq+1
+	case len(data) > 0:
+		reply.Flags, reply.Seq, reply.Ack = tcpACK, d.seq[tcp.SourcePort], tcp.Seq+uint32(len(data))
+		d.seq[tcp.SourcePort] += uint32(len(data))
+	default:
+		return
+	}
+	d.send(protoTCP, append(structBytes(reply), data...), muxHeaderLen)
+}
+
+func (d *fakeDevice) send(protocol uint32, payload []byte, headerLen int) {
+	var packet bytes.Buffer
+	if headerLen == muxHeaderV1Len {
+		binary.Write(&packet, binary.BigEndian, muxHeaderV1{Protocol: protocol, Length: uint32(headerLen + len(payload))})
+	} else {
+		binary.Write(&packet, binary.BigEndian, muxHeader{Protocol: protocol, Length: uint32(headerLen + len(payload)), Magic: muxMagic, TxSeq: d.txSeq})
+		d.txSeq++
+	}
+	packet.Write(payload)
+	// split the packet, the host has to reassemble packets that span several USB transfers
+	half := packet.Len() / 2
+	d.toHost.Write(packet.Bytes()[:half])
+	d.toHost.Write(packet.Bytes()[half:])
+}
+
+func TestMuxDevice(t *testing.T) {
+	fake := newFakeDevice(t)
+	device, err := newMuxDevice(fake, ios.DeviceProperties{SerialNumber: "00008110-0001"})
+	require.NoError(t, err)
+
+	conn, err := device.connect(echoPort)
+	require.NoError(t, err)
+	message := bytes.Repeat([]byte("go-ios "), 1000)
+	go func() {
+		_, err := conn.Write(message)
+		assert.NoError(t, err)
+	}()
+	echo := make([]byte, len(message))
+	_, err = io.ReadFull(conn, echo)
+	require.NoError(t, err)
+	assert.Equal(t, message, echo)
+
+	t.Run("closed ports refuse connections", func(t *testing.T) {
+		_, err := device.connect(4321)
+		assert.ErrorIs(t, err, errConnectionRefused)
+	})
+
+	t.Run("detaching closes the connections", func(t *testing.T) {
+		fake.Close()
+		<-device.done
+
+		_, err := conn.Read(make([]byte, 1))
+		assert.Equal(t, io.EOF, err)
+		_, err = device.connect(echoPort)
+		assert.ErrorIs(t, err, errDeviceClosed)
+	})
+}
+
+// recordingTransport keeps the packets the host sends
+type recordingTransport struct {
+	io.Reader
+	packets [][]byte
+}
+
+func (r *recordingTransport) WritePacket(packet []byte) error {
+	r.packets = append(r.packets, bytes.Clone(packet))
+	return nil
+}
+
+func (r *recordingTransport) Close() error {
+	return nil
+}
+
+// lastWindow returns the window of the last TCP packet the host sent
+func (r *recordingTransport) lastWindow(t *testing.T) uint32 {
+	reader := bytes.NewReader(r.packets[len(r.packets)-1])
+	var header muxHeader
+	require.NoError(t, binary.Read(reader, binary.BigEndian, &header))
+	var tcp tcpHeader
+	require.NoError(t, binary.Read(reader, binary.BigEndian, &tcp))
+	return uint32(tcp.Window) << 8
+}
+
+func TestMuxConnReceiveWindow(t *testing.T) {
+	transport := &recordingTransport{}
+	device := &muxDevice{transport: transport, version: muxVersion, conns: map[uint16]*muxConn{}}
+	conn := &muxConn{device: device, port: 1, devicePort: echoPort, state: connStateConnected}
+	conn.cond = sync.NewCond(&conn.mu)
+
+	chunk := bytes.Repeat([]byte{1}, receiveWindow/4)
+	conn.handle(tcpHeader{Flags: tcpACK}, chunk)
+	assert.Equal(t, uint32(receiveWindow*3/4), transport.lastWindow(t))
+	for i := 0; i < 3; i++ {
+		conn.handle(tcpHeader{Flags: tcpACK}, chunk)
+	}
+	assert.Equal(t, uint32(0), transport.lastWindow(t), "the window must be closed while the buffer is full")
+
+	sent := len(transport.packets)
+	_, err := io.ReadFull(conn, make([]byte, receiveWindow/4))
+	require.NoError(t, err)
+	assert.Len(t, transport.packets, sent, "the window is not announced for small reads")
+
+	_, err = io.ReadFull(conn, make([]byte, receiveWindow/4))
+	require.NoError(t, err)
+	assert.Len(t, transport.packets, sent+1)
+	assert.Equal(t, uint32(receiveWindow/2), transport.lastWindow(t))
+}
@@ -0,0 +1,88 @@
+package usbdirect
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+	"howett.net/plist"
+)
+
+// systemConfiguration is the file of usbmuxd that contains the BUID of the host
+const systemConfiguration = "SystemConfiguration"
+
+type systemConfigurationData struct {
+	SystemBUID string
+}
+
+// pairRecordStore keeps the pair records as plist files named after the udid in dir, like usbmuxd on Linux
+type pairRecordStore struct {
+	dir string
+}
+
+func (p pairRecordStore) path(name string) (string, error) {
+	if name == "" || filepath.Base(name) != name {
+		return "", fmt.Errorf("invalid pair record id '%s'", name)
+	}
+	return filepath.Join(p.dir, name+".plist"), nil
+}
+
+func (p pairRecordStore) read(udid string) ([]byte, error) {
+	path, err := p.path(udid)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(path)
+}
+
+func (p pairRecordStore) save(udid string, record []byte) error {
+	path, err := p.path(udid)
+	if err != nil {
+		return err
+	}
+	if len(record) == 0 {
+		return errors.New("pair record is empty")
+	}
+	err = os.MkdirAll(p.dir, 0o755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, record, 0o600)
+}
+
+func (p pairRecordStore) delete(udid string) error {
+	path, err := p.path(udid)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// buid returns the BUID of the host, a new one is created and saved if there is none yet
+func (p pairRecordStore) buid() (string, error) {
+	path, _ := p.path(systemConfiguration)
+	b, err := os.ReadFile(path)
+	if err == nil {
+		var config systemConfigurationData
+		_, err = plist.Unmarshal(b, &config)
+		if err == nil && config.SystemBUID != "" {
+			return config.SystemBUID, nil
+		}
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("buid: failed reading %s: %w", path, err)
+	}
+	config := systemConfigurationData{SystemBUID: strings.ToUpper(uuid.New().String())}
+	b, err = plist.Marshal(config, plist.XMLFormat)
+	if err != nil {
+		return "", err
+	}
+	err = os.MkdirAll(p.dir, 0o755)
+	if err != nil {
+		return "", err
+	}
+	return config.SystemBUID, os.WriteFile(path, b, 0o644)
+}
@@ -0,0 +1,308 @@
+package usbdirect
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/danielpaulus/go-ios/ios"
+	log "github.com/sirupsen/logrus"
+	"howett.net/plist"
+)
+
+// result codes of usbmuxd
+const (
+	resultOK           = 0
+	resultBadCommand   = 1
+	resultBadDevice    = 2
+	resultConnRefused  = 3
+	listenerBufferSize = 32
+)
+
+type muxResult struct {
+	MessageType string
+	Number      int
+}
+
+type deviceAttached struct {
+	DeviceID    int
+	MessageType string
+	Properties  ios.DeviceProperties
+}
+
+type deviceDetached struct {
+	DeviceID    int
+	MessageType string
+}
+
+type deviceList struct {
+	DeviceList []deviceAttached
+}
+
+type pairRecordData struct {
+	PairRecordData []byte
+}
+
+type buidResponse struct {
+	BUID string
+}
+
+// server answers the usbmuxd requests of go-ios for the devices attached over USB
+type server struct {
+	pairRecords pairRecordStore
+
+	mu        sync.Mutex
+	devices   map[int]*muxDevice
+	lastID    int
+	listeners map[chan any]bool
+}
+
+func newServer(pairRecords pairRecordStore) *server {
+	return &server{
+		pairRecords: pairRecords,
+		devices:     map[int]*muxDevice{},
+		listeners:   map[chan any]bool{},
+	}
+}
+
+// attach adds device with a new device id and detaches it once it is closed
+func (s *server) attach(device *muxDevice) {
+	s.mu.Lock()
+	s.lastID++
+	id := s.lastID
+	device.properties.DeviceID = id
+	s.devices[id] = device
+	s.notifyLocked(deviceAttached{DeviceID: id, MessageType: "Attached", Properties: device.properties})
+	s.mu.Unlock()
+	log.WithFields(log.Fields{"udid": device.properties.SerialNumber, "deviceID": id}).Info("usbdirect: device attached")
+
+	go func() {
+		<-device.done
+		s.mu.Lock()
+		delete(s.devices, id)
+		s.notifyLocked(deviceDetached{DeviceID: id, MessageType: "Detached"})
+		s.mu.Unlock()
+		log.WithFields(log.Fields{"udid": device.properties.SerialNumber, "deviceID": id, "reason": device.err}).Info("usbdirect: device detached")
+	}()
+}
+
+func (s *server) notifyLocked(message any) {
+	for listener := range s.listeners {
+		select {
+		case listener <- message:
+		default:
+			log.Warn("usbdirect: a listener is not reading device events, dropping it")
+			delete(s.listeners, listener)
+			close(listener)
+		}
+	}
+}
+
+func (s *server) device(id int) (*muxDevice, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	device, ok := s.devices[id]
+	return device, ok
+}
+
+func (s *server) serve(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handle(conn)
+	}
+}
+
+// handle answers the requests of a client until it disconnects, or until it starts listening or connects to a
+// device, which turns conn into an event stream or a connection to the device.
+func (s *server) handle(conn net.Conn) {
+	defer conn.Close()
+	muxConn := ios.NewUsbMuxConnection(ios.NewDeviceConnectionWithConn(conn))
+	for {
+		request, err := muxConn.ReadMessage()
+		if err != nil {
+			if err != io.EOF {
+				log.WithError(err).Debug("usbdirect: failed reading request")
+			}
+			return
+		}
+		var decoded map[string]any
+		_, err = plist.Unmarshal(request.Payload, &decoded)
+		if err != nil {
+			log.WithError(err).Debug("usbdirect: failed decoding request")
+			return
+		}
+		tag := request.Header.Tag
+		switch decoded["MessageType"] {
+		case "ListDevices":
+			err = s.listDevices(muxConn, tag)
+		case "Listen":
+			s.listen(muxConn, conn, tag)
+			return
+		case "Connect":
+			s.connect(muxConn, conn, tag, decoded)
+			return
+		case "ReadPairRecord":
+			err = s.readPairRecord(muxConn, tag, decoded)
+		case "SavePairRecord":
+			err = s.savePairRecord(muxConn, tag, decoded)
+		case "DeletePairRecord":
+			err = s.deletePairRecord(muxConn, tag, decoded)
+		case "ReadBUID":
+			err = s.readBUID(muxConn, tag)
+		default:
+			log.Debugf("usbdirect: unsupported request %v", decoded["MessageType"])
+			err = sendResult(muxConn, tag, resultBadCommand)
+		}
+		if err != nil {
+			log.WithError(err).Debug("usbdirect: failed answering request")
+			return
+		}
+	}
+}
+
+func (s *server) listDevices(muxConn *ios.UsbMuxConnection, tag uint32) error {
+	s.mu.Lock()
+	list := deviceList{DeviceList: []deviceAttached{}}
+	for id, device := range s.devices {
+		list.DeviceList = append(list.DeviceList, deviceAttached{DeviceID: id, MessageType: "Attached", Properties: device.properties})
+	}
+	s.mu.Unlock()
+	return send(muxConn, tag, list)
+}
+
+// listen sends the attached devices and then every attach and detach until the client disconnects
+func (s *server) listen(muxConn *ios.UsbMuxConnection, conn net.Conn, tag uint32) {
+	s.mu.Lock()
+	events := make(chan any, len(s.devices)+listenerBufferSize)
+	s.listeners[events] = true
+	for id, device := range s.devices {
+		events <- deviceAttached{DeviceID: id, MessageType: "Attached", Properties: device.properties}
+	}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		if s.listeners[events] {
+			delete(s.listeners, events)
+			close(events)
+		}
+		s.mu.Unlock()
+	}()
+
+	if sendResult(muxConn, tag, resultOK) != nil {
+		return
+	}
+	disconnected := make(chan struct{})
+	go func() {
+		// clients do not send anything after the listen request, reading only detects that they are gone
+		io.Copy(io.Discard, conn)
+		close(disconnected)
+	}()
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok || send(muxConn, 0, event) != nil {
+				return
+			}
+		case <-disconnected:
+			return
+		}
+	}
+}
+
+// connect connects to a port of a device and forwards the data of conn to it
+func (s *server) connect(muxConn *ios.UsbMuxConnection, conn net.Conn, tag uint32, request map[string]any) {
+	id, _ := intValue(request["DeviceID"])
+	portNumber, _ := intValue(request["PortNumber"])
+	device, ok := s.device(id)
+	if !ok {
+		sendResult(muxConn, tag, resultBadDevice)
+		return
+	}
+	// clients send the port in network byte order
+	port := ios.Ntohs(uint16(portNumber))
+	deviceConn, err := device.connect(port)
+	if err != nil {
+		log.WithFields(log.Fields{"udid": device.properties.SerialNumber, "port": port, "error": err}).Debug("usbdirect: connect failed")
+		sendResult(muxConn, tag, resultConnRefused)
+		return
+	}
+	defer deviceConn.Close()
+	if sendResult(muxConn, tag, resultOK) != nil {
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		io.Copy(deviceConn, conn)
+		deviceConn.Close()
+		close(done)
+	}()
+	io.Copy(conn, deviceConn)
+	conn.Close()
+	<-done
+}
+
+func (s *server) readPairRecord(muxConn *ios.UsbMuxConnection, tag uint32, request map[string]any) error {
+	udid, _ := request["PairRecordID"].(string)
+	record, err := s.pairRecords.read(udid)
+	if err != nil {
+		log.WithFields(log.Fields{"udid": udid, "error": err}).Debug("usbdirect: no pair record")
+		return sendResult(muxConn, tag, resultBadDevice)
+	}
+	return send(muxConn, tag, pairRecordData{PairRecordData: record})
+}
+
+func (s *server) savePairRecord(muxConn *ios.UsbMuxConnection, tag uint32, request map[string]any) error {
+	udid, _ := request["PairRecordID"].(string)
+	record, _ := request["PairRecordData"].([]byte)
+	err := s.pairRecords.save(udid, record)
+	if err != nil {
+		log.WithFields(log.Fields{"udid": udid, "error": err}).Error("usbdirect: failed saving pair record")
+		return sendResult(muxConn, tag, resultBadDevice)
+	}
+	return sendResult(muxConn, tag, resultOK)
+}
+
+func (s *server) deletePairRecord(muxConn *ios.UsbMuxConnection, tag uint32, request map[string]any) error {
+	udid, _ := request["PairRecordID"].(string)
+	err := s.pairRecords.delete(udid)
+	if err != nil {
+		log.WithFields(log.Fields{"udid": udid, "error": err}).Debug("usbdirect: failed deleting pair record")
+		return sendResult(muxConn, tag, resultBadDevice)
+	}
+	return sendResult(muxConn, tag, resultOK)
+}
+
+func (s *server) readBUID(muxConn *ios.UsbMuxConnection, tag uint32) error {
+	buid, err := s.pairRecords.buid()
+	if err != nil {
+		return errors.Join(err, sendResult(muxConn, tag, resultBadCommand))
+	}
+	return send(muxConn, tag, buidResponse{BUID: buid})
+}
+
+func sendResult(muxConn *ios.UsbMuxConnection, tag uint32, number int) error {
+	return send(muxConn, tag, muxResult{MessageType: "Result", Number: number})
+}
+
+// send sends message as the answer to the request with tag
+func send(muxConn *ios.UsbMuxConnection, tag uint32, message any) error {
+	payload := ios.ToPlistBytes(message)
+	return muxConn.SendMuxMessage(ios.UsbMuxMessage{
+		Header:  ios.UsbMuxHeader{Length: uint32(16 + len(payload)), Version: 1, Request: 8, Tag: tag},
+		Payload: payload,
+	})
+}
+
+func intValue(v any) (int, bool) {
+	switch i := v.(type) {
+	case uint64:
+		return int(i), true
+	case int64:
+		return int(i), true
+	}
+	return 0, false
+}
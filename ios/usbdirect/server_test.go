@@ -0,0 +1,121 @@
+package usbdirect
+
+import (
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/danielpaulus/go-ios/ios"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testUdid = "00008110-0001"
+
+func startTestServer(t *testing.T) (*server, *fakeDevice, string) {
+	pairRecordDir := t.TempDir()
+	s := newServer(pairRecordStore{dir: pairRecordDir})
+	listener, err := net.Listen("unix", filepath.Join(t.TempDir(), "usbmuxd"))
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	go s.serve(listener)
+
+	fake := newFakeDevice(t)
+	t.Cleanup(func() { fake.Close() })
+	device, err := newMuxDevice(fake, ios.DeviceProperties{SerialNumber: testUdid, ConnectionType: ios.ConnectionTypeUSB})
+	require.NoError(t, err)
+	s.attach(device)
+	return s, fake, listener.Addr().String()
+}
+
+func dialTestServer(t *testing.T, socket string) *ios.UsbMuxConnection {
+	conn, err := net.Dial("unix", socket)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return ios.NewUsbMuxConnection(ios.NewDeviceConnectionWithConn(conn))
+}
+
+func TestServer(t *testing.T) {
+	s, fake, socket := startTestServer(t)
+
+	t.Run("list devices", func(t *testing.T) {
+		list, err := dialTestServer(t, socket).ListDevices()
+
+		require.NoError(t, err)
+		require.Len(t, list.DeviceList, 1)
+		assert.Equal(t, 1, list.DeviceList[0].DeviceID)
+		assert.Equal(t, testUdid, list.DeviceList[0].Properties.SerialNumber)
+		assert.Equal(t, ios.ConnectionTypeUSB, list.DeviceList[0].Properties.ConnectionType)
+	})
+
+	t.Run("pair records", func(t *testing.T) {
+		muxConn := dialTestServer(t, socket)
+		_, err := muxConn.ReadPair(testUdid)
+		assert.Error(t, err, "the device is not paired yet")
+
+		record := ios.ToPlistBytes(ios.PairRecord{HostID: "host", SystemBUID: "buid"})
+		require.NoError(t, os.WriteFile(filepath.Join(s.pairRecords.dir, testUdid+".plist"), record, 0o600))
+		pairRecord, err := muxConn.ReadPair(testUdid)
+		require.NoError(t, err)
+		assert.Equal(t, "host", pairRecord.HostID)
+
+		buid, err := muxConn.ReadBuid()
+		require.NoError(t, err)
+		assert.NotEmpty(t, buid)
+		again, err := muxConn.ReadBuid()
+		require.NoError(t, err)
+		assert.Equal(t, buid, again, "the BUID is saved")
+
+		imported := ios.PairRecord{HostID: "other host", HostCertificate: []byte("cert"), HostPrivateKey: []byte("key")}
+		require.NoError(t, muxConn.SavePairRecord(testUdid, imported))
+		pairRecord, err = muxConn.ReadPair(testUdid)
+		require.NoError(t, err)
+		assert.Equal(t, imported.HostID, pairRecord.HostID)
+
+		require.NoError(t, muxConn.DeletePairRecord(testUdid))
+		_, err = muxConn.ReadPair(testUdid)
+		assert.Error(t, err)
+		assert.Error(t, muxConn.DeletePairRecord(testUdid), "there is no pair record anymore")
+	})
+
+	t.Run("connect", func(t *testing.T) {
+		muxConn := dialTestServer(t, socket)
+		assert.Error(t, muxConn.Connect(1, 4321))
+
+		muxConn = dialTestServer(t, socket)
+		require.NoError(t, muxConn.Connect(1, echoPort))
+		conn := muxConn.ReleaseDeviceConnection()
+		_, err := conn.Write([]byte("ping"))
+		require.NoError(t, err)
+		pong := make([]byte, 4)
+		_, err = io.ReadFull(conn, pong)
+		require.NoError(t, err)
+		assert.Equal(t, "ping", string(pong))
+	})
+
+	t.Run("listen", func(t *testing.T) {
+		receive, err := dialTestServer(t, socket).Listen()
+		require.NoError(t, err)
+
+		attached, err := receive()
+		require.NoError(t, err)
+		assert.True(t, attached.DeviceAttached())
+		assert.Equal(t, testUdid, attached.Properties.SerialNumber)
+
+		fake.Close()
+		detached, err := receive()
+		require.NoError(t, err)
+		assert.True(t, detached.DeviceDetached())
+		assert.Equal(t, 1, detached.DeviceID)
+	})
+}
+
+func TestPairRecordStoreRejectsPaths(t *testing.T) {
+	store := pairRecordStore{dir: t.TempDir()}
+
+	assert.Error(t, store.save("../udid", []byte("record")))
+	_, err := store.read("")
+	assert.Error(t, err)
+}
@@ -0,0 +1,267 @@
+//go:build usbdirect
+
+package usbdirect
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/danielpaulus/go-ios/ios"
+	"github.com/google/gousb"
+	log "github.com/sirupsen/logrus"
+)
+
+// Supported is true if go-ios was built with the usbdirect build tag and Start can access USB devices
+const Supported = true
+
+const (
+	vidApple     = 0x5ac
+	pidRangeLow  = 0x1290
+	pidRangeHigh = 0x12af
+
+	// the mux interface of a device, usbmuxd looks for the same class, subclass and protocol
+	muxInterfaceClass    = gousb.ClassVendorSpec
+	muxInterfaceSubClass = 0xfe
+	muxInterfaceProtocol = 2
+
+	usbScanInterval = time.Second
+	// readStreamTransfers is the number of transfers of maxPacketLen that are kept pending on the in endpoint
+	readStreamTransfers = 4
+)
+
+// watchUSB attaches the devices that are connected now before it returns and keeps looking for new devices until
+// ctx is done
+func watchUSB(ctx context.Context, attach func(*muxDevice)) error {
+	usbCtx := gousb.NewContext()
+	w := &usbWatcher{usbCtx: usbCtx, attach: attach, open: map[string]bool{}}
+	w.scan()
+	go func() {
+		defer usbCtx.Close()
+		ticker := time.NewTicker(usbScanInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				w.closeAll()
+				return
+			case <-ticker.C:
+				w.scan()
+			}
+		}
+	}()
+	return nil
+}
+
+type usbWatcher struct {
+	usbCtx *gousb.Context
+	attach func(*muxDevice)
+
+	mu sync.Mutex
+	// open contains the bus and address of the devices that are in use
+	open    map[string]bool
+	devices []*muxDevice
+}
+
+func usbLocation(desc *gousb.DeviceDesc) string {
+	return fmt.Sprintf("%d.%d", desc.Bus, desc.Address)
+}
+
+func (w *usbWatcher) scan() {
+	devices, err := w.usbCtx.OpenDevices(func(desc *gousb.DeviceDesc) bool {
+		if desc.Vendor != vidApple || desc.Product < pidRangeLow || desc.Product > pidRangeHigh {
+			return false
+		}
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		return !w.open[usbLocation(desc)]
+	})
+	if err != nil {
+		log.WithError(err).Debug("usbdirect: failed opening some USB devices")
+	}
+	for _, device := range devices {
+		location := usbLocation(device.Desc)
+		muxDevice, err := openMuxDevice(device)
+		if err != nil {
+			log.WithFields(log.Fields{"device": device.String(), "error": err}).Warn("usbdirect: cannot use device")
+			device.Close()
+			continue
+		}
+		w.mu.Lock()
+		w.open[location] = true
+		w.devices = append(w.devices, muxDevice)
+		w.mu.Unlock()
+		go func() {
+			<-muxDevice.done
+			w.mu.Lock()
+			delete(w.open, location)
+			w.mu.Unlock()
+		}()
+		w.attach(muxDevice)
+	}
+}
+
+func (w *usbWatcher) closeAll() {
+	w.mu.Lock()
+	devices := w.devices
+	w.devices = nil
+	w.mu.Unlock()
+	for _, device := range devices {
+		device.close(errDeviceClosed)
+	}
+}
+
+// openMuxDevice activates the configuration with the mux interface of device and claims the interface
+func openMuxDevice(device *gousb.Device) (*muxDevice, error) {
+	serial, err := device.SerialNumber()
+	if err != nil {
+		return nil, fmt.Errorf("failed reading serial number: %w", err)
+	}
+	// the serial number of newer devices lacks the dash of the udid
+	udid := strings.Trim(serial, "\x00")
+	if len(udid) == 24 {
+		udid = udid[:8] + "-" + udid[8:]
+	}
+	config, setting, err := findMuxInterface(device.Desc)
+	if err != nil {
+		return nil, err
+	}
+	err = device.SetAutoDetach(true)
+	if err != nil {
+		return nil, fmt.Errorf("failed enabling kernel driver detach: %w", err)
+	}
+	cfg, err := device.Config(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed activating configuration %d: %w", config, err)
+	}
+	iface, err := cfg.Interface(setting.Number, setting.Alternate)
+	if err != nil {
+		cfg.Close()
+		return nil, fmt.Errorf("failed claiming the mux interface, is usbmuxd running? %w", err)
+	}
+	t, err := newUSBTransport(device, cfg, iface)
+	if err != nil {
+		return nil, err
+	}
+	properties := ios.DeviceProperties{
+		ConnectionSpeed: connectionSpeed(device.Desc.Speed),
+		ConnectionType:  ios.ConnectionTypeUSB,
+		LocationID:      device.Desc.Bus<<16 | device.Desc.Address,
+		ProductID:       int(device.Desc.Product),
+		SerialNumber:    udid,
+	}
+	return newMuxDevice(t, properties)
+}
+
+func findMuxInterface(desc *gousb.DeviceDesc) (int, gousb.InterfaceSetting, error) {
+	for number, config := range desc.Configs {
+		for _, iface := range config.Interfaces {
+			for _, setting := range iface.AltSettings {
+				if setting.Class == muxInterfaceClass && setting.SubClass == muxInterfaceSubClass && setting.Protocol == muxInterfaceProtocol {
+					return number, setting, nil
+				}
+			}
+		}
+	}
+	return 0, gousb.InterfaceSetting{}, fmt.Errorf("device has no mux interface")
+}
+
+func connectionSpeed(speed gousb.Speed) int {
+	switch speed {
+	case gousb.SpeedLow:
+		return 1500000
+	case gousb.SpeedFull:
+		return 12000000
+	case gousb.SpeedHigh:
+		return 480000000
+	case gousb.SpeedSuper:
+		// a variable, the constant would not fit into int on 32 bit platforms
+		superSpeed := int64(5000000000)
+		return int(superSpeed)
+	}
+	return 0
+}
+
+// usbTransport reads and writes mux packets with the bulk endpoints of the mux interface
+type usbTransport struct {
+	device *gousb.Device
+	config *gousb.Config
+	iface  *gousb.Interface
+	out    *gousb.OutEndpoint
+	in     *gousb.ReadStream
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	// readMu is held while reading, the stream must not be closed during a read
+	readMu    sync.Mutex
+	closeOnce sync.Once
+}
+
+func newUSBTransport(device *gousb.Device, config *gousb.Config, iface *gousb.Interface) (*usbTransport, error) {
+	inNumber, outNumber := -1, -1
+	for _, endpoint := range iface.Setting.Endpoints {
+		switch endpoint.Direction {
+		case gousb.EndpointDirectionIn:
+			inNumber = endpoint.Number
+		case gousb.EndpointDirectionOut:
+			outNumber = endpoint.Number
+		}
+	}
+	t := &usbTransport{device: device, config: config, iface: iface}
+	t.ctx, t.cancel = context.WithCancel(context.Background())
+	if inNumber == -1 || outNumber == -1 {
+		t.Close()
+		return nil, fmt.Errorf("mux interface has no bulk endpoints")
+	}
+	in, err := iface.InEndpoint(inNumber)
+	if err != nil {
+		t.Close()
+		return nil, fmt.Errorf("failed opening in endpoint: %w", err)
+	}
+	t.out, err = iface.OutEndpoint(outNumber)
+	if err != nil {
+		t.Close()
+		return nil, fmt.Errorf("failed opening out endpoint: %w", err)
+	}
+	t.in, err = in.NewStream(maxPacketLen, readStreamTransfers)
+	if err != nil {
+		t.Close()
+		return nil, fmt.Errorf("failed opening in stream: %w", err)
+	}
+	return t, nil
+}
+
+func (t *usbTransport) Read(p []byte) (int, error) {
+	t.readMu.Lock()
+	defer t.readMu.Unlock()
+	return t.in.ReadContext(t.ctx, p)
+}
+
+func (t *usbTransport) WritePacket(packet []byte) error {
+	_, err := t.out.WriteContext(t.ctx, packet)
+	if err != nil {
+		return err
+	}
+	// a packet that fills the last USB packet completely is terminated with a zero length packet
+	if len(packet)%t.out.Desc.MaxPacketSize == 0 {
+		_, err = t.out.WriteContext(t.ctx, nil)
+	}
+	return err
+}
+
+func (t *usbTransport) Close() error {
+	t.closeOnce.Do(func() {
+		t.cancel()
+		t.readMu.Lock()
+		defer t.readMu.Unlock()
+		if t.in != nil {
+			t.in.Close()
+		}
+		t.iface.Close()
+		t.config.Close()
+		t.device.Close()
+	})
+	return nil
+}
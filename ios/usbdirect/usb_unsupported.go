@@ -0,0 +1,12 @@
+//go:build !usbdirect
+
+package usbdirect
+
+import "context"
+
+// Supported is true if go-ios was built with the usbdirect build tag and Start can access USB devices
+const Supported = false
+
+func watchUSB(ctx context.Context, attach func(*muxDevice)) error {
+	return ErrNotSupported
+}
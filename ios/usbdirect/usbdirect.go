@@ -0,0 +1,57 @@
+// Package usbdirect talks to iOS devices over USB without usbmuxd. It speaks the USB mux protocol of the devices
+// with libusb and offers them on a usbmuxd compatible socket, so that the rest of go-ios works unchanged.
+// Accessing USB needs cgo and libusb-1.0, so it is only built with the usbdirect build tag:
+//
+//	go build -tags usbdirect
+package usbdirect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultPairRecordDir is where usbmuxd keeps the pair records on Linux, using it shares the pairings with usbmuxd
+const DefaultPairRecordDir = "/var/lib/lockdown"
+
+// ErrNotSupported is returned by Start if go-ios was built without the usbdirect build tag
+var ErrNotSupported = errors.New("go-ios was built without direct USB support, install libusb-1.0 (f.ex. libusb-1.0-0-dev) and rebuild it with 'CGO_ENABLED=1 go build -tags usbdirect'")
+
+// Start opens the devices attached over USB and serves them on a usbmuxd compatible unix socket until ctx is
+// done. Devices attached later are picked up while it runs. Pair records are read from and saved to pairRecordDir.
+// It returns the path of the socket, set it as USBMUXD_SOCKET_ADDRESS to use it instead of usbmuxd.
+func Start(ctx context.Context, pairRecordDir string) (string, error) {
+	s := newServer(pairRecordStore{dir: pairRecordDir})
+	err := watchUSB(ctx, s.attach)
+	if err != nil {
+		return "", err
+	}
+	dir, err := os.MkdirTemp("", "go-ios-usbdirect")
+	if err != nil {
+		return "", fmt.Errorf("Start: failed creating socket directory: %w", err)
+	}
+	socket := filepath.Join(dir, "usbmuxd")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("Start: failed to listen on %s: %w", socket, err)
+	}
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+		os.RemoveAll(dir)
+	}()
+	go func() {
+		err := s.serve(listener)
+		if err != nil && ctx.Err() == nil {
+			log.WithError(err).Error("usbdirect: stopped serving the usbmuxd socket")
+		}
+	}()
+	log.WithField("socket", socket).Debug("usbdirect: serving USB devices")
+	return socket, nil
+}
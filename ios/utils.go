@@ -116,6 +116,8 @@ func Ntohs(port uint16) uint16 {
 // the device for the udid if a valid udid is provided.
 // if the env variable 'udid' is specified, the device with that udid
 // otherwise it returns the first device in the list.
+// Devices connected over USB and Wi-Fi are returned with their USB connection, devices only connected over Wi-Fi
+// with their network connection.
 func GetDevice(udid string) (DeviceEntry, error) {
 	return GetDeviceWithAddress(udid, "", nil)
 }
@@ -136,19 +138,17 @@ func GetDeviceWithAddress(udid string, address string, provider RsdPortProvider)
 		if len(deviceList.DeviceList) == 0 {
 			return DeviceEntry{}, errors.New("no iOS devices are attached to this host")
 		}
-		device := deviceList.DeviceList[0]
+		device, _ := findDevice(deviceList, deviceList.DeviceList[0].Properties.SerialNumber)
 		log.WithFields(log.Fields{"udid": device.Properties.SerialNumber}).
 			Info("no udid specified using first device in list")
 		device.Address = address
 		device.Rsd = provider
 		return device, nil
 	}
-	for _, device := range deviceList.DeviceList {
-		if device.Properties.SerialNumber == udid {
-			device.Address = address
-			device.Rsd = provider
-			return device, nil
-		}
+	if device, ok := findDevice(deviceList, udid); ok {
+		device.Address = address
+		device.Rsd = provider
+		return device, nil
 	}
 	return DeviceEntry{}, fmt.Errorf("Device '%s' not found. Is it attached to the machine?", udid)
 }
@@ -0,0 +1,338 @@
+// Package wda keeps a WebDriverAgent runner alive on a device. It starts the runner as XCUITest, forwards its HTTP
+// port to the host, checks its /status endpoint periodically and restarts the runner when it stops responding.
+package wda
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/danielpaulus/go-ios/ios"
+	"github.com/danielpaulus/go-ios/ios/forward"
+	"github.com/danielpaulus/go-ios/ios/testmanagerd"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultBundleID is the bundle id of the WebDriverAgent runner built from the Facebook/Appium project, it is
+	// used for BundleID and TestRunnerBundleID if none is set
+	DefaultBundleID = "com.facebook.WebDriverAgentRunner.xctrunner"
+	// DefaultXCTestConfig is the test bundle of the WebDriverAgent runner
+	DefaultXCTestConfig = "WebDriverAgentRunner.xctest"
+	// DefaultPort is the port WebDriverAgent listens on, on the device and on the host
+	DefaultPort = 8100
+
+	defaultHealthCheckInterval = 10 * time.Second
+	defaultHealthCheckTimeout  = 5 * time.Second
+	defaultMaxFailedChecks     = 3
+	defaultStartupTimeout      = 2 * time.Minute
+	defaultRestartDelay        = 2 * time.Second
+)
+
+// Config configures the WebDriverAgent runner of a Supervisor. Zero values are replaced with the defaults.
+type Config struct {
+	BundleID           string
+	TestRunnerBundleID string
+	XCTestConfig       string
+	Args               []string
+	Env                map[string]interface{}
+	// HostPort is the port on the host WebDriverAgent is reachable on and DevicePort the one it listens on on the device
+	HostPort   uint16
+	DevicePort uint16
+	// HealthCheckInterval is the time between two requests to /status, each of them may take HealthCheckTimeout
+	HealthCheckInterval time.Duration
+	HealthCheckTimeout  time.Duration
+	// MaxFailedHealthChecks is the number of health checks in a row that have to fail before the runner is restarted
+	MaxFailedHealthChecks int
+	// StartupTimeout is how long a started runner may take until its first successful health check
+	StartupTimeout time.Duration
+	// RestartDelay is the time to wait after the runner ended before it is started again
+	RestartDelay time.Duration
+	// LogWriter receives the log of the test runner, it is discarded if nil
+	LogWriter io.Writer
+}
+
+func (c Config) withDefaults() Config {
+	if c.BundleID == "" && c.TestRunnerBundleID == "" && c.XCTestConfig == "" {
+		c.BundleID, c.TestRunnerBundleID, c.XCTestConfig = DefaultBundleID, DefaultBundleID, DefaultXCTestConfig
+	}
+	if c.HostPort == 0 {
+		c.HostPort = DefaultPort
+	}
+	if c.DevicePort == 0 {
+		c.DevicePort = DefaultPort
+	}
+	if c.HealthCheckInterval <= 0 {
+		c.HealthCheckInterval = defaultHealthCheckInterval
+	}
+	if c.HealthCheckTimeout <= 0 {
+		c.HealthCheckTimeout = defaultHealthCheckTimeout
+	}
+	if c.MaxFailedHealthChecks <= 0 {
+		c.MaxFailedHealthChecks = defaultMaxFailedChecks
+	}
+	if c.StartupTimeout <= 0 {
+		c.StartupTimeout = defaultStartupTimeout
+	}
+	if c.RestartDelay <= 0 {
+		c.RestartDelay = defaultRestartDelay
+	}
+	if c.LogWriter == nil {
+		c.LogWriter = io.Discard
+	}
+	return c
+}
+
+// State is the lifecycle state of a supervised WebDriverAgent
+type State string
+
+const (
+	// StateStarting means the runner was launched and WebDriverAgent did not answer a health check yet
+	StateStarting = State("starting")
+	StateRunning  = State("running")
+	// StateUnhealthy means the last health check failed, the runner is restarted after MaxFailedHealthChecks failures
+	StateUnhealthy  = State("unhealthy")
+	StateRestarting = State("restarting")
+	StateStopped    = State("stopped")
+)
+
+// Status describes the state of a supervised WebDriverAgent
+type Status struct {
+	State State `json:"state"`
+	// URL is the address WebDriverAgent is reachable on from the host
+	URL string `json:"url"`
+	// Restarts is the number of times the runner was restarted since Start
+	Restarts        int       `json:"restarts"`
+	LastHealthCheck time.Time `json:"lastHealthCheck,omitempty"`
+	// LastError is the reason of the last failed health check or restart
+	LastError string `json:"lastError,omitempty"`
+}
+
+// ServerStatus is the answer of the /status endpoint of WebDriverAgent
+type ServerStatus struct {
+	Value struct {
+		Ready   bool   `json:"ready"`
+		Message string `json:"message"`
+	} `json:"value"`
+	SessionID string `json:"sessionId"`
+}
+
+// CheckStatus requests the /status endpoint of the WebDriverAgent at url and returns an error unless it answers
+// that it is ready
+func CheckStatus(ctx context.Context, url string) (ServerStatus, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url+"/status", nil)
+	if err != nil {
+		return ServerStatus{}, fmt.Errorf("CheckStatus: %w", err)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return ServerStatus{}, fmt.Errorf("CheckStatus: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return ServerStatus{}, fmt.Errorf("CheckStatus: unexpected status code %d", response.StatusCode)
+	}
+	var status ServerStatus
+	if err := json.NewDecoder(response.Body).Decode(&status); err != nil {
+		return ServerStatus{}, fmt.Errorf("CheckStatus: cannot decode status: %w", err)
+	}
+	if !status.Value.Ready {
+		return status, fmt.Errorf("CheckStatus: WebDriverAgent is not ready: %s", status.Value.Message)
+	}
+	return status, nil
+}
+
+// Supervisor runs WebDriverAgent on a device and restarts it when the runner ends or stops answering health checks
+type Supervisor struct {
+	device ios.DeviceEntry
+	config Config
+
+	// run, forward and checkStatus are replaced in tests
+	run         func(context.Context, testmanagerd.TestConfig) ([]testmanagerd.TestSuite, error)
+	forward     func(device ios.DeviceEntry, hostPort uint16, devicePort uint16) (io.Closer, error)
+	checkStatus func(ctx context.Context, url string) (ServerStatus, error)
+
+	mu        sync.Mutex
+	status    Status
+	cancel    context.CancelFunc
+	done      chan struct{}
+	forwarder io.Closer
+}
+
+// NewSupervisor creates a Supervisor for WebDriverAgent on device, it does nothing until Start is called
+func NewSupervisor(device ios.DeviceEntry, config Config) *Supervisor {
+	config = config.withDefaults()
+	return &Supervisor{
+		device:      device,
+		config:      config,
+		run:         testmanagerd.RunTestWithConfig,
+		forward:     forwardPort,
+		checkStatus: CheckStatus,
+		status:      Status{State: StateStopped, URL: fmt.Sprintf("http://127.0.0.1:%d", config.HostPort)},
+	}
+}
+
+func forwardPort(device ios.DeviceEntry, hostPort uint16, devicePort uint16) (io.Closer, error) {
+	return forward.Forward(device, hostPort, devicePort)
+}
+
+// Start forwards the WebDriverAgent port and launches the runner. It returns as soon as the runner was launched,
+// use Status to find out when WebDriverAgent is ready.
+func (s *Supervisor) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		return errors.New("Start: WebDriverAgent is already supervised")
+	}
+	forwarder, err := s.forward(s.device, s.config.HostPort, s.config.DevicePort)
+	if err != nil {
+		return fmt.Errorf("Start: cannot forward port %d: %w", s.config.HostPort, err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	s.forwarder = forwarder
+	s.status = Status{State: StateStarting, URL: s.status.URL}
+	go s.supervise(ctx, s.done)
+	return nil
+}
+
+// Stop stops the runner and the port forwarding and waits until the runner ended
+func (s *Supervisor) Stop() error {
+	s.mu.Lock()
+	cancel, done, forwarder := s.cancel, s.done, s.forwarder
+	s.cancel, s.done, s.forwarder = nil, nil, nil
+	s.mu.Unlock()
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+	<-done
+	if err := forwarder.Close(); err != nil {
+		return fmt.Errorf("Stop: %w", err)
+	}
+	return nil
+}
+
+// Status returns the current state of WebDriverAgent
+func (s *Supervisor) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+func (s *Supervisor) supervise(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	defer s.update(func(status *Status) { status.State = StateStopped })
+	for {
+		runCtx, stopRunner := context.WithCancel(ctx)
+		ended := make(chan error, 1)
+		go func() {
+			_, err := s.run(runCtx, s.testConfig())
+			ended <- err
+		}()
+		reason := s.watch(ctx, ended)
+		stopRunner()
+		if reason != nil && !errors.Is(reason, errRunnerEnded) {
+			<-ended
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		log.WithFields(log.Fields{"udid": s.device.Properties.SerialNumber, "reason": reason}).Warn("restarting WebDriverAgent")
+		s.update(func(status *Status) {
+			status.State = StateRestarting
+			status.Restarts++
+			status.LastError = reason.Error()
+		})
+		select {
+		case <-time.After(s.config.RestartDelay):
+		case <-ctx.Done():
+			return
+		}
+		s.update(func(status *Status) { status.State = StateStarting })
+	}
+}
+
+// errRunnerEnded is returned by watch if the runner ended by itself
+var errRunnerEnded = errors.New("WebDriverAgent runner ended")
+
+// watch checks the health of WebDriverAgent until the runner ended, it has to be restarted or ctx is done. It returns
+// the reason for restarting the runner, nil if ctx is done.
+func (s *Supervisor) watch(ctx context.Context, ended <-chan error) error {
+	ticker := time.NewTicker(s.config.HealthCheckInterval)
+	defer ticker.Stop()
+	started := time.Now()
+	ready := false
+	failures := 0
+	for {
+		select {
+		case <-ctx.Done():
+			<-ended
+			return nil
+		case err := <-ended:
+			if err != nil {
+				return fmt.Errorf("%w: %w", errRunnerEnded, err)
+			}
+			return errRunnerEnded
+		case <-ticker.C:
+		}
+		checkCtx, cancel := context.WithTimeout(ctx, s.config.HealthCheckTimeout)
+		_, err := s.checkStatus(checkCtx, s.Status().URL)
+		cancel()
+		if ctx.Err() != nil {
+			continue
+		}
+		s.update(func(status *Status) {
+			status.LastHealthCheck = time.Now()
+			switch {
+			case err == nil:
+				status.State = StateRunning
+			case ready:
+				status.State = StateUnhealthy
+			}
+			if err != nil {
+				status.LastError = err.Error()
+			}
+		})
+		if err == nil {
+			ready = true
+			failures = 0
+			continue
+		}
+		if !ready {
+			if time.Since(started) >= s.config.StartupTimeout {
+				return fmt.Errorf("WebDriverAgent did not become ready within %s: %w", s.config.StartupTimeout, err)
+			}
+			continue
+		}
+		failures++
+		if failures >= s.config.MaxFailedHealthChecks {
+			return fmt.Errorf("WebDriverAgent failed %d health checks in a row: %w", failures, err)
+		}
+	}
+}
+
+func (s *Supervisor) update(change func(status *Status)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	change(&s.status)
+}
+
+func (s *Supervisor) testConfig() testmanagerd.TestConfig {
+	return testmanagerd.TestConfig{
+		BundleId:           s.config.BundleID,
+		TestRunnerBundleId: s.config.TestRunnerBundleID,
+		XctestConfigName:   s.config.XCTestConfig,
+		Env:                s.config.Env,
+		Args:               s.config.Args,
+		Device:             s.device,
+		Listener:           testmanagerd.NewTestListener(s.config.LogWriter, s.config.LogWriter, os.TempDir()),
+	}
+}
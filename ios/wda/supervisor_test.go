@@ -0,0 +1,148 @@
+package wda
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/danielpaulus/go-ios/ios"
+	"github.com/danielpaulus/go-ios/ios/testmanagerd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckStatus(t *testing.T) {
+	t.Run("ready", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/status", r.URL.Path)
+			w.Write([]byte(`{"value":{"ready":true,"message":"WebDriverAgent is ready to accept commands"},"sessionId":"abc"}`))
+		}))
+		defer server.Close()
+
+		status, err := CheckStatus(context.Background(), server.URL)
+		require.NoError(t, err)
+		assert.True(t, status.Value.Ready)
+		assert.Equal(t, "abc", status.SessionID)
+	})
+	t.Run("not ready", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"value":{"ready":false,"message":"busy"}}`))
+		}))
+		defer server.Close()
+
+		_, err := CheckStatus(context.Background(), server.URL)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "busy")
+		}
+	})
+	t.Run("error status code", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer server.Close()
+
+		_, err := CheckStatus(context.Background(), server.URL)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "500")
+		}
+	})
+}
+
+type nopCloser struct{ closed atomic.Bool }
+
+func (c *nopCloser) Close() error {
+	c.closed.Store(true)
+	return nil
+}
+
+// fakeSupervisor returns a Supervisor whose runner blocks until its context is done and whose health checks
+// return the result of healthy
+func fakeSupervisor(healthy func() error) (*Supervisor, *atomic.Int32, *nopCloser) {
+	s := NewSupervisor(ios.DeviceEntry{}, Config{
+		HealthCheckInterval: 5 * time.Millisecond,
+		StartupTimeout:      time.Second,
+		RestartDelay:        time.Millisecond,
+	})
+	runs := &atomic.Int32{}
+	forwarder := &nopCloser{}
+	s.run = func(ctx context.Context, _ testmanagerd.TestConfig) ([]testmanagerd.TestSuite, error) {
+		runs.Add(1)
+		<-ctx.Done()
+		return nil, nil
+	}
+	s.forward = func(ios.DeviceEntry, uint16, uint16) (io.Closer, error) {
+		return forwarder, nil
+	}
+	s.checkStatus = func(context.Context, string) (ServerStatus, error) {
+		return ServerStatus{}, healthy()
+	}
+	return s, runs, forwarder
+}
+
+func TestSupervisorRunsAndStops(t *testing.T) {
+	s, runs, forwarder := fakeSupervisor(func() error { return nil })
+	assert.Equal(t, StateStopped, s.Status().State)
+
+	require.NoError(t, s.Start())
+	assert.Error(t, s.Start())
+	assert.Eventually(t, func() bool { return s.Status().State == StateRunning }, time.Second, time.Millisecond)
+	assert.Equal(t, "http://127.0.0.1:8100", s.Status().URL)
+
+	require.NoError(t, s.Stop())
+	assert.Equal(t, StateStopped, s.Status().State)
+	assert.Equal(t, int32(1), runs.Load())
+	assert.Equal(t, 0, s.Status().Restarts)
+	assert.True(t, forwarder.closed.Load())
+}
+
+func TestSupervisorRestartsUnresponsiveRunner(t *testing.T) {
+	var unhealthy atomic.Bool
+	s, runs, _ := fakeSupervisor(func() error {
+		if unhealthy.Load() {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+	require.NoError(t, s.Start())
+	defer s.Stop()
+	assert.Eventually(t, func() bool { return s.Status().State == StateRunning }, time.Second, time.Millisecond)
+
+	unhealthy.Store(true)
+	assert.Eventually(t, func() bool { return s.Status().Restarts == 1 }, time.Second, time.Millisecond)
+	unhealthy.Store(false)
+	assert.Eventually(t, func() bool { return s.Status().State == StateRunning }, time.Second, time.Millisecond)
+	assert.Equal(t, int32(2), runs.Load())
+	assert.Contains(t, s.Status().LastError, "connection refused")
+}
+
+func TestSupervisorRestartsEndedRunner(t *testing.T) {
+	s, runs, _ := fakeSupervisor(func() error { return nil })
+	s.run = func(ctx context.Context, _ testmanagerd.TestConfig) ([]testmanagerd.TestSuite, error) {
+		if runs.Add(1) == 1 {
+			return nil, errors.New("runner crashed")
+		}
+		<-ctx.Done()
+		return nil, nil
+	}
+	require.NoError(t, s.Start())
+	defer s.Stop()
+
+	assert.Eventually(t, func() bool { return s.Status().State == StateRunning }, time.Second, time.Millisecond)
+	assert.Equal(t, 1, s.Status().Restarts)
+	assert.Contains(t, s.Status().LastError, "runner crashed")
+}
+
+func TestSupervisorRestartsRunnerThatNeverGetsReady(t *testing.T) {
+	s, _, _ := fakeSupervisor(func() error { return errors.New("not yet") })
+	s.config.StartupTimeout = 20 * time.Millisecond
+	require.NoError(t, s.Start())
+	defer s.Stop()
+
+	assert.Eventually(t, func() bool { return s.Status().Restarts >= 1 }, time.Second, time.Millisecond)
+	assert.Contains(t, s.Status().LastError, "did not become ready")
+}
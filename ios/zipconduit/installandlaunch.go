@@ -0,0 +1,165 @@
+package zipconduit
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/danielpaulus/go-ios/ios"
+	"github.com/danielpaulus/go-ios/ios/installationproxy"
+	"github.com/danielpaulus/go-ios/ios/instruments"
+	log "github.com/sirupsen/logrus"
+	"howett.net/plist"
+)
+
+// appListedTimeout is how long InstallAndLaunch waits for installation_proxy to list an app after it was installed
+const appListedTimeout = 30 * time.Second
+
+// installAndLaunchSteps are the device operations InstallAndLaunch is composed of
+type installAndLaunchSteps struct {
+	isInstalled func(bundleID string) (bool, error)
+	install     func(appPath string) error
+	waitForApp  func(ctx context.Context, bundleID string) error
+	launch      func(bundleID string, args []interface{}, env map[string]interface{}) (uint64, error)
+	uninstall   func(bundleID string) error
+}
+
+// InstallAndLaunch installs the .app folder or ipa file at appPath with zipconduit, waits until installation_proxy
+// lists the app and launches it with instruments using args and env. It returns the pid of the launched app.
+// If a step fails after a fresh install, the app is uninstalled again. An app that was already installed before is
+// left in place, the previous version cannot be restored anyway and uninstalling it would delete its data.
+func InstallAndLaunch(device ios.DeviceEntry, appPath string, args []interface{}, env map[string]interface{}) (uint64, error) {
+	bundleID, err := BundleIDFromApp(appPath)
+	if err != nil {
+		return 0, fmt.Errorf("InstallAndLaunch: %w", err)
+	}
+	installationProxy, err := installationproxy.New(device)
+	if err != nil {
+		return 0, fmt.Errorf("InstallAndLaunch: cannot connect to installation proxy: %w", err)
+	}
+	defer installationProxy.Close()
+
+	steps := installAndLaunchSteps{
+		isInstalled: func(bundleID string) (bool, error) {
+			apps, err := installationProxy.BrowseUserApps()
+			if err != nil {
+				return false, err
+			}
+			for _, app := range apps {
+				if app.CFBundleIdentifier == bundleID {
+					return true, nil
+				}
+			}
+			return false, nil
+		},
+		install: func(appPath string) error {
+			conn, err := New(device)
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			return conn.SendFile(appPath)
+		},
+		waitForApp: func(ctx context.Context, bundleID string) error {
+			_, err := installationProxy.WaitForAppInstalled(ctx, bundleID)
+			return err
+		},
+		launch: func(bundleID string, args []interface{}, env map[string]interface{}) (uint64, error) {
+			pControl, err := instruments.NewProcessControl(device)
+			if err != nil {
+				return 0, err
+			}
+			defer pControl.Close()
+			return pControl.LaunchAppWithArgs(bundleID, args, env, nil)
+		},
+		uninstall: installationProxy.Uninstall,
+	}
+	return installAndLaunch(steps, appPath, bundleID, args, env)
+}
+
+func installAndLaunch(steps installAndLaunchSteps, appPath string, bundleID string, args []interface{}, env map[string]interface{}) (uint64, error) {
+	wasInstalled, err := steps.isInstalled(bundleID)
+	if err != nil {
+		return 0, fmt.Errorf("InstallAndLaunch: cannot check if %s is installed: %w", bundleID, err)
+	}
+	rollback := func(cause error) error {
+		if wasInstalled {
+			return cause
+		}
+		log.WithField("bundleID", bundleID).Info("uninstalling app after failed launch")
+		if err := steps.uninstall(bundleID); err != nil {
+			return errors.Join(cause, fmt.Errorf("InstallAndLaunch: rollback failed: %w", err))
+		}
+		return cause
+	}
+
+	if err := steps.install(appPath); err != nil {
+		return 0, rollback(fmt.Errorf("InstallAndLaunch: failed installing %s: %w", appPath, err))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), appListedTimeout)
+	defer cancel()
+	if err := steps.waitForApp(ctx, bundleID); err != nil {
+		return 0, rollback(fmt.Errorf("InstallAndLaunch: %w", err))
+	}
+	pid, err := steps.launch(bundleID, args, env)
+	if err != nil {
+		return 0, rollback(fmt.Errorf("InstallAndLaunch: failed launching %s: %w", bundleID, err))
+	}
+	return pid, nil
+}
+
+// BundleIDFromApp reads the CFBundleIdentifier from the Info.plist of the .app folder or ipa file at appPath
+func BundleIDFromApp(appPath string) (string, error) {
+	info, err := os.Stat(appPath)
+	if err != nil {
+		return "", err
+	}
+	var infoPlist []byte
+	if info.IsDir() {
+		infoPlist, err = os.ReadFile(filepath.Join(appPath, "Info.plist"))
+	} else {
+		infoPlist, err = readIpaInfoPlist(appPath)
+	}
+	if err != nil {
+		return "", fmt.Errorf("BundleIDFromApp: cannot read Info.plist: %w", err)
+	}
+	var bundleInfo struct {
+		CFBundleIdentifier string
+	}
+	if _, err := plist.Unmarshal(infoPlist, &bundleInfo); err != nil {
+		return "", fmt.Errorf("BundleIDFromApp: cannot parse Info.plist: %w", err)
+	}
+	if bundleInfo.CFBundleIdentifier == "" {
+		return "", errors.New("BundleIDFromApp: cannot find CFBundleIdentifier in Info.plist")
+	}
+	return bundleInfo.CFBundleIdentifier, nil
+}
+
+// readIpaInfoPlist returns the Info.plist of the app in the Payload folder of the ipa file
+func readIpaInfoPlist(ipaPath string) ([]byte, error) {
+	r, err := zip.OpenReader(ipaPath)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		dir, name := path.Split(f.Name)
+		if name != "Info.plist" || path.Dir(path.Clean(dir)) != "Payload" || !strings.HasSuffix(path.Clean(dir), ".app") {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return io.ReadAll(rc)
+	}
+	return nil, fmt.Errorf("no Payload/*.app/Info.plist in %s", ipaPath)
+}
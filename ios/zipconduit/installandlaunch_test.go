@@ -0,0 +1,113 @@
+package zipconduit
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testInfoPlist = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>CFBundleIdentifier</key>
+	<string>com.example.app</string>
+</dict>
+</plist>`
+
+// mockedSteps records the calls of InstallAndLaunch and fails the step named in failing
+func mockedSteps(installed bool, failing string, calls *[]string) installAndLaunchSteps {
+	step := func(name string) error {
+		*calls = append(*calls, name)
+		if name == failing {
+			return errors.New(name + " failed")
+		}
+		return nil
+	}
+	return installAndLaunchSteps{
+		isInstalled: func(string) (bool, error) { return installed, step("isInstalled") },
+		install:     func(string) error { return step("install") },
+		waitForApp:  func(context.Context, string) error { return step("waitForApp") },
+		launch: func(_ string, args []interface{}, env map[string]interface{}) (uint64, error) {
+			return 42, step("launch")
+		},
+		uninstall: func(string) error { return step("uninstall") },
+	}
+}
+
+func TestInstallAndLaunch(t *testing.T) {
+	var calls []string
+	pid, err := installAndLaunch(mockedSteps(false, "", &calls), "/tmp/app.ipa", "com.example.app", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(42), pid)
+	assert.Equal(t, []string{"isInstalled", "install", "waitForApp", "launch"}, calls)
+}
+
+func TestInstallAndLaunchRollsBackFreshInstall(t *testing.T) {
+	for _, failing := range []string{"install", "waitForApp", "launch"} {
+		t.Run(failing, func(t *testing.T) {
+			var calls []string
+			_, err := installAndLaunch(mockedSteps(false, failing, &calls), "/tmp/app.ipa", "com.example.app", nil, nil)
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), failing+" failed")
+			}
+			assert.Equal(t, "uninstall", calls[len(calls)-1])
+		})
+	}
+}
+
+func TestInstallAndLaunchKeepsPreviouslyInstalledApp(t *testing.T) {
+	var calls []string
+	_, err := installAndLaunch(mockedSteps(true, "launch", &calls), "/tmp/app.ipa", "com.example.app", nil, nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "launch failed")
+	}
+	assert.NotContains(t, calls, "uninstall")
+}
+
+func TestInstallAndLaunchReportsFailedRollback(t *testing.T) {
+	var calls []string
+	steps := mockedSteps(false, "launch", &calls)
+	steps.uninstall = func(string) error { return errors.New("uninstall failed") }
+	_, err := installAndLaunch(steps, "/tmp/app.ipa", "com.example.app", nil, nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "launch failed")
+	}
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "rollback failed: uninstall failed")
+	}
+}
+
+func TestBundleIDFromApp(t *testing.T) {
+	appDir := filepath.Join(t.TempDir(), "Example.app")
+	require.NoError(t, os.Mkdir(appDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(appDir, "Info.plist"), []byte(testInfoPlist), 0o644))
+
+	ipaPath := filepath.Join(t.TempDir(), "Example.ipa")
+	ipa, err := os.Create(ipaPath)
+	require.NoError(t, err)
+	w := zip.NewWriter(ipa)
+	for name, content := range map[string]string{
+		"Payload/Example.app/PlugIns/Ext.appex/Info.plist": "not the app",
+		"Payload/Example.app/Info.plist":                   testInfoPlist,
+	} {
+		f, err := w.Create(name)
+		require.NoError(t, err)
+		_, err = f.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	require.NoError(t, ipa.Close())
+
+	for _, appPath := range []string{appDir, ipaPath} {
+		bundleID, err := BundleIDFromApp(appPath)
+		require.NoError(t, err)
+		assert.Equal(t, "com.example.app", bundleID)
+	}
+}
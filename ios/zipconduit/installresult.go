@@ -0,0 +1,49 @@
+package zipconduit
+
+import (
+	"io"
+	"time"
+)
+
+// InstallResult contains the timing of an app installation
+type InstallResult struct {
+	// TransferDuration is the time it took to stream the app to the device
+	TransferDuration time.Duration
+	// ProcessingDuration is the time installd needed after the transfer was complete to install the app
+	ProcessingDuration time.Duration
+	// TotalDuration includes the local preparation like unzipping the ipa file
+	TotalDuration time.Duration
+	// BytesTransferred is the number of bytes sent to the device, including the zip headers
+	BytesTransferred uint64
+
+	start         time.Time
+	transferStart time.Time
+	transferEnd   time.Time
+}
+
+// countingWriter counts the bytes written to w
+type countingWriter struct {
+	w     io.Writer
+	count uint64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.count += uint64(n)
+	return n, err
+}
+
+func (r *InstallResult) startTransfer(w io.Writer) *countingWriter {
+	r.transferStart = time.Now()
+	return &countingWriter{w: w}
+}
+
+func (r *InstallResult) finishTransfer(transfer *countingWriter) {
+	r.transferEnd = time.Now()
+	r.TransferDuration = r.transferEnd.Sub(r.transferStart)
+	r.BytesTransferred = transfer.count
+}
+
+func (r *InstallResult) finishProcessing() {
+	r.ProcessingDuration = time.Since(r.transferEnd)
+}
@@ -1,6 +1,7 @@
 package zipconduit
 
 import (
+	"context"
 	"encoding/binary"
 	"hash/crc32"
 	"io"
@@ -9,6 +10,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/danielpaulus/go-ios/ios"
 	log "github.com/sirupsen/logrus"
@@ -87,28 +89,70 @@ func NewWithShimConnection(device ios.DeviceEntry) (*Connection, error) {
 // If you specify appFilePath to a file, it will try to Unzip it to a temp dir first and then send.
 // If appFilePath points to a directory, it will try to install the dir contents as an app.
 func (conn Connection) SendFile(appFilePath string) error {
+	return conn.SendFileCtx(context.Background(), appFilePath)
+}
+
+// SendFileCtx works like SendFile but aborts the installation when ctx is cancelled.
+// On cancellation the connection is closed before the transfer completes, which makes installd discard
+// the partially staged package. The local temp dir is removed as well and ctx.Err() is returned.
+func (conn Connection) SendFileCtx(ctx context.Context, appFilePath string) error {
+	_, err := conn.InstallCtx(ctx, appFilePath)
+	return err
+}
+
+// InstallCtx works like SendFileCtx and additionally returns how long the transfer of the app and the processing
+// by installd took and how many bytes were sent to the device
+func (conn Connection) InstallCtx(ctx context.Context, appFilePath string) (InstallResult, error) {
+	if err := ctx.Err(); err != nil {
+		return InstallResult{}, err
+	}
+	closed := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		defer close(closed)
+		log.Info("installation cancelled, closing zipconduit connection")
+		conn.deviceConn.Close()
+	})
+	defer func() {
+		// the connection must be closed before returning, if the installation was cancelled
+		if !stop() {
+			<-closed
+		}
+	}()
+
+	result, err := conn.sendFile(ctx, appFilePath)
+	if ctx.Err() != nil {
+		return result, ctx.Err()
+	}
+	return result, err
+}
+
+func (conn Connection) sendFile(ctx context.Context, appFilePath string) (InstallResult, error) {
+	result := InstallResult{start: time.Now()}
 	openedFile, err := os.Open(appFilePath)
 	if err != nil {
-		return err
+		return result, err
 	}
 
 	// Get the file information
 	info, err := openedFile.Stat()
 	openedFile.Close()
 	if err != nil {
-		return err
+		return result, err
 	}
 	if info.IsDir() {
-		return conn.sendDirectory(appFilePath)
+		err = conn.sendDirectory(ctx, appFilePath, &result)
+	} else {
+		err = conn.sendIpaFile(ctx, appFilePath, &result)
 	}
-	return conn.sendIpaFile(appFilePath)
+	result.TotalDuration = time.Since(result.start)
+	return result, err
 }
 
 func (conn Connection) Close() error {
 	return conn.deviceConn.Close()
 }
 
-func (conn Connection) sendDirectory(dir string) error {
+func (conn Connection) sendDirectory(ctx context.Context, dir string, result *InstallResult) error {
 	tmpDir, err := os.MkdirTemp("", "prefix")
 	if err != nil {
 		return err
@@ -142,22 +186,23 @@ func (conn Connection) sendDirectory(dir string) error {
 
 	init := newInitTransfer(dir + ".ipa")
 	log.Debugf("sending inittransfer %+v", init)
+	transfer := result.startTransfer(conn.deviceConn)
 	bytes, err := conn.plistCodec.Encode(init)
 	if err != nil {
 		return err
 	}
 
-	_, err = conn.deviceConn.Write(bytes)
+	_, err = transfer.Write(bytes)
 	if err != nil {
 		return err
 	}
 
 	log.Debug("writing meta inf")
-	err = AddFileToZip(conn.deviceConn, metainfFolder, tmpDir)
+	err = AddFileToZip(transfer, metainfFolder, tmpDir)
 	if err != nil {
 		return err
 	}
-	err = AddFileToZip(conn.deviceConn, metainfFile, tmpDir)
+	err = AddFileToZip(transfer, metainfFile, tmpDir)
 	if err != nil {
 		return err
 	}
@@ -166,21 +211,27 @@ func (conn Connection) sendDirectory(dir string) error {
 	log.Debug("sending files....")
 
 	for _, file := range unzippedFiles {
-		err := AddFileToZip(conn.deviceConn, file, dir)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := AddFileToZip(transfer, file, dir)
 		if err != nil {
 			return err
 		}
 	}
 	log.Debug("files sent, sending central header....")
-	_, err = conn.deviceConn.Write(centralDirectoryHeader)
+	_, err = transfer.Write(centralDirectoryHeader)
 	if err != nil {
 		return err
 	}
+	result.finishTransfer(transfer)
 
-	return conn.waitForInstallation()
+	err = conn.waitForInstallation()
+	result.finishProcessing()
+	return err
 }
 
-func (conn Connection) sendIpaFile(ipaFile string) error {
+func (conn Connection) sendIpaFile(ctx context.Context, ipaFile string, result *InstallResult) error {
 	tmpDir, err := os.MkdirTemp("", "prefix")
 	if err != nil {
 		return err
@@ -205,22 +256,23 @@ func (conn Connection) sendIpaFile(ipaFile string) error {
 
 	init := newInitTransfer(ipaFile)
 	log.Debugf("sending inittransfer %+v", init)
+	transfer := result.startTransfer(conn.deviceConn)
 	bytes, err := conn.plistCodec.Encode(init)
 	if err != nil {
 		return err
 	}
 
-	_, err = conn.deviceConn.Write(bytes)
+	_, err = transfer.Write(bytes)
 	if err != nil {
 		return err
 	}
 
 	log.Debug("writing meta inf")
-	err = AddFileToZip(conn.deviceConn, metainfFolder, tmpDir)
+	err = AddFileToZip(transfer, metainfFolder, tmpDir)
 	if err != nil {
 		return err
 	}
-	err = AddFileToZip(conn.deviceConn, metainfFile, tmpDir)
+	err = AddFileToZip(transfer, metainfFile, tmpDir)
 	if err != nil {
 		return err
 	}
@@ -229,18 +281,24 @@ func (conn Connection) sendIpaFile(ipaFile string) error {
 	log.Debug("sending files....")
 
 	for _, file := range unzippedFiles {
-		err := AddFileToZip(conn.deviceConn, file, tmpDir)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := AddFileToZip(transfer, file, tmpDir)
 		if err != nil {
 			return err
 		}
 	}
 	log.Debug("files sent, sending central header....")
-	_, err = conn.deviceConn.Write(centralDirectoryHeader)
+	_, err = transfer.Write(centralDirectoryHeader)
 	if err != nil {
 		return err
 	}
+	result.finishTransfer(transfer)
 
-	return conn.waitForInstallation()
+	err = conn.waitForInstallation()
+	result.finishProcessing()
+	return err
 }
 
 func (conn Connection) waitForInstallation() error {
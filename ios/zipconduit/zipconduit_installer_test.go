@@ -0,0 +1,135 @@
+package zipconduit
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/danielpaulus/go-ios/ios"
+	"github.com/stretchr/testify/assert"
+)
+
+// cancellingConn simulates a zipconduit connection. It cancels the install once cancelAfter bytes have been
+// written and behaves like a closed connection after Close was called.
+type cancellingConn struct {
+	mu          sync.Mutex
+	written     int
+	cancelAfter int
+	cancel      context.CancelFunc
+	closed      chan struct{}
+	closeOnce   sync.Once
+}
+
+func (c *cancellingConn) Write(p []byte) (int, error) {
+	select {
+	case <-c.closed:
+		return 0, io.ErrClosedPipe
+	default:
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.written += len(p)
+	if c.written > c.cancelAfter {
+		c.cancel()
+	}
+	return len(p), nil
+}
+
+func (c *cancellingConn) Read(p []byte) (int, error) {
+	<-c.closed
+	return 0, io.EOF
+}
+
+func (c *cancellingConn) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+	return nil
+}
+
+func TestSendFileCtxCancelledMidTransfer(t *testing.T) {
+	appDir := filepath.Join(t.TempDir(), "Test.app")
+	assert.NoError(t, os.Mkdir(appDir, 0o777))
+	for _, name := range []string{"Info.plist", "Test", "embedded.mobileprovision"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(appDir, name), make([]byte, 64*1024), 0o666))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mockConn := &cancellingConn{cancelAfter: 80 * 1024, cancel: cancel, closed: make(chan struct{})}
+	conn := Connection{deviceConn: mockConn, plistCodec: ios.NewPlistCodec()}
+
+	err := conn.SendFileCtx(ctx, appDir)
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, mockConn.written, 3*64*1024, "transfer should stop before all files are sent")
+	select {
+	case <-mockConn.closed:
+	default:
+		t.Error("connection must be closed on cancellation")
+	}
+}
+
+func TestSendFileCtxAlreadyCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	mockConn := &cancellingConn{cancel: cancel, closed: make(chan struct{})}
+	conn := Connection{deviceConn: mockConn, plistCodec: ios.NewPlistCodec()}
+
+	err := conn.SendFileCtx(ctx, t.TempDir())
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 0, mockConn.written)
+}
+
+// progressConn accepts the transfer and answers with progress updates of installd once it was completely sent
+type progressConn struct {
+	written  int
+	progress io.Reader
+}
+
+func (c *progressConn) Write(p []byte) (int, error) {
+	c.written += len(p)
+	return len(p), nil
+}
+
+func (c *progressConn) Read(p []byte) (int, error) {
+	time.Sleep(5 * time.Millisecond)
+	return c.progress.Read(p)
+}
+
+func (c *progressConn) Close() error {
+	return nil
+}
+
+func TestInstallCtxResult(t *testing.T) {
+	appDir := filepath.Join(t.TempDir(), "Test.app")
+	assert.NoError(t, os.Mkdir(appDir, 0o777))
+	assert.NoError(t, os.WriteFile(filepath.Join(appDir, "Test"), make([]byte, 16*1024), 0o666))
+
+	codec := ios.NewPlistCodec()
+	var progress bytes.Buffer
+	for _, update := range []map[string]interface{}{
+		{"InstallProgressDict": map[string]interface{}{"PercentComplete": uint64(40), "Status": "VerifyingApplication"}},
+		{"InstallProgressDict": map[string]interface{}{"PercentComplete": uint64(90), "Status": "GeneratingApplicationMap"}},
+		{"Status": "DataComplete"},
+	} {
+		msg, err := codec.Encode(update)
+		assert.NoError(t, err)
+		progress.Write(msg)
+	}
+	mockConn := &progressConn{progress: &progress}
+	conn := Connection{deviceConn: mockConn, plistCodec: codec}
+
+	result, err := conn.InstallCtx(context.Background(), appDir)
+
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(mockConn.written), result.BytesTransferred)
+	assert.Greater(t, result.BytesTransferred, uint64(16*1024))
+	assert.Greater(t, result.TransferDuration, time.Duration(0))
+	assert.GreaterOrEqual(t, result.ProcessingDuration, 15*time.Millisecond, "processing lasts until installd reports completion")
+	assert.GreaterOrEqual(t, result.TotalDuration, result.TransferDuration+result.ProcessingDuration)
+}
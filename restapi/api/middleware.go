@@ -14,8 +14,8 @@ import (
 // DeviceMiddleware makes sure a udid was specified and that a device with that UDID
 // is connected with the host. Will return 404 if the device is not found or 500 if something
 // else went wrong. Use `device := c.MustGet(IOS_KEY).(ios.DeviceEntry)` to acquire the device
-// in downstream handlers.
-func DeviceMiddleware() gin.HandlerFunc {
+// in downstream handlers. Services of the device are started on the lockdown session of pool, if it is not nil.
+func DeviceMiddleware(pool *ios.ConnectionPool) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		udid := c.Param("udid")
 
@@ -32,6 +32,7 @@ func DeviceMiddleware() gin.HandlerFunc {
 			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err})
 			return
 		}
+		device.ConnectionPool = pool
 
 		info, err := tunnel.TunnelInfoForDevice(device.Properties.SerialNumber, ios.HttpApiHost(), ios.HttpApiPort())
 		if err == nil {
@@ -40,7 +41,7 @@ func DeviceMiddleware() gin.HandlerFunc {
 			device.UserspaceTUNPort = info.UserspaceTUNPort
 			device.UserspaceTUN = info.UserspaceTUN
 
-			device, err = deviceWithRsdProvider(device, udid, info.Address, info.RsdPort)
+			device, err = deviceWithRsdProvider(device, pool, info.Address, info.RsdPort)
 			if err != nil {
 				c.Error(err)
 				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}) // Return an error response
@@ -55,27 +56,32 @@ func DeviceMiddleware() gin.HandlerFunc {
 	}
 }
 
-func deviceWithRsdProvider(device ios.DeviceEntry, udid string, address string, rsdPort int) (ios.DeviceEntry, error) {
-	rsdService, err := ios.NewWithAddrPortDevice(address, rsdPort, device)
-	if err != nil {
-		return device, err
+// deviceWithRsdProvider adds the RSD services of the device reachable through the tunnel at address and rsdPort,
+// the handshake is only done for the first request through a tunnel. Without a connection pool, f.ex. if the router
+// is used without Serve, the handshake is done for every request
+func deviceWithRsdProvider(device ios.DeviceEntry, pool *ios.ConnectionPool, address string, rsdPort int) (ios.DeviceEntry, error) {
+	var rsdProvider ios.RsdPortProvider
+	var err error
+	if pool != nil {
+		rsdProvider, err = pool.Rsd(device, address, rsdPort)
+	} else {
+		rsdProvider, err = rsdHandshake(device, address, rsdPort)
 	}
-
-	defer rsdService.Close()
-	rsdProvider, err := rsdService.Handshake()
 	if err != nil {
 		return device, err
 	}
+	device.Address = address
+	device.Rsd = rsdProvider
+	return device, nil
+}
 
-	device1, err := ios.GetDeviceWithAddress(udid, address, rsdProvider)
+func rsdHandshake(device ios.DeviceEntry, address string, rsdPort int) (ios.RsdPortProvider, error) {
+	rsdService, err := ios.NewWithAddrPortDevice(address, rsdPort, device)
 	if err != nil {
-		return device, err
+		return nil, err
 	}
-
-	device1.UserspaceTUN = device.UserspaceTUN
-	device1.UserspaceTUNPort = device.UserspaceTUNPort
-
-	return device1, nil
+	defer rsdService.Close()
+	return rsdService.Handshake()
 }
 
 const IOS_KEY = "go_ios_device"
@@ -0,0 +1,21 @@
+package api
+
+import (
+	"net"
+	"testing"
+
+	"github.com/danielpaulus/go-ios/ios"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeviceWithRsdProviderWithoutConnectionPool(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+	device := ios.DeviceEntry{Properties: ios.DeviceProperties{SerialNumber: "abcdefgh"}}
+
+	_, err = deviceWithRsdProvider(device, nil, "127.0.0.1", port)
+	assert.Error(t, err, "the handshake is done directly and fails without a tunnel")
+}
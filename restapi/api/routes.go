@@ -1,18 +1,20 @@
 package api
 
 import (
+	"github.com/danielpaulus/go-ios/ios"
 	"github.com/gin-gonic/gin"
 )
 
 var streamingMiddleWare = StreamingHeaderMiddleware()
 
-func registerRoutes(router *gin.RouterGroup) {
+func registerRoutes(router *gin.RouterGroup, pool *ios.ConnectionPool) {
 	router.GET("/list", List)
 
 	device := router.Group("/device/:udid")
-	device.Use(DeviceMiddleware())
+	device.Use(DeviceMiddleware(pool))
 	simpleDeviceRoutes(device)
 	appRoutes(device)
+	testRoutes(device)
 }
 
 func simpleDeviceRoutes(device *gin.RouterGroup) {
@@ -52,3 +54,9 @@ func appRoutes(group *gin.RouterGroup) {
 	router.POST("/install", InstallApp)
 	router.POST("/uninstall", UninstallApp)
 }
+
+func testRoutes(group *gin.RouterGroup) {
+	router := group.Group("/tests")
+	router.Use(LimitNumClientsUDID())
+	router.POST("/run", RunTests)
+}
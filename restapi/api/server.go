@@ -1,16 +1,39 @@
 package api
 
 import (
+	"context"
 	"io"
 	"os"
+	"time"
 
+	"github.com/danielpaulus/go-ios/ios"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
+// Main starts the API server on port 8080
 func Main() {
+	err := Serve(":8080")
+	if err != nil {
+		logrus.Error(err)
+	}
+}
+
+// Serve starts the API server on address, f.ex. "127.0.0.1:8080", and returns when the server fails
+func Serve(address string) error {
+	// the pool keeps the lockdown sessions, pair records and RSD services of the devices warm between requests
+	connectionPool := ios.NewConnectionPool(5 * time.Minute)
+	defer connectionPool.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		if err := connectionPool.EvictDetachedDevices(ctx); err != nil {
+			logrus.WithError(err).Warn("pooled connections of detached devices will not be closed")
+		}
+	}()
+
 	router := gin.Default()
 	log := logrus.New()
 	myfile, _ := os.Create("go-ios.log")
@@ -18,12 +41,9 @@ func Main() {
 	router.Use(MyLogger(log), gin.Recovery())
 
 	v1 := router.Group("/api/v1")
-	registerRoutes(v1)
+	registerRoutes(v1, connectionPool)
 
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-	err := router.Run(":8080")
-	if err != nil {
-		log.Error(err)
-	}
+	return router.Run(address)
 }
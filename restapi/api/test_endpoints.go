@@ -0,0 +1,93 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/danielpaulus/go-ios/ios"
+	"github.com/danielpaulus/go-ios/ios/testmanagerd"
+	"github.com/gin-gonic/gin"
+)
+
+// RunTestsRequest configures a test run, the fields mirror the options of `ios runtest`
+type RunTestsRequest struct {
+	BundleID           string         `json:"bundleId"`
+	TestRunnerBundleID string         `json:"testRunnerBundleId" binding:"required"`
+	XctestConfig       string         `json:"xctestConfig"`
+	TestsToRun         []string       `json:"testsToRun"`
+	TestsToSkip        []string       `json:"testsToSkip"`
+	Env                map[string]any `json:"env"`
+	Args               []string       `json:"args"`
+	XCTest             bool           `json:"xctest"`
+}
+
+// runTestWithConfig runs the tests, it is replaced in tests
+var runTestWithConfig = testmanagerd.RunTestWithConfig
+
+// flushWriter flushes every write to the client, so events of a streamed test run arrive while the tests run
+type flushWriter struct {
+	w gin.ResponseWriter
+}
+
+func (f flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	f.w.Flush()
+	return n, err
+}
+
+// RunTests runs XCTests or XCUITests on a device
+// @Summary      Run tests on a device
+// @Description  Runs the tests of an installed test runner and returns the results when all tests finished. With
+// @Description  events=true the events of the run are streamed as json objects separated by line breaks instead.
+// @Tags         tests
+// @Accept       json
+// @Produce      json
+// @Param        request body RunTestsRequest true "test run configuration"
+// @Param        events query bool false "stream the events of the test run"
+// @Success      200 {object} []testmanagerd.TestSuite
+// @Failure      422 {object} GenericResponse
+// @Failure      500 {object} GenericResponse
+// @Router       /device/{udid}/tests/run [post]
+func RunTests(c *gin.Context) {
+	device := c.MustGet(IOS_KEY).(ios.DeviceEntry)
+
+	var request RunTestsRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, GenericResponse{Error: err.Error()})
+		return
+	}
+
+	config := testmanagerd.TestConfig{
+		BundleId:           request.BundleID,
+		TestRunnerBundleId: request.TestRunnerBundleID,
+		XctestConfigName:   request.XctestConfig,
+		Env:                request.Env,
+		Args:               request.Args,
+		TestsToRun:         request.TestsToRun,
+		TestsToSkip:        request.TestsToSkip,
+		XcTest:             request.XCTest,
+		Device:             device,
+		Listener:           testmanagerd.NewTestListener(io.Discard, io.Discard, os.TempDir()),
+	}
+
+	if c.Query("events") != "true" {
+		suites, err := runTestWithConfig(c.Request.Context(), config)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, GenericResponse{Error: err.Error()})
+			return
+		}
+		c.IndentedJSON(http.StatusOK, suites)
+		return
+	}
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Cache-Control", "no-cache")
+	c.Status(http.StatusOK)
+	config.EventStream = flushWriter{c.Writer}
+	_, err := runTestWithConfig(c.Request.Context(), config)
+	if err != nil {
+		c.Writer.Write([]byte(MustMarshal(GenericResponse{Error: err.Error()})))
+		c.Writer.Write([]byte("\n"))
+	}
+}
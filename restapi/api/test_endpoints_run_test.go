@@ -0,0 +1,92 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/danielpaulus/go-ios/ios"
+	"github.com/danielpaulus/go-ios/ios/testmanagerd"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testDevice = ios.DeviceEntry{Properties: ios.DeviceProperties{SerialNumber: "abcdefgh"}}
+
+// stubTestRunner replaces the test runner of RunTests with run for the duration of the test
+func stubTestRunner(t *testing.T, run func(ctx context.Context, config testmanagerd.TestConfig) ([]testmanagerd.TestSuite, error)) {
+	original := runTestWithConfig
+	runTestWithConfig = run
+	t.Cleanup(func() { runTestWithConfig = original })
+}
+
+func postRunTests(target string, body string) *httptest.ResponseRecorder {
+	r := gin.New()
+	r.POST("/tests/run", func(c *gin.Context) {
+		c.Set(IOS_KEY, testDevice)
+	}, RunTests)
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestRunTestsReturnsResults(t *testing.T) {
+	var config testmanagerd.TestConfig
+	stubTestRunner(t, func(ctx context.Context, c testmanagerd.TestConfig) ([]testmanagerd.TestSuite, error) {
+		config = c
+		return []testmanagerd.TestSuite{{Name: "LoginUITests", TestCases: []testmanagerd.TestCase{{ClassName: "LoginUITests", MethodName: "testLogin", Status: testmanagerd.StatusPassed}}}}, nil
+	})
+
+	w := postRunTests("/tests/run", `{"bundleId": "com.example.app", "testRunnerBundleId": "com.example.appUITests.xctrunner", "xctestConfig": "appUITests.xctest", "testsToRun": ["LoginUITests/testLogin"], "env": {"KEY": "value"}, "args": ["-flag"]}`)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var suites []testmanagerd.TestSuite
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &suites))
+	require.Len(t, suites, 1)
+	assert.Equal(t, testmanagerd.StatusPassed, suites[0].TestCases[0].Status)
+
+	assert.Equal(t, "com.example.app", config.BundleId)
+	assert.Equal(t, "com.example.appUITests.xctrunner", config.TestRunnerBundleId)
+	assert.Equal(t, "appUITests.xctest", config.XctestConfigName)
+	assert.Equal(t, []string{"LoginUITests/testLogin"}, config.TestsToRun)
+	assert.Equal(t, map[string]any{"KEY": "value"}, config.Env)
+	assert.Equal(t, []string{"-flag"}, config.Args)
+	assert.Equal(t, testDevice, config.Device)
+	assert.NotNil(t, config.Listener)
+	assert.Nil(t, config.EventStream)
+}
+
+func TestRunTestsReportsFailure(t *testing.T) {
+	stubTestRunner(t, func(ctx context.Context, c testmanagerd.TestConfig) ([]testmanagerd.TestSuite, error) {
+		return nil, errors.New("cannot start test runner")
+	})
+
+	w := postRunTests("/tests/run", `{"testRunnerBundleId": "com.example.appUITests.xctrunner"}`)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "cannot start test runner")
+}
+
+func TestRunTestsStreamsEvents(t *testing.T) {
+	stubTestRunner(t, func(ctx context.Context, c testmanagerd.TestConfig) ([]testmanagerd.TestSuite, error) {
+		require.NotNil(t, c.EventStream)
+		_, err := c.EventStream.Write([]byte(`{"type":"testCaseStarted"}` + "\n"))
+		require.NoError(t, err)
+		return nil, errors.New("test session crashed")
+	})
+
+	w := postRunTests("/tests/run?events=true", `{"testRunnerBundleId": "com.example.appUITests.xctrunner"}`)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Equal(t, `{"type":"testCaseStarted"}`, lines[0])
+	assert.Contains(t, lines[1], "test session crashed")
+}
@@ -0,0 +1,23 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/danielpaulus/go-ios/restapi/api"
+)
+
+func TestRunTestsRequiresTestRunner(t *testing.T) {
+	r := getRouter()
+	r.POST("/tests/run", api.RunTests)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/tests/run", strings.NewReader(`{"bundleId": "com.example.app"}`))
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status %d, got %d: %s", http.StatusUnprocessableEntity, w.Code, w.Body.String())
+	}
+}
@@ -8,6 +8,7 @@ require (
 	github.com/danielpaulus/go-ios v1.0.91
 	github.com/gin-gonic/gin v1.8.1
 	github.com/sirupsen/logrus v1.9.3
+	github.com/stretchr/testify v1.8.4
 	github.com/swaggo/files v0.0.0-20220728132757-551d4a08d97a
 	github.com/swaggo/gin-swagger v1.5.2
 	github.com/swaggo/swag v1.16.3
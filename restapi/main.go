@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"os"
 
 	"github.com/danielpaulus/go-ios/restapi/api"
@@ -24,6 +25,10 @@ import (
 
 // @securityDefinitions.basic  BasicAuth
 func main() {
-	log.WithFields(log.Fields{"args": os.Args, "version": api.GetVersion()}).Infof("starting go-iOS-API")
-	api.Main()
+	address := flag.String("address", ":8080", "address the API listens on, f.ex. 127.0.0.1:8080")
+	flag.Parse()
+	log.WithFields(log.Fields{"args": os.Args, "version": api.GetVersion(), "address": *address}).Infof("starting go-iOS-API")
+	if err := api.Serve(*address); err != nil {
+		log.Fatal(err)
+	}
 }